@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 分配方式
+const (
+	abByRule   = "rule"
+	abByRandom = "random"
+)
+
+// ABTestConfig 新池在两个策略变体之间分配（按规则优先，其次按权重随机），用真实资金对比参数效果
+type ABTestConfig struct {
+	Enabled  bool        `json:"enabled"`
+	Variants []ABVariant `json:"variants"` // 恰好两个
+	Rules    []ABRule    `json:"rules"`
+}
+
+// ABVariant 策略变体，参数取自同名档位（profiles）
+type ABVariant struct {
+	Name    string  `json:"name"`
+	Profile string  `json:"profile"`
+	Weight  float64 `json:"weight"` // 随机分配权重（两个都为 0 时各 50%）
+}
+
+// ABRule 信号字段等于指定值时固定分配到某个变体（如 source = momentum）
+type ABRule struct {
+	Field   string `json:"field"`
+	Equals  string `json:"equals"`
+	Variant string `json:"variant"`
+}
+
+// ABAssignment 单个池的分配结果（data/state/ab_assignments.json: pool -> 分配）
+type ABAssignment struct {
+	Variant    string `json:"variant"`
+	By         string `json:"by"` // rule / random
+	AssignedAt string `json:"assignedAt"`
+}
+
+// ABVariantReport 变体的对比指标
+type ABVariantReport struct {
+	Variant    string     `json:"variant"`
+	Profile    string     `json:"profile"`
+	Pools      int        `json:"pools"`
+	OpenPools  int        `json:"openPools"`
+	FeesUSD    float64    `json:"feesUSD"` // 累计已领取的手续费与奖励
+	PnL        PnLSummary `json:"pnl"`
+	PnLPercent float64    `json:"pnlPercent"` // (已实现 + 未实现) / 成本
+}
+
+var abMutex sync.Mutex
+
+func (c ABTestConfig) validate(profiles map[string]ProfileConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Variants) != 2 {
+		return fmt.Errorf("abTest.variants 必须恰好包含两个变体")
+	}
+	names := map[string]bool{}
+	for _, v := range c.Variants {
+		if v.Name == "" || names[v.Name] {
+			return fmt.Errorf("abTest.variants 名称为空或重复: %q", v.Name)
+		}
+		names[v.Name] = true
+		if _, ok := profiles[v.Profile]; !ok {
+			return fmt.Errorf("abTest.variants.%s 引用了不存在的档位: %s", v.Name, v.Profile)
+		}
+		if v.Weight < 0 {
+			return fmt.Errorf("abTest.variants.%s.weight 不能为负数", v.Name)
+		}
+	}
+	for _, r := range c.Rules {
+		if r.Field == "" || !names[r.Variant] {
+			return fmt.Errorf("abTest.rules 字段为空或引用了不存在的变体: %s", r.Variant)
+		}
+	}
+	return nil
+}
+
+func loadABAssignments() map[string]ABAssignment {
+	assignments := map[string]ABAssignment{}
+	if err := loadStateFile("ab_assignments", &assignments); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return assignments
+}
+
+// 按规则或权重选择变体
+func (c ABTestConfig) pick(data map[string]interface{}) (string, string) {
+	for _, r := range c.Rules {
+		if v, _ := data[r.Field].(string); v == r.Equals {
+			return r.Variant, abByRule
+		}
+	}
+	a, b := c.Variants[0], c.Variants[1]
+	if a.Weight+b.Weight <= 0 {
+		a.Weight, b.Weight = 1, 1
+	}
+	if rand.Float64()*(a.Weight+b.Weight) < a.Weight {
+		return a.Name, abByRandom
+	}
+	return b.Name, abByRandom
+}
+
+// assignVariant 为新池分配变体（已分配的池保持原变体），未启用时返回空
+func assignVariant(poolAddress string, data map[string]interface{}) string {
+	cfg := currentConfig().ABTest
+	if !cfg.Enabled {
+		return ""
+	}
+	abMutex.Lock()
+	defer abMutex.Unlock()
+	assignments := loadABAssignments()
+	if a, ok := assignments[poolAddress]; ok {
+		return a.Variant
+	}
+	variant, by := cfg.pick(data)
+	assignments[poolAddress] = ABAssignment{Variant: variant, By: by, AssignedAt: time.Now().Format(time.RFC3339)}
+	if err := saveStateFile("ab_assignments", assignments); err != nil {
+		logOutput("❌ 保存A/B分配失败: %v\n", err)
+	}
+	metricABAssignments.Inc(variant)
+	logInfo("🧪 A/B 分配", "pool", poolAddress, "variant", variant, "by", by)
+	return variant
+}
+
+// 池所属的变体（未参与 A/B 时为空）
+func poolVariant(poolAddress string) string {
+	if !currentConfig().ABTest.Enabled {
+		return ""
+	}
+	abMutex.Lock()
+	defer abMutex.Unlock()
+	return loadABAssignments()[poolAddress].Variant
+}
+
+func variantProfile(variant string) string {
+	for _, v := range currentConfig().ABTest.Variants {
+		if v.Name == variant {
+			return v.Profile
+		}
+	}
+	return ""
+}
+
+// 池开仓使用的档位：参与 A/B 的池使用变体档位，否则为当前档位
+func poolProfile(poolAddress string) (string, ProfileConfig) {
+	if name := variantProfile(poolVariant(poolAddress)); name != "" {
+		return name, currentConfig().Profiles[name]
+	}
+	return activeProfile()
+}
+
+// 按变体汇总盈亏与手续费（以盈亏台账中记录的变体为准）
+func buildABReport() []ABVariantReport {
+	reports := map[string]*ABVariantReport{}
+	for _, v := range currentConfig().ABTest.Variants {
+		reports[v.Name] = &ABVariantReport{Variant: v.Name, Profile: v.Profile}
+	}
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	for _, p := range ledger {
+		if p.Variant == "" {
+			continue
+		}
+		r, ok := reports[p.Variant]
+		if !ok {
+			r = &ABVariantReport{Variant: p.Variant}
+			reports[p.Variant] = r
+		}
+		s := p.summary()
+		r.Pools++
+		if s.State == "open" {
+			r.OpenPools++
+		}
+		for _, v := range p.Positions {
+			r.FeesUSD += v.ClaimedUSD
+		}
+		r.PnL.accumulate(s)
+	}
+	result := make([]ABVariantReport, 0, len(reports))
+	for _, r := range reports {
+		if r.PnL.CostSOL > 0 {
+			r.PnLPercent = (r.PnL.RealizedSOL + r.PnL.UnrealizedSOL) / r.PnL.CostSOL * 100
+		}
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Variant < result[b].Variant })
+	return result
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccountSubscribeConfig 通过 Solana PubSub（accountSubscribe）订阅持仓池的 LbPair、仓位与 bin array 账户，
+// 账户变化时更新状态并按事件触发领取、价格获取与再平衡
+type AccountSubscribeConfig struct {
+	Enabled                 bool   `json:"enabled"`
+	WSURL                   string `json:"wsUrl"`                   // 为空时由 walletWatch.rpcUrl 推导（https→wss、http→ws）
+	Commitment              string `json:"commitment"`              // processed / confirmed / finalized
+	RefreshSeconds          int    `json:"refreshSeconds"`          // 重新扫描持仓、增减订阅并保存状态的间隔
+	ClaimOnChange           bool   `json:"claimOnChange"`           // 仓位有新的未领取手续费或奖励时加入领取（门槛仍由 claimPolicy 判断）
+	ClaimMinIntervalSeconds int    `json:"claimMinIntervalSeconds"` // 同一池由事件触发领取的最小间隔
+	PriceOnChange           bool   `json:"priceOnChange"`           // 池 active bin 变化时加入价格获取（止损止盈等随价格检查）
+	PriceMinIntervalSeconds int    `json:"priceMinIntervalSeconds"` // 同一池由事件触发价格获取的最小间隔
+	RebalanceOnChange       bool   `json:"rebalanceOnChange"`       // active bin 变化时立即按 rebalance 规则检查（需启用 rebalance）
+	PollEvery               int    `json:"pollEvery"`               // 订阅正常的池，定时领取与价格任务每 N 轮才处理一次（兜底），1 表示不减少
+}
+
+// AccountSubscription 一个持仓池的订阅状态（GET /subscriptions，data/state/account_subscriptions.json）
+type AccountSubscription struct {
+	PoolAddress      string    `json:"poolAddress"`
+	Position         string    `json:"position"`
+	TokenAddress     string    `json:"tokenAddress,omitempty"`
+	ActiveBin        int       `json:"activeBin"`
+	LowerBin         int       `json:"lowerBin"`
+	UpperBin         int       `json:"upperBin"`
+	InRange          bool      `json:"inRange"`
+	PendingFeeX      string    `json:"pendingFeeX"` // 未领取手续费（代币最小单位）
+	PendingFeeY      string    `json:"pendingFeeY"`
+	PendingRewards   [2]string `json:"pendingRewards"`
+	Slot             uint64    `json:"slot"`
+	UpdatedAt        string    `json:"updatedAt,omitempty"`
+	Events           int       `json:"events"` // 收到的账户变化通知数
+	LastClaimTrigger string    `json:"lastClaimTrigger,omitempty"`
+	LastPriceTrigger string    `json:"lastPriceTrigger,omitempty"`
+	Live             bool      `json:"live"` // 池、仓位与 bin array 均已订阅
+
+	seen bool // 已读取过 LbPair，之后的 active bin 变化才触发事件
+}
+
+// AccountSubscriptionStatus 订阅连接与各池状态
+type AccountSubscriptionStatus struct {
+	Enabled       bool                  `json:"enabled"`
+	Connected     bool                  `json:"connected"`
+	ConnectedAt   string                `json:"connectedAt,omitempty"`
+	LastError     string                `json:"lastError,omitempty"`
+	Subscriptions int                   `json:"subscriptions"` // 已订阅的账户数
+	Pools         []AccountSubscription `json:"pools"`
+}
+
+// accountSubscriber 单连接的订阅管理：读循环把消息交给主循环，订阅表与账户数据只在主循环中修改
+type accountSubscriber struct {
+	mu            sync.Mutex
+	conn          *wsConn
+	connectedAt   time.Time
+	lastError     string
+	nextID        int
+	requests      map[int]string    // accountSubscribe 请求 id -> 账户
+	subIDs        map[string]int    // 账户 -> 订阅 id
+	accounts      map[int]string    // 订阅 id -> 账户
+	data          map[string][]byte // 账户最新数据
+	pools         map[string]*AccountSubscription
+	positionPool  map[string]string   // 仓位 -> 池
+	binArrayPools map[string][]string // bin array -> 池
+	lastClaim     map[string]time.Time
+	lastPrice     map[string]time.Time
+	dirty         bool
+	pollRounds    map[string]int // 定时任务 -> 轮次（pollEvery）
+}
+
+var accountSub = &accountSubscriber{
+	data:          map[string][]byte{},
+	pools:         map[string]*AccountSubscription{},
+	positionPool:  map[string]string{},
+	binArrayPools: map[string][]string{},
+	lastClaim:     map[string]time.Time{},
+	lastPrice:     map[string]time.Time{},
+	pollRounds:    map[string]int{},
+}
+
+func (c AccountSubscribeConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WSURL == "" && watch.RPCURL == "" {
+		return fmt.Errorf("accountSubscribe 需要 wsUrl 或 walletWatch.rpcUrl")
+	}
+	if c.WSURL != "" && !strings.HasPrefix(c.WSURL, "ws://") && !strings.HasPrefix(c.WSURL, "wss://") {
+		return fmt.Errorf("accountSubscribe.wsUrl 需以 ws:// 或 wss:// 开头")
+	}
+	switch c.Commitment {
+	case "processed", "confirmed", "finalized":
+	default:
+		return fmt.Errorf("accountSubscribe.commitment 仅支持 processed、confirmed、finalized")
+	}
+	if c.RefreshSeconds <= 0 || c.PollEvery <= 0 {
+		return fmt.Errorf("accountSubscribe.refreshSeconds、pollEvery 必须大于0")
+	}
+	if c.ClaimMinIntervalSeconds < 0 || c.PriceMinIntervalSeconds < 0 {
+		return fmt.Errorf("accountSubscribe.claimMinIntervalSeconds、priceMinIntervalSeconds 不能为负数")
+	}
+	return nil
+}
+
+// PubSub 地址：未配置时把 walletWatch.rpcUrl 的 http(s) 换成 ws(s)
+func accountSubscribeURL() string {
+	cfg := currentConfig().AccountSubscribe
+	if cfg.WSURL != "" {
+		return cfg.WSURL
+	}
+	u := currentConfig().WalletWatch.RPCURL
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		return "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		return "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	return u
+}
+
+// startAccountSubscriptions 保持订阅连接，断线后 5 秒重连并重新订阅（演示模式没有链上仓位，不启动）
+func startAccountSubscriptions() {
+	cfg := currentConfig().AccountSubscribe
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	logOutput("📡 启动账户订阅（commitment %s，每%ds 同步持仓）\n", cfg.Commitment, cfg.RefreshSeconds)
+	for {
+		err := accountSub.run(globalCtx)
+		if globalCtx.Err() != nil {
+			logOutput("🛑 收到关闭信号，停止账户订阅\n")
+			accountSub.saveState()
+			return
+		}
+		accountSub.mu.Lock()
+		accountSub.lastError = err.Error()
+		accountSub.mu.Unlock()
+		logWarn("⚠️ 账户订阅连接断开，5秒后重连", "error", err)
+		if !sleepCtx(globalCtx, 5*time.Second) {
+			return
+		}
+	}
+}
+
+// 订阅通知（只取用到的字段）
+type pubsubMessage struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Method string `json:"method"`
+	Params struct {
+		Subscription int `json:"subscription"`
+		Result       struct {
+			Context struct {
+				Slot uint64 `json:"slot"`
+			} `json:"context"`
+			Value *struct {
+				Data []string `json:"data"`
+			} `json:"value"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+func (s *accountSubscriber) run(ctx context.Context) error {
+	conn, err := dialWebSocket(ctx, accountSubscribeURL())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conn, s.connectedAt, s.lastError = conn, time.Now(), ""
+	s.requests, s.subIDs, s.accounts = map[int]string{}, map[string]int{}, map[int]string{}
+	s.data = map[string][]byte{} // 重连后重新读取，断线期间的变化不会推送
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	messages := make(chan []byte, 256)
+	readErr := make(chan error, 1)
+	go func() {
+		// 读取 panic 时按断线处理，由外层重连
+		defer func() {
+			if r := recover(); r != nil {
+				pe := &panicError{value: r, stack: debug.Stack()}
+				notePanic("accountSub:reader", "", pe)
+				readErr <- pe
+			}
+		}()
+		for {
+			msg, err := conn.ReadMessage(2 * time.Minute)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	logOutput("📡 账户订阅已连接\n")
+	if err := s.refresh(ctx); err != nil {
+		return err
+	}
+	refresh := time.NewTicker(time.Duration(currentConfig().AccountSubscribe.RefreshSeconds) * time.Second)
+	defer refresh.Stop()
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case msg := <-messages:
+			callRecovered("accountSub", "", func() { s.handle(msg) })
+		case <-refresh.C:
+			if err := s.refresh(ctx); err != nil {
+				return err
+			}
+			s.saveState()
+		case <-ping.C:
+			if err := conn.Ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// refresh 按 data 目录的实盘持仓增减订阅：池的 LbPair、仓位账户与仓位范围覆盖的 bin array；
+// 新订阅的账户先批量读取一次当前数据（订阅只推送之后的变化）
+func (s *accountSubscriber) refresh(ctx context.Context) error {
+	wanted := map[string]string{} // 池 -> 仓位
+	files, err := os.ReadDir(poolDataDir())
+	if err != nil {
+		return nil
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		pool := strings.TrimSuffix(file.Name(), ".json")
+		if isPaperPool(pool) {
+			continue
+		}
+		if position := readPositionFromPoolJSON(pool); position != "" {
+			wanted[pool] = position
+		}
+	}
+
+	s.mu.Lock()
+	var missing []string
+	for pool, position := range wanted {
+		for _, acct := range []string{pool, position} {
+			if s.data[acct] == nil {
+				missing = append(missing, acct)
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err := s.seed(ctx, missing); err != nil {
+		logWarn("⚠️ 读取订阅账户失败", "accounts", len(missing), "error", err)
+	}
+
+	// 仓位范围覆盖的 bin array
+	s.mu.Lock()
+	positionPool := map[string]string{}
+	binArrayPools := map[string][]string{}
+	missing = nil
+	for pool, position := range wanted {
+		positionPool[position] = pool
+		p := parsePositionAccount(s.data[position])
+		if p == nil {
+			continue
+		}
+		for _, key := range p.binArrays() {
+			binArrayPools[key] = append(binArrayPools[key], pool)
+			if s.data[key] == nil {
+				missing = append(missing, key)
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err := s.seed(ctx, missing); err != nil {
+		logWarn("⚠️ 读取 bin array 失败", "accounts", len(missing), "error", err)
+	}
+
+	s.mu.Lock()
+	desired := map[string]bool{}
+	for pool, position := range wanted {
+		desired[pool], desired[position] = true, true
+	}
+	for key := range binArrayPools {
+		desired[key] = true
+	}
+	var subscribe, unsubscribe []string
+	pendingReq := map[string]bool{}
+	for _, acct := range s.requests {
+		pendingReq[acct] = true
+	}
+	for acct := range desired {
+		if _, ok := s.subIDs[acct]; !ok && !pendingReq[acct] {
+			subscribe = append(subscribe, acct)
+		}
+	}
+	for acct, id := range s.subIDs {
+		if !desired[acct] {
+			unsubscribe = append(unsubscribe, acct)
+			delete(s.subIDs, acct)
+			delete(s.accounts, id)
+			delete(s.data, acct)
+			req := s.nextRequestID()
+			s.requests[req] = "" // 退订结果不需要处理
+			s.sendLocked("accountUnsubscribe", []interface{}{id}, req)
+		}
+	}
+	for pool := range s.pools {
+		if _, ok := wanted[pool]; !ok {
+			delete(s.pools, pool)
+			s.dirty = true
+		}
+	}
+	s.positionPool, s.binArrayPools = positionPool, binArrayPools
+	for pool, position := range wanted {
+		st := s.pools[pool]
+		if st == nil || st.Position != position {
+			st = &AccountSubscription{PoolAddress: pool, Position: position, TokenAddress: readTokenContractAddressFromPoolJSON(pool)}
+			s.pools[pool] = st
+		}
+		s.updateActiveBinLocked(st)
+		s.updatePendingLocked(st)
+	}
+	commitment := currentConfig().AccountSubscribe.Commitment
+	for _, acct := range subscribe {
+		id := s.nextRequestID()
+		s.requests[id] = acct
+		if err := s.sendLocked("accountSubscribe", []interface{}{acct, map[string]string{"encoding": "base64", "commitment": commitment}}, id); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+	if len(subscribe) > 0 || len(unsubscribe) > 0 {
+		logInfo("📡 账户订阅已更新", "pools", len(wanted), "subscribe", len(subscribe), "unsubscribe", len(unsubscribe))
+	}
+	return nil
+}
+
+// seed 批量读取账户当前数据
+func (s *accountSubscriber) seed(ctx context.Context, accounts []string) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	rctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	data, err := multipleAccountData(rctx, accounts)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for acct, d := range data {
+		s.data[acct] = d
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *accountSubscriber) nextRequestID() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *accountSubscriber) sendLocked(method string, params []interface{}, id int) error {
+	if s.conn == nil {
+		return fmt.Errorf("账户订阅未连接")
+	}
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	return s.conn.WriteText(body)
+}
+
+// handle 处理订阅结果与账户变化通知
+func (s *accountSubscriber) handle(raw []byte) {
+	var msg pubsubMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		logDebug("账户订阅消息无法解析", "error", err)
+		return
+	}
+	s.mu.Lock()
+	if msg.ID != 0 {
+		acct, ok := s.requests[msg.ID]
+		delete(s.requests, msg.ID)
+		switch {
+		case !ok || acct == "":
+		case msg.Error != nil:
+			logWarn("⚠️ 订阅账户失败", "account", acct, "error", msg.Error.Message)
+		default:
+			var subID int
+			if json.Unmarshal(msg.Result, &subID) == nil {
+				s.subIDs[acct] = subID
+				s.accounts[subID] = acct
+			}
+		}
+		s.mu.Unlock()
+		return
+	}
+	if msg.Method != "accountNotification" {
+		s.mu.Unlock()
+		return
+	}
+	acct := s.accounts[msg.Params.Subscription]
+	v := msg.Params.Result.Value
+	if acct == "" || v == nil || len(v.Data) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(v.Data[0])
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.data[acct] = data
+	slot := msg.Params.Result.Context.Slot
+
+	// 受影响的池：LbPair 本身、仓位所属的池、使用该 bin array 的池
+	var affected []string
+	if _, ok := s.pools[acct]; ok {
+		affected = append(affected, acct)
+	}
+	if pool, ok := s.positionPool[acct]; ok {
+		affected = append(affected, pool)
+	}
+	affected = append(affected, s.binArrayPools[acct]...)
+
+	var actions []func()
+	for _, pool := range affected {
+		st := s.pools[pool]
+		if st == nil {
+			continue
+		}
+		st.Events++
+		st.Slot = slot
+		st.UpdatedAt = appNow().Format(time.RFC3339)
+		s.dirty = true
+		if s.updateActiveBinLocked(st) {
+			actions = append(actions, s.onActiveBinLocked(st)...)
+		}
+		if s.updatePendingLocked(st) {
+			if a := s.onPendingLocked(st); a != nil {
+				actions = append(actions, a)
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, a := range actions {
+		a()
+	}
+}
+
+// 从 LbPair 与仓位账户更新 active bin 与范围，返回 active bin 是否变化
+func (s *accountSubscriber) updateActiveBinLocked(st *AccountSubscription) bool {
+	changed := false
+	if d := s.data[st.PoolAddress]; len(d) >= lbPairMinLength {
+		active := int(int32(binary.LittleEndian.Uint32(d[lbPairActiveIDOffset:])))
+		changed = st.seen && active != st.ActiveBin
+		st.ActiveBin, st.seen = active, true
+	}
+	if p := parsePositionAccount(s.data[st.Position]); p != nil {
+		st.LowerBin, st.UpperBin = int(p.lower), int(p.upper)
+	}
+	st.InRange = st.ActiveBin >= st.LowerBin && st.ActiveBin <= st.UpperBin
+	return changed
+}
+
+// 从仓位与 bin array 计算未领取数量，返回是否出现新的未领取手续费或奖励
+func (s *accountSubscriber) updatePendingLocked(st *AccountSubscription) bool {
+	p := parsePositionAccount(s.data[st.Position])
+	if p == nil {
+		return false
+	}
+	pending, ok := p.pending(s.data)
+	if !ok {
+		return false
+	}
+	next := [4]string{pending.FeeX.String(), pending.FeeY.String(), pending.Rewards[0].String(), pending.Rewards[1].String()}
+	prev := [4]string{st.PendingFeeX, st.PendingFeeY, st.PendingRewards[0], st.PendingRewards[1]}
+	st.PendingFeeX, st.PendingFeeY, st.PendingRewards = next[0], next[1], [2]string{next[2], next[3]}
+	return next != prev && !pending.empty()
+}
+
+// active bin 变化：价格获取与再平衡检查（在锁外执行）
+func (s *accountSubscriber) onActiveBinLocked(st *AccountSubscription) []func() {
+	cfg := currentConfig().AccountSubscribe
+	var actions []func()
+	pool, ca, active := st.PoolAddress, st.TokenAddress, st.ActiveBin
+	if cfg.PriceOnChange && ca != "" && time.Since(s.lastPrice[pool]) >= time.Duration(cfg.PriceMinIntervalSeconds)*time.Second {
+		s.lastPrice[pool] = time.Now()
+		st.LastPriceTrigger = appNow().Format(time.RFC3339)
+		actions = append(actions, func() {
+			if isPaused() || shuttingDown() {
+				return
+			}
+			logDebug("📡 池 active bin 变化，加入价格获取", "pool", pool, "activeBin", active)
+			enqueuePriceFetch(pool, ca)
+		})
+	}
+	if cfg.RebalanceOnChange && currentConfig().Rebalance.Enabled {
+		actions = append(actions, func() {
+			if isPaused() || isPriceOnly() || shuttingDown() || dataVolumeUnavailable() || openPositionGroup(pool) != nil {
+				return
+			}
+			if rng := readOpenRangeFromPoolJSON(pool); rng != nil {
+				checkRebalanceAt(pool, ca, rng, active)
+			}
+		})
+	}
+	return actions
+}
+
+// 出现新的未领取手续费或奖励：按最小间隔加入领取（在锁外执行）
+func (s *accountSubscriber) onPendingLocked(st *AccountSubscription) func() {
+	cfg := currentConfig().AccountSubscribe
+	pool, ca := st.PoolAddress, st.TokenAddress
+	if !cfg.ClaimOnChange || time.Since(s.lastClaim[pool]) < time.Duration(cfg.ClaimMinIntervalSeconds)*time.Second {
+		return nil
+	}
+	s.lastClaim[pool] = time.Now()
+	st.LastClaimTrigger = appNow().Format(time.RFC3339)
+	feeX, feeY := st.PendingFeeX, st.PendingFeeY
+	return func() {
+		if isPaused() || shuttingDown() || dataVolumeUnavailable() || !enforceListPolicy(subsystemClaim, pool, ca) {
+			return
+		}
+		logOutput("⚡ 仓位有新的未领取手续费（X %s，Y %s），加入领取: %s\n", feeX, feeY, poolLabel(pool))
+		enqueueClaim(pool)
+	}
+}
+
+// 池的订阅是否完整：池、仓位与仓位范围覆盖的 bin array 都已订阅
+func (s *accountSubscriber) liveLocked(pool string) bool {
+	st := s.pools[pool]
+	if s.conn == nil || st == nil {
+		return false
+	}
+	if _, ok := s.subIDs[pool]; !ok {
+		return false
+	}
+	if _, ok := s.subIDs[st.Position]; !ok {
+		return false
+	}
+	p := parsePositionAccount(s.data[st.Position])
+	if p == nil {
+		return false
+	}
+	for _, key := range p.binArrays() {
+		if _, ok := s.subIDs[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// accountSubPollRound 定时任务开始新一轮，返回轮次
+func accountSubPollRound(job string) int {
+	accountSub.mu.Lock()
+	defer accountSub.mu.Unlock()
+	accountSub.pollRounds[job]++
+	return accountSub.pollRounds[job]
+}
+
+// accountSubSkipPoll 订阅正常的池在非兜底轮次跳过定时领取与价格获取
+func accountSubSkipPoll(round int, pool string) bool {
+	cfg := currentConfig().AccountSubscribe
+	if !cfg.Enabled || cfg.PollEvery <= 1 || round%cfg.PollEvery == 0 {
+		return false
+	}
+	accountSub.mu.Lock()
+	defer accountSub.mu.Unlock()
+	return accountSub.liveLocked(pool)
+}
+
+// 有变化时保存订阅状态
+func (s *accountSubscriber) saveState() {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	s.dirty = false
+	pools := s.snapshotLocked()
+	s.mu.Unlock()
+	if err := saveStateFile("account_subscriptions", pools); err != nil {
+		logOutput("⚠️ 保存账户订阅状态失败: %v\n", err)
+	}
+}
+
+func (s *accountSubscriber) snapshotLocked() []AccountSubscription {
+	pools := make([]AccountSubscription, 0, len(s.pools))
+	for pool, st := range s.pools {
+		v := *st
+		v.Live = s.liveLocked(pool)
+		pools = append(pools, v)
+	}
+	sort.Slice(pools, func(a, b int) bool { return pools[a].PoolAddress < pools[b].PoolAddress })
+	return pools
+}
+
+// accountSubscriptionStatus 订阅连接与各池状态（GET /subscriptions）
+func accountSubscriptionStatus() AccountSubscriptionStatus {
+	s := accountSub
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := AccountSubscriptionStatus{
+		Enabled:       currentConfig().AccountSubscribe.Enabled,
+		Connected:     s.conn != nil,
+		LastError:     s.lastError,
+		Subscriptions: len(s.subIDs),
+		Pools:         s.snapshotLocked(),
+	}
+	if s.conn != nil {
+		status.ConnectedAt = s.connectedAt.Format(time.RFC3339)
+	}
+	return status
+}
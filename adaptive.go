@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdaptiveConfig 按活跃度调整领取与兑换的频率：手续费累积快的池在两轮全局领取之间加领，不活跃的池退避，钱包余额没有变化时跳过兑换
+type AdaptiveConfig struct {
+	Enabled             bool    `json:"enabled"`
+	WindowMinutes       float64 `json:"windowMinutes"`       // 按最近该时长内的手续费累积计算速率
+	HotFeesUSDPerHour   float64 `json:"hotFeesUSDPerHour"`   // 速率达到该值（USD/小时）的池为活跃池；0 表示不加领
+	HotIntervalMinutes  float64 `json:"hotIntervalMinutes"`  // 活跃池的领取间隔，应短于全局领取周期
+	IdleFeesUSDPerHour  float64 `json:"idleFeesUSDPerHour"`  // 速率低于该值的池为不活跃池
+	IdleIntervalMinutes float64 `json:"idleIntervalMinutes"` // 不活跃池每 N 分钟才领取一次；0 表示不退避
+	SwapSkipUnchanged   bool    `json:"swapSkipUnchanged"`   // 钱包代币余额与上次兑换结束时相同则跳过本轮兑换
+	SwapMaxSkipMinutes  float64 `json:"swapMaxSkipMinutes"`  // 连续跳过超过该时长仍执行一次（重试失败的兑换）；0 表示一直跳过
+}
+
+// 池的活跃度分类
+const (
+	activityHot    = "hot"
+	activityNormal = "normal"
+	activityIdle   = "idle"
+)
+
+// feeSample 一次领取检查后池的累计手续费（已领取 + 未领取，USD）
+type feeSample struct {
+	At      string  `json:"at"`
+	FeesUSD float64 `json:"feesUSD"`
+}
+
+// PoolActivity 池的手续费累积速率与领取节奏（GET /adaptive）
+type PoolActivity struct {
+	PoolAddress string      `json:"poolAddress"`
+	Class       string      `json:"class"`
+	RateUSDHour float64     `json:"rateUSDPerHour"`
+	RateKnown   bool        `json:"rateKnown"` // 窗口内至少有两次检查
+	LastCheckAt string      `json:"lastCheckAt,omitempty"`
+	NextClaimAt string      `json:"nextClaimAt,omitempty"` // 活跃池的下一次加领、不活跃池退避结束的时间
+	Samples     []feeSample `json:"samples,omitempty"`
+}
+
+// SwapBalanceMark 钱包上次兑换结束时的代币余额指纹（data/state/swap_balances.json，键为钱包名，默认钱包为空字符串）
+type SwapBalanceMark struct {
+	Fingerprint string `json:"fingerprint"`
+	RanAt       string `json:"ranAt"`
+}
+
+var (
+	activityMutex sync.Mutex
+	swapMarkMutex sync.Mutex
+)
+
+var errNoSwapWallet = errors.New("钱包地址未知")
+
+func (c AdaptiveConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WindowMinutes <= 0 {
+		return fmt.Errorf("adaptive.windowMinutes 必须大于0")
+	}
+	if c.HotFeesUSDPerHour < 0 || c.IdleFeesUSDPerHour < 0 {
+		return fmt.Errorf("adaptive.hotFeesUSDPerHour、idleFeesUSDPerHour 不能为负数")
+	}
+	if c.HotFeesUSDPerHour > 0 && c.HotFeesUSDPerHour <= c.IdleFeesUSDPerHour {
+		return fmt.Errorf("adaptive.hotFeesUSDPerHour 必须大于 idleFeesUSDPerHour")
+	}
+	if c.HotFeesUSDPerHour > 0 && c.HotIntervalMinutes <= 0 {
+		return fmt.Errorf("adaptive.hotIntervalMinutes 必须大于0")
+	}
+	if c.IdleIntervalMinutes < 0 || c.SwapMaxSkipMinutes < 0 {
+		return fmt.Errorf("adaptive.idleIntervalMinutes、swapMaxSkipMinutes 不能为负数")
+	}
+	return nil
+}
+
+func loadFeeSamples() map[string][]feeSample {
+	samples := map[string][]feeSample{}
+	if err := loadStateFile("claim_activity", &samples); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return samples
+}
+
+// poolCumulativeFeesUSD 池的累计手续费：领取记录的累计估值 + 各仓位最近一次检查的未领取
+func poolCumulativeFeesUSD(poolAddress string) float64 {
+	var fees float64
+	if pc, ok := poolClaims(poolAddress); ok {
+		fees = pc.TotalUSD
+	}
+	for _, c := range listClaimChecks() {
+		if c.PoolAddress == poolAddress {
+			fees += c.PendingUSD
+		}
+	}
+	return fees
+}
+
+// noteClaimActivity 每次领取检查（执行领取脚本或批量读取确认为空）后记录池的累计手续费
+func noteClaimActivity(poolAddress string) {
+	if !currentConfig().Adaptive.Enabled {
+		return
+	}
+	sample := feeSample{At: time.Now().Format(time.RFC3339), FeesUSD: poolCumulativeFeesUSD(poolAddress)}
+	window := time.Duration(currentConfig().Adaptive.WindowMinutes * float64(time.Minute))
+
+	activityMutex.Lock()
+	defer activityMutex.Unlock()
+	all := loadFeeSamples()
+	samples := append(all[poolAddress], sample)
+	// 只保留窗口内的检查，至少保留最近两次用于计算速率
+	cut := 0
+	for cut < len(samples)-2 {
+		t, err := time.Parse(time.RFC3339, samples[cut].At)
+		if err == nil && time.Since(t) <= window {
+			break
+		}
+		cut++
+	}
+	all[poolAddress] = samples[cut:]
+	for pool, s := range all {
+		if t, err := time.Parse(time.RFC3339, s[len(s)-1].At); err == nil && time.Since(t) > claimCheckRetention {
+			delete(all, pool)
+		}
+	}
+	if err := saveStateFile("claim_activity", all); err != nil {
+		logOutput("❌ 保存领取活跃度失败: %v\n", err)
+	}
+}
+
+// classifyActivity 按窗口内首末两次检查的累计手续费之差计算速率并分类（不足两次检查时为 normal）
+func classifyActivity(poolAddress string, samples []feeSample) PoolActivity {
+	cfg := currentConfig().Adaptive
+	a := PoolActivity{PoolAddress: poolAddress, Class: activityNormal, Samples: samples}
+	if len(samples) == 0 {
+		return a
+	}
+	last := samples[len(samples)-1]
+	a.LastCheckAt = last.At
+	lastAt, err := time.Parse(time.RFC3339, last.At)
+	if err != nil {
+		return a
+	}
+	if len(samples) >= 2 {
+		if firstAt, err := time.Parse(time.RFC3339, samples[0].At); err == nil && lastAt.After(firstAt) {
+			// 领取后估值可能回落，速率不为负
+			a.RateUSDHour = max(0, (last.FeesUSD-samples[0].FeesUSD)/lastAt.Sub(firstAt).Hours())
+			a.RateKnown = true
+		}
+	}
+	if !a.RateKnown {
+		return a
+	}
+	switch {
+	case cfg.HotFeesUSDPerHour > 0 && a.RateUSDHour >= cfg.HotFeesUSDPerHour:
+		a.Class = activityHot
+		a.NextClaimAt = lastAt.Add(time.Duration(cfg.HotIntervalMinutes * float64(time.Minute))).Format(time.RFC3339)
+	case a.RateUSDHour < cfg.IdleFeesUSDPerHour:
+		a.Class = activityIdle
+		if cfg.IdleIntervalMinutes > 0 {
+			a.NextClaimAt = lastAt.Add(time.Duration(cfg.IdleIntervalMinutes * float64(time.Minute))).Format(time.RFC3339)
+		}
+	}
+	return a
+}
+
+// poolActivity 单个池的活跃度
+func poolActivity(poolAddress string) PoolActivity {
+	activityMutex.Lock()
+	samples := loadFeeSamples()[poolAddress]
+	activityMutex.Unlock()
+	return classifyActivity(poolAddress, samples)
+}
+
+// listPoolActivity 各池的活跃度（按速率倒序，GET /adaptive）
+func listPoolActivity() []PoolActivity {
+	activityMutex.Lock()
+	all := loadFeeSamples()
+	activityMutex.Unlock()
+	result := make([]PoolActivity, 0, len(all))
+	for pool, samples := range all {
+		a := classifyActivity(pool, samples)
+		a.Samples = nil
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RateUSDHour > result[j].RateUSDHour })
+	return result
+}
+
+// adaptiveClaimDeferred 不活跃的池在退避间隔内跳过全局领取，返回退避结束时间
+func adaptiveClaimDeferred(poolAddress string) (string, bool) {
+	cfg := currentConfig().Adaptive
+	if !cfg.Enabled || cfg.IdleIntervalMinutes <= 0 {
+		return "", false
+	}
+	a := poolActivity(poolAddress)
+	if a.Class != activityIdle || a.NextClaimAt == "" {
+		return "", false
+	}
+	next, err := time.Parse(time.RFC3339, a.NextClaimAt)
+	if err != nil || !time.Now().Before(next) {
+		return "", false
+	}
+	return a.NextClaimAt, true
+}
+
+// startAdaptiveClaims 活跃池在两轮全局领取之间按 hotIntervalMinutes 加领（速率阈值可热更新，enabled 需重启）
+func startAdaptiveClaims() {
+	cfg := currentConfig().Adaptive
+	if !cfg.Enabled {
+		return
+	}
+	logOutput("📈 启动自适应领取（活跃池每 %g 分钟领取，不活跃池每 %g 分钟领取）\n", cfg.HotIntervalMinutes, cfg.IdleIntervalMinutes)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止自适应领取\n")
+			return
+		case <-ticker.C:
+			claimHotPools()
+		}
+	}
+}
+
+// claimHotPools 对到达加领时间的活跃池排队领取（不等待完成）
+func claimHotPools() {
+	if currentConfig().Adaptive.HotFeesUSDPerHour <= 0 || isPaused() || jobPaused("claim") || isPriceOnly() || shuttingDown() || dataVolumeUnavailable() {
+		return
+	}
+	now := time.Now()
+	for _, a := range listPoolActivity() {
+		if a.Class != activityHot {
+			// 按速率倒序，之后都不是活跃池
+			return
+		}
+		if next, err := time.Parse(time.RFC3339, a.NextClaimAt); err != nil || now.Before(next) {
+			continue
+		}
+		pool := a.PoolAddress
+		if !poolExists(pool) || claimInFlight(pool) {
+			continue
+		}
+		if state, ok := poolStateOf(pool); ok && (state == poolClosed || state == poolExiting) {
+			continue
+		}
+		if readPositionFromPoolJSON(pool) == "" && !(isPaperPool(pool) && paperHasOpenPosition(pool)) {
+			continue
+		}
+		if !enforceListPolicy(subsystemClaim, pool, readTokenContractAddressFromPoolJSON(pool)) {
+			continue
+		}
+		logOutput("📈 活跃池手续费累积 %.2f USD/小时，加领: %s\n", a.RateUSDHour, poolLabel(pool))
+		enqueueClaim(pool)
+	}
+}
+
+// sweepWalletAddress 兑换钱包的地址（空字符串为默认钱包）
+func sweepWalletAddress(name string) string {
+	if name != "" {
+		if w := findWallet(name); w != nil {
+			return w.Address
+		}
+		return ""
+	}
+	if addr := lookupEnv("USER_WALLET_ADDRESS"); addr != "" {
+		return addr
+	}
+	return defaultKeyAddress()
+}
+
+// walletBalanceFingerprint 钱包非零代币余额的指纹（mint:amount，按 mint 排序）
+func walletBalanceFingerprint(wallet string) (string, error) {
+	address := sweepWalletAddress(wallet)
+	if address == "" {
+		return "", errNoSwapWallet
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	tokens, err := fetchTokenBalances(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		parts = append(parts, t.Mint+":"+t.Amount)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func loadSwapBalanceMarks() map[string]SwapBalanceMark {
+	marks := map[string]SwapBalanceMark{}
+	if err := loadStateFile("swap_balances", &marks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return marks
+}
+
+// swapBalancesUnchanged 钱包代币余额与上次兑换结束时相同（且未超过 swapMaxSkipMinutes）时返回 true；查询失败时照常兑换
+func swapBalancesUnchanged(wallet string) bool {
+	cfg := currentConfig().Adaptive
+	if !cfg.Enabled || !cfg.SwapSkipUnchanged || isDemo() {
+		return false
+	}
+	swapMarkMutex.Lock()
+	mark, ok := loadSwapBalanceMarks()[wallet]
+	swapMarkMutex.Unlock()
+	if !ok {
+		return false
+	}
+	if ran, err := time.Parse(time.RFC3339, mark.RanAt); err != nil ||
+		(cfg.SwapMaxSkipMinutes > 0 && time.Since(ran) >= time.Duration(cfg.SwapMaxSkipMinutes*float64(time.Minute))) {
+		return false
+	}
+	fp, err := walletBalanceFingerprint(wallet)
+	if err != nil {
+		logWarn("⚠️ 查询钱包余额失败，照常兑换", "wallet", wallet, "error", err)
+		return false
+	}
+	return fp == mark.Fingerprint
+}
+
+// noteSwapBalances 兑换结束后记录钱包余额指纹（剩余的代币如兑换失败的，下一轮余额不变时不再重试，直到 swapMaxSkipMinutes）
+func noteSwapBalances(wallet string) {
+	cfg := currentConfig().Adaptive
+	if !cfg.Enabled || !cfg.SwapSkipUnchanged || isDemo() {
+		return
+	}
+	fp, err := walletBalanceFingerprint(wallet)
+	if err != nil {
+		logWarn("⚠️ 查询钱包余额失败，下一轮照常兑换", "wallet", wallet, "error", err)
+		return
+	}
+	swapMarkMutex.Lock()
+	defer swapMarkMutex.Unlock()
+	marks := loadSwapBalanceMarks()
+	marks[wallet] = SwapBalanceMark{Fingerprint: fp, RanAt: time.Now().Format(time.RFC3339)}
+	if err := saveStateFile("swap_balances", marks); err != nil {
+		logOutput("❌ 保存兑换余额记录失败: %v\n", err)
+	}
+}
@@ -4,6 +4,7 @@ import {
   Keypair, 
   Transaction,
   VersionedTransaction,
+  ComputeBudgetProgram,
   clusterApiUrl
 } from '@solana/web3.js';
 import DLMM, { StrategyType } from '@meteora-ag/dlmm';
@@ -15,12 +16,34 @@ import axios from 'axios';
 import https from 'https';
 import fs from 'fs';
 import path from 'path';
+import { DATA_DIR, poolFilePath, updateJSONFile } from './dataFile';
+import { pipedPrivateKey } from './signingKey';
+
+// ===== 结构化输出（Go 端按 "@@event <json>" 行解析，见 scriptproto.go）=====
+function emitEvent(type: string, fields: Record<string, unknown> = {}): void {
+  console.log(`@@event ${JSON.stringify({ type, ...fields })}`);
+}
 
 // 加载环境变量
 dotenv.config();
 
-// 连接配置
-const connection = new Connection(clusterApiUrl('mainnet-beta'), 'confirmed');
+// 连接配置（RPC_URL 由 main.go 按 RPC 节点池的当前最优节点传入，未设置时使用公共节点）
+const connection = new Connection(process.env.RPC_URL || clusterApiUrl('mainnet-beta'), 'confirmed');
+useCachedBlockhash(connection);
+
+// --blockhash=<hash>:<lastValidBlockHeight>：main.go 预取的区块哈希，传入时 SDK 构建交易不再请求 getLatestBlockhash
+function useCachedBlockhash(conn: Connection): void {
+  for (const arg of process.argv.slice(2)) {
+    if (!arg.startsWith('--blockhash=')) continue;
+    const [blockhash, height] = arg.slice('--blockhash='.length).replace(/['"]/g, '').trim().split(':');
+    const lastValidBlockHeight = Number(height);
+    if (!blockhash || !Number.isInteger(lastValidBlockHeight) || lastValidBlockHeight <= 0) {
+      throw new Error(`--blockhash 取值无效: ${arg}`);
+    }
+    conn.getLatestBlockhash = async () => ({ blockhash, lastValidBlockHeight });
+    return;
+  }
+}
 
 // 从命令行与环境变量读取配置（命令行优先）
 const argv = process.argv.slice(2);
@@ -74,6 +97,122 @@ function resolveLastUpdatedFirstFromArgs(): string | undefined {
   return undefined;
 }
 
+// 阶梯档位名（--leg=wide）：仓位地址写入 legs.<name>，不占用顶层 positionAddress
+function resolveLegFromArgs(): string | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--leg=')) return sanitizeString(arg.split('=')[1]) || undefined;
+  }
+  return undefined;
+}
+
+// 档位宽度倍数（--range-scale=0.5）：以计算出的 bin 范围为基准，保持 maxBinId 不变缩放宽度
+function resolveRangeScaleFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--range-scale=')) {
+      const v = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(v) && v > 0) return v;
+      throw new Error(`--range-scale 取值无效: ${arg}`);
+    }
+  }
+  return 1;
+}
+
+// 本次投入的 SOL 数量（--sol-amount=0.5），未传入时使用 .env 中的 SOL_AMOUNT
+function resolveSolAmountFromArgs(): number | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--sol-amount=')) {
+      const v = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(v) && v > 0) return v;
+      throw new Error(`--sol-amount 取值无效: ${arg}`);
+    }
+  }
+  return undefined;
+}
+
+// 添加流动性滑点百分比（--slippage=0.5），由 main.go 按参数档位传入；默认 0.1
+function resolveSlippageFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--slippage=')) {
+      const v = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(v) && v > 0 && v < 100) return v;
+      throw new Error(`--slippage 取值无效: ${arg}`);
+    }
+  }
+  return 0.1;
+}
+
+// --cu-price=<microLamports>：计算单元价格（优先费），由 main.go 按近期区块优先费传入；未传入时沿用 SDK 默认
+function resolveComputeUnitPriceFromArgs(): number | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--cu-price=')) {
+      const v = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isInteger(v) && v >= 0) return v;
+      throw new Error(`--cu-price 取值无效: ${arg}`);
+    }
+  }
+  return undefined;
+}
+
+// 设置交易的计算单元价格：移除 SDK 已添加的 SetComputeUnitPrice 指令后插入到最前面（须在签名前调用）
+function applyComputeUnitPrice(transaction: Transaction): void {
+  const microLamports = resolveComputeUnitPriceFromArgs();
+  if (microLamports === undefined) return;
+  transaction.instructions = transaction.instructions.filter(
+    (ix) => !(ix.programId.equals(ComputeBudgetProgram.programId) && ix.data.length > 0 && ix.data[0] === 3),
+  );
+  transaction.instructions.unshift(ComputeBudgetProgram.setComputeUnitPrice({ microLamports }));
+}
+
+// 范围下跌幅度（--range-pct=45 表示覆盖到当前价 -45%），由 main.go 按近期波动率计算；默认 60%
+function resolveRangePctFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--range-pct=')) {
+      const v = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(v) && v > 0 && v < 100) return v;
+      throw new Error(`--range-pct 取值无效: ${arg}`);
+    }
+  }
+  return 60;
+}
+
+// 流动性分布（--strategy=spot|curve|bidask），由 main.go 按 liquidity 配置传入；默认 BidAsk
+function resolveStrategyTypeFromArgs(): StrategyType {
+  for (const arg of argv) {
+    if (arg.startsWith('--strategy=')) {
+      const v = sanitizeString(arg.split('=')[1]).toLowerCase();
+      if (v === 'spot') return StrategyType.Spot;
+      if (v === 'curve') return StrategyType.Curve;
+      if (v === 'bidask') return StrategyType.BidAsk;
+      throw new Error(`--strategy 取值无效: ${arg}`);
+    }
+  }
+  return StrategyType.BidAsk;
+}
+
+// bin 数量（--bins=69）：覆盖按 range-pct / last_updated_first 计算的宽度
+function resolveBinsFromArgs(): number | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--bins=')) {
+      const v = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isInteger(v) && v > 0) return v;
+      throw new Error(`--bins 取值无效: ${arg}`);
+    }
+  }
+  return undefined;
+}
+
+// 范围中位于 active bin 上方的比例（--skew=0.5 以 active bin 为中心）；默认 0，全部在下方
+function resolveSkewFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--skew=')) {
+      const v = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(v) && v >= 0 && v <= 0.5) return v;
+      throw new Error(`--skew 取值无效: ${arg}`);
+    }
+  }
+  return 0;
+}
+
 // 通用的引号处理函数：去掉包裹引号、处理%20/T分隔、去除转义符
 function sanitizeString(input: string): string {
   let s = input.trim();
@@ -99,7 +238,58 @@ function sanitizeLastUpdatedFirst(input: string): string {
 
 const USER_WALLET_ADDRESS = new PublicKey(process.env.USER_WALLET_ADDRESS!);
 
-// 通用重试工具：失败等待1秒再试，共最多3次（首试+重试2次）
+// 读取/写入/清除本次运行对应的仓位地址（主仓位为顶层 positionAddress，阶梯档位为 legs.<name>）
+function readPositionField(json: any, leg: string | undefined): string {
+  if (!json) return '';
+  const v = leg ? json.legs?.[leg] : json.positionAddress;
+  return typeof v === 'string' ? v.trim() : '';
+}
+
+function writePositionField(json: any, leg: string | undefined, posAddr: string): void {
+  if (leg) {
+    json.legs = (json.legs && typeof json.legs === 'object') ? json.legs : {};
+    json.legs[leg] = posAddr;
+    return;
+  }
+  json.positionAddress = posAddr;
+  if (json.data && typeof json.data === 'object') {
+    json.data.positionAddress = posAddr;
+  }
+}
+
+function clearPositionField(json: any, leg: string | undefined): void {
+  if (leg) {
+    if (json.legs && typeof json.legs === 'object') delete json.legs[leg];
+    return;
+  }
+  delete json.positionAddress;
+  delete json.c;
+  if (json.data && typeof json.data === 'object') {
+    delete json.data.positionAddress;
+    delete json.data.c;
+  }
+}
+
+// 上游响应的限速信息交给 main.go 的共享请求预算（ratelimit 事件，与 fetchPrice.ts 相同），返回 Retry-After 秒数（没有时为 0）
+function reportRateLimit(api: string, status: number, headers: Record<string, any> | undefined): number {
+  const h = headers || {};
+  const num = (v: any): number | undefined => {
+    const n = Number(v);
+    return v !== undefined && v !== null && v !== '' && Number.isFinite(n) ? n : undefined;
+  };
+  const remaining = num(h['x-ratelimit-remaining'] ?? h['ratelimit-remaining']);
+  const limit = num(h['x-ratelimit-limit'] ?? h['ratelimit-limit']);
+  let reset = num(h['x-ratelimit-reset'] ?? h['ratelimit-reset']);
+  if (reset !== undefined && reset > 1e12) reset = Math.max(0, reset / 1000 - Date.now() / 1000);
+  else if (reset !== undefined && reset > 1e9) reset = Math.max(0, reset - Date.now() / 1000);
+  const retryAfter = num(h['retry-after']);
+  if (remaining !== undefined || retryAfter !== undefined || status === 429) {
+    emitEvent('ratelimit', { api, httpStatus: status, remaining, limit, resetSeconds: reset, retryAfter });
+  }
+  return retryAfter ?? 0;
+}
+
+// 通用重试工具：共最多3次（首试+重试2次），被限流时按 Retry-After 等待（错误对象的 retryAfterMs），其余失败等待1秒
 async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
   const maxAttempts = 3;
   let lastErr: unknown;
@@ -109,8 +299,9 @@ async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
     } catch (err) {
       lastErr = err;
       if (attempt < maxAttempts) {
-        console.log(`获取失败，1秒后重试(${attempt}/${maxAttempts - 1}) -> ${desc}:`, err instanceof Error ? err.message : String(err));
-        await new Promise((r) => setTimeout(r, 1000));
+        const waitMs = Number((err as any)?.retryAfterMs) || 1000;
+        console.log(`获取失败，${(waitMs / 1000).toFixed(1)}秒后重试(${attempt}/${maxAttempts - 1}) -> ${desc}:`, err instanceof Error ? err.message : String(err));
+        await new Promise((r) => setTimeout(r, waitMs));
       }
     }
   }
@@ -126,8 +317,8 @@ const TOKEN_Y_DECIMAL = 9;  //sol
  * @returns 左侧bins数量
  */
 function calculateDynamicLeftBins(bin_step: number): number {
-  // 目标值：0.4
-  const targetValue = 0.4;  //-60%
+  // 目标值：默认 0.4（-60%），可由 --range-pct 覆盖
+  const targetValue = 1 - resolveRangePctFromArgs() / 100;
   // 基础值：1 - bin_step/10000
   const baseValue = 1 - bin_step / 10000;
   
@@ -218,6 +409,7 @@ async function fetchOkxCandles(tokenContractAddress: string, after?: string, bef
   const data = await new Promise<any>((resolve, reject) => {
     https.get(url, (res) => {
       const statusCode = res.statusCode || 0;
+      reportRateLimit('okx', statusCode, res.headers);
       if (statusCode < 200 || statusCode >= 300) {
         reject(new Error(`HTTP 状态码 ${statusCode}`));
         res.resume();
@@ -282,7 +474,19 @@ async function fetchOkxLatestPrice(tokenContractAddress: string): Promise<string
     'OK-ACCESS-SIGN': signature
   } as const;
 
-  const resp = await withRetry(() => axios.post(url, bodyArray, { headers }), 'OKX 最新价格');
+  const resp = await withRetry(async () => {
+    try {
+      const r = await axios.post(url, bodyArray, { headers });
+      reportRateLimit('okx', r.status, r.headers as Record<string, any>);
+      return r;
+    } catch (err: any) {
+      if (err?.response) {
+        const retryAfter = reportRateLimit('okx', err.response.status, err.response.headers);
+        if (retryAfter > 0) err.retryAfterMs = retryAfter * 1000;
+      }
+      throw err;
+    }
+  }, 'OKX 最新价格');
   if (!resp?.data) {
     console.log('OKX 价格响应为空');
     return undefined;
@@ -332,7 +536,7 @@ async function addLiquidityWithExtendedPosition(
   
   // 步骤2: 添加流动性到扩展仓位
   const strategy = {
-    strategyType: StrategyType.BidAsk,
+    strategyType: resolveStrategyTypeFromArgs(),
     minBinId: minBinId,
     maxBinId: maxBinId,
   };
@@ -451,11 +655,13 @@ async function completeBidAskStrategyFlow(
   
   // 步骤2: 执行创建交易（让仓位被DLMM程序拥有）
   console.log('步骤2: 执行创建交易');
+  applyComputeUnitPrice(createTransaction);
   createTransaction.sign(positionKeypair as any);
   const versionedCreateTransaction = new VersionedTransaction(createTransaction.compileMessage());
   versionedCreateTransaction.sign([positionKeypair as any]);
   const createTxHash = await connection.sendTransaction(versionedCreateTransaction);
   console.log('✅ 创建交易已发送:', createTxHash);
+  emitEvent('signature', { signature: createTxHash, action: 'createPosition' });
   
   // 等待交易确认
   await connection.getSignatureStatus(createTxHash, { searchTransactionHistory: true });
@@ -464,7 +670,7 @@ async function completeBidAskStrategyFlow(
   // 步骤3: 添加BidAsk策略流动性
   console.log('步骤3: 添加BidAsk策略流动性');
   const strategy = {
-    strategyType: StrategyType.BidAsk,
+    strategyType: resolveStrategyTypeFromArgs(),
     minBinId: minBinId,
     maxBinId: maxBinId,
   };
@@ -480,11 +686,13 @@ async function completeBidAskStrategyFlow(
   
   // 步骤4: 执行添加流动性交易
   console.log('步骤4: 执行添加流动性交易');
+  applyComputeUnitPrice(addLiquidityTransaction);
   addLiquidityTransaction.sign(userKeypair as any);
   const versionedAddLiquidityTransaction = new VersionedTransaction(addLiquidityTransaction.compileMessage());
   versionedAddLiquidityTransaction.sign([userKeypair as any]);
   const addLiquidityTxHash = await connection.sendTransaction(versionedAddLiquidityTransaction);
   console.log('✅ 添加流动性交易已发送:', addLiquidityTxHash);
+  emitEvent('signature', { signature: addLiquidityTxHash, action: 'addLiquidity' });
   
   // 等待交易确认（带重试和状态检查）
   console.log('等待添加流动性交易确认...');
@@ -560,8 +768,12 @@ async function main() {
     // 单边池参数 - tokenXAmount为0，只提供tokenY
     const tokenXAmount = new BN(0); // 单边池，Token X 数量为0
     
-    // 从环境变量读取SOL数量
-    const solAmount = parseFloat(process.env.SOL_AMOUNT!);
+    // 从命令行或环境变量读取SOL数量
+    const leg = resolveLegFromArgs();
+    if (leg) {
+      console.log(`🪜 阶梯档位: ${leg}`);
+    }
+    const solAmount = resolveSolAmountFromArgs() ?? parseFloat(process.env.SOL_AMOUNT!);
     const tokenYAmount = new BN(solAmount * 10 ** TOKEN_Y_DECIMAL); // SOL数量乘以精度
     
     // 计算Bin ID范围
@@ -597,31 +809,37 @@ async function main() {
       console.log(`- 总Bins数量: ${maxBinId - minBinId + 1}`);
     } 
     
-    // 创建用户密钥对（仅支持加密私钥，解密后为Base58格式）
+    // 创建用户密钥对（主程序经管道传入的私钥，或 .env 中的加密私钥，解密后为Base58格式）
     let userKeypair: Keypair;
-    if (!process.env.PRIVATE_KEY) {
-      console.log('❌ 未找到私钥配置');
-      throw new Error('未配置私钥，请在.env文件中设置PRIVATE_KEY');
-    }
-    if (process.env.PRIVATE_KEY_ENCRYPTED !== 'true') {
-      throw new Error('仅支持加密私钥：请将 PRIVATE_KEY_ENCRYPTED 设置为 true');
-    }
-    if (!process.env.PRIVATE_KEY_PASSWORD) {
-      throw new Error('使用加密私钥时，必须设置 PRIVATE_KEY_PASSWORD');
-    }
-    let decryptedPrivateKeyBase58: string;
-    try {
-      decryptedPrivateKeyBase58 = decryptPrivateKey(process.env.PRIVATE_KEY, process.env.PRIVATE_KEY_PASSWORD);
-      console.log('✅ 已解密加密私钥');
-    } catch (e) {
-      console.log('❌ 私钥解密失败');
-      throw new Error('私钥解密失败，请检查 PRIVATE_KEY 与 PRIVATE_KEY_PASSWORD 是否匹配');
-    }
-    try {
-      userKeypair = Keypair.fromSecretKey(bs58.decode(decryptedPrivateKeyBase58));
-      console.log('✅ 私钥格式：Base58 (解密后)');
-    } catch (e) {
-      throw new Error('解密后的私钥必须是 Base58 的 secret key');
+    const pipedKey = pipedPrivateKey();
+    if (pipedKey !== undefined) {
+      userKeypair = Keypair.fromSecretKey(bs58.decode(pipedKey));
+      console.log('✅ 已读取主程序传入的私钥');
+    } else {
+      if (!process.env.PRIVATE_KEY) {
+        console.log('❌ 未找到私钥配置');
+        throw new Error('未配置私钥，请在.env文件中设置PRIVATE_KEY');
+      }
+      if (process.env.PRIVATE_KEY_ENCRYPTED !== 'true') {
+        throw new Error('仅支持加密私钥：请将 PRIVATE_KEY_ENCRYPTED 设置为 true');
+      }
+      if (!process.env.PRIVATE_KEY_PASSWORD) {
+        throw new Error('使用加密私钥时，必须设置 PRIVATE_KEY_PASSWORD');
+      }
+      let decryptedPrivateKeyBase58: string;
+      try {
+        decryptedPrivateKeyBase58 = decryptPrivateKey(process.env.PRIVATE_KEY, process.env.PRIVATE_KEY_PASSWORD);
+        console.log('✅ 已解密加密私钥');
+      } catch (e) {
+        console.log('❌ 私钥解密失败');
+        throw new Error('私钥解密失败，请检查 PRIVATE_KEY 与 PRIVATE_KEY_PASSWORD 是否匹配');
+      }
+      try {
+        userKeypair = Keypair.fromSecretKey(bs58.decode(decryptedPrivateKeyBase58));
+        console.log('✅ 私钥格式：Base58 (解密后)');
+      } catch (e) {
+        throw new Error('解密后的私钥必须是 Base58 的 secret key');
+      }
     }
     
     console.log('用户钱包地址:', userKeypair.publicKey.toString());
@@ -692,20 +910,14 @@ async function main() {
               console.log(`last_updated_first 命中收盘价(c): ${c}`);
               // 将收盘价(c)持久化到 data/<pool>.json（顶层 c 与 data.c 同步，逻辑与 positionAddress 相似）
               try {
-                const poolFileForC = path.resolve(__dirname, 'data', `${POOL_ADDRESS.toString()}.json`);
-                let jsonC: any = {};
-                try {
-                  const rawC = fs.readFileSync(poolFileForC, 'utf8');
-                  jsonC = JSON.parse(rawC);
-                } catch (_) {
-                  jsonC = {};
-                }
+                const poolFileForC = poolFilePath(POOL_ADDRESS.toString());
                 const cStr = String(c);
-                jsonC.c = cStr;
-                if (jsonC.data && typeof jsonC.data === 'object') {
-                  jsonC.data.c = cStr;
-                }
-                fs.writeFileSync(poolFileForC, JSON.stringify(jsonC, null, 2));
+                updateJSONFile(poolFileForC, (jsonC) => {
+                  jsonC.c = cStr;
+                  if (jsonC.data && typeof jsonC.data === 'object') {
+                    jsonC.data.c = cStr;
+                  }
+                });
                 console.log(`已写入 收盘价(c) 到 ${poolFileForC}`);
               } catch (e: any) {
                 console.log('写入 收盘价(c) 到 JSON 失败:', e?.message || String(e));
@@ -791,10 +1003,36 @@ async function main() {
       console.log(`- 总Bins数量: ${maxBinId - minBinId + 1}`);
     }
     
+    // 策略指定的 bin 数量与位置：--bins 覆盖宽度（保持 maxBinId），--skew 时以实时 active bin 重新定位
+    const bins = resolveBinsFromArgs();
+    const skew = resolveSkewFromArgs();
+    let binsAboveActive = 0;
+    if (binRangeCalculated && bins !== undefined) {
+      minBinId = maxBinId - bins + 1;
+      console.log(`📐 策略 bin 数量 ${bins}: ${minBinId} - ${maxBinId}`);
+    }
+    if (binRangeCalculated && skew > 0) {
+      const width = maxBinId - minBinId + 1;
+      const activeNow = dlmmPool.lbPair.activeId;
+      binsAboveActive = Math.round(width * skew);
+      maxBinId = activeNow + binsAboveActive;
+      minBinId = maxBinId - width + 1;
+      console.log(`📐 策略偏移 ${skew}: active bin ${activeNow} 上方 ${binsAboveActive} 个bins (${minBinId} - ${maxBinId})`);
+    }
+
+    // 按档位倍数缩放 bin 宽度（保持 maxBinId 不变，向左扩展或收窄）
+    const rangeScale = resolveRangeScaleFromArgs();
+    if (binRangeCalculated && rangeScale !== 1) {
+      const width = maxBinId - minBinId + 1;
+      const scaledWidth = Math.max(1, Math.round(width * rangeScale));
+      minBinId = maxBinId - scaledWidth + 1;
+      console.log(`📐 档位宽度倍数 ${rangeScale}: ${width} -> ${scaledWidth} 个bins (${minBinId} - ${maxBinId})`);
+    }
+
     // 验证activeId是否大于或等于maxBinId（在所有bin范围计算完成后）
     const finalActiveId = dlmmPool.lbPair.activeId;
-    if (finalActiveId < maxBinId) {
-        throw new Error(`activeId (${finalActiveId}) 必须大于或等于 maxBinId (${maxBinId})`);
+    if (finalActiveId + binsAboveActive < maxBinId) {
+        throw new Error(`activeId (${finalActiveId}) 必须大于或等于 maxBinId (${maxBinId})${binsAboveActive > 0 ? `（允许上方 ${binsAboveActive} 个bins）` : ''}`);
     }
 
     // 等待一段时间
@@ -802,14 +1040,15 @@ async function main() {
     // await new Promise(resolve => setTimeout(resolve, 20000));
 
     // 优先复用已有 positionAddress；否则加锁创建一次并持久化
-    const poolFile = path.resolve(__dirname, 'data', `${POOL_ADDRESS.toString()}.json`);
-    const lockFile = path.resolve(__dirname, 'data', `${POOL_ADDRESS.toString()}.lock`);
+    const poolFile = poolFilePath(POOL_ADDRESS.toString());
+    const lockFile = path.join(DATA_DIR, `${POOL_ADDRESS.toString()}${leg ? `.${leg}` : ''}.lock`);
     let existingPositionAddress: string | undefined;
     try {
       const raw = fs.readFileSync(poolFile, 'utf8');
       const json = JSON.parse(raw);
-      if (json && typeof json.positionAddress === 'string' && json.positionAddress.trim().length > 0) {
-        existingPositionAddress = json.positionAddress.trim();
+      const addr = readPositionField(json, leg);
+      if (addr) {
+        existingPositionAddress = addr;
       }
     } catch (e) {
       // 文件不存在或解析失败时忽略，按无地址处理
@@ -838,7 +1077,7 @@ async function main() {
           try {
             const raw2 = fs.readFileSync(poolFile, 'utf8');
             const json2 = JSON.parse(raw2);
-            const addr2 = (json2 && typeof json2.positionAddress === 'string') ? json2.positionAddress.trim() : '';
+            const addr2 = readPositionField(json2, leg);
             if (addr2) {
               console.log(`🔁 等锁期间检测到已有仓位，复用: ${addr2}`);
               positionPubKey = new PublicKey(addr2);
@@ -858,7 +1097,7 @@ async function main() {
         try {
           const raw3 = fs.readFileSync(poolFile, 'utf8');
           const json3 = JSON.parse(raw3);
-          const addr3 = (json3 && typeof json3.positionAddress === 'string') ? json3.positionAddress.trim() : '';
+          const addr3 = readPositionField(json3, leg);
           if (addr3) {
             console.log(`🔁 创建前最终校验命中已有仓位，复用: ${addr3}`);
             positionPubKey = new PublicKey(addr3);
@@ -878,11 +1117,13 @@ async function main() {
 
           // 发送并确认创建仓位交易
           console.log('发送创建仓位交易...');
+          applyComputeUnitPrice(createTransaction);
           createTransaction.sign(userKeypair as any, positionKeypair as any);
           const versionedCreateTransaction = new VersionedTransaction(createTransaction.compileMessage());
           versionedCreateTransaction.sign([userKeypair as any, positionKeypair as any]);
           createTxHash = await connection.sendTransaction(versionedCreateTransaction);
           console.log('创建交易哈希:', createTxHash);
+          emitEvent('signature', { signature: createTxHash, action: 'createPosition' });
 
           // 等待交易确认
           console.log('等待交易确认...');
@@ -908,17 +1149,8 @@ async function main() {
           positionPubKey = positionKeypair.publicKey;
           createdNewPosition = true;
           try {
-            let jsonW: any = {};
-            try {
-              const rawW = fs.readFileSync(poolFile, 'utf8');
-              jsonW = JSON.parse(rawW);
-            } catch (e) { jsonW = {}; }
-            const posAddr = positionPubKey.toString();
-            jsonW.positionAddress = posAddr;
-            if (jsonW.data && typeof jsonW.data === 'object') {
-              jsonW.data.positionAddress = posAddr;
-            }
-            fs.writeFileSync(poolFile, JSON.stringify(jsonW, null, 2));
+            const createdAddress = positionPubKey.toString();
+            updateJSONFile(poolFile, (jsonW) => writePositionField(jsonW, leg, createdAddress));
             console.log(`已写入 positionAddress 到 ${poolFile}（创建确认后、加流动性前）`);
           } catch (e: any) {
             console.log('写入 positionAddress 到 JSON 失败:', e?.message || String(e));
@@ -933,7 +1165,7 @@ async function main() {
     // 使用addLiquidityByStrategy添加流动性
     try {
       const strategy = {
-        strategyType: StrategyType.BidAsk,
+        strategyType: resolveStrategyTypeFromArgs(),
         minBinId: minBinId,
         maxBinId: maxBinId,
       };
@@ -944,16 +1176,18 @@ async function main() {
         totalYAmount: tokenYAmount,
         strategy: strategy,
         user: userKeypair.publicKey,
-        slippage: 0.1
+        slippage: resolveSlippageFromArgs()
       });
       
       // 发送并确认添加流动性交易
       console.log('发送添加流动性交易...');
+      applyComputeUnitPrice(addLiquidityTransaction);
       addLiquidityTransaction.sign(userKeypair as any);
       const versionedAddLiquidityTransaction = new VersionedTransaction(addLiquidityTransaction.compileMessage());
       versionedAddLiquidityTransaction.sign([userKeypair as any]);
       const addLiquidityTxHash = await connection.sendTransaction(versionedAddLiquidityTransaction);
       console.log('添加流动性交易哈希:', addLiquidityTxHash);
+      emitEvent('signature', { signature: addLiquidityTxHash, action: 'addLiquidity' });
       
       // 等待交易确认（带重试和状态检查）
       console.log('等待添加流动性交易确认...');
@@ -993,19 +1227,25 @@ async function main() {
       // 仅在创建新仓位时持久化 positionAddress
       if (createdNewPosition) {
         try {
-          let json: any = {};
-          try {
-            const raw = fs.readFileSync(poolFile, 'utf8');
-            json = JSON.parse(raw);
-          } catch (e) {
-            json = {};
-          }
-          const posAddr = positionPubKey!.toString();
-          json.positionAddress = posAddr;
-          if (json.data && typeof json.data === 'object') {
-            json.data.positionAddress = posAddr;
-          }
-          fs.writeFileSync(poolFile, JSON.stringify(json, null, 2));
+          // 记录开仓范围，供 main.go 的仓位生命周期判断是否超出范围
+          const openRange = {
+            minBinId,
+            maxBinId,
+            activeId: finalActiveId,
+            binStep,
+            solAmount,
+            openPrice: latestPrice,
+            openedAt: new Date().toISOString(),
+          };
+          updateJSONFile(poolFile, (json) => {
+            writePositionField(json, leg, positionPubKey!.toString());
+            if (leg) {
+              json.legRanges = (json.legRanges && typeof json.legRanges === 'object') ? json.legRanges : {};
+              json.legRanges[leg] = openRange;
+            } else {
+              json.range = openRange;
+            }
+          });
           console.log(`已写入 positionAddress 到 ${poolFile}`);
         } catch (e: any) {
           console.log('写入 positionAddress 到 JSON 失败:', e?.message || String(e));
@@ -1029,21 +1269,8 @@ async function main() {
           
           // 清理JSON文件中的positionAddress，恢复到执行前状态
           try {
-            let json: any = {};
-            try {
-              const raw = fs.readFileSync(poolFile, 'utf8');
-              json = JSON.parse(raw);
-            } catch (e) {
-              json = {};
-            }
-            // 移除addLiquidity.ts添加的字段
-            delete json.positionAddress;
-            delete json.c;
-            if (json.data && typeof json.data === 'object') {
-              delete json.data.positionAddress;
-              delete json.data.c;
-            }
-            fs.writeFileSync(poolFile, JSON.stringify(json, null, 2));
+            // 移除addLiquidity.ts添加的字段（阶梯档位只清除自身的地址）
+            updateJSONFile(poolFile, (json) => clearPositionField(json, leg));
             console.log('✅ 已清理JSON文件中的positionAddress和c字段，恢复到执行前状态');
           } catch (cleanupError) {
             console.log('⚠️ 清理JSON文件失败:', cleanupError instanceof Error ? cleanupError.message : String(cleanupError));
@@ -1073,6 +1300,7 @@ async function main() {
     
   } catch (error) {
     console.error('错误:', error);
+    emitEvent('error', { code: 'ADD_LIQUIDITY_FAILED', message: error instanceof Error ? error.message : String(error) });
   }
 }
 
@@ -1102,12 +1330,14 @@ async function closeEmptyPosition(
     
     // 执行交易
     console.log('执行关闭仓位交易...');
+    applyComputeUnitPrice(closeTransaction);
     closeTransaction.sign(userKeypair as any);
     const versionedTransaction = new VersionedTransaction(closeTransaction.compileMessage());
     versionedTransaction.sign([userKeypair as any]);
     
     const txHash = await connection.sendTransaction(versionedTransaction);
     console.log('关闭仓位交易哈希:', txHash);
+    emitEvent('signature', { signature: txHash, action: 'closePosition' });
     
     await connection.getSignatureStatus(txHash, { searchTransactionHistory: true });
     console.log('关闭仓位交易已确认');
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AddGuardConfig 同一池已有未平仓仓位时拒绝再次添加流动性（CSV 中重复出现的池、被重新生成的池文件），避免敞口翻倍
+type AddGuardConfig struct {
+	Enabled    bool   `json:"enabled"`
+	TopUpField string `json:"topUpField"` // 信号中该字段为 true / 1 / yes 时对已有仓位追加流动性（addLiquidity.ts --top-up），为空表示一律拒绝
+}
+
+func (c AddGuardConfig) validate() error {
+	if strings.ContainsAny(c.TopUpField, " \t") {
+		return fmt.Errorf("addGuard.topUpField 不能包含空白")
+	}
+	return nil
+}
+
+// poolOpenPosition 池是否已有未平仓仓位，返回仓位地址：模拟池看模拟仓位，实盘池看池状态，没有状态记录（或 FAILED）时看生命周期记录与池文件的 positionAddress
+func poolOpenPosition(poolAddress string) (string, bool) {
+	if isPaperPool(poolAddress) {
+		return "", paperHasOpenPosition(poolAddress)
+	}
+	position := ""
+	if content, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json")); err == nil {
+		obj := map[string]interface{}{}
+		if json.Unmarshal(content, &obj) == nil {
+			position = readPositionFromPoolJSONObject(obj)
+		}
+	}
+	if open, known := poolStateOpen(poolAddress); known {
+		return position, open
+	}
+	lifecycleMutex.Lock()
+	r := loadPositionRecords()[poolAddress]
+	lifecycleMutex.Unlock()
+	return position, position != "" || (r != nil && r.State != positionStateClosed)
+}
+
+// topUpRequested 信号是否明确要求对已有仓位追加流动性
+func topUpRequested(data map[string]interface{}) bool {
+	return signalFlag(data, currentConfig().AddGuard.TopUpField)
+}
+
+// signalFlag 信号字段是否为 true / 1 / yes（field 为空时为 false）
+func signalFlag(data map[string]interface{}, field string) bool {
+	if field == "" {
+		return false
+	}
+	switch v := data[field].(type) {
+	case bool:
+		return v
+	case float64:
+		return v == 1
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "yes":
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddGuard 开仓前检查：池没有未平仓仓位时返回 ("", true)；已有仓位且信号要求追加时返回仓位地址与 true；否则拒绝
+func checkAddGuard(poolAddress, ca string, data map[string]interface{}) (string, bool) {
+	if !currentConfig().AddGuard.Enabled {
+		return "", true
+	}
+	position, open := poolOpenPosition(poolAddress)
+	if !open {
+		return "", true
+	}
+	if !topUpRequested(data) {
+		logWarn("⚠️ 池已有未平仓仓位，拒绝重复添加流动性", "pool", poolAddress, "position", position)
+		recordSkip(subsystemEntry, skipDuplicate, poolAddress, ca, "池已有未平仓仓位")
+		return "", false
+	}
+	if position == "" && !isPaperPool(poolAddress) {
+		logWarn("⚠️ 池文件缺少仓位地址，无法追加流动性", "pool", poolAddress)
+		recordSkip(subsystemEntry, skipDuplicate, poolAddress, ca, "池已有未平仓仓位，池文件缺少仓位地址，无法追加")
+		return "", false
+	}
+	return position, true
+}
+
+// keepOpenPositionFields 追加流动性的信号重写池文件时，保留脚本写入的仓位地址、开仓范围等新信号中没有的字段
+func keepOpenPositionFields(path string, out map[string]interface{}) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	existing := map[string]interface{}{}
+	if json.Unmarshal(content, &existing) != nil {
+		return
+	}
+	for k, v := range existing {
+		if _, ok := out[k]; !ok {
+			out[k] = v
+		}
+	}
+}
+
+// notePositionTopUp 追加成功后累加生命周期记录的投入（开仓时间与状态不变）
+func notePositionTopUp(poolAddress string, solAmount float64) {
+	updatePositionRecord(poolAddress, func(r *PositionRecord) *PositionRecord {
+		if r == nil {
+			return nil
+		}
+		r.SolAmount += solAmount
+		r.Transitions = append(r.Transitions, PositionTransition{State: r.State, At: time.Now().Format(time.RFC3339), Note: fmt.Sprintf("top_up %.6f SOL", solAmount)})
+		return r
+	})
+}
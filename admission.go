@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 准入规则名（记录在拒绝记录与 meteora_admission_rejections_total 的 rule 标签中）
+const (
+	ruleMinLiquidity  = "min_liquidity"
+	ruleBinStep       = "bin_step"
+	ruleTokenAge      = "token_age"
+	ruleCreator       = "creator"
+	ruleMaxPositions  = "max_positions"
+	ruleTokenExposure = "token_exposure"
+)
+
+// 保留的拒绝记录数
+const maxAdmissionRejections = 500
+
+// AdmissionConfig 新池信号的准入规则：在名单策略之后、写出池文件之前依次检查，任一规则不通过即拒绝
+type AdmissionConfig struct {
+	Enabled             bool     `json:"enabled"`
+	MinLiquidity        float64  `json:"minLiquidity"`        // 池流动性（USD）下限，0 表示不检查
+	MinBinStep          int      `json:"minBinStep"`          // bin step 范围，0 表示不限制
+	MaxBinStep          int      `json:"maxBinStep"`          //
+	MinTokenAgeMinutes  float64  `json:"minTokenAgeMinutes"`  // 代币年龄范围（分钟），0 表示不限制
+	MaxTokenAgeMinutes  float64  `json:"maxTokenAgeMinutes"`  //
+	BannedCreators      []string `json:"bannedCreators"`      // 代币创建者黑名单
+	MaxOpenPositions    int      `json:"maxOpenPositions"`    // 同时持仓的池数上限（含处理中的新池），0 表示不限制；启用 portfolio 时开仓前按同一上限再检查
+	MaxTokenExposureSOL float64  `json:"maxTokenExposureSOL"` // 同一代币所有未平仓池的投入 SOL 上限（含本次），0 表示不限制；同上
+
+	// 字段来源：CSV 字段名（映射后）。流动性与 bin step 在 CSV 中缺失时查询 pairApiUrl
+	LiquidityField string `json:"liquidityField"` // 默认 liquidity
+	BinStepField   string `json:"binStepField"`   // 默认 bin_step
+	TokenAgeField  string `json:"tokenAgeField"`  // 代币创建（首次出现）时间，默认 last_updated_first
+	CreatorField   string `json:"creatorField"`   // 默认 creator
+	PairAPIURL     string `json:"pairApiUrl"`     // Meteora 单个池查询接口，<url>/<pool>
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+// AdmissionRejection 一条被拒绝的信号（data/state/admission_rejections.json）
+type AdmissionRejection struct {
+	Time   string `json:"time"`
+	Source string `json:"source"`
+	Pool   string `json:"poolAddress"`
+	Token  string `json:"ca,omitempty"`
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+var admissionHTTP = &http.Client{}
+
+func (c AdmissionConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MinLiquidity < 0 || c.MinBinStep < 0 || c.MaxBinStep < 0 || c.MinTokenAgeMinutes < 0 || c.MaxTokenAgeMinutes < 0 ||
+		c.MaxOpenPositions < 0 || c.MaxTokenExposureSOL < 0 {
+		return fmt.Errorf("admission 的阈值不能为负数")
+	}
+	if c.MaxBinStep > 0 && c.MinBinStep > c.MaxBinStep {
+		return fmt.Errorf("admission.minBinStep 不能大于 maxBinStep")
+	}
+	if c.MaxTokenAgeMinutes > 0 && c.MinTokenAgeMinutes > c.MaxTokenAgeMinutes {
+		return fmt.Errorf("admission.minTokenAgeMinutes 不能大于 maxTokenAgeMinutes")
+	}
+	if c.PairAPIURL == "" {
+		return fmt.Errorf("admission.pairApiUrl 不能为空")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("admission.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+// admissionCheck 检查一条信号，返回未通过的规则与说明（通过时 rule 为空）
+func admissionCheck(profitData *ProfitData) (rule, detail string) {
+	cfg := currentConfig().Admission
+	if !cfg.Enabled {
+		return "", ""
+	}
+	data := profitData.Data
+	pool := profitData.PoolAddress
+	ca, _ := data["ca"].(string)
+
+	// 池信息：CSV 字段优先，缺失时查询一次接口
+	var pair *meteoraPair
+	pairLoaded := false
+	lookup := func() *meteoraPair {
+		if !pairLoaded {
+			pairLoaded = true
+			p, err := fetchPair(pool)
+			if err != nil {
+				logWarn("⚠️ 查询池信息失败", "pool", pool, "error", err)
+			}
+			pair = p
+		}
+		return pair
+	}
+
+	if cfg.MinLiquidity > 0 {
+		liquidity, ok := signalFloat(data, cfg.LiquidityField)
+		if !ok {
+			if p := lookup(); p != nil {
+				liquidity, ok = float64(p.Liquidity), true
+			}
+		}
+		if !ok {
+			return ruleMinLiquidity, "流动性未知"
+		}
+		if liquidity < cfg.MinLiquidity {
+			return ruleMinLiquidity, fmt.Sprintf("流动性 %.0f 低于 %.0f", liquidity, cfg.MinLiquidity)
+		}
+	}
+
+	if cfg.MinBinStep > 0 || cfg.MaxBinStep > 0 {
+		binStep, ok := signalFloat(data, cfg.BinStepField)
+		if !ok {
+			if p := lookup(); p != nil {
+				binStep, ok = float64(p.BinStep), true
+			}
+		}
+		if !ok {
+			return ruleBinStep, "bin step 未知"
+		}
+		if (cfg.MinBinStep > 0 && binStep < float64(cfg.MinBinStep)) || (cfg.MaxBinStep > 0 && binStep > float64(cfg.MaxBinStep)) {
+			return ruleBinStep, fmt.Sprintf("bin step %.0f 不在 [%d, %d] 内", binStep, cfg.MinBinStep, cfg.MaxBinStep)
+		}
+	}
+
+	if cfg.MinTokenAgeMinutes > 0 || cfg.MaxTokenAgeMinutes > 0 {
+		s, _ := data[cfg.TokenAgeField].(string)
+		created, err := parseLastUpdatedFirstToTime(s)
+		if err != nil {
+			return ruleTokenAge, fmt.Sprintf("无法解析 %s: %q", cfg.TokenAgeField, s)
+		}
+		age := time.Since(created).Minutes()
+		if (cfg.MinTokenAgeMinutes > 0 && age < cfg.MinTokenAgeMinutes) || (cfg.MaxTokenAgeMinutes > 0 && age > cfg.MaxTokenAgeMinutes) {
+			return ruleTokenAge, fmt.Sprintf("代币年龄 %.0f 分钟不在允许范围内", age)
+		}
+	}
+
+	if creator, _ := data[cfg.CreatorField].(string); creator != "" {
+		for _, banned := range cfg.BannedCreators {
+			if strings.TrimSpace(creator) == banned {
+				return ruleCreator, "创建者在黑名单中: " + creator
+			}
+		}
+	}
+
+	if cfg.MaxOpenPositions > 0 {
+		if open := openPositionCount(); open >= cfg.MaxOpenPositions {
+			return ruleMaxPositions, fmt.Sprintf("持仓池数 %d 已达上限 %d", open, cfg.MaxOpenPositions)
+		}
+	}
+
+	if cfg.MaxTokenExposureSOL > 0 && ca != "" {
+		exposure := tokenExposureSOL(ca) + plannedDepositSOL()
+		if exposure > cfg.MaxTokenExposureSOL {
+			return ruleTokenExposure, fmt.Sprintf("代币投入 %.4f SOL 将超过上限 %.4f SOL", exposure, cfg.MaxTokenExposureSOL)
+		}
+	}
+	return "", ""
+}
+
+// 信号中的数值字段（字段缺失或无法解析时返回 false）
+func signalFloat(data map[string]interface{}, field string) (float64, bool) {
+	s, ok := data[field].(string)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v, err == nil
+}
+
+// 查询单个 DLMM 池的信息
+func fetchPair(poolAddress string) (*meteoraPair, error) {
+	cfg := currentConfig().Admission
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(cfg.PairAPIURL, "/")+"/"+poolAddress, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := admissionHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var pair meteoraPair
+	if err := json.NewDecoder(resp.Body).Decode(&pair); err != nil {
+		return nil, fmt.Errorf("解析池信息失败: %v", err)
+	}
+	return &pair, nil
+}
+
+// 未平仓的池数（含模拟池）加上正在处理的新池任务
+func openPositionCount() int {
+	open := int(inFlightTasks.Load())
+	for _, r := range listPositionRecords() {
+		if r.State != positionStateClosed {
+			open++
+		}
+	}
+	return open
+}
+
+// 同一代币所有未平仓池的投入 SOL
+func tokenExposureSOL(tokenAddress string) float64 {
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	total := 0.0
+	for _, p := range ledger {
+		if p.TokenAddress == tokenAddress && p.ClosedAt == "" {
+			total += p.CostSOL
+		}
+	}
+	return total
+}
+
+// 本次开仓预计投入的 SOL：阶梯仓位为各档位之和，否则为当前档位的 solAmount（未配置时为 0）
+func plannedDepositSOL() float64 {
+	if ladderEnabled() {
+		total := 0.0
+		for _, leg := range currentConfig().Ladder.Legs {
+			total += leg.SolAmount
+		}
+		return total
+	}
+	_, p := activeProfile()
+	return p.SolAmount
+}
+
+// 记录被拒绝的信号
+func recordAdmissionRejection(sig Signal, profitData *ProfitData, rule, detail string) {
+	ca, _ := profitData.Data["ca"].(string)
+	metricAdmissionRejections.Inc(rule)
+	recordSkip(subsystemEntry, admissionSkipReasons[rule], profitData.PoolAddress, ca, rule+": "+detail)
+	logOutput("🚫 准入规则 %s 拒绝信号: %s（%s）\n", rule, profitData.PoolAddress, detail)
+	appendHistory("admission_rejections", AdmissionRejection{
+		Time: time.Now().Format(time.RFC3339), Source: sig.Source, Pool: profitData.PoolAddress, Token: ca, Rule: rule, Detail: detail,
+	}, maxAdmissionRejections)
+}
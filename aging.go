@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// AgingConfig 仓位老化：开仓超过一定时长、手续费收入仍低于门槛的仓位视为死池，自动领取、移除流动性并兑换为 SOL
+type AgingConfig struct {
+	Enabled              bool    `json:"enabled"`
+	MaxAgeMinutes        float64 `json:"maxAgeMinutes"`        // 开仓超过该时长后开始检查
+	MinFeesUSD           float64 `json:"minFeesUSD"`           // 累计手续费（已领取 + 未领取，USD）达到该值的仓位保留（0 表示不按金额判断）
+	MinFeesPercent       float64 `json:"minFeesPercent"`       // 累计手续费占开仓成本的百分比达到该值的仓位保留（0 表示不按比例判断）
+	CheckIntervalSeconds int     `json:"checkIntervalSeconds"` // 检查间隔
+}
+
+// 平仓原因：老化且手续费不足
+const exitReasonStale = "stale"
+
+// AgingStatus 一个未平仓仓位的老化检查结果（GET /aging）
+type AgingStatus struct {
+	PoolAddress  string  `json:"poolAddress"`
+	TokenAddress string  `json:"ca,omitempty"`
+	OpenedAt     string  `json:"openedAt"`
+	AgeMinutes   float64 `json:"ageMinutes"`
+	FeesUSD      float64 `json:"feesUSD"`             // 已领取 + 最近一次检查时的未领取
+	FeesPercent  float64 `json:"feesPercent"`         // 占开仓成本（成本未知时为 0）
+	FeesKnown    bool    `json:"feesKnown"`           // 开仓后是否已有领取脚本的结果
+	Stale        bool    `json:"stale"`               // 满足老化平仓条件
+	Reason       string  `json:"reason,omitempty"`    // 不平仓的原因或平仓说明
+	ExitAfter    string  `json:"exitAfter,omitempty"` // 未到时长时，最早的检查时间
+}
+
+func (c AgingConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxAgeMinutes <= 0 {
+		return fmt.Errorf("aging.maxAgeMinutes 必须大于0")
+	}
+	if c.MinFeesUSD < 0 || c.MinFeesPercent < 0 {
+		return fmt.Errorf("aging.minFeesUSD、minFeesPercent 不能为负数")
+	}
+	if c.MinFeesUSD == 0 && c.MinFeesPercent == 0 {
+		return fmt.Errorf("aging.minFeesUSD 与 minFeesPercent 至少设置一个")
+	}
+	if c.CheckIntervalSeconds <= 0 {
+		return fmt.Errorf("aging.checkIntervalSeconds 必须大于0")
+	}
+	return nil
+}
+
+// poolFeesUSD 池的累计手续费：盈亏台账中各仓位的累计已领取，加上领取检查记录中最近一次的未领取；
+// 开仓后还没有任何领取结果时 known 为 false
+func poolFeesUSD(poolAddress string, openedAt time.Time) (fees, costUSD float64, known bool) {
+	pnlMutex.Lock()
+	p := loadPnLLedger()[poolAddress]
+	pnlMutex.Unlock()
+	if p != nil && p.ClosedAt == "" {
+		for _, v := range p.Positions {
+			fees += v.ClaimedUSD
+		}
+		known = len(p.Positions) > 0
+		costUSD = p.CostUSD
+		if costUSD == 0 {
+			costUSD = p.CostSOL * p.SolUSD
+		}
+	}
+	for _, c := range listClaimChecks() {
+		if c.PoolAddress != poolAddress {
+			continue
+		}
+		if checked, err := time.Parse(time.RFC3339, c.CheckedAt); err == nil && !checked.Before(openedAt) {
+			fees += c.PendingUSD
+			known = true
+		}
+	}
+	return fees, costUSD, known
+}
+
+// agingStatus 按当前配置检查一个仓位
+func agingStatus(r *PositionRecord, now time.Time) AgingStatus {
+	cfg := currentConfig().Aging
+	st := AgingStatus{PoolAddress: r.PoolAddress, TokenAddress: r.TokenAddress, OpenedAt: r.OpenedAt}
+	opened, err := time.Parse(time.RFC3339, r.OpenedAt)
+	if err != nil {
+		st.Reason = "开仓时间无效"
+		return st
+	}
+	st.AgeMinutes = now.Sub(opened).Minutes()
+	var costUSD float64
+	st.FeesUSD, costUSD, st.FeesKnown = poolFeesUSD(r.PoolAddress, opened)
+	if costUSD > 0 {
+		st.FeesPercent = st.FeesUSD / costUSD * 100
+	}
+	switch {
+	case st.AgeMinutes < cfg.MaxAgeMinutes:
+		st.ExitAfter = opened.Add(time.Duration(cfg.MaxAgeMinutes * float64(time.Minute))).Format(time.RFC3339)
+		st.Reason = "未到老化时长"
+	case !st.FeesKnown:
+		st.Reason = "开仓后还没有领取结果，手续费未知"
+	case cfg.MinFeesUSD > 0 && st.FeesUSD >= cfg.MinFeesUSD:
+		st.Reason = fmt.Sprintf("手续费 %.2f USD 已达到 %g USD", st.FeesUSD, cfg.MinFeesUSD)
+	case cfg.MinFeesPercent > 0 && costUSD <= 0:
+		st.Reason = "开仓成本未知，无法按比例判断"
+	case cfg.MinFeesPercent > 0 && st.FeesPercent >= cfg.MinFeesPercent:
+		st.Reason = fmt.Sprintf("手续费占成本 %.2f%% 已达到 %g%%", st.FeesPercent, cfg.MinFeesPercent)
+	default:
+		st.Stale = true
+		st.Reason = fmt.Sprintf("开仓 %.0f 分钟，手续费 %.2f USD（%.2f%%）", st.AgeMinutes, st.FeesUSD, st.FeesPercent)
+	}
+	return st
+}
+
+// listAgingStatus 各未平仓仓位的老化检查结果（GET /aging）
+func listAgingStatus() []AgingStatus {
+	now := time.Now()
+	result := []AgingStatus{}
+	for _, r := range listPositionRecords() {
+		if r.State != positionStateClosed {
+			result = append(result, agingStatus(r, now))
+		}
+	}
+	return result
+}
+
+// startPositionAging 定期检查未平仓仓位的老化条件（修改阈值可热更新，enabled 与间隔需重启）
+func startPositionAging() {
+	cfg := currentConfig().Aging
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.CheckIntervalSeconds) * time.Second
+	logOutput("⌛ 启动仓位老化检查（每%v，开仓超过 %g 分钟且手续费不足时平仓）\n", interval, cfg.MaxAgeMinutes)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止仓位老化检查\n")
+			return
+		case <-ticker.C:
+			checkPositionAging()
+		}
+	}
+}
+
+// checkPositionAging 对满足条件的仓位领取并平仓（removeLiquidity.ts 平仓后兑换代币）
+func checkPositionAging() {
+	if isPaused() || isPriceOnly() || shuttingDown() || dataVolumeUnavailable() {
+		return
+	}
+	now := time.Now()
+	for _, r := range listPositionRecords() {
+		if globalCtx.Err() != nil {
+			return
+		}
+		if r.State == positionStateClosed {
+			continue
+		}
+		st := agingStatus(r, now)
+		if !st.Stale {
+			continue
+		}
+		if _, busy := closingPools.LoadOrStore(r.PoolAddress, true); busy {
+			continue
+		}
+		logOutput("⌛ 仓位老化且手续费不足（%s），自动领取并平仓: pool=%s\n", st.Reason, r.PoolAddress)
+		claimAndClosePosition(r.PoolAddress, exitReasonStale)
+		closingPools.Delete(r.PoolAddress)
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警规则类型
+const (
+	alertRulePriceChange = "price_change" // 窗口内价格相对最高价下跌（或相对最低价上涨）超过 changePct
+	alertRuleEventCount  = "event_count"  // 窗口内某类总线事件达到 count 次
+	alertRuleNoEvent     = "no_event"     // 窗口内没有某类总线事件
+	alertRulePnL         = "pnl"          // 盈亏合计向上或向下穿越阈值
+)
+
+// 盈亏规则的指标
+const (
+	pnlMetricTotal      = "total"
+	pnlMetricRealized   = "realized"
+	pnlMetricUnrealized = "unrealized"
+)
+
+const (
+	alertRulesTick    = 15 * time.Second // no_event、event_count 解除与 pnl 的检查间隔
+	alertRuleKeyTotal = "total"
+)
+
+// AlertRulesConfig 告警规则：按配置的条件在事件总线与价格存储上求值，满足时以 alert_rule 事件告警（替代写死在各处的告警日志）
+type AlertRulesConfig struct {
+	Enabled bool        `json:"enabled"`
+	Rules   []AlertRule `json:"rules"`
+}
+
+// AlertRule 一条告警规则；条件从不满足变为满足时告警一次，条件解除后重新生效
+type AlertRule struct {
+	Name          string   `json:"name"`
+	Kind          string   `json:"kind"`          // price_change / event_count / no_event / pnl
+	Level         string   `json:"level"`         // info / warning / critical，默认 warning
+	WindowMinutes int      `json:"windowMinutes"` // price_change、event_count、no_event 的时间窗口
+	ChangePct     float64  `json:"changePct"`     // price_change：负数为相对窗口内最高价的跌幅，正数为相对最低价的涨幅
+	Tokens        []string `json:"tokens"`        // price_change：只检查这些代币，为空时检查所有获取价格的代币
+	EventType     string   `json:"eventType"`     // event_count、no_event：总线事件类型
+	Stage         string   `json:"stage"`         // event_count、no_event：只统计该环节（entry / claim / price / sweep），为空表示全部
+	Count         int      `json:"count"`         // event_count：窗口内达到该次数时告警
+	Metric        string   `json:"metric"`        // pnl：total / realized / unrealized（按 reporting.currency 计价）
+	Above         *float64 `json:"above"`         // pnl：向上穿越该值时告警
+	Below         *float64 `json:"below"`         // pnl：向下穿越该值时告警
+	RepeatMinutes int      `json:"repeatMinutes"` // 条件持续满足时重复告警的间隔，0 表示只在条件重新满足时告警
+}
+
+// AlertRuleStatus 规则的当前状态（GET /alert-rules）
+type AlertRuleStatus struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Key     string `json:"key"` // price_change 为代币地址，pnl 为 above / below，其余为 total
+	Active  bool   `json:"active"`
+	FiredAt string `json:"firedAt,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type alertRuleState struct {
+	active  bool
+	firedAt time.Time
+	detail  string
+}
+
+var (
+	alertRulesMutex   sync.Mutex
+	alertRuleStates   = map[string]*alertRuleState{} // 规则名|key -> 状态
+	alertRuleEvents   = map[string][]time.Time{}     // 规则名 -> 窗口内匹配事件的时间
+	alertRuleLastSeen = map[string]time.Time{}       // 规则名 -> 最近一次匹配事件的时间（no_event）
+	alertPnLSeen      = map[string]bool{}            // 已取过盈亏的规则（首次取值不告警）
+	alertRulesStarted = time.Now()
+	alertRulesOnce    sync.Once
+)
+
+func (c AlertRulesConfig) validate(bus EventBusConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	names := map[string]bool{}
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("alertRules.rules[%d].name 不能为空", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("alertRules.rules 的 name 重复: %s", r.Name)
+		}
+		names[r.Name] = true
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("alertRules.rules.%s: %v", r.Name, err)
+		}
+		if r.Kind != alertRulePnL && !bus.Enabled {
+			return fmt.Errorf("alertRules.rules.%s 需要启用 eventBus", r.Name)
+		}
+	}
+	return nil
+}
+
+func (r AlertRule) validate() error {
+	switch r.Level {
+	case "", levelInfo, levelWarning, levelCritical:
+	default:
+		return fmt.Errorf("level 仅支持 info、warning 或 critical")
+	}
+	if r.RepeatMinutes < 0 {
+		return fmt.Errorf("repeatMinutes 不能为负数")
+	}
+	if r.Kind != alertRulePnL && r.WindowMinutes <= 0 {
+		return fmt.Errorf("windowMinutes 必须大于0")
+	}
+	switch r.Kind {
+	case alertRulePriceChange:
+		if r.ChangePct == 0 {
+			return fmt.Errorf("changePct 不能为0（负数为跌幅，正数为涨幅）")
+		}
+		if r.ChangePct <= -100 {
+			return fmt.Errorf("changePct 必须大于 -100")
+		}
+	case alertRuleEventCount, alertRuleNoEvent:
+		if !slices.Contains(busEventTypes, r.EventType) {
+			return fmt.Errorf("不支持的 eventType: %q（可选 %s）", r.EventType, strings.Join(busEventTypes, "、"))
+		}
+		if r.Kind == alertRuleEventCount && r.Count <= 0 {
+			return fmt.Errorf("count 必须大于0")
+		}
+	case alertRulePnL:
+		switch r.Metric {
+		case pnlMetricTotal, pnlMetricRealized, pnlMetricUnrealized:
+		default:
+			return fmt.Errorf("metric 仅支持 total、realized 或 unrealized")
+		}
+		if r.Above == nil && r.Below == nil {
+			return fmt.Errorf("above、below 至少设置一个")
+		}
+		if r.Above != nil && r.Below != nil && *r.Below >= *r.Above {
+			return fmt.Errorf("below 必须小于 above")
+		}
+	default:
+		return fmt.Errorf("kind 仅支持 %s、%s、%s 或 %s", alertRulePriceChange, alertRuleEventCount, alertRuleNoEvent, alertRulePnL)
+	}
+	return nil
+}
+
+func (r AlertRule) level() string {
+	if r.Level == "" {
+		return levelWarning
+	}
+	return r.Level
+}
+
+func (r AlertRule) window() time.Duration {
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+// matches 总线事件是否计入 event_count / no_event 规则
+func (r AlertRule) matches(e BusEvent) bool {
+	return (r.Kind == alertRuleEventCount || r.Kind == alertRuleNoEvent) && e.Type == r.EventType && (r.Stage == "" || e.Stage == r.Stage)
+}
+
+// setAlertCondition 更新规则在 key 上的条件：从不满足变为满足、或持续满足且超过 repeatMinutes 时告警
+func setAlertCondition(r AlertRule, key string, active bool, detail string, fields map[string]string) {
+	alertRulesMutex.Lock()
+	id := r.Name + "|" + key
+	st := alertRuleStates[id]
+	if st == nil {
+		st = &alertRuleState{}
+		alertRuleStates[id] = st
+	}
+	now := time.Now()
+	fire := active && (!st.active || (r.RepeatMinutes > 0 && now.Sub(st.firedAt) >= time.Duration(r.RepeatMinutes)*time.Minute))
+	st.active = active
+	if active {
+		st.detail = detail
+	}
+	if fire {
+		st.firedAt = now
+	}
+	alertRulesMutex.Unlock()
+	if !fire {
+		return
+	}
+
+	logWarn("🚨 告警规则触发", "rule", r.Name, "kind", r.Kind, "key", key, "detail", detail)
+	metricAlertRules.Inc(r.Name)
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	fields["rule"] = r.Name
+	fields["kind"] = r.Kind
+	notifyKeyed(eventAlertRule, r.level(), id, "告警规则 "+r.Name, detail, fields)
+}
+
+// alertRulesSubscriber 总线事件：记录 event_count / no_event 的匹配事件，按 price_fetched 检查价格变化
+func alertRulesSubscriber(e BusEvent) {
+	cfg := currentConfig().AlertRules
+	if !cfg.Enabled {
+		return
+	}
+	for _, r := range cfg.Rules {
+		switch {
+		case r.matches(e):
+			alertRulesMutex.Lock()
+			alertRuleLastSeen[r.Name] = e.Time
+			times := append(pruneAlertTimes(alertRuleEvents[r.Name], e.Time.Add(-r.window())), e.Time)
+			alertRuleEvents[r.Name] = times
+			alertRulesMutex.Unlock()
+			if r.Kind == alertRuleEventCount && len(times) >= r.Count {
+				setAlertCondition(r, alertRuleKeyTotal, true,
+					fmt.Sprintf("%d 分钟内 %s 事件 %d 次（阈值 %d）：%s", r.WindowMinutes, r.EventType, len(times), r.Count, e.Detail),
+					map[string]string{"count": strconv.Itoa(len(times)), "pool": e.Pool, "ca": e.Token})
+			}
+		case r.Kind == alertRulePriceChange && e.Type == busPriceFetched && e.Token != "":
+			if len(r.Tokens) == 0 || slices.Contains(r.Tokens, e.Token) {
+				evaluatePriceChangeRule(r, e)
+			}
+		}
+	}
+}
+
+// evaluatePriceChangeRule 以价格存储中窗口内的最高价（跌幅）或最低价（涨幅）为参考
+func evaluatePriceChangeRule(r AlertRule, e BusEvent) {
+	price, err := strconv.ParseFloat(e.Fields["price"], 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	ref := price
+	for _, p := range storedPrices(e.Token, e.Time.Add(-r.window())) {
+		if (r.ChangePct < 0 && p.Price > ref) || (r.ChangePct > 0 && p.Price < ref) {
+			ref = p.Price
+		}
+	}
+	change := (price - ref) / ref * 100
+	active := (r.ChangePct < 0 && change <= r.ChangePct) || (r.ChangePct > 0 && change >= r.ChangePct)
+	word := map[bool]string{true: "上涨", false: "下跌"}[r.ChangePct > 0]
+	setAlertCondition(r, e.Token, active,
+		fmt.Sprintf("%d 分钟内价格%s %.2f%%（%g → %g，阈值 %g%%）", r.WindowMinutes, word, math.Abs(change), ref, price, math.Abs(r.ChangePct)),
+		map[string]string{"ca": e.Token, "pool": e.Pool, "price": e.Fields["price"], "reference": strconv.FormatFloat(ref, 'g', -1, 64)})
+}
+
+func pruneAlertTimes(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	return times[i:]
+}
+
+// evaluateAlertRules 定时检查：event_count 窗口过后解除、no_event 与 pnl 规则
+func evaluateAlertRules() {
+	cfg := currentConfig().AlertRules
+	if !cfg.Enabled {
+		return
+	}
+	now := time.Now()
+	var report *PnLReport
+	for _, r := range cfg.Rules {
+		switch r.Kind {
+		case alertRuleEventCount:
+			alertRulesMutex.Lock()
+			times := pruneAlertTimes(alertRuleEvents[r.Name], now.Add(-r.window()))
+			alertRuleEvents[r.Name] = times
+			alertRulesMutex.Unlock()
+			if len(times) < r.Count {
+				setAlertCondition(r, alertRuleKeyTotal, false, "", nil)
+			}
+		case alertRuleNoEvent:
+			// 启动后的第一个窗口内不判断；自动化暂停期间不告警
+			alertRulesMutex.Lock()
+			last, ok := alertRuleLastSeen[r.Name]
+			alertRulesMutex.Unlock()
+			if !ok {
+				last = alertRulesStarted
+			}
+			active := now.Sub(last) >= r.window() && !isPaused()
+			detail := fmt.Sprintf("%d 分钟内没有 %s 事件", r.WindowMinutes, r.EventType)
+			if ok {
+				detail += "（最近一次 " + last.Format(time.RFC3339) + "）"
+			}
+			setAlertCondition(r, alertRuleKeyTotal, active, detail, nil)
+		case alertRulePnL:
+			if report == nil {
+				rep := buildPnLReport(reportCurrency())
+				report = &rep
+			}
+			evaluatePnLRule(r, report.Total)
+		}
+	}
+}
+
+// evaluatePnLRule 盈亏进入阈值之外时告警（同价格阈值告警，只在穿越时触发；启动后的首次取值只记录所在区间）
+func evaluatePnLRule(r AlertRule, total PnLSummary) {
+	value := total.Realized + total.Unrealized
+	switch r.Metric {
+	case pnlMetricRealized:
+		value = total.Realized
+	case pnlMetricUnrealized:
+		value = total.Unrealized
+	}
+	sides := map[string]*float64{"above": r.Above, "below": r.Below}
+	in := func(side string) bool {
+		if side == "above" {
+			return r.Above != nil && value >= *r.Above
+		}
+		return r.Below != nil && value <= *r.Below
+	}
+
+	alertRulesMutex.Lock()
+	seen := alertPnLSeen[r.Name]
+	alertPnLSeen[r.Name] = true
+	if !seen {
+		for side, threshold := range sides {
+			if threshold != nil {
+				alertRuleStates[r.Name+"|"+side] = &alertRuleState{active: in(side)}
+			}
+		}
+	}
+	alertRulesMutex.Unlock()
+	if !seen {
+		return
+	}
+	for side, threshold := range sides {
+		if threshold == nil {
+			continue
+		}
+		setAlertCondition(r, side, in(side),
+			fmt.Sprintf("盈亏（%s）%s %g %s，当前 %.4f", r.Metric, map[string]string{"above": "升至", "below": "降至"}[side], *threshold, total.Currency, value),
+			map[string]string{"metric": r.Metric, "value": formatFloat(value), "currency": total.Currency})
+	}
+}
+
+// listAlertRuleStatus 各规则的状态（按规则名与 key 排序）
+func listAlertRuleStatus() []AlertRuleStatus {
+	kinds := map[string]string{}
+	for _, r := range currentConfig().AlertRules.Rules {
+		kinds[r.Name] = r.Kind
+	}
+	alertRulesMutex.Lock()
+	defer alertRulesMutex.Unlock()
+	result := []AlertRuleStatus{}
+	for id, st := range alertRuleStates {
+		name, key, _ := strings.Cut(id, "|")
+		kind, ok := kinds[name]
+		if !ok {
+			continue
+		}
+		s := AlertRuleStatus{Name: name, Kind: kind, Key: key, Active: st.active, Detail: st.detail}
+		if !st.firedAt.IsZero() {
+			s.FiredAt = st.firedAt.Format(time.RFC3339)
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Name != result[b].Name {
+			return result[a].Name < result[b].Name
+		}
+		return result[a].Key < result[b].Key
+	})
+	return result
+}
+
+// startAlertRules 订阅总线事件并定时检查规则（启停与规则可热更新）
+func startAlertRules() {
+	alertRulesOnce.Do(func() {
+		subscribeEvents("alertRules", alertRulesSubscriber)
+		alertRulesStarted = time.Now()
+	})
+	ticker := time.NewTicker(alertRulesTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			evaluateAlertRules()
+		}
+	}
+}
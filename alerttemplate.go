@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 告警模板目录：<dir>/<语言>/<事件>.tmpl，default.tmpl 为该语言的默认模板
+func defaultAlertTemplateDir() string { return basePath("templates", "notify") }
+
+// 默认语言
+const defaultAlertLanguage = "zh"
+
+// 内置模板：与原有告警文本格式一致（级别、标题、部署标签、正文、按字母序的字段）
+const builtinAlertTemplate = `[{{upper .Level}}] {{.Title}}{{with .Tag}} {{.}}{{end}}{{with .Text}}
+{{.}}{{end}}{{range fieldLines .Fields}}
+{{.}}{{end}}`
+
+// 英文内置模板：标题按事件类型换成英文（未知事件保留原标题）
+const builtinAlertTemplateEN = `[{{upper .Level}}] {{eventTitle .Event .Title}}{{with .Tag}} {{.}}{{end}}{{with .Text}}
+{{.}}{{end}}{{range fieldLines .Fields}}
+{{.}}{{end}}`
+
+var alertEventTitlesEN = map[string]string{
+	eventNewPool:             "New pool detected",
+	eventAddLiquiditySuccess: "Liquidity added",
+	eventAddLiquidityFailure: "Add liquidity failed",
+	eventClaimFailure:        "Claim failed",
+	eventSwapFailure:         "Swap failed",
+	eventSwapDeviation:       "Swap fill deviated from quote",
+	eventPriceThreshold:      "Price threshold crossed",
+	eventStopLoss:            "Stop loss triggered",
+	eventTakeProfit:          "Take profit triggered",
+	eventShutdown:            "Bot stopped",
+	eventCircuitOpen:         "Circuit breaker open",
+	eventWalletActivity:      "Unexpected wallet transaction",
+	eventTripwire:            "Tripwire triggered",
+	eventRateGuard:           "Automation rate limit exceeded, paused",
+	eventClockDrift:          "Clock drift too large",
+	eventListPolicy:          "Ban/allow list policy hit",
+	eventLowBalance:          "Low SOL balance",
+	eventConfigReload:        "Config reload failed",
+	eventAutoBan:             "Token auto-banned",
+	eventRPCDegrade:          "RPC rate limited, running degraded",
+	eventTxFailed:            "Transaction failed or dropped",
+	eventClusterHealth:       "RPC node or cluster unhealthy",
+	eventJobInterrupted:      "Command interrupted by shutdown",
+	eventRebalance:           "Position rebalanced",
+	eventDataVolume:          "Data directory unavailable",
+	eventDailySummary:        "Daily summary",
+	eventTokenUnsafe:         "Token failed safety check",
+	eventBus:                 "Bot event",
+	eventPoolStuck:           "Pool stuck in state",
+	eventSubsystemRestart:    "Subsystem crashed or wedged, restarting",
+	eventGoroutinePanic:      "Background worker panicked and recovered",
+	eventPanicClose:          "Manual panic close",
+	eventKillSwitch:          "Kill switch toggled",
+}
+
+// alertTemplateData 模板可用的字段：Alert 的全部字段，加上部署标签 Tag
+type alertTemplateData struct {
+	Alert
+	Tag string
+}
+
+var alertTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// 按字段名排序的 "key: value" 行
+	"fieldLines": func(fields map[string]string) []string {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lines := make([]string, 0, len(keys))
+		for _, k := range keys {
+			lines = append(lines, k+": "+fields[k])
+		}
+		return lines
+	},
+	"field": func(fields map[string]string, key string) string { return fields[key] },
+	"short": func(addr string) string {
+		if len(addr) <= 10 {
+			return addr
+		}
+		return addr[:4] + "…" + addr[len(addr)-4:]
+	},
+	"time": func(t time.Time, layout string) string { return t.In(appLocation).Format(layout) },
+	"eventTitle": func(event, title string) string {
+		if t, ok := alertEventTitlesEN[event]; ok {
+			return t
+		}
+		return title
+	},
+}
+
+// alertTemplates 编译后的模板：语言 -> 事件（"*" 为默认）-> 模板
+type alertTemplates map[string]map[string]*template.Template
+
+// 编译告警模板。优先级：配置中的 templates > 模板目录中的文件 > 内置模板
+func buildAlertTemplates(cfg NotifyConfig) (alertTemplates, error) {
+	sources := map[string]map[string]string{
+		defaultAlertLanguage: {"*": builtinAlertTemplate},
+		"en":                 {"*": builtinAlertTemplateEN},
+	}
+	set := func(lang, event, text string) {
+		if sources[lang] == nil {
+			sources[lang] = map[string]string{}
+		}
+		sources[lang][event] = text
+	}
+
+	dir := cfg.TemplateDir
+	if dir == "" {
+		dir = defaultAlertTemplateDir()
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*.tmpl"))
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取告警模板失败: %v", err)
+		}
+		event := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if event == "default" {
+			event = "*"
+		}
+		set(filepath.Base(filepath.Dir(path)), event, strings.TrimRight(string(content), "\n"))
+	}
+	for lang, events := range cfg.Templates {
+		for event, text := range events {
+			set(lang, event, text)
+		}
+	}
+
+	result := alertTemplates{}
+	for lang, events := range sources {
+		result[lang] = map[string]*template.Template{}
+		for event, text := range events {
+			t, err := template.New(lang + "/" + event).Funcs(alertTemplateFuncs).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("告警模板 %s/%s 解析失败: %v", lang, event, err)
+			}
+			result[lang][event] = t
+		}
+	}
+	return result, nil
+}
+
+// render 按语言与事件选择模板：该语言的事件模板 > 该语言的默认模板 > 默认语言的事件模板 > 默认语言的默认模板；
+// 渲染失败时退回内置格式，避免告警丢失
+func (ts alertTemplates) render(alert Alert, lang string) string {
+	data := alertTemplateData{Alert: alert, Tag: deploymentTag(alert.Environment, alert.Instance)}
+	for _, candidate := range []struct{ lang, event string }{
+		{lang, alert.Event}, {lang, "*"}, {defaultAlertLanguage, alert.Event}, {defaultAlertLanguage, "*"},
+	} {
+		t := ts[candidate.lang][candidate.event]
+		if t == nil {
+			continue
+		}
+		var sb strings.Builder
+		if err := t.Execute(&sb, data); err != nil {
+			logOutput("⚠️ 告警模板 %s 渲染失败: %v\n", t.Name(), err)
+			break
+		}
+		return sb.String()
+	}
+	return formatAlertText(alert)
+}
@@ -0,0 +1,771 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 程序启动时间（用于 /status 输出运行时长）
+var startedAt = time.Now()
+
+// PoolRecord 对外输出的池信息（来自 data/<pool>.json）
+type PoolRecord struct {
+	PoolAddress      string `json:"poolAddress"`
+	PoolName         string `json:"poolName,omitempty"`
+	TokenAddress     string `json:"ca,omitempty"`
+	PositionAddress  string `json:"positionAddress,omitempty"`
+	LastUpdatedFirst string `json:"lastUpdatedFirst,omitempty"`
+	Source           string `json:"source,omitempty"` // 生成该记录的 CSV 源
+	Mode             string `json:"mode"`
+	Wallet           string `json:"wallet,omitempty"` // 多钱包时分配的钱包
+}
+
+// 列出 data 目录下所有池记录
+func listPoolRecords() []PoolRecord {
+	records := []PoolRecord{}
+	files, err := os.ReadDir(poolDataDir())
+	if err != nil {
+		logOutput("❌ 读取data目录失败: %v\n", err)
+		return records
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		poolAddress := strings.TrimSuffix(file.Name(), ".json")
+		records = append(records, PoolRecord{
+			PoolAddress:      poolAddress,
+			PoolName:         readPoolNameFromPoolJSON(poolAddress),
+			TokenAddress:     readTokenContractAddressFromPoolJSON(poolAddress),
+			PositionAddress:  readPositionFromPoolJSON(poolAddress),
+			LastUpdatedFirst: readLastUpdatedFirstFromPoolJSON(poolAddress),
+			Source:           readSourceFromPoolJSON(poolAddress),
+			Mode:             getPoolMode(poolAddress),
+			Wallet:           poolWallet(poolAddress),
+		})
+	}
+	return records
+}
+
+// 检查池 JSON 是否存在（同时防止路径穿越）
+func poolExists(poolAddress string) bool {
+	if poolAddress == "" || strings.ContainsAny(poolAddress, `/\.`) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(poolDataDir(), poolAddress+".json"))
+	return err == nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// 限定请求方法
+func methodOnly(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// 不需要访问令牌的路径：存活 / 就绪检查（负载均衡与进程管理器探测）、信号推送（自带令牌与签名，见 ingest.http）、
+// 内嵌面板的静态页面（页面中的请求另带令牌）
+func apiAuthExempt(path string) bool {
+	switch path {
+	case "/healthz", "/readyz", "/signals", "/":
+		return true
+	}
+	return strings.HasPrefix(path, "/ui/")
+}
+
+// requireAPIToken 管理接口鉴权：要求 Authorization: Bearer <api.token>，按常量时间比较；未配置令牌时拒绝所有请求
+func requireAPIToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthExempt(r.URL.Path) {
+			token := currentConfig().API.Token
+			got := r.Header.Get("Authorization")
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newAPIRequest 访问运行中进程（或主实例）管理接口的请求，带本实例配置的 api.token
+func newAPIRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := currentConfig().API.Token; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// 构建 HTTP 路由
+func newAPIMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"instance":     deployInstance,
+			"environment":  deployEnvironment,
+			"startedAt":    startedAt.Format(time.RFC3339),
+			"uptime":       time.Since(startedAt).Round(time.Second).String(),
+			"mode":         currentConfig().Mode,
+			"paused":       isPaused(),
+			"frozen":       isFrozen(),
+			"killSwitch":   killSwitchActive(),
+			"profile":      activeProfileName(),
+			"backpressure": currentBackpressure(),
+			"clock":        currentClockStatus(),
+			"rpcDegrade":   currentRPCDegrade(),
+			"cluster":      currentClusterHealth(),
+			"blockhash":    currentBlockhash(),
+			"lastClaim":    lastClaimRound(),
+			"lastSwap":     lastSwapRound(),
+		})
+	}))
+
+	// 存活与就绪检查：通过时 200，否则 503
+	mux.HandleFunc("/healthz", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, livenessReport())
+	}))
+
+	mux.HandleFunc("/readyz", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, readinessReport())
+	}))
+
+	mux.HandleFunc("/wallet/balance", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if !currentConfig().BalanceMonitor.Enabled {
+			writeError(w, http.StatusNotFound, "balanceMonitor 未启用")
+			return
+		}
+		writeJSON(w, http.StatusOK, currentWalletBalance())
+	}))
+
+	mux.HandleFunc("/claims/last", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		s := lastClaimRound()
+		if s == nil {
+			writeError(w, http.StatusNotFound, "尚无领取汇总")
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	}))
+
+	mux.HandleFunc("/claims/pending", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listClaimChecks())
+	}))
+
+	// 各池累计领取到账的代币数量与估值；/claims/<pool> 含每次领取的明细与交易签名
+	mux.HandleFunc("/claims", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listPoolClaims())
+	}))
+	mux.HandleFunc("/claims/", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		pool := strings.Trim(strings.TrimPrefix(r.URL.Path, "/claims/"), "/")
+		pc, ok := poolClaims(pool)
+		if !ok {
+			writeError(w, http.StatusNotFound, "该池没有领取记录")
+			return
+		}
+		writeJSON(w, http.StatusOK, pc)
+	}))
+
+	// 各 goroutine 已恢复的 panic 汇总
+	mux.HandleFunc("/panics", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listPanics())
+	}))
+
+	// 入场冷却中的代币
+	mux.HandleFunc("/cooldowns", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listTokenCooldowns())
+	}))
+
+	// 告警规则的当前状态与最近一次告警
+	mux.HandleFunc("/alert-rules", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listAlertRuleStatus())
+	}))
+
+	// 受守护子系统的状态与累计放弃的定时任务轮次
+	mux.HandleFunc("/supervisor", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"pid":        os.Getpid(),
+			"daemon":     daemonMode,
+			"subsystems": listSupervised(),
+			"wedgedRuns": wedgedRuns.Load(),
+		})
+	}))
+
+	mux.HandleFunc("/inflight", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"commands": listActiveJobs(), "tasks": inFlightTasks.Load()})
+	}))
+
+	mux.HandleFunc("/wal", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, walStatus())
+	}))
+
+	// 最近 days 天（默认 1）的跳过原因统计与最近 limit 条记录
+	mux.HandleFunc("/skips", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, skipSummary(queryInt(r, "days", 1), queryInt(r, "limit", 100)))
+	}))
+
+	mux.HandleFunc("/events", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		writeJSON(w, http.StatusOK, recentEvents(queryInt(r, "days", 1), queryInt(r, "limit", 100), q.Get("type"), q.Get("pool")))
+	}))
+
+	// 各池的状态机状态（可按 state 过滤）
+	mux.HandleFunc("/pool-states", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listPoolStates(strings.ToUpper(r.URL.Query().Get("state"))))
+	}))
+
+	// 当前敞口、剩余额度与等待额度的池文件
+	mux.HandleFunc("/portfolio", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentPortfolioStatus())
+	}))
+
+	// 等待入场条件的池文件（条件、参考价格、最高价、过期时间）
+	mux.HandleFunc("/entry-waiting", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listEntryWaiting())
+	}))
+
+	mux.HandleFunc("/queue", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listQueuedJobs())
+	}))
+
+	mux.HandleFunc("/tokens/metadata", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listTokenMetadata())
+	}))
+
+	mux.HandleFunc("/overrides", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, poolOverrideStatus())
+	}))
+
+	mux.HandleFunc("/ratelimits", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listAPIBudgets())
+	}))
+
+	mux.HandleFunc("/rpc/endpoints", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listRPCEndpoints())
+	}))
+
+	mux.HandleFunc("/fees/priority", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentPriorityFee())
+	}))
+
+	mux.HandleFunc("/transactions", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listTrackedTx(r.URL.Query().Get("status")))
+	}))
+
+	mux.HandleFunc("/wallets", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listWalletSummaries())
+	}))
+
+	mux.HandleFunc("/swaps/last", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		s := lastSwapRound()
+		if s == nil {
+			writeError(w, http.StatusNotFound, "尚无兑换汇总")
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	}))
+
+	mux.HandleFunc("/backpressure", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentBackpressure())
+	}))
+
+	mux.HandleFunc("/metrics", methodOnly(http.MethodGet, metricsHandler))
+
+	// 主备状态；/leader/snapshot 供备用实例拉取状态快照（tar.gz，仅主实例提供）
+	mux.HandleFunc("/leader", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentLeaderStatus())
+	}))
+	mux.HandleFunc("/leader/snapshot", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if !leaderElecting.Load() || !isLeader() {
+			writeError(w, http.StatusConflict, "本实例不是主实例")
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		if err := writeLeaderSnapshot(w); err != nil {
+			logWarn("⚠️ 写出状态快照失败", "error", err)
+		}
+	}))
+
+	// /pools?source=<name> 按 CSV 源筛选
+	mux.HandleFunc("/pools", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		source := r.URL.Query().Get("source")
+		pools := []PoolRecord{}
+		for _, rec := range listPoolRecords() {
+			if source == "" || rec.Source == source {
+				pools = append(pools, rec)
+			}
+		}
+		writeJSON(w, http.StatusOK, pools)
+	}))
+
+	mux.HandleFunc("/positions", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		positions := []PoolRecord{}
+		for _, rec := range listPoolRecords() {
+			if rec.PositionAddress != "" {
+				positions = append(positions, rec)
+			}
+		}
+		writeJSON(w, http.StatusOK, positions)
+	}))
+
+	mux.HandleFunc("/breakers", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listBreakerStatus())
+	}))
+
+	mux.HandleFunc("/data-volume", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentDataVolumeStatus())
+	}))
+	mux.HandleFunc("/rebalances", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listRebalanceRecords())
+	}))
+
+	mux.HandleFunc("/aging", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if !currentConfig().Aging.Enabled {
+			writeError(w, http.StatusNotFound, "aging 未启用")
+			return
+		}
+		writeJSON(w, http.StatusOK, listAgingStatus())
+	}))
+
+	// 已归档的池；POST /archive/run 立即执行一次归档与清理，POST /archive/<pool> 立即归档一个没有仓位的池
+	mux.HandleFunc("/archive", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listArchivedPools())
+	}))
+	mux.HandleFunc("/archive/", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		target := strings.Trim(strings.TrimPrefix(r.URL.Path, "/archive/"), "/")
+		if target == "run" {
+			if !currentConfig().Archive.Enabled {
+				writeError(w, http.StatusNotFound, "archive 未启用")
+				return
+			}
+			writeJSON(w, http.StatusOK, runArchive())
+			return
+		}
+		if err := archiveManually(target); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"pool": target, "archived": true})
+	}))
+
+	// 各池的手续费累积速率、活跃度分类与下一次加领 / 退避结束时间
+	mux.HandleFunc("/adaptive", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if !currentConfig().Adaptive.Enabled {
+			writeError(w, http.StatusNotFound, "adaptive 未启用")
+			return
+		}
+		writeJSON(w, http.StatusOK, listPoolActivity())
+	}))
+
+	mux.HandleFunc("/subscriptions", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, accountSubscriptionStatus())
+	}))
+
+	mux.HandleFunc("/lifecycle", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listPositionRecords())
+	}))
+
+	mux.HandleFunc("/pnl", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		currency := reportCurrency()
+		if c := strings.ToUpper(r.URL.Query().Get("currency")); c != "" {
+			if !validCurrency(c) {
+				writeError(w, http.StatusBadRequest, "currency must be SOL, USDC or USD")
+				return
+			}
+			currency = c
+		}
+		writeJSON(w, http.StatusOK, buildPnLReport(currency))
+	}))
+
+	// 交易成本：按天与按池的汇总、最近的逐笔记录（?pool= 只看单个池，?limit= 限制逐笔条数）
+	mux.HandleFunc("/costs", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		writeJSON(w, http.StatusOK, txCostReport(r.URL.Query().Get("pool"), limit))
+	}))
+
+	// 立即按配置导出（写入导出目录或 S3），返回写出的文件
+	mux.HandleFunc("/export", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, runExport(nil, nil))
+	}))
+
+	// /export/<数据集>?format=csv|parquet&days=N 直接下载一个数据集（days 只用于价格历史）
+	mux.HandleFunc("/export/", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		dataset := strings.Trim(strings.TrimPrefix(r.URL.Path, "/export/"), "/")
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportFormatCSV
+		}
+		if format != exportFormatCSV && format != exportFormatParquet {
+			writeError(w, http.StatusBadRequest, "format must be csv or parquet")
+			return
+		}
+		if !slices.Contains(exportDatasets, dataset) {
+			writeError(w, http.StatusNotFound, "unknown dataset")
+			return
+		}
+		t, err := buildExportTable(dataset, queryInt(r, "days", currentConfig().Export.PriceDays))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		data, err := encodeExport(t, format)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", exportContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, dataset, format))
+		w.Write(data)
+	}))
+
+	mux.HandleFunc("/summary/daily", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		day := r.URL.Query().Get("date")
+		if day == "" {
+			day = appNow().Format("2006-01-02")
+		} else if _, err := time.Parse("2006-01-02", day); err != nil {
+			writeError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		writeJSON(w, http.StatusOK, buildDailySummary(day))
+	}))
+
+	mux.HandleFunc("/signals", methodOnly(http.MethodPost, signalsHandler))
+
+	mux.HandleFunc("/ab", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, buildABReport())
+	}))
+
+	mux.HandleFunc("/wallet", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listExternalWalletTx())
+	}))
+
+	mux.HandleFunc("/groups", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listPositionGroups())
+	}))
+
+	mux.HandleFunc("/jobs", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listJobStatus())
+	}))
+
+	// 未来 minutes 分钟（默认 60，最长 1440）的任务触发计划与各池的领取预计
+	mux.HandleFunc("/schedule/upcoming", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, schedulePreview(queryInt(r, "minutes", schedulePreviewMinutes)))
+	}))
+
+	// /jobs/{name}/pause 与 /jobs/{name}/resume
+	mux.HandleFunc("/jobs/", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/"), "/")
+		if len(parts) != 2 || (parts[1] != "pause" && parts[1] != "resume") {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if !setJobPaused(parts[0], parts[1] == "pause") {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"job": parts[0], "paused": parts[1] == "pause"})
+	}))
+
+	mux.HandleFunc("/config", configHandler)
+	// 生效配置与安全限制概览
+	mux.HandleFunc("/config/summary", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentConfigSummary())
+	}))
+
+	mux.HandleFunc("/config/reload", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		result := reloadConfig()
+		if result.Error != "" {
+			writeJSON(w, http.StatusBadRequest, result)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}))
+
+	mux.HandleFunc("/pause", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		setPaused(true)
+		logOutput("⏸️ 已通过API暂停自动化处理\n")
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+	}))
+
+	mux.HandleFunc("/resume", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		setPaused(false)
+		logOutput("▶️ 已通过API恢复自动化处理\n")
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+	}))
+
+	mux.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, currentProfileStatus())
+		case http.MethodPost:
+			if err := setActiveProfile(r.URL.Query().Get("name")); err != nil {
+				writeError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, currentProfileStatus())
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	// /bans：GET 列出；POST ?kind=token|pool&address=&reason=&ttl=24h 加入；DELETE ?address= 移除
+	mux.HandleFunc("/bans", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, listBans())
+		case http.MethodPost:
+			var ttl time.Duration
+			if s := q.Get("ttl"); s != "" {
+				d, err := time.ParseDuration(s)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "invalid ttl: "+err.Error())
+					return
+				}
+				ttl = d
+			}
+			kind := q.Get("kind")
+			if kind == "" {
+				kind = banKindToken
+			}
+			e, err := addBan(kind, q.Get("address"), q.Get("reason"), banSourceAPI, ttl)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, e)
+		case http.MethodDelete:
+			ok, err := removeBan(q.Get("address"))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if !ok {
+				writeError(w, http.StatusNotFound, "ban not found")
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"removed": q.Get("address")})
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	mux.HandleFunc("/admission/rejections", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[AdmissionRejection]("admission_rejections"))
+	}))
+	mux.HandleFunc("/csv/rejections", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[CSVRejection]("csv_rejections"))
+	}))
+	mux.HandleFunc("/token-safety", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[TokenSafetyReport]("token_safety"))
+	}))
+
+	// 最近的复投记录
+	mux.HandleFunc("/compounds", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[CompoundRecord]("compounds"))
+	}))
+
+	mux.HandleFunc("/rate-guard", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentRateGuardStatus())
+	}))
+
+	mux.HandleFunc("/freeze", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, currentFreezeState())
+		case http.MethodPost:
+			freezeTransactions("通过API手动冻结", "")
+			writeJSON(w, http.StatusOK, currentFreezeState())
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	mux.HandleFunc("/unfreeze", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, unfreezeTransactions())
+	}))
+
+	// /kill-switch：GET 查看；POST ?reason= 开启；DELETE 关闭（文件开关需删除文件）
+	mux.HandleFunc("/kill-switch", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, currentKillSwitch())
+		case http.MethodPost:
+			reason := r.URL.Query().Get("reason")
+			if reason == "" {
+				reason = "通过API手动开启"
+			}
+			writeJSON(w, http.StatusOK, setKillSwitch(true, reason, killSwitchSourceAPI))
+		case http.MethodDelete:
+			writeJSON(w, http.StatusOK, setKillSwitch(false, "通过API手动关闭", killSwitchSourceAPI))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	// /pools/{addr}/claim、/pools/{addr}/close、/pools/{addr}/withdraw?percent=N、/pools/{addr}/panic-close、/pools/{addr}/promote、/pools/{addr}/demote、
+	// /pools/{addr}/compound-on、/pools/{addr}/compound-off
+	mux.HandleFunc("/pools/", methodOnly(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/pools/"), "/"), "/")
+		if len(parts) != 2 {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		percent := 100.0
+		if parts[1] == "withdraw" {
+			var err error
+			if percent, err = strconv.ParseFloat(r.URL.Query().Get("percent"), 64); err != nil {
+				percent = 0
+			}
+		}
+		status, result, err := performPoolAction(parts[0], parts[1], percent)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, status, result)
+	}))
+
+	registerDashboardRoutes(mux)
+	return mux
+}
+
+// performPoolAction 执行池的人工操作（HTTP 与 gRPC 共用）：claim、close、withdraw、panic-close、promote、demote、compound-on、compound-off。
+// 返回 HTTP 状态码与响应内容；失败时状态码对应错误类型
+func performPoolAction(poolAddress, action string, percent float64) (int, map[string]interface{}, error) {
+	if action == "promote" || action == "demote" {
+		if !poolExists(poolAddress) {
+			return http.StatusNotFound, nil, errors.New("pool not found")
+		}
+		mode := poolModeLive
+		if action == "demote" {
+			mode = poolModePaper
+		}
+		if err := setPoolMode(poolAddress, mode); err != nil {
+			return http.StatusConflict, nil, err
+		}
+		return http.StatusOK, map[string]interface{}{"pool": poolAddress, "mode": mode}, nil
+	}
+	if action == "compound-on" || action == "compound-off" {
+		if !poolExists(poolAddress) {
+			return http.StatusNotFound, nil, errors.New("pool not found")
+		}
+		if err := setPoolCompound(poolAddress, action == "compound-on"); err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		return http.StatusOK, map[string]interface{}{"pool": poolAddress, "compound": compoundEnabled(poolAddress)}, nil
+	}
+	if action != "claim" && action != "close" && action != "withdraw" && action != "panic-close" {
+		return http.StatusNotFound, nil, errors.New("unknown action")
+	}
+	if isPriceOnly() {
+		return http.StatusConflict, nil, errors.New("price-only mode does not send transactions")
+	}
+	if !poolExists(poolAddress) {
+		return http.StatusNotFound, nil, errors.New("pool not found")
+	}
+	if action == "panic-close" {
+		logOutput("🖐️ API触发紧急平仓: %s\n", poolAddress)
+		result, _, err := panicClosePool(poolAddress, "", banSourceAPI)
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		return http.StatusAccepted, map[string]interface{}{"pool": poolAddress, "action": action, "status": result.Status,
+			"token": result.Token, "ban": result.Ban, "cancelled": result.Cancelled}, nil
+	}
+	if action != "withdraw" {
+		percent = 100
+	} else if validatePercent(percent) != nil {
+		return http.StatusBadRequest, nil, errors.New("percent must be in (0, 100]")
+	}
+	if isPaperPool(poolAddress) {
+		if action == "withdraw" {
+			runPartialWithdraw(poolAddress, percent, exitReasonManual)
+		} else if action == "close" {
+			claimAndClosePosition(poolAddress, exitReasonManual)
+		} else {
+			runClaimRewards(poolAddress)
+		}
+		return http.StatusOK, map[string]interface{}{"pool": poolAddress, "action": action, "status": "simulated"}, nil
+	}
+	if readPositionFromPoolJSON(poolAddress) == "" {
+		return http.StatusConflict, nil, errors.New("pool has no positionAddress")
+	}
+
+	switch action {
+	case "claim":
+		logOutput("🖐️ API触发领取奖励: %s\n", poolAddress)
+		enqueueClaim(poolAddress)
+	case "close":
+		logOutput("🖐️ API触发领取并平仓: %s\n", poolAddress)
+		runInBackground(func() { claimAndClosePosition(poolAddress, exitReasonManual) })
+	case "withdraw":
+		logOutput("🖐️ API触发部分移除 %g%%: %s\n", percent, poolAddress)
+		runInBackground(func() { runPartialWithdraw(poolAddress, percent, exitReasonManual) })
+	}
+	return http.StatusAccepted, map[string]interface{}{"pool": poolAddress, "action": action, "status": "accepted"}, nil
+}
+
+// 后台执行人工触发的任务（纳入优雅关闭等待）
+func runInBackground(fn func()) {
+	shutdownWg.Add(1)
+	go func() {
+		defer shutdownWg.Done()
+		defer recoverPanic("api:background", "")
+		fn()
+	}()
+}
+
+// 启动 HTTP 管理接口
+func startAPIServer() {
+	if !currentConfig().API.Enabled {
+		return
+	}
+	server := &http.Server{
+		Addr:              currentConfig().API.Listen,
+		Handler:           recoverHandler("api", requireAPIToken(newAPIMux())),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-globalCtx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	logOutput("🌐 HTTP管理接口已启动: http://%s\n", currentConfig().API.Listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logOutput("❌ HTTP管理接口异常退出: %v\n", err)
+	}
+}
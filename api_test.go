@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIToken(t *testing.T) {
+	prev := currentConfig()
+	defer setConfig(prev)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	tests := []struct {
+		name   string
+		token  string // 配置的 api.token
+		path   string
+		auth   string
+		status int
+	}{
+		{"令牌正确", "s3cret", "/pools/x/close", "Bearer s3cret", http.StatusOK},
+		{"缺少令牌", "s3cret", "/pools/x/close", "", http.StatusUnauthorized},
+		{"令牌错误", "s3cret", "/config", "Bearer wrong", http.StatusUnauthorized},
+		{"缺少 Bearer 前缀", "s3cret", "/pause", "s3cret", http.StatusUnauthorized},
+		{"未配置令牌时拒绝空令牌", "", "/kill-switch", "Bearer ", http.StatusUnauthorized},
+		{"指标同样需要令牌", "s3cret", "/metrics", "", http.StatusUnauthorized},
+		{"存活检查不需要令牌", "s3cret", "/healthz", "", http.StatusOK},
+		{"就绪检查不需要令牌", "s3cret", "/readyz", "", http.StatusOK},
+		{"信号推送自带鉴权", "s3cret", "/signals", "", http.StatusOK},
+		{"面板静态页面", "s3cret", "/ui/index.html", "", http.StatusOK},
+		{"路径前缀不能绕过", "s3cret", "/healthz/../pause", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *prev
+			cfg.API.Token = tt.token
+			setConfig(&cfg)
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			requireAPIToken(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("%s %q: 状态 %d，应为 %d", tt.path, tt.auth, rec.Code, tt.status)
+			}
+		})
+	}
+}
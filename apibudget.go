@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 上游 API 的共享请求预算：按 priceFetch.limiters 的令牌桶限速，响应带限速头（X-RateLimit-Remaining / Reset、RateLimit-*）时
+// 按上游报告的剩余次数放行、用完后排队到窗口重置，429 时按 Retry-After（没有时指数退避）暂停该 API 的所有请求。
+// 价格获取、多源价格、兑换报价与脚本（ratelimit 事件）共用同一个预算。
+
+// 429 没有 Retry-After 时的退避范围
+const (
+	apiBackoffMin = time.Second
+	apiBackoffMax = time.Minute
+)
+
+// 上游限速头没有给出重置时间时，按该时长后重置处理
+const apiDefaultResetWindow = time.Second
+
+// apiBudget 单个上游 API 的预算
+type apiBudget struct {
+	name         string
+	mu           sync.Mutex
+	bucket       *tokenBucket // 配置的令牌桶（未配置时为 nil，只受上游限速头约束）
+	remaining    int          // 上游报告的剩余次数，-1 表示未知
+	limit        int
+	resetAt      time.Time
+	blockedUntil time.Time // 429 后暂停到该时间
+	backoff      time.Duration
+	waiting      int
+	requests     int64
+	throttled    int64 // 收到 429 的次数
+	gaveUp       int64 // 排队超过 priceFetch.maxWaitSeconds 放弃的请求数
+}
+
+// APIBudgetStatus 上游 API 预算的当前状态（GET /ratelimits）
+type APIBudgetStatus struct {
+	API          string  `json:"api"`
+	RatePerSec   float64 `json:"ratePerSecond,omitempty"` // 配置的令牌桶速率
+	Remaining    *int    `json:"remaining,omitempty"`     // 上游报告的剩余次数（窗口未重置时）
+	Limit        int     `json:"limit,omitempty"`
+	ResetAt      string  `json:"resetAt,omitempty"`
+	BlockedUntil string  `json:"blockedUntil,omitempty"`
+	Waiting      int     `json:"waiting"`
+	Requests     int64   `json:"requests"`
+	Throttled    int64   `json:"throttled"`
+	GaveUp       int64   `json:"gaveUp"`
+}
+
+var (
+	apiBudgetMutex sync.Mutex
+	apiBudgets     = map[string]*apiBudget{}
+)
+
+// apiBudgetFor 上游 API 的预算（首次使用时按当前配置创建）
+func apiBudgetFor(name string) *apiBudget {
+	apiBudgetMutex.Lock()
+	defer apiBudgetMutex.Unlock()
+	b := apiBudgets[name]
+	if b == nil {
+		b = &apiBudget{name: name, remaining: -1}
+		if cfg, ok := currentConfig().PriceFetch.Limiters[name]; ok {
+			b.bucket = newTokenBucket(cfg)
+		}
+		apiBudgets[name] = b
+	}
+	return b
+}
+
+// 限速配置变化后按新配置重建令牌桶（上游报告的剩余次数与暂停保留）
+func resetAPIBudgets() {
+	apiBudgetMutex.Lock()
+	defer apiBudgetMutex.Unlock()
+	for name, b := range apiBudgets {
+		b.mu.Lock()
+		b.bucket = nil
+		if cfg, ok := currentConfig().PriceFetch.Limiters[name]; ok {
+			b.bucket = newTokenBucket(cfg)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// reserve 取一次请求的额度：granted 为 true 时等待 delay 后即可发送；为 false 时需在 delay 后重新检查（暂停或窗口用完）
+func (b *apiBudget) reserve(now time.Time) (delay time.Duration, granted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now), false
+	}
+	if b.remaining >= 0 && now.Before(b.resetAt) {
+		if b.remaining > 0 {
+			b.remaining--
+			b.requests++
+			return 0, true
+		}
+		return b.resetAt.Sub(now), false
+	}
+	b.remaining = -1
+	b.requests++
+	if b.bucket == nil {
+		return 0, true
+	}
+	return b.bucket.reserve(), true
+}
+
+// acquire 排队等待一次请求的额度；ctx 取消或需要等待超过 priceFetch.maxWaitSeconds 时返回 false
+func (b *apiBudget) acquire(ctx context.Context) bool {
+	maxWait := time.Duration(currentConfig().PriceFetch.MaxWaitSeconds) * time.Second
+	start := time.Now()
+	b.mu.Lock()
+	b.waiting++
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.waiting--
+		b.mu.Unlock()
+	}()
+	for {
+		delay, granted := b.reserve(time.Now())
+		if delay <= 0 {
+			return true
+		}
+		if maxWait > 0 && time.Since(start)+delay > maxWait {
+			b.mu.Lock()
+			b.gaveUp++
+			b.mu.Unlock()
+			logWarn("⏳ 上游 API 预算不足，放弃本次请求", "api", b.name, "wait", delay.Round(time.Millisecond))
+			return false
+		}
+		if !sleepCtx(ctx, delay) {
+			return false
+		}
+		if granted {
+			return true
+		}
+	}
+}
+
+// observe 按响应状态与限速头更新预算
+func (b *apiBudget) observe(status int, remaining *int, limit int, reset, retryAfter time.Duration) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining != nil {
+		b.remaining = max(*remaining, 0)
+		if reset <= 0 {
+			reset = apiDefaultResetWindow
+		}
+		b.resetAt = now.Add(reset)
+	}
+	if limit > 0 {
+		b.limit = limit
+	}
+	if status != http.StatusTooManyRequests {
+		if status > 0 && status < 400 {
+			b.backoff = 0
+		}
+		return
+	}
+	b.throttled++
+	metricAPIThrottled.Inc(b.name)
+	wait := retryAfter
+	if wait <= 0 {
+		b.backoff = min(max(b.backoff*2, apiBackoffMin), apiBackoffMax)
+		wait = b.backoff
+	}
+	if until := now.Add(wait); until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+	logWarn("🐢 上游 API 限流，暂停请求", "api", b.name, "wait", wait.Round(time.Millisecond))
+}
+
+// observeHeaders 解析 HTTP 响应的限速头
+func (b *apiBudget) observeHeaders(status int, h http.Header) {
+	var remaining *int
+	if v, err := strconv.Atoi(firstHeader(h, "X-RateLimit-Remaining", "RateLimit-Remaining")); err == nil {
+		remaining = &v
+	}
+	limit, _ := strconv.Atoi(firstHeader(h, "X-RateLimit-Limit", "RateLimit-Limit"))
+	reset := resetSeconds(firstHeader(h, "X-RateLimit-Reset", "RateLimit-Reset"))
+	b.observe(status, remaining, limit, reset, retryAfterDelay(h.Get("Retry-After")))
+}
+
+func firstHeader(h http.Header, names ...string) string {
+	for _, name := range names {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// resetSeconds 重置时间：秒数，或 Unix 时间戳（秒 / 毫秒）
+func resetSeconds(v string) time.Duration {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	switch {
+	case f > 1e12:
+		return time.Until(time.UnixMilli(int64(f)))
+	case f > 1e9:
+		return time.Until(time.Unix(int64(f), 0))
+	}
+	return time.Duration(f * float64(time.Second))
+}
+
+// retryAfterDelay Retry-After：秒数或 HTTP 日期
+func retryAfterDelay(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(f * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// noteScriptRateLimits 脚本输出的 ratelimit 事件计入对应 API 的预算（脚本直接请求的上游，如 fetchPrice.ts 的 OKX）
+func noteScriptRateLimits(out []byte) {
+	for _, ev := range decodeScriptOutput(out).eventsOf(scriptEventRateLimit) {
+		if ev.API == "" {
+			continue
+		}
+		var remaining *int
+		if ev.Remaining != nil {
+			v := int(*ev.Remaining)
+			remaining = &v
+		}
+		apiBudgetFor(ev.API).observe(ev.HTTPStatus, remaining, int(ev.Limit),
+			time.Duration(ev.ResetSeconds*float64(time.Second)), time.Duration(ev.RetryAfter*float64(time.Second)))
+	}
+}
+
+// apiGetJSON 通过共享预算发送 GET 请求并解码 JSON；429 时等待预算恢复后重试一次
+func apiGetJSON(ctx context.Context, api, rawURL string, headers map[string]string, out interface{}) error {
+	b := apiBudgetFor(api)
+	for attempt := 1; ; attempt++ {
+		if !b.acquire(ctx) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%s 请求预算不足", api)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := pricingHTTP.Do(req)
+		if err != nil {
+			return err
+		}
+		b.observeHeaders(resp.StatusCode, resp.Header)
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 2 {
+			resp.Body.Close()
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+// listAPIBudgets 各上游 API 的预算状态（GET /ratelimits）
+func listAPIBudgets() []APIBudgetStatus {
+	apiBudgetMutex.Lock()
+	budgets := make([]*apiBudget, 0, len(apiBudgets))
+	for _, b := range apiBudgets {
+		budgets = append(budgets, b)
+	}
+	apiBudgetMutex.Unlock()
+	now := time.Now()
+	result := make([]APIBudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		b.mu.Lock()
+		s := APIBudgetStatus{API: b.name, Limit: b.limit, Waiting: b.waiting, Requests: b.requests, Throttled: b.throttled, GaveUp: b.gaveUp}
+		if b.bucket != nil {
+			s.RatePerSec = b.bucket.rate
+		}
+		if b.remaining >= 0 && now.Before(b.resetAt) {
+			remaining := b.remaining
+			s.Remaining = &remaining
+			s.ResetAt = b.resetAt.Format(time.RFC3339)
+		}
+		if now.Before(b.blockedUntil) {
+			s.BlockedUntil = b.blockedUntil.Format(time.RFC3339)
+		}
+		b.mu.Unlock()
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].API < result[j].API })
+	return result
+}
+
+// apiBudgetSamples 各上游 API 排队等待的请求数
+func apiBudgetSamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, s := range listAPIBudgets() {
+		samples = append(samples, gaugeSample{LabelValues: []string{s.API}, Value: float64(s.Waiting)})
+	}
+	return samples
+}
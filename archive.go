@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveConfig 池归档：已平仓或失效的池文件移出数据目录（领取、价格获取等定时任务不再遍历），并清理过期的归档与按池 / 代币生成的文件
+type ArchiveConfig struct {
+	Enabled              bool `json:"enabled"`
+	ClosedMinutes        int  `json:"closedMinutes"`        // 处于 CLOSED 超过该时长的池文件移入归档
+	ExpiredHours         int  `json:"expiredHours"`         // 没有仓位、也不在开仓或平仓流程中的池文件超过该时长未修改时移入归档；0 表示不按此归档
+	RetentionDays        int  `json:"retentionDays"`        // 归档的池文件、不再使用的代币价格缓存与价格历史、日志超过该天数删除；0 表示不删除
+	CheckIntervalMinutes int  `json:"checkIntervalMinutes"` // 检查间隔
+}
+
+// 归档原因
+const (
+	archiveReasonClosed  = "closed"  // 平仓后超过 closedMinutes
+	archiveReasonExpired = "expired" // 信号未开仓或平仓残留，超过 expiredHours 未修改
+	archiveReasonManual  = "manual"  // API 触发
+)
+
+// ArchivedPool 一个已归档的池（data/state/archive.json: pool -> 最近一次归档）
+type ArchivedPool struct {
+	PoolAddress  string `json:"poolAddress"`
+	TokenAddress string `json:"ca,omitempty"`
+	State        string `json:"state,omitempty"` // 归档时的池状态
+	Reason       string `json:"reason"`
+	ArchivedAt   string `json:"archivedAt"`
+	File         string `json:"file"` // 相对数据目录的归档文件
+}
+
+// ArchiveResult 一次归档与清理的结果
+type ArchiveResult struct {
+	At       string   `json:"at"`
+	Archived []string `json:"archived"`
+	Pruned   int      `json:"pruned"` // 删除的文件数
+	Errors   []string `json:"errors,omitempty"`
+}
+
+var archiveMutex sync.Mutex
+
+// 归档目录（与 removeLiquidity.ts 平仓后迁移池文件的目录相同）
+func archiveDir() string { return filepath.Join(poolDataDir(), "history") }
+
+func (c ArchiveConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ClosedMinutes < 0 || c.ExpiredHours < 0 || c.RetentionDays < 0 {
+		return fmt.Errorf("archive.closedMinutes、expiredHours、retentionDays 不能为负数")
+	}
+	if c.CheckIntervalMinutes <= 0 {
+		return fmt.Errorf("archive.checkIntervalMinutes 必须大于0")
+	}
+	return nil
+}
+
+func loadArchivedPools() map[string]*ArchivedPool {
+	records := map[string]*ArchivedPool{}
+	if err := loadStateFile("archive", &records); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return records
+}
+
+// listArchivedPools 已归档的池（按归档时间倒序，GET /archive）
+func listArchivedPools() []*ArchivedPool {
+	archiveMutex.Lock()
+	records := loadArchivedPools()
+	archiveMutex.Unlock()
+	result := make([]*ArchivedPool, 0, len(records))
+	for _, r := range records {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ArchivedAt > result[j].ArchivedAt })
+	return result
+}
+
+// poolHasPosition 池是否仍持有或正在处理仓位（这样的池文件不归档）
+func poolHasPosition(poolAddress string) bool {
+	if state, ok := poolStateOf(poolAddress); ok {
+		switch state {
+		case poolAdding, poolActive, poolClaiming, poolExiting, poolFailed:
+			return true
+		}
+	}
+	if readPositionFromPoolJSON(poolAddress) != "" || (isPaperPool(poolAddress) && paperHasOpenPosition(poolAddress)) {
+		return true
+	}
+	if openPositionGroup(poolAddress) != nil || claimInFlight(poolAddress) {
+		return true
+	}
+	if _, closing := closingPools.Load(poolAddress); closing {
+		return true
+	}
+	for _, r := range listPositionRecords() {
+		if r.PoolAddress == poolAddress && r.State != positionStateClosed {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveReason 池文件是否满足归档条件，返回原因（不满足时为空）
+func archiveReason(poolAddress string, modTime, now time.Time) string {
+	cfg := currentConfig().Archive
+	if poolHasPosition(poolAddress) {
+		return ""
+	}
+	poolStateMutex.Lock()
+	r := loadPoolStates()[poolAddress]
+	poolStateMutex.Unlock()
+	if r != nil && r.State == poolClosed {
+		if since, err := time.Parse(time.RFC3339, r.Since); err == nil && now.Sub(since) >= time.Duration(cfg.ClosedMinutes)*time.Minute {
+			return archiveReasonClosed
+		}
+		return ""
+	}
+	if cfg.ExpiredHours > 0 && now.Sub(modTime) >= time.Duration(cfg.ExpiredHours)*time.Hour {
+		return archiveReasonExpired
+	}
+	return ""
+}
+
+// archivePool 持有池文件锁把池文件移入归档目录（命名与 removeLiquidity.ts 相同），未平仓记录的池转为 CLOSED
+func archivePool(poolAddress, reason string) error {
+	src := filepath.Join(poolDataDir(), poolAddress+".json")
+	ca := readTokenContractAddressFromPoolJSON(poolAddress)
+	if err := os.MkdirAll(archiveDir(), 0755); err != nil {
+		return err
+	}
+	stamp := strings.NewReplacer(":", "-", ".", "-").Replace(time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	dst := filepath.Join(archiveDir(), poolAddress+"_"+stamp+".json")
+	unlock, err := lockDataFile(src)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(src, dst)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	state, _ := poolStateOf(poolAddress)
+	if state != poolClosed {
+		transitionPool(poolAddress, poolClosed, "已归档")
+	}
+	rel, _ := filepath.Rel(poolDataDir(), dst)
+	archiveMutex.Lock()
+	records := loadArchivedPools()
+	records[poolAddress] = &ArchivedPool{PoolAddress: poolAddress, TokenAddress: ca, State: state, Reason: reason,
+		ArchivedAt: time.Now().Format(time.RFC3339), File: filepath.ToSlash(rel)}
+	if err := saveStateFile("archive", records); err != nil {
+		logOutput("❌ 保存归档记录失败: %v\n", err)
+	}
+	archiveMutex.Unlock()
+	logInfo("📦 池文件已归档", "pool", poolAddress, "reason", reason, "file", rel)
+	return nil
+}
+
+// archiveManually 立即归档一个没有仓位的池（POST /archive/<pool>）
+func archiveManually(poolAddress string) error {
+	if !poolExists(poolAddress) {
+		return fmt.Errorf("池文件不存在: %s", poolAddress)
+	}
+	if poolHasPosition(poolAddress) {
+		return fmt.Errorf("池仍持有或正在处理仓位，不能归档: %s", poolAddress)
+	}
+	return archivePool(poolAddress, archiveReasonManual)
+}
+
+// runArchive 归档满足条件的池文件，并清理超过 retentionDays 的文件
+func runArchive() ArchiveResult {
+	now := time.Now()
+	result := ArchiveResult{At: now.Format(time.RFC3339), Archived: []string{}}
+	if isPaused() || shuttingDown() || dataVolumeUnavailable() {
+		return result
+	}
+	entries, err := os.ReadDir(poolDataDir())
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+	for _, e := range entries {
+		path := filepath.Join(poolDataDir(), e.Name())
+		if e.IsDir() || !isPoolFile(poolDataDir(), path) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		pool := strings.TrimSuffix(e.Name(), ".json")
+		reason := archiveReason(pool, info.ModTime(), now)
+		if reason == "" {
+			continue
+		}
+		if err := archivePool(pool, reason); err != nil {
+			logWarn("⚠️ 归档池文件失败", "pool", pool, "error", err)
+			result.Errors = append(result.Errors, pool+": "+err.Error())
+			continue
+		}
+		result.Archived = append(result.Archived, pool)
+	}
+	if days := currentConfig().Archive.RetentionDays; days > 0 {
+		result.Pruned = pruneDataFiles(now.AddDate(0, 0, -days))
+	}
+	if len(result.Archived) > 0 || result.Pruned > 0 {
+		logOutput("📦 归档 %d 个池文件，清理 %d 个过期文件\n", len(result.Archived), result.Pruned)
+	}
+	return result
+}
+
+// pruneDataFiles 删除 cutoff 之前修改的归档池文件、当前池不再使用的代币价格缓存与价格历史、日志文件，返回删除数
+func pruneDataFiles(cutoff time.Time) int {
+	inUse := map[string]bool{}
+	for _, ca := range getAllTokenContractAddresses() {
+		inUse[ca] = true
+	}
+	pruned := 0
+	prune := func(dir string, keep func(name string) bool) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() || keep(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, e.Name())); err == nil {
+				pruned++
+			}
+		}
+	}
+	prune(archiveDir(), func(name string) bool { return !strings.HasSuffix(name, ".json") })
+	// fetchPrice.ts 的价格缓存 <ca>.json 与价格历史 <ca>.jsonl
+	prune(filepath.Join(appDataDir, "prices"), func(name string) bool {
+		return !strings.HasSuffix(name, ".json") || inUse[strings.TrimSuffix(name, ".json")]
+	})
+	prune(priceHistoryDir(), func(name string) bool {
+		return !strings.HasSuffix(name, ".jsonl") || inUse[strings.TrimSuffix(name, ".jsonl")]
+	})
+	if dir := currentConfig().Logging.Dir; dir != "" {
+		prune(dir, func(name string) bool { return strings.HasPrefix(name, ".") })
+	}
+
+	// 归档记录对应的文件已删除时一并移除
+	archiveMutex.Lock()
+	records := loadArchivedPools()
+	changed := false
+	for pool, r := range records {
+		if _, err := os.Stat(filepath.Join(poolDataDir(), filepath.FromSlash(r.File))); os.IsNotExist(err) {
+			delete(records, pool)
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveStateFile("archive", records); err != nil {
+			logOutput("❌ 保存归档记录失败: %v\n", err)
+		}
+	}
+	archiveMutex.Unlock()
+	return pruned
+}
+
+// startPoolArchiver 定期归档与清理（条件与保留天数可热更新，enabled 与间隔需重启）
+func startPoolArchiver() {
+	cfg := currentConfig().Archive
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.CheckIntervalMinutes) * time.Minute
+	logOutput("📦 启动池归档（每%v，CLOSED 超过 %d 分钟归档，保留 %d 天）\n", interval, cfg.ClosedMinutes, cfg.RetentionDays)
+	runArchive()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止池归档\n")
+			return
+		case <-ticker.C:
+			runArchive()
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditConfig 审计日志：程序的决策（如跳过原因）按天追加到 <dir>/audit_YYYY-MM-DD.jsonl，便于事后统计与追溯
+type AuditConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Dir           string `json:"dir"`
+	RetentionDays int    `json:"retentionDays"` // 保留天数，0 表示不清理
+}
+
+// AuditEntry 审计日志中的一条记录
+type AuditEntry struct {
+	Time        string            `json:"time"`
+	Kind        string            `json:"kind"`             // 记录类型，如 skip
+	Stage       string            `json:"stage,omitempty"`  // 所属环节：entry / claim / price / sweep
+	Reason      string            `json:"reason,omitempty"` // 分类后的原因
+	Pool        string            `json:"pool,omitempty"`
+	Token       string            `json:"ca,omitempty"`
+	Wallet      string            `json:"wallet,omitempty"`
+	Detail      string            `json:"detail,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"` // 事件总线记录的附加字段
+	Instance    string            `json:"instance,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+}
+
+var (
+	auditMutex  sync.Mutex
+	auditPruned string // 最近一次清理旧文件的日期
+)
+
+func (c AuditConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Dir == "" {
+		return fmt.Errorf("audit.dir 不能为空")
+	}
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("audit.retentionDays 不能为负数")
+	}
+	return nil
+}
+
+// 审计目录：dry-run 与演示模式写入各自的目录，不混入实盘记录
+func auditDir() string {
+	if isDryRun() {
+		return filepath.Join(filepath.Dir(dryRunStateDir()), "audit")
+	}
+	if isDemo() {
+		return filepath.Join(filepath.Dir(demoStateDir()), "audit")
+	}
+	return currentConfig().Audit.Dir
+}
+
+func auditFilePath(day string) string {
+	return filepath.Join(auditDir(), "audit_"+day+".jsonl")
+}
+
+// appendAudit 追加一条审计记录（时间与部署标签为空时自动填充）
+func appendAudit(e AuditEntry) {
+	if !currentConfig().Audit.Enabled {
+		return
+	}
+	now := appNow()
+	if e.Time == "" {
+		e.Time = now.Format(time.RFC3339)
+	}
+	e.Instance, e.Environment = deployInstance, deployEnvironment
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	day := now.Format("2006-01-02")
+	if err := os.MkdirAll(auditDir(), 0755); err != nil {
+		logOutput("❌ 创建审计目录失败: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(auditFilePath(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logOutput("❌ 写入审计日志失败: %v\n", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+	if auditPruned != day {
+		auditPruned = day
+		pruneAuditFiles(now)
+	}
+}
+
+// 清理超过保留天数的审计文件（文件名含日期，按名称判断）
+func pruneAuditFiles(now time.Time) {
+	days := currentConfig().Audit.RetentionDays
+	if days <= 0 {
+		return
+	}
+	cutoff := "audit_" + now.AddDate(0, 0, -days).Format("2006-01-02") + ".jsonl"
+	matches, _ := filepath.Glob(filepath.Join(auditDir(), "audit_*.jsonl"))
+	for _, path := range matches {
+		if filepath.Base(path) < cutoff {
+			os.Remove(path)
+		}
+	}
+}
+
+// readAudit 读取最近 days 天（含今天）中 kind 类型的审计记录，按时间顺序
+func readAudit(kind string, days int) []AuditEntry {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	now := appNow()
+	var paths []string
+	for i := days - 1; i >= 0; i-- {
+		paths = append(paths, auditFilePath(now.AddDate(0, 0, -i).Format("2006-01-02")))
+	}
+	entries := []AuditEntry{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			var e AuditEntry
+			if line == "" || json.Unmarshal([]byte(line), &e) != nil || (kind != "" && e.Kind != kind) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		f.Close()
+	}
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Time < entries[b].Time })
+	return entries
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 运行时负载状态（供上游 CSV 生产者判断是否暂停输出）
+var (
+	inFlightTasks atomic.Int64 // 排队与正在处理的新池任务数
+	queueCapacity atomic.Int64 // 新池任务并发上限
+	pausedFlag    atomic.Bool  // 人工暂停
+	lowSOLFlag    atomic.Bool  // SOL 余额不足
+	lastSaturated = -1         // 上次写入的饱和状态（-1 未知，0 空闲，1 饱和）
+	statusMutex   sync.Mutex
+)
+
+// BackpressureStatus 写入状态文件的内容
+type BackpressureStatus struct {
+	Saturated  bool     `json:"saturated"`
+	Reasons    []string `json:"reasons"`
+	QueueDepth int64    `json:"queueDepth"`
+	QueueLimit int64    `json:"queueLimit"`
+	Paused     bool     `json:"paused"`
+	LowSOL     bool     `json:"lowSOL"`
+	Frozen     bool     `json:"frozen"`
+	KillSwitch bool     `json:"killSwitch"`
+	UpdatedAt  string   `json:"updatedAt"`
+
+	Instance    string `json:"instance,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// 新池任务并发上限可在运行时调整（配置热更新、RPC 限流降级），调小后新任务等待在途任务结束
+func setTaskCapacity(n int) {
+	queueCapacity.Store(int64(n))
+	kickJobQueue()
+}
+
+func setPaused(paused bool) { pausedFlag.Store(paused) }
+func isPaused() bool        { return pausedFlag.Load() }
+func setLowSOL(low bool)    { lowSOLFlag.Store(low) }
+
+// 计算当前饱和状态
+func currentBackpressure() BackpressureStatus {
+	depth := inFlightTasks.Load()
+	limit := queueCapacity.Load()
+	highWater := int64(currentConfig().Backpressure.QueueHighWater)
+	if highWater <= 0 {
+		highWater = limit
+	}
+
+	status := BackpressureStatus{
+		Reasons:    []string{},
+		QueueDepth: depth,
+		QueueLimit: limit,
+		Paused:     pausedFlag.Load(),
+		LowSOL:     lowSOLFlag.Load(),
+		Frozen:     isFrozen(),
+		KillSwitch: killSwitchActive(),
+		UpdatedAt:  time.Now().Format(time.RFC3339),
+
+		Instance:    deployInstance,
+		Environment: deployEnvironment,
+	}
+	if highWater > 0 && depth >= highWater {
+		status.Reasons = append(status.Reasons, "queue_full")
+	}
+	if status.Paused {
+		status.Reasons = append(status.Reasons, "paused")
+	}
+	if status.LowSOL {
+		status.Reasons = append(status.Reasons, "low_sol")
+	}
+	if status.Frozen {
+		status.Reasons = append(status.Reasons, "frozen")
+	}
+	if status.KillSwitch {
+		status.Reasons = append(status.Reasons, "kill_switch")
+	}
+	status.Saturated = len(status.Reasons) > 0
+	return status
+}
+
+// 写入状态文件（先写临时文件再重命名，避免上游读到半截内容）
+func writeBackpressureStatus() {
+	status := currentBackpressure()
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return
+	}
+
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	path := currentConfig().Backpressure.StatusFile
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logOutput("❌ 创建状态目录失败: %v\n", err)
+		return
+	}
+	if err := writeFileAtomic(path, content, 0644); err != nil {
+		logOutput("❌ 写入背压状态文件失败: %v\n", err)
+		return
+	}
+
+	// 仅在饱和状态变化时输出日志
+	saturated := 0
+	if status.Saturated {
+		saturated = 1
+	}
+	if saturated != lastSaturated {
+		lastSaturated = saturated
+		if status.Saturated {
+			logOutput("🚦 机器人已饱和，通知上游暂停输出: %v\n", status.Reasons)
+		} else {
+			logOutput("🟢 机器人空闲，上游可继续输出\n")
+		}
+	}
+}
+
+// 启动背压状态文件刷新任务
+func startBackpressureReporter() {
+	if !currentConfig().Backpressure.Enabled {
+		return
+	}
+	interval := time.Duration(currentConfig().Backpressure.IntervalSeconds) * time.Second
+	logOutput("🕐 启动背压状态上报（每%v写入 %s）\n", interval, currentConfig().Backpressure.StatusFile)
+
+	writeBackpressureStatus()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止背压状态上报\n")
+			return
+		case <-ticker.C:
+			writeBackpressureStatus()
+		}
+	}
+}
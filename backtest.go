@@ -0,0 +1,480 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BacktestConfig 回测的仓位模型参数（-backtest）。价格历史只有代币价格，仓位按单边 SOL、
+// 范围 [入场价 ×(1-rangePct%), 入场价] 内均匀分布估值，SOL 本身的价格视为不变
+type BacktestConfig struct {
+	SolAmount         float64 `json:"solAmount"`         // 每次入场投入 SOL
+	RangePct          float64 `json:"rangePct"`          // 范围下沿相对入场价的跌幅（%），默认 60（同 addLiquidity.ts）
+	FeePercentPerHour float64 `json:"feePercentPerHour"` // 价格在范围内时每小时手续费收入占投入的比例（%）
+	TxCostSOL         float64 `json:"txCostSol"`         // 每次开仓 / 平仓的交易成本（SOL）
+}
+
+// 回测结束时仍未平仓的仓位按最后价格结算
+const exitReasonBacktestEnd = "end"
+
+// BacktestPosition 一笔模拟仓位
+type BacktestPosition struct {
+	Token        string  `json:"ca"`
+	PoolAddress  string  `json:"poolAddress,omitempty"`
+	EntryAt      string  `json:"entryAt"`
+	ExitAt       string  `json:"exitAt"`
+	EntryPrice   float64 `json:"entryPrice"`
+	LowerPrice   float64 `json:"lowerPrice"`
+	ExitPrice    float64 `json:"exitPrice"`
+	ExitReason   string  `json:"exitReason"`
+	Detail       string  `json:"detail,omitempty"`
+	SolAmount    float64 `json:"solAmount"`
+	HoursInRange float64 `json:"hoursInRange"`
+	FeesSOL      float64 `json:"feesSol"`
+	TxCostSOL    float64 `json:"txCostSol"`
+	ValueSOL     float64 `json:"valueSol"` // 平仓价值（含手续费，已扣交易成本）
+	PnLSOL       float64 `json:"pnlSol"`
+	PnLPercent   float64 `json:"pnlPercent"`
+}
+
+// BacktestSummary 回测汇总
+type BacktestSummary struct {
+	Series      int            `json:"series"`
+	Samples     int            `json:"samples"`
+	Entries     int            `json:"entries"`
+	Exits       map[string]int `json:"exits"` // 平仓原因 -> 次数
+	Wins        int            `json:"wins"`
+	WinRate     float64        `json:"winRate"`
+	InvestedSOL float64        `json:"investedSol"` // 各代币首次入场投入之和（再平衡沿用平仓价值，不重复计入）
+	FeesSOL     float64        `json:"feesSol"`
+	TxCostSOL   float64        `json:"txCostSol"`
+	PnLSOL      float64        `json:"pnlSol"`
+	WorstPnL    float64        `json:"worstPnlPercent"`
+	BestPnL     float64        `json:"bestPnlPercent"`
+}
+
+// BacktestReport 回测报告
+type BacktestReport struct {
+	GeneratedAt string             `json:"generatedAt"`
+	Sources     []string           `json:"sources"`
+	Model       BacktestConfig     `json:"model"`
+	Risk        RiskConfig         `json:"risk"`
+	Lifecycle   LifecycleConfig    `json:"lifecycle"`
+	Rebalance   RebalanceConfig    `json:"rebalance"`
+	Summary     BacktestSummary    `json:"summary"`
+	Positions   []BacktestPosition `json:"positions"`
+}
+
+// 一个代币的价格序列
+type backtestSeries struct {
+	token   string
+	pool    string
+	samples []backtestTick
+}
+
+type backtestTick struct {
+	at    time.Time
+	price float64
+}
+
+func (c BacktestConfig) validate() error {
+	if c.SolAmount <= 0 {
+		return fmt.Errorf("backtest.solAmount 必须大于0")
+	}
+	if c.RangePct <= 0 || c.RangePct >= 100 {
+		return fmt.Errorf("backtest.rangePct 取值范围为 0 到 100")
+	}
+	if c.FeePercentPerHour < 0 || c.TxCostSOL < 0 {
+		return fmt.Errorf("backtest.feePercentPerHour、txCostSol 不能为负数")
+	}
+	return nil
+}
+
+// loadBacktestSeries 读取价格序列：目录下的 .jsonl / .csv，或单个文件；未指定时读取价格历史（原始采样已清理的时段用 K 线补齐）。
+// CSV 需含 time 与 price 列，可选 ca（或 token）与 pool（或 poolAddress）列；缺少 ca 列时以文件名为代币
+func loadBacktestSeries(path string, since time.Time) ([]*backtestSeries, []string, error) {
+	if path == "" {
+		return loadStoredBacktestSeries(since), []string{priceHistoryDir(), ohlcDir()}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".jsonl" || ext == ".csv") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	byToken := map[string]*backtestSeries{}
+	add := func(token, pool string, at time.Time, price float64) {
+		if token == "" || price <= 0 || at.Before(since) {
+			return
+		}
+		s := byToken[token]
+		if s == nil {
+			s = &backtestSeries{token: token}
+			byToken[token] = s
+		}
+		if s.pool == "" {
+			s.pool = pool
+		}
+		s.samples = append(s.samples, backtestTick{at: at, price: price})
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		var err error
+		if filepath.Ext(file) == ".csv" {
+			err = readBacktestCSV(file, name, add)
+		} else {
+			err = readBacktestJSONL(file, name, add)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取 %s 失败: %v", file, err)
+		}
+	}
+	return sortBacktestSeries(byToken), files, nil
+}
+
+func loadStoredBacktestSeries(since time.Time) []*backtestSeries {
+	byToken := map[string]*backtestSeries{}
+	for _, token := range storedTokens() {
+		s := &backtestSeries{token: token}
+		for _, p := range storedPrices(token, since) {
+			if s.pool == "" {
+				s.pool = p.Pool
+			}
+			s.samples = append(s.samples, backtestTick{at: p.At, price: p.Price})
+		}
+		byToken[token] = s
+	}
+	return sortBacktestSeries(byToken)
+}
+
+// 按时间排序样本，丢弃少于两个样本的序列，序列按首个样本时间排序
+func sortBacktestSeries(byToken map[string]*backtestSeries) []*backtestSeries {
+	series := make([]*backtestSeries, 0, len(byToken))
+	for _, s := range byToken {
+		sort.SliceStable(s.samples, func(i, j int) bool { return s.samples[i].at.Before(s.samples[j].at) })
+		if len(s.samples) >= 2 {
+			series = append(series, s)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].samples[0].at.Before(series[j].samples[0].at) })
+	return series
+}
+
+func readBacktestJSONL(file, defaultToken string, add func(token, pool string, at time.Time, price float64)) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		var s PriceSample
+		if line == "" || json.Unmarshal([]byte(line), &s) != nil {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, s.Time)
+		price, perr := strconv.ParseFloat(strings.TrimSpace(s.Price), 64)
+		if err != nil || perr != nil {
+			continue
+		}
+		token := s.Token
+		if token == "" {
+			token = defaultToken
+		}
+		add(token, s.PoolAddress, at, price)
+	}
+	return nil
+}
+
+func readBacktestCSV(file, defaultToken string, add func(token, pool string, at time.Time, price float64)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	col := func(names ...string) int {
+		for i, h := range header {
+			for _, n := range names {
+				if strings.EqualFold(strings.TrimSpace(h), n) {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	timeCol, priceCol := col("time", "timestamp"), col("price", "close", "c")
+	tokenCol, poolCol := col("ca", "token"), col("pool", "poolAddress")
+	if timeCol < 0 || priceCol < 0 {
+		return fmt.Errorf("缺少 time 或 price 列")
+	}
+	field := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		at, err := parseLastUpdatedFirstToTime(field(row, timeCol))
+		if err != nil {
+			continue
+		}
+		price, err := strconv.ParseFloat(field(row, priceCol), 64)
+		if err != nil {
+			continue
+		}
+		token := field(row, tokenCol)
+		if token == "" {
+			token = defaultToken
+		}
+		add(token, field(row, poolCol), at, price)
+	}
+}
+
+// 单边 SOL 仓位在价格 p 时的价值（SOL）：范围 [lower, upper] 内按价格均匀投入，价格下跌穿过的部分换成代币
+func backtestPositionValue(sol, upper, lower, p float64) float64 {
+	if p >= upper || upper <= lower {
+		return sol
+	}
+	q := math.Max(p, lower)
+	remaining := sol * (q - lower) / (upper - lower)
+	tokens := sol / (upper - lower) * math.Log(upper/q)
+	return remaining + tokens*p
+}
+
+// 模拟中的仓位
+type backtestOpen struct {
+	BacktestPosition
+	openedAt      time.Time
+	signalAt      time.Time
+	outOfRangeAt  time.Time
+	lastRebalance time.Time
+	prevAt        time.Time
+	prevPrice     float64
+}
+
+func newBacktestOpen(s *backtestSeries, sol float64, tick backtestTick, signalAt time.Time, rangePct float64) *backtestOpen {
+	return &backtestOpen{
+		BacktestPosition: BacktestPosition{
+			Token:       s.token,
+			PoolAddress: s.pool,
+			EntryAt:     tick.at.Format(time.RFC3339),
+			EntryPrice:  tick.price,
+			LowerPrice:  tick.price * (1 - rangePct/100),
+			SolAmount:   sol,
+		},
+		openedAt:  tick.at,
+		signalAt:  signalAt,
+		prevAt:    tick.at,
+		prevPrice: tick.price,
+	}
+}
+
+// 价格所在的范围外方向（范围内返回空）
+func (o *backtestOpen) outside(price float64) string {
+	switch {
+	case price > o.EntryPrice:
+		return "above"
+	case price < o.LowerPrice:
+		return "below"
+	}
+	return ""
+}
+
+// 按配置的规则检查一次价格：返回平仓原因与说明（空表示继续持有）
+func (o *backtestOpen) evaluate(tick backtestTick, model BacktestConfig) (string, string) {
+	if side := o.outside(o.prevPrice); side == "" {
+		hours := tick.at.Sub(o.prevAt).Hours()
+		o.HoursInRange += hours
+		o.FeesSOL += o.SolAmount * model.FeePercentPerHour / 100 * hours
+	}
+	o.prevAt, o.prevPrice = tick.at, tick.price
+	o.ValueSOL = backtestPositionValue(o.SolAmount, o.EntryPrice, o.LowerPrice, tick.price) + o.FeesSOL
+	o.PnLPercent = (o.ValueSOL - o.SolAmount) / o.SolAmount * 100
+
+	side := o.outside(tick.price)
+	if side == "" {
+		o.outOfRangeAt = time.Time{}
+	} else if o.outOfRangeAt.IsZero() {
+		o.outOfRangeAt = tick.at
+	}
+	outFor := tick.at.Sub(o.outOfRangeAt)
+
+	if detail := checkStopLoss(o.PoolAddress, o.EntryPrice, tick.price); detail != "" {
+		return exitReasonStopLoss, detail
+	}
+	record := &PositionRecord{SolAmount: o.SolAmount, ValueSOL: o.ValueSOL, PnLPercent: o.PnLPercent}
+	if detail := checkTakeProfit(o.PoolAddress, record, o.EntryPrice, tick.price); detail != "" {
+		return exitReasonTakeProfit, detail
+	}
+	// 信号时间起强制平仓（与 checkAndExecuteSignalTimeout 一致）
+	if timeout := signalTimeout(); timeout > 0 && tick.at.Sub(o.signalAt) >= timeout {
+		return exitReasonMaxAge, fmt.Sprintf("信号时间起超过 %d 分钟", currentConfig().Lifecycle.SignalTimeoutMinutes)
+	}
+	if lc := currentConfig().Lifecycle; lc.Enabled {
+		if lc.MaxAgeMinutes > 0 && tick.at.Sub(o.openedAt) >= time.Duration(lc.MaxAgeMinutes)*time.Minute {
+			return exitReasonMaxAge, fmt.Sprintf("开仓超过 %d 分钟", lc.MaxAgeMinutes)
+		}
+		if lc.PnLTargetPercent > 0 && o.PnLPercent >= lc.PnLTargetPercent {
+			return exitReasonPnLTarget, fmt.Sprintf("收益 %.2f%% ≥ %g%%", o.PnLPercent, lc.PnLTargetPercent)
+		}
+		if side != "" && lc.OutOfRangeMinutes > 0 && (lc.OutOfRangeSide == "both" || lc.OutOfRangeSide == side) &&
+			outFor >= time.Duration(lc.OutOfRangeMinutes)*time.Minute {
+			return exitReasonOutOfRange, fmt.Sprintf("价格在范围%s %v", side, outFor)
+		}
+	}
+	if rb := currentConfig().Rebalance; rb.Enabled && side != "" && (rb.Side == "both" || rb.Side == side) &&
+		outFor >= time.Duration(rb.MinOutOfRangeMinutes*float64(time.Minute)) &&
+		(o.lastRebalance.IsZero() || tick.at.Sub(o.lastRebalance) >= time.Duration(rb.CooldownMinutes*float64(time.Minute))) {
+		return exitReasonRebalance, fmt.Sprintf("价格在范围%s %v", side, outFor)
+	}
+	return "", ""
+}
+
+// 平仓结算（两次交易成本：开仓与平仓）
+func (o *backtestOpen) close(tick backtestTick, reason, detail string, model BacktestConfig) BacktestPosition {
+	p := o.BacktestPosition
+	p.ExitAt = tick.at.Format(time.RFC3339)
+	p.ExitPrice = tick.price
+	p.ExitReason = reason
+	p.Detail = detail
+	p.TxCostSOL = 2 * model.TxCostSOL
+	p.ValueSOL = o.ValueSOL - p.TxCostSOL
+	p.PnLSOL = p.ValueSOL - p.SolAmount
+	p.PnLPercent = p.PnLSOL / p.SolAmount * 100
+	return p
+}
+
+// simulateBacktestSeries 首个采样作为信号入场，逐个采样按止损、止盈、生命周期与再平衡规则检查；
+// 再平衡以平仓价值在当前价重新开仓（范围宽度取 rebalance.rangePct，未设置时同入场），其余原因平仓后该代币不再入场
+func simulateBacktestSeries(s *backtestSeries, model BacktestConfig) []BacktestPosition {
+	var positions []BacktestPosition
+	signalAt := s.samples[0].at
+	open := newBacktestOpen(s, model.SolAmount, s.samples[0], signalAt, model.RangePct)
+	for i, tick := range s.samples[1:] {
+		reason, detail := open.evaluate(tick, model)
+		if reason == "" && i == len(s.samples)-2 {
+			reason, detail = exitReasonBacktestEnd, "价格序列结束"
+		}
+		if reason == "" {
+			continue
+		}
+		closed := open.close(tick, reason, detail, model)
+		positions = append(positions, closed)
+		if reason != exitReasonRebalance || closed.ValueSOL <= 0 {
+			break
+		}
+		rangePct := model.RangePct
+		if currentConfig().Rebalance.RangePct > 0 {
+			rangePct = currentConfig().Rebalance.RangePct
+		}
+		next := newBacktestOpen(s, closed.ValueSOL, tick, signalAt, rangePct)
+		next.lastRebalance = tick.at
+		open = next
+	}
+	return positions
+}
+
+// runBacktest 回放价格序列生成报告（不执行任何脚本、不写入状态）
+func runBacktest(path string, since time.Time) (*BacktestReport, error) {
+	model := currentConfig().Backtest
+	series, sources, err := loadBacktestSeries(path, since)
+	if err != nil {
+		return nil, err
+	}
+	report := &BacktestReport{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Sources:     sources,
+		Model:       model,
+		Risk:        currentConfig().Risk,
+		Lifecycle:   currentConfig().Lifecycle,
+		Rebalance:   currentConfig().Rebalance,
+		Summary:     BacktestSummary{Series: len(series), Exits: map[string]int{}},
+		Positions:   []BacktestPosition{},
+	}
+	sum := &report.Summary
+	for _, s := range series {
+		sum.Samples += len(s.samples)
+		sum.InvestedSOL += model.SolAmount
+		positions := simulateBacktestSeries(s, model)
+		report.Positions = append(report.Positions, positions...)
+		for _, p := range positions {
+			sum.Entries++
+			sum.Exits[p.ExitReason]++
+			sum.FeesSOL += p.FeesSOL
+			sum.TxCostSOL += p.TxCostSOL
+			sum.PnLSOL += p.PnLSOL
+			if p.PnLSOL > 0 {
+				sum.Wins++
+			}
+			if sum.Entries == 1 || p.PnLPercent < sum.WorstPnL {
+				sum.WorstPnL = p.PnLPercent
+			}
+			if sum.Entries == 1 || p.PnLPercent > sum.BestPnL {
+				sum.BestPnL = p.PnLPercent
+			}
+		}
+	}
+	if sum.Entries > 0 {
+		sum.WinRate = float64(sum.Wins) / float64(sum.Entries) * 100
+	}
+	return report, nil
+}
+
+// printBacktestReport 输出汇总与各平仓原因的次数
+func printBacktestReport(r *BacktestReport) {
+	s := r.Summary
+	fmt.Printf("📊 回测：%d 个代币、%d 个价格采样（%d 个文件）\n", s.Series, s.Samples, len(r.Sources))
+	fmt.Printf("   入场 %d 次，盈利 %d 次（胜率 %.1f%%），单笔收益 %.2f%% ~ %.2f%%\n", s.Entries, s.Wins, s.WinRate, s.WorstPnL, s.BestPnL)
+	fmt.Printf("   投入 %.4f SOL，手续费 %.4f SOL，交易成本 %.4f SOL，盈亏 %+.4f SOL\n", s.InvestedSOL, s.FeesSOL, s.TxCostSOL, s.PnLSOL)
+	reasons := make([]string, 0, len(s.Exits))
+	for reason := range s.Exits {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("   平仓 %-14s %d\n", reason, s.Exits[reason])
+	}
+}
+
+// writeBacktestReport 写入 JSON 报告
+func writeBacktestReport(path string, r *BacktestReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
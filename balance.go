@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BalanceMonitorConfig 定期查询钱包 SOL 与代币余额，SOL 低于阈值时告警（复用 walletWatch 的 RPC 与地址）
+type BalanceMonitorConfig struct {
+	Enabled         bool    `json:"enabled"`
+	IntervalSeconds int     `json:"intervalSeconds"` // 查询间隔
+	MinSOL          float64 `json:"minSol"`          // SOL 低于该值时告警（交易手续费与开仓租金不足）
+	Tokens          bool    `json:"tokens"`          // 同时查询 SPL 代币余额
+}
+
+// TokenBalance 钱包中的一种代币
+type TokenBalance struct {
+	Mint     string  `json:"mint"`
+	Amount   string  `json:"amount"` // 最小单位
+	Decimals int     `json:"decimals"`
+	UIAmount float64 `json:"uiAmount"`
+}
+
+// WalletBalance 最近一次余额查询结果
+type WalletBalance struct {
+	CheckedAt string         `json:"checkedAt,omitempty"`
+	Address   string         `json:"address,omitempty"`
+	SOL       float64        `json:"sol"`
+	Tokens    []TokenBalance `json:"tokens,omitempty"`
+	Low       bool           `json:"low"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// SPL Token 与 Token-2022 程序
+var tokenProgramIDs = []string{
+	"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA",
+	"TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb",
+}
+
+var (
+	walletBalanceMutex sync.Mutex
+	walletBalance      WalletBalance
+)
+
+func (c BalanceMonitorConfig) validate(walletWatch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if walletWatch.RPCURL == "" {
+		return fmt.Errorf("balanceMonitor 依赖 walletWatch.rpcUrl")
+	}
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("balanceMonitor.intervalSeconds 必须大于0")
+	}
+	if c.MinSOL < 0 {
+		return fmt.Errorf("balanceMonitor.minSol 不能为负数")
+	}
+	return nil
+}
+
+// 查询 SOL 余额
+func getSOLBalance(ctx context.Context, address string) (float64, error) {
+	var result struct {
+		Value int64 `json:"value"`
+	}
+	if err := solanaRPC(ctx, "getBalance", []interface{}{address, map[string]string{"commitment": "confirmed"}}, &result); err != nil {
+		return 0, err
+	}
+	return float64(result.Value) / 1e9, nil
+}
+
+// 查询钱包持有的非零代币余额
+func fetchTokenBalances(ctx context.Context, address string) ([]TokenBalance, error) {
+	var tokens []TokenBalance
+	for _, program := range tokenProgramIDs {
+		var result struct {
+			Value []struct {
+				Account struct {
+					Data struct {
+						Parsed struct {
+							Info struct {
+								Mint        string `json:"mint"`
+								TokenAmount struct {
+									Amount         string `json:"amount"`
+									Decimals       int    `json:"decimals"`
+									UIAmountString string `json:"uiAmountString"`
+								} `json:"tokenAmount"`
+							} `json:"info"`
+						} `json:"parsed"`
+					} `json:"data"`
+				} `json:"account"`
+			} `json:"value"`
+		}
+		params := []interface{}{address, map[string]string{"programId": program}, map[string]string{"encoding": "jsonParsed", "commitment": "confirmed"}}
+		if err := solanaRPC(ctx, "getTokenAccountsByOwner", params, &result); err != nil {
+			return nil, err
+		}
+		for _, v := range result.Value {
+			info := v.Account.Data.Parsed.Info
+			if info.TokenAmount.Amount == "" || info.TokenAmount.Amount == "0" {
+				continue
+			}
+			ui, _ := strconv.ParseFloat(info.TokenAmount.UIAmountString, 64)
+			tokens = append(tokens, TokenBalance{Mint: info.Mint, Amount: info.TokenAmount.Amount, Decimals: info.TokenAmount.Decimals, UIAmount: ui})
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Mint < tokens[j].Mint })
+	return tokens, nil
+}
+
+// checkWalletBalance 查询一次余额，记录结果并在 SOL 不足时告警
+func checkWalletBalance() {
+	cfg := currentConfig().BalanceMonitor
+	address := walletAddress()
+	status := WalletBalance{CheckedAt: time.Now().Format(time.RFC3339), Address: address}
+	if address == "" {
+		status.Error = "未配置钱包地址（walletWatch.address 或 USER_WALLET_ADDRESS）"
+	} else {
+		ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+		defer cancel()
+		if sol, err := getSOLBalance(ctx, address); err != nil {
+			status.Error = err.Error()
+		} else {
+			status.SOL = sol
+			status.Low = status.SOL < cfg.MinSOL
+		}
+		if status.Error == "" && cfg.Tokens {
+			tokens, err := fetchTokenBalances(ctx, address)
+			if err != nil {
+				logWarn("⚠️ 查询代币余额失败", "address", address, "error", err)
+			}
+			status.Tokens = tokens
+		}
+	}
+
+	walletBalanceMutex.Lock()
+	prevLow := walletBalance.Low
+	if status.Error != "" {
+		// 查询失败时保留上次的余额，只更新错误
+		prev := walletBalance
+		prev.CheckedAt, prev.Error = status.CheckedAt, status.Error
+		status = prev
+	}
+	walletBalance = status
+	walletBalanceMutex.Unlock()
+
+	if status.Error != "" {
+		logWarn("⚠️ 查询钱包余额失败", "address", address, "error", status.Error)
+		return
+	}
+	setLowSOL(status.Low)
+	sol := fmt.Sprintf("%.6f", status.SOL)
+	if status.Low {
+		logWarn("🪫 钱包 SOL 余额不足，交易可能因手续费不足失败", "address", address, "sol", sol, "minSol", cfg.MinSOL)
+		notifyKeyed(eventLowBalance, levelCritical, "low_sol", "钱包 SOL 余额不足",
+			fmt.Sprintf("当前 %s SOL，低于阈值 %g SOL，请及时充值", sol, cfg.MinSOL),
+			map[string]string{"address": address, "sol": sol})
+		return
+	}
+	if prevLow {
+		logOutput("🔋 钱包 SOL 余额已恢复: %s SOL\n", sol)
+	}
+	logDebug("💰 钱包余额检查", "address", address, "sol", sol, "tokens", len(status.Tokens))
+}
+
+func currentWalletBalance() WalletBalance {
+	walletBalanceMutex.Lock()
+	defer walletBalanceMutex.Unlock()
+	return walletBalance
+}
+
+// 代币余额指标样本
+func walletTokenSamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, t := range currentWalletBalance().Tokens {
+		samples = append(samples, gaugeSample{LabelValues: []string{t.Mint}, Value: t.UIAmount})
+	}
+	return samples
+}
+
+// startBalanceMonitor 启动时查询一次，之后按间隔定期查询
+func startBalanceMonitor() {
+	cfg := currentConfig().BalanceMonitor
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("💰 启动钱包余额监控（每%v，SOL 告警阈值 %g）\n", interval, cfg.MinSOL)
+	checkWalletBalance()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止钱包余额监控\n")
+			return
+		case <-ticker.C:
+			checkWalletBalance()
+		}
+	}
+}
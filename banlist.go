@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 名单文件：逗号分隔的地址（支持中英文逗号）
+func banFilePath() string     { return dataPath("ban", "ban.csv") }   // 代币 ca
+func poolBanFilePath() string { return dataPath("ban", "pools.csv") } // 池地址（代币没问题但池有问题时使用）
+func allowFilePath() string   { return dataPath("ban", "allow.csv") } // 允许名单：代币 ca 或池地址，文件为空或不存在表示不限制
+
+// banFile 名单缓存：文件变化（fsnotify）时标记失效，下次读取时重新加载并只记录增删的条目
+type banFile struct {
+	path     func() string // 数据目录可由命令行参数指定，读取时再解析
+	name     string        // 日志中的名单名称
+	kind     string        // 日志中的条目名称
+	optional bool          // 文件不存在时不告警
+	mu       sync.Mutex
+	cache    map[string]bool
+	stale    atomic.Bool
+}
+
+var (
+	tokenBanList   = &banFile{path: banFilePath, name: "黑名单", kind: "ca"}
+	poolBanList    = &banFile{path: poolBanFilePath, name: "黑名单", kind: "池"}
+	allowList      = &banFile{path: allowFilePath, name: "允许名单", kind: "地址", optional: true}
+	banListWatched atomic.Bool // 监听未启动时每次读取都重新加载
+)
+
+// 从文件解析名单
+func (b *banFile) load() map[string]bool {
+	banList := make(map[string]bool)
+	content, err := os.ReadFile(b.path())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logOutput("❌ 读取%s文件失败: %v\n", b.name, err)
+		} else if !b.optional {
+			logOutput("⚠️ %s文件不存在: %s\n", b.name, b.path())
+		}
+		return banList
+	}
+	// 先替换中文逗号为英文逗号，然后分割
+	line := strings.ReplaceAll(strings.TrimSpace(string(content)), "，", ",")
+	for _, addr := range strings.Split(line, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			banList[addr] = true
+		}
+	}
+	return banList
+}
+
+// 记录名单的增删
+func (b *banFile) logDiff(old, cur map[string]bool) {
+	var added, removed []string
+	for addr := range cur {
+		if !old[addr] {
+			added = append(added, addr)
+		}
+	}
+	for addr := range old {
+		if !cur[addr] {
+			removed = append(removed, addr)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	for _, addr := range added {
+		logOutput("🚫 %s新增%s: %s\n", b.name, b.kind, addr)
+	}
+	for _, addr := range removed {
+		logOutput("✅ %s移除%s: %s\n", b.name, b.kind, addr)
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		logOutput("📊 %s%s已更新: 共 %d 个（新增 %d，移除 %d）\n", b.kind, b.name, len(cur), len(added), len(removed))
+	}
+}
+
+// 返回最新的缓存（调用方需持有 b.mu）
+func (b *banFile) currentLocked() map[string]bool {
+	if b.cache == nil || b.stale.Swap(false) || !banListWatched.Load() {
+		cur := b.load()
+		if b.cache == nil {
+			logOutput("📊 加载了 %d 个%s%s\n", len(cur), b.name, b.kind)
+		} else {
+			b.logDiff(b.cache, cur)
+		}
+		b.cache = cur
+	}
+	return b.cache
+}
+
+func (b *banFile) contains(addr string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentLocked()[addr]
+}
+
+// isPoolBanned 池是否在 pools.csv 或管理的黑名单中（各子系统如何处理见 listPolicy）
+func isPoolBanned(poolAddress string) bool {
+	return poolBanList.contains(poolAddress) || isManagedBan(banKindPool, poolAddress)
+}
+
+// 允许名单为空时不限制；否则代币或池任一在名单中即允许
+func isAllowed(poolAddress, tokenAddress string) bool {
+	allowList.mu.Lock()
+	defer allowList.mu.Unlock()
+	cur := allowList.currentLocked()
+	return len(cur) == 0 || (poolAddress != "" && cur[poolAddress]) || (tokenAddress != "" && cur[tokenAddress])
+}
+
+// startBanListWatcher 监听名单所在目录，文件写入、重建或删除时使对应缓存失效
+func startBanListWatcher() {
+	dir := filepath.Dir(banFilePath())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logWarn("⚠️ 创建黑名单目录失败，黑名单将每次重新读取", "dir", dir, "error", err)
+		return
+	}
+	watcher, err := newFileWatcher("banlist")
+	if err != nil {
+		logWarn("⚠️ 创建黑名单监听失败，黑名单将每次重新读取", "error", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		logWarn("⚠️ 添加黑名单监听失败，黑名单将每次重新读取", "dir", dir, "error", err)
+		return
+	}
+	lists := []*banFile{tokenBanList, poolBanList, allowList}
+	for _, b := range lists {
+		b.stale.Store(true)
+	}
+	banListWatched.Store(true)
+	defer banListWatched.Store(false)
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			for _, b := range lists {
+				if event.Name == b.path() {
+					b.stale.Store(true)
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// 监听出错时可能丢失事件，下次读取时重新加载
+			for _, b := range lists {
+				b.stale.Store(true)
+			}
+			logError("❌ 黑名单监听错误", "error", err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通过 API / CLI 管理的黑名单（data/state/bans.json），与 ban.csv、pools.csv 合并生效
+const (
+	banKindToken = "token"
+	banKindPool  = "pool"
+)
+
+// 条目来源
+const (
+	banSourceAPI      = "api"
+	banSourceCLI      = "cli"
+	banSourceSwapFail = "swap_failures" // 兑换连续失败自动加入
+	banSourceLegacy   = "legacy"        // 首次运行时从 ban.csv、pools.csv 导入
+)
+
+// BanListConfig 自动拉黑
+type BanListConfig struct {
+	AutoBanSwapFailures int     `json:"autoBanSwapFailures"` // 代币兑换连续失败达到该次数后自动拉黑，0 表示关闭
+	AutoBanHours        float64 `json:"autoBanHours"`        // 自动拉黑的有效期（小时），0 表示永久
+}
+
+// BanEntry 一条黑名单
+type BanEntry struct {
+	Address   string `json:"address"`
+	Kind      string `json:"kind"` // token | pool
+	Reason    string `json:"reason,omitempty"`
+	Source    string `json:"source"`
+	AddedAt   string `json:"addedAt"`
+	ExpiresAt string `json:"expiresAt,omitempty"` // 为空表示永久
+}
+
+var (
+	banStoreMutex   sync.Mutex
+	banStore        map[string]BanEntry // 地址 -> 条目
+	banStoreModTime time.Time           // 状态文件修改时间，CLI 在进程外修改后重新加载
+	swapFailures    = map[string]int{}  // 代币 -> 连续兑换失败次数
+)
+
+func (c BanListConfig) validate() error {
+	if c.AutoBanSwapFailures < 0 {
+		return fmt.Errorf("banList.autoBanSwapFailures 不能为负数")
+	}
+	if c.AutoBanHours < 0 {
+		return fmt.Errorf("banList.autoBanHours 不能为负数")
+	}
+	return nil
+}
+
+func (e BanEntry) expired(now time.Time) bool {
+	if e.ExpiresAt == "" {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, e.ExpiresAt)
+	return err == nil && !now.Before(t)
+}
+
+// 返回最新的条目（调用方需持有 banStoreMutex）
+func currentBansLocked() map[string]BanEntry {
+	info, err := os.Stat(filepath.Join(currentStateDir(), "bans.json"))
+	if banStore != nil && (err != nil || info.ModTime().Equal(banStoreModTime)) {
+		return banStore
+	}
+	bans := map[string]BanEntry{}
+	if err := loadStateFile("bans", &bans); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if err == nil {
+		banStoreModTime = info.ModTime()
+	}
+	banStore = bans
+	return banStore
+}
+
+// 保存条目，同时清理已到期的条目（调用方需持有 banStoreMutex）
+func saveBansLocked(bans map[string]BanEntry) error {
+	now := time.Now()
+	for addr, e := range bans {
+		if e.expired(now) {
+			delete(bans, addr)
+		}
+	}
+	if err := saveStateFile("bans", bans); err != nil {
+		return err
+	}
+	banStore = bans
+	if info, err := os.Stat(filepath.Join(currentStateDir(), "bans.json")); err == nil {
+		banStoreModTime = info.ModTime()
+	}
+	return nil
+}
+
+// 地址是否在管理的黑名单中（已到期的不算）
+func isManagedBan(kind, address string) bool {
+	if address == "" {
+		return false
+	}
+	banStoreMutex.Lock()
+	defer banStoreMutex.Unlock()
+	e, ok := currentBansLocked()[address]
+	return ok && e.Kind == kind && !e.expired(time.Now())
+}
+
+// isTokenBanned 代币在 ban.csv 或管理的黑名单中
+func isTokenBanned(tokenAddress string) bool {
+	return tokenBanList.contains(tokenAddress) || isManagedBan(banKindToken, tokenAddress)
+}
+
+// addBan 加入黑名单（已存在时更新原因与有效期），ttl 为 0 表示永久
+func addBan(kind, address, reason, source string, ttl time.Duration) (BanEntry, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return BanEntry{}, fmt.Errorf("地址不能为空")
+	}
+	if kind != banKindToken && kind != banKindPool {
+		return BanEntry{}, fmt.Errorf("kind 只支持 %s 或 %s: %s", banKindToken, banKindPool, kind)
+	}
+	if ttl < 0 {
+		return BanEntry{}, fmt.Errorf("有效期不能为负数")
+	}
+	now := time.Now()
+	e := BanEntry{Address: address, Kind: kind, Reason: reason, Source: source, AddedAt: now.Format(time.RFC3339)}
+	if ttl > 0 {
+		e.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+	}
+
+	banStoreMutex.Lock()
+	defer banStoreMutex.Unlock()
+	bans := map[string]BanEntry{}
+	for k, v := range currentBansLocked() {
+		bans[k] = v
+	}
+	bans[address] = e
+	if err := saveBansLocked(bans); err != nil {
+		return BanEntry{}, fmt.Errorf("保存黑名单失败: %v", err)
+	}
+	expires := "永久"
+	if e.ExpiresAt != "" {
+		expires = e.ExpiresAt
+	}
+	logOutput("🚫 黑名单新增%s: %s（%s，来源 %s，到期 %s）\n", kind, address, reason, source, expires)
+	return e, nil
+}
+
+// removeBan 移除黑名单条目，返回是否存在（ban.csv 中的地址需编辑文件移除）
+func removeBan(address string) (bool, error) {
+	banStoreMutex.Lock()
+	defer banStoreMutex.Unlock()
+	bans := map[string]BanEntry{}
+	for k, v := range currentBansLocked() {
+		bans[k] = v
+	}
+	if _, ok := bans[address]; !ok {
+		return false, nil
+	}
+	delete(bans, address)
+	if err := saveBansLocked(bans); err != nil {
+		return false, fmt.Errorf("保存黑名单失败: %v", err)
+	}
+	logOutput("✅ 黑名单移除: %s\n", address)
+	return true, nil
+}
+
+// 未到期的黑名单条目（按加入时间倒序）
+func listBans() []BanEntry {
+	banStoreMutex.Lock()
+	defer banStoreMutex.Unlock()
+	now := time.Now()
+	result := []BanEntry{}
+	for _, e := range currentBansLocked() {
+		if !e.expired(now) {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AddedAt > result[j].AddedAt })
+	return result
+}
+
+// noteSwapResult 记录代币兑换结果，连续失败达到阈值时自动拉黑（风控、阶梯清理等轮外兑换同样计入）
+func noteSwapResult(tokenAddress string, err error) {
+	cfg := currentConfig().BanList
+	if cfg.AutoBanSwapFailures <= 0 || isDryRun() {
+		return
+	}
+	// 冻结、熔断与关闭时的失败与代币无关
+	if errors.Is(err, errFrozen) || errors.Is(err, errCircuitOpen) || globalCtx.Err() != nil {
+		return
+	}
+	banStoreMutex.Lock()
+	if err == nil {
+		delete(swapFailures, tokenAddress)
+		banStoreMutex.Unlock()
+		return
+	}
+	swapFailures[tokenAddress]++
+	failures := swapFailures[tokenAddress]
+	banStoreMutex.Unlock()
+	if failures < cfg.AutoBanSwapFailures {
+		return
+	}
+
+	ttl := time.Duration(cfg.AutoBanHours * float64(time.Hour))
+	reason := fmt.Sprintf("兑换连续失败 %d 次: %v", failures, err)
+	if _, addErr := addBan(banKindToken, tokenAddress, reason, banSourceSwapFail, ttl); addErr != nil {
+		logError("❌ 自动拉黑失败", "token", tokenAddress, "error", addErr)
+		return
+	}
+	banStoreMutex.Lock()
+	delete(swapFailures, tokenAddress)
+	banStoreMutex.Unlock()
+	notifyKeyed(eventAutoBan, levelWarning, tokenAddress, "代币已自动拉黑", reason,
+		map[string]string{"ca": tokenAddress, "failures": fmt.Sprint(failures)})
+}
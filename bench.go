@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 压测报告（-bench），每个阶段完成后更新
+func benchReportPath() string { return dataPath("demo", "bench.json") }
+
+// 压测期间的采样间隔
+const benchSampleInterval = 5 * time.Second
+
+// BenchJobStats 一个阶段内单个定时任务的轮次耗时
+type BenchJobStats struct {
+	Runs     int    `json:"runs"`
+	Overlaps int    `json:"overlaps"` // 上一轮未结束而丢弃的轮次
+	Avg      string `json:"avg,omitempty"`
+	Max      string `json:"max,omitempty"`
+	total    time.Duration
+	max      time.Duration
+}
+
+// BenchStage 一个池数阶段的测量结果
+type BenchStage struct {
+	Pools          int                       `json:"pools"`   // 目标持仓池数
+	Reached        bool                      `json:"reached"` // 爬坡超时未达到目标时为 false，测量仍按实际池数进行
+	OpenPools      int                       `json:"openPools"`
+	RampTook       string                    `json:"rampTook"`
+	Jobs           map[string]*BenchJobStats `json:"jobs"`
+	MaxInFlight    int64                     `json:"maxInFlight"`    // 在途新池任务峰值
+	MaxBacklog     int64                     `json:"maxBacklog"`     // 已写入 CSV 但尚未处理的信号峰值
+	MaxHeapMB      float64                   `json:"maxHeapMB"`      // 堆内存峰值
+	MaxSysMB       float64                   `json:"maxSysMB"`       // 向系统申请的内存峰值
+	MaxGoroutines  int                       `json:"maxGoroutines"`  //
+	DroppedRounds  int                       `json:"droppedRounds"`  // 各定时任务因重叠丢弃的轮次
+	DroppedAlerts  int64                     `json:"droppedAlerts"`  // 告警队列已满而丢弃的告警
+	ScriptFailures int64                     `json:"scriptFailures"` // 模拟脚本失败次数（含重试）
+}
+
+// BenchReport 压测报告
+type BenchReport struct {
+	StartedAt     string            `json:"startedAt"`
+	FinishedAt    string            `json:"finishedAt,omitempty"`
+	Schedules     map[string]string `json:"schedules"`
+	MaxConcurrent int               `json:"maxConcurrentTasks"`
+	Stages        []*BenchStage     `json:"stages"`
+}
+
+// 解析 -bench 参数，如 "100,500,1000"（按升序执行）
+func parseBenchStages(s string) ([]int, error) {
+	var stages []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("-bench 需要以逗号分隔的正整数池数，如 100,500,1000: %q", part)
+		}
+		stages = append(stages, n)
+	}
+	sort.Ints(stages)
+	return stages, nil
+}
+
+// 当前持仓中的演示池数
+func demoOpenPools() int {
+	demo.mu.Lock()
+	defer demo.mu.Unlock()
+	return len(demo.open)
+}
+
+func demoScriptFailures() int64 {
+	demo.mu.Lock()
+	defer demo.mu.Unlock()
+	var total int64
+	for _, v := range demo.fails {
+		total += v
+	}
+	return total
+}
+
+// startBench 按阶段爬坡到目标池数，每个阶段保持 demo.benchStageMinutes 并采样，全部完成后写出报告并退出
+func startBench(stages []int) {
+	cfg := currentConfig().Demo
+	report := &BenchReport{
+		StartedAt:     time.Now().Format(time.RFC3339),
+		MaxConcurrent: currentConfig().MaxConcurrent,
+		Schedules: map[string]string{
+			"price": currentConfig().Schedules.Price.Cron, "claim": currentConfig().Schedules.Claim.Cron, "swap": currentConfig().Schedules.Swap.Cron,
+		},
+	}
+	logOutput("🏋️ 开始压测：阶段 %v，每阶段测量 %d 分钟，爬坡速率 %.0f 行/秒\n", stages, cfg.BenchStageMinutes, cfg.BenchRowsPerSecond)
+	for _, target := range stages {
+		stage := &BenchStage{Pools: target, Jobs: map[string]*BenchJobStats{}}
+		report.Stages = append(report.Stages, stage)
+		if !benchRamp(stage) || !benchMeasure(stage) {
+			break
+		}
+		logBenchStage(stage)
+		writeBenchReport(report)
+	}
+	report.FinishedAt = time.Now().Format(time.RFC3339)
+	writeBenchReport(report)
+	if globalCtx.Err() == nil {
+		logOutput("🏁 压测完成，报告已写入 %s\n", benchReportPath())
+		globalCancel()
+	}
+}
+
+// 按 benchRowsPerSecond 写入信号直到持仓池数达到目标；超时未达到时记录并继续测量。收到退出信号时返回 false
+func benchRamp(stage *BenchStage) bool {
+	cfg := currentConfig().Demo
+	start := time.Now()
+	// 预期爬坡时间的 3 倍再加 2 分钟（开仓失败重试、并发排队）
+	timeout := time.Duration(float64(stage.Pools)/cfg.BenchRowsPerSecond*3*float64(time.Second)) + 2*time.Minute
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	logOutput("📈 压测阶段 %d 个池：开始爬坡\n", stage.Pools)
+	for {
+		open := demoOpenPools()
+		if open >= stage.Pools {
+			stage.Reached = true
+			break
+		}
+		if time.Since(start) > timeout {
+			logWarn("⚠️ 爬坡超时，未达到目标池数", "target", stage.Pools, "openPools", open, "took", time.Since(start).Round(time.Second))
+			break
+		}
+		// 尚未处理的信号与在途任务也计入，避免排队期间重复写入过多
+		pending := int(demo.rowsWritten.Load() - int64(metricCSVRows.Value("received")) + inFlightTasks.Load())
+		batch := int(cfg.BenchRowsPerSecond)
+		if need := stage.Pools - open - pending; need < batch {
+			batch = need
+		}
+		for i := 0; i < batch; i++ {
+			if _, _, err := appendDemoRow(); err != nil {
+				logError("❌ 写入演示信号失败", "error", err)
+				break
+			}
+		}
+		select {
+		case <-globalCtx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	stage.RampTook = time.Since(start).Round(time.Second).String()
+	return true
+}
+
+// 在测量窗口内采样内存、队列与各定时任务的轮次耗时
+func benchMeasure(stage *BenchStage) bool {
+	cfg := currentConfig().Demo
+	start := time.Now()
+	end := start.Add(time.Duration(cfg.BenchStageMinutes) * time.Minute)
+	alertsBefore := metricAlertsDropped.Value()
+	failuresBefore := demoScriptFailures()
+	seen := map[string]map[string]bool{}
+	ticker := time.NewTicker(benchSampleInterval)
+	defer ticker.Stop()
+	logOutput("⏱️ 压测阶段 %d 个池：持仓 %d 个，开始测量\n", stage.Pools, demoOpenPools())
+	for {
+		benchSample(stage, start, seen)
+		if !time.Now().Before(end) {
+			break
+		}
+		select {
+		case <-globalCtx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	stage.OpenPools = demoOpenPools()
+	stage.DroppedAlerts = int64(metricAlertsDropped.Value() - alertsBefore)
+	stage.ScriptFailures = demoScriptFailures() - failuresBefore
+	for _, j := range stage.Jobs {
+		stage.DroppedRounds += j.Overlaps
+		if j.Runs > 0 {
+			j.Avg = (j.total / time.Duration(j.Runs)).Round(time.Millisecond).String()
+			j.Max = j.max.Round(time.Millisecond).String()
+		}
+	}
+	return true
+}
+
+func benchSample(stage *BenchStage, since time.Time, seen map[string]map[string]bool) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stage.MaxHeapMB = maxFloat(stage.MaxHeapMB, float64(mem.HeapAlloc)/1024/1024)
+	stage.MaxSysMB = maxFloat(stage.MaxSysMB, float64(mem.Sys)/1024/1024)
+	if g := runtime.NumGoroutine(); g > stage.MaxGoroutines {
+		stage.MaxGoroutines = g
+	}
+	if n := inFlightTasks.Load(); n > stage.MaxInFlight {
+		stage.MaxInFlight = n
+	}
+	if backlog := demo.rowsWritten.Load() - int64(metricCSVRows.Value("received")); backlog > stage.MaxBacklog {
+		stage.MaxBacklog = backlog
+	}
+
+	// 只统计本阶段开始后计划的轮次，按计划时间去重（执行记录在轮次结束时追加，顺序不一定按计划时间）
+	for _, job := range listJobStatus() {
+		stats := stage.Jobs[job.Name]
+		if stats == nil {
+			stats = &BenchJobStats{}
+			stage.Jobs[job.Name] = stats
+			seen[job.Name] = map[string]bool{}
+		}
+		for _, run := range job.History {
+			at, err := time.Parse(time.RFC3339, run.ScheduledAt)
+			if err != nil || at.Before(since) || seen[job.Name][run.ScheduledAt] {
+				continue
+			}
+			if run.Skipped == "overlap" {
+				stats.Overlaps++
+			} else if took, err := time.ParseDuration(run.Duration); err == nil {
+				stats.Runs++
+				stats.total += took
+				if took > stats.max {
+					stats.max = took
+				}
+			} else {
+				continue
+			}
+			seen[job.Name][run.ScheduledAt] = true
+		}
+	}
+}
+
+func maxFloat(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func logBenchStage(stage *BenchStage) {
+	jobs := make([]string, 0, len(stage.Jobs))
+	for name, j := range stage.Jobs {
+		if j.Runs > 0 || j.Overlaps > 0 {
+			jobs = append(jobs, fmt.Sprintf("%s 平均%s/最长%s/丢弃%d", name, j.Avg, j.Max, j.Overlaps))
+		}
+	}
+	sort.Strings(jobs)
+	logInfo("📊 压测阶段结果", "pools", stage.Pools, "openPools", stage.OpenPools, "reached", stage.Reached, "rampTook", stage.RampTook,
+		"jobs", strings.Join(jobs, "; "), "maxInFlight", stage.MaxInFlight, "maxBacklog", stage.MaxBacklog,
+		"heapMB", fmt.Sprintf("%.1f", stage.MaxHeapMB), "sysMB", fmt.Sprintf("%.1f", stage.MaxSysMB), "goroutines", stage.MaxGoroutines,
+		"droppedRounds", stage.DroppedRounds, "droppedAlerts", stage.DroppedAlerts, "scriptFailures", stage.ScriptFailures)
+}
+
+func writeBenchReport(report *BenchReport) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(benchReportPath(), content, 0644)
+	}
+	if err != nil {
+		logError("❌ 写入压测报告失败", "error", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockhashCacheConfig 预取并缓存最新区块哈希，通过 --blockhash 传给发送交易的脚本，避免每个脚本各自请求 getLatestBlockhash
+type BlockhashCacheConfig struct {
+	Enabled        bool     `json:"enabled"`
+	RefreshSeconds int      `json:"refreshSeconds"` // 后台刷新间隔（RPC 同 walletWatch.rpcUrl）
+	MaxAgeSeconds  int      `json:"maxAgeSeconds"`  // 缓存超过该时间不再使用，执行前同步刷新（区块哈希约 60~90 秒后过期）
+	Commitment     string   `json:"commitment"`     // getLatestBlockhash 的确认级别
+	Targets        []string `json:"targets"`        // 传入 --blockhash 的外部命令目标（须为支持该参数的 ts 脚本）
+}
+
+// cachedBlockhash 缓存的区块哈希
+type cachedBlockhash struct {
+	Blockhash            string `json:"blockhash"`
+	LastValidBlockHeight uint64 `json:"lastValidBlockHeight"` // 超过该区块高度后使用此哈希的交易不会再上链
+	FetchedAt            time.Time
+}
+
+// BlockhashStatus 当前缓存状态（/status）
+type BlockhashStatus struct {
+	Blockhash            string  `json:"blockhash,omitempty"`
+	LastValidBlockHeight uint64  `json:"lastValidBlockHeight,omitempty"`
+	FetchedAt            string  `json:"fetchedAt,omitempty"`
+	AgeSeconds           float64 `json:"ageSeconds"`
+	Error                string  `json:"error,omitempty"`
+}
+
+var (
+	blockhashMutex sync.Mutex
+	blockhashFetch sync.Mutex // 同一时间只有一个刷新请求，并发的执行方等待其结果
+	blockhashCache cachedBlockhash
+	blockhashErr   string
+)
+
+func (c BlockhashCacheConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("blockhashCache 需要 walletWatch.rpcUrl")
+	}
+	if c.RefreshSeconds <= 0 || c.MaxAgeSeconds <= 0 {
+		return fmt.Errorf("blockhashCache.refreshSeconds、maxAgeSeconds 必须大于0")
+	}
+	if c.RefreshSeconds >= c.MaxAgeSeconds {
+		return fmt.Errorf("blockhashCache.refreshSeconds 必须小于 maxAgeSeconds")
+	}
+	if c.Commitment != "processed" && c.Commitment != "confirmed" && c.Commitment != "finalized" {
+		return fmt.Errorf("blockhashCache.commitment 仅支持 processed、confirmed 或 finalized")
+	}
+	return nil
+}
+
+func (b cachedBlockhash) fresh() bool {
+	return b.Blockhash != "" && time.Since(b.FetchedAt) < time.Duration(currentConfig().BlockhashCache.MaxAgeSeconds)*time.Second
+}
+
+// refreshBlockhash 请求一次 getLatestBlockhash 并更新缓存；等待期间已被其他调用刷新时直接返回新缓存
+func refreshBlockhash() (cachedBlockhash, error) {
+	requested := time.Now()
+	blockhashFetch.Lock()
+	defer blockhashFetch.Unlock()
+	blockhashMutex.Lock()
+	current := blockhashCache
+	blockhashMutex.Unlock()
+	if current.FetchedAt.After(requested) {
+		return current, nil
+	}
+
+	ctx, cancel := context.WithTimeout(globalCtx, 10*time.Second)
+	defer cancel()
+	var result struct {
+		Value struct {
+			Blockhash            string `json:"blockhash"`
+			LastValidBlockHeight uint64 `json:"lastValidBlockHeight"`
+		} `json:"value"`
+	}
+	err := solanaRPC(ctx, "getLatestBlockhash", []interface{}{map[string]string{"commitment": currentConfig().BlockhashCache.Commitment}}, &result)
+
+	blockhashMutex.Lock()
+	defer blockhashMutex.Unlock()
+	if err != nil {
+		blockhashErr = err.Error()
+		metricBlockhash.Inc("error")
+		return blockhashCache, err
+	}
+	blockhashCache = cachedBlockhash{Blockhash: result.Value.Blockhash, LastValidBlockHeight: result.Value.LastValidBlockHeight, FetchedAt: time.Now()}
+	blockhashErr = ""
+	metricBlockhash.Inc("refresh")
+	return blockhashCache, nil
+}
+
+// blockhashArgs 目标脚本的 --blockhash=<hash>:<lastValidBlockHeight>；缓存过期时同步刷新，刷新失败时不传（脚本自行获取）
+func blockhashArgs(target string) []string {
+	cfg := currentConfig().BlockhashCache
+	if !cfg.Enabled || isDemo() || !containsTarget(cfg.Targets, target) {
+		return nil
+	}
+	blockhashMutex.Lock()
+	b := blockhashCache
+	blockhashMutex.Unlock()
+	if b.fresh() {
+		metricBlockhash.Inc("hit")
+	} else {
+		var err error
+		if b, err = refreshBlockhash(); err != nil || !b.fresh() {
+			logWarn("⚠️ 获取区块哈希失败，由脚本自行获取", "target", target, "error", err)
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("--blockhash=%s:%d", b.Blockhash, b.LastValidBlockHeight)}
+}
+
+// 从命令参数中的 --blockhash 解析 lastValidBlockHeight（未传入时为 0）
+func argLastValidBlockHeight(args []string) uint64 {
+	v := argValue(args, "--blockhash")
+	i := strings.LastIndex(v, ":")
+	if i < 0 {
+		return 0
+	}
+	h, _ := strconv.ParseUint(v[i+1:], 10, 64)
+	return h
+}
+
+func currentBlockhash() BlockhashStatus {
+	blockhashMutex.Lock()
+	defer blockhashMutex.Unlock()
+	s := BlockhashStatus{Blockhash: blockhashCache.Blockhash, LastValidBlockHeight: blockhashCache.LastValidBlockHeight, Error: blockhashErr}
+	if !blockhashCache.FetchedAt.IsZero() {
+		s.FetchedAt = blockhashCache.FetchedAt.Format(time.RFC3339)
+		s.AgeSeconds = time.Since(blockhashCache.FetchedAt).Seconds()
+	}
+	return s
+}
+
+// startBlockhashCache 定期预取区块哈希，使突发的领取、兑换直接使用缓存
+func startBlockhashCache() {
+	cfg := currentConfig().BlockhashCache
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	interval := time.Duration(cfg.RefreshSeconds) * time.Second
+	logOutput("🧱 启动区块哈希预取（每%v，缓存有效 %ds）\n", interval, cfg.MaxAgeSeconds)
+	if _, err := refreshBlockhash(); err != nil {
+		logWarn("⚠️ 预取区块哈希失败", "error", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止区块哈希预取\n")
+			return
+		case <-ticker.C:
+			if _, err := refreshBlockhash(); err != nil {
+				logWarn("⚠️ 预取区块哈希失败", "error", err)
+			}
+		}
+	}
+}
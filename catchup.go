@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CatchUpConfig 启动时补处理停机期间遗漏的 CSV 新增行与池 JSON 文件
+type CatchUpConfig struct {
+	Enabled       bool `json:"enabled"`       // 关闭时丢弃停机期间的 CSV 新增行，不扫描池文件
+	MaxAgeMinutes int  `json:"maxAgeMinutes"` // 早于该时长的信号不再补处理（0 表示不限制）
+}
+
+func (c CatchUpConfig) validate() error {
+	if c.MaxAgeMinutes < 0 {
+		return fmt.Errorf("catchUp.maxAgeMinutes 不能为负数")
+	}
+	return nil
+}
+
+// 补处理的时间下限（不限制时为零值）
+func catchUpCutoff() time.Time {
+	if currentConfig().CatchUp.MaxAgeMinutes <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(currentConfig().CatchUp.MaxAgeMinutes) * time.Minute)
+}
+
+// catchUpFresh 积压信号是否仍在补处理时限内（按 last_updated_first 判断，缺失或无法解析时视为有效）
+func catchUpFresh(data map[string]interface{}) bool {
+	cutoff := catchUpCutoff()
+	if cutoff.IsZero() {
+		return true
+	}
+	s, _ := data["last_updated_first"].(string)
+	t, err := parseLastUpdatedFirstToTime(s)
+	if err != nil {
+		return true
+	}
+	return !t.Before(cutoff)
+}
+
+// missedPoolFiles 停机期间写入、尚未处理且在时限内的池 JSON 文件（按修改时间排序）
+func missedPoolFiles(dir string) []string {
+	if !currentConfig().CatchUp.Enabled {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil
+	}
+	cutoff := catchUpCutoff()
+	type candidate struct {
+		path    string
+		modTime time.Time
+	}
+	var files []candidate
+	stale := 0
+	for _, path := range matches {
+		fi, err := os.Stat(path)
+		if err != nil || fi.IsDir() || isProcessed(path) {
+			continue
+		}
+		if !cutoff.IsZero() && fi.ModTime().Before(cutoff) {
+			stale++
+			continue
+		}
+		files = append(files, candidate{path, fi.ModTime()})
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].modTime.Before(files[b].modTime) })
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	if len(paths) > 0 || stale > 0 {
+		logOutput("🧭 启动补处理: 发现 %d 个未处理的池文件，%d 个超出时限已跳过\n", len(paths), stale)
+	}
+	return paths
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// 故障注入（-inject-failures / -inject-latency）：按概率让外部命令与 RPC 请求失败，或在发出前随机延迟，
+// 用于在投入真实资金前验证重试、熔断、RPC 节点切换与状态机能否恢复。注入的失败发生在命令执行 / 请求发出之前，不会产生交易
+var (
+	chaosFailureRate float64       // 每次外部命令或 RPC 请求失败的概率（0~1）
+	chaosLatency     time.Duration // 每次执行前随机延迟 0~该时长
+)
+
+// 注入的故障类型（指标 meteora_injected_faults_total 的 kind）
+const (
+	chaosExecFailure = "exec_failure"
+	chaosRPCFailure  = "rpc_failure"
+	chaosLatencyKind = "latency"
+)
+
+// setChaos 按命令行参数开启故障注入
+func setChaos(failureRate float64, latency time.Duration) error {
+	if failureRate < 0 || failureRate > 1 {
+		return fmt.Errorf("-inject-failures 必须在 0~1 之间")
+	}
+	if latency < 0 {
+		return fmt.Errorf("-inject-latency 不能为负数")
+	}
+	chaosFailureRate, chaosLatency = failureRate, latency
+	return nil
+}
+
+func chaosEnabled() bool { return chaosFailureRate > 0 || chaosLatency > 0 }
+
+// logChaos 启动时提示故障注入已开启
+func logChaos() {
+	if chaosEnabled() {
+		logWarn("🧪 故障注入已开启，外部命令与 RPC 请求将随机失败或延迟", "failureRate", chaosFailureRate, "latency", chaosLatency)
+	}
+}
+
+// chaosDelay 随机延迟；ctx 取消时返回其错误
+func chaosDelay(ctx context.Context) error {
+	if chaosLatency <= 0 {
+		return nil
+	}
+	d := time.Duration(rand.Int63n(int64(chaosLatency) + 1))
+	metricInjectedFaults.Inc(chaosLatencyKind)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// chaosFail 按概率判定本次是否注入失败
+func chaosFail(kind string) bool {
+	if chaosFailureRate <= 0 || rand.Float64() >= chaosFailureRate {
+		return false
+	}
+	metricInjectedFaults.Inc(kind)
+	return true
+}
+
+// chaosExternal 外部命令执行前的注入：延迟后按概率返回可重试的失败；返回错误时不执行命令
+func chaosExternal(ctx context.Context, target string) ([]byte, error) {
+	if err := chaosDelay(ctx); err != nil {
+		return nil, err
+	}
+	if !chaosFail(chaosExecFailure) {
+		return nil, nil
+	}
+	logDebug("🧪 注入外部命令失败", "target", target)
+	return []byte("[inject] 注入失败: 503 Service Unavailable\n"), fmt.Errorf("exit status 1")
+}
+
+// chaosRPC RPC 请求发出前的注入：延迟后按概率返回节点不可用（触发节点切换与重试）
+func chaosRPC(ctx context.Context, url, method string) error {
+	if err := chaosDelay(ctx); err != nil {
+		return err
+	}
+	if !chaosFail(chaosRPCFailure) {
+		return nil
+	}
+	logDebug("🧪 注入 RPC 失败", "url", url, "method", method)
+	return fmt.Errorf("%w: RPC %s HTTP 503（注入）", errRPCUnavailable, method)
+}
@@ -2,7 +2,9 @@ import {
   Connection, 
   PublicKey, 
   Keypair, 
+  Transaction,
   VersionedTransaction,
+  ComputeBudgetProgram,
   clusterApiUrl
 } from '@solana/web3.js';
 import DLMM from '@meteora-ag/dlmm';
@@ -13,10 +15,16 @@ import bs58 from 'bs58';
 import CryptoJS from 'crypto-js';
 import fs from 'fs';
 import path from 'path';
-import { exec } from 'child_process';
-import { promisify } from 'util';
+import { DATA_DIR, poolFilePath } from './dataFile';
+import { execWithKey, pipedPrivateKey } from './signingKey';
 
-const execAsync = promisify(exec);
+// 程序目录（由 Go 调度程序通过环境变量传入；单独运行时为脚本所在目录）
+const BASE_DIR = process.env.METEORA_BASE_DIR || __dirname;
+
+// ===== 结构化输出（Go 端按 "@@event <json>" 行解析，见 scriptproto.go）=====
+function emitEvent(type: string, fields: Record<string, unknown> = {}): void {
+  console.log(`@@event ${JSON.stringify({ type, ...fields })}`);
+}
 
 // 加载环境变量
 dotenv.config();
@@ -96,12 +104,10 @@ async function executeJupSwap(ca: string): Promise<void> {
   try {
     console.log(`🔄 开始执行 jupSwap: ${ca}`);
     
-    const command = `./jupSwap -input ${ca} -maxfee 50000`;
+    const command = `./jupSwap -input ${ca} -maxfee ${resolveSwapMaxFeeFromArgs()}`;
     console.log(`执行命令: ${command}`);
     
-    const { stdout, stderr } = await execAsync(command, {
-      cwd: '/Users/yqw/meteora_dlmm'
-    });
+    const { stdout, stderr } = await execWithKey(command, BASE_DIR, true);
     
     if (stdout) {
       console.log('jupSwap 输出:', stdout);
@@ -116,8 +122,23 @@ async function executeJupSwap(ca: string): Promise<void> {
   }
 }
 
-// 连接配置
-const connection = new Connection(clusterApiUrl('mainnet-beta'), 'confirmed');
+// 连接配置（RPC_URL 由 main.go 按 RPC 节点池的当前最优节点传入，未设置时使用公共节点）
+const connection = new Connection(process.env.RPC_URL || clusterApiUrl('mainnet-beta'), 'confirmed');
+useCachedBlockhash(connection);
+
+// --blockhash=<hash>:<lastValidBlockHeight>：main.go 预取的区块哈希，传入时 SDK 构建交易不再请求 getLatestBlockhash
+function useCachedBlockhash(conn: Connection): void {
+  for (const arg of process.argv.slice(2)) {
+    if (!arg.startsWith('--blockhash=')) continue;
+    const [blockhash, height] = arg.slice('--blockhash='.length).replace(/['"]/g, '').trim().split(':');
+    const lastValidBlockHeight = Number(height);
+    if (!blockhash || !Number.isInteger(lastValidBlockHeight) || lastValidBlockHeight <= 0) {
+      throw new Error(`--blockhash 取值无效: ${arg}`);
+    }
+    conn.getLatestBlockhash = async () => ({ blockhash, lastValidBlockHeight });
+    return;
+  }
+}
 
 function getRawAmount(value: any): number {
   if (typeof value === 'number') return value;
@@ -129,7 +150,7 @@ function getRawAmount(value: any): number {
 // 从本地价格缓存读取 USD 价格：/data/prices/<mint>.json
 function readUsdPriceFromCache(tokenMint: string): number | undefined {
   try {
-    const p = path.resolve(__dirname, 'data', 'prices', `${tokenMint}.json`);
+    const p = path.join(DATA_DIR, 'prices', `${tokenMint}.json`);
     if (!fs.existsSync(p)) return undefined;
     const raw = fs.readFileSync(p, 'utf8');
     const obj = JSON.parse(raw);
@@ -172,9 +193,63 @@ function resolvePositionAddressFromArgs(): string | undefined {
   return undefined;
 }
 
+// --no-auto-exit：只输出仓位价值，不在达到 1.05 SOL 时自动移除（阶梯仓位由 main.go 按组统一平仓）
+function resolveNoAutoExitFromArgs(): boolean {
+  return argv.includes('--no-auto-exit');
+}
+
+// --min-claim-usd=<x>：未领取手续费（X + SOL，USD）达到该值才发送领取交易，默认 1
+function resolveMinClaimUsdFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--min-claim-usd=')) {
+      const n = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(n) && n >= 0) return n;
+    }
+  }
+  return 1;
+}
+
+// --force-claim：距上次领取已超过最长间隔（Go 端判断），只要有未领取手续费就领取
+function resolveForceClaimFromArgs(): boolean {
+  return argv.includes('--force-claim');
+}
+
+// --cu-price=<microLamports>：计算单元价格（优先费），由 main.go 按近期区块优先费传入；未传入时沿用 SDK 默认
+function resolveComputeUnitPriceFromArgs(): number | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--cu-price=')) {
+      const v = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isInteger(v) && v >= 0) return v;
+      throw new Error(`--cu-price 取值无效: ${arg}`);
+    }
+  }
+  return undefined;
+}
+
+// 设置交易的计算单元价格：移除 SDK 已添加的 SetComputeUnitPrice 指令后插入到最前面（须在签名前调用）
+function applyComputeUnitPrice(transaction: Transaction): void {
+  const microLamports = resolveComputeUnitPriceFromArgs();
+  if (microLamports === undefined) return;
+  transaction.instructions = transaction.instructions.filter(
+    (ix) => !(ix.programId.equals(ComputeBudgetProgram.programId) && ix.data.length > 0 && ix.data[0] === 3),
+  );
+  transaction.instructions.unshift(ComputeBudgetProgram.setComputeUnitPrice({ microLamports }));
+}
+
+// --swap-maxfee=<lamports>：领取后 jupSwap 的 -maxfee，由 main.go 按优先费传入；默认 50000
+function resolveSwapMaxFeeFromArgs(): string {
+  for (const arg of argv) {
+    if (arg.startsWith('--swap-maxfee=')) {
+      const v = sanitizeString(arg.split('=')[1]);
+      if (/^\d+$/.test(v)) return v;
+    }
+  }
+  return '50000';
+}
+
 function readPositionFromPoolJson(poolAddress: string): string | undefined {
   try {
-    const file = path.resolve(__dirname, 'data', `${poolAddress}.json`);
+    const file = poolFilePath(poolAddress);
     const raw = fs.readFileSync(file, 'utf8');
     const json = JSON.parse(raw);
     return json.positionAddress || json?.data?.positionAddress;
@@ -185,7 +260,7 @@ function readPositionFromPoolJson(poolAddress: string): string | undefined {
 
 function readTokenContractAddressFromPoolJson(poolAddress: string): string | undefined {
   try {
-    const file = path.resolve(__dirname, 'data', `${poolAddress}.json`);
+    const file = poolFilePath(poolAddress);
     const raw = fs.readFileSync(file, 'utf8');
     const json = JSON.parse(raw);
     
@@ -242,6 +317,8 @@ async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
  * 最基本的按仓位领取（Swap Fee + LM 奖励，若有其一即可）
  */
 async function claimAllRewardsByPosition() {
+  // SOL 的 USD 价格（止盈判断时获取），用于计算未领取的 SOL 手续费价值
+  let solUsdForFees: number | undefined;
   try {
     // 1. 解析池地址与仓位地址（命令行优先，其次环境变量）
     const cliPool = resolvePoolAddressFromArgs();
@@ -287,7 +364,7 @@ async function claimAllRewardsByPosition() {
     // 读取 JSON 文件获取池信息
     const readPoolJson = (poolAddress: string): any => {
       try {
-        const file = path.resolve(__dirname, 'data', `${poolAddress}.json`);
+        const file = poolFilePath(poolAddress);
         const raw = fs.readFileSync(file, 'utf8');
         return JSON.parse(raw);
       } catch (_) {
@@ -317,6 +394,7 @@ async function claimAllRewardsByPosition() {
       if (data && typeof data.total_fee_usd_claimed === 'number' && typeof data.total_reward_usd_claimed === 'number') {
         const totalUsd = Number(data.total_fee_usd_claimed) + Number(data.total_reward_usd_claimed);
         console.log(`💵 累计已领取(USD): fee=${data.total_fee_usd_claimed}, reward=${data.total_reward_usd_claimed}, sum=${totalUsd}`);
+        emitEvent('value', { key: 'claimedUSD', value: totalUsd });
 
         // 读取 position 的当前持仓 X/Y（最小单位），换算为实际数量
         const currentX = getRawAmount(position.positionData.totalXAmount) / Math.pow(10, tokenXDecimals);
@@ -330,6 +408,7 @@ async function claimAllRewardsByPosition() {
         // SOL 价格通过 fetchPrice.ts 的方法实时获取（字符串转 number）
         const solPriceStr = await fetchOkxLatestPriceFromModule(solMint);
         const solUsdPrice = solPriceStr ? Number(solPriceStr) : undefined;
+        solUsdForFees = solUsdPrice;
 
         if (xUsdPrice !== undefined && solUsdPrice !== undefined) {
           const currentPositionUsd = currentX * xUsdPrice + currentY * solUsdPrice;
@@ -349,14 +428,18 @@ async function claimAllRewardsByPosition() {
           console.log(`💤 未领取费用USD价值: X=${pendingUsdX.toFixed(6)}, Y=${pendingUsdY.toFixed(6)}, sum=${pendingUsdSum.toFixed(6)}`);
           console.log(`💰 累计已领取USD + 当前positionUSD + 未领取费用USD: ${(sumUsd).toFixed(6)}`);
           console.log(`🪙 1 SOL 的USD价格: ${solUsdPrice}`);
+          emitEvent('value', { key: 'positionValueUSD', value: sumUsd });
+          emitEvent('value', { key: 'solUSD', value: solUsdPrice });
           const threshold = 1.05 * solUsdPrice;
-          if (sumUsd >= threshold) {
+          if (resolveNoAutoExitFromArgs()) {
+            console.log('⏭️ 检测到 --no-auto-exit，跳过自动移除判断');
+          } else if (sumUsd >= threshold) {
             console.log('✅ (累计已领取USD + 当前positionUSD + 未领取费用USD) ≥ 1.05 SOL 的USD，触发移除流动性');
             // 触发移除流动性，执行内部swap
             try {
               const cmd = `npx ts-node removeLiquidity.ts --pool=${poolAddress.toString()} --position=${positionPubKey.toString()}`;
               console.log(`🛠️ 触发移除流动性: ${cmd}`);
-              const { stdout, stderr } = await execAsync(cmd, { cwd: '/Users/yqw/meteora_dlmm' });
+              const { stdout, stderr } = await execWithKey(cmd, BASE_DIR);
               if (stdout) console.log(stdout);
               if (stderr) console.error(stderr);
             } catch (e) {
@@ -400,26 +483,37 @@ async function claimAllRewardsByPosition() {
     // 使用 data/prices/<ca>.json 的最新价格计算 X 费用价值
     const caForX = readTokenContractAddressFromPoolJson(poolAddress.toString());
     const latestXPrice = caForX ? readUsdPriceFromCache(caForX) : undefined;
-    if (latestXPrice === undefined) {
+    const forceClaim = resolveForceClaimFromArgs();
+    const hasPendingFees = !claimableFeeX.isZero() || !claimableFeeY.isZero();
+    if (latestXPrice === undefined && !forceClaim) {
       console.log('⚠️ 未找到 X 的本地最新价格(data/prices/<ca>.json)，跳过领取');
       return;
     }
-    const feeValue = actualClaimableFeeX * latestXPrice;
+    const feeValue = actualClaimableFeeX * (latestXPrice ?? 0);
     console.log(`${xTokenName}费用价值 (${xTokenName} * latestPrice):`, feeValue);
 
-    // 上方止盈判断处已输出“未领取费用USD价值”，此处不再重复打印
-    
-    // 判断是否领取（只判断 X 费用价值，SOL 费用不判断）
-    if (feeValue > 1) {
-      console.log(`✅ ${xTokenName}费用价值大于 1，继续领取...`);
+    // 未领取手续费合计（SOL 价格缺失时只计 X），供 Go 端按池记录
+    const pendingUsd = feeValue + (solUsdForFees !== undefined ? actualClaimableFeeY * solUsdForFees : 0);
+    emitEvent('value', { key: 'pendingFeesUSD', value: pendingUsd });
+
+    // 判断是否领取：达到 --min-claim-usd，或 --force-claim 且有未领取手续费
+    const minClaimUsd = resolveMinClaimUsdFromArgs();
+    if (forceClaim && hasPendingFees) {
+      console.log(`✅ 已超过最长领取间隔，未领取手续费 ${pendingUsd.toFixed(6)} USD，强制领取...`);
+    } else if (pendingUsd >= minClaimUsd && hasPendingFees) {
+      console.log(`✅ 未领取手续费 ${pendingUsd.toFixed(6)} USD ≥ ${minClaimUsd}，继续领取...`);
     } else {
-      console.log(`❌ ${xTokenName}费用价值小于等于 1，跳过领取`);
+      console.log(`❌ 未领取手续费 ${pendingUsd.toFixed(6)} USD 未达到 ${minClaimUsd}，跳过领取`);
       return;
     }
 
     // 4. 准备用户密钥对
     let userKeypair: Keypair;
-    if (process.env.PRIVATE_KEY_ENCRYPTED === 'true') {
+    const pipedKey = pipedPrivateKey();
+    if (pipedKey !== undefined) {
+      userKeypair = Keypair.fromSecretKey(bs58.decode(pipedKey));
+      console.log('✅ 已读取主程序传入的私钥');
+    } else if (process.env.PRIVATE_KEY_ENCRYPTED === 'true') {
       if (!process.env.PRIVATE_KEY_PASSWORD) {
         throw new Error('使用加密私钥时，必须设置PRIVATE_KEY_PASSWORD环境变量');
       }
@@ -460,23 +554,33 @@ async function claimAllRewardsByPosition() {
 
     console.log(`生成了 ${transactions.length} 个交易`);
 
-    // 6. 依次签名并发送
+    // 6. 依次签名并发送（累计交易手续费，供 Go 端本轮领取汇总）
+    let feeLamports = 0;
     for (let i = 0; i < transactions.length; i++) {
       const transaction = transactions[i];
       console.log(`执行交易 ${i + 1}/${transactions.length}...`);
 
+      applyComputeUnitPrice(transaction);
       transaction.sign(userKeypair as any);
       const versionedTransaction = new VersionedTransaction(transaction.compileMessage());
       versionedTransaction.sign([userKeypair as any]);
 
+      const fee = await connection.getFeeForMessage(versionedTransaction.message).catch(() => null);
+      feeLamports += fee?.value ?? 0;
+
       const txHash = await withRetry(() => connection.sendTransaction(versionedTransaction), 'connection.sendTransaction');
       console.log(`交易 ${i + 1} 哈希:`, txHash);
+      emitEvent('signature', { signature: txHash, action: 'claim' });
 
       await withRetry(() => connection.getSignatureStatus(txHash, { searchTransactionHistory: true }), 'connection.getSignatureStatus');
       console.log(`交易 ${i + 1} 已确认`);
     }
 
     console.log('✅ 领取完成');
+    emitEvent('claimed', { token: dlmmPool.lbPair.tokenXMint.toString(), amount: String(actualClaimableFeeX) });
+    emitEvent('claimed', { token: dlmmPool.lbPair.tokenYMint.toString(), amount: String(actualClaimableFeeY) });
+    emitEvent('value', { key: 'feeSOL', value: feeLamports / 1e9 });
+    emitEvent('status', { status: 'ok' });
     
     // 领取成功后智能等待代币到账，然后执行 jupSwap
     const ca = readTokenContractAddressFromPoolJson(poolAddress.toString());
@@ -489,6 +593,7 @@ async function claimAllRewardsByPosition() {
     
   } catch (error) {
     console.error('错误:', error instanceof Error ? error.message : String(error));
+    emitEvent('error', { code: 'CLAIM_FAILED', message: error instanceof Error ? error.message : String(error) });
   }
 }
 
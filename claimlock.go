@@ -0,0 +1,33 @@
+package main
+
+import "sync"
+
+var (
+	poolClaimLocksMutex sync.Mutex
+	poolClaimLocks      = map[string]*sync.Mutex{} // 池地址 -> 领取互斥（队列、API 与演练等入口共用）
+)
+
+// poolClaimLock 池的领取互斥：同一池的领取脚本不会同时执行两次
+func poolClaimLock(poolAddress string) *sync.Mutex {
+	poolClaimLocksMutex.Lock()
+	defer poolClaimLocksMutex.Unlock()
+	m := poolClaimLocks[poolAddress]
+	if m == nil {
+		m = &sync.Mutex{}
+		poolClaimLocks[poolAddress] = m
+	}
+	return m
+}
+
+// claimInFlight 池的上一次领取仍在排队或执行中
+func claimInFlight(poolAddress string) bool {
+	if jobActive(jobClaim, poolAddress) {
+		return true
+	}
+	m := poolClaimLock(poolAddress)
+	if !m.TryLock() {
+		return true
+	}
+	m.Unlock()
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClaimPolicyConfig 按仓位累计的未领取手续费决定是否发送领取交易
+type ClaimPolicyConfig struct {
+	MinPendingUSD      float64 `json:"minPendingUSD"`      // 未领取手续费（X + SOL，USD）达到该值才领取
+	MaxIntervalMinutes float64 `json:"maxIntervalMinutes"` // 距上次领取超过该时间时，只要有未领取手续费就领取；0 表示不强制
+	SkipEmpty          bool    `json:"skipEmpty"`          // 每轮领取前批量读取仓位账户，没有未领取手续费与奖励的仓位不执行领取脚本
+}
+
+// ClaimCheck 一个仓位的领取检查记录（data/state/claim_checks.json，键为仓位地址）
+type ClaimCheck struct {
+	PoolAddress   string  `json:"poolAddress"`
+	FirstSeenAt   string  `json:"firstSeenAt"`           // 首次检查时间，尚未领取过时作为最长间隔的起点
+	LastClaimAt   string  `json:"lastClaimAt,omitempty"` // 最近一次实际领取
+	CheckedAt     string  `json:"checkedAt"`
+	PendingUSD    float64 `json:"pendingUSD"` // 最近一次检查时的未领取手续费
+	SkippedChecks int     `json:"skippedChecks"`
+}
+
+// 超过该时间未检查的记录视为仓位已关闭，保存时清理
+const claimCheckRetention = 7 * 24 * time.Hour
+
+var claimCheckMutex sync.Mutex
+
+func (c ClaimPolicyConfig) validate() error {
+	if c.MinPendingUSD < 0 {
+		return fmt.Errorf("claimPolicy.minPendingUSD 不能为负数")
+	}
+	if c.MaxIntervalMinutes < 0 {
+		return fmt.Errorf("claimPolicy.maxIntervalMinutes 不能为负数")
+	}
+	return nil
+}
+
+// claimPolicyArgs 领取脚本的门槛参数：--min-claim-usd（池配置覆盖优先），距上次领取超过 maxIntervalMinutes 时加 --force-claim
+func claimPolicyArgs(poolAddress, positionAddress string) []string {
+	cfg := currentConfig().ClaimPolicy
+	args := []string{"--min-claim-usd=" + strconv.FormatFloat(poolMinPendingUSD(poolAddress), 'f', -1, 64)}
+	if cfg.MaxIntervalMinutes <= 0 {
+		return args
+	}
+	claimCheckMutex.Lock()
+	defer claimCheckMutex.Unlock()
+	checks := map[string]ClaimCheck{}
+	if err := loadStateFile("claim_checks", &checks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	c, ok := checks[positionAddress]
+	if !ok {
+		return args
+	}
+	since := c.LastClaimAt
+	if since == "" {
+		since = c.FirstSeenAt
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil && time.Since(t) >= time.Duration(cfg.MaxIntervalMinutes*float64(time.Minute)) {
+		args = append(args, "--force-claim")
+	}
+	return args
+}
+
+// noteClaimCheck 记录领取脚本的结果：未领取手续费、是否实际领取（脚本失败时不更新）
+func noteClaimCheck(poolAddress, positionAddress string, out []byte, err error) {
+	if err != nil || positionAddress == "" {
+		return
+	}
+	o := decodeScriptOutput(out)
+	pending, hasPending := o.Value("pendingFeesUSD", "")
+	claimed := len(o.Claimed()) > 0 || strings.Contains(o.Raw, "✅ 领取完成")
+
+	claimCheckMutex.Lock()
+	defer claimCheckMutex.Unlock()
+	checks := map[string]ClaimCheck{}
+	if err := loadStateFile("claim_checks", &checks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	now := time.Now()
+	c, ok := checks[positionAddress]
+	if !ok {
+		c = ClaimCheck{PoolAddress: poolAddress, FirstSeenAt: now.Format(time.RFC3339)}
+	}
+	c.CheckedAt = now.Format(time.RFC3339)
+	if hasPending {
+		c.PendingUSD = pending
+	}
+	if claimed {
+		c.LastClaimAt = c.CheckedAt
+		c.PendingUSD = 0
+		c.SkippedChecks = 0
+	} else {
+		c.SkippedChecks++
+		if hasPending {
+			logOutput("⏭️ 未领取手续费 %.4f USD 未达到门槛 %g USD，暂不领取: %s\n", pending, poolMinPendingUSD(poolAddress), positionAddress)
+		}
+	}
+	checks[positionAddress] = c
+
+	for addr, v := range checks {
+		if t, err := time.Parse(time.RFC3339, v.CheckedAt); err == nil && now.Sub(t) > claimCheckRetention {
+			delete(checks, addr)
+		}
+	}
+	if err := saveStateFile("claim_checks", checks); err != nil {
+		logOutput("❌ 保存领取检查记录失败: %v\n", err)
+	}
+}
+
+// noteClaimEmpty 批量读取确认仓位没有未领取手续费与奖励，本轮不执行领取脚本
+func noteClaimEmpty(poolAddress, positionAddress string) {
+	claimCheckMutex.Lock()
+	defer claimCheckMutex.Unlock()
+	checks := map[string]ClaimCheck{}
+	if err := loadStateFile("claim_checks", &checks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	now := time.Now().Format(time.RFC3339)
+	c, ok := checks[positionAddress]
+	if !ok {
+		c = ClaimCheck{PoolAddress: poolAddress, FirstSeenAt: now}
+	}
+	c.CheckedAt = now
+	c.PendingUSD = 0
+	c.SkippedChecks++
+	checks[positionAddress] = c
+	if err := saveStateFile("claim_checks", checks); err != nil {
+		logOutput("❌ 保存领取检查记录失败: %v\n", err)
+	}
+}
+
+// 各仓位的领取检查记录
+func listClaimChecks() map[string]ClaimCheck {
+	claimCheckMutex.Lock()
+	defer claimCheckMutex.Unlock()
+	checks := map[string]ClaimCheck{}
+	if err := loadStateFile("claim_checks", &checks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return checks
+}
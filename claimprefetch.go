@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DLMM 程序与账户布局（PositionV2、BinArray）
+const (
+	dlmmProgram = "LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo"
+
+	positionV2Length       = 8120
+	positionLbPairOffset   = 8
+	positionSharesOffset   = 72   // liquidityShares [u128; 70]
+	positionRewardsOffset  = 1192 // rewardInfos [{rewardPerTokenCompletes [u128; 2], rewardPendings [u64; 2]}; 70]
+	positionFeesOffset     = 4552 // feeInfos [{feeXPerTokenComplete u128, feeYPerTokenComplete u128, feeXPending u64, feeYPending u64}; 70]
+	positionLowerBinOffset = 7912
+	positionUpperBinOffset = 7916
+	positionBinInfoSize    = 48
+
+	binArrayBinsOffset  = 56 // discriminator(8) + index(8) + version/padding(8) + lbPair(32)
+	binArrayBinSize     = 144
+	binArrayRewardPer   = 48 // Bin.rewardPerTokenStored [u128; 2]
+	binArrayFeeXPer     = 80 // Bin.feeAmountXPerTokenStored
+	binArrayFeeYPer     = 96 // Bin.feeAmountYPerTokenStored
+	binsPerArray        = 70
+	multipleAccountsMax = 100 // getMultipleAccounts 单次最多账户数
+)
+
+// PendingClaim 仓位账户中按 bin 累计的未领取手续费与奖励（代币最小单位）
+type PendingClaim struct {
+	FeeX    *big.Int
+	FeeY    *big.Int
+	Rewards [2]*big.Int
+}
+
+func (p PendingClaim) empty() bool {
+	return p.FeeX.Sign() == 0 && p.FeeY.Sign() == 0 && p.Rewards[0].Sign() == 0 && p.Rewards[1].Sign() == 0
+}
+
+// prefetchPendingClaims 每轮领取前批量读取全部仓位账户与所需的 bin array（getMultipleAccounts），
+// 计算各仓位的未领取手续费与奖励。读取或解析失败的仓位不在结果中（照常执行领取脚本）
+func prefetchPendingClaims(positions []string) map[string]PendingClaim {
+	result := map[string]PendingClaim{}
+	if len(positions) == 0 || len(rpcEndpoints()) == 0 {
+		return result
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+	defer cancel()
+
+	accounts, err := multipleAccountData(ctx, positions)
+	if err != nil {
+		logWarn("⚠️ 批量读取仓位账户失败，本轮照常领取", "positions", len(positions), "error", err)
+		return result
+	}
+	parsed := map[string]*positionAccount{}
+	binArrays := map[string]bool{}
+	var binArrayList []string
+	for _, addr := range positions {
+		p := parsePositionAccount(accounts[addr])
+		if p == nil {
+			continue
+		}
+		parsed[addr] = p
+		for _, key := range p.binArrays() {
+			if !binArrays[key] {
+				binArrays[key] = true
+				binArrayList = append(binArrayList, key)
+			}
+		}
+	}
+	bins, err := multipleAccountData(ctx, binArrayList)
+	if err != nil {
+		logWarn("⚠️ 批量读取 bin array 失败，本轮照常领取", "binArrays", len(binArrayList), "error", err)
+		return result
+	}
+
+	for addr, p := range parsed {
+		if pending, ok := p.pending(bins); ok {
+			result[addr] = pending
+		}
+	}
+	return result
+}
+
+// positionAccount 解析后的 PositionV2 账户
+type positionAccount struct {
+	lbPair       []byte
+	lower, upper int32
+	data         []byte
+}
+
+// parsePositionAccount 解析 PositionV2 账户；长度不符或超过一个 bin array 宽度（70 个 bin）时返回 nil
+func parsePositionAccount(data []byte) *positionAccount {
+	if len(data) != positionV2Length {
+		return nil
+	}
+	p := &positionAccount{
+		lbPair: data[positionLbPairOffset : positionLbPairOffset+32],
+		lower:  int32(binary.LittleEndian.Uint32(data[positionLowerBinOffset:])),
+		upper:  int32(binary.LittleEndian.Uint32(data[positionUpperBinOffset:])),
+		data:   data,
+	}
+	if p.upper < p.lower || p.upper-p.lower >= binsPerArray {
+		return nil
+	}
+	return p
+}
+
+// binArrays 仓位范围覆盖的 bin array 地址
+func (p *positionAccount) binArrays() []string {
+	var keys []string
+	for idx := binArrayIndex(p.lower); idx <= binArrayIndex(p.upper); idx++ {
+		if key := binArrayAddress(p.lbPair, idx); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// pending 按 bin 累计未领取的手续费与奖励；bins 缺少所需的 bin array 时返回 false
+func (p *positionAccount) pending(bins map[string][]byte) (PendingClaim, bool) {
+	pending := PendingClaim{FeeX: new(big.Int), FeeY: new(big.Int), Rewards: [2]*big.Int{new(big.Int), new(big.Int)}}
+	for i := int32(0); i <= p.upper-p.lower; i++ {
+		binID := p.lower + i
+		arr := bins[binArrayAddress(p.lbPair, binArrayIndex(binID))]
+		off := binArrayBinsOffset + int(binID-binArrayIndex(binID)*binsPerArray)*binArrayBinSize
+		if len(arr) < off+binArrayBinSize {
+			return pending, false
+		}
+		bin := arr[off : off+binArrayBinSize]
+		share := u128(p.data, positionSharesOffset+int(i)*16)
+		share.Rsh(share, 64)
+		fee := p.data[positionFeesOffset+int(i)*positionBinInfoSize:]
+		reward := p.data[positionRewardsOffset+int(i)*positionBinInfoSize:]
+		pending.FeeX.Add(pending.FeeX, accrued(share, u128(bin, binArrayFeeXPer), u128(fee, 0), u64(fee, 32)))
+		pending.FeeY.Add(pending.FeeY, accrued(share, u128(bin, binArrayFeeYPer), u128(fee, 16), u64(fee, 40)))
+		for r := 0; r < 2; r++ {
+			pending.Rewards[r].Add(pending.Rewards[r], accrued(share, u128(bin, binArrayRewardPer+r*16), u128(reward, r*16), u64(reward, 32+r*8)))
+		}
+	}
+	return pending, true
+}
+
+// 已记录的未领取数量 + 份额 × (bin 当前累计值 - 仓位上次结算值) >> 64
+func accrued(share, stored, completed, recorded *big.Int) *big.Int {
+	v := new(big.Int).Sub(stored, completed)
+	if v.Sign() < 0 {
+		v.SetInt64(0)
+	}
+	v.Mul(v, share)
+	v.Rsh(v, 64)
+	return v.Add(v, recorded)
+}
+
+func u128(b []byte, off int) *big.Int {
+	le := b[off : off+16]
+	be := make([]byte, 16)
+	for i := range le {
+		be[15-i] = le[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+func u64(b []byte, off int) *big.Int {
+	return new(big.Int).SetUint64(binary.LittleEndian.Uint64(b[off:]))
+}
+
+// bin 所在的 bin array 序号（向下取整）
+func binArrayIndex(binID int32) int32 {
+	idx := binID / binsPerArray
+	if binID < 0 && binID%binsPerArray != 0 {
+		idx--
+	}
+	return idx
+}
+
+var (
+	binArrayAddressMutex sync.Mutex
+	binArrayAddressCache = map[string]string{} // lbPair/index -> bin array 地址（账户订阅的每次通知都要计算）
+)
+
+// bin array 账户地址：PDA ["bin_array", lbPair, index(i64 LE)]
+func binArrayAddress(lbPair []byte, index int32) string {
+	cacheKey := string(lbPair) + "/" + strconv.Itoa(int(index))
+	binArrayAddressMutex.Lock()
+	addr, ok := binArrayAddressCache[cacheKey]
+	binArrayAddressMutex.Unlock()
+	if ok {
+		return addr
+	}
+	program, err := decodeBase58(dlmmProgram)
+	if err != nil {
+		return ""
+	}
+	idx := make([]byte, 8)
+	binary.LittleEndian.PutUint64(idx, uint64(int64(index)))
+	pda, err := findProgramAddress([][]byte{[]byte("bin_array"), lbPair, idx}, program)
+	if err != nil {
+		return ""
+	}
+	addr = encodeBase58(pda)
+	binArrayAddressMutex.Lock()
+	binArrayAddressCache[cacheKey] = addr
+	binArrayAddressMutex.Unlock()
+	return addr
+}
+
+// multipleAccountData 批量读取账户数据（每次最多 100 个），不存在的账户不在结果中
+func multipleAccountData(ctx context.Context, addresses []string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	for start := 0; start < len(addresses); start += multipleAccountsMax {
+		chunk := addresses[start:min(start+multipleAccountsMax, len(addresses))]
+		var resp struct {
+			Value []*struct {
+				Data []string `json:"data"`
+			} `json:"value"`
+		}
+		if err := solanaRPC(ctx, "getMultipleAccounts", []interface{}{chunk, map[string]string{"encoding": "base64"}}, &resp); err != nil {
+			return nil, err
+		}
+		for i, v := range resp.Value {
+			if i >= len(chunk) || v == nil || len(v.Data) == 0 {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(v.Data[0])
+			if err != nil {
+				return nil, err
+			}
+			result[chunk[i]] = data
+		}
+	}
+	return result, nil
+}
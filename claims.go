@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClaimRecord 一次实际发生的领取：到账的代币数量与交易签名
+type ClaimRecord struct {
+	At         string             `json:"at"`
+	Position   string             `json:"position,omitempty"`
+	Amounts    map[string]float64 `json:"amounts"`            // 代币 mint -> 到账数量
+	ValueUSD   float64            `json:"valueUSD,omitempty"` // 领取前脚本估算的未领取手续费价值
+	ValueSOL   float64            `json:"valueSOL,omitempty"` // 按领取时的 SOL 价格折算
+	FeeSOL     float64            `json:"feeSOL,omitempty"`   // 领取交易的手续费
+	Signatures []string           `json:"signatures,omitempty"`
+}
+
+// PoolClaims 单个池的领取记录与累计（data/state/claims.json: pool -> 记录）
+type PoolClaims struct {
+	PoolAddress  string             `json:"poolAddress"`
+	TokenAddress string             `json:"ca,omitempty"`
+	Count        int                `json:"count"`
+	Totals       map[string]float64 `json:"totals"` // 代币 mint -> 累计到账数量
+	TotalUSD     float64            `json:"totalUSD"`
+	TotalSOL     float64            `json:"totalSOL"`
+	FeeSOL       float64            `json:"feeSOL"`
+	FirstAt      string             `json:"firstAt"`
+	LastAt       string             `json:"lastAt"`
+	Claims       []ClaimRecord      `json:"claims,omitempty"` // 最近 maxClaimRecords 次
+}
+
+// 每个池保留的领取明细条数（累计值不受影响）
+const maxClaimRecords = 200
+
+var claimsMutex sync.Mutex
+
+func loadClaimRecords() map[string]*PoolClaims {
+	records := map[string]*PoolClaims{}
+	if err := loadStateFile("claims", &records); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return records
+}
+
+// recordClaimResult 解析领取脚本的输出，实际领取时记录到账数量与签名，并累计到该池的盈亏台账（脚本失败或未达领取门槛时不记录）
+func recordClaimResult(poolAddress, positionAddress string, out []byte, err error) {
+	if err != nil {
+		return
+	}
+	o := decodeScriptOutput(out)
+	if !claimOutputClaimed(o) {
+		return
+	}
+	rec := ClaimRecord{At: time.Now().Format(time.RFC3339), Position: positionAddress, Amounts: o.Claimed(), Signatures: o.Signatures()}
+	rec.ValueUSD, _ = o.Value("pendingFeesUSD", "")
+	rec.FeeSOL, _ = o.Value("feeSOL", "")
+	solUSD, ok := o.Value("solUSD", "")
+	if !ok {
+		pnlMutex.Lock()
+		solUSD = lastSolUSD
+		pnlMutex.Unlock()
+	}
+	if solUSD > 0 {
+		rec.ValueSOL = rec.ValueUSD / solUSD
+	}
+
+	claimsMutex.Lock()
+	records := loadClaimRecords()
+	pc := records[poolAddress]
+	if pc == nil {
+		pc = &PoolClaims{PoolAddress: poolAddress, TokenAddress: readTokenContractAddressFromPoolJSON(poolAddress), Totals: map[string]float64{}, FirstAt: rec.At}
+		records[poolAddress] = pc
+	}
+	pc.Count++
+	for token, amount := range rec.Amounts {
+		pc.Totals[token] += amount
+	}
+	pc.TotalUSD += rec.ValueUSD
+	pc.TotalSOL += rec.ValueSOL
+	pc.FeeSOL += rec.FeeSOL
+	pc.LastAt = rec.At
+	pc.Claims = append(pc.Claims, rec)
+	if len(pc.Claims) > maxClaimRecords {
+		pc.Claims = pc.Claims[len(pc.Claims)-maxClaimRecords:]
+	}
+	if err := saveStateFile("claims", records); err != nil {
+		logOutput("❌ 保存领取记录失败: %v\n", err)
+	}
+	claimsMutex.Unlock()
+
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil || p.ClosedAt != "" {
+			return nil
+		}
+		if p.ClaimedTokens == nil {
+			p.ClaimedTokens = map[string]float64{}
+		}
+		for token, amount := range rec.Amounts {
+			p.ClaimedTokens[token] += amount
+		}
+		p.Claims++
+		p.ClaimedFeesUSD += rec.ValueUSD
+		p.ClaimedFeesSOL += rec.ValueSOL
+		return p
+	})
+	logInfo("🧾 已记录领取", "pool", poolAddress, "position", positionAddress, "amounts", formatTokenAmounts(rec.Amounts),
+		"valueUSD", rec.ValueUSD, "signatures", len(rec.Signatures))
+}
+
+// listPoolClaims 各池的领取累计（不含明细，按最近领取时间倒序，GET /claims）
+func listPoolClaims() []PoolClaims {
+	claimsMutex.Lock()
+	records := loadClaimRecords()
+	claimsMutex.Unlock()
+	result := make([]PoolClaims, 0, len(records))
+	for _, pc := range records {
+		s := *pc
+		s.Claims = nil
+		result = append(result, s)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].LastAt > result[b].LastAt })
+	return result
+}
+
+// poolClaims 单个池的领取记录（含明细，GET /claims/<pool>）
+func poolClaims(poolAddress string) (*PoolClaims, bool) {
+	claimsMutex.Lock()
+	defer claimsMutex.Unlock()
+	pc, ok := loadClaimRecords()[poolAddress]
+	return pc, ok
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClaimRoundSummary 一轮全局领取的汇总（data/state/claim_round.json 保存最近一轮）
+type ClaimRoundSummary struct {
+	StartedAt       string             `json:"startedAt"`
+	FinishedAt      string             `json:"finishedAt,omitempty"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Claimed         int                `json:"claimed"` // 实际发送了领取交易的池
+	Skipped         int                `json:"skipped"` // 名单策略跳过或未达领取门槛的池
+	Failed          int                `json:"failed"`
+	Earned          map[string]float64 `json:"earned"` // 代币 mint -> 领取数量
+	FeeSOL          float64            `json:"feeSOL"` // 领取交易手续费
+	Instance        string             `json:"instance,omitempty"`
+	Environment     string             `json:"environment,omitempty"`
+}
+
+// 单个池在本轮中的结果
+const (
+	claimPoolClaimed = "claimed"
+	claimPoolSkipped = "skipped"
+	claimPoolFailed  = "failed"
+)
+
+var (
+	claimRoundMutex sync.Mutex
+	claimRound      *claimRoundState // 进行中的一轮（为空表示不在全局领取中，如 API 手动领取）
+)
+
+type claimRoundState struct {
+	started time.Time
+	pools   map[string]string
+	earned  map[string]float64
+	feeSOL  float64
+}
+
+// 开始一轮全局领取
+func beginClaimRound() {
+	claimRoundMutex.Lock()
+	defer claimRoundMutex.Unlock()
+	claimRound = &claimRoundState{started: time.Now(), pools: map[string]string{}, earned: map[string]float64{}}
+}
+
+// 记录一次领取脚本执行（主仓位与阶梯档位都会调用，按池合并：失败 > 领取 > 跳过）
+func noteClaimOutput(poolAddress string, out []byte, err error) {
+	tallyClaim(decodeScriptOutput(out), err)
+	publishClaim(poolAddress, decodeScriptOutput(out), err)
+
+	claimRoundMutex.Lock()
+	defer claimRoundMutex.Unlock()
+	if claimRound == nil {
+		return
+	}
+	result := claimPoolSkipped
+	if err != nil {
+		result = claimPoolFailed
+	} else {
+		o := decodeScriptOutput(out)
+		for token, amount := range o.Claimed() {
+			claimRound.earned[token] += amount
+		}
+		if fee, ok := o.Value("feeSOL", ""); ok {
+			claimRound.feeSOL += fee
+		}
+		if claimOutputClaimed(o) {
+			result = claimPoolClaimed
+		}
+	}
+	switch prev := claimRound.pools[poolAddress]; {
+	case prev == claimPoolFailed, prev == claimPoolClaimed && result == claimPoolSkipped:
+	default:
+		claimRound.pools[poolAddress] = result
+	}
+}
+
+// 领取脚本是否实际领取（未达领取门槛时脚本正常结束但不领取）；旧脚本没有 claimed 事件，按完成日志判断
+func claimOutputClaimed(o ScriptOutput) bool {
+	return len(o.Claimed()) > 0 || strings.Contains(o.Raw, "✅ 领取完成")
+}
+
+// 记录被跳过的池（名单策略等）
+func noteClaimSkipped(poolAddress string) {
+	claimRoundMutex.Lock()
+	defer claimRoundMutex.Unlock()
+	if claimRound != nil && claimRound.pools[poolAddress] == "" {
+		claimRound.pools[poolAddress] = claimPoolSkipped
+	}
+}
+
+// 结束一轮全局领取：输出单行汇总并保存
+func finishClaimRound() ClaimRoundSummary {
+	claimRoundMutex.Lock()
+	round := claimRound
+	claimRound = nil
+	claimRoundMutex.Unlock()
+	if round == nil {
+		return ClaimRoundSummary{}
+	}
+
+	now := time.Now()
+	s := ClaimRoundSummary{
+		StartedAt:       round.started.Format(time.RFC3339),
+		FinishedAt:      now.Format(time.RFC3339),
+		DurationSeconds: now.Sub(round.started).Seconds(),
+		Earned:          round.earned,
+		FeeSOL:          round.feeSOL,
+		Instance:        deployInstance,
+		Environment:     deployEnvironment,
+	}
+	for _, result := range round.pools {
+		switch result {
+		case claimPoolClaimed:
+			s.Claimed++
+		case claimPoolFailed:
+			s.Failed++
+		default:
+			s.Skipped++
+		}
+	}
+	logOutput("📋 本轮领取汇总: %s\n", s.line())
+	if err := saveStateFile("claim_round", s); err != nil {
+		logOutput("❌ 保存领取汇总失败: %v\n", err)
+	}
+	recordClaimHistory(s)
+	return s
+}
+
+// 单行汇总，如 "领取 3 池，跳过 5 池，失败 0 池，收益 So11…=0.012 / Abc…=1520，手续费 0.000015 SOL，耗时 12.3s"
+func (s ClaimRoundSummary) line() string {
+	tokens := make([]string, 0, len(s.Earned))
+	for token := range s.Earned {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	earned := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		earned = append(earned, fmt.Sprintf("%s=%g", token, s.Earned[token]))
+	}
+	earnedText := "无"
+	if len(earned) > 0 {
+		earnedText = strings.Join(earned, " / ")
+	}
+	return fmt.Sprintf("领取 %d 池，跳过 %d 池，失败 %d 池，收益 %s，手续费 %.6f SOL，耗时 %.1fs",
+		s.Claimed, s.Skipped, s.Failed, earnedText, s.FeeSOL, s.DurationSeconds)
+}
+
+// 最近一轮领取汇总（尚无记录时返回 nil）
+func lastClaimRound() *ClaimRoundSummary {
+	var s ClaimRoundSummary
+	if err := loadStateFile("claim_round", &s); err != nil {
+		logOutput("⚠️ %v\n", err)
+		return nil
+	}
+	if s.StartedAt == "" {
+		return nil
+	}
+	return &s
+}
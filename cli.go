@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// cliCommand 子命令：与守护进程共用配置、状态与执行路径，用于手动的一次性操作
+type cliCommand struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var cliCommands []cliCommand
+
+func init() {
+	cliCommands = []cliCommand{
+		{"run", "监听信号与数据目录并执行定时任务（默认，不带子命令时即为 run）", runDaemon},
+		{"claim", "领取指定池的手续费与奖励：claim --pool <addr>", cmdClaim},
+		{"swap", "兑换指定代币：swap --token <ca> [--output <mint>] [--wallet <name>]", cmdSwap},
+		{"price", "获取代币价格并按价格检查风控与生命周期：price --token <ca> [--pool <addr>]", cmdPrice},
+		{"positions", "仓位生命周期记录：positions list [--state open|closed|<state>] [--json]", cmdPositions},
+		{"state", "状态迁移：state migrate --from <positions.csv> [--overwrite]", cmdState},
+		{"backtest", "按价格历史回放退出规则：backtest [--data <path>] [--days N] [--report <path>]", cmdBacktest},
+		{"drill", "按当前配置推演故障场景的告警与暂停：drill [--scenario rpc_down,wallet_low,sidecar_crash] [--json]", cmdDrill},
+		{"keys", "签名私钥：keys encrypt --out <file> 生成加密私钥文件；keys check 核对各钱包的私钥来源与地址", cmdKeys},
+		{"export", "导出仓位、兑换、领取、台账与价格历史：export [--datasets positions,prices] [--format csv|parquet] [--dir <path>]", cmdExport},
+		{"harness", "集成测试：临时目录中以回放的脚本输出跑通 CSV → 开仓 → 领取 → 兑换：harness [--rows 3] [--fixtures <dir>] [--timeout 2m] [--keep] [--json] [--inject-failures 0.1]", cmdHarness},
+		{"signal", "手动注入信号或回放 CSV 历史行（与实时信号相同的流程）：signal inject --pool <addr> --token <ca> | signal replay --from-line N", cmdSignal},
+		{"panic", "紧急平仓：拉黑代币、取消该池排队中的任务并以最高优先级领取、平仓、兑换：panic close --pool <addr> [--url <api>] [--local]", cmdPanic},
+		{"tui", "终端监控运行中的进程（池、仓位、任务、最近错误），可手动领取、平仓、拉黑：tui [--url <api>] [--interval 2s]", cmdTUI},
+	}
+}
+
+func main() {
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage()
+		return
+	}
+	for _, c := range cliCommands {
+		if c.name == name {
+			c.run(args)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知的子命令 %q\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "用法: %s <子命令> [参数]\n\n子命令:\n", os.Args[0])
+	for _, c := range cliCommands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\n查看子命令的参数: %s <子命令> -h\n", os.Args[0])
+}
+
+// commonFlags 各子命令共用的参数
+type commonFlags struct {
+	config  *string
+	mode    *string
+	dryRun  *bool
+	baseDir *string
+	dataDir *string
+
+	injectFailures *float64
+	injectLatency  *time.Duration
+}
+
+func newCommandFlags(name string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s %s [参数]\n", os.Args[0], name)
+		fs.PrintDefaults()
+	}
+	return fs, &commonFlags{
+		config:  fs.String("config", "", "配置文件路径（默认 <程序目录>/config.json）"),
+		mode:    fs.String("mode", "", "运行模式: live 或 price-only（覆盖配置文件）"),
+		dryRun:  fs.Bool("dry-run", false, "模拟运行：记录将执行的命令而不发送任何交易"),
+		baseDir: fs.String("base-dir", "", "程序目录：TS 脚本、.env 与 config.json 所在目录（默认 $"+envBaseDir+" 或自动判断）"),
+		dataDir: fs.String("data-dir", "", "数据目录：池文件、状态、日志与价格历史（默认 $"+envDataDir+"、<程序目录>/data 或 XDG 数据目录）"),
+
+		injectFailures: fs.Float64("inject-failures", 0, "故障注入（测试用）：外部命令与 RPC 请求随机失败的概率（0~1）"),
+		injectLatency:  fs.Duration("inject-latency", 0, "故障注入（测试用）：外部命令与 RPC 请求发出前随机延迟 0~该时长（如 5s）"),
+	}
+}
+
+// initApp 加载配置并初始化日志、告警与持久化状态（与守护进程相同），返回退出前的清理函数
+func initApp(f *commonFlags) func() {
+	loadAppConfig(f)
+
+	// 初始化日志系统
+	if err := initLogging(currentConfig().Logging); err != nil {
+		log.Fatalf("初始化日志系统失败: %v", err)
+	}
+	if err := initNotifier(); err != nil {
+		log.Fatalf("初始化告警系统失败: %v", err)
+	}
+	logChaos()
+	loadFreezeState()
+	loadKillSwitchState()
+	loadProfileState()
+	loadProcessedMarkers()
+	loadJobJournal()
+	return closeLogging
+}
+
+// loadAppConfig 只加载配置（只读状态的子命令不初始化日志，输出不混入启动日志）
+func loadAppConfig(f *commonFlags) {
+	dryRunMode = *f.dryRun
+	if dryRunMode && demoMode {
+		log.Fatalf("-demo 与 -dry-run 不能同时使用")
+	}
+	if err := setChaos(*f.injectFailures, *f.injectLatency); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// 先确定程序目录与数据目录，配置中的相对路径与默认路径都基于它们
+	setPaths(*f.baseDir, *f.dataDir)
+	if *f.config == "" {
+		*f.config = defaultConfigPath()
+	}
+
+	// 加载配置
+	cfg, err := loadConfig(*f.config)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if *f.mode != "" {
+		cfg.Mode = *f.mode
+		if err := cfg.validate(); err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+	}
+	if demoMode {
+		applyDemoConfig(cfg)
+	}
+	setConfig(cfg)
+	configFilePath, configModeFlag = *f.config, *f.mode
+	appLocation, _ = parseTimezone(cfg.Timezone)
+	initDeployment(cfg)
+}
+
+// 一次性子命令的上下文：收到 SIGINT / SIGTERM 时取消进行中的外部命令
+func startCommandContext() {
+	globalCtx, globalCancel = context.WithCancel(context.Background())
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		globalCancel()
+	}()
+}
+
+func cmdClaim(args []string) {
+	fs, common := newCommandFlags("claim")
+	pool := fs.String("pool", "", "池地址（仓位地址从 data/<pool>.json 读取）")
+	fs.Parse(args)
+	if *pool == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+	if err := runClaimRewards(*pool); err != nil {
+		log.Fatalf("领取失败: %v", err)
+	}
+}
+
+func cmdSwap(args []string) {
+	fs, common := newCommandFlags("swap")
+	token := fs.String("token", "", "要卖出的代币 ca")
+	output := fs.String("output", "", "输出代币 mint（默认 SOL）")
+	wallet := fs.String("wallet", "", "使用的钱包名（默认为持有该代币的池分配的钱包）")
+	fs.Parse(args)
+	if *token == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+	if *wallet == "" {
+		if pools := findPoolsByToken(*token); len(pools) > 0 {
+			*wallet = poolWallet(pools[0])
+		}
+	} else if findWallet(*wallet) == nil {
+		log.Fatalf("未配置的钱包: %s", *wallet)
+	}
+	if err := executeJupSwapToMint(*wallet, *token, *output); err != nil {
+		log.Fatalf("兑换失败: %v", err)
+	}
+}
+
+func cmdPrice(args []string) {
+	fs, common := newCommandFlags("price")
+	token := fs.String("token", "", "代币 ca")
+	pool := fs.String("pool", "", "池地址（为空时按 ca 查找 data 目录中的池）")
+	fs.Parse(args)
+	if *token == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+	pools := []string{*pool}
+	if *pool == "" {
+		if pools = findPoolsByToken(*token); len(pools) == 0 {
+			log.Fatalf("data 目录中没有代币 %s 的池，请用 --pool 指定", *token)
+		}
+	}
+	for _, p := range pools {
+		fetchPriceForToken(p, *token)
+	}
+}
+
+func cmdPositions(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintf(os.Stderr, "用法: %s positions list [--state open|closed|<state>] [--json]\n", os.Args[0])
+		os.Exit(2)
+	}
+	fs, common := newCommandFlags("positions list")
+	state := fs.String("state", "", "按状态过滤：open（未平仓）、closed 或具体状态名，为空时全部")
+	asJSON := fs.Bool("json", false, "输出 JSON")
+	fs.Parse(args[1:])
+	loadAppConfig(common)
+
+	var records []*PositionRecord
+	for _, r := range listPositionRecords() {
+		switch *state {
+		case "":
+		case "open":
+			if r.State == positionStateClosed {
+				continue
+			}
+		default:
+			if r.State != *state {
+				continue
+			}
+		}
+		records = append(records, r)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []*PositionRecord{}
+		}
+		enc.Encode(records)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tCA\tSTATE\tMODE\tSOL\tENTRY\tLAST\tPNL%\tOPENED\tCLOSE")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%g\t%g\t%g\t%.2f\t%s\t%s\n",
+			r.PoolAddress, r.TokenAddress, r.State, r.Mode, r.SolAmount, r.EntryPrice, r.LastPrice, r.PnLPercent, r.OpenedAt, r.CloseReason)
+	}
+	w.Flush()
+}
+
+func cmdState(args []string) {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintf(os.Stderr, "用法: %s state migrate --from <positions.csv> [--overwrite]\n", os.Args[0])
+		os.Exit(2)
+	}
+	fs, common := newCommandFlags("state migrate")
+	from := fs.String("from", "", "仓位快照 CSV（默认配置 positionImport.file）")
+	overwrite := fs.Bool("overwrite", false, "覆盖已有生命周期与盈亏记录的池（默认按 positionImport.overwrite）")
+	fs.Parse(args[1:])
+	defer initApp(common)()
+	path := *from
+	if path == "" {
+		path = currentConfig().PositionImport.File
+	}
+	if path == "" {
+		log.Fatalf("请用 --from 指定仓位快照 CSV")
+	}
+	runStateMigrate(path, *overwrite || currentConfig().PositionImport.Overwrite)
+}
+
+// runStateMigrate 从仓位快照导入已有仓位并输出结果（state migrate 与旧参数 -import-positions）
+func runStateMigrate(path string, overwrite bool) {
+	result, err := runPositionImport(path, overwrite, false)
+	if err != nil {
+		log.Fatalf("导入仓位快照失败: %v", err)
+	}
+	fmt.Printf("📥 已导入 %d 个池，跳过 %d 项\n", len(result.Imported), len(result.Skipped))
+	for key, reason := range result.Skipped {
+		fmt.Printf("  - %s: %s\n", key, reason)
+	}
+}
+
+func cmdBacktest(args []string) {
+	fs, common := newCommandFlags("backtest")
+	data := fs.String("data", "", "价格数据：目录或 .jsonl / .csv 文件（默认价格历史目录）")
+	days := fs.Int("days", 0, "只回放最近 N 天的价格，0 表示全部")
+	report := fs.String("report", "", "回测报告 JSON 的写入路径（为空时只输出汇总）")
+	fs.Parse(args)
+	defer initApp(common)()
+	runBacktestCommand(*data, *days, *report)
+}
+
+// runBacktestCommand 回测并输出报告（backtest 与旧参数 -backtest）
+func runBacktestCommand(data string, days int, reportPath string) {
+	var since time.Time
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+	report, err := runBacktest(data, since)
+	if err != nil {
+		log.Fatalf("回测失败: %v", err)
+	}
+	printBacktestReport(report)
+	if reportPath != "" {
+		if err := writeBacktestReport(reportPath, report); err != nil {
+			log.Fatalf("写入回测报告失败: %v", err)
+		}
+		fmt.Printf("📝 回测报告已写入 %s\n", reportPath)
+	}
+}
+
+func cmdDrill(args []string) {
+	fs, common := newCommandFlags("drill")
+	scenario := fs.String("scenario", "", "演练场景，逗号分隔（rpc_down、wallet_low、sidecar_crash），为空时全部")
+	asJSON := fs.Bool("json", false, "输出 JSON")
+	fs.Parse(args)
+	*common.dryRun = true // 演练只读取配置，始终按模拟运行处理
+	loadAppConfig(common)
+
+	var names []string
+	for _, name := range strings.Split(*scenario, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	report, err := runDrill(names)
+	if err != nil {
+		log.Fatalf("演练失败: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return
+	}
+	printDrillReport(report)
+}
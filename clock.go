@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// ClockCheckConfig 定期与 NTP 服务器比对本机时钟，偏差超过阈值时告警
+type ClockCheckConfig struct {
+	Enabled         bool     `json:"enabled"`
+	Servers         []string `json:"servers"`         // host 或 host:port，依次尝试直到有一个成功
+	IntervalSeconds int      `json:"intervalSeconds"` // 检查间隔
+	MaxDriftMs      int      `json:"maxDriftMs"`      // 偏差告警阈值
+	TimeoutSeconds  int      `json:"timeoutSeconds"`  // 单个服务器查询超时
+}
+
+// ClockStatus 最近一次时钟检查结果
+type ClockStatus struct {
+	CheckedAt string  `json:"checkedAt,omitempty"`
+	Server    string  `json:"server,omitempty"`
+	DriftMs   float64 `json:"driftMs"` // 本机时钟 - NTP 时间，正数表示本机偏快
+	RTTMs     float64 `json:"rttMs"`
+	Exceeded  bool    `json:"exceeded"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// NTP 时间戳纪元（1900-01-01）与 Unix 纪元相差的秒数
+const ntpEpochOffset = 2208988800
+
+var (
+	clockMutex  sync.Mutex
+	clockStatus ClockStatus
+)
+
+func (c ClockCheckConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Servers) == 0 {
+		return fmt.Errorf("clockCheck.servers 不能为空")
+	}
+	if c.IntervalSeconds <= 0 || c.MaxDriftMs <= 0 || c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("clockCheck.intervalSeconds、maxDriftMs、timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+func ntpToTime(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpochOffset, nsec)
+}
+
+func timeToNTP(t time.Time, b []byte) {
+	sec := uint32(t.Unix() + ntpEpochOffset)
+	frac := uint32((int64(t.Nanosecond()) << 32) / 1e9)
+	binary.BigEndian.PutUint32(b[0:4], sec)
+	binary.BigEndian.PutUint32(b[4:8], frac)
+}
+
+// 发送一次 SNTP 请求，返回本机时钟偏差（本机 - 服务器）与往返时延
+func queryNTP(server string, timeout time.Duration) (time.Duration, time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // LI=0, VN=4, Mode=3（客户端）
+	t1 := time.Now()
+	timeToNTP(t1, req[40:48])
+	if _, err := conn.Write(req); err != nil {
+		return 0, 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, 0, err
+	}
+	if n < 48 {
+		return 0, 0, fmt.Errorf("NTP 响应长度不足: %d", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, 0, fmt.Errorf("NTP 响应模式异常: %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 || stratum > 15 {
+		return 0, 0, fmt.Errorf("NTP 服务器不可用（stratum %d）", stratum)
+	}
+	t2 := ntpToTime(resp[32:40])
+	t3 := ntpToTime(resp[40:48])
+	// 标准 NTP 计算：offset 为服务器相对本机的偏移，取反即本机偏差
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	rtt := t4.Sub(t1) - t3.Sub(t2)
+	return -offset, rtt, nil
+}
+
+// checkClockDrift 依次查询配置的服务器，记录结果并在超出阈值时告警
+func checkClockDrift() {
+	cfg := currentConfig().ClockCheck
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	status := ClockStatus{CheckedAt: time.Now().Format(time.RFC3339)}
+	var lastErr error
+	for _, server := range cfg.Servers {
+		drift, rtt, err := queryNTP(server, timeout)
+		if err != nil {
+			lastErr = err
+			logWarn("⚠️ NTP 查询失败", "server", server, "error", err)
+			continue
+		}
+		status.Server = server
+		status.DriftMs = float64(drift) / float64(time.Millisecond)
+		status.RTTMs = float64(rtt) / float64(time.Millisecond)
+		lastErr = nil
+		break
+	}
+	if lastErr != nil {
+		status.Error = lastErr.Error()
+	} else {
+		status.Exceeded = math.Abs(status.DriftMs) > float64(cfg.MaxDriftMs)
+	}
+
+	clockMutex.Lock()
+	clockStatus = status
+	clockMutex.Unlock()
+
+	if status.Error != "" {
+		return
+	}
+	fields := map[string]string{
+		"server":  status.Server,
+		"driftMs": fmt.Sprintf("%.1f", status.DriftMs),
+		"rttMs":   fmt.Sprintf("%.1f", status.RTTMs),
+	}
+	if status.Exceeded {
+		logWarn("⏱️ 本机时钟偏差超过阈值，按秒执行的定时任务与交易 blockhash 有效期可能受影响",
+			"server", status.Server, "driftMs", fields["driftMs"], "maxDriftMs", cfg.MaxDriftMs)
+		notifyKeyed(eventClockDrift, levelWarning, "clock", "本机时钟偏差过大", "请检查系统时间同步（NTP）", fields)
+		return
+	}
+	logDebug("⏱️ 时钟偏差检查", "server", status.Server, "driftMs", fields["driftMs"], "rttMs", fields["rttMs"])
+}
+
+func currentClockStatus() ClockStatus {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	return clockStatus
+}
+
+// startClockCheck 启动时检查一次，之后按间隔定期检查
+func startClockCheck() {
+	cfg := currentConfig().ClockCheck
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🕐 启动时钟偏差检查（每%v，阈值 %dms）\n", interval, cfg.MaxDriftMs)
+	checkClockDrift()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止时钟偏差检查\n")
+			return
+		case <-ticker.C:
+			checkClockDrift()
+		}
+	}
+}
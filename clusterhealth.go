@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClusterHealthConfig 比对本节点与参考节点的 slot、统计集群 TPS，节点落后或集群拥堵时暂停开仓并告警
+type ClusterHealthConfig struct {
+	Enabled         bool    `json:"enabled"`
+	IntervalSeconds int     `json:"intervalSeconds"` // 检查间隔
+	ReferenceRPCURL string  `json:"referenceRpcUrl"` // 参考节点（本节点为 walletWatch.rpcUrl），为空时不检查 slot 落后
+	MaxSlotLag      int64   `json:"maxSlotLag"`      // 本节点落后参考节点超过该 slot 数视为不健康
+	MinTPS          float64 `json:"minTps"`          // 集群 TPS 低于该值视为拥堵，0 表示不检查
+	TPSSamples      int     `json:"tpsSamples"`      // 取最近几个性能样本（每个约 60 秒）计算 TPS
+	PauseEntries    bool    `json:"pauseEntries"`    // 不健康时暂停开仓（领取、移除等不受影响）
+	RecoveryChecks  int     `json:"recoveryChecks"`  // 连续该次数检查正常后恢复开仓
+	TimeoutSeconds  int     `json:"timeoutSeconds"`
+}
+
+// ClusterHealthStatus 最近一次检查结果
+type ClusterHealthStatus struct {
+	CheckedAt     string   `json:"checkedAt,omitempty"`
+	Slot          uint64   `json:"slot"`
+	ReferenceSlot uint64   `json:"referenceSlot,omitempty"`
+	SlotLag       int64    `json:"slotLag"`
+	TPS           float64  `json:"tps"`
+	Healthy       bool     `json:"healthy"`
+	Reasons       []string `json:"reasons,omitempty"`
+	EntriesPaused bool     `json:"entriesPaused"`
+	Error         string   `json:"error,omitempty"`
+}
+
+type performanceSample struct {
+	Slot             uint64 `json:"slot"`
+	NumTransactions  uint64 `json:"numTransactions"`
+	NumSlots         uint64 `json:"numSlots"`
+	SamplePeriodSecs uint64 `json:"samplePeriodSecs"`
+}
+
+var (
+	clusterMutex        sync.Mutex
+	clusterStatus       = ClusterHealthStatus{Healthy: true}
+	clusterUnhealthy    bool // 当前处于不健康状态（已告警）
+	clusterHealthyCount int  // 不健康状态下连续正常的检查次数
+)
+
+func (c ClusterHealthConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("clusterHealth 需要 walletWatch.rpcUrl")
+	}
+	if c.IntervalSeconds <= 0 || c.TimeoutSeconds <= 0 || c.RecoveryChecks <= 0 {
+		return fmt.Errorf("clusterHealth.intervalSeconds、timeoutSeconds、recoveryChecks 必须大于0")
+	}
+	if c.ReferenceRPCURL != "" && c.MaxSlotLag <= 0 {
+		return fmt.Errorf("clusterHealth.maxSlotLag 必须大于0")
+	}
+	if c.MinTPS < 0 {
+		return fmt.Errorf("clusterHealth.minTps 不能为负数")
+	}
+	if c.MinTPS > 0 && c.TPSSamples <= 0 {
+		return fmt.Errorf("clusterHealth.tpsSamples 必须大于0")
+	}
+	return nil
+}
+
+// 检查一次节点 slot 落后与集群 TPS
+func checkClusterHealth() {
+	cfg := currentConfig().ClusterHealth
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	status := ClusterHealthStatus{CheckedAt: time.Now().Format(time.RFC3339)}
+	var reasons []string
+
+	// 本节点无法访问时同样视为不健康
+	if err := solanaRPC(ctx, "getSlot", []interface{}{}, &status.Slot); err != nil {
+		status.Error = err.Error()
+		reasons = append(reasons, "节点不可用")
+	}
+	if cfg.ReferenceRPCURL != "" && status.Slot > 0 {
+		// 参考节点失败不影响判断，只跳过本轮落后检查
+		if err := solanaRPCAt(ctx, cfg.ReferenceRPCURL, "", "getSlot", []interface{}{}, &status.ReferenceSlot); err != nil {
+			logWarn("⚠️ 查询参考节点 slot 失败", "error", err)
+		} else {
+			status.SlotLag = int64(status.ReferenceSlot) - int64(status.Slot)
+			if status.SlotLag > cfg.MaxSlotLag {
+				reasons = append(reasons, fmt.Sprintf("节点落后 %d slot", status.SlotLag))
+			}
+		}
+	}
+	if cfg.MinTPS > 0 && status.Error == "" {
+		var samples []performanceSample
+		if err := solanaRPC(ctx, "getRecentPerformanceSamples", []interface{}{cfg.TPSSamples}, &samples); err != nil {
+			logWarn("⚠️ 查询集群性能样本失败", "error", err)
+		} else {
+			var txs, secs uint64
+			for _, s := range samples {
+				txs += s.NumTransactions
+				secs += s.SamplePeriodSecs
+			}
+			if secs > 0 {
+				status.TPS = float64(txs) / float64(secs)
+				if status.TPS < cfg.MinTPS {
+					reasons = append(reasons, fmt.Sprintf("集群 TPS %.0f 低于 %.0f", status.TPS, cfg.MinTPS))
+				}
+			}
+		}
+	}
+	status.Healthy = len(reasons) == 0
+	status.Reasons = reasons
+
+	clusterMutex.Lock()
+	wasUnhealthy := clusterUnhealthy
+	recovered := false
+	switch {
+	case !status.Healthy:
+		clusterUnhealthy = true
+		clusterHealthyCount = 0
+	case clusterUnhealthy:
+		clusterHealthyCount++
+		if clusterHealthyCount >= cfg.RecoveryChecks {
+			clusterUnhealthy, clusterHealthyCount, recovered = false, 0, true
+		}
+	}
+	status.EntriesPaused = clusterUnhealthy && cfg.PauseEntries
+	clusterStatus = status
+	clusterMutex.Unlock()
+
+	fields := map[string]string{
+		"slot":    fmt.Sprint(status.Slot),
+		"slotLag": fmt.Sprint(status.SlotLag),
+		"tps":     fmt.Sprintf("%.0f", status.TPS),
+	}
+	switch {
+	case !status.Healthy && !wasUnhealthy:
+		text := "交易大概率失败"
+		if cfg.PauseEntries {
+			text = "已暂停开仓，交易大概率失败"
+		}
+		logWarn("🩺 RPC 节点或集群不健康", "reasons", strings.Join(reasons, "；"), "slotLag", status.SlotLag, "tps", fields["tps"], "pauseEntries", cfg.PauseEntries)
+		notifyKeyed(eventClusterHealth, levelWarning, "cluster", "RPC 节点或集群不健康："+strings.Join(reasons, "；"), text, fields)
+	case recovered:
+		logInfo("✅ RPC 节点与集群恢复正常", "slotLag", status.SlotLag, "tps", fields["tps"])
+		notifyKeyed(eventClusterHealth, levelInfo, "cluster", "RPC 节点与集群恢复正常", "", fields)
+	default:
+		logDebug("🩺 集群健康检查", "slot", status.Slot, "slotLag", status.SlotLag, "tps", fields["tps"], "healthy", status.Healthy)
+	}
+}
+
+// clusterEntriesPaused 节点或集群不健康且配置了暂停开仓
+func clusterEntriesPaused() bool {
+	if !currentConfig().ClusterHealth.Enabled {
+		return false
+	}
+	clusterMutex.Lock()
+	defer clusterMutex.Unlock()
+	return clusterUnhealthy && currentConfig().ClusterHealth.PauseEntries
+}
+
+func currentClusterHealth() ClusterHealthStatus {
+	clusterMutex.Lock()
+	defer clusterMutex.Unlock()
+	return clusterStatus
+}
+
+// startClusterHealthCheck 启动时检查一次，之后按间隔定期检查（演示模式不访问 RPC）
+func startClusterHealthCheck() {
+	cfg := currentConfig().ClusterHealth
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🩺 启动集群健康检查（每%v，最大落后 %d slot，最低 TPS %.0f）\n", interval, cfg.MaxSlotLag, cfg.MinTPS)
+	checkClusterHealth()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止集群健康检查\n")
+			return
+		case <-ticker.C:
+			checkClusterHealth()
+		}
+	}
+}
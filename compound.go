@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 复投任务在队列中的类型
+const jobCompound = "compound"
+
+// 兑换时跳过等待复投的代币
+const swapSkipCompound = "compound"
+
+// 保留的复投记录数
+const maxCompoundHistory = 500
+
+// CompoundConfig 领取到的手续费与奖励自动复投回同一仓位（不再由兑换定时任务卖出）
+type CompoundConfig struct {
+	Enabled     bool            `json:"enabled"`     // 默认对所有实盘池复投
+	Pools       map[string]bool `json:"pools"`       // 池 -> 是否复投，覆盖 enabled（运行时可通过 API 切换）
+	MinValueUSD float64         `json:"minValueUSD"` // 本次领取的价值低于该金额时不复投（留给兑换定时任务）
+	TimeoutSec  int             `json:"timeoutSeconds"`
+}
+
+// CompoundRecord 一次复投（data/state/compounds.json）
+type CompoundRecord struct {
+	Time     string             `json:"time"`
+	Pool     string             `json:"poolAddress"`
+	Position string             `json:"position"`
+	Wallet   string             `json:"wallet,omitempty"`
+	Amounts  map[string]float64 `json:"amounts"` // 代币 -> 数量
+	ValueUSD float64            `json:"valueUSD"`
+	Result   string             `json:"result"` // success / failed / skipped
+	Error    string             `json:"error,omitempty"`
+}
+
+var (
+	compoundMutex sync.Mutex
+	// 等待复投的代币（钱包/代币 -> 池），兑换在复投结束前跳过这些代币
+	compoundPending = map[string]string{}
+	// 同一钱包同一代币的复投与兑换互斥执行
+	tokenOpLocks sync.Map
+)
+
+func (c CompoundConfig) validate() error {
+	if c.MinValueUSD < 0 {
+		return fmt.Errorf("compound.minValueUSD 不能为负数")
+	}
+	if c.TimeoutSec <= 0 {
+		return fmt.Errorf("compound.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+func tokenOpLock(wallet, token string) *sync.Mutex {
+	v, _ := tokenOpLocks.LoadOrStore(wallet+"/"+token, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+// compoundEnabled 池是否复投：运行时切换优先，其次为 compound.pools，最后为 compound.enabled；模拟池不复投
+func compoundEnabled(poolAddress string) bool {
+	if isPaperPool(poolAddress) || isPriceOnly() {
+		return false
+	}
+	overrides := map[string]bool{}
+	if err := loadStateFile("compound_pools", &overrides); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if on, ok := overrides[poolAddress]; ok {
+		return on
+	}
+	if on, ok := currentConfig().Compound.Pools[poolAddress]; ok {
+		return on
+	}
+	return currentConfig().Compound.Enabled
+}
+
+// setPoolCompound 运行时切换池的复投（data/state/compound_pools.json）
+func setPoolCompound(poolAddress string, on bool) error {
+	compoundMutex.Lock()
+	defer compoundMutex.Unlock()
+	overrides := map[string]bool{}
+	if err := loadStateFile("compound_pools", &overrides); err != nil {
+		return err
+	}
+	overrides[poolAddress] = on
+	logInfo("🔁 池复投已切换", "pool", poolAddress, "enabled", on)
+	return saveStateFile("compound_pools", overrides)
+}
+
+// claimCompoundArgs 复投的池领取时不在脚本内兑换领取到的代币
+func claimCompoundArgs(poolAddress string) []string {
+	if !compoundEnabled(poolAddress) {
+		return nil
+	}
+	return []string{"--no-swap"}
+}
+
+// maybeCompound 领取成功后按本次领取的代币数量排队复投；需在 recordPnLClaim 之前调用（以台账中上一次的累计领取计算本次价值）
+func maybeCompound(poolAddress, position string, out []byte) {
+	if !compoundEnabled(poolAddress) {
+		return
+	}
+	o := decodeScriptOutput(out)
+	amounts := map[string]float64{}
+	for token, amount := range o.Claimed() {
+		if amount > 0 {
+			amounts[token] = amount
+		}
+	}
+	if len(amounts) == 0 {
+		return
+	}
+	valueUSD, known := claimIncrementUSD(poolAddress, position, o)
+	if min := currentConfig().Compound.MinValueUSD; min > 0 && (!known || valueUSD < min) {
+		detail := fmt.Sprintf("本次领取价值 %.2f USD 低于复投门槛 %.2f USD", valueUSD, min)
+		if !known {
+			detail = "领取输出缺少 claimedUSD，无法判断复投门槛"
+		}
+		recordSkip(subsystemClaim, skipBelowThreshold, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), detail)
+		return
+	}
+
+	wallet := poolWallet(poolAddress)
+	compoundMutex.Lock()
+	for token := range amounts {
+		compoundPending[wallet+"/"+token] = poolAddress
+	}
+	compoundMutex.Unlock()
+	logOutput("🔁 领取到的代币加入复投队列: %s（%s）\n", poolLabel(poolAddress), formatAmounts(amounts))
+	enqueueJob(&QueuedJob{
+		Type: jobCompound,
+		Key:  poolAddress,
+		Run:  func() error { return runCompound(poolAddress, position, wallet, amounts, valueUSD) },
+		OnDrop: func() {
+			releaseCompound(wallet, amounts)
+		},
+		OnBlock: func(reason, detail string) {
+			logOutput("🛑 %s，跳过复投: %s\n", detail, poolLabel(poolAddress))
+			recordSkip(subsystemClaim, reason, poolAddress, "", detail)
+			releaseCompound(wallet, amounts)
+		},
+	})
+}
+
+// 本次领取的价值：脚本输出的累计 claimedUSD 减去台账中该仓位上一次的累计值
+func claimIncrementUSD(poolAddress, position string, o ScriptOutput) (float64, bool) {
+	total, ok := o.Value("claimedUSD", "")
+	if !ok {
+		return 0, false
+	}
+	pnlMutex.Lock()
+	p := loadPnLLedger()[poolAddress]
+	pnlMutex.Unlock()
+	if p != nil && p.Positions[position] != nil {
+		total -= p.Positions[position].ClaimedUSD
+	}
+	if total < 0 {
+		total = 0
+	}
+	return total, true
+}
+
+func releaseCompound(wallet string, amounts map[string]float64) {
+	compoundMutex.Lock()
+	defer compoundMutex.Unlock()
+	for token := range amounts {
+		delete(compoundPending, wallet+"/"+token)
+	}
+}
+
+// compoundPendingPool 代币是否等待复投，返回所属的池
+func compoundPendingPool(wallet, token string) (string, bool) {
+	compoundMutex.Lock()
+	defer compoundMutex.Unlock()
+	pool, ok := compoundPending[wallet+"/"+token]
+	return pool, ok
+}
+
+// compoundSwapFilter 兑换时跳过等待复投的代币
+func compoundSwapFilter(wallet, tokenAddress string) string {
+	if pool, ok := compoundPendingPool(wallet, tokenAddress); ok {
+		recordSkip(subsystemSweep, skipFiltered, pool, tokenAddress, "代币等待复投")
+		return swapSkipCompound
+	}
+	return ""
+}
+
+// runCompound 把领取到的代币追加到原仓位（addLiquidity.ts --top-up --compound）；结束后代币不再保留，失败时由下一轮兑换处理
+func runCompound(poolAddress, position, wallet string, amounts map[string]float64, valueUSD float64) error {
+	defer releaseCompound(wallet, amounts)
+	// 领取中加入队列的复投等待该池的领取结束
+	claimLock := poolClaimLock(poolAddress)
+	claimLock.Lock()
+	defer claimLock.Unlock()
+	tokens := make([]string, 0, len(amounts))
+	for token := range amounts {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		lock := tokenOpLock(wallet, token)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	record := CompoundRecord{Time: appNow().Format(time.RFC3339), Pool: poolAddress, Position: position, Wallet: wallet, Amounts: amounts, ValueUSD: valueUSD}
+	defer func() { appendHistory("compounds", record, maxCompoundHistory) }()
+
+	if isPaused() {
+		record.Result = "skipped"
+		recordSkip(subsystemClaim, skipPaused, poolAddress, "", "复投")
+		return nil
+	}
+	if readPositionFromPoolJSON(poolAddress) != position {
+		record.Result, record.Error = "skipped", "仓位已变化"
+		recordSkip(subsystemClaim, skipFiltered, poolAddress, "", "复投前仓位已变化")
+		return nil
+	}
+	if err := transitionPool(poolAddress, poolAdding, "compound"); err != nil {
+		record.Result, record.Error = "skipped", err.Error()
+		recordSkip(subsystemClaim, skipInProgress, poolAddress, "", err.Error())
+		return nil
+	}
+	defer transitionPoolFrom(poolAddress, poolAdding, poolActive, "compound")
+
+	args := []string{fmt.Sprintf("--pool=%s", poolAddress), "--top-up=" + position, "--compound"}
+	for _, token := range tokens {
+		args = append(args, fmt.Sprintf("--deposit=%s:%s", token, strconv.FormatFloat(amounts[token], 'f', -1, 64)))
+	}
+	args = append(args, priorityFeeArgs(feeOpAddLiquidity)...)
+	dex := poolDex(poolAddress)
+	logOutput("🔁 执行复投: %s\n", strings.Join(dex.CommandLine(dexOpAddLiquidity, args...), " "))
+
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(currentConfig().Compound.TimeoutSec)*time.Second)
+	defer cancel()
+	out, err := dex.AddLiquidity(withWallet(ctx, wallet), args...)
+	logCommandOutput(out)
+	metricCompounds.Inc(resultLabel(err))
+	if err != nil {
+		record.Result, record.Error = "failed", err.Error()
+		logError("❌ 复投失败，代币将由兑换定时任务处理", "pool", poolAddress, "error", err)
+		publishError(subsystemClaim, poolAddress, "", err, map[string]string{"target": "compound"})
+		return nil
+	}
+	record.Result = "success"
+	recordPnLCompound(poolAddress, position, valueUSD)
+	logInfo("✅ 复投成功", "pool", poolAddress, "position", position, "valueUSD", valueUSD)
+	fields := map[string]string{"position": position, "valueUSD": formatFloat(valueUSD)}
+	for token, amount := range amounts {
+		fields[token] = formatFloat(amount)
+	}
+	publishEvent(BusEvent{Type: busLiquidityAdded, Stage: subsystemClaim, Pool: poolAddress, Wallet: wallet, Detail: "复投", Fields: fields})
+	return nil
+}
+
+// recordPnLCompound 复投计入成本（已领取的收益转为仓位价值，避免重复计为收益）
+func recordPnLCompound(poolAddress, position string, valueUSD float64) {
+	if valueUSD <= 0 {
+		return
+	}
+	pnlMutex.Lock()
+	solUSD := lastSolUSD
+	pnlMutex.Unlock()
+	if solUSD <= 0 {
+		return
+	}
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil || p.ClosedAt != "" {
+			return nil
+		}
+		p.CostSOL += valueUSD / solUSD
+		p.CostUSD += valueUSD
+		p.add(pnlDeposit, position, valueUSD/solUSD, valueUSD, "compound")
+		return p
+	})
+}
+
+func formatAmounts(amounts map[string]float64) string {
+	parts := make([]string, 0, len(amounts))
+	for token, amount := range amounts {
+		parts = append(parts, fmt.Sprintf("%s=%s", token, formatFloat(amount)))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
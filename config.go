@@ -0,0 +1,899 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// 默认配置文件路径（可通过 -config 参数覆盖）
+func defaultConfigPath() string { return basePath("config.json") }
+
+// Config 程序运行配置（JSON 格式，缺省字段使用默认值）
+type Config struct {
+	Instance         string                   `json:"instance"`        // 实例名（默认主机名），附加到指标、日志、告警与导出记录
+	Environment      string                   `json:"environment"`     // 部署环境，如 staging、production
+	Mode             string                   `json:"mode"`            // live（默认）或 price-only（研究模式，不发送交易）
+	DefaultPoolMode  string                   `json:"defaultPoolMode"` // 新池默认模式: live 或 paper
+	CSVSources       []CSVSourceConfig        `json:"csvSources"`      // 上游信号 CSV 列表
+	Ingest           IngestConfig             `json:"ingest"`          // CSV 之外的信号输入
+	Backpressure     BackpressureConfig       `json:"backpressure"`
+	API              APIConfig                `json:"api"`
+	Notify           NotifyConfig             `json:"notify"`
+	Logging          LoggingConfig            `json:"logging"`
+	Schedules        SchedulesConfig          `json:"schedules"`
+	PartialWithdraw  PartialWithdrawConfig    `json:"partialWithdraw"`
+	Ladder           LadderConfig             `json:"ladder"`
+	Exec             ExecConfig               `json:"exec"`
+	VolatilityRange  VolatilityRangeConfig    `json:"volatilityRange"`
+	Lifecycle        LifecycleConfig          `json:"lifecycle"`
+	Aging            AgingConfig              `json:"aging"`
+	Archive          ArchiveConfig            `json:"archive"`
+	PoolOverrides    PoolOverridesConfig      `json:"poolOverrides"` // 按池覆盖开仓金额、流动性策略、领取门槛、止损、兑换与告警后端
+	PoolSelection    PoolSelectionConfig      `json:"poolSelection"`
+	Risk             RiskConfig               `json:"risk"`
+	DuplicateToken   DuplicateTokenConfig     `json:"duplicateToken"`
+	WalletWatch      WalletWatchConfig        `json:"walletWatch"`
+	Tripwire         TripwireConfig           `json:"tripwire"`
+	RateGuard        RateGuardConfig          `json:"rateGuard"`
+	Profile          string                   `json:"profile"` // 启动时使用的参数档位（API 切换后以 data/state/profile.json 为准）
+	Profiles         map[string]ProfileConfig `json:"profiles"`
+	ABTest           ABTestConfig             `json:"abTest"`
+	Timezone         string                   `json:"timezone"`        // IANA 时区（如 Asia/Shanghai、UTC），空或 Local 为本机时区
+	TradingWindows   []TradingWindow          `json:"tradingWindows"`  // 允许自动开仓的时段，为空表示不限制
+	BlackoutWindows  []TradingWindow          `json:"blackoutWindows"` // 禁止自动开仓的时段（优先于 tradingWindows）
+	KillSwitch       KillSwitchConfig         `json:"killSwitch"`      // 全局停止开关：停止开仓与兑换，领取与监控照常
+	ClockCheck       ClockCheckConfig         `json:"clockCheck"`
+	CatchUp          CatchUpConfig            `json:"catchUp"`
+	PriceFetch       PriceFetchConfig         `json:"priceFetch"`
+	Pricing          PricingConfig            `json:"pricing"`
+	ListPolicy       ListPolicyConfig         `json:"listPolicy"` // 黑名单/允许名单在各子系统中的处理方式
+	BalanceMonitor   BalanceMonitorConfig     `json:"balanceMonitor"`
+	Wallets          []WalletConfig           `json:"wallets"` // 多钱包，为空时使用进程环境 / .env 中的单一钱包
+	WalletAssignment WalletAssignmentConfig   `json:"walletAssignment"`
+	Keys             KeysConfig               `json:"keys"`               // 签名私钥来源（加密文件、Vault、AWS Secrets Manager）
+	Reporting        ReportingConfig          `json:"reporting"`          // 报表、告警与面板的计价货币
+	MaxConcurrent    int                      `json:"maxConcurrentTasks"` // 同时处理的新池 JSON 任务数
+	HotReload        bool                     `json:"hotReload"`          // 监听配置文件，修改后热更新调度、并发、名单策略、止损止盈与告警配置
+	BanList          BanListConfig            `json:"banList"`
+	Admission        AdmissionConfig          `json:"admission"`        // 新池信号准入规则
+	ClaimPolicy      ClaimPolicyConfig        `json:"claimPolicy"`      // 按未领取手续费决定是否发送领取交易
+	Adaptive         AdaptiveConfig           `json:"adaptive"`         // 按手续费累积速率调整各池的领取频率，余额未变化时跳过兑换
+	RPCDegrade       RPCDegradeConfig         `json:"rpcDegrade"`       // RPC 限流时拉长定时任务间隔、降低并发
+	TxTracker        TxTrackerConfig          `json:"txTracker"`        // 跟踪交易确认状态，丢弃的交易重新执行或告警
+	PriorityFee      PriorityFeeConfig        `json:"priorityFee"`      // 按近期区块优先费动态设置开仓、领取与兑换的计算单元价格
+	ClusterHealth    ClusterHealthConfig      `json:"clusterHealth"`    // 节点落后或集群拥堵时暂停开仓
+	BlockhashCache   BlockhashCacheConfig     `json:"blockhashCache"`   // 预取区块哈希供发送交易的脚本使用
+	RPCPool          RPCPoolConfig            `json:"rpcPool"`          // 多个 RPC 节点的探测、切换与限速
+	Shutdown         ShutdownConfig           `json:"shutdown"`         // 关闭时等待进行中的命令完成，中断的命令下次启动时处理
+	TokenAccounts    TokenAccountsConfig      `json:"tokenAccounts"`    // 开仓前预创建交易对代币的关联代币账户
+	JobQueue         JobQueueConfig           `json:"jobQueue"`         // 开仓、领取、兑换与价格任务的优先级、去重、重试与并发
+	Health           HealthConfig             `json:"health"`           // 存活与就绪检查（/healthz、/readyz）的判定阈值
+	Audit            AuditConfig              `json:"audit"`            // 审计日志（跳过原因等决策记录）
+	Consolidation    ConsolidationConfig      `json:"consolidation"`    // 定时兑换的归集策略（目标资产、最低余额、灰尘与持仓代币保留）
+	SwapGuard        SwapGuardConfig          `json:"swapGuard"`        // 持仓保护：定时兑换跳过或限制实盘未平仓池的代币
+	SignalFreshness  SignalFreshnessConfig    `json:"signalFreshness"`  // 信号新鲜度：过期信号不开仓
+	Rebalance        RebalanceConfig          `json:"rebalance"`        // 价格离开 bin 范围时自动再平衡
+	DataVolume       DataVolumeConfig         `json:"dataVolume"`       // 数据目录所在卷不可用时暂停并告警，恢复后自动继续
+	Liquidity        LiquidityConfig          `json:"liquidity"`        // 开仓的流动性分布策略（spot、curve、bidAsk、oneSided）
+	Dex              DexConfig                `json:"dex"`              // 执行层协议：开仓、领取、移除与报价的后端，按信号字段或按池选择
+	Backtest         BacktestConfig           `json:"backtest"`         // 回测（-backtest）的仓位模型参数
+	PositionImport   PositionImportConfig     `json:"positionImport"`   // 启动时从仓位快照 CSV 导入已有仓位
+	PoolMetadata     PoolMetadataConfig       `json:"poolMetadata"`     // 新池到达时读取链上的代币、精度、符号、bin step 与费率
+	AccountSubscribe AccountSubscribeConfig   `json:"accountSubscribe"` // WebSocket 订阅池与仓位账户，按变化触发领取、价格获取与再平衡
+	LegacyImport     LegacyImportConfig       `json:"legacyImport"`     // 首次运行时导入旧版本的池文件、名单文件与日志
+	AddGuard         AddGuardConfig           `json:"addGuard"`         // 同一池已有未平仓仓位时拒绝再次添加流动性（或按信号字段追加）
+	DailySummary     DailySummaryConfig       `json:"dailySummary"`     // 每日汇总领取、兑换、手续费、盈亏与持仓数
+	TokenSafety      TokenSafetyConfig        `json:"tokenSafety"`      // 开仓前的代币安全检查（风险评分接口与链上权限、持有者集中度）
+	CSVSchema        CSVSchemaConfig          `json:"csvSchema"`        // CSV 表头到统一字段名的映射、必需字段与地址字段校验
+	EventBus         EventBusConfig           `json:"eventBus"`         // 内部事件总线：信号、开仓、领取、兑换、价格与错误事件写入审计日志与告警
+	PoolState        PoolStateConfig          `json:"poolState"`        // 池状态机：各状态的停留时限告警
+	Portfolio        PortfolioConfig          `json:"portfolio"`        // 全局敞口上限与开仓金额分配
+	EntryTrigger     EntryTriggerConfig       `json:"entryTrigger"`     // 按价格条件择时开仓
+	Compound         CompoundConfig           `json:"compound"`         // 领取收益自动复投
+	PriceStore       PriceStoreConfig         `json:"priceStore"`       // 价格历史的 K 线降采样与保留策略
+	GRPC             GRPCConfig               `json:"grpc"`             // 控制面 gRPC 接口
+	FileWatch        FileWatchConfig          `json:"fileWatch"`        // 文件监听方式：fsnotify 不可用时改为轮询
+	Supervisor       SupervisorConfig         `json:"supervisor"`       // 子系统自动重启、卡住的定时任务与 PID 文件
+	Scripts          ScriptsConfig            `json:"scripts"`          // 外部命令注册表：命令、超时、工作目录、环境变量与输出格式
+	TokenCooldown    TokenCooldownConfig      `json:"tokenCooldown"`    // 同一代币入场后的冷却期
+	TxCost           TxCostConfig             `json:"txCost"`           // 每笔交易的交易费、优先费与账户押金，按池与按天汇总并计入盈亏
+	SwapQuoteGuard   SwapQuoteGuardConfig     `json:"swapQuoteGuard"`   // 兑换前报价检查：价格影响上限与按已存价格的最低输出
+	SwapVerify       SwapVerifyConfig         `json:"swapVerify"`       // 兑换后按余额变化核对实际成交与报价
+	AlertRules       AlertRulesConfig         `json:"alertRules"`       // 可配置的告警规则（价格变化、事件次数、无事件、盈亏阈值）
+	Export           ExportConfig             `json:"export"`           // 仓位、兑换、领取与价格历史导出为 CSV / Parquet（目录或 S3）
+	Leader           LeaderConfig             `json:"leader"`           // 主备部署：租约选主，备用实例同步状态并在主实例故障时接管
+	Demo             DemoConfig               `json:"demo"`             // 演示/压测模式（-demo）的信号速率与模拟脚本参数
+}
+
+// BackpressureConfig 向上游 CSV 生产者暴露的饱和状态配置
+type BackpressureConfig struct {
+	Enabled         bool   `json:"enabled"`
+	StatusFile      string `json:"statusFile"`      // 状态文件路径，上游扫描器轮询读取
+	IntervalSeconds int    `json:"intervalSeconds"` // 状态文件刷新间隔
+	QueueHighWater  int    `json:"queueHighWater"`  // 在途任务数达到该值视为饱和（0 表示使用并发上限）
+}
+
+// SchedulesConfig 定时任务调度（cron 表达式含秒字段）
+type SchedulesConfig struct {
+	Price        ScheduleConfig `json:"price"`
+	Claim        ScheduleConfig `json:"claim"`
+	Swap         ScheduleConfig `json:"swap"`
+	PnLReport    ScheduleConfig `json:"pnlReport"`    // 盈亏日报
+	DailySummary ScheduleConfig `json:"dailySummary"` // 每日汇总（dailySummary.enabled 时执行）
+	Export       ScheduleConfig `json:"export"`       // 数据导出（export.enabled 时执行）
+}
+
+// APIConfig 内嵌 HTTP 管理接口配置
+type APIConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Listen    string `json:"listen"`    // 监听地址，例如 127.0.0.1:8088
+	Dashboard bool   `json:"dashboard"` // 在 /ui/ 提供内嵌 Web 面板
+	Token     string `json:"token"`     // 访问令牌：请求须带 Authorization: Bearer <token>（存活 / 就绪检查除外）
+}
+
+// 运行模式
+const (
+	modeLive      = "live"
+	modePriceOnly = "price-only"
+)
+
+// 研究模式：只做信号接收、价格记录，不执行任何交易
+func isPriceOnly() bool { return currentConfig().Mode == modePriceOnly }
+
+// 当前生效的配置：启动加载与热更新时整体替换，读取方通过 currentConfig 取得快照（只读，不修改其内容）
+var activeConfig atomic.Pointer[Config]
+
+func init() { activeConfig.Store(defaultConfig()) }
+
+func currentConfig() *Config { return activeConfig.Load() }
+
+// setConfig 替换当前配置（启动时加载与热更新）
+func setConfig(c *Config) { activeConfig.Store(c) }
+
+// 默认配置
+func defaultConfig() *Config {
+	return &Config{
+		Mode:            modeLive,
+		DefaultPoolMode: poolModeLive,
+		CSVSources: []CSVSourceConfig{
+			{Name: "auto_profit", Path: filepath.Join(filepath.Dir(appBaseDir), "dlmm_8_27", "data", "auto_profit.csv")},
+		},
+		Backpressure: BackpressureConfig{
+			Enabled:         false,
+			StatusFile:      dataPath("status", "backpressure.json"),
+			IntervalSeconds: 5,
+		},
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "human",
+			Dir:        dataPath("log"),
+			MaxSizeMB:  100,
+			Daily:      true,
+			MaxFiles:   30,
+			MaxAgeDays: 14,
+			Redact: LogRedactConfig{
+				Enabled:   false,
+				Addresses: true,
+				Amounts:   true,
+				Fields:    []string{"sol", "balance", "realized", "unrealized", "pnl", "proceeds", "deposit", "amount", "pendingUSD"},
+				Keywords:  []string{"余额", "收益", "盈亏", "成本", "价值", "已实现", "未实现", "合计", "收入", "balance", "PnL"},
+			},
+		},
+		Schedules: SchedulesConfig{
+			Price:        ScheduleConfig{Cron: "1 * * * * *"},     // 每分钟01秒
+			Claim:        ScheduleConfig{Cron: "10,40 * * * * *"}, // 每分钟10秒和40秒
+			Swap:         ScheduleConfig{Cron: "6 * * * * *"},     // 每分钟06秒
+			PnLReport:    ScheduleConfig{Cron: "50 59 23 * * *"},  // 每天23:59:50
+			DailySummary: ScheduleConfig{Cron: "0 55 23 * * *"},   // 每天23:55:00
+			Export:       ScheduleConfig{Cron: "0 10 0 * * *"},    // 每天00:10:00
+		},
+		API: APIConfig{
+			Enabled:   false,
+			Listen:    "127.0.0.1:8088",
+			Dashboard: true,
+		},
+		Exec: ExecConfig{
+			Default: RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1000, MaxDelayMs: 10000, BreakerThreshold: 5, BreakerCooldownSeconds: 60},
+			Output:  ExecOutputConfig{Stream: true, LineTimeoutSeconds: 180, MaxBytes: 1 << 20},
+		},
+		VolatilityRange: VolatilityRangeConfig{
+			LookbackMinutes: 120,
+			MinSamples:      30,
+			HorizonMinutes:  300,
+			Multiplier:      2,
+			MinRangePct:     20,
+			MaxRangePct:     90,
+		},
+		Risk: RiskConfig{
+			StopLoss:   StopLossConfig{DefaultPercent: 30, SwapTo: swapToSOL},
+			TakeProfit: TakeProfitConfig{SwapTo: swapToSOL},
+		},
+		PoolSelection: PoolSelectionConfig{
+			APIURL:         "https://dlmm-api.meteora.ag/pair/all_with_pagination",
+			TimeoutSeconds: 10,
+			VolumeWeight:   1,
+			TVLWeight:      0.5,
+			FeeWeight:      1,
+		},
+		WalletWatch: WalletWatchConfig{
+			RPCURL:          "https://api.mainnet-beta.solana.com",
+			IntervalSeconds: 30,
+			GraceSeconds:    120,
+			WindowTargets:   []string{"jupSwap"},
+		},
+		Tripwire: TripwireConfig{
+			MinOutgoingSOL: 0.01,
+			BalanceDropSOL: 0.05,
+		},
+		DuplicateToken: DuplicateTokenConfig{
+			Mode: duplicateModeFirst,
+		},
+		Lifecycle: LifecycleConfig{
+			OutOfRangeSide:       "both",
+			SignalTimeoutMinutes: 300,
+		},
+		Aging: AgingConfig{
+			MaxAgeMinutes:        240,
+			MinFeesPercent:       1,
+			CheckIntervalSeconds: 300,
+		},
+		Archive: ArchiveConfig{
+			ClosedMinutes:        60,
+			ExpiredHours:         24,
+			RetentionDays:        30,
+			CheckIntervalMinutes: 30,
+		},
+		PoolOverrides: PoolOverridesConfig{
+			Dir: "pools.d",
+		},
+		PartialWithdraw: PartialWithdrawConfig{
+			PercentOf: withdrawOfRemaining,
+		},
+		Ladder: LadderConfig{
+			TakeProfitRatio: 1.05, // 与 claimAllRewards.ts 的单仓位止盈线一致
+		},
+		Pricing: PricingConfig{
+			Providers:      []string{priceSourceOKX},
+			Strategy:       pricingFallback,
+			TimeoutSeconds: 5,
+		},
+		PriceFetch: PriceFetchConfig{
+			Workers: 1,
+			// 上游没有返回限速头时，与原先每次获取后固定等待 1.1 秒的节奏一致
+			Limiters:       map[string]RateLimitConfig{"okx": {RatePerSecond: 0.9, Burst: 1}},
+			MaxWaitSeconds: 60,
+		},
+		CatchUp: CatchUpConfig{
+			Enabled:       true,
+			MaxAgeMinutes: 30,
+		},
+		ClockCheck: ClockCheckConfig{
+			Enabled:         true,
+			Servers:         []string{"time.cloudflare.com", "time.google.com", "pool.ntp.org"},
+			IntervalSeconds: 600,
+			MaxDriftMs:      500,
+			TimeoutSeconds:  5,
+		},
+		ListPolicy: defaultListPolicy(),
+		BalanceMonitor: BalanceMonitorConfig{
+			IntervalSeconds: 300,
+			MinSOL:          0.05,
+			Tokens:          true,
+		},
+		Reporting: ReportingConfig{
+			Currency:            currencySOL,
+			RateIntervalSeconds: 600,
+		},
+		BanList: BanListConfig{
+			AutoBanSwapFailures: 3,
+			AutoBanHours:        24,
+		},
+		Admission: AdmissionConfig{
+			LiquidityField: "liquidity",
+			BinStepField:   "bin_step",
+			TokenAgeField:  "last_updated_first",
+			CreatorField:   "creator",
+			PairAPIURL:     "https://dlmm-api.meteora.ag/pair",
+			TimeoutSeconds: 10,
+		},
+		ClaimPolicy: ClaimPolicyConfig{
+			MinPendingUSD:      1, // 与 claimAllRewards.ts 原有的领取门槛一致
+			MaxIntervalMinutes: 360,
+		},
+		Adaptive: AdaptiveConfig{
+			WindowMinutes:       120,
+			HotFeesUSDPerHour:   5,
+			HotIntervalMinutes:  5,
+			IdleFeesUSDPerHour:  0.2,
+			IdleIntervalMinutes: 60,
+			SwapSkipUnchanged:   true,
+			SwapMaxSkipMinutes:  360,
+		},
+		RPCDegrade: RPCDegradeConfig{
+			Enabled:         true,
+			WindowSeconds:   60,
+			Threshold:       5,
+			MaxLevel:        3,
+			RecoverySeconds: 300,
+			Jobs:            []string{"price", "claim"},
+			Patterns:        []string{"Too Many Requests", "HTTP 429", "Node is behind", "-32005", "Minimum context slot has not been reached"},
+		},
+		TxTracker: TxTrackerConfig{
+			Enabled:         true,
+			IntervalSeconds: 15,
+			Commitment:      "confirmed",
+			ExpireSeconds:   150, // 区块哈希约 150 个区块（60~90 秒）后过期，留出余量
+			ResubmitTargets: []string{"claimAllRewards", "jupSwap"},
+			MaxResubmits:    2,
+		},
+		PriorityFee: PriorityFeeConfig{
+			Enabled:          false,
+			IntervalSeconds:  30,
+			Percentile:       75,
+			MinMicroLamports: 1000,
+			Operations: map[string]PriorityFeeOpConfig{
+				feeOpAddLiquidity: {Multiplier: 1.2, MaxMicroLamports: 2_000_000},
+				feeOpClaim:        {Multiplier: 1, MaxMicroLamports: 500_000},
+				// 上限 2,500,000 × 200,000 CU = 500000 lamports，与原先固定的 -maxfee 一致
+				feeOpSwap: {Multiplier: 1.2, MaxMicroLamports: 2_500_000, ComputeUnits: 200_000},
+			},
+		},
+		ClusterHealth: ClusterHealthConfig{
+			IntervalSeconds: 30,
+			ReferenceRPCURL: "https://api.mainnet-beta.solana.com",
+			MaxSlotLag:      50,
+			MinTPS:          1000,
+			TPSSamples:      5,
+			PauseEntries:    true,
+			RecoveryChecks:  2,
+			TimeoutSeconds:  10,
+		},
+		BlockhashCache: BlockhashCacheConfig{
+			RefreshSeconds: 10,
+			MaxAgeSeconds:  30,
+			Commitment:     "confirmed",
+			Targets:        []string{"addLiquidity", "claimAllRewards", "removeLiquidity", "removeLiquidityPartial", "createTokenAccounts"},
+		},
+		RPCPool: RPCPoolConfig{
+			ProbeIntervalSeconds: 30,
+			ProbeTimeoutSeconds:  5,
+			FailureThreshold:     3,
+			CooldownSeconds:      60,
+		},
+		Shutdown: ShutdownConfig{
+			GracePeriodSeconds: 120,
+			ResumeTargets:      []string{"claimAllRewards", "jupSwap"},
+		},
+		TokenAccounts: TokenAccountsConfig{
+			Enabled:        true,
+			SkipMints:      []string{wrappedSOLMint},
+			BatchSize:      5,
+			TimeoutSeconds: 60,
+		},
+		JobQueue: JobQueueConfig{
+			Workers:           32,
+			Priorities:        map[string]int{jobAddLiquidity: 40, jobPrice: 30, jobCompound: 25, jobSwap: 20, jobClaim: 10},
+			Concurrency:       map[string]int{jobClaim: 4, jobSwap: 1, jobCompound: 1},
+			MaxRetries:        map[string]int{jobClaim: 1, jobSwap: 1},
+			RetryDelaySeconds: 30,
+		},
+		Health: HealthConfig{
+			WatcherStaleSeconds: 60,
+			JobStaleFactor:      5,
+			MaxJobRunSeconds:    1800,
+			RPCCheckSeconds:     15,
+		},
+		Audit: AuditConfig{
+			Enabled:       true,
+			Dir:           dataPath("audit"),
+			RetentionDays: 90,
+		},
+		Consolidation: ConsolidationConfig{
+			Target:               swapToSOL,
+			DustAction:           dustActionSkip,
+			ExcludeOpenPositions: true,
+		},
+		SwapGuard: SwapGuardConfig{
+			Mode:               swapGuardSkip,
+			CapIntervalMinutes: 360,
+			States:             []string{positionStateOpened, positionStateActive, positionStateOutOfRange},
+		},
+		SignalFreshness: SignalFreshnessConfig{
+			MaxAgeMinutes: 30,
+			Timezone:      "Asia/Shanghai",
+		},
+		Rebalance: RebalanceConfig{
+			IntervalSeconds:      60,
+			Side:                 "both",
+			MinOutOfRangeMinutes: 10,
+			CooldownMinutes:      60,
+			Swap:                 true,
+		},
+		DataVolume: DataVolumeConfig{
+			Enabled:         true,
+			IntervalSeconds: 10,
+			RecoveryChecks:  2,
+		},
+		Liquidity: LiquidityConfig{
+			LiquidityParams: LiquidityParams{Strategy: liquidityBidAsk},
+		},
+		Dex: DexConfig{
+			Default:     protocolMeteoraDLMM,
+			SignalField: poolProtocolField,
+		},
+		Backtest: BacktestConfig{
+			SolAmount: 1,
+			RangePct:  60,
+		},
+		PoolMetadata: PoolMetadataConfig{
+			TimeoutSeconds: 10,
+		},
+		AccountSubscribe: AccountSubscribeConfig{
+			Commitment:              "confirmed",
+			RefreshSeconds:          30,
+			ClaimOnChange:           true,
+			ClaimMinIntervalSeconds: 600,
+			PriceOnChange:           true,
+			PriceMinIntervalSeconds: 60,
+			RebalanceOnChange:       true,
+			PollEvery:               10,
+		},
+		LegacyImport: LegacyImportConfig{
+			Enabled:  true,
+			ScanLogs: true,
+		},
+		AddGuard: AddGuardConfig{
+			Enabled: true,
+		},
+		DailySummary: DailySummaryConfig{
+			Formats: []string{summaryFormatJSON, summaryFormatCSV},
+		},
+		TokenSafety: TokenSafetyConfig{
+			Action:                   safetyActionSkip,
+			ProviderURL:              "https://api.rugcheck.xyz/v1/tokens/{mint}/report/summary",
+			RejectRiskLevel:          []string{"danger"},
+			RequireNoMintAuthority:   true,
+			RequireNoFreezeAuthority: true,
+			MaxTopHolderPercent:      20,
+			CacheMinutes:             30,
+			TimeoutSeconds:           10,
+		},
+		CSVSchema: CSVSchemaConfig{
+			Aliases: map[string][]string{
+				"poolAddress":        {"pool", "pool_address", "lbPair", "lb_pair"},
+				"ca":                 {"mint", "token", "token_address", "tokenAddress"},
+				"poolName":           {"pool_name", "pairName", "pair_name"},
+				"last_updated_first": {"first_seen", "firstSeen", "created_at"},
+			},
+			Required:      []string{"poolAddress"},
+			AddressFields: []string{"poolAddress", "ca"},
+		},
+		EventBus: EventBusConfig{
+			BufferSize:  1000,
+			Log:         true,
+			Audit:       true,
+			NotifyTypes: []string{},
+		},
+		PoolState: PoolStateConfig{
+			StuckMinutes:         map[string]int{poolSignaled: 30, poolAdding: 15, poolClaiming: 15, poolExiting: 30},
+			CheckIntervalSeconds: 60,
+		},
+		Portfolio: PortfolioConfig{
+			Allocation:   allocationFixed,
+			OnLimit:      onLimitQueue,
+			QueueMinutes: 30,
+			RetrySeconds: 30,
+		},
+		EntryTrigger: EntryTriggerConfig{
+			Mode:                 entryNow,
+			Field:                "entry",
+			PriceField:           "price",
+			TTLMinutes:           60,
+			CheckIntervalSeconds: 60,
+		},
+		Compound: CompoundConfig{
+			Pools:      map[string]bool{},
+			TimeoutSec: 300,
+		},
+		PriceStore: PriceStoreConfig{
+			RawRetentionHours:      168,
+			RetentionDays:          map[string]int{"1m": 14, "5m": 90, "1h": 0},
+			CompactIntervalMinutes: 60,
+		},
+		GRPC: GRPCConfig{
+			Listen:       "127.0.0.1:8089",
+			StreamBuffer: 256,
+		},
+		FileWatch: FileWatchConfig{
+			Mode:           fileWatchAuto,
+			PollIntervalMs: 1000,
+		},
+		Supervisor: SupervisorConfig{
+			PIDFile:               dataPath("meteora_dlmm.pid"),
+			InitialBackoffSeconds: 1,
+			MaxBackoffSeconds:     300,
+			StableSeconds:         600,
+			AbandonWedgedJobs:     true,
+			MaxWedgedRuns:         3,
+		},
+		Scripts: ScriptsConfig{
+			EnvPassthrough: append([]string(nil), defaultEnvPassthrough...),
+			Registry:       defaultScriptRegistry(),
+		},
+		TokenCooldown: TokenCooldownConfig{
+			Minutes:       30,
+			OverrideField: "ignoreCooldown",
+		},
+		TxCost: TxCostConfig{
+			IntervalSeconds: 30,
+			MaxAgeMinutes:   30,
+			BatchSize:       20,
+		},
+		SwapQuoteGuard: SwapQuoteGuardConfig{
+			QuoteURL:           "https://lite-api.jup.ag/swap/v1/quote",
+			SlippageBps:        100,
+			MaxPriceImpactPct:  5,
+			MinOutputPct:       80,
+			PriceMaxAgeMinutes: 30,
+			OnQuoteError:       quoteErrorSkip,
+		},
+		SwapVerify: SwapVerifyConfig{
+			TolerancePct:  3,
+			SettleSeconds: 15,
+		},
+		Export: ExportConfig{
+			Formats:   []string{exportFormatCSV},
+			PriceDays: 7,
+		},
+		Leader: LeaderConfig{
+			Backend:      leaderBackendFile,
+			Key:          "meteora-dlmm/leader",
+			LeaseSeconds: 15,
+			RenewSeconds: 5,
+			SyncSeconds:  30,
+		},
+		Demo: DemoConfig{
+			RowsPerMinute:         30,
+			ScriptLatencyMs:       300,
+			FailureRate:           0.02,
+			ReportIntervalSeconds: 30,
+			BenchStageMinutes:     3,
+			BenchRowsPerSecond:    20,
+		},
+		MaxConcurrent: 20,
+		HotReload:     true,
+		Profile:       "normal",
+		Profiles:      defaultProfiles(),
+	}
+}
+
+// 加载配置文件；文件不存在时使用默认配置
+func loadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := json.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	cfg.resolvePaths()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 校验配置
+func (c *Config) validate() error {
+	if err := validateDeployLabel("instance", c.Instance); err != nil {
+		return err
+	}
+	if err := validateDeployLabel("environment", c.Environment); err != nil {
+		return err
+	}
+	if c.Mode != modeLive && c.Mode != modePriceOnly {
+		return fmt.Errorf("mode 仅支持 %s 或 %s", modeLive, modePriceOnly)
+	}
+	if c.DefaultPoolMode != poolModeLive && c.DefaultPoolMode != poolModePaper {
+		return fmt.Errorf("defaultPoolMode 仅支持 %s 或 %s", poolModeLive, poolModePaper)
+	}
+	if err := validateCSVSources(c.CSVSources); err != nil {
+		return err
+	}
+	if err := c.Ingest.validate(c.CSVSources, c.API); err != nil {
+		return err
+	}
+	if _, err := parseLogLevel(c.Logging.Level); err != nil {
+		return err
+	}
+	if c.Logging.Format != "human" && c.Logging.Format != "json" {
+		return fmt.Errorf("logging.format 仅支持 human 或 json")
+	}
+	if c.Logging.Dir == "" {
+		return fmt.Errorf("logging.dir 不能为空")
+	}
+	if err := c.Logging.Redact.validate(); err != nil {
+		return err
+	}
+	for name, sc := range map[string]ScheduleConfig{"price": c.Schedules.Price, "claim": c.Schedules.Claim, "swap": c.Schedules.Swap} {
+		if _, err := parseCron(sc.Cron); err != nil {
+			return fmt.Errorf("schedules.%s: %v", name, err)
+		}
+		if sc.JitterMs < 0 {
+			return fmt.Errorf("schedules.%s.jitterMs 不能为负数", name)
+		}
+	}
+	if err := c.PartialWithdraw.validate(); err != nil {
+		return err
+	}
+	if err := c.Exec.validate(); err != nil {
+		return err
+	}
+	if err := c.VolatilityRange.validate(); err != nil {
+		return err
+	}
+	if err := c.Risk.validate(); err != nil {
+		return err
+	}
+	if err := c.WalletWatch.validate(); err != nil {
+		return err
+	}
+	if err := c.Pricing.validate(); err != nil {
+		return err
+	}
+	if err := c.ListPolicy.validate(); err != nil {
+		return err
+	}
+	if err := c.PriceFetch.validate(); err != nil {
+		return err
+	}
+	if err := c.CatchUp.validate(); err != nil {
+		return err
+	}
+	if err := c.ClockCheck.validate(); err != nil {
+		return err
+	}
+	if err := c.RateGuard.validate(); err != nil {
+		return err
+	}
+	if err := c.Tripwire.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.BalanceMonitor.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := validateWallets(c.Wallets, c.WalletAssignment); err != nil {
+		return err
+	}
+	if err := c.Keys.validate(); err != nil {
+		return err
+	}
+	if err := c.Reporting.validate(); err != nil {
+		return err
+	}
+	if err := c.BanList.validate(); err != nil {
+		return err
+	}
+	if err := c.Admission.validate(); err != nil {
+		return err
+	}
+	if err := c.ClaimPolicy.validate(); err != nil {
+		return err
+	}
+	if err := c.Adaptive.validate(); err != nil {
+		return err
+	}
+	if err := c.RPCDegrade.validate(); err != nil {
+		return err
+	}
+	if err := c.TxTracker.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.PriorityFee.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.ClusterHealth.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.BlockhashCache.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.RPCPool.validate(); err != nil {
+		return err
+	}
+	if err := c.Shutdown.validate(); err != nil {
+		return err
+	}
+	if err := c.TokenAccounts.validate(); err != nil {
+		return err
+	}
+	if err := c.JobQueue.validate(); err != nil {
+		return err
+	}
+	if err := c.Health.validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.validate(); err != nil {
+		return err
+	}
+	if err := c.Consolidation.validate(); err != nil {
+		return err
+	}
+	if err := c.SwapGuard.validate(); err != nil {
+		return err
+	}
+	if err := c.SignalFreshness.validate(); err != nil {
+		return err
+	}
+	if err := c.Rebalance.validate(); err != nil {
+		return err
+	}
+	if err := c.DataVolume.validate(); err != nil {
+		return err
+	}
+	if err := c.Liquidity.validate(); err != nil {
+		return err
+	}
+	if err := c.Dex.validate(); err != nil {
+		return err
+	}
+	if err := c.Backtest.validate(); err != nil {
+		return err
+	}
+	if err := c.PositionImport.validate(); err != nil {
+		return err
+	}
+	if err := c.PoolMetadata.validate(c.WalletWatch, c.RPCPool); err != nil {
+		return err
+	}
+	if err := c.AccountSubscribe.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.AddGuard.validate(); err != nil {
+		return err
+	}
+	if err := c.DailySummary.validate(); err != nil {
+		return err
+	}
+	if err := c.TokenSafety.validate(c.WalletWatch, c.RPCPool); err != nil {
+		return err
+	}
+	if err := c.CSVSchema.validate(); err != nil {
+		return err
+	}
+	if err := c.EventBus.validate(); err != nil {
+		return err
+	}
+	if err := c.PoolState.validate(); err != nil {
+		return err
+	}
+	if err := c.Portfolio.validate(c.Admission); err != nil {
+		return err
+	}
+	if err := c.EntryTrigger.validate(); err != nil {
+		return err
+	}
+	if err := c.Compound.validate(); err != nil {
+		return err
+	}
+	if err := c.PriceStore.validate(); err != nil {
+		return err
+	}
+	if err := c.GRPC.validate(); err != nil {
+		return err
+	}
+	if err := c.FileWatch.validate(); err != nil {
+		return err
+	}
+	if err := c.Supervisor.validate(); err != nil {
+		return err
+	}
+	if err := c.Scripts.validate(); err != nil {
+		return err
+	}
+	if err := c.TokenCooldown.validate(); err != nil {
+		return err
+	}
+	if err := c.TxCost.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.SwapQuoteGuard.validate(c.WalletWatch); err != nil {
+		return err
+	}
+	if err := c.SwapVerify.validate(c.WalletWatch, c.SwapQuoteGuard); err != nil {
+		return err
+	}
+	if err := c.AlertRules.validate(c.EventBus); err != nil {
+		return err
+	}
+	if err := c.Export.validate(); err != nil {
+		return err
+	}
+	if err := c.Leader.validate(c.API); err != nil {
+		return err
+	}
+	if c.VolatilityRange.Enabled && c.PriceStore.RawRetentionHours > 0 && c.PriceStore.RawRetentionHours*60 < c.VolatilityRange.LookbackMinutes {
+		return fmt.Errorf("priceStore.rawRetentionHours 短于 volatilityRange.lookbackMinutes，波动率将缺少原始采样")
+	}
+	if err := c.Demo.validate(); err != nil {
+		return err
+	}
+	if c.MaxConcurrent <= 0 {
+		return fmt.Errorf("maxConcurrentTasks 必须大于0")
+	}
+	if err := c.DuplicateToken.validate(); err != nil {
+		return err
+	}
+	if err := c.PoolSelection.validate(); err != nil {
+		return err
+	}
+	if err := c.Lifecycle.validate(); err != nil {
+		return err
+	}
+	if err := c.Aging.validate(); err != nil {
+		return err
+	}
+	// 老化按开仓时间计算，开仓晚于信号：时长不小于信号起强制平仓时永远来不及触发
+	if timeout := c.Lifecycle.SignalTimeoutMinutes; c.Aging.Enabled && timeout > 0 && c.Aging.MaxAgeMinutes >= float64(timeout) {
+		return fmt.Errorf("aging.maxAgeMinutes（%g）须小于 lifecycle.signalTimeoutMinutes（%d），否则信号起强制平仓先触发，老化检查不会生效", c.Aging.MaxAgeMinutes, timeout)
+	}
+	if err := c.Archive.validate(); err != nil {
+		return err
+	}
+	if err := c.PoolOverrides.validate(); err != nil {
+		return err
+	}
+	if err := c.Ladder.validate(); err != nil {
+		return err
+	}
+	if err := validateProfiles(c); err != nil {
+		return err
+	}
+	if err := c.ABTest.validate(c.Profiles); err != nil {
+		return err
+	}
+	if _, err := parseTimezone(c.Timezone); err != nil {
+		return err
+	}
+	for _, w := range append(append([]TradingWindow{}, c.TradingWindows...), c.BlackoutWindows...) {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.KillSwitch.validate(); err != nil {
+		return err
+	}
+	if c.Backpressure.IntervalSeconds <= 0 {
+		return fmt.Errorf("backpressure.intervalSeconds 必须大于0")
+	}
+	if c.Backpressure.QueueHighWater < 0 {
+		return fmt.Errorf("backpressure.queueHighWater 不能为负数")
+	}
+	if c.API.Enabled && c.API.Listen == "" {
+		return fmt.Errorf("api.listen 不能为空")
+	}
+	if c.API.Enabled && c.API.Token == "" {
+		return fmt.Errorf("api.token 不能为空（管理接口可平仓、暂停与修改配置）")
+	}
+	if c.Notify.Enabled {
+		built, err := buildNotifiers(c.Notify)
+		if err != nil {
+			return err
+		}
+		for event, route := range c.Notify.Routes {
+			for _, name := range route.Backends {
+				if _, ok := built[name]; !ok {
+					return fmt.Errorf("notify.routes.%s 引用了不存在的后端: %s", event, name)
+				}
+			}
+		}
+	}
+	return nil
+}
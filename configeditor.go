@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 审计日志中配置修改记录的类型
+const auditKindConfig = "config"
+
+// 面板中不可编辑的热更新配置项（含告警后端凭据，仍需修改配置文件）
+var configEditorExcluded = map[string]bool{
+	"Notify": true,
+}
+
+// 返回配置时遮蔽的字段（JSON 名）
+var configSecretKeys = map[string]bool{
+	"botToken":      true,
+	"webhookUrl":    true,
+	"token":         true,
+	"hmacSecret":    true,
+	"password":      true,
+	"birdeyeApiKey": true,
+}
+
+// 同一时间只允许一次面板写入
+var configEditMutex sync.Mutex
+
+// ConfigView 当前生效的配置（GET /config）
+type ConfigView struct {
+	File     string                 `json:"file"`
+	Editable []string               `json:"editable"` // 可在面板修改的配置项（JSON 名）
+	Config   map[string]interface{} `json:"config"`   // 敏感字段已遮蔽
+}
+
+// ConfigEditResult 面板修改配置的结果（PUT /config）
+type ConfigEditResult struct {
+	ConfigReloadResult
+	Changed   []string `json:"changed"`             // 提交中与配置文件不同的配置项
+	Validated bool     `json:"validated,omitempty"` // 仅校验（validateOnly=1）时为 true
+}
+
+// 可在面板修改的配置项：JSON 名 -> Config 字段名
+func configEditableFields() map[string]string {
+	fields := map[string]string{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if hotReloadFields[f.Name] && !configEditorExcluded[f.Name] {
+			fields[configFieldName(f)] = f.Name
+		}
+	}
+	return fields
+}
+
+// currentConfigView 当前生效的配置（遮蔽凭据）
+func currentConfigView() (ConfigView, error) {
+	view := ConfigView{File: configFilePath, Editable: []string{}}
+	for name := range configEditableFields() {
+		view.Editable = append(view.Editable, name)
+	}
+	sort.Strings(view.Editable)
+	content, err := json.Marshal(currentConfig())
+	if err != nil {
+		return view, err
+	}
+	if err := json.Unmarshal(content, &view.Config); err != nil {
+		return view, err
+	}
+	maskConfigSecrets(view.Config)
+	return view, nil
+}
+
+func maskConfigSecrets(v interface{}) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		for k, child := range m {
+			if s, ok := child.(string); ok && configSecretKeys[k] && s != "" {
+				m[k] = redactedValue
+				continue
+			}
+			maskConfigSecrets(child)
+		}
+	case []interface{}:
+		for _, child := range m {
+			maskConfigSecrets(child)
+		}
+	}
+}
+
+// editConfig 用提交的配置项（整段替换）更新配置文件并热更新。
+// 只允许可编辑的配置项；合并后的完整配置先通过校验（含 cron）才写入文件，原文件备份为 <file>.bak
+func editConfig(sections map[string]json.RawMessage, validateOnly bool, source string) (ConfigEditResult, error) {
+	result := ConfigEditResult{ConfigReloadResult: ConfigReloadResult{Applied: []string{}}, Changed: []string{}}
+	if configFilePath == "" {
+		return result, fmt.Errorf("未指定配置文件")
+	}
+	if len(sections) == 0 {
+		return result, fmt.Errorf("没有提交配置项")
+	}
+	editable := configEditableFields()
+	var rejected []string
+	for name := range sections {
+		if editable[name] == "" {
+			rejected = append(rejected, name)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return result, fmt.Errorf("以下配置项不能在面板修改: %s", strings.Join(rejected, ","))
+	}
+
+	configEditMutex.Lock()
+	defer configEditMutex.Unlock()
+
+	original, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return result, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	file := map[string]json.RawMessage{}
+	if err := json.Unmarshal(original, &file); err != nil {
+		return result, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	for name, raw := range sections {
+		if !jsonEqual(file[name], raw) {
+			result.Changed = append(result.Changed, name)
+		}
+		file[name] = raw
+	}
+	sort.Strings(result.Changed)
+	merged, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return result, err
+	}
+
+	// 与加载配置文件相同的方式校验：在默认配置之上解析
+	next := defaultConfig()
+	if err := json.Unmarshal(merged, next); err != nil {
+		return result, fmt.Errorf("解析配置失败: %v", err)
+	}
+	next.resolvePaths()
+	if err := next.validate(); err != nil {
+		return result, err
+	}
+	if err := validateSchedules(scheduleConfigs(next.Schedules)); err != nil {
+		return result, err
+	}
+	if validateOnly {
+		result.Validated = true
+		return result, nil
+	}
+	if len(result.Changed) == 0 {
+		return result, nil
+	}
+
+	info, err := os.Stat(configFilePath)
+	if err != nil {
+		return result, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := os.WriteFile(configFilePath+".bak", original, info.Mode().Perm()); err != nil {
+		return result, fmt.Errorf("备份配置文件失败: %v", err)
+	}
+	tmpPath := configFilePath + ".tmp"
+	if err := os.WriteFile(tmpPath, append(merged, '\n'), info.Mode().Perm()); err != nil {
+		return result, fmt.Errorf("写入配置文件失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, configFilePath); err != nil {
+		return result, fmt.Errorf("写入配置文件失败: %v", err)
+	}
+
+	result.ConfigReloadResult = reloadConfig()
+	detail := "修改 " + strings.Join(result.Changed, ",")
+	if result.Error != "" {
+		detail += "，热更新失败: " + result.Error
+	}
+	appendAudit(AuditEntry{Kind: auditKindConfig, Reason: "edit", Detail: detail + "（来源 " + source + "）"})
+	logInfo("📝 已通过面板修改配置", "changed", strings.Join(result.Changed, ","), "source", source)
+	return result, nil
+}
+
+// 两段 JSON 语义是否相同（缺失视为不同）
+func jsonEqual(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// /config：GET 返回当前生效的配置；PUT 提交 {"<配置项>": {...}} 修改可编辑的配置项（?validateOnly=1 仅校验）
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		view, err := currentConfigView()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, view)
+	case http.MethodPut:
+		var sections map[string]json.RawMessage
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&sections); err != nil {
+			writeError(w, http.StatusBadRequest, "请求体不是有效的 JSON 对象: "+err.Error())
+			return
+		}
+		result, err := editConfig(sections, r.URL.Query().Get("validateOnly") == "1", r.RemoteAddr)
+		if err != nil {
+			result.Error = err.Error()
+			writeJSON(w, http.StatusBadRequest, result)
+			return
+		}
+		if result.Error != "" {
+			writeJSON(w, http.StatusBadRequest, result)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 可热更新的配置项（Config 字段名）；其余配置修改后保留旧值，提示重启生效
+var hotReloadFields = map[string]bool{
+	"Schedules":       true,
+	"MaxConcurrent":   true,
+	"PriceFetch":      true,
+	"ListPolicy":      true,
+	"BanList":         true,
+	"Admission":       true,
+	"Portfolio":       true,
+	"EntryTrigger":    true,
+	"Compound":        true,
+	"PriceStore":      true,
+	"ClaimPolicy":     true,
+	"Risk":            true,
+	"Notify":          true,
+	"JobQueue":        true,
+	"Consolidation":   true,
+	"SwapGuard":       true,
+	"SignalFreshness": true,
+	"Liquidity":       true,
+	"Dex":             true,
+	"EventBus":        true,
+	"Supervisor":      true,
+	"TokenCooldown":   true,
+	"SwapQuoteGuard":  true,
+	"SwapVerify":      true,
+	"AlertRules":      true,
+	"Export":          true,
+	"Aging":           true,
+	"Adaptive":        true,
+	"Archive":         true,
+	"TradingWindows":  true,
+	"BlackoutWindows": true,
+}
+
+// 连续写入合并为一次重新加载
+const configReloadDebounce = 500 * time.Millisecond
+
+var (
+	configFilePath  string // 启动时加载的配置文件
+	configModeFlag  string // 命令行 -mode 覆盖（重新加载时同样生效）
+	configReloadMux sync.Mutex
+)
+
+// ConfigReloadResult 一次重新加载的结果（POST /config/reload）
+type ConfigReloadResult struct {
+	Applied []string `json:"applied"`           // 已生效的配置项
+	Restart []string `json:"restart,omitempty"` // 有修改但需重启生效的配置项
+	Error   string   `json:"error,omitempty"`
+}
+
+// 配置项的 JSON 名
+func configFieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// reloadConfig 重新读取配置文件：校验失败时保持当前配置不变；通过校验后只替换可热更新的配置项
+func reloadConfig() ConfigReloadResult {
+	configReloadMux.Lock()
+	defer configReloadMux.Unlock()
+
+	result, err := applyConfigReload()
+	if err != nil {
+		result.Error = err.Error()
+		metricConfigReloads.Inc("rejected")
+		logError("❌ 配置热更新失败，继续使用当前配置", "file", configFilePath, "error", err)
+		notifyKeyed(eventConfigReload, levelWarning, "config", "配置热更新失败", err.Error(), map[string]string{"file": configFilePath})
+		return result
+	}
+	if len(result.Applied) == 0 {
+		metricConfigReloads.Inc("unchanged")
+	} else {
+		metricConfigReloads.Inc("applied")
+		logInfo("🔄 配置已热更新", "file", configFilePath, "applied", strings.Join(result.Applied, ","))
+	}
+	if len(result.Restart) > 0 {
+		logWarn("⚠️ 以下配置修改需重启生效", "fields", strings.Join(result.Restart, ","))
+	}
+	return result
+}
+
+func applyConfigReload() (ConfigReloadResult, error) {
+	result := ConfigReloadResult{Applied: []string{}}
+	// 文件不存在时 loadConfig 返回默认配置，热更新时不能据此覆盖
+	if _, err := os.Stat(configFilePath); err != nil {
+		return result, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	loaded, err := loadConfig(configFilePath)
+	if err != nil {
+		return result, err
+	}
+	if configModeFlag != "" {
+		loaded.Mode = configModeFlag
+	}
+	if isDemo() {
+		applyDemoConfig(loaded)
+	}
+
+	// 合并：可热更新的配置项取新值，其余保留当前值
+	cur := currentConfig()
+	next := *cur
+	nv, cv, lv := reflect.ValueOf(&next).Elem(), reflect.ValueOf(cur).Elem(), reflect.ValueOf(loaded).Elem()
+	for i := 0; i < nv.NumField(); i++ {
+		field := nv.Type().Field(i)
+		if reflect.DeepEqual(cv.Field(i).Interface(), lv.Field(i).Interface()) {
+			continue
+		}
+		if hotReloadFields[field.Name] {
+			nv.Field(i).Set(lv.Field(i))
+			result.Applied = append(result.Applied, configFieldName(field))
+		} else {
+			result.Restart = append(result.Restart, configFieldName(field))
+		}
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+	if err := next.validate(); err != nil {
+		return result, err
+	}
+
+	// 先准备可能失败的部分（告警后端、cron），全部成功后再切换，避免只生效一半
+	var built map[string]Notifier
+	if next.Notify.Enabled {
+		if built, err = buildNotifiers(next.Notify); err != nil {
+			return result, err
+		}
+	}
+	schedules := scheduleConfigs(next.Schedules)
+	if err := validateSchedules(schedules); err != nil {
+		return result, err
+	}
+
+	setConfig(&next)
+	if !reflect.DeepEqual(cur.Notify, next.Notify) {
+		setNotifiers(built)
+	}
+	if !reflect.DeepEqual(cur.Schedules, next.Schedules) {
+		for name, sc := range schedules {
+			if err := rescheduleJob(name, sc); err != nil {
+				logError("❌ 更新定时任务失败", "job", name, "error", err)
+			}
+		}
+	}
+	if cur.MaxConcurrent != next.MaxConcurrent {
+		// 保持 RPC 限流降级的缩减
+		applyRPCDegrade()
+	}
+	if !reflect.DeepEqual(cur.PriceFetch.Limiters, next.PriceFetch.Limiters) {
+		resetAPIBudgets()
+	}
+	// 并发上限可能调大，按新配置重新调度
+	kickJobQueue()
+	return result, nil
+}
+
+// 各定时任务的调度配置
+func scheduleConfigs(s SchedulesConfig) map[string]ScheduleConfig {
+	return map[string]ScheduleConfig{
+		"price": s.Price, "claim": s.Claim, "swap": s.Swap, "pnlReport": s.PnLReport, "dailySummary": s.DailySummary,
+		"export": s.Export,
+	}
+}
+
+// 校验定时任务的 cron 表达式
+func validateSchedules(schedules map[string]ScheduleConfig) error {
+	for name, sc := range schedules {
+		if _, err := parseCron(sc.Cron); err != nil {
+			return fmt.Errorf("schedules.%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// startConfigWatcher 监听配置文件所在目录（编辑器常以重命名方式保存），文件变化后重新加载
+func startConfigWatcher() {
+	if !currentConfig().HotReload || configFilePath == "" {
+		return
+	}
+	path := filepath.Clean(configFilePath)
+	watcher, err := newFileWatcher("config")
+	if err != nil {
+		logWarn("⚠️ 创建配置文件监听失败，配置热更新不可用", "error", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		logWarn("⚠️ 添加配置文件监听失败，配置热更新不可用", "file", path, "error", err)
+		return
+	}
+	logOutput("👀 监听配置文件: %s\n", path)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce = time.After(configReloadDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			reloadConfig()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logError("❌ 配置文件监听错误", "error", err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigSummary 生效配置与安全限制概览（启动时输出，GET /config/summary 查看当前值）
+type ConfigSummary struct {
+	Instance       string            `json:"instance,omitempty"`
+	Environment    string            `json:"environment,omitempty"`
+	Mode           string            `json:"mode"`
+	DryRun         bool              `json:"dryRun"`
+	Demo           bool              `json:"demo"`
+	Paused         bool              `json:"paused"`
+	Frozen         bool              `json:"frozen"`
+	KillSwitch     bool              `json:"killSwitch"`
+	Profile        string            `json:"profile,omitempty"`
+	Sizing         SizingSummary     `json:"sizing"`
+	Limits         LimitsSummary     `json:"limits"`
+	Schedules      map[string]string `json:"schedules"`
+	Timezone       string            `json:"timezone"`
+	TradingWindows []TradingWindow   `json:"tradingWindows"`
+	Blackouts      []TradingWindow   `json:"blackoutWindows"`
+	Subsystems     map[string]bool   `json:"subsystems"` // 带 enabled 开关的配置项是否启用
+	Warnings       []string          `json:"warnings"`   // 可能的误配置（过滤或保护未启用等）
+}
+
+// SizingSummary 开仓金额与滑点
+type SizingSummary struct {
+	SolAmount   float64            `json:"solAmount"`           // 单次开仓 SOL（档位金额，0 时为 SOL_AMOUNT）
+	SolSource   string             `json:"solSource"`           // profile / env / ladder
+	Ladder      []LadderLeg        `json:"ladder,omitempty"`    // 启用阶梯仓位时的档位
+	Strategy    string             `json:"strategy"`            // 默认流动性分布策略（liquidity.strategy）
+	SlippagePct float64            `json:"slippagePct"`         // 0 表示脚本默认
+	SwapMaxFee  int64              `json:"swapMaxFee"`          // 0 表示 jupSwap 默认
+	MinFields   map[string]float64 `json:"minFields,omitempty"` // 信号字段下限
+}
+
+// LimitsSummary 开仓与交易的上限（未启用的限制为 0）
+type LimitsSummary struct {
+	MaxConcurrentTasks    int     `json:"maxConcurrentTasks"`
+	MaxOpenPositions      int     `json:"maxOpenPositions"`
+	MaxTokenExposureSOL   float64 `json:"maxTokenExposureSOL"`
+	MaxPositionsPerHour   int     `json:"maxPositionsPerHour"`
+	MaxSwapsPerHour       int     `json:"maxSwapsPerHour"`
+	MaxSOLDeployedPerHour float64 `json:"maxSolDeployedPerHour"`
+	StopLossPercent       float64 `json:"stopLossPercent"`
+	TakeProfitRules       int     `json:"takeProfitRules"`
+	SignalMaxAgeMinutes   float64 `json:"signalMaxAgeMinutes"`
+	ClaimMinPendingUSD    float64 `json:"claimMinPendingUSD"`
+	BalanceMinSOL         float64 `json:"balanceMinSol"`
+}
+
+// currentConfigSummary 按当前生效的配置（含热更新与档位切换）生成概览
+func currentConfigSummary() ConfigSummary {
+	cfg := currentConfig()
+	profileName, profile := activeProfile()
+	s := ConfigSummary{
+		Instance:       deployInstance,
+		Environment:    deployEnvironment,
+		Mode:           cfg.Mode,
+		DryRun:         isDryRun(),
+		Demo:           isDemo(),
+		Paused:         isPaused(),
+		Frozen:         isFrozen(),
+		KillSwitch:     killSwitchActive(),
+		Profile:        profileName,
+		Schedules:      map[string]string{},
+		Timezone:       appLocation.String(),
+		TradingWindows: cfg.TradingWindows,
+		Blackouts:      cfg.BlackoutWindows,
+		Subsystems:     configSubsystems(cfg),
+		Warnings:       []string{},
+	}
+	if s.TradingWindows == nil {
+		s.TradingWindows = []TradingWindow{}
+	}
+	if s.Blackouts == nil {
+		s.Blackouts = []TradingWindow{}
+	}
+
+	s.Sizing = SizingSummary{
+		SolAmount:   profile.SolAmount,
+		SolSource:   "profile",
+		Strategy:    cfg.Liquidity.Strategy,
+		SlippagePct: profile.SlippagePct,
+		SwapMaxFee:  profile.SwapMaxFee,
+		MinFields:   profile.MinFields,
+	}
+	switch {
+	case ladderEnabled():
+		s.Sizing.SolSource = "ladder"
+		s.Sizing.Ladder = cfg.Ladder.Legs
+		s.Sizing.SolAmount = 0
+		for _, leg := range cfg.Ladder.Legs {
+			s.Sizing.SolAmount += leg.SolAmount
+		}
+	case profile.SolAmount <= 0:
+		s.Sizing.SolSource = "env"
+		s.Sizing.SolAmount, _ = strconv.ParseFloat(lookupEnv("SOL_AMOUNT"), 64)
+	}
+
+	s.Limits.MaxConcurrentTasks = cfg.MaxConcurrent
+	if cfg.Admission.Enabled {
+		s.Limits.MaxOpenPositions = cfg.Admission.MaxOpenPositions
+		s.Limits.MaxTokenExposureSOL = cfg.Admission.MaxTokenExposureSOL
+	}
+	if cfg.RateGuard.Enabled {
+		s.Limits.MaxPositionsPerHour = cfg.RateGuard.MaxPositionsPerHour
+		s.Limits.MaxSwapsPerHour = cfg.RateGuard.MaxSwapsPerHour
+		s.Limits.MaxSOLDeployedPerHour = cfg.RateGuard.MaxSOLDeployedPerHour
+	}
+	if cfg.Risk.StopLoss.Enabled {
+		s.Limits.StopLossPercent = cfg.Risk.StopLoss.DefaultPercent
+	}
+	if cfg.Risk.TakeProfit.Enabled {
+		s.Limits.TakeProfitRules = len(cfg.Risk.TakeProfit.Rules)
+	}
+	if cfg.SignalFreshness.Enabled {
+		s.Limits.SignalMaxAgeMinutes = cfg.SignalFreshness.MaxAgeMinutes
+	}
+	s.Limits.ClaimMinPendingUSD = cfg.ClaimPolicy.MinPendingUSD
+	if cfg.BalanceMonitor.Enabled {
+		s.Limits.BalanceMinSOL = cfg.BalanceMonitor.MinSOL
+	}
+
+	for name, sc := range scheduleConfigs(cfg.Schedules) {
+		s.Schedules[name] = sc.Cron
+	}
+	s.Warnings = configWarnings(s)
+	return s
+}
+
+// 带 enabled 开关的配置项（JSON 名）；自身没有开关时取下一层，如 risk.stopLoss
+func configSubsystems(cfg *Config) map[string]bool {
+	subsystems := map[string]bool{}
+	v := reflect.ValueOf(*cfg)
+	for i := 0; i < v.NumField(); i++ {
+		f, fv := v.Type().Field(i), v.Field(i)
+		if fv.Kind() != reflect.Struct {
+			continue
+		}
+		name := configFieldName(f)
+		if enabled, ok := structEnabled(fv); ok {
+			subsystems[name] = enabled
+			continue
+		}
+		for j := 0; j < fv.NumField(); j++ {
+			if fv.Field(j).Kind() != reflect.Struct {
+				continue
+			}
+			if enabled, ok := structEnabled(fv.Field(j)); ok {
+				subsystems[name+"."+configFieldName(fv.Type().Field(j))] = enabled
+			}
+		}
+	}
+	return subsystems
+}
+
+func structEnabled(v reflect.Value) (bool, bool) {
+	f := v.FieldByName("Enabled")
+	if !f.IsValid() || f.Kind() != reflect.Bool {
+		return false, false
+	}
+	return f.Bool(), true
+}
+
+// 实盘运行时值得注意的配置：入场过滤与风控保护未启用、开仓金额未知等
+func configWarnings(s ConfigSummary) []string {
+	warnings := []string{}
+	if s.Mode == modePriceOnly || s.Demo {
+		return warnings
+	}
+	if s.Sizing.SolAmount <= 0 {
+		warnings = append(warnings, "开仓金额未设置（档位 solAmount 与 SOL_AMOUNT 均为空）")
+	}
+	if !s.Subsystems["admission"] {
+		warnings = append(warnings, "新池准入规则（admission）未启用，流动性、bin step、持仓数上限不检查")
+	} else if s.Limits.MaxOpenPositions == 0 {
+		warnings = append(warnings, "未限制同时持仓的池数（admission.maxOpenPositions 为 0）")
+	}
+	if len(s.Sizing.MinFields) == 0 {
+		warnings = append(warnings, "档位未设置信号字段下限（minFields），所有信号都会入场")
+	}
+	if !s.Subsystems["signalFreshness"] {
+		warnings = append(warnings, "信号新鲜度（signalFreshness）未启用，过期信号也会开仓")
+	}
+	if !s.Subsystems["rateGuard"] {
+		warnings = append(warnings, "速率保护（rateGuard）未启用，开仓与兑换次数不受限制")
+	}
+	if !s.Subsystems["risk.stopLoss"] {
+		warnings = append(warnings, "止损（risk.stopLoss）未启用")
+	}
+	if !s.Subsystems["tripwire"] {
+		warnings = append(warnings, "钱包异常冻结（tripwire）未启用")
+	}
+	if s.Paused {
+		warnings = append(warnings, "自动开仓已暂停")
+	}
+	if s.Frozen {
+		warnings = append(warnings, "交易已冻结（tripwire），需通过 /unfreeze 解除")
+	}
+	return warnings
+}
+
+// logConfigSummary 启动时输出生效配置与安全限制，误配置在第一笔交易之前即可发现
+func logConfigSummary() {
+	s := currentConfigSummary()
+	var enabled, disabled []string
+	for name, on := range s.Subsystems {
+		if on {
+			enabled = append(enabled, name)
+		} else {
+			disabled = append(disabled, name)
+		}
+	}
+	sort.Strings(enabled)
+	sort.Strings(disabled)
+	var schedules []string
+	for name, cron := range s.Schedules {
+		schedules = append(schedules, name+"="+cron)
+	}
+	sort.Strings(schedules)
+
+	logInfo("📂 目录", "base", appBaseDir, "data", appDataDir, "config", configFilePath)
+	logInfo("📋 生效配置", "mode", s.Mode, "dryRun", s.DryRun, "demo", s.Demo, "profile", s.Profile, "timezone", s.Timezone, "tradingWindows", len(s.TradingWindows), "blackoutWindows", len(s.Blackouts), "killSwitch", s.KillSwitch)
+	logInfo("📋 开仓参数", "solAmount", s.Sizing.SolAmount, "solSource", s.Sizing.SolSource, "strategy", s.Sizing.Strategy, "ladderLegs", len(s.Sizing.Ladder), "slippagePct", s.Sizing.SlippagePct, "swapMaxFee", s.Sizing.SwapMaxFee, "minFields", len(s.Sizing.MinFields))
+	logInfo("📋 安全限制",
+		"maxConcurrentTasks", s.Limits.MaxConcurrentTasks,
+		"maxOpenPositions", s.Limits.MaxOpenPositions,
+		"maxTokenExposureSOL", s.Limits.MaxTokenExposureSOL,
+		"maxPositionsPerHour", s.Limits.MaxPositionsPerHour,
+		"maxSwapsPerHour", s.Limits.MaxSwapsPerHour,
+		"maxSolDeployedPerHour", s.Limits.MaxSOLDeployedPerHour,
+		"stopLossPercent", s.Limits.StopLossPercent,
+		"takeProfitRules", s.Limits.TakeProfitRules,
+		"signalMaxAgeMinutes", s.Limits.SignalMaxAgeMinutes,
+		"claimMinPendingUSD", s.Limits.ClaimMinPendingUSD,
+		"balanceMinSol", s.Limits.BalanceMinSOL)
+	logInfo("📋 定时任务", "schedules", strings.Join(schedules, " "))
+	logInfo("📋 子系统", "enabled", strings.Join(enabled, ","), "disabled", strings.Join(disabled, ","))
+	for _, w := range s.Warnings {
+		logWarn("⚠️ 配置提示: " + w)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConsolidationConfig 定时兑换的归集策略：把钱包中的代币统一兑换为目标资产，
+// 余额不足门槛的代币与当前持仓所需的代币留在钱包中
+type ConsolidationConfig struct {
+	Enabled              bool               `json:"enabled"`
+	Target               string             `json:"target"`               // 目标资产：SOL（默认）或 USDC
+	MinBalance           float64            `json:"minBalance"`           // 默认最低兑换余额（代币数量，0 表示不限制）
+	MinBalances          map[string]float64 `json:"minBalances"`          // 按代币（ca）覆盖最低兑换余额
+	DustBalance          float64            `json:"dustBalance"`          // 余额不超过该值视为灰尘（0 表示仅余额为 0 的代币）
+	DustAction           string             `json:"dustAction"`           // 灰尘处理：skip 跳过并记入跳过原因统计，ignore 仅跳过
+	ExcludeOpenPositions bool               `json:"excludeOpenPositions"` // 不兑换当前未平仓池（同一钱包）的代币
+}
+
+// 灰尘处理方式
+const (
+	dustActionSkip   = "skip"
+	dustActionIgnore = "ignore"
+)
+
+// 归集策略的兑换跳过原因（记入本轮兑换汇总）
+const (
+	swapSkipTarget       = "target"
+	swapSkipBelowMin     = "below_min"
+	swapSkipDust         = "dust"
+	swapSkipOpenPosition = "open_position"
+)
+
+func (c ConsolidationConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := validateSwapTo("consolidation.target", c.Target); err != nil {
+		return err
+	}
+	if c.MinBalance < 0 || c.DustBalance < 0 {
+		return fmt.Errorf("consolidation.minBalance、dustBalance 不能为负数")
+	}
+	for ca, v := range c.MinBalances {
+		if v < 0 {
+			return fmt.Errorf("consolidation.minBalances[%s] 不能为负数", ca)
+		}
+	}
+	if c.DustAction != dustActionSkip && c.DustAction != dustActionIgnore {
+		return fmt.Errorf("consolidation.dustAction 仅支持 %s 或 %s", dustActionSkip, dustActionIgnore)
+	}
+	return nil
+}
+
+// 定时兑换的输出 mint：归集目标为 USDC 时兑换为 USDC，否则为 jupSwap 默认输出（SOL）
+func consolidationOutputMint() string {
+	cfg := currentConfig().Consolidation
+	if cfg.Enabled && cfg.Target == swapToUSDC {
+		return usdcMint
+	}
+	return ""
+}
+
+// 代币的最低兑换余额
+func (c ConsolidationConfig) minBalanceFor(tokenAddress string) float64 {
+	if v, ok := c.MinBalances[tokenAddress]; ok {
+		return v
+	}
+	return c.MinBalance
+}
+
+// consolidationFilter 返回本轮兑换的过滤函数：返回非空原因时该代币留在钱包中。
+// balances 为持仓输出中的余额（缺失时不检查门槛与灰尘）
+func consolidationFilter(wallet string, balances map[string]float64) func(tokenAddress string) string {
+	cfg := currentConfig().Consolidation
+	if !cfg.Enabled {
+		return func(string) string { return "" }
+	}
+	var open map[string]string
+	if cfg.ExcludeOpenPositions {
+		open = positionTokens(wallet, nil)
+	}
+	return func(tokenAddress string) string {
+		if cfg.Target == swapToUSDC && tokenAddress == usdcMint {
+			return swapSkipTarget
+		}
+		if pool, ok := open[tokenAddress]; ok {
+			recordSkip(subsystemSweep, skipFiltered, pool, tokenAddress, "代币属于未平仓的池")
+			return swapSkipOpenPosition
+		}
+		balance, ok := balances[tokenAddress]
+		if !ok {
+			return ""
+		}
+		if balance <= cfg.DustBalance {
+			if cfg.DustAction == dustActionSkip {
+				recordSkip(subsystemSweep, skipBelowThreshold, "", tokenAddress, fmt.Sprintf("灰尘余额 %g", balance))
+			}
+			return swapSkipDust
+		}
+		if min := cfg.minBalanceFor(tokenAddress); min > 0 && balance < min {
+			recordSkip(subsystemSweep, skipBelowThreshold, "", tokenAddress, fmt.Sprintf("余额 %g 低于最低兑换余额 %g", balance, min))
+			return swapSkipBelowMin
+		}
+		return ""
+	}
+}
+
+// 归集策略的说明（每轮兑换开始时输出一次）
+func consolidationSummary() string {
+	cfg := currentConfig().Consolidation
+	if !cfg.Enabled {
+		return ""
+	}
+	parts := []string{"目标 " + cfg.Target}
+	if cfg.MinBalance > 0 {
+		parts = append(parts, fmt.Sprintf("最低余额 %g", cfg.MinBalance))
+	}
+	if len(cfg.MinBalances) > 0 {
+		parts = append(parts, fmt.Sprintf("%d 个代币单独门槛", len(cfg.MinBalances)))
+	}
+	if cfg.ExcludeOpenPositions {
+		parts = append(parts, "保留未平仓池代币")
+	}
+	return strings.Join(parts, "，")
+}
@@ -0,0 +1,189 @@
+import {
+  Connection,
+  PublicKey,
+  Keypair,
+  Transaction,
+  VersionedTransaction,
+  ComputeBudgetProgram,
+  clusterApiUrl
+} from '@solana/web3.js';
+import {
+  TOKEN_PROGRAM_ID,
+  TOKEN_2022_PROGRAM_ID,
+  getAssociatedTokenAddressSync,
+  createAssociatedTokenAccountIdempotentInstruction
+} from '@solana/spl-token';
+import * as dotenv from 'dotenv';
+import bs58 from 'bs58';
+import CryptoJS from 'crypto-js';
+import { pipedPrivateKey } from './signingKey';
+
+// ===== 结构化输出（Go 端按 "@@event <json>" 行解析，见 scriptproto.go）=====
+function emitEvent(type: string, fields: Record<string, unknown> = {}): void {
+  console.log(`@@event ${JSON.stringify({ type, ...fields })}`);
+}
+
+// 加载环境变量
+dotenv.config();
+
+// 连接配置（RPC_URL 由 main.go 按 RPC 节点池的当前最优节点传入，未设置时使用公共节点）
+const connection = new Connection(process.env.RPC_URL || clusterApiUrl('mainnet-beta'), 'confirmed');
+
+// 命令行参数解析
+const argv = process.argv.slice(2);
+
+// 清理字符串，移除引号和多余空格
+function sanitizeString(str: string): string {
+  return str.replace(/['"]/g, '').trim();
+}
+
+// --mints=<mint1,mint2,...>：需要预创建关联代币账户的代币
+function getMintsFromArgs(): string[] {
+  for (const arg of argv) {
+    if (arg.startsWith('--mints=')) {
+      return sanitizeString(arg.split('=')[1]).split(',').map((m) => m.trim()).filter((m) => m !== '');
+    }
+  }
+  return [];
+}
+
+// --batch=<n>：每笔交易最多创建的账户数，默认 5
+function getBatchSizeFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--batch=')) {
+      const v = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isInteger(v) && v > 0) return v;
+      throw new Error(`--batch 取值无效: ${arg}`);
+    }
+  }
+  return 5;
+}
+
+// --cu-price=<microLamports>：计算单元价格（优先费），未传入时不设置
+function resolveComputeUnitPriceFromArgs(): number | undefined {
+  for (const arg of argv) {
+    if (arg.startsWith('--cu-price=')) {
+      const v = Number(sanitizeString(arg.split('=')[1]));
+      if (Number.isInteger(v) && v >= 0) return v;
+      throw new Error(`--cu-price 取值无效: ${arg}`);
+    }
+  }
+  return undefined;
+}
+
+// --blockhash=<hash>:<lastValidBlockHeight>：main.go 预取的区块哈希，未传入时请求 getLatestBlockhash
+async function resolveBlockhash(): Promise<string> {
+  for (const arg of argv) {
+    if (arg.startsWith('--blockhash=')) {
+      const [blockhash] = sanitizeString(arg.slice('--blockhash='.length)).split(':');
+      if (blockhash) return blockhash;
+    }
+  }
+  return (await connection.getLatestBlockhash()).blockhash;
+}
+
+/**
+ * 解密私钥
+ * @param encryptedPrivateKey 加密的私钥
+ * @param password 解密密码
+ * @returns 解密后的私钥字符串
+ */
+function decryptPrivateKey(encryptedPrivateKey: string, password: string): string {
+  try {
+    const decrypted = CryptoJS.AES.decrypt(encryptedPrivateKey, password);
+    return decrypted.toString(CryptoJS.enc.Utf8);
+  } catch (error) {
+    throw new Error('私钥解密失败，请检查密码是否正确');
+  }
+}
+
+function loadUserKeypair(): Keypair {
+  const pipedKey = pipedPrivateKey();
+  if (pipedKey !== undefined) {
+    return Keypair.fromSecretKey(bs58.decode(pipedKey));
+  }
+  if (process.env.PRIVATE_KEY_ENCRYPTED === 'true') {
+    if (!process.env.PRIVATE_KEY_PASSWORD) {
+      throw new Error('使用加密私钥时，必须设置PRIVATE_KEY_PASSWORD环境变量');
+    }
+    const decryptedPrivateKey = decryptPrivateKey(process.env.PRIVATE_KEY!, process.env.PRIVATE_KEY_PASSWORD);
+    return Keypair.fromSecretKey(bs58.decode(decryptedPrivateKey));
+  }
+  return Keypair.fromSecretKey(bs58.decode(process.env.PRIVATE_KEY!));
+}
+
+/**
+ * 为指定代币预创建当前钱包的关联代币账户（已存在的跳过），按批次合并为少量交易
+ */
+async function createTokenAccounts(): Promise<void> {
+  try {
+    const mints = getMintsFromArgs();
+    if (mints.length === 0) {
+      throw new Error('缺少必需的代币列表：请通过 --mints= 传入');
+    }
+    const userKeypair = loadUserKeypair();
+    const owner = userKeypair.publicKey;
+    const mintKeys = mints.map((m) => new PublicKey(m));
+
+    // 代币所属的 token program（Token-2022 的关联账户地址不同）
+    const mintInfos = await connection.getMultipleAccountsInfo(mintKeys);
+    const targets: { mint: PublicKey; ata: PublicKey; programId: PublicKey }[] = [];
+    mintKeys.forEach((mint, i) => {
+      const info = mintInfos[i];
+      if (!info) {
+        console.log(`⚠️ 代币不存在，跳过: ${mint.toString()}`);
+        return;
+      }
+      const programId = info.owner.equals(TOKEN_2022_PROGRAM_ID) ? TOKEN_2022_PROGRAM_ID : TOKEN_PROGRAM_ID;
+      targets.push({ mint, ata: getAssociatedTokenAddressSync(mint, owner, false, programId), programId });
+    });
+
+    const ataInfos = await connection.getMultipleAccountsInfo(targets.map((t) => t.ata));
+    const missing = targets.filter((t, i) => {
+      if (ataInfos[i]) {
+        console.log(`✅ 关联代币账户已存在: ${t.mint.toString()} -> ${t.ata.toString()}`);
+        emitEvent('account', { token: t.mint.toString(), account: t.ata.toString() });
+        return false;
+      }
+      return true;
+    });
+    if (missing.length === 0) {
+      emitEvent('status', { status: 'ok' });
+      return;
+    }
+
+    const batchSize = getBatchSizeFromArgs();
+    const microLamports = resolveComputeUnitPriceFromArgs();
+    for (let i = 0; i < missing.length; i += batchSize) {
+      const batch = missing.slice(i, i + batchSize);
+      const transaction = new Transaction();
+      if (microLamports !== undefined) {
+        transaction.add(ComputeBudgetProgram.setComputeUnitPrice({ microLamports }));
+      }
+      for (const t of batch) {
+        transaction.add(createAssociatedTokenAccountIdempotentInstruction(owner, t.ata, owner, t.mint, t.programId));
+      }
+      transaction.feePayer = owner;
+      transaction.recentBlockhash = await resolveBlockhash();
+      transaction.sign(userKeypair);
+      const versionedTransaction = new VersionedTransaction(transaction.compileMessage());
+      versionedTransaction.sign([userKeypair]);
+      const txHash = await connection.sendTransaction(versionedTransaction);
+      console.log(`✅ 已创建 ${batch.length} 个关联代币账户: ${txHash}`);
+      emitEvent('signature', { signature: txHash, action: 'createTokenAccounts' });
+      for (const t of batch) {
+        emitEvent('account', { token: t.mint.toString(), account: t.ata.toString() });
+      }
+    }
+    emitEvent('status', { status: 'ok' });
+  } catch (error) {
+    console.error('错误:', error);
+    emitEvent('error', { code: 'CREATE_TOKEN_ACCOUNTS_FAILED', message: error instanceof Error ? error.message : String(error) });
+    process.exitCode = 1;
+  }
+}
+
+// 运行
+if (require.main === module) {
+  createTokenAccounts();
+}
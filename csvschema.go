@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 保留的信号拒绝记录数
+const maxCSVRejections = 500
+
+// CSVSchemaConfig CSV 表头到统一字段名的映射与信号校验：各源的 headerMap 优先，其余表头按 aliases 映射
+type CSVSchemaConfig struct {
+	Aliases       map[string][]string `json:"aliases"`       // 统一字段名 -> 可接受的表头名（忽略大小写与首尾空白）；表头中已有该统一字段时不映射
+	Required      []string            `json:"required"`      // 映射后表头必须包含的字段，启动时与重新读取表头时校验，缺失时不读取该 CSV
+	AddressFields []string            `json:"addressFields"` // 取值必须是有效 Solana 地址的字段（所有信号来源），非空且无效时拒绝该信号
+}
+
+// CSVRejection 一条因字段无效被拒绝的信号（data/state/csv_rejections.json）
+type CSVRejection struct {
+	Time   string `json:"time"`
+	Source string `json:"source"`
+	Line   int    `json:"line,omitempty"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Error  string `json:"error"`
+}
+
+func (c CSVSchemaConfig) validate() error {
+	targets := map[string]string{}
+	for field, aliases := range c.Aliases {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("csvSchema.aliases 的字段名不能为空")
+		}
+		for _, a := range aliases {
+			key := strings.ToLower(strings.TrimSpace(a))
+			if key == "" {
+				return fmt.Errorf("csvSchema.aliases.%s 含空的表头名", field)
+			}
+			if prev, ok := targets[key]; ok && prev != field {
+				return fmt.Errorf("csvSchema.aliases 表头名 %q 同时映射到 %s 与 %s", a, prev, field)
+			}
+			targets[key] = field
+		}
+	}
+	for _, f := range c.Required {
+		if strings.TrimSpace(f) == "" {
+			return fmt.Errorf("csvSchema.required 含空字段名")
+		}
+	}
+	return nil
+}
+
+// mapCSVHeaders 按 headerMap 与 aliases 得到各列的统一字段名，并校验必需字段
+func mapCSVHeaders(source CSVSourceConfig, headers []string) ([]string, error) {
+	schema := currentConfig().CSVSchema
+	alias := map[string]string{}
+	for field, names := range schema.Aliases {
+		for _, n := range names {
+			alias[strings.ToLower(strings.TrimSpace(n))] = field
+		}
+	}
+
+	fields := make([]string, len(headers))
+	present := map[string]bool{}
+	for i, h := range headers {
+		name := strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if mapped, ok := source.HeaderMap[h]; ok {
+			name = mapped
+		}
+		fields[i] = name
+		present[name] = true
+	}
+	for i, h := range headers {
+		if _, ok := source.HeaderMap[h]; ok {
+			continue
+		}
+		if field, ok := alias[strings.ToLower(fields[i])]; ok && !present[field] {
+			fields[i] = field
+			present[field] = true
+		}
+	}
+
+	var missing []string
+	for _, f := range schema.Required {
+		if !present[f] {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV表头缺少必需字段 %s（表头: %s），可在 csvSources.%s.headerMap 或 csvSchema.aliases 中映射",
+			strings.Join(missing, "、"), strings.Join(headers, ","), source.Name)
+	}
+	return fields, nil
+}
+
+// validateSignalFields 检查信号的地址字段，返回第一个无效的字段、取值与原因（全部有效时 field 为空）
+func validateSignalFields(data map[string]interface{}) (field, value, reason string) {
+	for _, f := range currentConfig().CSVSchema.AddressFields {
+		s, _ := data[f].(string)
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if err := validateAddress(s); err != nil {
+			return f, s, err.Error()
+		}
+	}
+	return "", "", ""
+}
+
+// validateAddress Solana 地址：base58 编码的 32 字节公钥
+func validateAddress(s string) error {
+	if len(s) < 32 || len(s) > 44 {
+		return fmt.Errorf("长度 %d 不在 32~44 之间", len(s))
+	}
+	b, err := decodeBase58(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != 32 {
+		return fmt.Errorf("解码后为 %d 字节，应为 32 字节", len(b))
+	}
+	return nil
+}
+
+// recordCSVRejection 记录被拒绝的信号：跳过原因 invalid、拒绝记录与日志
+func recordCSVRejection(sig Signal, pool, ca, field, value, reason string) {
+	metricCSVRows.Inc("invalid")
+	detail := fmt.Sprintf("字段 %s 的值 %q 不是有效地址: %s", field, value, reason)
+	if sig.Line > 0 {
+		detail = fmt.Sprintf("第 %d 行%s", sig.Line, detail)
+	}
+	recordSkip(subsystemEntry, skipInvalid, pool, ca, detail+"（来源 "+sig.Source+"）")
+	logOutput("🚫 [%s] 信号无效，已拒绝: %s\n", sig.Source, detail)
+	appendHistory("csv_rejections", CSVRejection{
+		Time: time.Now().Format(time.RFC3339), Source: sig.Source, Line: sig.Line, Field: field, Value: value, Error: reason,
+	}, maxCSVRejections)
+}
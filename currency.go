@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 报表计价货币
+const (
+	currencySOL  = "SOL"
+	currencyUSDC = "USDC"
+	currencyUSD  = "USD"
+)
+
+// ReportingConfig 报表、告警与面板金额的计价货币。历史金额按事件发生时记录的汇率折算，而不是当前汇率
+type ReportingConfig struct {
+	Currency            string `json:"currency"`            // SOL（默认）、USDC 或 USD
+	RateIntervalSeconds int    `json:"rateIntervalSeconds"` // 定期记录 SOL、USDC 美元价格的间隔，0 表示只在领取时记录 SOL 价格
+}
+
+func (c ReportingConfig) validate() error {
+	if !validCurrency(c.Currency) {
+		return fmt.Errorf("reporting.currency 仅支持 %s、%s 或 %s: %s", currencySOL, currencyUSDC, currencyUSD, c.Currency)
+	}
+	if c.RateIntervalSeconds < 0 {
+		return fmt.Errorf("reporting.rateIntervalSeconds 不能为负数")
+	}
+	return nil
+}
+
+func validCurrency(currency string) bool {
+	switch currency {
+	case currencySOL, currencyUSDC, currencyUSD:
+		return true
+	}
+	return false
+}
+
+func reportCurrency() string {
+	return currentConfig().Reporting.Currency
+}
+
+// 记录 SOL 或 USDC 的美元价格（写入价格历史 data/prices/history/<mint>.jsonl，供历史金额折算）；
+// 演示模式下的模拟价格不记录
+func recordFXRate(mint string, usd float64, source string) {
+	if usd <= 0 || (isDemo() && source == "claim") {
+		return
+	}
+	recordPriceSample("", mint, formatPrice(usd), source, nil)
+}
+
+type rateSample struct {
+	at  time.Time
+	usd float64
+}
+
+// fxRates 按时间排序的 SOL、USDC 美元价格，生成一份报表时加载一次
+type fxRates map[string][]rateSample
+
+func loadFXRates() fxRates {
+	rates := fxRates{}
+	for _, mint := range []string{solMint, usdcMint} {
+		var samples []rateSample
+		for _, p := range storedPrices(mint, time.Time{}) {
+			samples = append(samples, rateSample{at: p.At, usd: p.Price})
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].at.Before(samples[j].at) })
+		rates[mint] = samples
+	}
+	return rates
+}
+
+// at 时刻的美元价格：取 at 之前最近的一次记录，没有时取之后的第一次；USDC 无记录时按 1 美元
+func (r fxRates) at(mint string, at time.Time) float64 {
+	samples := r[mint]
+	if len(samples) == 0 {
+		if mint == usdcMint {
+			return 1
+		}
+		return 0
+	}
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].at.After(at) })
+	if i == 0 {
+		return samples[0].usd
+	}
+	return samples[i-1].usd
+}
+
+// convert 将 at 时刻的一笔金额折算为 currency。sol 与 usd 给出其一即可（usd 优先，为事件发生时的美元价值）；
+// 缺少 at 时刻的 SOL 价格时返回 false
+func (r fxRates) convert(currency string, sol, usd float64, at time.Time) (float64, bool) {
+	if currency == currencySOL && usd == 0 {
+		return sol, true
+	}
+	if usd == 0 && sol != 0 {
+		solUSD := r.at(solMint, at)
+		if solUSD <= 0 {
+			return 0, false
+		}
+		usd = sol * solUSD
+	}
+	switch currency {
+	case currencySOL:
+		solUSD := r.at(solMint, at)
+		if solUSD <= 0 {
+			return 0, false
+		}
+		return usd / solUSD, true
+	case currencyUSDC:
+		return usd / r.at(usdcMint, at), true
+	default:
+		return usd, true
+	}
+}
+
+// 按计价货币格式化金额，如 "0.8400 SOL"、"12.30 USD"
+func formatMoney(v float64, currency string) string {
+	if currency == currencySOL {
+		return fmt.Sprintf("%.4f %s", v, currency)
+	}
+	return fmt.Sprintf("%.2f %s", v, currency)
+}
+
+// 定期记录 SOL 与 USDC 的美元价格（Jupiter Price API）
+func sampleFXRates() {
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	for _, mint := range []string{solMint, usdcMint} {
+		usd, err := jupiterUSDPrice(ctx, mint)
+		if err != nil {
+			logDebug("记录汇率失败", "mint", mint, "error", err)
+			continue
+		}
+		recordFXRate(mint, usd, priceSourceJupiter)
+	}
+}
+
+func startFXRateSampler() {
+	cfg := currentConfig().Reporting
+	if cfg.RateIntervalSeconds <= 0 {
+		return
+	}
+	interval := time.Duration(cfg.RateIntervalSeconds) * time.Second
+	logOutput("💱 启动汇率记录（每%v，报表计价 %s）\n", interval, cfg.Currency)
+	sampleFXRates()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止汇率记录\n")
+			return
+		case <-ticker.C:
+			sampleFXRates()
+		}
+	}
+}
+
+// 按最近记录的汇率计算 SOL 或 USDC 数量的美元价值（汇率未知时为 0）
+func usdValue(mint string, amount float64) float64 {
+	if mint == swapToSOL {
+		mint = solMint
+	}
+	if amount <= 0 || (mint != solMint && mint != usdcMint) {
+		return 0
+	}
+	return amount * loadFXRates().at(mint, time.Now())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// -daemon：在 systemd 等进程管理器下运行，终端输出去掉 emoji 与 ANSI 颜色，并带 journald 的级别前缀
+var daemonMode bool
+
+// writePIDFile 写入 PID 文件；文件中的进程仍在运行时返回错误（防止同一数据目录启动两个实例）
+func writePIDFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if content, err := os.ReadFile(path); err == nil {
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+		if pid > 0 && pid != os.Getpid() && syscall.Kill(pid, 0) == nil {
+			return fmt.Errorf("已有实例在运行（PID %d，见 %s）", pid, path)
+		}
+		logWarn("⚠️ 覆盖过期的 PID 文件", "file", path, "pid", pid)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePIDFile 退出时删除 PID 文件（只删除本进程写入的）
+func removePIDFile(path string) {
+	if path == "" {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(content)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
+
+// journaldPrefix journald 识别的 syslog 级别前缀（<3> 错误、<4> 警告、<6> 信息、<7> 调试）
+func journaldPrefix(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "<3>"
+	case level >= slog.LevelWarn:
+		return "<4>"
+	case level >= slog.LevelInfo:
+		return "<6>"
+	}
+	return "<7>"
+}
+
+// consoleLine 终端输出的一行：daemon 模式下去掉 emoji 与颜色、行首空行，并加上级别前缀
+func consoleLine(level slog.Level, line string) string {
+	if !daemonMode {
+		return line
+	}
+	return journaldPrefix(level) + strings.TrimLeft(plainText(line), "\n")
+}
+
+// plainText 去掉 ANSI 转义序列与 emoji（行首 emoji 后的空格一并去掉），用于 daemon 模式的日志
+func plainText(s string) string {
+	var sb strings.Builder
+	lineStart := true
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '[' {
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			i = j + 1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if isEmojiRune(r) {
+			if lineStart && i < len(s) && s[i] == ' ' {
+				i++
+			}
+			continue
+		}
+		sb.WriteRune(r)
+		lineStart = r == '\n' || (lineStart && r == ' ')
+	}
+	return sb.String()
+}
+
+// isEmojiRune 日志中使用的 emoji 与其变体选择符、连接符（箭头等普通符号保留）
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // 表情、图形符号、交通与地图等
+		r >= 0x2600 && r <= 0x27BF, // 杂项符号与装饰符号（✅ ❌ ⚠ ⚡ 等）
+		r >= 0x2300 && r <= 0x23FF, // ⏰ ⏳ ⏸ ⏹ ⏭ 等
+		r >= 0x2B00 && r <= 0x2BFF, // ⭐ ⬆ 等
+		r == 0xFE0F, r == 0x200D, r == 0x20E3:
+		return true
+	}
+	return false
+}
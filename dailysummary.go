@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 汇总文件格式
+const (
+	summaryFormatJSON = "json"
+	summaryFormatCSV  = "csv"
+)
+
+// 按日累计保留的天数
+const dailyTallyRetentionDays = 7
+
+// DailySummaryConfig 每日汇总：按 schedules.dailySummary 汇总当天的领取、兑换、手续费、盈亏与持仓数，写入日报目录并可推送告警
+type DailySummaryConfig struct {
+	Enabled bool     `json:"enabled"`
+	Formats []string `json:"formats"` // json / csv
+	Notify  bool     `json:"notify"`  // 通过告警后端推送（事件 daily_summary）
+}
+
+// DailyTally 一天内随执行累计的计数（data/state/daily_tally.json: 日期 -> 计数）
+type DailyTally struct {
+	ClaimRuns    int                `json:"claimRuns"` // 领取脚本执行次数（含阶梯档位、事件与 API 触发）
+	Claimed      int                `json:"claimed"`   // 实际领取的次数
+	ClaimFailed  int                `json:"claimFailed"`
+	Earned       map[string]float64 `json:"earned"` // 代币 mint -> 领取数量
+	ClaimFeeSOL  float64            `json:"claimFeeSOL"`
+	Swaps        int                `json:"swaps"`
+	SwapFailed   int                `json:"swapFailed"`
+	SwapProceeds map[string]float64 `json:"swapProceeds"` // 输出代币 -> 合计数量
+	SwapValueUSD float64            `json:"swapValueUSD"`
+	SwapFeeSOL   float64            `json:"swapFeeSOL"`
+	Deposits     int                `json:"deposits"` // 开仓、阶梯档位与追加流动性
+	DepositSOL   float64            `json:"depositSOL"`
+}
+
+// DailySummary 每日汇总（data/reports/summary_<日期>.json / .csv）
+type DailySummary struct {
+	Date string `json:"date"`
+	DailyTally
+	FeesSOL         float64 `json:"feesSOL"`        // 领取与兑换交易手续费合计
+	TxFeeSOL        float64 `json:"txFeeSOL"`       // 当天全部链上交易的实际交易费（含优先费，需启用 txCost）
+	PriorityFeeSOL  float64 `json:"priorityFeeSOL"` // 其中的优先费
+	RentSOL         float64 `json:"rentSOL"`        // 当天的净账户押金（负数为净退回）
+	ActivePositions int     `json:"activePositions"`
+	OpenedToday     int     `json:"openedToday"`
+	ClosedToday     int     `json:"closedToday"`
+	Currency        string  `json:"currency"`
+	Cost            float64 `json:"cost"` // 全部台账（未平仓与已平仓）的合计
+	Value           float64 `json:"value"`
+	Realized        float64 `json:"realized"`
+	Unrealized      float64 `json:"unrealized"`
+	RealizedToday   float64 `json:"realizedToday"` // 当日平仓的池的已实现盈亏
+	GeneratedAt     string  `json:"generatedAt"`
+	Instance        string  `json:"instance,omitempty"`
+	Environment     string  `json:"environment,omitempty"`
+}
+
+var dailyTallyMutex sync.Mutex
+
+func (c DailySummaryConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Formats) == 0 {
+		return fmt.Errorf("dailySummary.formats 不能为空")
+	}
+	for _, f := range c.Formats {
+		if f != summaryFormatJSON && f != summaryFormatCSV {
+			return fmt.Errorf("dailySummary.formats 仅支持 json、csv: %s", f)
+		}
+	}
+	return nil
+}
+
+// updateDailyTally 修改当天的计数（读-改-写），同时清理超过保留天数的记录
+func updateDailyTally(fn func(t *DailyTally)) {
+	dailyTallyMutex.Lock()
+	defer dailyTallyMutex.Unlock()
+	tallies := map[string]*DailyTally{}
+	if err := loadStateFile("daily_tally", &tallies); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	day := appNow().Format("2006-01-02")
+	t := tallies[day]
+	if t == nil {
+		t = &DailyTally{}
+		tallies[day] = t
+	}
+	if t.Earned == nil {
+		t.Earned = map[string]float64{}
+	}
+	if t.SwapProceeds == nil {
+		t.SwapProceeds = map[string]float64{}
+	}
+	fn(t)
+	cutoff := appNow().AddDate(0, 0, -dailyTallyRetentionDays).Format("2006-01-02")
+	for d := range tallies {
+		if d < cutoff {
+			delete(tallies, d)
+		}
+	}
+	if err := saveStateFile("daily_tally", tallies); err != nil {
+		logOutput("❌ 保存每日计数失败: %v\n", err)
+	}
+}
+
+// 当天（或指定日期）的计数
+func loadDailyTally(day string) DailyTally {
+	dailyTallyMutex.Lock()
+	defer dailyTallyMutex.Unlock()
+	tallies := map[string]*DailyTally{}
+	if err := loadStateFile("daily_tally", &tallies); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if t := tallies[day]; t != nil {
+		return *t
+	}
+	return DailyTally{Earned: map[string]float64{}, SwapProceeds: map[string]float64{}}
+}
+
+// 领取脚本执行一次（全局领取、事件与 API 触发、阶梯档位）
+func tallyClaim(o ScriptOutput, err error) {
+	updateDailyTally(func(t *DailyTally) {
+		t.ClaimRuns++
+		if err != nil {
+			t.ClaimFailed++
+			return
+		}
+		for token, amount := range o.Claimed() {
+			t.Earned[token] += amount
+		}
+		if fee, ok := o.Value("feeSOL", ""); ok {
+			t.ClaimFeeSOL += fee
+		}
+		if claimOutputClaimed(o) {
+			t.Claimed++
+		}
+	})
+}
+
+// 兑换一次（定时兑换与风控、阶梯清理等轮外兑换）；sale 为 nil 表示失败
+func tallySwap(sale *SwapSale) {
+	updateDailyTally(func(t *DailyTally) {
+		if sale == nil {
+			t.SwapFailed++
+			return
+		}
+		t.Swaps++
+		t.SwapProceeds[sale.OutputMint] += sale.Proceeds
+		t.SwapValueUSD += sale.ValueUSD
+		t.SwapFeeSOL += sale.FeeSOL
+	})
+}
+
+// 投入一次（开仓、阶梯档位与追加流动性）
+func tallyDeposit(solAmount float64) {
+	updateDailyTally(func(t *DailyTally) {
+		t.Deposits++
+		t.DepositSOL += solAmount
+	})
+}
+
+// buildDailySummary 汇总当天的计数、盈亏台账与仓位生命周期
+func buildDailySummary(day string) DailySummary {
+	currency := reportCurrency()
+	s := DailySummary{Date: day, DailyTally: loadDailyTally(day), Currency: currency, GeneratedAt: time.Now().Format(time.RFC3339),
+		Instance: deployInstance, Environment: deployEnvironment}
+	s.FeesSOL = s.ClaimFeeSOL + s.SwapFeeSOL
+	if c := dayTxCosts(day); c.Txs > 0 {
+		s.TxFeeSOL, s.PriorityFeeSOL, s.RentSOL = lamportsToSOL(c.FeeLamports), lamportsToSOL(c.PriorityLamports), lamportsToSOL(c.RentLamports)
+	}
+
+	report := buildPnLReport(currency)
+	s.Cost, s.Value, s.Realized, s.Unrealized = report.Total.Cost, report.Total.Value, report.Total.Realized, report.Total.Unrealized
+	for _, p := range report.Pools {
+		if p.State == "closed" && localDay(p.ClosedAt) == day {
+			s.RealizedToday += p.Realized
+		}
+	}
+	for _, r := range listPositionRecords() {
+		if r.State != positionStateClosed {
+			s.ActivePositions++
+		}
+		if localDay(r.OpenedAt) == day {
+			s.OpenedToday++
+		}
+		if r.ClosedAt != "" && localDay(r.ClosedAt) == day {
+			s.ClosedToday++
+		}
+	}
+	return s
+}
+
+// writeDailySummary 生成当天的汇总，写入日报目录并按配置推送（定时任务 dailySummary）
+func writeDailySummary() {
+	cfg := currentConfig().DailySummary
+	if !cfg.Enabled {
+		return
+	}
+	s := buildDailySummary(appNow().Format("2006-01-02"))
+	if err := os.MkdirAll(pnlReportDir(), 0755); err != nil {
+		logError("❌ 创建日报目录失败", "dir", pnlReportDir(), "error", err)
+		return
+	}
+	var files []string
+	for _, format := range cfg.Formats {
+		path := filepath.Join(pnlReportDir(), "summary_"+s.Date+"."+format)
+		var err error
+		if format == summaryFormatCSV {
+			err = writeDailySummaryCSV(path, s)
+		} else {
+			err = writeDailySummaryJSON(path, s)
+		}
+		if err != nil {
+			logError("❌ 写入每日汇总失败", "file", path, "error", err)
+			continue
+		}
+		files = append(files, path)
+	}
+	logInfo("📰 每日汇总已生成", "date", s.Date, "files", strings.Join(files, ","), "summary", s.line())
+	if cfg.Notify {
+		notifyKeyed(eventDailySummary, levelInfo, s.Date, "每日汇总 "+s.Date, s.line(), s.fields())
+	}
+}
+
+func writeDailySummaryJSON(path string, s DailySummary) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// CSV 为表头 + 一行；领取与兑换的各代币数量按 mint=数量 以 / 连接
+func writeDailySummaryCSV(path string, s DailySummary) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	w := csv.NewWriter(file)
+	w.Write([]string{"date", "claimRuns", "claimed", "claimFailed", "earned", "claimFeeSOL", "swaps", "swapFailed", "swapProceeds",
+		"swapValueUSD", "swapFeeSOL", "feesSOL", "deposits", "depositSOL", "activePositions", "openedToday", "closedToday",
+		"currency", "cost", "value", "realized", "unrealized", "realizedToday", "instance", "environment",
+		"txFeeSOL", "priorityFeeSOL", "rentSOL"})
+	w.Write([]string{s.Date, strconv.Itoa(s.ClaimRuns), strconv.Itoa(s.Claimed), strconv.Itoa(s.ClaimFailed), amountList(s.Earned), f(s.ClaimFeeSOL),
+		strconv.Itoa(s.Swaps), strconv.Itoa(s.SwapFailed), amountList(s.SwapProceeds), f(s.SwapValueUSD), f(s.SwapFeeSOL), f(s.FeesSOL),
+		strconv.Itoa(s.Deposits), f(s.DepositSOL), strconv.Itoa(s.ActivePositions), strconv.Itoa(s.OpenedToday), strconv.Itoa(s.ClosedToday),
+		s.Currency, f(s.Cost), f(s.Value), f(s.Realized), f(s.Unrealized), f(s.RealizedToday), s.Instance, s.Environment,
+		f(s.TxFeeSOL), f(s.PriorityFeeSOL), f(s.RentSOL)})
+	w.Flush()
+	return w.Error()
+}
+
+// 按 mint 排序的 "mint=数量" 列表
+func amountList(amounts map[string]float64) string {
+	keys := make([]string, 0, len(amounts))
+	for k := range amounts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, amounts[k]))
+	}
+	return strings.Join(parts, " / ")
+}
+
+// 单行汇总，如 "领取 12/15 次（失败 1），兑换 3 次（失败 0），手续费 0.000120 SOL，开仓 2 次，持仓 5 个，已实现 $1.20（当日平仓 $0.30），未实现 -$0.40"
+func (s DailySummary) line() string {
+	line := fmt.Sprintf("领取 %d/%d 次（失败 %d），兑换 %d 次（失败 %d），手续费 %.6f SOL，开仓 %d 次，持仓 %d 个，已实现 %s（当日平仓 %s），未实现 %s",
+		s.Claimed, s.ClaimRuns, s.ClaimFailed, s.Swaps, s.SwapFailed, s.FeesSOL, s.Deposits, s.ActivePositions,
+		formatMoney(s.Realized, s.Currency), formatMoney(s.RealizedToday, s.Currency), formatMoney(s.Unrealized, s.Currency))
+	if s.TxFeeSOL > 0 {
+		line += fmt.Sprintf("，链上交易费 %.6f SOL（优先费 %.6f），净押金 %.6f SOL", s.TxFeeSOL, s.PriorityFeeSOL, s.RentSOL)
+	}
+	return line
+}
+
+// 告警字段
+func (s DailySummary) fields() map[string]string {
+	fields := map[string]string{
+		"claims":    fmt.Sprintf("%d/%d", s.Claimed, s.ClaimRuns),
+		"swaps":     strconv.Itoa(s.Swaps),
+		"fees":      fmt.Sprintf("%.6f SOL", s.FeesSOL),
+		"positions": strconv.Itoa(s.ActivePositions),
+		"realized":  formatMoney(s.Realized, s.Currency),
+		"pnlToday":  formatMoney(s.RealizedToday, s.Currency),
+	}
+	if len(s.Earned) > 0 {
+		fields["earned"] = amountList(s.Earned)
+	}
+	if s.TxFeeSOL > 0 {
+		fields["txFees"] = fmt.Sprintf("%.6f SOL", s.TxFeeSOL)
+		fields["rent"] = fmt.Sprintf("%.6f SOL", s.RentSOL)
+	}
+	return fields
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内嵌的 Web 面板（web/index.html），构建在 HTTP 管理接口之上
+//
+//go:embed web
+var webFS embed.FS
+
+// 内存中保留的最近日志行数（面板实时日志）
+const logRingSize = 1000
+
+// 保留的领取轮次与兑换记录数
+const (
+	maxClaimHistory = 50
+	maxSwapHistory  = 200
+)
+
+// LogLine 一行日志（Seq 单调递增，面板按 since 增量拉取）
+type LogLine struct {
+	Seq  int64  `json:"seq"`
+	Time string `json:"time"`
+	Text string `json:"text"`
+}
+
+type logRingBuffer struct {
+	mu    sync.Mutex
+	lines []LogLine
+	seq   int64
+}
+
+var logRing = &logRingBuffer{}
+
+func (b *logRingBuffer) add(text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	b.lines = append(b.lines, LogLine{Seq: b.seq, Time: time.Now().Format("15:04:05"), Text: text})
+	if len(b.lines) > logRingSize {
+		b.lines = b.lines[len(b.lines)-logRingSize:]
+	}
+}
+
+// since 之后的日志，最多 limit 行
+func (b *logRingBuffer) since(since int64, limit int) []LogLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := []LogLine{}
+	for _, l := range b.lines {
+		if l.Seq > since {
+			result = append(result, l)
+		}
+	}
+	if len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// 追加到状态文件中的历史列表（保留最近 max 条）
+func appendHistory[T any](name string, item T, max int) {
+	var items []T
+	if err := loadStateFile(name, &items); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	items = append(items, item)
+	if len(items) > max {
+		items = items[len(items)-max:]
+	}
+	if err := saveStateFile(name, items); err != nil {
+		logOutput("❌ 保存%s失败: %v\n", name, err)
+	}
+}
+
+func loadHistory[T any](name string) []T {
+	items := []T{}
+	if err := loadStateFile(name, &items); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return items
+}
+
+// SwapRecord 一次程序发起的兑换（含风控、阶梯清理等轮外兑换）
+type SwapRecord struct {
+	Time string `json:"time"`
+	SwapSale
+}
+
+func recordSwapHistory(sale SwapSale) {
+	appendHistory("swap_history", SwapRecord{Time: time.Now().Format(time.RFC3339), SwapSale: sale}, maxSwapHistory)
+}
+
+func recordClaimHistory(s ClaimRoundSummary) {
+	appendHistory("claim_history", s, maxClaimHistory)
+}
+
+// 查询参数中的正整数，缺省或非法时取 def
+func queryInt(r *http.Request, key string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// 面板使用的接口与静态页面
+func registerDashboardRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/logs", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+		writeJSON(w, http.StatusOK, logRing.since(since, queryInt(r, "limit", 200)))
+	}))
+
+	mux.HandleFunc("/claims/history", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[ClaimRoundSummary]("claim_history"))
+	}))
+
+	mux.HandleFunc("/swaps/history", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, loadHistory[SwapRecord]("swap_history"))
+	}))
+
+	// /prices/<ca>?hours=24 价格历史；带 interval=1m|5m|1h 时返回 K 线
+	mux.HandleFunc("/prices/", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/prices/")
+		if token == "" || strings.ContainsAny(token, `/\.`) {
+			writeError(w, http.StatusBadRequest, "invalid token")
+			return
+		}
+		since := time.Now().Add(-time.Duration(queryInt(r, "hours", 24)) * time.Hour)
+		if interval := r.URL.Query().Get("interval"); interval != "" {
+			bars, err := loadOHLC(token, interval, since)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, bars)
+			return
+		}
+		samples := loadPriceHistory(token, since)
+		if samples == nil {
+			samples = []PriceSample{}
+		}
+		writeJSON(w, http.StatusOK, samples)
+	}))
+
+	if !currentConfig().API.Dashboard {
+		return
+	}
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		logOutput("❌ 加载内嵌面板失败: %v\n", err)
+		return
+	}
+	mux.Handle("/ui/", http.StripPrefix("/ui/", http.FileServer(http.FS(sub))))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	})
+}
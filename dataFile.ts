@@ -0,0 +1,88 @@
+import fs from 'fs';
+import path from 'path';
+
+// 数据目录 JSON 文件的原子写入与建议锁，约定与 main 程序的 datafile.go 相同：
+// 写入先写同目录下的 .<文件名>.<随机>.tmp 再重命名覆盖；读改写期间持有 <文件>.lock（O_EXCL 创建，内容为 PID 与时间）
+
+const LOCK_TIMEOUT_MS = 10000;
+const LOCK_STALE_MS = 30000; // 超过该时长的锁视为持有方已崩溃，直接删除
+const LOCK_RETRY_MS = 20;
+
+// 程序目录与数据目录（由 Go 调度程序通过环境变量传入；单独运行时为脚本所在目录及其下的 data）
+export const BASE_DIR = process.env.METEORA_BASE_DIR || __dirname;
+export const DATA_DIR = process.env.METEORA_DATA_DIR || path.join(BASE_DIR, 'data');
+
+// data 目录下池文件的路径
+export function poolFilePath(poolAddress: string): string {
+  return path.join(DATA_DIR, `${poolAddress}.json`);
+}
+
+function sleepSync(ms: number): void {
+  Atomics.wait(new Int32Array(new SharedArrayBuffer(4)), 0, 0, ms);
+}
+
+// 原子写入：读取方只会看到完整的旧内容或新内容
+export function writeFileAtomic(file: string, content: string): void {
+  const suffix = `${process.pid}${Math.random().toString(36).slice(2, 8)}`;
+  const tmp = path.join(path.dirname(file), `.${path.basename(file)}.${suffix}.tmp`);
+  try {
+    const fd = fs.openSync(tmp, 'w', 0o644);
+    try {
+      fs.writeSync(fd, content);
+      fs.fsyncSync(fd);
+    } finally {
+      fs.closeSync(fd);
+    }
+    fs.renameSync(tmp, file);
+  } catch (e) {
+    try { fs.unlinkSync(tmp); } catch (_) {}
+    throw e;
+  }
+}
+
+// 持有 file 的建议锁执行 fn；等待超过 LOCK_TIMEOUT_MS 时抛出错误
+export function withFileLock<T>(file: string, fn: () => T): T {
+  const lockFile = `${file}.lock`;
+  const deadline = Date.now() + LOCK_TIMEOUT_MS;
+  for (;;) {
+    try {
+      const fd = fs.openSync(lockFile, 'wx');
+      fs.writeSync(fd, `${process.pid}\n${new Date().toISOString()}`);
+      fs.closeSync(fd);
+      break;
+    } catch (e: any) {
+      if (e?.code !== 'EEXIST') throw e;
+      try {
+        if (Date.now() - fs.statSync(lockFile).mtimeMs > LOCK_STALE_MS) {
+          fs.unlinkSync(lockFile);
+          continue;
+        }
+      } catch (_) {
+        continue;
+      }
+      if (Date.now() > deadline) {
+        throw new Error(`等待文件锁超时: ${lockFile}`);
+      }
+      sleepSync(LOCK_RETRY_MS);
+    }
+  }
+  try {
+    return fn();
+  } finally {
+    try { fs.unlinkSync(lockFile); } catch (_) {}
+  }
+}
+
+// 加锁读取 JSON 对象、由 fn 修改后原子写回（两个空格缩进）；文件不存在或无法解析时从空对象开始
+export function updateJSONFile(file: string, fn: (json: any) => void): void {
+  withFileLock(file, () => {
+    let json: any = {};
+    try {
+      json = JSON.parse(fs.readFileSync(file, 'utf8'));
+    } catch (_) {
+      json = {};
+    }
+    fn(json);
+    writeFileAtomic(file, JSON.stringify(json, null, 2));
+  });
+}
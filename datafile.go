@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数据文件的建议锁：<文件>.lock 以 O_EXCL 创建、内容为 PID 与时间，与 dataFile.ts 相同（Go 与 TS 脚本互斥读改写同一个池文件）
+const (
+	dataLockTimeout = 10 * time.Second
+	dataLockStale   = 30 * time.Second // 超过该时长的锁视为持有方已崩溃，直接删除
+	dataLockRetry   = 20 * time.Millisecond
+)
+
+// 原子写入造成的 Remove + Create 在该时间内视为替换（kqueue）
+const poolFileReplaceWindow = 2 * time.Second
+
+// writeFileAtomic 先写同目录下的临时文件（以 . 开头、.tmp 结尾，不触发新池监听）并落盘，再重命名覆盖目标：
+// 读取方（包括 TS 脚本）只会看到完整的旧内容或新内容
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// writeJSONFileAtomic 以两个空格缩进写入 JSON（与 TS 脚本的 JSON.stringify(obj, null, 2) 一致）
+func writeJSONFileAtomic(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content, 0644)
+}
+
+// lockDataFile 获取 path 的建议锁，返回解锁函数；等待超过 dataLockTimeout 时返回错误
+func lockDataFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(dataLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n%s", os.Getpid(), time.Now().Format(time.RFC3339))
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, statErr := os.Stat(lockPath); statErr == nil && time.Since(fi.ModTime()) > dataLockStale {
+			logWarn("⚠️ 删除过期的文件锁", "lock", lockPath, "holder", lockHolder(lockPath), "age", time.Since(fi.ModTime()).Round(time.Second))
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("等待文件锁超时: %s（持有方 PID %s）", lockPath, lockHolder(lockPath))
+		}
+		time.Sleep(dataLockRetry)
+	}
+}
+
+// lockHolder 锁文件中记录的 PID
+func lockHolder(lockPath string) string {
+	content, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "?"
+	}
+	pid, _, _ := strings.Cut(string(content), "\n")
+	if _, err := strconv.Atoi(strings.TrimSpace(pid)); err != nil {
+		return "?"
+	}
+	return strings.TrimSpace(pid)
+}
+
+// updateJSONFile 加锁后读取已有的 JSON 对象，由 fn 修改后原子写回；fn 返回 false 时不写入。
+// 文件不存在时返回错误（不重新创建已被归档的池文件）
+func updateJSONFile(path string, fn func(obj map[string]interface{}) (bool, error)) error {
+	unlock, err := lockDataFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(content, &obj); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", filepath.Base(path), err)
+	}
+	changed, err := fn(obj)
+	if err != nil || !changed {
+		return err
+	}
+	return writeJSONFileAtomic(path, obj)
+}
+
+// poolFileTracker 区分新池文件与原子写入造成的替换：临时文件重命名覆盖已有池文件时，
+// inotify 报告目标的 Create，kqueue 报告 Remove 紧接 Create，二者都不是新信号（只在主循环中使用）
+type poolFileTracker struct {
+	known   map[string]bool
+	removed map[string]time.Time
+}
+
+func newPoolFileTracker(dir string) *poolFileTracker {
+	t := &poolFileTracker{known: map[string]bool{}, removed: map[string]time.Time{}}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, path := range matches {
+		t.known[path] = true
+	}
+	return t
+}
+
+// isPoolFile data 目录下的池文件（隐藏文件是 TS 脚本的状态文件或临时文件）
+func isPoolFile(dataDir, path string) bool {
+	return filepath.Dir(path) == filepath.Clean(dataDir) && strings.HasSuffix(path, ".json") && !strings.HasPrefix(filepath.Base(path), ".")
+}
+
+func (t *poolFileTracker) remove(path string) {
+	if t.known[path] {
+		delete(t.known, path)
+		t.removed[path] = time.Now()
+	}
+}
+
+// create 记录 Create 事件，返回是否为新出现的池文件
+func (t *poolFileTracker) create(path string) bool {
+	now := time.Now()
+	for p, at := range t.removed {
+		if now.Sub(at) > poolFileReplaceWindow {
+			delete(t.removed, p)
+		}
+	}
+	_, replaced := t.removed[path]
+	delete(t.removed, path)
+	isNew := !t.known[path] && !replaced
+	t.known[path] = true
+	return isNew
+}
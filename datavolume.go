@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DataVolumeConfig 数据目录所在卷（外置硬盘、网络共享）卸载时暂停依赖文件的子系统并告警，路径恢复后自动继续
+type DataVolumeConfig struct {
+	Enabled         bool     `json:"enabled"`
+	IntervalSeconds int      `json:"intervalSeconds"` // 检查间隔
+	Paths           []string `json:"paths"`           // 额外检查的目录（数据、状态与日志目录始终检查）
+	MarkerFile      string   `json:"markerFile"`      // 卷上固定存在的文件；卷卸载后挂载点目录仍在时，靠它判断卷不可用
+	RecoveryChecks  int      `json:"recoveryChecks"`  // 连续该次数检查正常后恢复
+}
+
+// DataVolumeStatus 最近一次检查结果
+type DataVolumeStatus struct {
+	CheckedAt   string            `json:"checkedAt,omitempty"`
+	Available   bool              `json:"available"`
+	Since       string            `json:"since,omitempty"`       // 不可用的开始时间
+	Unavailable map[string]string `json:"unavailable,omitempty"` // 路径 -> 原因
+}
+
+var (
+	dataVolumeMutex     sync.Mutex
+	dataVolumeStatus    = DataVolumeStatus{Available: true}
+	dataVolumeDown      bool                     // 当前处于不可用状态（已告警）
+	dataVolumeOKCount   int                      // 不可用状态下连续正常的检查次数
+	dataVolumeSeen      = map[string]bool{}      // 曾经存在过的目录（启动后尚未创建的目录不算不可用）
+	dataVolumeRecovered = make(chan struct{}, 1) // 恢复时通知主循环重新监听数据目录并补处理
+)
+
+func (c DataVolumeConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.IntervalSeconds <= 0 || c.RecoveryChecks <= 0 {
+		return fmt.Errorf("dataVolume.intervalSeconds、recoveryChecks 必须大于0")
+	}
+	for _, p := range c.Paths {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("dataVolume.paths 不能包含空路径")
+		}
+	}
+	return nil
+}
+
+// 需要检查的目录
+func dataVolumePaths() []string {
+	paths := []string{poolDataDir(), currentStateDir()}
+	if currentConfig().Logging.Dir != "" {
+		paths = append(paths, currentConfig().Logging.Dir)
+	}
+	for _, p := range currentConfig().DataVolume.Paths {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// 目录是否可用：存在、是目录且可写（不创建目录，卷卸载后不会在挂载点下重新建出空目录）
+func probeDataDir(dir string, seen bool) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) && !seen {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("不是目录")
+	}
+	f, err := os.CreateTemp(dir, ".volumecheck-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(time.Now().Format(time.RFC3339))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	os.Remove(f.Name())
+	return err
+}
+
+// 检查一次各目录与标记文件
+func checkDataVolume() {
+	cfg := currentConfig().DataVolume
+	status := DataVolumeStatus{CheckedAt: time.Now().Format(time.RFC3339), Unavailable: map[string]string{}}
+	paths := dataVolumePaths()
+
+	dataVolumeMutex.Lock()
+	seen := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		seen[p] = dataVolumeSeen[p]
+	}
+	dataVolumeMutex.Unlock()
+
+	var existing []string
+	if cfg.MarkerFile != "" {
+		if _, err := os.Stat(cfg.MarkerFile); err != nil {
+			status.Unavailable[cfg.MarkerFile] = "标记文件不可用: " + err.Error()
+		}
+	}
+	for _, p := range paths {
+		if err := probeDataDir(p, seen[p]); err != nil {
+			status.Unavailable[p] = err.Error()
+		} else if _, err := os.Stat(p); err == nil {
+			existing = append(existing, p)
+		}
+	}
+	status.Available = len(status.Unavailable) == 0
+	if status.Available {
+		status.Unavailable = nil
+	}
+
+	dataVolumeMutex.Lock()
+	for _, p := range existing {
+		dataVolumeSeen[p] = true
+	}
+	wasDown := dataVolumeDown
+	recovered := false
+	switch {
+	case !status.Available:
+		if !dataVolumeDown {
+			status.Since = status.CheckedAt
+		} else {
+			status.Since = dataVolumeStatus.Since
+		}
+		dataVolumeDown = true
+		dataVolumeOKCount = 0
+	case dataVolumeDown:
+		dataVolumeOKCount++
+		if dataVolumeOKCount >= cfg.RecoveryChecks {
+			dataVolumeDown, dataVolumeOKCount, recovered = false, 0, true
+		} else {
+			status.Since = dataVolumeStatus.Since
+		}
+	}
+	since := dataVolumeStatus.Since
+	dataVolumeStatus = status
+	dataVolumeMutex.Unlock()
+
+	switch {
+	case !status.Available && !wasDown:
+		reasons := make([]string, 0, len(status.Unavailable))
+		for p, reason := range status.Unavailable {
+			reasons = append(reasons, p+": "+reason)
+		}
+		sort.Strings(reasons)
+		logError("💾 数据目录不可用，暂停开仓、定时任务与再平衡", "reasons", strings.Join(reasons, "；"))
+		notifyKeyed(eventDataVolume, levelCritical, "data_volume", "数据目录不可用", "已暂停开仓、定时任务与再平衡，路径恢复后自动继续："+strings.Join(reasons, "；"), nil)
+	case recovered:
+		logInfo("💾 数据目录已恢复，继续运行", "since", since)
+		notifyKeyed(eventDataVolume, levelInfo, "data_volume", "数据目录已恢复", "不可用开始于 "+since+"，已重新监听数据目录并补处理期间的池文件", nil)
+		select {
+		case dataVolumeRecovered <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// dataVolumeUnavailable 数据目录所在卷当前不可用（恢复前依赖文件的子系统暂停）
+func dataVolumeUnavailable() bool {
+	if !currentConfig().DataVolume.Enabled {
+		return false
+	}
+	dataVolumeMutex.Lock()
+	defer dataVolumeMutex.Unlock()
+	return dataVolumeDown
+}
+
+func currentDataVolumeStatus() DataVolumeStatus {
+	dataVolumeMutex.Lock()
+	defer dataVolumeMutex.Unlock()
+	return dataVolumeStatus
+}
+
+// startDataVolumeCheck 启动时检查一次，之后按间隔定期检查
+func startDataVolumeCheck() {
+	cfg := currentConfig().DataVolume
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("💾 启动数据目录检查（每%v）：%s\n", interval, strings.Join(dataVolumePaths(), ", "))
+	checkDataVolume()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止数据目录检查\n")
+			return
+		case <-ticker.C:
+			checkDataVolume()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import "fmt"
+
+// 同一代币重复信号的处理策略
+const (
+	duplicateModeFirst = "first" // 保留已入场的池，忽略后到的池
+	duplicateModeBest  = "best"  // 按 poolSelection 的评分保留更优的池，必要时平掉已入场的池
+)
+
+// 平仓原因：同一代币切换到更优的池
+const exitReasonDuplicateToken = "duplicate_token"
+
+// checkDuplicateToken 的结果
+const (
+	duplicateNone    = iota // 无重复，正常入场
+	duplicateSkip           // 忽略该行
+	duplicateReplace        // 先平掉同代币的其他池再入场
+)
+
+// DuplicateTokenConfig CSV 中同一代币出现在不同池时只入场一个，避免对单一代币的敞口翻倍
+type DuplicateTokenConfig struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"` // first（默认）或 best
+}
+
+func (c DuplicateTokenConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Mode != duplicateModeFirst && c.Mode != duplicateModeBest {
+		return fmt.Errorf("duplicateToken.mode 仅支持 %s 或 %s", duplicateModeFirst, duplicateModeBest)
+	}
+	return nil
+}
+
+// 池是否仍持有（或正在建立）仓位：模拟池看模拟仓位，实盘池以池 JSON 存在且生命周期未关闭为准
+func poolHoldsPosition(poolAddress string) bool {
+	if isPaperPool(poolAddress) {
+		return paperHasOpenPosition(poolAddress)
+	}
+	lifecycleMutex.Lock()
+	r := loadPositionRecords()[poolAddress]
+	lifecycleMutex.Unlock()
+	return r == nil || r.State != positionStateClosed
+}
+
+// 同一代币正在持仓的其他池
+func duplicatePools(tokenAddress, poolAddress string) []string {
+	var pools []string
+	for _, pool := range findPoolsByToken(tokenAddress) {
+		if pool != poolAddress && poolHoldsPosition(pool) {
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// checkDuplicateToken 判断新行的代币是否已在其他池入场，并按配置决定忽略、替换或正常入场
+func checkDuplicateToken(profitData *ProfitData) int {
+	cfg := currentConfig().DuplicateToken
+	ca, _ := profitData.Data["ca"].(string)
+	if !cfg.Enabled || ca == "" {
+		return duplicateNone
+	}
+	existing := duplicatePools(ca, profitData.PoolAddress)
+	if len(existing) == 0 {
+		return duplicateNone
+	}
+	if cfg.Mode != duplicateModeBest {
+		logInfo("⏭️ 同一代币已在其他池入场，忽略该行", "token", ca, "pool", profitData.PoolAddress, "existing", existing)
+		return duplicateSkip
+	}
+
+	candidates, err := fetchPoolCandidates(ca)
+	if err != nil {
+		logWarn("⚠️ 查询候选池失败，保留已入场的池", "token", ca, "pool", profitData.PoolAddress, "existing", existing, "error", err)
+		return duplicateSkip
+	}
+	scores := map[string]float64{}
+	for _, c := range candidates {
+		scores[c.Address] = c.Score
+	}
+	score, ok := scores[profitData.PoolAddress]
+	for _, pool := range existing {
+		if s, found := scores[pool]; !ok || !found || s >= score {
+			logInfo("⏭️ 同一代币已在评分更高的池入场，忽略该行", "token", ca, "pool", profitData.PoolAddress, "existing", pool,
+				"score", fmt.Sprintf("%.3f", score), "existingScore", fmt.Sprintf("%.3f", s))
+			return duplicateSkip
+		}
+	}
+	logInfo("🔀 同一代币出现评分更高的池，平掉已入场的池后切换", "token", ca, "pool", profitData.PoolAddress,
+		"existing", existing, "score", fmt.Sprintf("%.3f", score))
+	return duplicateReplace
+}
+
+// 平掉同一代币的其他池；全部成功才返回 true
+func closeDuplicatePools(profitData *ProfitData) bool {
+	ca, _ := profitData.Data["ca"].(string)
+	for _, pool := range duplicatePools(ca, profitData.PoolAddress) {
+		if _, busy := closingPools.LoadOrStore(pool, true); busy {
+			return false
+		}
+		closed := claimAndClosePosition(pool, exitReasonDuplicateToken)
+		closingPools.Delete(pool)
+		if !closed {
+			logWarn("⚠️ 平掉重复代币的池失败，放弃切换", "token", ca, "pool", profitData.PoolAddress, "existing", pool)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 演示/压测模式（-demo）：生成合成 CSV 行，外部脚本由进程内模拟输出代替，完整走一遍信号 → 开仓 → 价格 → 领取 → 兑换流程，
+// 同时记录各定时任务的耗时，找出当前设计能承载的池数上限
+const (
+	demoSourceName = "demo"
+	demoPrefix     = "Demo" // 合成的池、代币地址前缀（base58 合法字符）
+)
+
+func demoCSVPath() string    { return dataPath("demo", "signals.csv") }
+func demoStateDir() string   { return dataPath("demo", "state") }
+func demoReportPath() string { return dataPath("demo", "report.json") }
+
+// DemoConfig 演示/压测模式参数（仅在 -demo 下生效）
+type DemoConfig struct {
+	RowsPerMinute         float64 `json:"rowsPerMinute"`         // 生成 CSV 行（新池）的速率
+	MaxRows               int     `json:"maxRows"`               // 生成行数上限，0 表示不限
+	DurationMinutes       int     `json:"durationMinutes"`       // 运行时长，到时写出报告并退出，0 表示直到手动停止
+	ScriptLatencyMs       int     `json:"scriptLatencyMs"`       // 模拟脚本耗时（在 ±50% 范围内随机）
+	FailureRate           float64 `json:"failureRate"`           // 模拟脚本失败的概率（0~1），失败标记为可重试
+	ReportIntervalSeconds int     `json:"reportIntervalSeconds"` // 负载报告间隔
+	BenchStageMinutes     int     `json:"benchStageMinutes"`     // -bench 每个阶段达到目标池数后的测量时长
+	BenchRowsPerSecond    float64 `json:"benchRowsPerSecond"`    // -bench 爬坡时写入信号的速率
+}
+
+func (c DemoConfig) validate() error {
+	if c.RowsPerMinute <= 0 {
+		return fmt.Errorf("demo.rowsPerMinute 必须大于0")
+	}
+	if c.MaxRows < 0 || c.DurationMinutes < 0 || c.ScriptLatencyMs < 0 {
+		return fmt.Errorf("demo 的取值不能为负数")
+	}
+	if c.FailureRate < 0 || c.FailureRate > 1 {
+		return fmt.Errorf("demo.failureRate 必须在 0~1 之间")
+	}
+	if c.ReportIntervalSeconds <= 0 {
+		return fmt.Errorf("demo.reportIntervalSeconds 必须大于0")
+	}
+	if c.BenchStageMinutes <= 0 || c.BenchRowsPerSecond < 1 {
+		return fmt.Errorf("demo.benchStageMinutes 必须大于0，benchRowsPerSecond 不能小于1")
+	}
+	return nil
+}
+
+// 由 -demo 参数开启，启动后不再变化
+var demoMode bool
+
+func isDemo() bool { return demoMode }
+
+// DemoJobStats 单个定时任务在压测期间的表现
+type DemoJobStats struct {
+	Runs        int    `json:"runs"`
+	Overlaps    int    `json:"overlaps"`               // 上一轮未结束而跳过的次数
+	LastTook    string `json:"lastTook,omitempty"`     // 最近一轮耗时
+	MaxTook     string `json:"maxTook,omitempty"`      // 最长一轮耗时
+	CeilingPool int    `json:"ceilingPools,omitempty"` // 首次出现重叠时的持仓池数（即该任务的池数上限）
+	CeilingAt   string `json:"ceilingAt,omitempty"`
+}
+
+// DemoReport 压测报告（data/demo/report.json）
+type DemoReport struct {
+	StartedAt    string                   `json:"startedAt"`
+	UpdatedAt    string                   `json:"updatedAt"`
+	RowsWritten  int64                    `json:"rowsWritten"`
+	Opened       int64                    `json:"opened"` // 模拟开仓成功次数
+	OpenPools    int                      `json:"openPools"`
+	MaxOpenPools int                      `json:"maxOpenPools"`
+	InFlight     int64                    `json:"inFlight"` // 在途的新池任务
+	Saturated    int                      `json:"saturated"`
+	ScriptCalls  map[string]int64         `json:"scriptCalls"`
+	ScriptFails  map[string]int64         `json:"scriptFailures"`
+	Jobs         map[string]*DemoJobStats `json:"jobs"`
+}
+
+// 模拟链上状态：仓位、价格与钱包持仓
+type demoSim struct {
+	mu          sync.Mutex
+	open        map[string]bool    // 持仓中的池
+	prices      map[string]float64 // 代币 -> 最新价格
+	holdings    map[string]bool    // 钱包中待兑换的代币
+	calls       map[string]int64
+	fails       map[string]int64
+	jobs        map[string]*DemoJobStats
+	started     time.Time
+	seenRuns    map[string]map[string]bool // 各任务已统计的执行记录（按计划时间）
+	report      DemoReport
+	rowsWritten atomic.Int64
+	opened      atomic.Int64
+}
+
+var demo = newDemoSim()
+
+func newDemoSim() *demoSim {
+	return &demoSim{
+		open:     map[string]bool{},
+		prices:   map[string]float64{},
+		holdings: map[string]bool{},
+		calls:    map[string]int64{},
+		fails:    map[string]int64{},
+		jobs:     map[string]*DemoJobStats{},
+		seenRuns: map[string]map[string]bool{},
+	}
+}
+
+// 合成地址：前缀 Demo + 随机 base58，共 44 个字符
+func demoAddress() string {
+	b := make([]byte, 44-len(demoPrefix))
+	for i := range b {
+		b[i] = base58Alphabet[rand.Intn(len(base58Alphabet))]
+	}
+	return demoPrefix + string(b)
+}
+
+// 演示模式下的配置覆盖：信号只来自合成 CSV，不访问候选池与外部价格源
+func applyDemoConfig(cfg *Config) {
+	cfg.CSVSources = []CSVSourceConfig{{Name: demoSourceName, Path: demoCSVPath()}}
+	cfg.Ingest = IngestConfig{}
+	cfg.PoolSelection.Enabled = false
+	cfg.Pricing.Providers = []string{priceSourceOKX}
+	cfg.Pricing.Strategy = pricingFallback
+}
+
+// 是否为演示模式生成的池文件（前缀与来源同时匹配）
+func isDemoPool(poolAddress string, data map[string]interface{}) bool {
+	source, _ := data["source"].(string)
+	return strings.HasPrefix(poolAddress, demoPrefix) && source == demoSourceName
+}
+
+// prepareDemo 清理上次演示遗留的池文件并重建合成 CSV（只有表头）
+func prepareDemo() error {
+	cleanupDemoFiles()
+	if err := os.MkdirAll(filepath.Dir(demoCSVPath()), 0755); err != nil {
+		return fmt.Errorf("创建演示目录失败: %v", err)
+	}
+	header := "poolAddress,ca,poolName,last_updated_first,liquidity,volume_24h\n"
+	if err := os.WriteFile(demoCSVPath(), []byte(header), 0644); err != nil {
+		return fmt.Errorf("创建演示 CSV 失败: %v", err)
+	}
+	demo.started = time.Now()
+	demo.report = DemoReport{StartedAt: demo.started.Format(time.RFC3339)}
+	return nil
+}
+
+// 删除 data 目录中演示生成的池文件及其价格历史（避免之后的实盘进程处理这些池）
+func cleanupDemoFiles() {
+	files, _ := filepath.Glob(filepath.Join(poolDataDir(), demoPrefix+"*.json"))
+	removed := 0
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var pd ProfitData
+		if json.Unmarshal(content, &pd) != nil || !isDemoPool(pd.PoolAddress, pd.Data) {
+			continue
+		}
+		if ca, _ := pd.Data["ca"].(string); strings.HasPrefix(ca, demoPrefix) {
+			removeStoredPrices(ca)
+		}
+		if os.Remove(path) == nil {
+			removed++
+		}
+	}
+	if removed > 0 {
+		logOutput("🧹 已清理演示池文件 %d 个\n", removed)
+	}
+}
+
+// startDemoProducer 按 rowsPerMinute 向合成 CSV 追加新池信号
+func startDemoProducer() {
+	cfg := currentConfig().Demo
+	interval := time.Duration(float64(time.Minute) / cfg.RowsPerMinute)
+	logOutput("🧪 演示模式：每%v生成一行信号 -> %s\n", interval.Round(time.Millisecond), demoCSVPath())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var deadline <-chan time.Time
+	if cfg.DurationMinutes > 0 {
+		timer := time.NewTimer(time.Duration(cfg.DurationMinutes) * time.Minute)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-deadline:
+			logOutput("⏱️ 演示运行时长已到（%d 分钟），写出报告并退出\n", cfg.DurationMinutes)
+			globalCancel()
+			return
+		case <-ticker.C:
+			if cfg.MaxRows > 0 && demo.rowsWritten.Load() >= int64(cfg.MaxRows) {
+				continue
+			}
+			if _, _, err := appendDemoRow(); err != nil {
+				logError("❌ 写入演示信号失败", "error", err)
+			}
+		}
+	}
+}
+
+// appendDemoRow 追加一行新池信号，返回合成的池与代币地址
+func appendDemoRow() (string, string, error) {
+	f, err := os.OpenFile(demoCSVPath(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	n := demo.rowsWritten.Add(1)
+	pool, token := demoAddress(), demoAddress()
+	w := csv.NewWriter(f)
+	w.Write([]string{
+		pool, token, fmt.Sprintf("DEMO%d-SOL", n),
+		time.Now().In(time.FixedZone("CST8", 8*60*60)).Format("2006-01-02 15:04:05"),
+		strconv.Itoa(10000 + rand.Intn(500000)),
+		strconv.Itoa(20000 + rand.Intn(2000000)),
+	})
+	w.Flush()
+	return pool, token, w.Error()
+}
+
+// 一行结构化事件
+func demoEvent(ev ScriptEvent) string {
+	b, _ := json.Marshal(ev)
+	return scriptEventPrefix + string(b) + "\n"
+}
+
+// demoExternal 代替外部命令：按配置的耗时与失败率返回与真实脚本相同格式的输出
+func demoExternal(ctx context.Context, target string, args []string) ([]byte, error) {
+	if isHarness() {
+		return fixtureExternal(ctx, target, args)
+	}
+	cfg := currentConfig().Demo
+	if cfg.ScriptLatencyMs > 0 {
+		latency := time.Duration(float64(cfg.ScriptLatencyMs)*(0.5+rand.Float64())) * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(latency):
+		}
+	}
+	demo.mu.Lock()
+	demo.calls[target]++
+	demo.mu.Unlock()
+	if rand.Float64() < cfg.FailureRate {
+		demo.mu.Lock()
+		demo.fails[target]++
+		demo.mu.Unlock()
+		out := "[demo] 模拟失败: 503 Service Unavailable\n" +
+			demoEvent(ScriptEvent{Type: scriptEventError, Code: "DEMO_FAILURE", Message: "simulated failure", Retryable: true}) +
+			demoEvent(ScriptEvent{Type: scriptEventStatus, Status: "error", Code: "DEMO_FAILURE"})
+		return []byte(out), fmt.Errorf("exit status 1")
+	}
+
+	pool := argValue(args, "--pool")
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("[demo] %s %s\n", target, strings.Join(args, " ")))
+	switch target {
+	case "addLiquidity":
+		if err := demoWritePosition(pool, argValue(args, "--leg")); err != nil {
+			return []byte(out.String()), err
+		}
+		if argValue(args, "--leg") == "" {
+			demo.mu.Lock()
+			demo.open[pool] = true
+			demo.mu.Unlock()
+			demo.opened.Add(1)
+		}
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventSignature, Signature: demoAddress(), Action: "addLiquidity"}))
+	case "fetchPrice":
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventPrice, Price: formatPrice(demoPrice(argValue(args, "--token"))), Source: priceSourceOKX}))
+	case "claimAllRewards":
+		token := readTokenContractAddressFromPoolJSON(pool)
+		solUSD := 150.0
+		value := 0.1 * solUSD * demoPrice(token) / demoBasePrice(token)
+		pending := value * 0.1 * rand.Float64()
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "pendingFeesUSD", Value: pending}))
+		// 与脚本一致：达到 --min-claim-usd 或带 --force-claim 时才领取
+		minClaim, err := strconv.ParseFloat(argValue(args, "--min-claim-usd"), 64)
+		if err != nil {
+			minClaim = 1
+		}
+		force := false
+		for _, arg := range args {
+			force = force || arg == "--force-claim"
+		}
+		if force || pending >= minClaim {
+			demo.mu.Lock()
+			if token != "" {
+				demo.holdings[token] = true
+			}
+			demo.mu.Unlock()
+			out.WriteString(demoEvent(ScriptEvent{Type: scriptEventClaimed, Token: token, Amount: formatPrice(pending)}))
+			out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "feeSOL", Value: 0.000005}))
+		}
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "claimedUSD", Value: pending}))
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "positionValueUSD", Value: value}))
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "solUSD", Value: solUSD}))
+	case "removeLiquidity":
+		demo.mu.Lock()
+		delete(demo.open, pool)
+		demo.mu.Unlock()
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventSignature, Signature: demoAddress(), Action: "removeLiquidity"}))
+	case "removeLiquidityPartial":
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventSignature, Signature: demoAddress(), Action: "removeLiquidity"}))
+	case "jupSwapBalances":
+		demo.mu.Lock()
+		tokens := make([]string, 0, len(demo.holdings))
+		for token := range demo.holdings {
+			tokens = append(tokens, token)
+		}
+		demo.mu.Unlock()
+		sort.Strings(tokens)
+		for _, token := range tokens {
+			out.WriteString(demoEvent(ScriptEvent{Type: scriptEventToken, Token: token, Balance: "1000000"}))
+		}
+	case "createTokenAccounts":
+		for _, mint := range strings.Split(argValue(args, "--mints"), ",") {
+			out.WriteString(demoEvent(ScriptEvent{Type: scriptEventAccount, Token: mint, Account: demoAddress()}))
+		}
+	case "jupSwap":
+		demo.mu.Lock()
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "-input" {
+				delete(demo.holdings, args[i+1])
+			}
+		}
+		demo.mu.Unlock()
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "proceeds", Value: 0.001 + 0.01*rand.Float64()}))
+		out.WriteString(demoEvent(ScriptEvent{Type: scriptEventValue, Key: "feeSOL", Value: 0.000005}))
+	}
+	out.WriteString(demoEvent(ScriptEvent{Type: scriptEventStatus, Status: "ok"}))
+	return []byte(out.String()), nil
+}
+
+// 代币的初始价格（按地址固定，便于估值）
+func demoBasePrice(token string) float64 {
+	var h uint32
+	for i := 0; i < len(token); i++ {
+		h = h*31 + uint32(token[i])
+	}
+	return 0.0001 * float64(1+h%1000)
+}
+
+// 代币价格：在初始价格附近随机游走
+func demoPrice(token string) float64 {
+	demo.mu.Lock()
+	defer demo.mu.Unlock()
+	p, ok := demo.prices[token]
+	if !ok {
+		p = demoBasePrice(token)
+	}
+	p *= math.Exp(rand.NormFloat64() * 0.02)
+	demo.prices[token] = p
+	return p
+}
+
+// 像 addLiquidity.ts 一样把仓位地址写回池文件（加锁、原子写入，覆盖已有池文件不触发新池事件）
+func demoWritePosition(pool, leg string) error {
+	position := demoAddress()
+	return updateJSONFile(filepath.Join(poolDataDir(), pool+".json"), func(obj map[string]interface{}) (bool, error) {
+		if leg != "" {
+			legs, _ := obj["legs"].(map[string]interface{})
+			if legs == nil {
+				legs = map[string]interface{}{}
+			}
+			legs[leg] = position
+			obj["legs"] = legs
+		} else {
+			obj["positionAddress"] = position
+			if data, ok := obj["data"].(map[string]interface{}); ok {
+				data["positionAddress"] = position
+			}
+		}
+		return true, nil
+	})
+}
+
+// startDemoReporter 定期汇总负载：持仓池数、在途任务、各定时任务耗时与重叠；任务首次重叠时记录池数上限
+func startDemoReporter() {
+	interval := time.Duration(currentConfig().Demo.ReportIntervalSeconds) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			r := updateDemoReport()
+			jobs := make([]string, 0, len(r.Jobs))
+			for name, j := range r.Jobs {
+				jobs = append(jobs, fmt.Sprintf("%s=%s/%d重叠", name, j.LastTook, j.Overlaps))
+			}
+			sort.Strings(jobs)
+			logInfo("📈 演示负载", "rows", r.RowsWritten, "opened", r.Opened, "openPools", r.OpenPools,
+				"inFlight", r.InFlight, "jobs", strings.Join(jobs, " "))
+		}
+	}
+}
+
+// 按调度器的执行记录更新报告
+func updateDemoReport() DemoReport {
+	demo.mu.Lock()
+	defer demo.mu.Unlock()
+	r := &demo.report
+	r.UpdatedAt = time.Now().Format(time.RFC3339)
+	r.RowsWritten = demo.rowsWritten.Load()
+	r.Opened = demo.opened.Load()
+	r.OpenPools = len(demo.open)
+	if r.OpenPools > r.MaxOpenPools {
+		r.MaxOpenPools = r.OpenPools
+	}
+	r.InFlight = inFlightTasks.Load()
+	if r.InFlight >= queueCapacity.Load() {
+		r.Saturated++
+	}
+	r.ScriptCalls = map[string]int64{}
+	r.ScriptFails = map[string]int64{}
+	for k, v := range demo.calls {
+		r.ScriptCalls[k] = v
+	}
+	for k, v := range demo.fails {
+		r.ScriptFails[k] = v
+	}
+
+	for _, job := range listJobStatus() {
+		stats := demo.jobs[job.Name]
+		if stats == nil {
+			stats = &DemoJobStats{}
+			demo.jobs[job.Name] = stats
+			demo.seenRuns[job.Name] = map[string]bool{}
+		}
+		// 执行记录在轮次结束时追加（重叠跳过的记录可能先于仍在执行的轮次），按计划时间去重
+		for _, run := range job.History {
+			at, err := time.Parse(time.RFC3339, run.ScheduledAt)
+			if err != nil || at.Before(demo.started) || demo.seenRuns[job.Name][run.ScheduledAt] {
+				continue
+			}
+			if run.Skipped == "overlap" {
+				stats.Overlaps++
+				if stats.CeilingPool == 0 {
+					stats.CeilingPool = r.OpenPools
+					stats.CeilingAt = run.ScheduledAt
+					logWarn("⚠️ 达到池数上限：定时任务一轮未在调度间隔内完成", "job", job.Name, "openPools", r.OpenPools)
+				}
+				continue
+			}
+			if run.Duration == "" {
+				continue
+			}
+			demo.seenRuns[job.Name][run.ScheduledAt] = true
+			stats.Runs++
+			stats.LastTook = run.Duration
+			took, _ := time.ParseDuration(run.Duration)
+			maxTook, _ := time.ParseDuration(stats.MaxTook)
+			if took > maxTook {
+				stats.MaxTook = run.Duration
+			}
+		}
+	}
+	r.Jobs = demo.jobs
+	return *r
+}
+
+// finishDemo 退出前写出报告并清理演示池文件
+func finishDemo() {
+	r := updateDemoReport()
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.WriteFile(demoReportPath(), content, 0644)
+	}
+	if err != nil {
+		logError("❌ 写入演示报告失败", "error", err)
+	} else {
+		logOutput("📄 演示报告已写入 %s（生成 %d 行，开仓 %d，最多同时持仓 %d 个池）\n", demoReportPath(), r.RowsWritten, r.Opened, r.MaxOpenPools)
+	}
+	for name, j := range r.Jobs {
+		if j.CeilingPool > 0 {
+			logOutput("📉 %s：持仓 %d 个池时开始跳过轮次（最长一轮 %s）\n", name, j.CeilingPool, j.MaxTook)
+		}
+	}
+	cleanupDemoFiles()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 部署标识：区分接入同一 Grafana / Telegram 的多个实例（如 staging 与 production）
+var (
+	deployInstance    string
+	deployEnvironment string
+)
+
+// initDeployment 读取配置中的 instance / environment，instance 为空时使用主机名
+func initDeployment(cfg *Config) {
+	deployEnvironment = cfg.Environment
+	deployInstance = cfg.Instance
+	if deployInstance == "" {
+		if host, err := os.Hostname(); err == nil {
+			deployInstance = host
+		}
+	}
+}
+
+func validateDeployLabel(key, v string) error {
+	if strings.ContainsAny(v, " \t\n\"\\") {
+		return fmt.Errorf("%s 不能包含空白、引号或反斜杠: %q", key, v)
+	}
+	return nil
+}
+
+// 部署标签键值对（用于指标与日志，未设置的省略）
+func deploymentLabels() []string {
+	var kv []string
+	if deployInstance != "" {
+		kv = append(kv, "instance", deployInstance)
+	}
+	if deployEnvironment != "" {
+		kv = append(kv, "environment", deployEnvironment)
+	}
+	return kv
+}
+
+// 告警标题前缀，如 [production/bot-1]
+func deploymentTag(environment, instance string) string {
+	parts := make([]string, 0, 2)
+	for _, v := range []string{environment, instance} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, "/") + "]"
+}
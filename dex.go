@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 执行层协议：开仓、领取、移除流动性与报价经 Dex 接口执行，按信号的协议字段或按池配置选择后端。
+// 目前只有 Meteora DLMM（addLiquidity.ts 等脚本），之后的后端（Meteora DAMM v2、Orca Whirlpools）在 dexBackends 中注册
+const (
+	protocolMeteoraDLMM = "meteora-dlmm"
+)
+
+// 池文件 data 中记录协议的字段（信号入场时写入解析后的协议名）
+const poolProtocolField = "protocol"
+
+// Dex 操作（CommandLine 的 op）
+const (
+	dexOpAddLiquidity    = "addLiquidity"
+	dexOpClaim           = "claim"
+	dexOpRemoveLiquidity = "removeLiquidity"
+	dexOpRemovePartial   = "removeLiquidityPartial"
+	dexOpQuote           = "quote"
+)
+
+// Dex 执行层后端：args 为调用方构造的脚本参数，返回命令输出（@@event 事件见 scriptproto.go）
+type Dex interface {
+	Name() string
+	AddLiquidity(ctx context.Context, args ...string) ([]byte, error)
+	Claim(ctx context.Context, args ...string) ([]byte, error)
+	RemoveLiquidity(ctx context.Context, partial bool, args ...string) ([]byte, error)
+	Quote(ctx context.Context, args ...string) ([]byte, error)
+	CommandLine(op string, args ...string) []string // 操作对应的完整命令行，用于日志与 paper 模式记录
+}
+
+// scriptDex 每个操作对应外部命令注册表（scripts.registry）中的一个目标
+type scriptDex struct {
+	name    string
+	targets map[string]string // 操作 -> 目标
+}
+
+func (d scriptDex) Name() string { return d.name }
+
+func (d scriptDex) AddLiquidity(ctx context.Context, args ...string) ([]byte, error) {
+	return runExternal(ctx, d.targets[dexOpAddLiquidity], args...)
+}
+
+func (d scriptDex) Claim(ctx context.Context, args ...string) ([]byte, error) {
+	return runExternal(ctx, d.targets[dexOpClaim], args...)
+}
+
+func (d scriptDex) RemoveLiquidity(ctx context.Context, partial bool, args ...string) ([]byte, error) {
+	op := dexOpRemoveLiquidity
+	if partial {
+		op = dexOpRemovePartial
+	}
+	return runExternal(ctx, d.targets[op], args...)
+}
+
+func (d scriptDex) Quote(ctx context.Context, args ...string) ([]byte, error) {
+	return runExternal(ctx, d.targets[dexOpQuote], args...)
+}
+
+func (d scriptDex) CommandLine(op string, args ...string) []string {
+	return scriptCommandLine(d.targets[op], args...)
+}
+
+// 已注册的后端
+var dexBackends = map[string]Dex{
+	protocolMeteoraDLMM: scriptDex{name: protocolMeteoraDLMM, targets: map[string]string{
+		dexOpAddLiquidity:    scriptAddLiquidity,
+		dexOpClaim:           scriptClaimAllRewards,
+		dexOpRemoveLiquidity: scriptRemoveLiquidity,
+		dexOpRemovePartial:   scriptRemoveLiquidityPartial,
+		dexOpQuote:           scriptFetchPrice,
+	}},
+}
+
+// 协议名的其他写法
+var dexAliases = map[string]string{
+	"meteoradlmm": protocolMeteoraDLMM,
+	"dlmm":        protocolMeteoraDLMM,
+}
+
+// DexConfig 执行层协议的选择：按池配置 > 信号字段 > 默认协议
+type DexConfig struct {
+	Default     string            `json:"default"`     // 默认协议（meteora-dlmm）
+	SignalField string            `json:"signalField"` // 信号中指定协议的字段（CSV 的 protocol 列），为空时不读取
+	Pools       map[string]string `json:"pools"`       // 按池指定协议
+}
+
+// 按名称查找后端（不区分大小写，忽略 - 与 _）
+func dexByName(name string) (Dex, error) {
+	key := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(strings.TrimSpace(name)))
+	if alias, ok := dexAliases[key]; ok {
+		key = alias
+	}
+	if d, ok := dexBackends[key]; ok {
+		return d, nil
+	}
+	names := make([]string, 0, len(dexBackends))
+	for n := range dexBackends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("不支持的协议 %q（可选 %s）", name, strings.Join(names, "、"))
+}
+
+func (c DexConfig) validate() error {
+	if _, err := dexByName(c.Default); err != nil {
+		return fmt.Errorf("dex.default: %v", err)
+	}
+	for pool, name := range c.Pools {
+		if _, err := dexByName(name); err != nil {
+			return fmt.Errorf("dex.pools[%s]: %v", pool, err)
+		}
+	}
+	return nil
+}
+
+// defaultDex 默认协议的后端
+func defaultDex() Dex {
+	if d, err := dexByName(currentConfig().Dex.Default); err == nil {
+		return d
+	}
+	return dexBackends[protocolMeteoraDLMM]
+}
+
+// signalDex 新池信号使用的后端：按池配置 > 信号字段 > 默认协议；信号指定的协议不支持时返回错误
+func signalDex(poolAddress string, data map[string]interface{}) (Dex, error) {
+	cfg := currentConfig().Dex
+	if name, ok := cfg.Pools[poolAddress]; ok {
+		return dexByName(name)
+	}
+	if cfg.SignalField != "" {
+		if name, ok := data[cfg.SignalField].(string); ok && strings.TrimSpace(name) != "" {
+			return dexByName(name)
+		}
+	}
+	return defaultDex(), nil
+}
+
+// poolDex 池使用的后端：按池配置 > 池文件记录的协议 > 默认协议
+func poolDex(poolAddress string) Dex {
+	if name, ok := currentConfig().Dex.Pools[poolAddress]; ok {
+		if d, err := dexByName(name); err == nil {
+			return d
+		}
+	}
+	if name := readProtocolFromPoolJSON(poolAddress); name != "" {
+		d, err := dexByName(name)
+		if err == nil {
+			return d
+		}
+		logWarn("⚠️ 池文件记录的协议不支持，使用默认协议", "pool", poolAddress, "protocol", name, "default", defaultDex().Name())
+	}
+	return defaultDex()
+}
+
+// readProtocolFromPoolJSON 池文件 data 中记录的协议（旧池文件没有该字段）
+func readProtocolFromPoolJSON(poolAddress string) string {
+	content, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json"))
+	if err != nil {
+		return ""
+	}
+	var data ProfitData
+	if json.Unmarshal(content, &data) != nil {
+		return ""
+	}
+	name, _ := data.Data[poolProtocolField].(string)
+	return name
+}
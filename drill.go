@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DrillAlert 演练场景中预计触发的告警及其送达情况
+type DrillAlert struct {
+	Event              string   `json:"event"`
+	Level              string   `json:"level"`
+	Title              string   `json:"title"`
+	Backends           []string `json:"backends"`                     // 按路由实际送达的后端
+	Delivery           string   `json:"delivery"`                     // delivered / notify_disabled / route_disabled / no_backend
+	MinIntervalSeconds int      `json:"minIntervalSeconds,omitempty"` // 同一事件重复告警的最小间隔
+}
+
+// DrillEffect 场景中暂停或降级的子系统
+type DrillEffect struct {
+	Subsystem string `json:"subsystem"`
+	Effect    string `json:"effect"`
+}
+
+// DrillScenario 单个故障场景的推演结果
+type DrillScenario struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Alerts      []DrillAlert  `json:"alerts"`
+	Effects     []DrillEffect `json:"effects"`
+	Gaps        []string      `json:"gaps"` // 该场景下没有保护或告警无法送达的地方
+}
+
+// DrillReport 演练报告（drill 子命令）
+type DrillReport struct {
+	Instance    string          `json:"instance,omitempty"`
+	Environment string          `json:"environment,omitempty"`
+	Mode        string          `json:"mode"`
+	Scenarios   []DrillScenario `json:"scenarios"`
+}
+
+// drillScenarios 可演练的场景，按顺序执行
+var drillScenarios = []struct {
+	name        string
+	description string
+	run         func(s *DrillScenario)
+}{
+	{"rpc_down", "RPC 节点不可用（超时、限流或节点落后）", drillRPCDown},
+	{"wallet_low", "钱包 SOL 余额不足（手续费与开仓租金不够）", drillWalletLow},
+	{"sidecar_crash", "外部脚本进程崩溃（ts 脚本、jupSwap 反复异常退出）", drillSidecarCrash},
+}
+
+// runDrill 按当前配置推演故障场景：只读取配置，不发送告警、不执行命令、不修改状态。
+// names 为空时演练全部场景
+func runDrill(names []string) (DrillReport, error) {
+	report := DrillReport{Instance: deployInstance, Environment: deployEnvironment, Mode: currentConfig().Mode, Scenarios: []DrillScenario{}}
+	for _, name := range names {
+		if !drillScenarioKnown(name) {
+			return report, fmt.Errorf("未知的演练场景 %q（可选: %s）", name, strings.Join(drillScenarioNames(), ", "))
+		}
+	}
+	for _, sc := range drillScenarios {
+		if len(names) > 0 && !slices.Contains(names, sc.name) {
+			continue
+		}
+		s := DrillScenario{Name: sc.name, Description: sc.description, Alerts: []DrillAlert{}, Effects: []DrillEffect{}, Gaps: []string{}}
+		sc.run(&s)
+		for _, a := range s.Alerts {
+			if a.Delivery != "delivered" && (a.Level == levelCritical || a.Level == levelWarning) {
+				s.Gaps = append(s.Gaps, fmt.Sprintf("告警 %s 不会送达（%s）", a.Event, a.Delivery))
+			}
+		}
+		report.Scenarios = append(report.Scenarios, s)
+	}
+	return report, nil
+}
+
+func drillScenarioNames() []string {
+	names := make([]string, 0, len(drillScenarios))
+	for _, sc := range drillScenarios {
+		names = append(names, sc.name)
+	}
+	return names
+}
+
+func drillScenarioKnown(name string) bool {
+	return slices.Contains(drillScenarioNames(), name)
+}
+
+// drillAlert 按告警配置与事件路由推算告警会送达哪些后端（与 dispatchAlert 的选择一致）
+func drillAlert(event, level, title string) DrillAlert {
+	cfg := currentConfig().Notify
+	route := routeFor(event)
+	a := DrillAlert{Event: event, Level: level, Title: title, Backends: []string{}, MinIntervalSeconds: route.MinIntervalSeconds}
+	configured := map[string]bool{}
+	for _, b := range cfg.Backends {
+		configured[b.Name] = true
+	}
+	switch {
+	case !cfg.Enabled:
+		a.Delivery = "notify_disabled"
+		return a
+	case route.Disabled:
+		a.Delivery = "route_disabled"
+		return a
+	}
+	targets := route.Backends
+	if len(targets) == 0 {
+		for name := range configured {
+			targets = append(targets, name)
+		}
+	}
+	for _, name := range targets {
+		if configured[name] {
+			a.Backends = append(a.Backends, name)
+		}
+	}
+	sort.Strings(a.Backends)
+	a.Delivery = "delivered"
+	if len(a.Backends) == 0 {
+		a.Delivery = "no_backend"
+	}
+	return a
+}
+
+func (s *DrillScenario) alert(event, level, title string) {
+	s.Alerts = append(s.Alerts, drillAlert(event, level, title))
+}
+
+func (s *DrillScenario) effect(subsystem, format string, args ...interface{}) {
+	s.Effects = append(s.Effects, DrillEffect{Subsystem: subsystem, Effect: fmt.Sprintf(format, args...)})
+}
+
+func (s *DrillScenario) gap(format string, args ...interface{}) {
+	s.Gaps = append(s.Gaps, fmt.Sprintf(format, args...))
+}
+
+// 熔断：各目标按各自的重试策略（未单独配置的目标使用 exec.default）
+func drillBreakers(s *DrillScenario) {
+	targets := make([]string, 0, len(currentConfig().Exec.Targets))
+	for name := range currentConfig().Exec.Targets {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	breakerAlert := false
+	describe := func(name string, p RetryPolicy) {
+		if p.BreakerThreshold <= 0 {
+			s.gap("%s 未启用熔断（breakerThreshold 为 0），失败时每轮照常重试 %d 次", name, p.MaxAttempts)
+			return
+		}
+		breakerAlert = true
+		s.effect("exec:"+name, "每次最多尝试 %d 次，连续失败 %d 次后熔断 %d 秒，之后放行一次试探", p.MaxAttempts, p.BreakerThreshold, p.BreakerCooldownSeconds)
+	}
+	describe("exec.default", currentConfig().Exec.Default)
+	for _, name := range targets {
+		describe(name, currentConfig().Exec.Targets[name])
+	}
+	if breakerAlert {
+		s.alert(eventCircuitOpen, levelCritical, "外部命令熔断")
+	}
+}
+
+// 操作失败告警（添加流动性、领取、兑换）
+func drillOperationFailures(s *DrillScenario) {
+	if currentConfig().Mode != modePriceOnly {
+		s.alert(eventAddLiquidityFailure, levelCritical, "添加流动性失败")
+	}
+	s.alert(eventClaimFailure, levelWarning, "领取奖励失败")
+	s.alert(eventSwapFailure, levelWarning, "jupSwap执行失败")
+}
+
+func drillRPCDown(s *DrillScenario) {
+	cfg := currentConfig()
+	endpoints := rpcEndpoints()
+	switch len(endpoints) {
+	case 0:
+		s.gap("未配置 RPC 节点（rpcPool.endpoints 与 walletWatch.rpcUrl 均为空），余额、交易确认与集群检查都不可用")
+	case 1:
+		s.gap("只有一个 RPC 节点，无法切换（rpcPool.endpoints）")
+		s.effect("rpcPool", "唯一节点连续失败 %d 次后下线 %d 秒，期间 RPC 请求直接失败", cfg.RPCPool.FailureThreshold, cfg.RPCPool.CooldownSeconds)
+	default:
+		s.effect("rpcPool", "节点连续失败 %d 次后下线 %d 秒，请求切换到其余 %d 个节点", cfg.RPCPool.FailureThreshold, cfg.RPCPool.CooldownSeconds, len(endpoints)-1)
+	}
+	if len(endpoints) > 0 {
+		s.effect("health", "就绪检查（/ready）的 rpc 项失败，返回 503")
+	}
+
+	if cfg.RPCDegrade.Enabled {
+		s.alert(eventRPCDegrade, levelWarning, "RPC 限流，已降级运行")
+		s.effect("rpcDegrade", "窗口 %d 秒内 %d 次限流信号升一级，最高 %d 级：%s 每 %d 轮执行一次，并发降为 1/%d",
+			cfg.RPCDegrade.WindowSeconds, cfg.RPCDegrade.Threshold, cfg.RPCDegrade.MaxLevel,
+			strings.Join(cfg.RPCDegrade.Jobs, "、"), 1<<cfg.RPCDegrade.MaxLevel, 1<<cfg.RPCDegrade.MaxLevel)
+	} else {
+		s.gap("RPC 降级（rpcDegrade）未启用，限流时定时任务照常按间隔执行")
+	}
+
+	if cfg.ClusterHealth.Enabled {
+		s.alert(eventClusterHealth, levelWarning, "RPC 节点或集群不健康")
+		if cfg.ClusterHealth.PauseEntries {
+			s.effect("entries", "暂停开仓，连续 %d 次检查正常后恢复（领取、移除不受影响）", cfg.ClusterHealth.RecoveryChecks)
+		} else {
+			s.gap("集群健康检查不暂停开仓（clusterHealth.pauseEntries 为 false）")
+		}
+	} else {
+		s.gap("集群健康检查（clusterHealth）未启用，节点落后时照常开仓")
+	}
+
+	if cfg.TxTracker.Enabled {
+		s.alert(eventTxFailed, levelCritical, "交易已丢弃")
+		s.effect("txTracker", "发送后 %d 秒仍查不到的交易视为已丢弃", cfg.TxTracker.ExpireSeconds)
+	}
+	if cfg.BalanceMonitor.Enabled || cfg.WalletWatch.Enabled {
+		s.effect("walletWatch", "余额与钱包交易轮询失败，余额不足与外部转出在 RPC 恢复前无法发现")
+	}
+	drillBreakers(s)
+	drillOperationFailures(s)
+}
+
+func drillWalletLow(s *DrillScenario) {
+	cfg := currentConfig()
+	if cfg.BalanceMonitor.Enabled {
+		s.alert(eventLowBalance, levelCritical, "钱包 SOL 余额不足")
+		s.effect("balanceMonitor", "每 %d 秒查询余额，低于 %g SOL 时告警", cfg.BalanceMonitor.IntervalSeconds, cfg.BalanceMonitor.MinSOL)
+		s.effect("backpressure", "饱和状态带 low_sol 原因，上游生产者应暂停投递信号")
+	} else {
+		s.gap("余额监控（balanceMonitor）未启用，SOL 不足时只会表现为开仓与兑换失败")
+	}
+	if cfg.Mode != modePriceOnly {
+		s.alert(eventAddLiquidityFailure, levelCritical, "添加流动性失败")
+		s.effect("entries", "开仓不会因余额不足自动暂停，每个新信号都会尝试开仓并失败")
+	}
+	s.alert(eventSwapFailure, levelWarning, "jupSwap执行失败")
+	s.alert(eventClaimFailure, levelWarning, "领取奖励失败")
+
+	if cfg.Tripwire.Enabled && cfg.Tripwire.BalanceDropSOL > 0 {
+		s.alert(eventTripwire, levelCritical, "🧊 安全冻结：已停止所有交易")
+		s.effect("tripwire", "余额下降由外部转出引起且超过 %g SOL 时冻结全部交易命令，需通过 /unfreeze 解除", cfg.Tripwire.BalanceDropSOL)
+	} else {
+		s.gap("未检查非本程序的余额下降（tripwire.balanceDropSOL），钱包被转空不会冻结交易")
+	}
+	drillBreakers(s)
+}
+
+func drillSidecarCrash(s *DrillScenario) {
+	cfg := currentConfig()
+	p := cfg.Exec.Default
+	if p.MaxAttempts <= 1 {
+		s.gap("外部命令不重试（exec.default.maxAttempts 为 %d），一次崩溃即判定失败", p.MaxAttempts)
+	} else {
+		s.effect("exec", "按命令输出匹配可重试错误，最多尝试 %d 次，退避 %d~%d 毫秒", p.MaxAttempts, p.BaseDelayMs, p.MaxDelayMs)
+	}
+	drillBreakers(s)
+	drillOperationFailures(s)
+	if cfg.Health.MaxJobRunSeconds > 0 {
+		s.effect("health", "脚本挂起导致定时任务单轮超过 %d 秒时，存活检查（/health）返回 503", cfg.Health.MaxJobRunSeconds)
+	} else {
+		s.gap("未限制定时任务单轮时长（health.maxJobRunSeconds 为 0），脚本挂起不会反映在 /health")
+	}
+	s.effect("jobs", "定时任务本轮失败后按计划继续下一轮，未完成的领取与兑换在下一轮重新执行")
+}
+
+// printDrillReport 输出演练报告
+func printDrillReport(r DrillReport) {
+	fmt.Printf("🧯 故障演练（mode=%s，只推演配置，不发送告警、不执行命令）\n", r.Mode)
+	for _, s := range r.Scenarios {
+		fmt.Printf("\n== %s：%s\n", s.Name, s.Description)
+		fmt.Println("  告警:")
+		for _, a := range s.Alerts {
+			target := strings.Join(a.Backends, ",")
+			if a.Delivery != "delivered" {
+				target = a.Delivery
+			}
+			fmt.Printf("    - [%s] %s %s → %s\n", a.Level, a.Event, a.Title, target)
+		}
+		fmt.Println("  暂停与降级:")
+		for _, e := range s.Effects {
+			fmt.Printf("    - %s: %s\n", e.Subsystem, e.Effect)
+		}
+		if len(s.Gaps) > 0 {
+			fmt.Println("  ⚠️ 缺口:")
+			for _, g := range s.Gaps {
+				fmt.Printf("    - %s\n", g)
+			}
+		}
+	}
+}
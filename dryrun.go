@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dry-run 模拟执行记录与独立的状态目录（不污染实盘状态）
+func dryRunActionsPath() string { return dataPath("dryrun", "actions.jsonl") }
+func dryRunStateDir() string    { return dataPath("dryrun", "state") }
+
+// 只读目标：dry-run 与冻结期间照常执行（fetchPrice 会附加 --price-only）
+var readOnlyTargets = map[string]bool{
+	"fetchPrice":      true,
+	"jupSwapBalances": true,
+}
+
+// 由 --dry-run 参数开启，启动后不再变化
+var dryRunMode bool
+
+func isDryRun() bool { return dryRunMode }
+
+// 从命令参数中提取 --key=value
+func argValue(args []string, key string) string {
+	for _, a := range args {
+		if strings.HasPrefix(a, key+"=") {
+			return strings.TrimPrefix(a, key+"=")
+		}
+	}
+	return ""
+}
+
+// simulateExternal 记录本应执行的命令（日志 + data/dryrun/actions.jsonl），不触碰链上
+func simulateExternal(target, name string, args []string) {
+	command := strings.TrimSpace(name + " " + strings.Join(args, " "))
+	pool := argValue(args, "--pool")
+	logInfo("🧪 [dry-run] 模拟执行", "target", target, "pool", pool, "command", command)
+
+	record, _ := json.Marshal(map[string]interface{}{
+		"time":        time.Now().Format(time.RFC3339),
+		"target":      target,
+		"pool":        pool,
+		"command":     command,
+		"args":        args,
+		"profile":     activeProfileName(),
+		"instance":    deployInstance,
+		"environment": deployEnvironment,
+	})
+	if err := os.MkdirAll(filepath.Dir(dryRunActionsPath()), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(dryRunActionsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(record, '\n'))
+}
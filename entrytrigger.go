@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 入场条件类型
+const (
+	entryNow      = "now"      // 立即开仓
+	entryWithin   = "within"   // 价格与信号价格的偏离不超过 pct%
+	entryPullback = "pullback" // 价格从等待期间的最高价（不低于信号价格）回撤 pct%
+)
+
+// 池文件等待入场条件的结果；超过 TTL 记为 entry_expired
+const (
+	outcomeEntryWaiting = "entry_waiting"
+	outcomeEntryExpired = "entry_expired"
+)
+
+// EntryTriggerConfig 按价格条件择时开仓：信号到达后先挂起，每次获取价格时检查条件，满足时开仓，超过 TTL 放弃
+type EntryTriggerConfig struct {
+	Enabled              bool    `json:"enabled"`
+	Mode                 string  `json:"mode"`                 // 默认条件：now | within | pullback
+	Pct                  float64 `json:"pct"`                  // 默认条件的百分比
+	Field                string  `json:"field"`                // 信号中覆盖条件的字段，取值如 now、within:2、pullback:5
+	PriceField           string  `json:"priceField"`           // 信号价格字段，缺失时以挂起后获取到的第一个价格为准
+	TTLMinutes           int     `json:"ttlMinutes"`           // 等待上限
+	CheckIntervalSeconds int     `json:"checkIntervalSeconds"` // 检查过期的间隔（条件本身在每次获取价格时检查）
+}
+
+// EntryCondition 一个池的入场条件
+type EntryCondition struct {
+	Mode string  `json:"mode"`
+	Pct  float64 `json:"pct,omitempty"`
+}
+
+// EntryWaiting 等待入场条件的池文件（data/state/entry_waiting.json: 路径 -> 记录）
+type EntryWaiting struct {
+	Path        string         `json:"path"`
+	Pool        string         `json:"poolAddress"`
+	Token       string         `json:"ca,omitempty"`
+	Condition   EntryCondition `json:"condition"`
+	SignalPrice float64        `json:"signalPrice"` // 参考价格
+	HighPrice   float64        `json:"highPrice"`   // 等待期间的最高价
+	LastPrice   float64        `json:"lastPrice"`
+	Ticks       int            `json:"ticks"`
+	ParkedAt    string         `json:"parkedAt"`
+	ExpiresAt   string         `json:"expiresAt"`
+	TriggeredAt string         `json:"triggeredAt,omitempty"` // 条件已满足，池文件已重新加入开仓队列
+}
+
+var entryMutex sync.Mutex
+
+func (c EntryTriggerConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := (EntryCondition{Mode: c.Mode, Pct: c.Pct}).validate(); err != nil {
+		return fmt.Errorf("entryTrigger: %v", err)
+	}
+	if c.TTLMinutes <= 0 {
+		return fmt.Errorf("entryTrigger.ttlMinutes 必须大于0")
+	}
+	if c.CheckIntervalSeconds <= 0 {
+		return fmt.Errorf("entryTrigger.checkIntervalSeconds 必须大于0")
+	}
+	return nil
+}
+
+func (c EntryCondition) validate() error {
+	switch c.Mode {
+	case entryNow:
+	case entryWithin, entryPullback:
+		if c.Pct <= 0 || c.Pct >= 100 {
+			return fmt.Errorf("%s 的百分比必须在 (0, 100) 内", c.Mode)
+		}
+	default:
+		return fmt.Errorf("不支持的入场条件: %s（可选 now、within、pullback）", c.Mode)
+	}
+	return nil
+}
+
+func (c EntryCondition) String() string {
+	if c.Mode == entryNow {
+		return c.Mode
+	}
+	return c.Mode + ":" + strconv.FormatFloat(c.Pct, 'f', -1, 64)
+}
+
+// entryConditionFor 信号字段覆盖默认条件（取值无效时沿用默认条件并告警）
+func entryConditionFor(poolAddress string, data map[string]interface{}) EntryCondition {
+	cfg := currentConfig().EntryTrigger
+	cond := EntryCondition{Mode: cfg.Mode, Pct: cfg.Pct}
+	s, _ := data[cfg.Field].(string)
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return cond
+	}
+	mode, pct, _ := strings.Cut(s, ":")
+	override := EntryCondition{Mode: mode}
+	if pct != "" {
+		v, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			logWarn("⚠️ 信号中的入场条件无效，使用默认条件", "pool", poolAddress, "value", s)
+			return cond
+		}
+		override.Pct = v
+	}
+	if err := override.validate(); err != nil {
+		logWarn("⚠️ 信号中的入场条件无效，使用默认条件", "pool", poolAddress, "value", s, "error", err)
+		return cond
+	}
+	return override
+}
+
+func loadEntryWaiting() map[string]*EntryWaiting {
+	waiting := map[string]*EntryWaiting{}
+	if err := loadStateFile("entry_waiting", &waiting); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return waiting
+}
+
+// 调用方需持有 entryMutex
+func saveEntryWaitingLocked(waiting map[string]*EntryWaiting) {
+	if err := saveStateFile("entry_waiting", waiting); err != nil {
+		logOutput("❌ 保存等待入场的池文件失败: %v\n", err)
+	}
+}
+
+// entryTriggered 池文件的入场条件已满足（本次处理不再挂起，也不再检查信号新鲜度）；记录随之移除
+func entryTriggered(path string) bool {
+	entryMutex.Lock()
+	defer entryMutex.Unlock()
+	waiting := loadEntryWaiting()
+	w := waiting[path]
+	if w == nil || w.TriggeredAt == "" {
+		return false
+	}
+	delete(waiting, path)
+	saveEntryWaitingLocked(waiting)
+	return true
+}
+
+// parkForEntry 需要等待入场条件时挂起池文件并返回 true；未启用、条件为 now 或追加流动性时返回 false
+func parkForEntry(path, poolAddress, ca string, data map[string]interface{}) bool {
+	cfg := currentConfig().EntryTrigger
+	if !cfg.Enabled {
+		return false
+	}
+	cond := entryConditionFor(poolAddress, data)
+	if cond.Mode == entryNow {
+		return false
+	}
+	signalPrice, _ := signalFloat(data, cfg.PriceField)
+	now := appNow()
+	w := &EntryWaiting{
+		Path: path, Pool: poolAddress, Token: ca, Condition: cond, SignalPrice: signalPrice, HighPrice: signalPrice,
+		ParkedAt: now.Format(time.RFC3339), ExpiresAt: now.Add(time.Duration(cfg.TTLMinutes) * time.Minute).Format(time.RFC3339),
+	}
+	entryMutex.Lock()
+	waiting := loadEntryWaiting()
+	waiting[path] = w
+	saveEntryWaitingLocked(waiting)
+	entryMutex.Unlock()
+
+	metricEntryTriggers.Inc("parked")
+	reference := "首个价格"
+	if signalPrice > 0 {
+		reference = formatFloat(signalPrice)
+	}
+	logOutput("⏳ 等待入场条件 %s（参考价格 %s，最长 %d 分钟）: %s\n", cond, reference, cfg.TTLMinutes, poolLabel(poolAddress))
+	return true
+}
+
+// met 按最新价格更新记录并判断条件是否满足
+func (w *EntryWaiting) met(price float64) bool {
+	w.LastPrice = price
+	w.Ticks++
+	if w.SignalPrice <= 0 {
+		w.SignalPrice = price
+	}
+	w.HighPrice = math.Max(math.Max(w.HighPrice, w.SignalPrice), price)
+	switch w.Condition.Mode {
+	case entryWithin:
+		return math.Abs(price-w.SignalPrice)/w.SignalPrice*100 <= w.Condition.Pct
+	case entryPullback:
+		return price <= w.HighPrice*(1-w.Condition.Pct/100)
+	}
+	return true
+}
+
+// evaluateEntryTrigger 获取到池的价格后检查等待中的入场条件，满足时把池文件重新加入开仓队列
+func evaluateEntryTrigger(poolAddress, priceStr string) {
+	price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	var fired []*EntryWaiting
+	entryMutex.Lock()
+	waiting := loadEntryWaiting()
+	changed := false
+	for _, w := range waiting {
+		if w.Pool != poolAddress || w.TriggeredAt != "" || entryExpired(w) {
+			continue
+		}
+		changed = true
+		if w.met(price) {
+			w.TriggeredAt = appNow().Format(time.RFC3339)
+			fired = append(fired, w)
+		}
+	}
+	if changed {
+		saveEntryWaitingLocked(waiting)
+	}
+	entryMutex.Unlock()
+
+	for _, w := range fired {
+		metricEntryTriggers.Inc("triggered")
+		logOutput("🎯 入场条件 %s 已满足（参考 %s，高点 %s，当前 %s），开仓: %s\n",
+			w.Condition, formatFloat(w.SignalPrice), formatFloat(w.HighPrice), formatFloat(price), poolLabel(poolAddress))
+		if requeueProcessed(w.Path, outcomeEntryWaiting) {
+			enqueuePoolFile(w.Path)
+		}
+	}
+}
+
+func entryExpired(w *EntryWaiting) bool {
+	t, err := time.Parse(time.RFC3339, w.ExpiresAt)
+	return err == nil && appNow().After(t)
+}
+
+// startEntryTriggerMonitor 定期放弃超过 TTL 的等待，并清理已不再等待的记录
+func startEntryTriggerMonitor() {
+	cfg := currentConfig().EntryTrigger
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.CheckIntervalSeconds) * time.Second
+	logOutput("⏳ 启动入场条件过期检查（每%v）\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			expireEntryWaiting()
+		}
+	}
+}
+
+func expireEntryWaiting() {
+	var expired []*EntryWaiting
+	entryMutex.Lock()
+	waiting := loadEntryWaiting()
+	changed := false
+	for path, w := range waiting {
+		outcome := processedOutcome(path)
+		switch {
+		case outcome != outcomeEntryWaiting && outcome != outcomeProcessing:
+			// 已按其他结果处理完（或标记已清理）
+			delete(waiting, path)
+			changed = true
+		case w.TriggeredAt == "" && entryExpired(w):
+			delete(waiting, path)
+			changed = true
+			expired = append(expired, w)
+		}
+	}
+	if changed {
+		saveEntryWaitingLocked(waiting)
+	}
+	entryMutex.Unlock()
+
+	for _, w := range expired {
+		metricEntryTriggers.Inc("expired")
+		detail := fmt.Sprintf("入场条件 %s 在 %s 前未满足（参考 %s，最近 %s，%d 次价格）",
+			w.Condition, w.ExpiresAt, formatFloat(w.SignalPrice), formatFloat(w.LastPrice), w.Ticks)
+		logOutput("⌛ %s，放弃开仓: %s\n", detail, poolLabel(w.Pool))
+		recordSkip(subsystemEntry, skipStale, w.Pool, w.Token, detail)
+		markProcessed(w.Path, outcomeEntryExpired)
+		notePoolOutcome(w.Pool, outcomeEntryExpired)
+	}
+}
+
+// listEntryWaiting 等待入场条件的池文件（按挂起时间排序）
+func listEntryWaiting() []EntryWaiting {
+	entryMutex.Lock()
+	waiting := loadEntryWaiting()
+	entryMutex.Unlock()
+	result := []EntryWaiting{}
+	for _, w := range waiting {
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].ParkedAt < result[b].ParkedAt })
+	return result
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件总线的事件类型（审计日志 kind: event 记录的 reason 字段）
+const (
+	busSignalReceived = "signal_received" // 收到新池信号
+	busLiquidityAdded = "liquidity_added" // 开仓、追加流动性或阶梯档位开仓成功
+	busRewardsClaimed = "rewards_claimed" // 领取脚本执行成功（含未达门槛）
+	busSwapExecuted   = "swap_executed"   // jupSwap 兑换成功
+	busPriceFetched   = "price_fetched"   // 价格获取成功
+	busError          = "error"           // 开仓、领取、兑换或价格获取失败
+)
+
+var busEventTypes = []string{busSignalReceived, busLiquidityAdded, busRewardsClaimed, busSwapExecuted, busPriceFetched, busError}
+
+// 审计日志中总线事件的类型
+const auditKindEvent = "event"
+
+// EventBusConfig 内部事件总线：各环节发布事件，由日志、告警与审计日志订阅者处理
+type EventBusConfig struct {
+	Enabled     bool     `json:"enabled"`
+	BufferSize  int      `json:"bufferSize"`  // 待分发事件的队列长度，队列满时由发布者同步分发（不丢事件）
+	Log         bool     `json:"log"`         // 每个事件输出一行结构化日志（debug 级别）
+	Audit       bool     `json:"audit"`       // 追加到审计日志（kind: event，需要 audit.enabled）
+	NotifyTypes []string `json:"notifyTypes"` // 以 bus_event 告警推送的事件类型
+}
+
+// BusEvent 总线上的一个事件
+type BusEvent struct {
+	Type   string            `json:"type"`
+	Time   time.Time         `json:"time"`
+	Stage  string            `json:"stage,omitempty"` // 发布的环节：entry / claim / price / sweep
+	Pool   string            `json:"pool,omitempty"`
+	Token  string            `json:"ca,omitempty"`
+	Wallet string            `json:"wallet,omitempty"`
+	Detail string            `json:"detail,omitempty"` // 结果或原因
+	Fields map[string]string `json:"fields,omitempty"` // 数量、价格、来源等附加字段
+}
+
+// busSubscriber 一个订阅者；types 为空时接收全部类型
+type busSubscriber struct {
+	name  string
+	types map[string]bool
+	fn    func(BusEvent)
+}
+
+var (
+	busMutex       sync.RWMutex
+	busSubscribers []busSubscriber
+	busQueueMutex  sync.RWMutex // 保护 busQueue 的关闭（发布与 stopEventBus 并发）
+	busQueue       chan BusEvent
+	busDone        chan struct{}
+)
+
+func (c EventBusConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.BufferSize <= 0 {
+		return fmt.Errorf("eventBus.bufferSize 必须大于0")
+	}
+	for _, t := range c.NotifyTypes {
+		if !slices.Contains(busEventTypes, t) {
+			return fmt.Errorf("eventBus.notifyTypes 不支持的事件类型: %s（可选 %s）", t, strings.Join(busEventTypes, "、"))
+		}
+	}
+	return nil
+}
+
+// subscribeEvents 注册订阅者；types 为空时接收全部类型
+func subscribeEvents(name string, fn func(BusEvent), types ...string) {
+	sub := busSubscriber{name: name, fn: fn}
+	if len(types) > 0 {
+		sub.types = map[string]bool{}
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	busMutex.Lock()
+	busSubscribers = append(busSubscribers, sub)
+	busMutex.Unlock()
+}
+
+// startEventBus 注册内置订阅者（日志、告警、审计日志）并启动分发；启停由 eventBus.enabled 控制（可热更新）
+func startEventBus() {
+	subscribeEvents("log", busLogSubscriber)
+	subscribeEvents("notify", busNotifySubscriber)
+	subscribeEvents("audit", busAuditSubscriber)
+
+	size := currentConfig().EventBus.BufferSize
+	if size <= 0 {
+		size = defaultConfig().EventBus.BufferSize
+	}
+	queue := make(chan BusEvent, size)
+	busDone = make(chan struct{})
+	busQueueMutex.Lock()
+	busQueue = queue
+	busQueueMutex.Unlock()
+	go func() {
+		defer close(busDone)
+		for e := range queue {
+			dispatchEvent(e)
+		}
+	}()
+}
+
+// stopEventBus 关闭时分发完队列中的事件
+func stopEventBus() {
+	busQueueMutex.Lock()
+	queue := busQueue
+	busQueue = nil
+	busQueueMutex.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-busDone
+}
+
+// publishEvent 发布一个事件；未启用时忽略，总线已停止（关闭期间）时由发布者同步分发
+func publishEvent(e BusEvent) {
+	if !currentConfig().EventBus.Enabled {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = appNow()
+	}
+	busQueueMutex.RLock()
+	defer busQueueMutex.RUnlock()
+	if busQueue != nil {
+		select {
+		case busQueue <- e:
+			return
+		default:
+		}
+	}
+	dispatchEvent(e)
+}
+
+// publishError 发布 error 事件
+func publishError(stage, pool, token string, err error, fields map[string]string) {
+	publishEvent(BusEvent{Type: busError, Stage: stage, Pool: pool, Token: token, Detail: err.Error(), Fields: fields})
+}
+
+func dispatchEvent(e BusEvent) {
+	busMutex.RLock()
+	subs := busSubscribers
+	busMutex.RUnlock()
+	for _, sub := range subs {
+		if sub.types != nil && !sub.types[e.Type] {
+			continue
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					// 只记录不发布：订阅 error 事件的订阅者 panic 时避免循环
+					logError("❌ 事件订阅者异常", "subscriber", sub.name, "event", e.Type)
+					recordPanic("subscriber:"+sub.name, e.Pool, &panicError{value: r, stack: debug.Stack()})
+				}
+			}()
+			sub.fn(e)
+		}()
+	}
+}
+
+func busLogSubscriber(e BusEvent) {
+	if !currentConfig().EventBus.Log {
+		return
+	}
+	kv := []interface{}{"event", e.Type, "stage", e.Stage, "pool", e.Pool, "token", e.Token, "detail", e.Detail}
+	for _, k := range sortedFieldKeys(e.Fields) {
+		kv = append(kv, k, e.Fields[k])
+	}
+	logDebug("📨 事件", kv...)
+}
+
+func busNotifySubscriber(e BusEvent) {
+	if !slices.Contains(currentConfig().EventBus.NotifyTypes, e.Type) {
+		return
+	}
+	level := levelInfo
+	if e.Type == busError {
+		level = levelWarning
+	}
+	fields := map[string]string{"type": e.Type}
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	if e.Pool != "" {
+		fields["pool"] = e.Pool
+	}
+	if e.Token != "" {
+		fields["ca"] = e.Token
+	}
+	notifyKeyed(eventBus, level, e.Type+"|"+e.Pool+"|"+e.Token, "事件 "+e.Type, e.Detail, fields)
+}
+
+func busAuditSubscriber(e BusEvent) {
+	if !currentConfig().EventBus.Audit {
+		return
+	}
+	appendAudit(AuditEntry{
+		Time: e.Time.Format(time.RFC3339), Kind: auditKindEvent, Stage: e.Stage, Reason: e.Type,
+		Pool: e.Pool, Token: e.Token, Wallet: e.Wallet, Detail: e.Detail, Fields: e.Fields,
+	})
+}
+
+func sortedFieldKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recentEvents 最近 days 天审计日志中的总线事件（可按类型与池过滤），新的在前，最多 limit 条
+func recentEvents(days, limit int, eventType, pool string) []AuditEntry {
+	entries := readAudit(auditKindEvent, days)
+	out := []AuditEntry{}
+	for i := len(entries) - 1; i >= 0 && len(out) < limit; i-- {
+		e := entries[i]
+		if (eventType != "" && e.Reason != eventType) || (pool != "" && e.Pool != pool) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// publishClaim 领取脚本执行一次：成功发布 rewards_claimed（各代币领取数量），失败发布 error
+func publishClaim(poolAddress string, o ScriptOutput, err error) {
+	if err != nil {
+		publishError(subsystemClaim, poolAddress, "", err, map[string]string{"target": "claimAllRewards"})
+		return
+	}
+	fields := map[string]string{}
+	for token, amount := range o.Claimed() {
+		fields[token] = formatFloat(amount)
+	}
+	detail := "已领取"
+	if !claimOutputClaimed(o) {
+		detail = "未达领取门槛"
+	}
+	publishEvent(BusEvent{Type: busRewardsClaimed, Stage: subsystemClaim, Pool: poolAddress, Detail: detail, Fields: fields})
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ExecConfig 外部命令（ts 脚本、jupSwap）执行策略
+type ExecConfig struct {
+	Default           RetryPolicy            `json:"default"`
+	Targets           map[string]RetryPolicy `json:"targets"`           // 按目标整体覆盖，目标名同 /metrics 中的 script 标签
+	RetryablePatterns []string               `json:"retryablePatterns"` // 额外的可重试错误关键词（匹配命令输出）
+	Output            ExecOutputConfig       `json:"output"`            // 输出逐行写入日志、空闲超时与保留上限
+}
+
+// RetryPolicy 重试与熔断策略
+type RetryPolicy struct {
+	MaxAttempts            int `json:"maxAttempts"`            // 总尝试次数（含首次），1 表示不重试
+	BaseDelayMs            int `json:"baseDelayMs"`            // 首次重试等待，之后按 2 倍递增
+	MaxDelayMs             int `json:"maxDelayMs"`             // 单次等待上限
+	BreakerThreshold       int `json:"breakerThreshold"`       // 连续失败次数达到该值时熔断（0 表示不熔断）
+	BreakerCooldownSeconds int `json:"breakerCooldownSeconds"` // 熔断后多久放行一次试探
+}
+
+// 内置的瞬时错误关键词（RPC 限流、网络抖动、区块哈希过期等）
+var defaultRetryablePatterns = []string{
+	"429", "Too Many Requests", "502", "503", "504", "Bad Gateway", "Service Unavailable", "Gateway Timeout",
+	"ECONNRESET", "ECONNREFUSED", "ETIMEDOUT", "EAI_AGAIN", "ENOTFOUND", "socket hang up", "fetch failed",
+	"network error", "timed out", "timeout", "Blockhash not found", "block height exceeded", "Node is behind",
+	"获取失败",
+}
+
+// 非幂等目标：交易可能已上链但确认超时，重新执行会重复开仓、移除或兑换；
+// 未在 exec.targets 中单独配置时不重试，配置了重试也只在输出中还没有交易签名时重试
+var nonIdempotentTargets = map[string]bool{
+	scriptAddLiquidity:           true,
+	scriptRemoveLiquidity:        true,
+	scriptRemoveLiquidityPartial: true,
+	scriptJupSwap:                true,
+}
+
+// 熔断打开时直接返回的错误
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker 单个目标的熔断状态
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+	trial     bool // 冷却结束后仅放行一次试探
+}
+
+// BreakerStatus 对外输出的熔断状态
+type BreakerStatus struct {
+	Target    string `json:"target"`
+	State     string `json:"state"` // closed / open / half-open
+	Failures  int    `json:"failures"`
+	OpenUntil string `json:"openUntil,omitempty"`
+}
+
+var (
+	breakers     = map[string]*circuitBreaker{}
+	breakerMutex sync.Mutex
+)
+
+func (p RetryPolicy) validate(name string) error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("exec.%s.maxAttempts 必须大于0", name)
+	}
+	if p.BaseDelayMs < 0 || p.MaxDelayMs < 0 || p.BreakerThreshold < 0 || p.BreakerCooldownSeconds < 0 {
+		return fmt.Errorf("exec.%s 的取值不能为负数", name)
+	}
+	if p.BreakerThreshold > 0 && p.BreakerCooldownSeconds == 0 {
+		return fmt.Errorf("exec.%s.breakerCooldownSeconds 必须大于0", name)
+	}
+	return nil
+}
+
+func (c ExecConfig) validate() error {
+	if err := c.Default.validate("default"); err != nil {
+		return err
+	}
+	for name, p := range c.Targets {
+		if err := p.validate("targets." + name); err != nil {
+			return err
+		}
+	}
+	return c.Output.validate()
+}
+
+func policyFor(target string) RetryPolicy {
+	if p, ok := currentConfig().Exec.Targets[target]; ok {
+		return p
+	}
+	p := currentConfig().Exec.Default
+	if nonIdempotentTargets[target] {
+		p.MaxAttempts = 1
+	}
+	return p
+}
+
+// 非幂等目标的输出中已有交易签名：交易已发出，不再重试
+func sentTransaction(target string, output []byte) bool {
+	return nonIdempotentTargets[target] && len(decodeScriptOutput(output).Signatures()) > 0
+}
+
+// 第 attempt 次重试前的等待：指数退避 + 抖动（取 [d/2, d)）
+func backoffDelay(p RetryPolicy, attempt int) time.Duration {
+	d := time.Duration(p.BaseDelayMs) * time.Millisecond
+	for i := 1; i < attempt && d < time.Duration(p.MaxDelayMs)*time.Millisecond; i++ {
+		d *= 2
+	}
+	if max := time.Duration(p.MaxDelayMs) * time.Millisecond; max > 0 && d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// 判断错误是否可重试：上下文取消/超时与未知错误视为致命，只有命中瞬时错误关键词才重试
+func isRetryable(ctx context.Context, output string, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, exec.ErrNotFound) {
+		return false
+	}
+	// 结构化错误事件由脚本声明是否可重试
+	if ev := decodeScriptOutput([]byte(output)).Error(); ev != nil && ev.Retryable {
+		return true
+	}
+	lower := strings.ToLower(output + "\n" + err.Error())
+	for _, patterns := range [][]string{defaultRetryablePatterns, currentConfig().Exec.RetryablePatterns} {
+		for _, p := range patterns {
+			if p != "" && strings.Contains(lower, strings.ToLower(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 熔断检查：返回 false 表示拒绝执行
+func breakerAllow(target string, p RetryPolicy) bool {
+	if p.BreakerThreshold <= 0 {
+		return true
+	}
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+	b := breakers[target]
+	if b == nil || b.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// 记录执行结果并在连续失败达到阈值时熔断
+func breakerRecord(target string, p RetryPolicy, err error) {
+	if p.BreakerThreshold <= 0 {
+		return
+	}
+	breakerMutex.Lock()
+	b := breakers[target]
+	if b == nil {
+		b = &circuitBreaker{}
+		breakers[target] = b
+	}
+	if err == nil {
+		recovered := !b.openUntil.IsZero()
+		*b = circuitBreaker{}
+		breakerMutex.Unlock()
+		if recovered {
+			logInfo("✅ 熔断恢复", "target", target)
+		}
+		return
+	}
+	b.failures++
+	tripped := b.trial || (b.openUntil.IsZero() && b.failures >= p.BreakerThreshold)
+	if tripped {
+		b.openUntil = time.Now().Add(time.Duration(p.BreakerCooldownSeconds) * time.Second)
+		b.trial = false
+	}
+	failures := b.failures
+	breakerMutex.Unlock()
+
+	if tripped {
+		metricBreakerTrips.Inc(target)
+		logWarn("⚠️ 连续失败触发熔断", "target", target, "failures", failures, "cooldown", fmt.Sprintf("%ds", p.BreakerCooldownSeconds))
+		notifyKeyed(eventCircuitOpen, levelCritical, target, "外部命令熔断", fmt.Sprintf("%s 连续失败 %d 次，暂停 %d 秒", target, failures, p.BreakerCooldownSeconds),
+			map[string]string{"target": target})
+	}
+}
+
+// runExternal 按注册表（scripts.registry）执行目标的外部命令，args 追加在注册项的固定参数之后：按目标策略退避重试并经过熔断器，返回最后一次的输出。
+// ctx 控制整体超时（含重试等待），注册项的 timeoutSeconds 限制单次执行；每次尝试都会记录 meteora_script_duration_seconds。
+// dry-run 下除只读目标外只记录命令，返回空输出；开启故障注入时每次尝试前随机延迟或失败（见 chaos.go）；安全冻结期间或本实例失去主实例租约时拒绝执行非只读目标；演示模式下由 demoExternal 返回模拟输出。
+// 收到关闭信号后不再启动新命令，已启动的命令不随 ctx 取消，宽限期内继续执行（见 drainInFlight）。
+func runExternal(ctx context.Context, target string, args ...string) (out []byte, err error) {
+	spec := scriptSpec(target)
+	if isDryRun() && !readOnlyTargets[target] {
+		simulateExternal(target, strings.Join(append([]string{spec.Command}, spec.fixedArgs()...), " "), args)
+		return nil, nil
+	}
+	if isFrozen() && !readOnlyTargets[target] {
+		logWarn("🧊 交易已冻结，拒绝执行", "target", target)
+		return nil, errFrozen
+	}
+	if shuttingDown() {
+		logWarn("⏹️ 正在关闭，不再执行新的命令", "target", target)
+		return nil, errShuttingDown
+	}
+	if !isLeader() && !readOnlyTargets[target] {
+		logWarn("👥 本实例不是主实例，拒绝执行", "target", target)
+		return nil, errNotLeader
+	}
+	jobID, err := beginJob(ctx, target, args)
+	if err != nil {
+		logError("❌ 预写日志无法落盘，拒绝执行", "target", target, "pool", argValue(args, "--pool"), "error", err)
+		notifyKeyed(eventJobInterrupted, levelCritical, "wal", "预写日志不可用",
+			fmt.Sprintf("%s 未执行：%v", target, err), map[string]string{"target": target})
+		return nil, err
+	}
+	defer func() { finishJob(jobID, out, err) }()
+	// 多钱包：按上下文中的钱包设置 USER_WALLET_ADDRESS 与私钥
+	wallet, secret, err := walletEnv(ctx)
+	if err != nil && !isDemo() {
+		logError("❌ 无法加载钱包", "target", target, "error", err)
+		return nil, err
+	}
+	p := policyFor(target)
+	for attempt := 1; attempt <= p.MaxAttempts; attempt++ {
+		if !breakerAllow(target, p) {
+			logWarn("⚠️ 熔断中，跳过执行", "target", target)
+			return nil, errCircuitOpen
+		}
+		// 每次尝试使用当时缓存的区块哈希，重试不会沿用已过期的哈希
+		runArgs := append(args[:len(args):len(args)], blockhashArgs(target)...)
+		start := time.Now()
+		done := beginBotActivity()
+		cmdCtx, cancelCmd := commandContext(ctx)
+		if spec.TimeoutSeconds > 0 {
+			var cancelTimeout context.CancelFunc
+			cmdCtx, cancelTimeout = context.WithTimeout(cmdCtx, time.Duration(spec.TimeoutSeconds)*time.Second)
+			prev := cancelCmd
+			cancelCmd = func() { cancelTimeout(); prev() }
+		}
+		out, err = chaosExternal(cmdCtx, target)
+		switch {
+		case err != nil:
+			// 注入的失败（或注入延迟期间取消），不执行命令
+		case isDemo():
+			out, err = demoExternal(cmdCtx, target, args)
+		default:
+			fixed := spec.fixedArgs()
+			cmd := exec.CommandContext(cmdCtx, spec.Command, append(fixed[:len(fixed):len(fixed)], runArgs...)...)
+			cmd.Dir = spec.dir()
+			// 每次尝试使用当时最优的 RPC 节点
+			cmd.Env = scriptEnv(spec, wallet, rpcScriptURL())
+			closeKey, keyErr := attachSigningKey(cmd, spec, secret)
+			if keyErr != nil {
+				out, err = nil, fmt.Errorf("无法向子进程传入私钥: %w", keyErr)
+				break
+			}
+			// 独立进程组：终端的 Ctrl+C 只发给本进程，子进程由宽限期控制；超时或取消时终止整个进程组（npx 启动的 node 一并结束）
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+			cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+			out, err = runCommand(cmd, target)
+			closeKey()
+			if fixtureRecordDir != "" {
+				recordFixture(target, runArgs, out, err)
+			}
+		}
+		// JSON 事件行与按正则生成的事件统一为 @@event 行
+		out = normalizeScriptOutput(target, spec, out)
+		if err != nil && errors.Is(cmdCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			logWarn("⏰ 外部命令执行超时，已终止", "target", target, "timeout", fmt.Sprintf("%ds", spec.TimeoutSeconds))
+			err = fmt.Errorf("%w: %s 超过 %d 秒 (%v)", errScriptTimeout, target, spec.TimeoutSeconds, err)
+		}
+		if err == nil {
+			err = checkScriptOutput(target, spec, out)
+		}
+		cancelCmd()
+		noteBotActivity(target, start, out)
+		trackTransactions(ctx, target, runArgs, out)
+		queueTxCosts(ctx, target, runArgs, out)
+		if err != nil {
+			noteRPCOutput(target, string(out)+"\n"+err.Error())
+		} else {
+			noteRPCOutput(target, string(out))
+		}
+		noteScriptRateLimits(out)
+		done()
+		observeScript(target, start, err)
+		breakerRecord(target, p, err)
+		if err == nil || attempt == p.MaxAttempts || errors.Is(err, errOutputSchema) || sentTransaction(target, out) || !isRetryable(ctx, string(out), err) {
+			return out, err
+		}
+
+		delay := backoffDelay(p, attempt)
+		metricExecRetries.Inc(target)
+		logCommandOutput(out)
+		logWarn("⚠️ 外部命令失败，准备重试", "target", target, "attempt", attempt, "maxAttempts", p.MaxAttempts, "delay", delay.Round(time.Millisecond), "error", err)
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-globalCtx.Done():
+			return out, err
+		case <-time.After(delay):
+		}
+	}
+	return out, err
+}
+
+// 所有熔断器状态
+func listBreakerStatus() []BreakerStatus {
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+	result := make([]BreakerStatus, 0, len(breakers))
+	for target, b := range breakers {
+		s := BreakerStatus{Target: target, State: "closed", Failures: b.failures}
+		if !b.openUntil.IsZero() {
+			s.State = "open"
+			if b.trial || !time.Now().Before(b.openUntil) {
+				s.State = "half-open"
+			}
+			s.OpenUntil = b.openUntil.Format(time.RFC3339)
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Target < result[b].Target })
+	return result
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestPolicyForNonIdempotentTargets(t *testing.T) {
+	prev := currentConfig()
+	defer setConfig(prev)
+	cfg := *prev
+	cfg.Exec.Default = RetryPolicy{MaxAttempts: 3, BreakerThreshold: 5, BreakerCooldownSeconds: 60}
+	cfg.Exec.Targets = map[string]RetryPolicy{scriptJupSwap: {MaxAttempts: 2}}
+	setConfig(&cfg)
+
+	tests := []struct {
+		target   string
+		attempts int
+	}{
+		{scriptAddLiquidity, 1},
+		{scriptRemoveLiquidity, 1},
+		{scriptRemoveLiquidityPartial, 1},
+		{scriptJupSwap, 2}, // exec.targets 中单独配置
+		{scriptClaimAllRewards, 3},
+		{scriptFetchPrice, 3},
+	}
+	for _, tt := range tests {
+		p := policyFor(tt.target)
+		if p.MaxAttempts != tt.attempts {
+			t.Errorf("%s: maxAttempts=%d，应为 %d", tt.target, p.MaxAttempts, tt.attempts)
+		}
+		if tt.target == scriptAddLiquidity && p.BreakerThreshold != 5 {
+			t.Errorf("%s: 应沿用 default 的熔断参数，实际 %+v", tt.target, p)
+		}
+	}
+}
+
+func TestSentTransaction(t *testing.T) {
+	sig := demoEvent(ScriptEvent{Type: scriptEventSignature, Signature: demoAddress() + demoAddress(), Action: "addLiquidity"})
+	timeout := "Error: transaction confirmation timed out\n"
+	tests := []struct {
+		name   string
+		target string
+		output string
+		want   bool
+	}{
+		{"开仓已发出交易", scriptAddLiquidity, sig + timeout, true},
+		{"开仓未发出交易", scriptAddLiquidity, timeout, false},
+		{"兑换已发出交易", scriptJupSwap, sig, true},
+		{"领取可重复执行", scriptClaimAllRewards, sig + timeout, false},
+	}
+	for _, tt := range tests {
+		if got := sentTransaction(tt.target, []byte(tt.output)); got != tt.want {
+			t.Errorf("%s: sentTransaction=%v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
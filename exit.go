@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// 平仓原因
+const (
+	exitReasonMaxAge = "max_age"
+	exitReasonManual = "manual"
+	// 阶梯仓位组达到组级止盈
+	exitReasonGroupTarget = "group_target"
+)
+
+// claimAndClosePosition 领取全部手续费/奖励并移除流动性、关闭仓位。
+// removeLiquidity.ts 使用 shouldClaimAndClose，领取、移除与关闭在同一批交易内完成，
+// 不会先移除再单独领取而遗漏未领取的收益。止损、超时与手动平仓统一走此入口。
+// 池存在阶梯仓位组时先平附加档位，最后平主仓位（负责 swap 与归档池 JSON）。
+// extraArgs 追加到主仓位的 removeLiquidity.ts 参数（如 --skipSwap）。
+func claimAndClosePosition(poolAddress, reason string, extraArgs ...string) bool {
+	if isPaperPool(poolAddress) {
+		if !paperHasOpenPosition(poolAddress) {
+			return false
+		}
+		logOutput("🚪 [paper] 模拟领取并平仓 (%s): pool=%s\n", reason, poolAddress)
+		simulatePoolAction(poolAddress, "removeLiquidity", poolDex(poolAddress).CommandLine(dexOpRemoveLiquidity, append([]string{fmt.Sprintf("--pool=%s", poolAddress)}, extraArgs...)...))
+		markPositionClosed(poolAddress, reason)
+		return true
+	}
+
+	positionAddress := readPositionFromPoolJSON(poolAddress)
+	if positionAddress == "" {
+		logOutput("⚠️ 找不到 positionAddress，无法领取并平仓: pool=%s\n", poolAddress)
+		return false
+	}
+
+	if err := transitionPool(poolAddress, poolExiting, reason); err != nil {
+		logOutput("⚠️ 池当前状态不允许平仓: pool=%s（%v）\n", poolAddress, err)
+		return false
+	}
+	logOutput("🚪 领取并平仓 (%s): pool=%s position=%s\n", reason, poolAddress, positionAddress)
+	grouped := openPositionGroup(poolAddress) != nil
+	if grouped && !closeLadderLegs(poolAddress) {
+		logOutput("⚠️ 部分阶梯档位平仓失败，将在下一轮领取时重试: pool=%s\n", poolAddress)
+	}
+	if !runRemoveLiquidity(poolAddress, positionAddress, extraArgs...) {
+		transitionPoolFrom(poolAddress, poolExiting, poolActive, "移除流动性失败")
+		return false
+	}
+	if grouped {
+		markLegClosed(poolAddress, positionAddress)
+		if openLegCount(poolAddress) == 0 {
+			markGroupClosed(poolAddress)
+		}
+	}
+	markPositionClosed(poolAddress, reason)
+	return true
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出的数据集
+const (
+	exportPositions = "positions" // 仓位生命周期记录与盈亏台账汇总
+	exportTrades    = "trades"    // 程序发起的兑换（最近 200 条）
+	exportClaims    = "claims"    // 各池每次实际领取的到账数量与交易签名
+	exportLedger    = "ledger"    // 盈亏台账全部记录（开仓、领取、兑换、交易费、押金）
+	exportPrices    = "prices"    // 价格历史原始采样
+)
+
+var exportDatasets = []string{exportPositions, exportTrades, exportClaims, exportLedger, exportPrices}
+
+// 导出格式
+const (
+	exportFormatCSV     = "csv"
+	exportFormatParquet = "parquet"
+)
+
+// 列类型
+const (
+	exportString = "string"
+	exportFloat  = "float"
+	exportInt    = "int"
+)
+
+// ExportConfig 数据导出：按 schedules.export 将仓位、兑换、领取与价格历史写成 CSV 或 Parquet，可同时上传到 S3；HTTP 与子命令导出不受 enabled 限制
+type ExportConfig struct {
+	Enabled   bool     `json:"enabled"`   // 启用定时导出
+	Dir       string   `json:"dir"`       // 导出目录，相对路径按程序目录解析，为空时为 <数据目录>/exports
+	Formats   []string `json:"formats"`   // csv / parquet
+	Datasets  []string `json:"datasets"`  // 定时导出的数据集，为空表示全部
+	PriceDays int      `json:"priceDays"` // 价格历史导出最近 N 天
+	KeepLocal bool     `json:"keepLocal"` // 上传 S3 成功后保留本地文件
+	S3        S3Config `json:"s3"`
+}
+
+// exportColumn 表的一列
+type exportColumn struct {
+	Name string
+	Type string // string / float / int
+}
+
+// exportTable 一个数据集导出的表（行中的值按列类型为 string、float64 或 int64）
+type exportTable struct {
+	Name    string
+	Columns []exportColumn
+	Rows    [][]interface{}
+}
+
+// ExportFile 一次导出写出的文件
+type ExportFile struct {
+	Dataset string `json:"dataset"`
+	Format  string `json:"format"`
+	Rows    int    `json:"rows"`
+	Path    string `json:"path,omitempty"`
+	S3      string `json:"s3,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (c ExportConfig) validate() error {
+	if len(c.Formats) == 0 {
+		return fmt.Errorf("export.formats 不能为空")
+	}
+	for _, f := range c.Formats {
+		if f != exportFormatCSV && f != exportFormatParquet {
+			return fmt.Errorf("export.formats 仅支持 %s 或 %s", exportFormatCSV, exportFormatParquet)
+		}
+	}
+	for _, d := range c.Datasets {
+		if !slices.Contains(exportDatasets, d) {
+			return fmt.Errorf("export.datasets 不支持 %s（可选 %s）", d, strings.Join(exportDatasets, "、"))
+		}
+	}
+	if c.PriceDays <= 0 {
+		return fmt.Errorf("export.priceDays 必须大于0")
+	}
+	return c.S3.validate("export")
+}
+
+func exportDir() string {
+	if currentConfig().Export.Dir == "" {
+		return dataPath("exports")
+	}
+	return resolvePath(currentConfig().Export.Dir)
+}
+
+func (t *exportTable) add(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// buildExportTable 读取一个数据集；priceDays 只用于价格历史
+func buildExportTable(dataset string, priceDays int) (*exportTable, error) {
+	switch dataset {
+	case exportPositions:
+		return exportPositionTable(), nil
+	case exportTrades:
+		return exportTradeTable(), nil
+	case exportClaims:
+		return exportClaimTable(), nil
+	case exportLedger:
+		return exportLedgerTable(exportLedger, ""), nil
+	case exportPrices:
+		return exportPriceTable(priceDays)
+	}
+	return nil, fmt.Errorf("未知的数据集: %s", dataset)
+}
+
+func exportPositionTable() *exportTable {
+	t := &exportTable{Name: exportPositions, Columns: []exportColumn{
+		{"pool", exportString}, {"ca", exportString}, {"position", exportString}, {"mode", exportString}, {"profile", exportString},
+		{"state", exportString}, {"opened_at", exportString}, {"closed_at", exportString}, {"close_reason", exportString},
+		{"sol_amount", exportFloat}, {"entry_price", exportFloat}, {"last_price", exportFloat}, {"pnl_percent", exportFloat},
+		{"withdrawn_pct", exportFloat}, {"cost_sol", exportFloat}, {"value_sol", exportFloat}, {"realized_sol", exportFloat},
+		{"unrealized_sol", exportFloat}, {"fees_sol", exportFloat}, {"rent_sol", exportFloat},
+	}}
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	for _, r := range listPositionRecords() {
+		var s PnLSummary
+		if p, ok := ledger[r.PoolAddress]; ok {
+			s = p.summary()
+		}
+		t.add(r.PoolAddress, r.TokenAddress, r.Position, r.Mode, r.Profile, r.State, r.OpenedAt, r.ClosedAt, r.CloseReason,
+			r.SolAmount, r.EntryPrice, r.LastPrice, r.PnLPercent, r.withdrawnPct(),
+			s.CostSOL, s.ValueSOL, s.RealizedSOL, s.UnrealizedSOL, s.FeesSOL, s.RentSOL)
+	}
+	return t
+}
+
+func exportTradeTable() *exportTable {
+	t := &exportTable{Name: exportTrades, Columns: []exportColumn{
+		{"time", exportString}, {"token", exportString}, {"wallet", exportString}, {"output_mint", exportString},
+		{"proceeds", exportFloat}, {"fee_sol", exportFloat}, {"value_usd", exportFloat}, {"source", exportString}, {"pools", exportString},
+	}}
+	for _, s := range loadHistory[SwapRecord]("swap_history") {
+		t.add(s.Time, s.Token, s.Wallet, s.OutputMint, s.Proceeds, s.FeeSOL, s.ValueUSD, s.Source, strings.Join(s.Pools, ";"))
+	}
+	return t
+}
+
+// exportLedgerTable 盈亏台账记录（kind 为空表示全部类型），按时间排序
+func exportLedgerTable(name, kind string) *exportTable {
+	t := &exportTable{Name: name, Columns: []exportColumn{
+		{"at", exportString}, {"pool", exportString}, {"ca", exportString}, {"mode", exportString}, {"kind", exportString},
+		{"position", exportString}, {"sol", exportFloat}, {"usd", exportFloat}, {"note", exportString}, {"profile", exportString},
+	}}
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	for _, p := range ledger {
+		for _, e := range p.Entries {
+			if kind == "" || e.Kind == kind {
+				t.add(e.At, p.PoolAddress, p.TokenAddress, p.Mode, e.Kind, e.Position, e.SOL, e.USD, e.Note, e.Profile)
+			}
+		}
+	}
+	sort.SliceStable(t.Rows, func(a, b int) bool { return t.Rows[a][0].(string) < t.Rows[b][0].(string) })
+	return t
+}
+
+// exportClaimTable 每次领取一行：代币（非 SOL 的一侧）与 SOL 的到账数量、估值与签名（逗号分隔）
+func exportClaimTable() *exportTable {
+	t := &exportTable{Name: exportClaims, Columns: []exportColumn{
+		{"at", exportString}, {"pool", exportString}, {"ca", exportString}, {"position", exportString},
+		{"token", exportString}, {"symbol", exportString}, {"token_amount", exportFloat}, {"sol_amount", exportFloat},
+		{"value_usd", exportFloat}, {"value_sol", exportFloat}, {"fee_sol", exportFloat}, {"signatures", exportString},
+	}}
+	claimsMutex.Lock()
+	records := loadClaimRecords()
+	claimsMutex.Unlock()
+	for _, pc := range records {
+		for _, c := range pc.Claims {
+			tokens := make([]string, 0, len(c.Amounts))
+			for token := range c.Amounts {
+				if token != solMint {
+					tokens = append(tokens, token)
+				}
+			}
+			sort.Strings(tokens)
+			token, tokenAmount := "", 0.0
+			if len(tokens) > 0 {
+				token, tokenAmount = tokens[0], c.Amounts[tokens[0]]
+			}
+			t.add(c.At, pc.PoolAddress, pc.TokenAddress, c.Position, token, tokenSymbol(token), tokenAmount, c.Amounts[solMint],
+				c.ValueUSD, c.ValueSOL, c.FeeSOL, strings.Join(c.Signatures, ","))
+		}
+	}
+	sort.SliceStable(t.Rows, func(a, b int) bool { return t.Rows[a][0].(string) < t.Rows[b][0].(string) })
+	return t
+}
+
+func exportPriceTable(days int) (*exportTable, error) {
+	t := &exportTable{Name: exportPrices, Columns: []exportColumn{
+		{"time", exportString}, {"ca", exportString}, {"pool", exportString}, {"price", exportFloat},
+		{"source", exportString}, {"mode", exportString},
+	}}
+	files, err := os.ReadDir(priceHistoryDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return t, nil
+		}
+		return nil, err
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+			continue
+		}
+		for _, s := range loadPriceHistory(strings.TrimSuffix(f.Name(), ".jsonl"), since) {
+			price, err := strconv.ParseFloat(strings.TrimSpace(s.Price), 64)
+			if err != nil {
+				continue
+			}
+			t.add(s.Time, s.Token, s.PoolAddress, price, s.Source, s.Mode)
+		}
+	}
+	return t, nil
+}
+
+// encodeExport 按格式编码表
+func encodeExport(t *exportTable, format string) ([]byte, error) {
+	if format == exportFormatParquet {
+		return writeParquet(t)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Name
+	}
+	w.Write(header)
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			switch x := v.(type) {
+			case float64:
+				record[i] = strconv.FormatFloat(x, 'f', -1, 64)
+			case int64:
+				record[i] = strconv.FormatInt(x, 10)
+			default:
+				record[i] = fmt.Sprint(x)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func exportContentType(format string) string {
+	if format == exportFormatParquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// runExport 导出数据集（为空时按配置）到导出目录，配置了 S3 时同时上传；文件名为 <数据集>_<时间>.<格式>
+func runExport(datasets []string, formats []string) []ExportFile {
+	cfg := currentConfig().Export
+	if len(datasets) == 0 {
+		datasets = cfg.Datasets
+	}
+	if len(datasets) == 0 {
+		datasets = exportDatasets
+	}
+	if len(formats) == 0 {
+		formats = cfg.Formats
+	}
+	stamp := appNow().Format("20060102-150405")
+	dir := exportDir()
+	results := []ExportFile{}
+	for _, dataset := range datasets {
+		t, err := buildExportTable(dataset, cfg.PriceDays)
+		for _, format := range formats {
+			f := ExportFile{Dataset: dataset, Format: format}
+			if err == nil {
+				f.Rows = len(t.Rows)
+				err = writeExportFile(cfg, dir, dataset+"_"+stamp+"."+format, t, &f)
+			}
+			if err != nil {
+				f.Error = err.Error()
+				logError("❌ 导出失败", "dataset", dataset, "format", format, "error", err)
+			}
+			results = append(results, f)
+			err = nil
+		}
+	}
+	logInfo("📦 数据导出完成", "dir", dir, "files", len(results))
+	return results
+}
+
+func writeExportFile(cfg ExportConfig, dir, name string, t *exportTable, f *ExportFile) error {
+	data, err := encodeExport(t, f.Format)
+	if err != nil {
+		return err
+	}
+	upload := cfg.S3.Bucket != ""
+	if !upload || cfg.KeepLocal {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		f.Path = filepath.Join(dir, name)
+		if err := writeFileAtomic(f.Path, data, 0644); err != nil {
+			return err
+		}
+	}
+	if upload {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+		defer cancel()
+		if f.S3, err = putS3Object(ctx, cfg.S3, name, data, exportContentType(f.Format)); err != nil {
+			return fmt.Errorf("上传 S3 失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// executeExport 定时导出（schedules.export）
+func executeExport() {
+	for _, f := range runExport(nil, nil) {
+		if f.Error != "" {
+			notifyKeyed(eventExportFailed, levelWarning, f.Dataset, "数据导出失败", f.Error,
+				map[string]string{"dataset": f.Dataset, "format": f.Format})
+		}
+	}
+}
+
+func cmdExport(args []string) {
+	fs, common := newCommandFlags("export")
+	datasets := fs.String("datasets", "", "数据集，逗号分隔（"+strings.Join(exportDatasets, "、")+"），为空时按配置 export.datasets")
+	format := fs.String("format", "", "csv 或 parquet，为空时按配置 export.formats")
+	dir := fs.String("dir", "", "导出目录（覆盖 export.dir）")
+	fs.Parse(args)
+	loadAppConfig(common)
+
+	var names, formats []string
+	for _, d := range strings.Split(*datasets, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			if !slices.Contains(exportDatasets, d) {
+				fmt.Fprintf(os.Stderr, "未知的数据集: %s\n", d)
+				os.Exit(2)
+			}
+			names = append(names, d)
+		}
+	}
+	if *format != "" {
+		if *format != exportFormatCSV && *format != exportFormatParquet {
+			fmt.Fprintf(os.Stderr, "--format 仅支持 csv 或 parquet\n")
+			os.Exit(2)
+		}
+		formats = []string{*format}
+	}
+	if *dir != "" {
+		currentConfig().Export.Dir = *dir
+	}
+	failed := false
+	for _, f := range runExport(names, formats) {
+		target := f.Path
+		if f.S3 != "" {
+			target = f.S3
+		}
+		if f.Error != "" {
+			failed = true
+			fmt.Printf("%-10s %-8s 失败: %s\n", f.Dataset, f.Format, f.Error)
+			continue
+		}
+		fmt.Printf("%-10s %-8s %6d 行  %s\n", f.Dataset, f.Format, f.Rows, target)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
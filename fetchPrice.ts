@@ -7,15 +7,22 @@ import DLMM from '@meteora-ag/dlmm';
 import * as dotenv from 'dotenv';
 import * as CryptoJS from 'crypto-js';
 import axios from 'axios';
-import { exec } from 'child_process';
-import { promisify } from 'util';
 import * as fs from 'fs';
 import * as path from 'path';
+import { writeFileAtomic } from './dataFile';
+import { execWithKey } from './signingKey';
 
-const execAsync = promisify(exec);
+// 程序目录与数据目录（由 Go 调度程序通过环境变量传入；单独运行时为脚本所在目录及其下的 data）
+const BASE_DIR = process.env.METEORA_BASE_DIR || __dirname;
+const DATA_DIR = process.env.METEORA_DATA_DIR || path.join(BASE_DIR, 'data');
+
+// ===== 结构化输出（Go 端按 "@@event <json>" 行解析，见 scriptproto.go）=====
+function emitEvent(type: string, fields: Record<string, unknown> = {}): void {
+  console.log(`@@event ${JSON.stringify({ type, ...fields })}`);
+}
 
 // ===== 价格缓存（跨进程、基于文件）=====
-const PRICE_CACHE_DIR = '/Users/yqw/meteora_dlmm/data/prices';
+const PRICE_CACHE_DIR = path.join(DATA_DIR, 'prices');
 
 interface PriceCacheEntry {
   price: string;           // 原样字符串
@@ -53,7 +60,7 @@ function writeCachedPrice(tokenContractAddress: string, price: string): void {
     ensurePriceCacheDir();
     const p = getPriceCachePath(tokenContractAddress);
     const entry: PriceCacheEntry = { price, timestamp: Date.now() };
-    fs.writeFileSync(p, JSON.stringify(entry));
+    writeFileAtomic(p, JSON.stringify(entry));
   } catch (_) {}
 }
 
@@ -70,7 +77,7 @@ interface PriceMonitorState {
 }
 
 // 监控状态文件路径
-const PRICE_MONITOR_STATES_FILE = path.join('/Users/yqw/meteora_dlmm/data', '.priceMonitorStates.json');
+const PRICE_MONITOR_STATES_FILE = path.join(DATA_DIR, '.priceMonitorStates.json');
 
 // 从文件加载监控状态
 function loadPriceMonitorStates(): Map<string, PriceMonitorState> {
@@ -97,7 +104,7 @@ function savePriceMonitorStates(states: Map<string, PriceMonitorState>): void {
     for (const [key, value] of states.entries()) {
       data[key] = value;
     }
-    fs.writeFileSync(PRICE_MONITOR_STATES_FILE, JSON.stringify(data, null, 2));
+    writeFileAtomic(PRICE_MONITOR_STATES_FILE, JSON.stringify(data, null, 2));
   } catch (_) {
     // 忽略写入错误
   }
@@ -111,7 +118,7 @@ interface ZeroXMonitorState {
   zeroSince: number | null;   // 开始为0的时间戳(ms)
 }
 
-const ZERO_X_STATES_FILE = path.join('/Users/yqw/meteora_dlmm/data', '.zeroXStates.json');
+const ZERO_X_STATES_FILE = path.join(DATA_DIR, '.zeroXStates.json');
 
 // 从文件加载状态
 function loadZeroXStates(): Map<string, ZeroXMonitorState> {
@@ -138,7 +145,7 @@ function saveZeroXStates(states: Map<string, ZeroXMonitorState>): void {
     for (const [key, value] of states.entries()) {
       data[key] = value;
     }
-    fs.writeFileSync(ZERO_X_STATES_FILE, JSON.stringify(data, null, 2));
+    writeFileAtomic(ZERO_X_STATES_FILE, JSON.stringify(data, null, 2));
   } catch (_) {
     // 忽略写入错误
   }
@@ -215,8 +222,8 @@ async function checkZeroXAndMaybeRemove(poolAddress: string, positionAddress: st
 // 加载环境变量
 dotenv.config();
 
-// 连接配置
-const connection = new Connection(clusterApiUrl('mainnet-beta'), 'confirmed');
+// 连接配置（RPC_URL 由 main.go 按 RPC 节点池的当前最优节点传入，未设置时使用公共节点）
+const connection = new Connection(process.env.RPC_URL || clusterApiUrl('mainnet-beta'), 'confirmed');
 
 // 从命令行读取参数
 const argv = process.argv.slice(2);
@@ -235,6 +242,11 @@ function resolveTokenAddressFromArgs(): string | undefined {
   return undefined;
 }
 
+// 是否为仅价格模式（研究模式：只输出价格，不做比较与移除）
+function resolvePriceOnlyFromArgs(): boolean {
+  return argv.includes('--price-only') || argv.includes('--price-only=true');
+}
+
 // 通用的引号处理函数
 function sanitizeString(input: string): string {
   let s = input.trim();
@@ -248,7 +260,30 @@ function sanitizeString(input: string): string {
   return s.trim();
 }
 
-// 通用重试工具
+// OKX 的限流错误码（HTTP 429 或响应体 code=50011）
+const OKX_RATE_LIMIT_CODE = '50011';
+
+// 上游响应的限速信息交给 main.go 的共享请求预算（ratelimit 事件），返回 Retry-After 秒数（没有时为 0）
+function reportRateLimit(api: string, status: number, headers: Record<string, any> | undefined): number {
+  const h = headers || {};
+  const num = (v: any): number | undefined => {
+    const n = Number(v);
+    return v !== undefined && v !== null && v !== '' && Number.isFinite(n) ? n : undefined;
+  };
+  const remaining = num(h['x-ratelimit-remaining'] ?? h['ratelimit-remaining']);
+  const limit = num(h['x-ratelimit-limit'] ?? h['ratelimit-limit']);
+  let reset = num(h['x-ratelimit-reset'] ?? h['ratelimit-reset']);
+  // 重置时间可能是 Unix 时间戳（秒或毫秒）
+  if (reset !== undefined && reset > 1e12) reset = Math.max(0, reset / 1000 - Date.now() / 1000);
+  else if (reset !== undefined && reset > 1e9) reset = Math.max(0, reset - Date.now() / 1000);
+  const retryAfter = num(h['retry-after']);
+  if (remaining !== undefined || retryAfter !== undefined || status === 429) {
+    emitEvent('ratelimit', { api, httpStatus: status, remaining, limit, resetSeconds: reset, retryAfter });
+  }
+  return retryAfter ?? 0;
+}
+
+// 通用重试工具：被限流时按 Retry-After 等待（错误对象的 retryAfterMs），其余失败 1 秒后重试
 async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
   const maxAttempts = 3;
   let lastErr: unknown;
@@ -258,8 +293,9 @@ async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
     } catch (err) {
       lastErr = err;
       if (attempt < maxAttempts) {
-        console.log(`获取失败，1秒后重试(${attempt}/${maxAttempts - 1}) -> ${desc}:`, err instanceof Error ? err.message : String(err));
-        await new Promise((r) => setTimeout(r, 1000));
+        const waitMs = Number((err as any)?.retryAfterMs) || 1000;
+        console.log(`获取失败，${(waitMs / 1000).toFixed(1)}秒后重试(${attempt}/${maxAttempts - 1}) -> ${desc}:`, err instanceof Error ? err.message : String(err));
+        await new Promise((r) => setTimeout(r, waitMs));
       }
     }
   }
@@ -271,7 +307,7 @@ async function withRetry<T>(fn: () => Promise<T>, desc: string): Promise<T> {
  */
 async function readPoolDataFromJSON(poolAddress: string): Promise<{c: number, positionAddress: string} | null> {
   try {
-    const dataPath = path.join('/Users/yqw/meteora_dlmm/data', `${poolAddress}.json`);
+    const dataPath = path.join(DATA_DIR, `${poolAddress}.json`);
     
     if (!fs.existsSync(dataPath)) {
       console.log(`JSON 文件不存在: ${dataPath}`);
@@ -320,9 +356,7 @@ async function executeRemoveLiquidity(poolAddress: string, positionAddress: stri
     const command = `npx ts-node removeLiquidity.ts --pool=${poolAddress} --position=${positionAddress}`;
     console.log(`执行命令: ${command}`);
     
-    const { stdout, stderr } = await execAsync(command, {
-      cwd: '/Users/yqw/meteora_dlmm'
-    });
+    const { stdout, stderr } = await execWithKey(command, BASE_DIR);
     
     if (stdout) {
       console.log('移除流动性输出:', stdout);
@@ -495,7 +529,23 @@ export async function fetchOkxLatestPrice(tokenContractAddress: string): Promise
     'OK-ACCESS-SIGN': signature
   } as const;
 
-  const resp = await withRetry(() => axios.post(url, bodyArray, { headers }), 'OKX 最新价格');
+  const resp = await withRetry(async () => {
+    try {
+      const r = await axios.post(url, bodyArray, { headers });
+      if (r?.data?.code === OKX_RATE_LIMIT_CODE) {
+        const retryAfter = reportRateLimit('okx', 429, r.headers as Record<string, any>);
+        throw Object.assign(new Error(`OKX 限流: ${r.data.msg || ''}`), { retryAfterMs: retryAfter * 1000 || undefined });
+      }
+      reportRateLimit('okx', r.status, r.headers as Record<string, any>);
+      return r;
+    } catch (err: any) {
+      if (err?.response && err.retryAfterMs === undefined) {
+        const retryAfter = reportRateLimit('okx', err.response.status, err.response.headers);
+        if (retryAfter > 0) err.retryAfterMs = retryAfter * 1000;
+      }
+      throw err;
+    }
+  }, 'OKX 最新价格');
   if (!resp?.data) {
     console.log('OKX 价格响应为空');
     return undefined;
@@ -545,7 +595,13 @@ async function main() {
     const latestPrice = await fetchOkxLatestPrice(tokenAddress);
     if (latestPrice !== undefined) {
       console.log('OKX DEX 最新价格:', latestPrice);
-      console.log('price:', latestPrice); // 专门输出price字段，供main.go解析
+      console.log('price:', latestPrice); // 专门输出price字段，供main.go解析（旧协议）
+      emitEvent('price', { price: String(latestPrice), source: 'okx' });
+
+      if (resolvePriceOnlyFromArgs()) {
+        console.log('🔬 仅价格模式，跳过价格比较与移除检查');
+        return;
+      }
       
       // 读取池数据进行比较
       const poolData = await readPoolDataFromJSON(poolAddress);
@@ -592,6 +648,7 @@ async function main() {
     
   } catch (error) {
     console.error('错误:', error);
+    emitEvent('error', { code: 'FETCH_PRICE_FAILED', message: error instanceof Error ? error.message : String(error) });
     process.exit(1);
   }
 }
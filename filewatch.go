@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 文件监听方式
+const (
+	fileWatchAuto     = "auto"     // 优先 fsnotify，创建或添加目录失败时该目录改为轮询
+	fileWatchFSNotify = "fsnotify" // 只用 fsnotify，失败时报错（与旧版本相同）
+	fileWatchPoll     = "poll"     // 只轮询（网络文件系统、部分容器与 VPS 的 overlay 上 inotify 收不到事件）
+)
+
+// FileWatchConfig data 目录、CSV、名单与配置文件的监听方式。
+// Linux 上 inotify 实例数或监听数达到上限（fs.inotify.max_user_instances / max_user_watches）时 fsnotify 会失败，
+// auto 模式下自动改为轮询，不再因此无法启动
+type FileWatchConfig struct {
+	Mode           string `json:"mode"`           // auto | fsnotify | poll
+	PollIntervalMs int    `json:"pollIntervalMs"` // 轮询间隔
+}
+
+func (c FileWatchConfig) validate() error {
+	switch c.Mode {
+	case fileWatchAuto, fileWatchFSNotify, fileWatchPoll:
+	default:
+		return fmt.Errorf("fileWatch.mode 仅支持 %s、%s 或 %s", fileWatchAuto, fileWatchFSNotify, fileWatchPoll)
+	}
+	if c.PollIntervalMs < 100 {
+		return fmt.Errorf("fileWatch.pollIntervalMs 不能小于 100")
+	}
+	return nil
+}
+
+// fileStamp 轮询时比较的文件状态
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// fileWatcher 与 fsnotify.Watcher 相同的用法（Events / Errors / Add / Remove / Close），
+// 轮询的目录按大小与修改时间合成 Create / Write / Remove 事件
+type fileWatcher struct {
+	Events <-chan fsnotify.Event
+	Errors <-chan error
+
+	name     string // 日志中的监听名称
+	mode     string
+	interval time.Duration
+	events   chan fsnotify.Event
+	errors   chan error
+	fsw      *fsnotify.Watcher // poll 模式或创建失败时为 nil
+	mu       sync.Mutex
+	polled   map[string]map[string]fileStamp // 轮询的目录 -> 文件名 -> 状态
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// newFileWatcher 按 fileWatch 配置创建监听；auto 模式下只有 fsnotify 模式才会返回错误
+func newFileWatcher(name string) (*fileWatcher, error) {
+	cfg := currentConfig().FileWatch
+	w := &fileWatcher{
+		name:     name,
+		mode:     cfg.Mode,
+		interval: time.Duration(cfg.PollIntervalMs) * time.Millisecond,
+		events:   make(chan fsnotify.Event, 64),
+		errors:   make(chan error, 8),
+		polled:   map[string]map[string]fileStamp{},
+		done:     make(chan struct{}),
+	}
+	w.Events, w.Errors = w.events, w.errors
+	if w.mode != fileWatchPoll {
+		fsw, err := fsnotify.NewWatcher()
+		if err != nil {
+			if w.mode == fileWatchFSNotify {
+				return nil, err
+			}
+			logWarn("⚠️ fsnotify 不可用，改为轮询", "watcher", name, "interval", w.interval, "error", err)
+		} else {
+			w.fsw = fsw
+			w.wg.Add(1)
+			go w.forward()
+		}
+	}
+	w.wg.Add(1)
+	go w.pollLoop()
+	go func() {
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	}()
+	return w, nil
+}
+
+// Add 监听目录；auto 模式下 fsnotify 添加失败（如 inotify 监听数达到上限）时该目录改为轮询
+func (w *fileWatcher) Add(dir string) error {
+	dir = filepath.Clean(dir)
+	if w.fsw != nil {
+		err := w.fsw.Add(dir)
+		if err == nil || w.mode == fileWatchFSNotify {
+			return err
+		}
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return err
+		}
+		logWarn("⚠️ fsnotify 添加目录失败，该目录改为轮询", "watcher", w.name, "dir", dir, "interval", w.interval, "error", err)
+	} else if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.polled[dir] = scanDir(dir)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *fileWatcher) Remove(dir string) error {
+	dir = filepath.Clean(dir)
+	w.mu.Lock()
+	_, polled := w.polled[dir]
+	delete(w.polled, dir)
+	w.mu.Unlock()
+	if polled || w.fsw == nil {
+		return nil
+	}
+	return w.fsw.Remove(dir)
+}
+
+// Close 停止监听，之后 Events 与 Errors 会被关闭
+func (w *fileWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		if w.fsw != nil {
+			err = w.fsw.Close()
+		}
+	})
+	return err
+}
+
+func (w *fileWatcher) forward() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.sendEvent(event)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.sendError(err)
+		}
+	}
+}
+
+func (w *fileWatcher) pollLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			callRecovered("fileWatch:"+w.name, "", w.pollOnce)
+		}
+	}
+}
+
+// pollOnce 对比每个轮询目录的前后两次快照；目录暂时不存在（卷卸载）时其中的文件视为删除
+func (w *fileWatcher) pollOnce() {
+	w.mu.Lock()
+	dirs := make([]string, 0, len(w.polled))
+	for dir := range w.polled {
+		dirs = append(dirs, dir)
+	}
+	w.mu.Unlock()
+	for _, dir := range dirs {
+		cur := scanDir(dir)
+		w.mu.Lock()
+		prev, ok := w.polled[dir]
+		if ok {
+			w.polled[dir] = cur
+		}
+		w.mu.Unlock()
+		if !ok {
+			continue
+		}
+		for name, stamp := range cur {
+			old, existed := prev[name]
+			switch {
+			case !existed:
+				w.sendEvent(fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Create})
+			case old.size != stamp.size || !old.modTime.Equal(stamp.modTime):
+				w.sendEvent(fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Write})
+			}
+		}
+		for name := range prev {
+			if _, exists := cur[name]; !exists {
+				w.sendEvent(fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove})
+			}
+		}
+	}
+}
+
+func (w *fileWatcher) sendEvent(event fsnotify.Event) {
+	select {
+	case w.events <- event:
+	case <-w.done:
+	}
+}
+
+func (w *fileWatcher) sendError(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+// scanDir 目录下文件（不含子目录）的大小与修改时间；读取失败时返回空快照
+func scanDir(dir string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return stamps
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		stamps[e.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	return stamps
+}
@@ -1,6 +1,6 @@
 module meteora_dlmm
 
-go 1.21
+go 1.24
 
 require github.com/fsnotify/fsnotify v1.7.0
 
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 控制面服务的路径前缀（proto/control.proto 中的 meteora.control.v1.ControlPlane）
+const grpcServicePath = "/meteora.control.v1.ControlPlane/"
+
+// gRPC 状态码
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcNotFound           = 5
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnauthenticated    = 16
+)
+
+// GRPCConfig 控制面 gRPC 接口：与 HTTP 管理接口共用同一套操作，另外提供事件与日志的流式订阅
+type GRPCConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Listen       string `json:"listen"`   // 监听地址，例如 127.0.0.1:8089
+	Token        string `json:"token"`    // 非空时要求 authorization: Bearer <token>
+	CertFile     string `json:"certFile"` // 与 keyFile 同时设置时使用 TLS，否则为明文 HTTP/2（h2c）
+	KeyFile      string `json:"keyFile"`
+	StreamBuffer int    `json:"streamBuffer"` // 每个事件流的缓冲条数，客户端读取过慢时丢弃新事件
+}
+
+// grpcError 带状态码的错误
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// grpcMethod 一个 RPC：unary 返回一条响应，stream 通过 send 返回多条
+type grpcMethod struct {
+	unary  func(ctx context.Context, req []byte) ([]byte, error)
+	stream func(ctx context.Context, req []byte, send func([]byte) error) error
+}
+
+// 消息：与 proto/control.proto 中的同名 message 一一对应（去掉 grpc 前缀），字段号只能新增不能复用
+type (
+	grpcGetStatusRequest struct{}
+
+	grpcSetPausedRequest struct {
+		Paused bool `pb:"1,paused"`
+	}
+
+	grpcStatus struct {
+		Instance      string `pb:"1,instance"`
+		Environment   string `pb:"2,environment"`
+		StartedAt     string `pb:"3,started_at"`
+		UptimeSeconds int64  `pb:"4,uptime_seconds"`
+		Mode          string `pb:"5,mode"`
+		Paused        bool   `pb:"6,paused"`
+		Frozen        bool   `pb:"7,frozen"`
+		Profile       string `pb:"8,profile"`
+	}
+
+	grpcListPoolsRequest struct {
+		Source       string `pb:"1,source"`
+		State        string `pb:"2,state"`
+		WithPosition bool   `pb:"3,with_position"`
+	}
+
+	grpcListPoolsResponse struct {
+		Pools []grpcPool `pb:"1,pools"`
+	}
+
+	grpcGetPoolRequest struct {
+		PoolAddress string `pb:"1,pool_address"`
+	}
+
+	grpcPool struct {
+		PoolAddress      string `pb:"1,pool_address"`
+		PoolName         string `pb:"2,pool_name"`
+		CA               string `pb:"3,ca"`
+		PositionAddress  string `pb:"4,position_address"`
+		LastUpdatedFirst string `pb:"5,last_updated_first"`
+		Source           string `pb:"6,source"`
+		Mode             string `pb:"7,mode"`
+		Wallet           string `pb:"8,wallet"`
+		State            string `pb:"9,state"`
+		StateSince       string `pb:"10,state_since"`
+		Compound         bool   `pb:"11,compound"`
+	}
+
+	grpcPoolActionType int32
+
+	grpcPoolActionRequest struct {
+		PoolAddress string             `pb:"1,pool_address"`
+		Action      grpcPoolActionType `pb:"2,action"`
+		Percent     float64            `pb:"3,percent"`
+	}
+
+	grpcPoolActionResponse struct {
+		PoolAddress string `pb:"1,pool_address"`
+		Action      string `pb:"2,action"`
+		Status      string `pb:"3,status"`
+		Mode        string `pb:"4,mode"`
+		Compound    bool   `pb:"5,compound"`
+	}
+
+	grpcStreamEventsRequest struct {
+		Types []string `pb:"1,types"`
+		Pool  string   `pb:"2,pool"`
+	}
+
+	grpcEvent struct {
+		Type       string            `pb:"1,type"`
+		TimeUnixMs int64             `pb:"2,time_unix_ms"`
+		Stage      string            `pb:"3,stage"`
+		Pool       string            `pb:"4,pool"`
+		CA         string            `pb:"5,ca"`
+		Wallet     string            `pb:"6,wallet"`
+		Detail     string            `pb:"7,detail"`
+		Fields     map[string]string `pb:"8,fields"`
+	}
+
+	grpcStreamLogsRequest struct {
+		Since int64 `pb:"1,since"`
+	}
+
+	grpcLogLine struct {
+		Seq  int64  `pb:"1,seq"`
+		Time string `pb:"2,time"`
+		Text string `pb:"3,text"`
+	}
+)
+
+var grpcMethods = map[string]grpcMethod{
+	"GetStatus":    {unary: grpcGetStatus},
+	"SetPaused":    {unary: grpcSetPaused},
+	"ListPools":    {unary: grpcListPools},
+	"GetPool":      {unary: grpcGetPool},
+	"PoolAction":   {unary: grpcPoolAction},
+	"StreamEvents": {stream: grpcStreamEvents},
+	"StreamLogs":   {stream: grpcStreamLogs},
+}
+
+var (
+	grpcStreamMutex sync.Mutex
+	// 正在订阅事件的流
+	grpcEventStreams = map[chan BusEvent]struct{}{}
+)
+
+func (c GRPCConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Listen == "" {
+		return fmt.Errorf("grpc.listen 不能为空")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("grpc.certFile 与 grpc.keyFile 需同时设置")
+	}
+	if c.StreamBuffer <= 0 {
+		return fmt.Errorf("grpc.streamBuffer 必须大于0")
+	}
+	return nil
+}
+
+// startGRPCServer 启动控制面 gRPC 服务（明文时为 h2c，客户端使用 insecure 凭据连接）
+func startGRPCServer() {
+	cfg := currentConfig().GRPC
+	if !cfg.Enabled {
+		return
+	}
+	subscribeEvents("grpc", grpcEventSubscriber)
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           recoverHandler("grpc", http.HandlerFunc(grpcHandler)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	tlsEnabled := cfg.CertFile != ""
+	if !tlsEnabled {
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		server.Protocols = &protocols
+	}
+
+	go func() {
+		<-globalCtx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	logOutput("🛰️ gRPC控制面接口已启动: %s（TLS: %v）\n", cfg.Listen, tlsEnabled)
+	var err error
+	if tlsEnabled {
+		err = server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logOutput("❌ gRPC控制面接口异常退出: %v\n", err)
+	}
+}
+
+func grpcHandler(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC only", http.StatusUnsupportedMediaType)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, grpcServicePath)
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	err := serveGRPC(r, w, name)
+	code, msg := grpcOK, ""
+	if err != nil {
+		code, msg = grpcInternal, err.Error()
+		var ge *grpcError
+		if errors.As(err, &ge) {
+			code = ge.code
+		}
+	}
+	if _, known := grpcMethods[name]; !known {
+		name = "unknown"
+	}
+	metricGRPCRequests.Inc(name, strconv.Itoa(code))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcEncodeMessage(msg))
+	}
+}
+
+func serveGRPC(r *http.Request, w http.ResponseWriter, name string) error {
+	if token := currentConfig().GRPC.Token; token != "" {
+		got := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+			return grpcErrorf(grpcUnauthenticated, "invalid token")
+		}
+	}
+	method, ok := grpcMethods[name]
+	if !ok || !strings.HasPrefix(r.URL.Path, grpcServicePath) {
+		return grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path)
+	}
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "读取请求失败: %v", err)
+	}
+	rc := http.NewResponseController(w)
+	if method.stream != nil {
+		// 流式调用先发出响应头，客户端随即可以开始读取
+		rc.Flush()
+	}
+	send := func(msg []byte) error {
+		if err := writeGRPCMessage(w, msg); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if method.stream != nil {
+		return method.stream(r.Context(), req, send)
+	}
+	resp, err := method.unary(r.Context(), req)
+	if err != nil {
+		return err
+	}
+	return send(resp)
+}
+
+func grpcStatusMessage() []byte {
+	return pbMarshal(grpcStatus{
+		Instance:      deployInstance,
+		Environment:   deployEnvironment,
+		StartedAt:     startedAt.Format(time.RFC3339),
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Mode:          currentConfig().Mode,
+		Paused:        isPaused(),
+		Frozen:        isFrozen(),
+		Profile:       activeProfileName(),
+	})
+}
+
+func grpcGetStatus(ctx context.Context, req []byte) ([]byte, error) {
+	if err := pbUnmarshal(req, &grpcGetStatusRequest{}); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	return grpcStatusMessage(), nil
+}
+
+func grpcSetPaused(ctx context.Context, req []byte) ([]byte, error) {
+	var in grpcSetPausedRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	setPaused(in.Paused)
+	if in.Paused {
+		logOutput("⏸️ 已通过gRPC暂停自动化处理\n")
+	} else {
+		logOutput("▶️ 已通过gRPC恢复自动化处理\n")
+	}
+	return grpcStatusMessage(), nil
+}
+
+func grpcPoolMessage(rec PoolRecord, state *PoolStateRecord) grpcPool {
+	p := grpcPool{
+		PoolAddress:      rec.PoolAddress,
+		PoolName:         rec.PoolName,
+		CA:               rec.TokenAddress,
+		PositionAddress:  rec.PositionAddress,
+		LastUpdatedFirst: rec.LastUpdatedFirst,
+		Source:           rec.Source,
+		Mode:             rec.Mode,
+		Wallet:           rec.Wallet,
+		Compound:         compoundEnabled(rec.PoolAddress),
+	}
+	if state != nil {
+		p.State, p.StateSince = state.State, state.Since
+	}
+	return p
+}
+
+func grpcPoolStates() map[string]*PoolStateRecord {
+	states := map[string]*PoolStateRecord{}
+	for _, s := range listPoolStates("") {
+		states[s.Pool] = s
+	}
+	return states
+}
+
+func grpcListPools(ctx context.Context, req []byte) ([]byte, error) {
+	var in grpcListPoolsRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	if in.State != "" && !slices.Contains(poolStates, in.State) {
+		return nil, grpcErrorf(grpcInvalidArgument, "不支持的池状态: %s", in.State)
+	}
+	states := grpcPoolStates()
+	var out grpcListPoolsResponse
+	for _, rec := range listPoolRecords() {
+		s := states[rec.PoolAddress]
+		if in.Source != "" && rec.Source != in.Source || in.WithPosition && rec.PositionAddress == "" {
+			continue
+		}
+		if in.State != "" && (s == nil || s.State != in.State) {
+			continue
+		}
+		out.Pools = append(out.Pools, grpcPoolMessage(rec, s))
+	}
+	return pbMarshal(out), nil
+}
+
+func grpcGetPool(ctx context.Context, req []byte) ([]byte, error) {
+	var in grpcGetPoolRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	if !poolExists(in.PoolAddress) {
+		return nil, grpcErrorf(grpcNotFound, "pool not found")
+	}
+	for _, rec := range listPoolRecords() {
+		if rec.PoolAddress == in.PoolAddress {
+			return pbMarshal(grpcPoolMessage(rec, grpcPoolStates()[in.PoolAddress])), nil
+		}
+	}
+	return nil, grpcErrorf(grpcNotFound, "pool not found")
+}
+
+// PoolActionType 枚举值 -> performPoolAction 的操作名
+var grpcPoolActions = map[grpcPoolActionType]string{
+	1: "claim",
+	2: "close",
+	3: "withdraw",
+	4: "promote",
+	5: "demote",
+	6: "compound-on",
+	7: "compound-off",
+	8: "panic-close",
+}
+
+func grpcPoolAction(ctx context.Context, req []byte) ([]byte, error) {
+	var in grpcPoolActionRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	action, ok := grpcPoolActions[in.Action]
+	if !ok {
+		return nil, grpcErrorf(grpcInvalidArgument, "不支持的操作: %d", in.Action)
+	}
+	status, result, err := performPoolAction(in.PoolAddress, action, in.Percent)
+	if err != nil {
+		return nil, grpcErrorf(grpcCodeFromHTTP(status), "%v", err)
+	}
+	out := grpcPoolActionResponse{PoolAddress: in.PoolAddress, Action: action, Status: "done"}
+	if s, ok := result["status"].(string); ok {
+		out.Status = s
+	}
+	out.Mode, _ = result["mode"].(string)
+	out.Compound, _ = result["compound"].(bool)
+	return pbMarshal(out), nil
+}
+
+func grpcCodeFromHTTP(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument
+	case http.StatusNotFound:
+		return grpcNotFound
+	case http.StatusConflict:
+		return grpcFailedPrecondition
+	}
+	return grpcInternal
+}
+
+// grpcEventSubscriber 把总线事件分发给正在订阅的流；流的缓冲已满时丢弃
+func grpcEventSubscriber(e BusEvent) {
+	grpcStreamMutex.Lock()
+	defer grpcStreamMutex.Unlock()
+	for ch := range grpcEventStreams {
+		select {
+		case ch <- e:
+		default:
+			metricGRPCEventsDropped.Inc()
+		}
+	}
+}
+
+func grpcStreamEvents(ctx context.Context, req []byte, send func([]byte) error) error {
+	var in grpcStreamEventsRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	for _, t := range in.Types {
+		if !slices.Contains(busEventTypes, t) {
+			return grpcErrorf(grpcInvalidArgument, "不支持的事件类型: %s（可选 %s）", t, strings.Join(busEventTypes, "、"))
+		}
+	}
+	if !currentConfig().EventBus.Enabled {
+		return grpcErrorf(grpcFailedPrecondition, "eventBus 未启用")
+	}
+
+	ch := make(chan BusEvent, currentConfig().GRPC.StreamBuffer)
+	grpcStreamMutex.Lock()
+	grpcEventStreams[ch] = struct{}{}
+	grpcStreamMutex.Unlock()
+	defer func() {
+		grpcStreamMutex.Lock()
+		delete(grpcEventStreams, ch)
+		grpcStreamMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-globalCtx.Done():
+			return nil
+		case ev := <-ch:
+			if len(in.Types) > 0 && !slices.Contains(in.Types, ev.Type) || in.Pool != "" && ev.Pool != in.Pool {
+				continue
+			}
+			msg := grpcEvent{
+				Type: ev.Type, TimeUnixMs: ev.Time.UnixMilli(), Stage: ev.Stage, Pool: ev.Pool,
+				CA: ev.Token, Wallet: ev.Wallet, Detail: ev.Detail, Fields: ev.Fields,
+			}
+			if err := send(pbMarshal(msg)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func grpcStreamLogs(ctx context.Context, req []byte, send func([]byte) error) error {
+	var in grpcStreamLogsRequest
+	if err := pbUnmarshal(req, &in); err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	since := in.Since
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		for _, l := range logRing.since(since, logRingSize) {
+			if err := send(pbMarshal(grpcLogLine{Seq: l.Seq, Time: l.Time, Text: l.Text})); err != nil {
+				return err
+			}
+			since = l.Seq
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-globalCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
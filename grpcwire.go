@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// protobuf 线格式类型
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+// gRPC 单条消息上限
+const grpcMaxMessageBytes = 4 << 20
+
+// pbEncoder 最小 protobuf 编码器：按 proto3 语义省略零值字段
+type pbEncoder struct {
+	buf []byte
+}
+
+func (e *pbEncoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *pbEncoder) String(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.tag(field, pbBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *pbEncoder) Bool(field int, v bool) {
+	if v {
+		e.Int64(field, 1)
+	}
+}
+
+func (e *pbEncoder) Int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, pbVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *pbEncoder) Double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, pbFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// Message 嵌套消息（repeated 字段逐个调用）；空消息也会写出
+func (e *pbEncoder) Message(field int, msg []byte) {
+	e.tag(field, pbBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(msg)))
+	e.buf = append(e.buf, msg...)
+}
+
+// StringMap map<string, string>，按键排序写出
+func (e *pbEncoder) StringMap(field int, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry pbEncoder
+		entry.String(1, k)
+		entry.String(2, m[k])
+		e.Message(field, entry.buf)
+	}
+}
+
+// 消息结构体的字段以 pb 标签声明字段号与 proto 字段名（如 `pb:"1,pool_address"`），与 proto/control.proto 一一对应，
+// 由 pbMarshal / pbUnmarshal 按标签编解码；grpcwire_test.go 核对每个消息的字段号、名称与类型并逐个往返编解码。
+// 支持的类型：string、bool、int64、double、枚举（int32）、嵌套消息、repeated（string 与消息）与 map<string, string>
+
+// pbTag 解析字段的 pb 标签，返回字段号与 proto 字段名
+func pbTag(f reflect.StructField) (int, string, bool) {
+	tag, ok := f.Tag.Lookup("pb")
+	if !ok {
+		return 0, "", false
+	}
+	numStr, name, _ := strings.Cut(tag, ",")
+	num, err := strconv.Atoi(numStr)
+	if err != nil || num <= 0 {
+		return 0, "", false
+	}
+	return num, name, true
+}
+
+// pbMarshal 按 pb 标签编码消息结构体（proto3 语义：省略零值的标量字段）
+func pbMarshal(msg any) []byte {
+	return pbMarshalValue(reflect.Indirect(reflect.ValueOf(msg)))
+}
+
+func pbMarshalValue(v reflect.Value) []byte {
+	var e pbEncoder
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		num, _, ok := pbTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				// repeated 的元素即使为零值也要写出
+				if el := fv.Index(j); el.Kind() == reflect.String {
+					e.tag(num, pbBytes)
+					e.buf = binary.AppendUvarint(e.buf, uint64(el.Len()))
+					e.buf = append(e.buf, el.String()...)
+				} else {
+					e.Message(num, pbMarshalValue(el))
+				}
+			}
+		case reflect.Map:
+			e.StringMap(num, fv.Interface().(map[string]string))
+		case reflect.Struct:
+			e.Message(num, pbMarshalValue(fv))
+		case reflect.String:
+			e.String(num, fv.String())
+		case reflect.Bool:
+			e.Bool(num, fv.Bool())
+		case reflect.Int32, reflect.Int64:
+			e.Int64(num, fv.Int())
+		case reflect.Float64:
+			e.Double(num, fv.Float())
+		}
+	}
+	return e.buf
+}
+
+// pbUnmarshal 按 pb 标签解码到消息结构体；未知字段忽略，已知字段的线格式类型不符时返回错误
+func pbUnmarshal(b []byte, msg any) error {
+	return pbUnmarshalValue(b, reflect.ValueOf(msg).Elem())
+}
+
+func pbUnmarshalValue(b []byte, v reflect.Value) error {
+	t := v.Type()
+	fields := map[int]int{} // 字段号 -> 结构体字段下标
+	for i := 0; i < t.NumField(); i++ {
+		if num, _, ok := pbTag(t.Field(i)); ok {
+			fields[num] = i
+		}
+	}
+	var fieldErr error
+	err := decodeProto(b, func(f pbField) {
+		i, ok := fields[f.Num]
+		if !ok || fieldErr != nil {
+			return
+		}
+		if err := pbSetField(v.Field(i), f); err != nil {
+			fieldErr = fmt.Errorf("protobuf: %s: %v", t.Field(i).Name, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return fieldErr
+}
+
+// pbWireType 字段类型对应的线格式类型
+func pbWireType(k reflect.Kind) int {
+	switch k {
+	case reflect.Bool, reflect.Int32, reflect.Int64:
+		return pbVarint
+	case reflect.Float64:
+		return pbFixed64
+	}
+	return pbBytes
+}
+
+func pbSetField(v reflect.Value, f pbField) error {
+	kind := v.Kind()
+	if kind == reflect.Slice {
+		kind = v.Type().Elem().Kind()
+	}
+	if want := pbWireType(kind); f.WireType != want {
+		return fmt.Errorf("线格式类型 %d，应为 %d", f.WireType, want)
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		el := reflect.New(v.Type().Elem()).Elem()
+		if err := pbSetField(el, f); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, el))
+	case reflect.Map:
+		var key, value string
+		if err := decodeProto(f.Bytes, func(entry pbField) {
+			switch entry.Num {
+			case 1:
+				key = entry.String()
+			case 2:
+				value = entry.String()
+			}
+		}); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+	case reflect.Struct:
+		return pbUnmarshalValue(f.Bytes, v)
+	case reflect.String:
+		v.SetString(f.String())
+	case reflect.Bool:
+		v.SetBool(f.Bool())
+	case reflect.Int32, reflect.Int64:
+		v.SetInt(f.Int64())
+	case reflect.Float64:
+		v.SetFloat(f.Double())
+	}
+	return nil
+}
+
+// pbField 解码出的一个字段
+type pbField struct {
+	Num      int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+func (f pbField) String() string { return string(f.Bytes) }
+func (f pbField) Bool() bool     { return f.Varint != 0 }
+func (f pbField) Int64() int64   { return int64(f.Varint) }
+func (f pbField) Double() float64 {
+	if f.WireType != pbFixed64 {
+		return 0
+	}
+	return math.Float64frombits(f.Varint)
+}
+
+// decodeProto 逐个字段回调；未知字段由回调忽略即可
+func decodeProto(b []byte, fn func(pbField)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("protobuf: 字段标签无效")
+		}
+		b = b[n:]
+		f := pbField{Num: int(key >> 3), WireType: int(key & 7)}
+		switch f.WireType {
+		case pbVarint:
+			if f.Varint, n = binary.Uvarint(b); n <= 0 {
+				return errors.New("protobuf: varint 无效")
+			}
+			b = b[n:]
+		case pbFixed64:
+			if len(b) < 8 {
+				return errors.New("protobuf: fixed64 长度不足")
+			}
+			f.Varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case pbFixed32:
+			if len(b) < 4 {
+				return errors.New("protobuf: fixed32 长度不足")
+			}
+			f.Varint = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case pbBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errors.New("protobuf: 长度前缀无效")
+			}
+			f.Bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			return fmt.Errorf("protobuf: 不支持的线格式类型 %d", f.WireType)
+		}
+		fn(f)
+	}
+	return nil
+}
+
+// readGRPCMessage 读取一条带 5 字节前缀（压缩标志 + 长度）的 gRPC 消息
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("不支持压缩的消息")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessageBytes {
+		return nil, fmt.Errorf("消息过大: %d 字节", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w io.Writer, msg []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// grpcEncodeMessage grpc-message 头的百分号编码（可打印 ASCII 以外的字节与 % 需要编码）
+func grpcEncodeMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// proto/control.proto 中 message 对应的结构体；新增 message 时在这里登记
+var grpcProtoMessages = map[string]any{
+	"GetStatusRequest":    grpcGetStatusRequest{},
+	"SetPausedRequest":    grpcSetPausedRequest{},
+	"Status":              grpcStatus{},
+	"ListPoolsRequest":    grpcListPoolsRequest{},
+	"ListPoolsResponse":   grpcListPoolsResponse{},
+	"GetPoolRequest":      grpcGetPoolRequest{},
+	"Pool":                grpcPool{},
+	"PoolActionRequest":   grpcPoolActionRequest{},
+	"PoolActionResponse":  grpcPoolActionResponse{},
+	"StreamEventsRequest": grpcStreamEventsRequest{},
+	"Event":               grpcEvent{},
+	"StreamLogsRequest":   grpcStreamLogsRequest{},
+	"LogLine":             grpcLogLine{},
+}
+
+// protoField proto 文件中声明的一个字段
+type protoField struct {
+	num      int
+	name     string
+	typ      string // 标量类型、enum 或 message 名；map 为 map<K,V>
+	repeated bool
+}
+
+// protoFile 解析后的 proto 文件（只支持 control.proto 用到的语法：无嵌套、无 oneof）
+type protoFile struct {
+	messages map[string][]protoField
+	enums    map[string]map[string]int
+	rpcs     map[string]bool // 方法 -> 是否为服务端流
+}
+
+var (
+	protoBlockRe = regexp.MustCompile(`(?ms)^(message|enum) (\w+) \{(?:\}|(.*?)^\})`)
+	protoFieldRe = regexp.MustCompile(`^\s*(repeated\s+)?(map<\s*\w+\s*,\s*\w+\s*>|\w+)\s+(\w+)\s*=\s*(\d+)\s*;`)
+	protoEnumRe  = regexp.MustCompile(`^\s*(\w+)\s*=\s*(\d+)\s*;`)
+	protoRPCRe   = regexp.MustCompile(`rpc (\w+)\((\w+)\) returns \((stream )?(\w+)\);`)
+)
+
+func parseControlProto(t *testing.T) protoFile {
+	t.Helper()
+	content, err := os.ReadFile("proto/control.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(content)
+	pf := protoFile{messages: map[string][]protoField{}, enums: map[string]map[string]int{}, rpcs: map[string]bool{}}
+	for _, m := range protoBlockRe.FindAllStringSubmatch(src, -1) {
+		kind, name, body := m[1], m[2], m[3]
+		if kind == "enum" {
+			pf.enums[name] = map[string]int{}
+		} else {
+			pf.messages[name] = []protoField{}
+		}
+		for _, line := range strings.Split(body, "\n") {
+			line, _, _ = strings.Cut(line, "//")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if kind == "enum" {
+				v := protoEnumRe.FindStringSubmatch(line)
+				if v == nil {
+					t.Fatalf("无法解析 enum %s 的行: %q", name, line)
+				}
+				n, _ := strconv.Atoi(v[2])
+				pf.enums[name][v[1]] = n
+				continue
+			}
+			f := protoFieldRe.FindStringSubmatch(line)
+			if f == nil {
+				t.Fatalf("无法解析 message %s 的行: %q", name, line)
+			}
+			n, _ := strconv.Atoi(f[4])
+			typ := strings.Join(strings.Fields(f[2]), "")
+			pf.messages[name] = append(pf.messages[name], protoField{num: n, name: f[3], typ: typ, repeated: f[1] != ""})
+		}
+	}
+	for _, r := range protoRPCRe.FindAllStringSubmatch(src, -1) {
+		pf.rpcs[r[1]] = r[3] != ""
+		for _, msg := range []string{r[2], r[4]} {
+			if _, ok := pf.messages[msg]; !ok {
+				t.Fatalf("rpc %s 使用了未声明的 message %s", r[1], msg)
+			}
+		}
+	}
+	if len(pf.messages) == 0 || len(pf.rpcs) == 0 {
+		t.Fatal("proto/control.proto 中没有解析到 message 或 rpc")
+	}
+	return pf
+}
+
+// expectedGoType proto 字段类型对应的 Go 类型
+func expectedGoType(t *testing.T, pf protoFile, typ string) reflect.Type {
+	switch typ {
+	case "string":
+		return reflect.TypeOf("")
+	case "bool":
+		return reflect.TypeOf(false)
+	case "int64":
+		return reflect.TypeOf(int64(0))
+	case "double":
+		return reflect.TypeOf(float64(0))
+	case "map<string,string>":
+		return reflect.TypeOf(map[string]string{})
+	}
+	if _, ok := pf.enums[typ]; ok {
+		return nil // 枚举：任意 int32 命名类型
+	}
+	if msg, ok := grpcProtoMessages[typ]; ok {
+		return reflect.TypeOf(msg)
+	}
+	t.Fatalf("不支持的 proto 类型 %s（需要在 grpcwire.go 中实现编解码）", typ)
+	return nil
+}
+
+func TestGRPCMessagesMatchProto(t *testing.T) {
+	pf := parseControlProto(t)
+	for name := range grpcProtoMessages {
+		if _, ok := pf.messages[name]; !ok {
+			t.Errorf("结构体登记了 proto 中不存在的 message %s", name)
+		}
+	}
+	for name, fields := range pf.messages {
+		msg, ok := grpcProtoMessages[name]
+		if !ok {
+			t.Errorf("message %s 没有对应的结构体", name)
+			continue
+		}
+		goFields := map[int]reflect.StructField{}
+		rt := reflect.TypeOf(msg)
+		for i := 0; i < rt.NumField(); i++ {
+			if num, _, ok := pbTag(rt.Field(i)); ok {
+				if _, dup := goFields[num]; dup {
+					t.Errorf("%s: 字段号 %d 重复", name, num)
+				}
+				goFields[num] = rt.Field(i)
+			}
+		}
+		if len(goFields) != len(fields) {
+			t.Errorf("%s: proto 有 %d 个字段，结构体有 %d 个", name, len(fields), len(goFields))
+		}
+		for _, f := range fields {
+			sf, ok := goFields[f.num]
+			if !ok {
+				t.Errorf("%s.%s: 结构体缺少字段号 %d", name, f.name, f.num)
+				continue
+			}
+			if _, tagName, _ := pbTag(sf); tagName != f.name {
+				t.Errorf("%s: 字段号 %d 在 proto 中为 %s，结构体标签为 %s", name, f.num, f.name, tagName)
+			}
+			got := sf.Type
+			if f.repeated {
+				if got.Kind() != reflect.Slice {
+					t.Errorf("%s.%s: repeated 字段应为切片，实际为 %s", name, f.name, got)
+					continue
+				}
+				got = got.Elem()
+			}
+			want := expectedGoType(t, pf, f.typ)
+			if want == nil {
+				if got.Kind() != reflect.Int32 {
+					t.Errorf("%s.%s: 枚举 %s 应为 int32 类型，实际为 %s", name, f.name, f.typ, got)
+				}
+				continue
+			}
+			if got != want {
+				t.Errorf("%s.%s: proto 类型 %s 应为 %s，实际为 %s", name, f.name, f.typ, want, got)
+			}
+		}
+	}
+}
+
+// sampleValue 为每个字段填入非零值（repeated 含一个零值元素，检查元素不会被省略）
+func sampleValue(v reflect.Value, seed int) {
+	for i := 0; i < v.NumField(); i++ {
+		if _, _, ok := pbTag(v.Type().Field(i)); !ok {
+			continue
+		}
+		fv := v.Field(i)
+		n := seed*100 + i + 1
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString("值-" + strconv.Itoa(n))
+		case reflect.Bool:
+			fv.SetBool(true)
+		case reflect.Int32:
+			fv.SetInt(int64(n))
+		case reflect.Int64:
+			fv.SetInt(-int64(n) * 1e9)
+		case reflect.Float64:
+			fv.SetFloat(float64(n) + 0.25)
+		case reflect.Map:
+			fv.Set(reflect.ValueOf(map[string]string{"a": strconv.Itoa(n), "b": "", "": "c"}))
+		case reflect.Struct:
+			sampleValue(fv, seed+1)
+		case reflect.Slice:
+			el := reflect.New(fv.Type().Elem()).Elem()
+			if el.Kind() == reflect.Struct {
+				sampleValue(el, seed+1)
+			} else {
+				el.SetString("元素-" + strconv.Itoa(n))
+			}
+			fv.Set(reflect.Append(reflect.Append(fv, el), reflect.New(fv.Type().Elem()).Elem()))
+		}
+	}
+}
+
+func TestGRPCMessagesRoundTrip(t *testing.T) {
+	names := make([]string, 0, len(grpcProtoMessages))
+	for name := range grpcProtoMessages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ := reflect.TypeOf(grpcProtoMessages[name])
+		for _, fill := range []bool{false, true} {
+			in := reflect.New(typ)
+			if fill {
+				sampleValue(in.Elem(), 0)
+			}
+			b := pbMarshal(in.Interface())
+			out := reflect.New(typ)
+			if err := pbUnmarshal(b, out.Interface()); err != nil {
+				t.Errorf("%s: 解码失败: %v", name, err)
+				continue
+			}
+			if !reflect.DeepEqual(in.Elem().Interface(), out.Elem().Interface()) {
+				t.Errorf("%s: 往返编解码不一致\n编码前 %+v\n解码后 %+v", name, in.Elem().Interface(), out.Elem().Interface())
+			}
+			if !fill && len(b) != 0 {
+				t.Errorf("%s: 零值消息应编码为空，实际 %d 字节", name, len(b))
+			}
+		}
+	}
+}
+
+func TestGRPCServiceMatchesProto(t *testing.T) {
+	pf := parseControlProto(t)
+	for name, stream := range pf.rpcs {
+		m, ok := grpcMethods[name]
+		if !ok {
+			t.Errorf("rpc %s 没有实现", name)
+			continue
+		}
+		if stream != (m.stream != nil) {
+			t.Errorf("rpc %s: proto 中 stream=%v 与实现不一致", name, stream)
+		}
+	}
+	for name := range grpcMethods {
+		if _, ok := pf.rpcs[name]; !ok {
+			t.Errorf("实现了 proto 中不存在的 rpc %s", name)
+		}
+	}
+	// PoolActionType 的每个取值（UNSPECIFIED 除外）都有对应的操作名：POOL_ACTION_PANIC_CLOSE -> panic-close
+	values := pf.enums["PoolActionType"]
+	if len(values) == 0 {
+		t.Fatal("proto 中没有 PoolActionType")
+	}
+	for name, v := range values {
+		if v == 0 {
+			continue
+		}
+		want := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, "POOL_ACTION_")), "_", "-")
+		if got := grpcPoolActions[grpcPoolActionType(v)]; got != want {
+			t.Errorf("PoolActionType %s = %d: 对应操作 %q，应为 %q", name, v, got, want)
+		}
+	}
+	if len(grpcPoolActions) != len(values)-1 {
+		t.Errorf("grpcPoolActions 有 %d 项，proto 中有 %d 个非零取值", len(grpcPoolActions), len(values)-1)
+	}
+}
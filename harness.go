@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 集成测试夹具：在临时目录中以演示模式启动完整的监听流程，写入合成 CSV 行，外部脚本改为回放录制的输出（不需要 Solana 与 Node），
+// 等待每个池走完 CSV → 池文件 → 开仓 → 领取 → 兑换后关闭并返回报告。可由 harness 子命令运行，也可在 go test 中直接调用 runHarness
+
+// 流程各阶段
+const (
+	harnessStageJSON  = "json"  // CSV 行写出池文件
+	harnessStageAdd   = "add"   // 开仓（池文件写入仓位地址）
+	harnessStageClaim = "claim" // 盈亏台账中有领取记录
+	harnessStageSwap  = "swap"  // 兑换记录中有该代币
+)
+
+var harnessStages = []string{harnessStageJSON, harnessStageAdd, harnessStageClaim, harnessStageSwap}
+
+// 录制输出中的占位符，回放时替换为本次调用的值
+const (
+	fixturePool      = "{{pool}}"
+	fixtureToken     = "{{token}}"
+	fixtureSignature = "{{signature}}"
+)
+
+// HarnessOptions 一次集成测试的参数
+type HarnessOptions struct {
+	Rows     int           // 写入的 CSV 行（新池）数
+	Fixtures string        // 录制输出目录（<target>.json），为空时使用内置输出
+	Timeout  time.Duration // 等待所有池走完流程的时长
+	Keep     bool          // 保留临时目录（便于排查）
+
+	InjectFailures float64       // 故障注入：外部命令失败的概率（见 -inject-failures）
+	InjectLatency  time.Duration // 故障注入：外部命令执行前的随机延迟上限
+}
+
+// HarnessPool 一个池走到的阶段
+type HarnessPool struct {
+	Pool   string          `json:"pool"`
+	Token  string          `json:"token"`
+	Stages map[string]bool `json:"stages"`
+}
+
+// HarnessReport 集成测试结果
+type HarnessReport struct {
+	Dir     string           `json:"dir"`
+	Passed  bool             `json:"passed"`
+	Took    string           `json:"took"`
+	Pools   []*HarnessPool   `json:"pools"`
+	Calls   map[string]int64 `json:"calls"` // 各外部命令的回放次数
+	Missing []string         `json:"missing,omitempty"`
+}
+
+// FixtureCall 一次外部命令的录制输出
+type FixtureCall struct {
+	Args     []string `json:"args,omitempty"` // 录制时的参数（仅供查阅）
+	ExitCode int      `json:"exitCode"`
+	Output   string   `json:"output"`
+}
+
+// fixtureSet 按目标依次回放录制输出，用完后重复最后一条
+type fixtureSet struct {
+	mu    sync.Mutex
+	calls map[string][]FixtureCall
+	next  map[string]int
+}
+
+var (
+	// 集成测试运行中时非空：演示模式的外部命令改为回放录制输出，且不启动合成信号生成
+	harnessFixtures *fixtureSet
+
+	// 由 run -record-fixtures 开启：真实执行的外部命令输出追加到该目录，供集成测试回放
+	fixtureRecordDir   string
+	fixtureRecordMutex sync.Mutex
+)
+
+func isHarness() bool { return harnessFixtures != nil }
+
+// defaultFixtures 内置的录制输出：与各脚本成功时的结构化事件一致
+func defaultFixtures() map[string][]FixtureCall {
+	events := func(evs ...ScriptEvent) []FixtureCall {
+		var out strings.Builder
+		for _, ev := range append(evs, ScriptEvent{Type: scriptEventStatus, Status: "ok"}) {
+			out.WriteString(demoEvent(ev))
+		}
+		return []FixtureCall{{Output: out.String()}}
+	}
+	return map[string][]FixtureCall{
+		scriptAddLiquidity: events(ScriptEvent{Type: scriptEventSignature, Signature: fixtureSignature, Action: "addLiquidity"}),
+		scriptFetchPrice:   events(ScriptEvent{Type: scriptEventPrice, Price: "0.00123", Source: priceSourceOKX}),
+		scriptClaimAllRewards: events(
+			ScriptEvent{Type: scriptEventValue, Key: "pendingFeesUSD", Value: 2.5},
+			ScriptEvent{Type: scriptEventClaimed, Token: fixtureToken, Amount: "2.5"},
+			ScriptEvent{Type: scriptEventValue, Key: "feeSOL", Value: 0.000005},
+			ScriptEvent{Type: scriptEventValue, Key: "claimedUSD", Value: 2.5},
+			ScriptEvent{Type: scriptEventValue, Key: "positionValueUSD", Value: 15},
+			ScriptEvent{Type: scriptEventValue, Key: "solUSD", Value: 150},
+		),
+		scriptRemoveLiquidity:        events(ScriptEvent{Type: scriptEventSignature, Signature: fixtureSignature, Action: "removeLiquidity"}),
+		scriptRemoveLiquidityPartial: events(ScriptEvent{Type: scriptEventSignature, Signature: fixtureSignature, Action: "removeLiquidity"}),
+		scriptCreateTokenAccounts:    events(),
+		scriptJupSwapBalances:        events(ScriptEvent{Type: scriptEventToken, Token: fixtureToken, Balance: "1000000"}),
+		scriptJupSwap: events(
+			ScriptEvent{Type: scriptEventValue, Key: "proceeds", Value: 0.005},
+			ScriptEvent{Type: scriptEventValue, Key: "feeSOL", Value: 0.000005},
+		),
+	}
+}
+
+// loadFixtures 读取录制输出目录；目录中没有的目标使用内置输出
+func loadFixtures(dir string) (*fixtureSet, error) {
+	set := &fixtureSet{calls: defaultFixtures(), next: map[string]int{}}
+	if dir == "" {
+		return set, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var calls []FixtureCall
+		if err := json.Unmarshal(content, &calls); err != nil {
+			return nil, fmt.Errorf("解析录制输出 %s 失败: %v", path, err)
+		}
+		if len(calls) > 0 {
+			set.calls[strings.TrimSuffix(filepath.Base(path), ".json")] = calls
+		}
+	}
+	return set, nil
+}
+
+func (s *fixtureSet) take(target string) (FixtureCall, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := s.calls[target]
+	if len(calls) == 0 {
+		return FixtureCall{}, false
+	}
+	i := s.next[target]
+	if i < len(calls)-1 {
+		s.next[target] = i + 1
+	}
+	return calls[i], true
+}
+
+// fixtureExternal 代替外部命令回放录制输出，并模拟脚本的副作用：开仓写回仓位地址、领取后钱包持有代币、兑换后清空
+func fixtureExternal(ctx context.Context, target string, args []string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	call, ok := harnessFixtures.take(target)
+	if !ok {
+		return nil, fmt.Errorf("没有 %s 的录制输出", target)
+	}
+	demo.mu.Lock()
+	demo.calls[target]++
+	holdings := make([]string, 0, len(demo.holdings))
+	for token := range demo.holdings {
+		holdings = append(holdings, token)
+	}
+	demo.mu.Unlock()
+	sort.Strings(holdings)
+
+	pool := argValue(args, "--pool")
+	token := argValue(args, "--token")
+	if token == "" && pool != "" {
+		token = readTokenContractAddressFromPoolJSON(pool)
+	}
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(call.Output, "\n") {
+		// 没有代币参数的命令（查询钱包持仓）按当前持有的代币逐个展开
+		tokens := []string{token}
+		if token == "" && strings.Contains(line, fixtureToken) {
+			tokens = holdings
+		}
+		for _, t := range tokens {
+			out.WriteString(strings.NewReplacer(fixturePool, pool, fixtureToken, t, fixtureSignature, demoAddress()+demoAddress()).Replace(line))
+		}
+	}
+	if call.ExitCode != 0 {
+		return []byte(out.String()), fmt.Errorf("exit status %d", call.ExitCode)
+	}
+
+	switch target {
+	case scriptAddLiquidity:
+		if err := demoWritePosition(pool, argValue(args, "--leg")); err != nil {
+			return []byte(out.String()), err
+		}
+	case scriptClaimAllRewards:
+		for _, ev := range decodeScriptOutput([]byte(out.String())).Events {
+			if ev.Type == scriptEventClaimed && ev.Token != "" {
+				demo.mu.Lock()
+				demo.holdings[ev.Token] = true
+				demo.mu.Unlock()
+			}
+		}
+	case scriptJupSwap:
+		demo.mu.Lock()
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "-input" {
+				delete(demo.holdings, args[i+1])
+			}
+		}
+		demo.mu.Unlock()
+	}
+	return []byte(out.String()), nil
+}
+
+// recordFixture 追加一次真实执行的输出（run -record-fixtures），池、代币地址与交易签名替换为占位符
+func recordFixture(target string, args []string, out []byte, runErr error) {
+	call := FixtureCall{Args: args, Output: string(out)}
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		call.ExitCode = exitErr.ExitCode()
+	} else if runErr != nil {
+		return
+	}
+	pool := argValue(args, "--pool")
+	token := argValue(args, "--token")
+	if token == "" && pool != "" {
+		token = readTokenContractAddressFromPoolJSON(pool)
+	}
+	call.Output = signaturePattern.ReplaceAllString(call.Output, fixtureSignature)
+	for value, placeholder := range map[string]string{pool: fixturePool, token: fixtureToken} {
+		if value != "" {
+			call.Output = strings.ReplaceAll(call.Output, value, placeholder)
+		}
+	}
+
+	fixtureRecordMutex.Lock()
+	defer fixtureRecordMutex.Unlock()
+	path := filepath.Join(fixtureRecordDir, target+".json")
+	var calls []FixtureCall
+	if content, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(content, &calls)
+	}
+	content, err := json.MarshalIndent(append(calls, call), "", "  ")
+	if err == nil {
+		err = os.MkdirAll(fixtureRecordDir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(path, content, 0644)
+	}
+	if err != nil {
+		logWarn("⚠️ 保存录制输出失败", "target", target, "error", err)
+	}
+}
+
+// harnessConfig 集成测试的配置：各定时任务每隔几秒执行一次，脚本无延迟、不失败
+const harnessConfig = `{
+  "schedules": {
+    "price": {"cron": "*/2 * * * * *"},
+    "claim": {"cron": "*/2 * * * * *"},
+    "swap": {"cron": "1-59/2 * * * * *"}
+  },
+  "demo": {"scriptLatencyMs": 0, "failureRate": 0}
+}
+`
+
+// resetHarnessState 还原上一次运行留下的进程级状态（配置、任务队列、定时任务、事件总线订阅者、告警后端与演示模拟状态），
+// 使同一进程（如 go test）可以多次调用 runHarness
+func resetHarnessState() {
+	setConfig(defaultConfig())
+
+	jobQueueMutex.Lock()
+	jobQueue = nil
+	jobQueueKeys = map[string]*QueuedJob{}
+	jobRunning = map[*QueuedJob]bool{}
+	jobRunningTypes = map[string]int{}
+	jobQueueStopped = false
+	jobQueueMutex.Unlock()
+
+	schedulerMutex.Lock()
+	schedulerJobs = map[string]*scheduledJob{}
+	schedulerMutex.Unlock()
+
+	busMutex.Lock()
+	busSubscribers = nil
+	busMutex.Unlock()
+
+	setNotifiers(nil)
+	demo = newDemoSim()
+}
+
+// runHarness 运行一次集成测试；开始前还原上一次运行的全局状态，结束后恢复程序目录与数据目录，可在同一进程中多次调用（不能并发）
+func runHarness(opts HarnessOptions) (*HarnessReport, error) {
+	if opts.Rows <= 0 {
+		opts.Rows = 3
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	fixtures, err := loadFixtures(opts.Fixtures)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "meteora-harness-")
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Keep {
+		defer os.RemoveAll(dir)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(harnessConfig), 0644); err != nil {
+		return nil, err
+	}
+
+	// 目录与其环境变量在运行中指向临时目录，结束后恢复
+	baseDir, dataDir := appBaseDir, appDataDir
+	envBase, hasEnvBase := os.LookupEnv(envBaseDir)
+	envData, hasEnvData := os.LookupEnv(envDataDir)
+	defer func() {
+		harnessFixtures = nil
+		appBaseDir, appDataDir = baseDir, dataDir
+		restoreEnv(envBaseDir, envBase, hasEnvBase)
+		restoreEnv(envDataDir, envData, hasEnvData)
+	}()
+
+	resetHarnessState()
+	harnessFixtures = fixtures
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runDaemon([]string{"-demo", "-config", configPath, "-base-dir", dir, "-data-dir", filepath.Join(dir, "data"),
+			"-inject-failures", strconv.FormatFloat(opts.InjectFailures, 'g', -1, 64), "-inject-latency", opts.InjectLatency.String()})
+	}()
+	deadline := time.After(opts.Timeout)
+	stop := func() {
+		if globalCancel != nil {
+			globalCancel()
+		}
+		<-done
+	}
+
+	// 等待监听启动后写入信号
+	for !watcherRunning.Load() {
+		select {
+		case <-done:
+			return nil, fmt.Errorf("流程未能启动")
+		case <-deadline:
+			stop()
+			return nil, fmt.Errorf("等待监听启动超时")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	report := &HarnessReport{Dir: dir}
+	for i := 0; i < opts.Rows; i++ {
+		pool, token, err := appendDemoRow()
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		report.Pools = append(report.Pools, &HarnessPool{Pool: pool, Token: token, Stages: map[string]bool{}})
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for !report.check() {
+		select {
+		case <-deadline:
+			break wait
+		case <-ticker.C:
+		}
+	}
+	stop()
+
+	report.Took = time.Since(start).Round(time.Millisecond).String()
+	report.Passed = len(report.Missing) == 0
+	report.Calls = map[string]int64{}
+	demo.mu.Lock()
+	for target, n := range demo.calls {
+		report.Calls[target] = n
+	}
+	demo.mu.Unlock()
+	return report, nil
+}
+
+func restoreEnv(key, value string, ok bool) {
+	if ok {
+		os.Setenv(key, value)
+	} else {
+		os.Unsetenv(key)
+	}
+}
+
+// check 更新各池到达的阶段，返回是否全部走完
+func (r *HarnessReport) check() bool {
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	swapped := map[string]bool{}
+	for _, s := range loadHistory[SwapRecord]("swap_history") {
+		swapped[s.Token] = true
+	}
+	r.Missing = nil
+	for _, p := range r.Pools {
+		if _, err := os.Stat(filepath.Join(poolDataDir(), p.Pool+".json")); err == nil {
+			p.Stages[harnessStageJSON] = true
+			if readPositionFromPoolJSON(p.Pool) != "" {
+				p.Stages[harnessStageAdd] = true
+			}
+		}
+		if pp, ok := ledger[p.Pool]; ok {
+			for _, e := range pp.Entries {
+				if e.Kind == pnlClaim {
+					p.Stages[harnessStageClaim] = true
+				}
+			}
+		}
+		if swapped[p.Token] {
+			p.Stages[harnessStageSwap] = true
+		}
+		for _, stage := range harnessStages {
+			if !p.Stages[stage] {
+				r.Missing = append(r.Missing, shortAddress(p.Pool)+":"+stage)
+			}
+		}
+	}
+	return len(r.Missing) == 0
+}
+
+func cmdHarness(args []string) {
+	fs := flag.NewFlagSet("harness", flag.ExitOnError)
+	rows := fs.Int("rows", 3, "写入的 CSV 行（新池）数")
+	fixtures := fs.String("fixtures", "", "录制输出目录（<target>.json，由 run -record-fixtures 生成），为空时使用内置输出")
+	timeout := fs.Duration("timeout", 2*time.Minute, "等待所有池走完流程的时长")
+	keep := fs.Bool("keep", false, "保留临时目录")
+	jsonOut := fs.Bool("json", false, "输出 JSON 报告")
+	injectFailures := fs.Float64("inject-failures", 0, "故障注入：回放的外部命令随机失败的概率（0~1），验证重试与熔断后仍能走完流程")
+	injectLatency := fs.Duration("inject-latency", 0, "故障注入：回放的外部命令执行前随机延迟 0~该时长")
+	fs.Parse(args)
+
+	report, err := runHarness(HarnessOptions{Rows: *rows, Fixtures: *fixtures, Timeout: *timeout, Keep: *keep, InjectFailures: *injectFailures, InjectLatency: *injectLatency})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "集成测试失败: %v\n", err)
+		os.Exit(1)
+	}
+	if *jsonOut {
+		content, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(content))
+	} else {
+		for _, p := range report.Pools {
+			var stages []string
+			for _, stage := range harnessStages {
+				mark := "✗"
+				if p.Stages[stage] {
+					mark = "✓"
+				}
+				stages = append(stages, stage+mark)
+			}
+			fmt.Printf("%s  %s\n", shortAddress(p.Pool), strings.Join(stages, " "))
+		}
+		if report.Passed {
+			fmt.Printf("✅ %d 个池走完 CSV → 池文件 → 开仓 → 领取 → 兑换，用时 %s\n", len(report.Pools), report.Took)
+		} else {
+			fmt.Printf("❌ 未完成: %s（用时 %s）\n", strings.Join(report.Missing, ", "), report.Took)
+		}
+		if *keep {
+			fmt.Printf("临时目录: %s\n", report.Dir)
+		}
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
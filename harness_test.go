@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runHarnessT 运行一次集成测试，检查每个池都走完 CSV → 池文件 → 开仓 → 领取 → 兑换
+func runHarnessT(t *testing.T, rows int) *HarnessReport {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("集成测试：-short 时跳过")
+	}
+	report, err := runHarness(HarnessOptions{Rows: rows, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("集成测试未能运行: %v", err)
+	}
+	if len(report.Pools) != rows {
+		t.Fatalf("写入 %d 行，报告中有 %d 个池", rows, len(report.Pools))
+	}
+	for _, p := range report.Pools {
+		for _, stage := range harnessStages {
+			if !p.Stages[stage] {
+				t.Errorf("池 %s 未到达阶段 %s", shortAddress(p.Pool), stage)
+			}
+		}
+	}
+	if !report.Passed || len(report.Missing) > 0 {
+		t.Errorf("Passed=%v，未完成: %v（用时 %s）", report.Passed, report.Missing, report.Took)
+	}
+	return report
+}
+
+func TestHarnessPipeline(t *testing.T) {
+	report := runHarnessT(t, 3)
+	if n := report.Calls[scriptAddLiquidity]; n != 3 {
+		t.Errorf("开仓回放 %d 次，应为 3 次", n)
+	}
+	for _, target := range []string{scriptClaimAllRewards, scriptJupSwapBalances, scriptJupSwap} {
+		if report.Calls[target] == 0 {
+			t.Errorf("没有回放 %s", target)
+		}
+	}
+}
+
+// 同一进程再次运行：上一次的任务队列、演示持仓与调用计数不影响本次
+func TestHarnessRepeatable(t *testing.T) {
+	first := runHarnessT(t, 2)
+	second := runHarnessT(t, 2)
+	if n := second.Calls[scriptAddLiquidity]; n != 2 {
+		t.Errorf("第二次运行开仓回放 %d 次，应为 2 次（调用计数未重置）", n)
+	}
+	if first.Dir == second.Dir || first.Pools[0].Pool == second.Pools[0].Pool {
+		t.Errorf("两次运行使用了相同的目录或池: %s %s", first.Dir, first.Pools[0].Pool)
+	}
+	if isHarness() || appDataDir == filepath.Join(second.Dir, "data") {
+		t.Errorf("运行结束后没有恢复回放状态与数据目录")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthConfig 存活与就绪检查（GET /healthz、GET /readyz），供 systemd、Docker、k8s 在子系统卡住时重启
+type HealthConfig struct {
+	WatcherStaleSeconds int `json:"watcherStaleSeconds"` // 主循环（文件监听与信号处理）超过该时长没有响应视为卡住
+	JobStaleFactor      int `json:"jobStaleFactor"`      // 定时任务超过 该倍数 × 间隔（含 RPC 降级的拉长）没有完成一轮视为卡住
+	MaxJobRunSeconds    int `json:"maxJobRunSeconds"`    // 定时任务单轮执行超过该时长视为卡住
+	RPCCheckSeconds     int `json:"rpcCheckSeconds"`     // 就绪检查中 RPC 连通性（getHealth）结果的缓存时间
+}
+
+// HealthCheck 单项检查结果
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport 检查汇总，任一项失败时返回 503
+type HealthReport struct {
+	Status    string                 `json:"status"` // ok / fail
+	CheckedAt string                 `json:"checkedAt"`
+	Checks    map[string]HealthCheck `json:"checks"`
+}
+
+// 主循环心跳间隔
+const watcherHeartbeatInterval = 5 * time.Second
+
+var (
+	watcherRunning   atomic.Bool
+	watcherHeartbeat atomic.Int64 // 主循环最近一次响应的时间（UnixNano）
+
+	healthRPCMutex   sync.Mutex
+	healthRPCChecked time.Time
+	healthRPCResult  HealthCheck
+)
+
+func (c HealthConfig) validate() error {
+	if c.WatcherStaleSeconds <= 0 || c.JobStaleFactor <= 0 || c.MaxJobRunSeconds <= 0 || c.RPCCheckSeconds <= 0 {
+		return fmt.Errorf("health.watcherStaleSeconds、jobStaleFactor、maxJobRunSeconds、rpcCheckSeconds 必须大于0")
+	}
+	return nil
+}
+
+// noteWatcherHeartbeat 主循环每次响应时调用
+func noteWatcherHeartbeat() {
+	watcherHeartbeat.Store(time.Now().UnixNano())
+}
+
+// 文件监听是否在运行、主循环是否仍在响应
+func checkWatcher() HealthCheck {
+	if !watcherRunning.Load() {
+		return HealthCheck{Detail: "文件监听未运行"}
+	}
+	since := time.Since(time.Unix(0, watcherHeartbeat.Load()))
+	if since > time.Duration(currentConfig().Health.WatcherStaleSeconds)*time.Second {
+		return HealthCheck{Detail: fmt.Sprintf("主循环 %v 没有响应", since.Round(time.Second))}
+	}
+	return HealthCheck{OK: true}
+}
+
+// 各定时任务最近一轮完成的时间：单轮执行过久、或长时间没有完成一轮时视为卡住（暂停的任务不检查）
+func checkScheduledJobs() map[string]HealthCheck {
+	cfg := currentConfig().Health
+	now := appNow()
+	stretch := time.Duration(1) << currentRPCDegradeLevel()
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+	checks := map[string]HealthCheck{}
+	for name, j := range schedulerJobs {
+		j.mu.Lock()
+		schedule, jitter, runStart, lastDone := j.schedule, j.jitter, j.runStart, j.lastDone
+		j.mu.Unlock()
+		if j.paused.Load() {
+			checks[name] = HealthCheck{OK: true, Detail: "已暂停"}
+			continue
+		}
+		if j.running.Load() {
+			if d := time.Since(runStart); d > time.Duration(cfg.MaxJobRunSeconds)*time.Second {
+				checks[name] = HealthCheck{Detail: fmt.Sprintf("本轮已执行 %v", d.Round(time.Second))}
+				continue
+			}
+		}
+		next := schedule.Next(now)
+		interval := schedule.Next(next).Sub(next)
+		allowed := time.Duration(cfg.JobStaleFactor)*interval*stretch + jitter
+		last := lastDone
+		if last.IsZero() {
+			last = startedAt
+		}
+		if since := time.Since(last); since > allowed {
+			checks[name] = HealthCheck{Detail: fmt.Sprintf("%v 没有完成一轮（允许 %v）", since.Round(time.Second), allowed)}
+			continue
+		}
+		check := HealthCheck{OK: true}
+		if !lastDone.IsZero() {
+			check.Detail = "最近完成 " + lastDone.Format(time.RFC3339)
+		}
+		checks[name] = check
+	}
+	return checks
+}
+
+// RPC 连通性：经节点池请求 getHealth，结果缓存 rpcCheckSeconds（演示模式与未配置 RPC 时跳过）
+func checkRPC() HealthCheck {
+	if isDemo() {
+		return HealthCheck{OK: true, Detail: "演示模式不访问 RPC"}
+	}
+	if len(rpcEndpoints()) == 0 {
+		return HealthCheck{OK: true, Detail: "未配置 RPC 节点"}
+	}
+	healthRPCMutex.Lock()
+	defer healthRPCMutex.Unlock()
+	if time.Since(healthRPCChecked) < time.Duration(currentConfig().Health.RPCCheckSeconds)*time.Second {
+		return healthRPCResult
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var health string
+	if err := solanaRPC(ctx, "getHealth", []interface{}{}, &health); err != nil {
+		healthRPCResult = HealthCheck{Detail: err.Error()}
+	} else {
+		healthRPCResult = HealthCheck{OK: true, Detail: health}
+	}
+	healthRPCChecked = time.Now()
+	return healthRPCResult
+}
+
+// 目录可写：创建并删除一个临时文件（不使用 .json 后缀，不会触发新池监听）
+func checkWritable(dir string) HealthCheck {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return HealthCheck{Detail: err.Error()}
+	}
+	f, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return HealthCheck{Detail: err.Error()}
+	}
+	_, err = f.WriteString(time.Now().Format(time.RFC3339))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	os.Remove(f.Name())
+	if err != nil {
+		return HealthCheck{Detail: err.Error()}
+	}
+	return HealthCheck{OK: true}
+}
+
+// 存活检查：主循环与定时任务没有卡住
+func livenessReport() HealthReport {
+	checks := map[string]HealthCheck{"watcher": checkWatcher()}
+	for name, c := range checkScheduledJobs() {
+		checks["job:"+name] = c
+	}
+	for name, c := range checkSupervised() {
+		checks[name] = c
+	}
+	return newHealthReport(checks)
+}
+
+// 就绪检查：存活检查之外，RPC 可用、数据与状态目录可写、未在关闭
+func readinessReport() HealthReport {
+	report := livenessReport()
+	report.Checks["rpc"] = checkRPC()
+	report.Checks["dataDir"] = checkWritable(poolDataDir())
+	report.Checks["stateDir"] = checkWritable(currentStateDir())
+	if currentConfig().Logging.Dir != "" {
+		report.Checks["logDir"] = checkWritable(currentConfig().Logging.Dir)
+	}
+	if shuttingDown() {
+		report.Checks["shutdown"] = HealthCheck{Detail: "正在关闭"}
+	}
+	return newHealthReport(report.Checks)
+}
+
+func newHealthReport(checks map[string]HealthCheck) HealthReport {
+	report := HealthReport{Status: "ok", CheckedAt: time.Now().Format(time.RFC3339), Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			report.Status = "fail"
+		}
+	}
+	return report
+}
+
+// 检查通过返回 200，否则 503
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
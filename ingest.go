@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Signal 一条新池信号：CSV 行或其他来源推送的字段集合，统一进入 handleSignal 处理
+type Signal struct {
+	Source    string
+	OutputDir string                 // 池记录输出目录（默认 data 目录）
+	Data      map[string]interface{} // 字段名 -> 原始字符串
+	Headers   []string               // 原始表头（非 CSV 来源为排序后的字段名）
+	Record    []string
+	Line      int // CSV 行号（非 CSV 来源为 0）
+}
+
+// SignalIngestor 新池信号输入：Run 阻塞直到 ctx 取消，收到的信号通过 emit 交给主循环串行处理
+type SignalIngestor interface {
+	Name() string
+	Run(ctx context.Context, emit func(Signal)) error
+}
+
+// IngestConfig CSV 之外的信号输入
+type IngestConfig struct {
+	JSONDirs []JSONDirIngestConfig `json:"jsonDirs"`
+	Redis    []RedisIngestConfig   `json:"redis"`
+	NATS     []NATSIngestConfig    `json:"nats"`
+	HTTP     HTTPIngestConfig      `json:"http"`
+}
+
+// JSONDirIngestConfig 监听目录中的信号 JSON 文件（处理后移入 processed/，无法解析的移入 failed/）
+type JSONDirIngestConfig struct {
+	Name            string `json:"name"`
+	Dir             string `json:"dir"`
+	OutputDir       string `json:"outputDir"`
+	IntervalSeconds int    `json:"intervalSeconds"` // 扫描间隔（默认 2 秒）
+}
+
+// HTTPIngestConfig 通过 POST /signals 推送信号（挂在管理接口上，或在 listen 单独监听）
+type HTTPIngestConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Name            string `json:"name"`            // 信号来源名（默认 http）
+	Token           string `json:"token"`           // 非空时要求 Authorization: Bearer <token>
+	OutputDir       string `json:"outputDir"`       // 池记录输出目录（默认 data 目录）
+	Listen          string `json:"listen"`          // 单独的监听地址（只提供 POST /signals），为空时使用管理接口
+	HMACSecret      string `json:"hmacSecret"`      // 非空时校验请求签名
+	SignatureHeader string `json:"signatureHeader"` // 签名头（默认 X-Signature）
+	TimestampHeader string `json:"timestampHeader"` // 时间戳头（默认 X-Signature-Timestamp）
+	MaxSkewSeconds  int    `json:"maxSkewSeconds"`  // 时间戳允许的偏差（默认 300 秒），窗口内重复的签名视为重放
+}
+
+// 刚写入的文件等待该时长后再读取，避免读到半截内容
+const jsonDirSettle = time.Second
+
+var (
+	httpSignalMutex sync.Mutex
+	httpSignalEmit  func(Signal) // HTTP 信号输入运行期间有效
+)
+
+func outputDirOrData(dir string) string {
+	if dir == "" {
+		return poolDataDir()
+	}
+	return dir
+}
+
+func (c HTTPIngestConfig) name() string {
+	if c.Name == "" {
+		return "http"
+	}
+	return c.Name
+}
+
+func (c IngestConfig) validate(sources []CSVSourceConfig, api APIConfig) error {
+	names := map[string]bool{}
+	for _, s := range sources {
+		names[s.Name] = true
+	}
+	checkName := func(kind, name string) error {
+		if name == "" || strings.ContainsAny(name, " /\\.") {
+			return fmt.Errorf("ingest.%s 名称非法: %q", kind, name)
+		}
+		if names[name] {
+			return fmt.Errorf("ingest.%s 名称与其他信号来源重复: %s", kind, name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, d := range c.JSONDirs {
+		if err := checkName("jsonDirs", d.Name); err != nil {
+			return err
+		}
+		if !filepath.IsAbs(d.Dir) || (d.OutputDir != "" && !filepath.IsAbs(d.OutputDir)) {
+			return fmt.Errorf("ingest.jsonDirs.%s 的目录必须为绝对路径", d.Name)
+		}
+		if d.IntervalSeconds < 0 {
+			return fmt.Errorf("ingest.jsonDirs.%s.intervalSeconds 不能为负数", d.Name)
+		}
+	}
+	for _, r := range c.Redis {
+		if err := checkName("redis", r.Name); err != nil {
+			return err
+		}
+		if r.Addr == "" || r.Key == "" {
+			return fmt.Errorf("ingest.redis.%s 需要 addr 与 key", r.Name)
+		}
+	}
+	for _, n := range c.NATS {
+		if err := checkName("nats", n.Name); err != nil {
+			return err
+		}
+		if n.URL == "" || n.Subject == "" {
+			return fmt.Errorf("ingest.nats.%s 需要 url 与 subject", n.Name)
+		}
+	}
+	if c.HTTP.Enabled {
+		if err := checkName("http", c.HTTP.name()); err != nil {
+			return err
+		}
+		if !api.Enabled && c.HTTP.Listen == "" {
+			return fmt.Errorf("ingest.http 需要开启 api 或设置 listen")
+		}
+		if c.HTTP.Listen != "" && api.Enabled && c.HTTP.Listen == api.Listen {
+			return fmt.Errorf("ingest.http.listen 不能与 api.listen 相同")
+		}
+		if c.HTTP.MaxSkewSeconds < 0 {
+			return fmt.Errorf("ingest.http.maxSkewSeconds 不能为负数")
+		}
+	}
+	return nil
+}
+
+// 按配置创建全部信号输入（CSV 源在前）
+func buildIngestors() ([]SignalIngestor, error) {
+	var ingestors []SignalIngestor
+	for _, source := range currentConfig().CSVSources {
+		t, err := newCSVTailer(source)
+		if err != nil {
+			return nil, fmt.Errorf("读取CSV头部失败: %s, %v", source.Name, err)
+		}
+		logOutput("开始监听文件: %s（源: %s，输出: %s）\n", source.Path, source.Name, source.outputDir())
+		logOutput("CSV字段数: %d，当前行数: %d\n", len(t.headers), t.state.Line)
+		ingestors = append(ingestors, &csvIngestor{t: t})
+	}
+	cfg := currentConfig().Ingest
+	for _, d := range cfg.JSONDirs {
+		ingestors = append(ingestors, &jsonDirIngestor{cfg: d})
+	}
+	for _, r := range cfg.Redis {
+		ingestors = append(ingestors, &redisIngestor{cfg: r})
+	}
+	for _, n := range cfg.NATS {
+		ingestors = append(ingestors, &natsIngestor{cfg: n})
+	}
+	if cfg.HTTP.Enabled {
+		ingestors = append(ingestors, &httpIngestor{cfg: cfg.HTTP})
+	}
+	return ingestors, nil
+}
+
+// 运行信号输入，异常退出时返回错误（由 supervise 告警并按退避重启）
+func runIngestor(ing SignalIngestor, signals chan<- Signal) error {
+	emit := func(sig Signal) {
+		metricSignals.Inc(sig.Source)
+		select {
+		case signals <- sig:
+		case <-globalCtx.Done():
+		}
+	}
+	logOutput("📡 信号输入已启动: %s\n", ing.Name())
+	return ing.Run(globalCtx, emit)
+}
+
+// 等待重连（ctx 取消时返回 false）
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// 解析 JSON 信号：单个对象或对象数组，字段值统一转为字符串（与 CSV 一致）
+func parseSignalJSON(source, outputDir string, payload []byte) ([]Signal, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("解析信号JSON失败: %v", err)
+	}
+	var objects []map[string]interface{}
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		objects = append(objects, v)
+	case []interface{}:
+		for _, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("信号数组中存在非对象元素")
+			}
+			objects = append(objects, obj)
+		}
+	default:
+		return nil, fmt.Errorf("信号必须是JSON对象或对象数组")
+	}
+
+	signals := make([]Signal, 0, len(objects))
+	for _, obj := range objects {
+		sig := Signal{Source: source, OutputDir: outputDir, Data: map[string]interface{}{}}
+		for k := range obj {
+			sig.Headers = append(sig.Headers, k)
+		}
+		sort.Strings(sig.Headers)
+		for _, k := range sig.Headers {
+			var s string
+			switch x := obj[k].(type) {
+			case string:
+				s = x
+			case json.Number:
+				s = x.String()
+			case nil:
+			default:
+				b, _ := json.Marshal(x)
+				s = string(b)
+			}
+			sig.Data[k] = s
+			sig.Record = append(sig.Record, s)
+		}
+		signals = append(signals, sig)
+	}
+	return signals, nil
+}
+
+// csvIngestor 按字节偏移追踪 CSV（监听所在目录 + 定时兜底轮询）
+type csvIngestor struct {
+	t *csvTailer
+}
+
+func (c *csvIngestor) Name() string { return c.t.source.Name }
+
+func (c *csvIngestor) Run(ctx context.Context, emit func(Signal)) error {
+	t := c.t
+	outputDir := t.source.outputDir()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	// 监听CSV所在目录（文件被轮转或重建后仍能收到事件）
+	watcher, err := newFileWatcher("csv:" + t.source.Name)
+	if err != nil {
+		return fmt.Errorf("创建文件监听器失败: %v", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(t.path)); err != nil {
+		return fmt.Errorf("添加CSV文件监听失败: %v", err)
+	}
+	// 兜底轮询：防止遗漏文件系统事件
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	// 读取一块新增内容并处理，返回是否还有未读取的新增行
+	pollChunk := func() bool {
+		rows, more, err := t.poll()
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logWarn("⚠️ 读取CSV新增内容失败", "source", t.source.Name, "file", t.path, "error", err)
+			}
+			return false
+		}
+		if len(rows) == 0 {
+			return more
+		}
+		logOutput("🔄 [%s] 检测到 %d 行新增，开始处理...\n", t.source.Name, len(rows))
+		backlog := t.catchUp
+		// 积压分多块读取时，每块都按补处理时限过滤
+		t.catchUp = backlog && more
+		stale := 0
+		for _, row := range rows {
+			if len(row.record) < 1 {
+				continue
+			}
+			data := make(map[string]interface{})
+			// 将每个字段与对应的头部名称配对，保持原始字符串格式
+			for i, value := range row.record {
+				if i < len(t.fields) {
+					data[t.fields[i]] = value
+				}
+			}
+			// 停机期间的积压行超出补处理时限时跳过
+			if backlog && !catchUpFresh(data) {
+				stale++
+				continue
+			}
+			emit(Signal{Source: t.source.Name, OutputDir: outputDir, Data: data, Headers: t.headers, Record: row.record, Line: row.line})
+		}
+		if backlog {
+			logOutput("🧭 [%s] 启动补处理: 积压 %d 行，%d 行超出时限已跳过\n", t.source.Name, len(rows), stale)
+		}
+		logOutput("📊 [%s] 当前总行数: %d\n", t.source.Name, t.state.Line)
+		return more
+	}
+	poll := func() {
+		for pollChunk() && ctx.Err() == nil {
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// 处理CSV文件写入、重建与轮转事件
+			if event.Name == t.path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				time.Sleep(200 * time.Millisecond) // 等待写入完成
+				poll()
+			}
+		case <-ticker.C:
+			poll()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logError("❌ CSV监听错误", "source", t.source.Name, "error", err)
+		}
+	}
+}
+
+// jsonDirIngestor 定时扫描目录中的 *.json 信号文件
+type jsonDirIngestor struct {
+	cfg JSONDirIngestConfig
+}
+
+func (j *jsonDirIngestor) Name() string { return j.cfg.Name }
+
+func (j *jsonDirIngestor) Run(ctx context.Context, emit func(Signal)) error {
+	for _, sub := range []string{"", "processed", "failed"} {
+		if err := os.MkdirAll(filepath.Join(j.cfg.Dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+	interval := time.Duration(j.cfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	for {
+		j.scan(emit)
+		if !sleepCtx(ctx, interval) {
+			return nil
+		}
+	}
+}
+
+func (j *jsonDirIngestor) scan(emit func(Signal)) {
+	entries, err := os.ReadDir(j.cfg.Dir)
+	if err != nil {
+		logWarn("⚠️ 读取信号目录失败", "source", j.cfg.Name, "dir", j.cfg.Dir, "error", err)
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if info, err := e.Info(); err != nil || time.Since(info.ModTime()) < jsonDirSettle {
+			continue
+		}
+		path := filepath.Join(j.cfg.Dir, e.Name())
+		dest := "processed"
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		signals, err := parseSignalJSON(j.cfg.Name, outputDirOrData(j.cfg.OutputDir), content)
+		if err != nil {
+			logWarn("⚠️ 信号文件无效", "source", j.cfg.Name, "file", path, "error", err)
+			metricCSVRows.Inc("invalid")
+			dest = "failed"
+		}
+		for _, sig := range signals {
+			emit(sig)
+		}
+		if err := os.Rename(path, filepath.Join(j.cfg.Dir, dest, e.Name())); err != nil {
+			logError("❌ 移动信号文件失败", "file", path, "error", err)
+		}
+	}
+}
+
+// httpIngestor 接收 POST /signals 推送的信号
+type httpIngestor struct {
+	cfg HTTPIngestConfig
+}
+
+func (h *httpIngestor) Name() string { return h.cfg.name() }
+
+func (h *httpIngestor) Run(ctx context.Context, emit func(Signal)) error {
+	httpSignalMutex.Lock()
+	httpSignalEmit = emit
+	httpSignalMutex.Unlock()
+	defer func() {
+		httpSignalMutex.Lock()
+		httpSignalEmit = nil
+		httpSignalMutex.Unlock()
+	}()
+	if h.cfg.Listen != "" {
+		return serveSignalWebhook(ctx, h.cfg)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// POST /signals：请求体为单个信号对象或对象数组，Content-Type 为 text/csv 时为带表头的 CSV
+func signalsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := currentConfig().Ingest.HTTP
+	httpSignalMutex.Lock()
+	emit := httpSignalEmit
+	httpSignalMutex.Unlock()
+	if !cfg.Enabled || emit == nil {
+		writeError(w, http.StatusServiceUnavailable, "http signal ingest disabled")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !authorizeSignalRequest(w, r, cfg, body) {
+		return
+	}
+	signals, err := parsePushedSignals(cfg, r.Header.Get("Content-Type"), body)
+	if err != nil {
+		webhookInvalid(w, err)
+		return
+	}
+	for _, sig := range signals {
+		emit(sig)
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(signals)})
+}
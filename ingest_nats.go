@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NATSIngestConfig 订阅 NATS 主题读取信号，每条消息为一个 JSON 信号
+type NATSIngestConfig struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"` // nats://[user:pass@]host:4222
+	Token     string `json:"token"`
+	Subject   string `json:"subject"`
+	Queue     string `json:"queue"` // 队列组（多实例时只有一个实例收到消息）
+	OutputDir string `json:"outputDir"`
+}
+
+// natsIngestor 使用最小 NATS 文本协议客户端订阅主题，断线后自动重连
+type natsIngestor struct {
+	cfg NATSIngestConfig
+}
+
+func (n *natsIngestor) Name() string { return n.cfg.Name }
+
+func (n *natsIngestor) Run(ctx context.Context, emit func(Signal)) error {
+	for {
+		err := n.consume(ctx, emit)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logWarn("⚠️ NATS信号输入断开，5秒后重连", "source", n.cfg.Name, "subject", n.cfg.Subject, "error", err)
+		if !sleepCtx(ctx, 5*time.Second) {
+			return nil
+		}
+	}
+}
+
+// 解析地址与 URL 中的用户名密码
+func (n *natsIngestor) endpoint() (addr string, user, pass string, err error) {
+	raw := n.cfg.URL
+	if !strings.Contains(raw, "://") {
+		raw = "nats://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "", err
+	}
+	addr = u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	return addr, user, pass, nil
+}
+
+func (n *natsIngestor) consume(ctx context.Context, emit func(Signal)) error {
+	addr, user, pass, err := n.endpoint()
+	if err != nil {
+		return err
+	}
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	rd := bufio.NewReader(conn)
+	// 服务端默认每 2 分钟 PING 一次，超过 5 分钟无数据视为断线
+	readLine := func() (string, error) {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := rd.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	if line, err := readLine(); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("nats: 意外的握手: %q", line)
+	}
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "meteora_dlmm", "lang": "go", "version": "1.0"}
+	if n.cfg.Token != "" {
+		opts["auth_token"] = n.cfg.Token
+	}
+	if user != "" {
+		opts["user"], opts["pass"] = user, pass
+	}
+	connect, _ := json.Marshal(opts)
+	sub := fmt.Sprintf("SUB %s 1\r\n", n.cfg.Subject)
+	if n.cfg.Queue != "" {
+		sub = fmt.Sprintf("SUB %s %s 1\r\n", n.cfg.Subject, n.cfg.Queue)
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n%sPING\r\n", connect, sub); err != nil {
+		return err
+	}
+	logOutput("📡 已订阅NATS信号主题: %s %s\n", addr, n.cfg.Subject)
+
+	for {
+		line, err := readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return fmt.Errorf("nats: 无效的消息头: %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(rd, payload); err != nil {
+				return err
+			}
+			signals, err := parseSignalJSON(n.cfg.Name, outputDirOrData(n.cfg.OutputDir), payload[:size])
+			if err != nil {
+				logWarn("⚠️ NATS信号无效", "source", n.cfg.Name, "payload", string(payload[:size]), "error", err)
+				metricCSVRows.Inc("invalid")
+				continue
+			}
+			for _, sig := range signals {
+				emit(sig)
+			}
+		case line == "PING":
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisIngestConfig 从 Redis 列表（BLPOP）读取信号，每个元素为一个 JSON 信号
+type RedisIngestConfig struct {
+	Name      string `json:"name"`
+	Addr      string `json:"addr"` // host:port
+	Password  string `json:"password"`
+	DB        int    `json:"db"`
+	Key       string `json:"key"`
+	OutputDir string `json:"outputDir"`
+}
+
+// BLPOP 阻塞等待时长（秒），用于定期检查关闭信号
+const redisPopTimeout = 5
+
+// redisIngestor 使用最小 RESP 客户端消费列表，断线后自动重连
+type redisIngestor struct {
+	cfg RedisIngestConfig
+}
+
+func (r *redisIngestor) Name() string { return r.cfg.Name }
+
+func (r *redisIngestor) Run(ctx context.Context, emit func(Signal)) error {
+	for {
+		err := r.consume(ctx, emit)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logWarn("⚠️ Redis信号输入断开，5秒后重连", "source", r.cfg.Name, "addr", r.cfg.Addr, "error", err)
+		if !sleepCtx(ctx, 5*time.Second) {
+			return nil
+		}
+	}
+}
+
+func (r *redisIngestor) consume(ctx context.Context, emit func(Signal)) error {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", r.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	rd := bufio.NewReader(conn)
+	call := func(args ...string) (interface{}, error) {
+		conn.SetDeadline(time.Now().Add((redisPopTimeout + 10) * time.Second))
+		if _, err := conn.Write(encodeRESP(args)); err != nil {
+			return nil, err
+		}
+		return readRESP(rd)
+	}
+	if r.cfg.Password != "" {
+		if _, err := call("AUTH", r.cfg.Password); err != nil {
+			return err
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := call("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			return err
+		}
+	}
+	logOutput("📡 已连接Redis信号队列: %s %s\n", r.cfg.Addr, r.cfg.Key)
+
+	for ctx.Err() == nil {
+		reply, err := call("BLPOP", r.cfg.Key, strconv.Itoa(redisPopTimeout))
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			continue // 超时返回 nil
+		}
+		payload, _ := items[1].(string)
+		signals, err := parseSignalJSON(r.cfg.Name, outputDirOrData(r.cfg.OutputDir), []byte(payload))
+		if err != nil {
+			logWarn("⚠️ Redis信号无效", "source", r.cfg.Name, "payload", payload, "error", err)
+			metricCSVRows.Inc("invalid")
+			continue
+		}
+		for _, sig := range signals {
+			emit(sig)
+		}
+	}
+	return nil
+}
+
+// RESP 命令编码
+func encodeRESP(args []string) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(sb.String())
+}
+
+// 读取一个 RESP 回复：简单字符串/整数/批量字符串返回 string 或 int64，数组返回 []interface{}，空值返回 nil
+func readRESP(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: 空回复")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: 无法识别的回复: %q", line)
+}
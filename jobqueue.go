@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 队列中的任务类型
+const (
+	jobAddLiquidity = "addLiquidity" // 处理新池文件（开仓）
+	jobClaim        = "claim"
+	jobSwap         = "swap"
+	jobPrice        = "price"
+	// jobCompound 复投，见 compound.go
+	// jobPanicClose 紧急平仓，见 panicclose.go
+)
+
+// 同一钱包连续兑换之间的间隔，避免系统负载过高
+const swapJobSpacing = 2 * time.Second
+
+// JobQueueConfig 进程内任务队列：按优先级取任务，同一去重键的任务只保留一个，失败按次数重试，并按类型限制并发。
+// 开仓并发取 maxConcurrentTasks、价格并发取 priceFetch.workers（均随 RPC 限流降级缩减）
+type JobQueueConfig struct {
+	Workers           int            `json:"workers"`           // 同时执行的任务总数上限
+	Priorities        map[string]int `json:"priorities"`        // 任务类型 -> 优先级，数值大的先执行
+	Concurrency       map[string]int `json:"concurrency"`       // claim / swap 的并发上限（默认 1，即顺序执行）
+	MaxRetries        map[string]int `json:"maxRetries"`        // claim / swap 失败后的重试次数（开仓不重试，避免重复加仓）
+	RetryDelaySeconds int            `json:"retryDelaySeconds"` // 重试前等待的时间
+}
+
+// QueuedJob 队列中的一个任务
+type QueuedJob struct {
+	Type   string
+	Key    string       // 去重键：同类型同键的任务在排队或执行中时，新加入的直接合并到已有任务
+	Run    func() error // 返回错误且未超过重试次数时延迟后重新执行
+	OnDrop func()       // 关闭时仍在排队的任务被丢弃时调用
+	Urgent bool         // 紧急任务：排在所有任务之前，不受 workers 与类型并发上限限制
+	// OnBlock 停止开关或交易时段不允许执行、任务被跳过时调用（reason 为跳过原因），未设置时调用 OnDrop
+	OnBlock func(reason, detail string)
+
+	priority   int
+	attempts   int
+	enqueuedAt time.Time
+	notBefore  time.Time // 等待重试时的最早执行时间
+	running    bool
+	done       chan struct{}
+}
+
+// QueueJobStatus 队列中任务的状态（GET /queue）
+type QueueJobStatus struct {
+	Type       string `json:"type"`
+	Key        string `json:"key,omitempty"`
+	State      string `json:"state"` // pending / retrying / running
+	Priority   int    `json:"priority"`
+	Attempts   int    `json:"attempts"`
+	EnqueuedAt string `json:"enqueuedAt"`
+	NotBefore  string `json:"notBefore,omitempty"`
+}
+
+var (
+	jobQueueMutex   sync.Mutex
+	jobQueue        []*QueuedJob              // 排队中（含等待重试）的任务
+	jobQueueKeys    = map[string]*QueuedJob{} // 类型/去重键 -> 排队或执行中的任务
+	jobRunning      = map[*QueuedJob]bool{}
+	jobRunningTypes = map[string]int{}
+	jobQueueStopped bool
+)
+
+func (c JobQueueConfig) validate() error {
+	if c.Workers <= 0 {
+		return fmt.Errorf("jobQueue.workers 必须大于0")
+	}
+	for t := range c.Priorities {
+		if t != jobAddLiquidity && t != jobClaim && t != jobSwap && t != jobPrice && t != jobCompound {
+			return fmt.Errorf("jobQueue.priorities 仅支持 addLiquidity、claim、swap、price、compound: %s", t)
+		}
+	}
+	for t, n := range c.Concurrency {
+		if t != jobClaim && t != jobSwap && t != jobCompound {
+			return fmt.Errorf("jobQueue.concurrency 仅支持 claim、swap、compound（开仓取 maxConcurrentTasks，价格取 priceFetch.workers）: %s", t)
+		}
+		if n <= 0 {
+			return fmt.Errorf("jobQueue.concurrency.%s 必须大于0", t)
+		}
+	}
+	for t, n := range c.MaxRetries {
+		if t != jobClaim && t != jobSwap {
+			return fmt.Errorf("jobQueue.maxRetries 仅支持 claim、swap: %s", t)
+		}
+		if n < 0 {
+			return fmt.Errorf("jobQueue.maxRetries.%s 不能为负数", t)
+		}
+	}
+	if c.RetryDelaySeconds < 0 {
+		return fmt.Errorf("jobQueue.retryDelaySeconds 不能为负数")
+	}
+	return nil
+}
+
+// 任务类型的并发上限
+func jobTypeLimit(t string) int {
+	switch t {
+	case jobAddLiquidity:
+		return int(queueCapacity.Load())
+	case jobPrice:
+		return degradedConcurrency(currentConfig().PriceFetch.Workers)
+	}
+	if n := currentConfig().JobQueue.Concurrency[t]; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// enqueueJob 加入队列，返回任务结束（完成、放弃重试或被丢弃）时关闭的 channel；
+// 同类型同键的任务已在排队或执行中时不再加入，返回已有任务的 channel 与 false
+func enqueueJob(job *QueuedJob) (<-chan struct{}, bool) {
+	key := job.Type + "/" + job.Key
+	jobQueueMutex.Lock()
+	defer jobQueueMutex.Unlock()
+	if jobQueueStopped {
+		done := make(chan struct{})
+		close(done)
+		return done, false
+	}
+	if existing := jobQueueKeys[key]; existing != nil && job.Key != "" {
+		metricQueueJobs.Inc(job.Type, "deduplicated")
+		logDebug("⏭️ 相同任务已在队列中，合并", "type", job.Type, "key", job.Key)
+		return existing.done, false
+	}
+	job.priority = currentConfig().JobQueue.Priorities[job.Type]
+	if job.Urgent {
+		job.priority = math.MaxInt
+	}
+	job.enqueuedAt = time.Now()
+	job.done = make(chan struct{})
+	jobQueue = append(jobQueue, job)
+	if job.Key != "" {
+		jobQueueKeys[key] = job
+	}
+	if job.Type == jobAddLiquidity {
+		inFlightTasks.Add(1)
+	}
+	dispatchJobsLocked()
+	return job.done, true
+}
+
+// 调用方需持有 jobQueueMutex；按优先级（相同时先进先出）启动并发未满的任务。
+// 停止开关与交易时段在这里统一检查（tradingHalt）：不允许执行的开仓、兑换、复投任务直接结束，不再排队等待
+func dispatchJobsLocked() {
+	if jobQueueStopped {
+		return
+	}
+	sort.SliceStable(jobQueue, func(a, b int) bool {
+		if jobQueue[a].priority != jobQueue[b].priority {
+			return jobQueue[a].priority > jobQueue[b].priority
+		}
+		return jobQueue[a].enqueuedAt.Before(jobQueue[b].enqueuedAt)
+	})
+	now := time.Now()
+	workers := currentConfig().JobQueue.Workers
+	remaining := jobQueue[:0]
+	for _, j := range jobQueue {
+		if reason, detail := tradingHalt(j.Type); reason != "" && !j.Urgent {
+			finishQueuedJobLocked(j)
+			blockJob(j, reason, detail)
+			continue
+		}
+		if j.Urgent || len(jobRunning) < workers && !now.Before(j.notBefore) && jobRunningTypes[j.Type] < jobTypeLimit(j.Type) {
+			j.running = true
+			jobRunning[j] = true
+			jobRunningTypes[j.Type]++
+			shutdownWg.Add(1)
+			go runQueuedJob(j)
+			continue
+		}
+		remaining = append(remaining, j)
+	}
+	for i := len(remaining); i < len(jobQueue); i++ {
+		jobQueue[i] = nil
+	}
+	jobQueue = remaining
+}
+
+// 跳过不允许执行的任务（回调在后台执行，调用方持有 jobQueueMutex）
+func blockJob(j *QueuedJob, reason, detail string) {
+	metricQueueJobs.Inc(j.Type, "blocked")
+	logDebug("⛔ 任务不允许执行，跳过", "type", j.Type, "key", j.Key, "reason", reason)
+	if j.OnBlock == nil && j.OnDrop == nil {
+		return
+	}
+	runInBackground(func() {
+		if j.OnBlock != nil {
+			j.OnBlock(reason, detail)
+		} else {
+			j.OnDrop()
+		}
+	})
+}
+
+// 并发上限调大或重试到期后重新调度
+func kickJobQueue() {
+	jobQueueMutex.Lock()
+	defer jobQueueMutex.Unlock()
+	dispatchJobsLocked()
+}
+
+func runQueuedJob(j *QueuedJob) {
+	defer shutdownWg.Done()
+	// panic 视为本次执行失败，照常重试或结束（释放去重键、通知等待方），不影响其他任务
+	err := runRecovered(j.Run)
+	if pe, ok := err.(*panicError); ok {
+		notePanic("job:"+j.Type, j.Key, pe)
+	}
+
+	jobQueueMutex.Lock()
+	defer jobQueueMutex.Unlock()
+	j.running = false
+	delete(jobRunning, j)
+	jobRunningTypes[j.Type]--
+	j.attempts++
+	if err != nil && j.attempts <= currentConfig().JobQueue.MaxRetries[j.Type] && !jobQueueStopped {
+		delay := time.Duration(currentConfig().JobQueue.RetryDelaySeconds) * time.Second
+		j.notBefore = time.Now().Add(delay)
+		jobQueue = append(jobQueue, j)
+		time.AfterFunc(delay, kickJobQueue)
+		metricQueueJobs.Inc(j.Type, "retried")
+		logWarn("🔁 任务失败，稍后重试", "type", j.Type, "key", j.Key, "attempt", j.attempts, "delay", delay, "error", err)
+	} else {
+		finishQueuedJobLocked(j)
+		metricQueueJobs.Inc(j.Type, resultLabel(err))
+	}
+	dispatchJobsLocked()
+}
+
+// 调用方需持有 jobQueueMutex；任务结束，释放去重键并通知等待方
+func finishQueuedJobLocked(j *QueuedJob) {
+	if key := j.Type + "/" + j.Key; jobQueueKeys[key] == j {
+		delete(jobQueueKeys, key)
+	}
+	if j.Type == jobAddLiquidity {
+		inFlightTasks.Add(-1)
+	}
+	close(j.done)
+}
+
+// jobActive 同类型同键的任务在排队或执行中
+func jobActive(jobType, key string) bool {
+	jobQueueMutex.Lock()
+	defer jobQueueMutex.Unlock()
+	return jobQueueKeys[jobType+"/"+key] != nil
+}
+
+// waitJobs 等待一组任务全部结束
+func waitJobs(done []<-chan struct{}) {
+	for _, d := range done {
+		<-d
+	}
+}
+
+// stopJobQueue 收到关闭信号后不再启动新任务，丢弃仍在排队的任务（执行中的任务由排空等待）
+func stopJobQueue() {
+	jobQueueMutex.Lock()
+	jobQueueStopped = true
+	dropped := jobQueue
+	jobQueue = nil
+	for _, j := range dropped {
+		finishQueuedJobLocked(j)
+	}
+	jobQueueMutex.Unlock()
+
+	for _, j := range dropped {
+		metricQueueJobs.Inc(j.Type, "dropped")
+		if j.OnDrop != nil {
+			j.OnDrop()
+		}
+	}
+	if len(dropped) > 0 {
+		logOutput("🗑️ 已丢弃 %d 个排队中的任务\n", len(dropped))
+	}
+}
+
+// cancelQueuedJobs 取消排队中（含等待重试）且符合条件的任务，返回被取消的任务；执行中的任务不受影响
+func cancelQueuedJobs(match func(j *QueuedJob) bool) []QueueJobStatus {
+	jobQueueMutex.Lock()
+	var cancelled []*QueuedJob
+	remaining := jobQueue[:0]
+	for _, j := range jobQueue {
+		if match(j) {
+			cancelled = append(cancelled, j)
+			finishQueuedJobLocked(j)
+			continue
+		}
+		remaining = append(remaining, j)
+	}
+	for i := len(remaining); i < len(jobQueue); i++ {
+		jobQueue[i] = nil
+	}
+	jobQueue = remaining
+	jobQueueMutex.Unlock()
+
+	result := make([]QueueJobStatus, 0, len(cancelled))
+	for _, j := range cancelled {
+		metricQueueJobs.Inc(j.Type, "cancelled")
+		if j.OnDrop != nil {
+			j.OnDrop()
+		}
+		result = append(result, QueueJobStatus{Type: j.Type, Key: j.Key, State: "cancelled", Priority: j.priority, Attempts: j.attempts,
+			EnqueuedAt: j.enqueuedAt.Format(time.RFC3339)})
+	}
+	return result
+}
+
+// 排队与执行中的任务（执行中的在前，其余按执行顺序）
+func listQueuedJobs() []QueueJobStatus {
+	jobQueueMutex.Lock()
+	defer jobQueueMutex.Unlock()
+	status := func(j *QueuedJob, state string) QueueJobStatus {
+		s := QueueJobStatus{Type: j.Type, Key: j.Key, State: state, Priority: j.priority, Attempts: j.attempts,
+			EnqueuedAt: j.enqueuedAt.Format(time.RFC3339)}
+		if state == "retrying" {
+			s.NotBefore = j.notBefore.Format(time.RFC3339)
+		}
+		return s
+	}
+	result := []QueueJobStatus{}
+	for j := range jobRunning {
+		result = append(result, status(j, "running"))
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].EnqueuedAt < result[b].EnqueuedAt })
+	for _, j := range jobQueue {
+		state := "pending"
+		if time.Now().Before(j.notBefore) {
+			state = "retrying"
+		}
+		result = append(result, status(j, state))
+	}
+	return result
+}
+
+// 各类型排队与执行中的任务数（/metrics）
+func jobQueueSamples() []gaugeSample {
+	counts := map[[2]string]int{}
+	for _, s := range listQueuedJobs() {
+		counts[[2]string{s.Type, s.State}]++
+	}
+	var samples []gaugeSample
+	for _, t := range []string{jobPanicClose, jobAddLiquidity, jobPrice, jobSwap, jobClaim, jobCompound} {
+		for _, state := range []string{"pending", "retrying", "running"} {
+			samples = append(samples, gaugeSample{LabelValues: []string{t, state}, Value: float64(counts[[2]string{t, state}])})
+		}
+	}
+	return samples
+}
+
+// enqueuePoolFile 处理新池文件（已由 claimProcessed 记为处理中）；关闭时仍在排队的撤销标记，下次启动补处理
+func enqueuePoolFile(path string) {
+	enqueueJob(&QueuedJob{
+		Type: jobAddLiquidity,
+		Key:  path,
+		Run: func() error {
+			// 数据目录不可用：释放处理标记，恢复后补处理
+			if dataVolumeUnavailable() {
+				logOutput("💾 数据目录不可用，池文件待恢复后处理: %s\n", path)
+				recordSkip(subsystemEntry, skipUnhealthy, "", "", "数据目录不可用: "+path)
+				releaseProcessed(path)
+				return nil
+			}
+			markProcessed(path, processNewJSONFile(path))
+			return nil
+		},
+		OnDrop: func() { releaseProcessed(path) },
+		OnBlock: func(reason, detail string) {
+			skipPoolFile(path, reason, detail)
+		},
+	})
+}
+
+// enqueueClaim 领取池的奖励
+func enqueueClaim(poolAddress string) <-chan struct{} {
+	done, _ := enqueueJob(&QueuedJob{
+		Type: jobClaim,
+		Key:  poolAddress,
+		Run:  func() error { return runClaimRewards(poolAddress) },
+	})
+	return done
+}
+
+// enqueueSwap 兑换钱包中的代币（outputMint 为空时兑换为 SOL）
+func enqueueSwap(wallet, ca, outputMint string) <-chan struct{} {
+	done, _ := enqueueJob(&QueuedJob{
+		Type: jobSwap,
+		Key:  wallet + "/" + ca,
+		Run: func() error {
+			err := executeJupSwapToMint(wallet, ca, outputMint)
+			sleepCtx(globalCtx, swapJobSpacing)
+			return err
+		},
+		OnBlock: func(reason, detail string) {
+			logOutput("🛑 %s，跳过jupSwap: %s\n", detail, ca)
+			noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: reason})
+			recordSkip(subsystemSweep, reason, "", ca, detail)
+		},
+	})
+	return done
+}
+
+// enqueuePriceFetch 获取池的价格（fetchPrice.ts 请求 OKX 前先取得 OKX 的请求预算）
+func enqueuePriceFetch(poolAddress, tokenAddress string) <-chan struct{} {
+	done, _ := enqueueJob(&QueuedJob{
+		Type: jobPrice,
+		Key:  poolAddress,
+		Run: func() error {
+			if !apiBudgetFor(priceSourceOKX).acquire(globalCtx) {
+				if globalCtx.Err() == nil {
+					recordSkip(subsystemPrice, skipQuota, poolAddress, tokenAddress, "OKX 请求预算不足")
+				}
+				return nil
+			}
+			logOutput("🔄 正在获取价格: %s -> %s\n", poolAddress, tokenAddress)
+
+			// 显示position存在时间
+			displayPositionExistenceTime(poolAddress)
+
+			// 检查信号起强制平仓时限（在价格获取前检查；研究模式不移除）
+			if !isPriceOnly() {
+				checkAndExecuteSignalTimeout(poolAddress)
+			}
+
+			fetchPriceForToken(poolAddress, tokenAddress)
+			return nil
+		},
+	})
+	return done
+}
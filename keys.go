@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 签名私钥的来源：环境变量、加密私钥文件（口令取自环境变量或系统钥匙串）、HashiCorp Vault 或 AWS Secrets Manager。
+// 私钥只在本进程内存中解密，不写入磁盘；经继承的管道传给脚本（PRIVATE_KEY_FD），不出现在环境变量与命令行中
+
+// 私钥来源
+const (
+	keySourceEnv   = "env"   // 环境变量（进程环境或 .env），可为 encrypt_private_key.ts 加密的密文
+	keySourceFile  = "file"  // keys encrypt 生成的加密私钥文件
+	keySourceVault = "vault" // HashiCorp Vault KV（v1 / v2）
+	keySourceAWS   = "aws"   // AWS Secrets Manager
+)
+
+// 加密私钥文件的口令派生参数
+const (
+	keyFileVersion    = 1
+	keyFileKDF        = "pbkdf2-sha256"
+	keyFileIterations = 600000
+)
+
+// 脚本读取私钥的文件描述符所在的环境变量
+const envPrivateKeyFD = "PRIVATE_KEY_FD"
+
+// KeysConfig 签名私钥
+type KeysConfig struct {
+	Default        KeySourceConfig `json:"default"`        // 默认钱包（未配置 wallets 或池未分配钱包时）的私钥来源，type 为空时脚本按 .env 自行读取
+	RefreshMinutes int             `json:"refreshMinutes"` // 远程来源（vault、aws）重新读取的间隔，0 表示只在首次使用时读取
+}
+
+// KeySourceConfig 一个私钥来源
+type KeySourceConfig struct {
+	Type            string `json:"type"`            // env | file | vault | aws
+	Env             string `json:"env"`             // env：私钥所在的环境变量
+	Encrypted       bool   `json:"encrypted"`       // env：私钥为 encrypt_private_key.ts 加密后的密文
+	PasswordEnv     string `json:"passwordEnv"`     // env 密文的密码、file 的口令所在的环境变量
+	File            string `json:"file"`            // file：加密私钥文件，相对路径按程序目录解析
+	KeychainService string `json:"keychainService"` // file：口令存放在系统钥匙串中的服务名（macOS security / Linux secret-tool）
+	KeychainAccount string `json:"keychainAccount"` // file：钥匙串中的账户名，默认为钱包名（默认钱包为 default）
+	URL             string `json:"url"`             // vault：服务地址（默认 $VAULT_ADDR）；aws：自定义 endpoint
+	Path            string `json:"path"`            // vault：密钥路径，如 secret/data/meteora/main
+	TokenEnv        string `json:"tokenEnv"`        // vault：令牌所在的环境变量（默认 VAULT_TOKEN）
+	SecretID        string `json:"secretId"`        // aws：密钥名称或 ARN
+	Region          string `json:"region"`          // aws：区域
+	Field           string `json:"field"`           // vault / aws：私钥所在的字段（默认 privateKey；aws 密钥不是 JSON 对象时取整个值）
+}
+
+// keyFile 加密私钥文件：AES-256-GCM，密钥由口令经 PBKDF2-SHA256 派生，地址作为附加数据
+type keyFile struct {
+	Version    int    `json:"version"`
+	Address    string `json:"address"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type cachedKey struct {
+	secret []byte // 64 字节：种子 + 公钥
+	loaded time.Time
+}
+
+var (
+	keyCacheMutex sync.Mutex
+	keyCache      = map[string]*cachedKey{} // 钱包名（默认钱包为空）-> 私钥
+
+	keysHTTP = &http.Client{Timeout: 30 * time.Second}
+)
+
+func (c KeysConfig) validate() error {
+	if c.RefreshMinutes < 0 {
+		return fmt.Errorf("keys.refreshMinutes 不能为负数")
+	}
+	if c.Default.Type == "" {
+		return nil
+	}
+	return c.Default.validate("keys.default")
+}
+
+func (c KeySourceConfig) validate(prefix string) error {
+	switch c.Type {
+	case keySourceEnv:
+		if c.Env == "" {
+			return fmt.Errorf("%s.env 不能为空", prefix)
+		}
+	case keySourceFile:
+		if c.File == "" {
+			return fmt.Errorf("%s.file 不能为空", prefix)
+		}
+		if c.PasswordEnv == "" && c.KeychainService == "" {
+			return fmt.Errorf("%s 需要 passwordEnv 或 keychainService 提供口令", prefix)
+		}
+	case keySourceVault:
+		if c.Path == "" {
+			return fmt.Errorf("%s.path 不能为空", prefix)
+		}
+	case keySourceAWS:
+		if c.SecretID == "" || c.Region == "" {
+			return fmt.Errorf("%s.secretId 与 region 不能为空", prefix)
+		}
+	default:
+		return fmt.Errorf("%s.type 只支持 env、file、vault、aws: %q", prefix, c.Type)
+	}
+	return nil
+}
+
+// describe 日志与 keys check 中的来源说明（不含任何秘密）
+func (c KeySourceConfig) describe() string {
+	switch c.Type {
+	case keySourceEnv:
+		return "env:" + c.Env
+	case keySourceFile:
+		return "file:" + c.File
+	case keySourceVault:
+		return "vault:" + c.Path
+	case keySourceAWS:
+		return "aws:" + c.SecretID
+	}
+	return c.Type
+}
+
+func walletLabel(name string) string {
+	if name == "" {
+		return "默认钱包"
+	}
+	return name
+}
+
+// keySource 钱包的私钥来源：配置了 key 时使用它，否则为 privateKeyEnv 对应的环境变量
+func (w WalletConfig) keySource() KeySourceConfig {
+	if w.Key != nil {
+		return *w.Key
+	}
+	return KeySourceConfig{Type: keySourceEnv, Env: w.PrivateKeyEnv, Encrypted: w.Encrypted, PasswordEnv: w.PasswordEnv}
+}
+
+// signingKeyEnv 子进程的钱包地址与私钥：私钥由 attachSigningKey 经管道传入，不放入环境变量
+func signingKeyEnv(name string, src KeySourceConfig, address string) ([]string, []byte, error) {
+	secret, err := signingKey(name, src, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{"USER_WALLET_ADDRESS=" + encodeBase58(secret[32:])}, secret, nil
+}
+
+// attachSigningKey 把私钥（Base58）写入管道，读端作为子进程的 fd 3 继承，PRIVATE_KEY_FD 为其编号；子进程不再继承本进程的 PRIVATE_KEY* 变量。
+// 注册项设置了 keyEnv 时（只能从环境变量读取私钥的可执行文件，如 jupSwap）改为经该变量传入。返回的函数在命令结束后关闭本进程持有的读端
+func attachSigningKey(cmd *exec.Cmd, spec ScriptSpec, secret []byte) (func(), error) {
+	if secret == nil {
+		return func() {}, nil
+	}
+	env := cmd.Env[:0:0]
+	for _, kv := range cmd.Env {
+		if !strings.HasPrefix(kv, "PRIVATE_KEY") {
+			env = append(env, kv)
+		}
+	}
+	cmd.Env = env
+	if spec.KeyEnv != "" {
+		cmd.Env = append(cmd.Env, spec.KeyEnv+"="+encodeBase58(secret))
+		return func() {}, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// 私钥远小于管道缓冲区：写完即关闭写端，子进程读到 EOF 即得到完整私钥
+	_, err = io.WriteString(w, encodeBase58(secret))
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", envPrivateKeyFD, 2+len(cmd.ExtraFiles)))
+	return func() { r.Close() }, nil
+}
+
+// signingKey 读取（或取缓存的）私钥并核对地址；远程来源按 keys.refreshMinutes 重新读取，失败时继续使用已加载的私钥
+func signingKey(name string, src KeySourceConfig, address string) ([]byte, error) {
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	c := keyCache[name]
+	remote := src.Type == keySourceVault || src.Type == keySourceAWS
+	refresh := time.Duration(currentConfig().Keys.RefreshMinutes) * time.Minute
+	if c != nil && (!remote || refresh <= 0 || time.Since(c.loaded) < refresh) {
+		return c.secret, nil
+	}
+	secret, err := loadKeySource(name, src)
+	if err == nil && address != "" && encodeBase58(secret[32:]) != address {
+		err = fmt.Errorf("私钥对应的地址 %s 与配置的 %s 不一致", encodeBase58(secret[32:]), address)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s 的私钥（%s）: %v", walletLabel(name), src.describe(), err)
+		if c != nil {
+			logWarn("⚠️ 重新读取私钥失败，继续使用已加载的私钥", "wallet", walletLabel(name), "error", err)
+			c.loaded = time.Now()
+			return c.secret, nil
+		}
+		return nil, err
+	}
+	keyCache[name] = &cachedKey{secret: secret, loaded: time.Now()}
+	return secret, nil
+}
+
+func loadKeySource(name string, src KeySourceConfig) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch src.Type {
+	case keySourceEnv:
+		value := lookupEnv(src.Env)
+		if value == "" {
+			return nil, fmt.Errorf("环境变量 %s 未设置", src.Env)
+		}
+		if src.Encrypted {
+			passwordEnv := src.PasswordEnv
+			if passwordEnv == "" {
+				passwordEnv = "PRIVATE_KEY_PASSWORD"
+			}
+			plain, err := cryptoJSDecrypt(value, lookupEnv(passwordEnv))
+			if err != nil {
+				return nil, err
+			}
+			value = plain
+		}
+		return parseSecretKey(value)
+	case keySourceFile:
+		data, err := os.ReadFile(resolvePath(src.File))
+		if err != nil {
+			return nil, err
+		}
+		passphrase, err := keyPassphrase(ctx, name, src)
+		if err != nil {
+			return nil, err
+		}
+		return openKeyFile(data, passphrase)
+	case keySourceVault:
+		value, err := fetchVaultSecret(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		return parseSecretKey(value)
+	case keySourceAWS:
+		value, err := fetchAWSSecret(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		return parseSecretKey(value)
+	}
+	return nil, fmt.Errorf("未知的私钥来源: %s", src.Type)
+}
+
+// keyPassphrase 加密私钥文件的口令：环境变量优先，其次系统钥匙串
+func keyPassphrase(ctx context.Context, name string, src KeySourceConfig) (string, error) {
+	if src.PasswordEnv != "" {
+		if v := lookupEnv(src.PasswordEnv); v != "" {
+			return v, nil
+		}
+		if src.KeychainService == "" {
+			return "", fmt.Errorf("口令环境变量 %s 未设置", src.PasswordEnv)
+		}
+	}
+	account := src.KeychainAccount
+	if account == "" {
+		account = name
+	}
+	if account == "" {
+		account = "default"
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", src.KeychainService, "-a", account, "-w")
+	} else {
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", src.KeychainService, "account", account)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("从系统钥匙串读取口令失败（service=%s account=%s）: %v", src.KeychainService, account, err)
+	}
+	passphrase := strings.TrimRight(string(out), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("系统钥匙串中的口令为空（service=%s account=%s）", src.KeychainService, account)
+	}
+	return passphrase, nil
+}
+
+// fetchVaultSecret 读取 Vault KV 密钥的字段（KV v2 的字段在 data.data 下）
+func fetchVaultSecret(ctx context.Context, src KeySourceConfig) (string, error) {
+	addr := src.URL
+	if addr == "" {
+		addr = lookupEnv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return "", fmt.Errorf("未配置 Vault 地址（url 或 VAULT_ADDR）")
+	}
+	tokenEnv := src.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = "VAULT_TOKEN"
+	}
+	token := lookupEnv(tokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("Vault 令牌环境变量 %s 未设置", tokenEnv)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(src.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := lookupEnv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := doKeyRequest(req, &body); err != nil {
+		return "", fmt.Errorf("Vault: %v", err)
+	}
+	fields := body.Data
+	if inner, ok := fields["data"].(map[string]interface{}); ok {
+		fields = inner
+	}
+	field := keyField(src)
+	value, ok := fields[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("Vault 密钥 %s 中没有字段 %s", src.Path, field)
+	}
+	return value, nil
+}
+
+// fetchAWSSecret 调用 Secrets Manager GetSecretValue；密钥为 JSON 对象时取 field 字段，否则取整个值
+func fetchAWSSecret(ctx context.Context, src KeySourceConfig) (string, error) {
+	accessKey, secretKey := lookupEnv("AWS_ACCESS_KEY_ID"), lookupEnv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("缺少 AWS_ACCESS_KEY_ID 或 AWS_SECRET_ACCESS_KEY")
+	}
+	endpoint := src.URL
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + src.Region + ".amazonaws.com"
+	}
+	payload, _ := json.Marshal(map[string]string{"SecretId": src.SecretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := lookupEnv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, payload, accessKey, secretKey, src.Region, "secretsmanager", time.Now())
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doKeyRequest(req, &body); err != nil {
+		return "", fmt.Errorf("Secrets Manager: %v", err)
+	}
+	value := strings.TrimSpace(body.SecretString)
+	if strings.HasPrefix(value, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err == nil {
+			field := keyField(src)
+			s, ok := fields[field].(string)
+			if !ok || s == "" {
+				return "", fmt.Errorf("密钥 %s 中没有字段 %s", src.SecretID, field)
+			}
+			return s, nil
+		}
+	}
+	if value == "" {
+		return "", fmt.Errorf("密钥 %s 没有 SecretString", src.SecretID)
+	}
+	return value, nil
+}
+
+func keyField(src KeySourceConfig) string {
+	if src.Field == "" {
+		return "privateKey"
+	}
+	return src.Field
+}
+
+// doKeyRequest 发送请求并解析 JSON；错误信息只保留状态码（响应中可能含有密钥）
+func doKeyRequest(req *http.Request, v interface{}) error {
+	resp, err := keysHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// parseSecretKey 解析 Solana 私钥：base58（64 字节）或 solana-keygen 的 JSON 数组，并校验公钥与种子一致
+func parseSecretKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	var secret []byte
+	if strings.HasPrefix(s, "[") {
+		var nums []byte
+		var arr []int
+		if err := json.Unmarshal([]byte(s), &arr); err != nil {
+			return nil, fmt.Errorf("私钥不是有效的 JSON 数组")
+		}
+		for _, n := range arr {
+			if n < 0 || n > 255 {
+				return nil, fmt.Errorf("私钥不是有效的 JSON 数组")
+			}
+			nums = append(nums, byte(n))
+		}
+		secret = nums
+	} else {
+		b, err := decodeBase58(s)
+		if err != nil {
+			return nil, fmt.Errorf("私钥不是有效的 base58")
+		}
+		secret = b
+	}
+	if len(secret) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("私钥长度为 %d 字节，应为 %d", len(secret), ed25519.PrivateKeySize)
+	}
+	if !bytes.Equal(ed25519.NewKeyFromSeed(secret[:32])[32:], secret[32:]) {
+		return nil, fmt.Errorf("私钥中的公钥与种子不匹配")
+	}
+	return secret, nil
+}
+
+// sealKeyFile 用口令加密私钥，生成加密私钥文件内容
+func sealKeyFile(secret []byte, passphrase string) ([]byte, error) {
+	f := keyFile{Version: keyFileVersion, Address: encodeBase58(secret[32:]), KDF: keyFileKDF, Iterations: keyFileIterations}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := keyFileCipher(passphrase, salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	f.Salt = base64.StdEncoding.EncodeToString(salt)
+	f.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	f.Ciphertext = base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, secret, []byte(f.Address)))
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// openKeyFile 用口令解密加密私钥文件
+func openKeyFile(data []byte, passphrase string) ([]byte, error) {
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("加密私钥文件格式错误: %v", err)
+	}
+	if f.Version != keyFileVersion || f.KDF != keyFileKDF || f.Iterations <= 0 {
+		return nil, fmt.Errorf("不支持的加密私钥文件（version=%d kdf=%s）", f.Version, f.KDF)
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(f.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(f.Nonce)
+	sealed, err3 := base64.StdEncoding.DecodeString(f.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("加密私钥文件格式错误")
+	}
+	gcm, err := keyFileCipher(passphrase, salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("加密私钥文件格式错误")
+	}
+	secret, err := gcm.Open(nil, nonce, sealed, []byte(f.Address))
+	if err != nil {
+		return nil, fmt.Errorf("口令错误或文件已损坏")
+	}
+	if len(secret) != ed25519.PrivateKeySize || !bytes.Equal(ed25519.NewKeyFromSeed(secret[:32])[32:], secret[32:]) {
+		return nil, fmt.Errorf("解密后的私钥无效")
+	}
+	return secret, nil
+}
+
+func keyFileCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cryptoJSDecrypt 解密 encrypt_private_key.ts 生成的密文
+func cryptoJSDecrypt(encoded, password string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) < 16+aes.BlockSize || string(raw[:8]) != "Salted__" || (len(raw)-16)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("不是 encrypt_private_key.ts 生成的密文")
+	}
+	key, iv := evpBytesToKey(password, raw[8:16])
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(raw)-16)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, raw[16:])
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return "", fmt.Errorf("私钥解密失败，请检查密码")
+	}
+	return string(plain[:len(plain)-pad]), nil
+}
+
+// evpBytesToKey OpenSSL EVP_BytesToKey（MD5，1 次迭代）派生 32 字节密钥与 16 字节 IV
+func evpBytesToKey(password string, salt []byte) ([]byte, []byte) {
+	var derived, prev []byte
+	for len(derived) < 48 {
+		h := md5.New()
+		h.Write(prev)
+		h.Write([]byte(password))
+		h.Write(salt)
+		prev = h.Sum(nil)
+		derived = append(derived, prev...)
+	}
+	return derived[:32], derived[32:48]
+}
+
+// configuredKeySources 由本进程读取私钥的钱包（默认钱包为空名）
+func configuredKeySources() map[string]KeySourceConfig {
+	sources := map[string]KeySourceConfig{}
+	if currentConfig().Keys.Default.Type != "" {
+		sources[""] = currentConfig().Keys.Default
+	}
+	for _, w := range currentConfig().Wallets {
+		if w.Key != nil {
+			sources[w.Name] = *w.Key
+		}
+	}
+	return sources
+}
+
+// keyAddressFor 钱包配置的地址（默认钱包为 USER_WALLET_ADDRESS），为空时不核对
+func keyAddressFor(name string) string {
+	if w := findWallet(name); w != nil {
+		return w.Address
+	}
+	return lookupEnv("USER_WALLET_ADDRESS")
+}
+
+// preloadSigningKeys 启动时读取全部配置的私钥，来源不可用或地址不符时返回错误
+func preloadSigningKeys() error {
+	for name, src := range configuredKeySources() {
+		secret, err := signingKey(name, src, keyAddressFor(name))
+		if err != nil {
+			return err
+		}
+		logInfo("🔑 已加载签名私钥", "wallet", walletLabel(name), "source", src.describe(), "address", encodeBase58(secret[32:]))
+	}
+	return nil
+}
+
+// defaultKeyAddress 已加载的默认钱包私钥对应的地址（未通过 keys.default 加载时为空）
+func defaultKeyAddress() string {
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	if c := keyCache[""]; c != nil {
+		return encodeBase58(c.secret[32:])
+	}
+	return ""
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readSecret 从标准输入读取一行；终端输入时关闭回显
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	restore := func() {}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		stty := func(arg string) error {
+			cmd := exec.Command("stty", arg)
+			cmd.Stdin = os.Stdin
+			return cmd.Run()
+		}
+		if stty("-echo") == nil {
+			restore = func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+	line, err := stdinReader.ReadString('\n')
+	restore()
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func cmdKeys(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "用法:\n  %[1]s keys encrypt --out <file> [--passphrase-env <NAME>]\n  %[1]s keys check\n", os.Args[0])
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+	switch args[0] {
+	case "encrypt":
+		cmdKeysEncrypt(args[1:])
+	case "check":
+		cmdKeysCheck(args[1:])
+	default:
+		usage()
+	}
+}
+
+// cmdKeysEncrypt 从标准输入读取私钥与口令，写出加密私钥文件（权限 0600）
+func cmdKeysEncrypt(args []string) {
+	fs := flag.NewFlagSet("keys encrypt", flag.ExitOnError)
+	out := fs.String("out", "", "加密私钥文件的输出路径")
+	passphraseEnv := fs.String("passphrase-env", "", "从该环境变量读取口令（默认交互输入两次）")
+	fs.Parse(args)
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "请用 --out 指定输出路径")
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*out); err == nil {
+		fmt.Fprintf(os.Stderr, "%s 已存在，请先移走或换一个路径\n", *out)
+		os.Exit(1)
+	}
+
+	input, err := readSecret("私钥（base58 或 JSON 数组）: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取私钥失败: %v\n", err)
+		os.Exit(1)
+	}
+	secret, err := parseSecretKey(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var passphrase string
+	if *passphraseEnv != "" {
+		if passphrase = os.Getenv(*passphraseEnv); passphrase == "" {
+			fmt.Fprintf(os.Stderr, "环境变量 %s 未设置\n", *passphraseEnv)
+			os.Exit(1)
+		}
+	} else {
+		passphrase, err = readSecret("口令: ")
+		if err == nil {
+			var confirm string
+			if confirm, err = readSecret("再次输入口令: "); err == nil && confirm != passphrase {
+				err = fmt.Errorf("两次输入的口令不一致")
+			}
+		}
+		if err == nil && len(passphrase) < 8 {
+			err = fmt.Errorf("口令至少 8 个字符")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	data, err := sealKeyFile(secret, passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加密失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeFileAtomic(*out, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "写入失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🔐 已写入 %s（地址 %s）\n", *out, encodeBase58(secret[32:]))
+}
+
+// cmdKeysCheck 读取全部钱包的私钥并核对地址，只输出来源与地址
+func cmdKeysCheck(args []string) {
+	fs, common := newCommandFlags("keys check")
+	fs.Parse(args)
+	loadAppConfig(common)
+
+	type check struct {
+		name string
+		src  KeySourceConfig
+	}
+	var checks []check
+	if currentConfig().Keys.Default.Type != "" {
+		checks = append(checks, check{"", currentConfig().Keys.Default})
+	} else if lookupEnv("PRIVATE_KEY") != "" {
+		checks = append(checks, check{"", KeySourceConfig{
+			Type: keySourceEnv, Env: "PRIVATE_KEY", Encrypted: lookupEnv("PRIVATE_KEY_ENCRYPTED") == "true", PasswordEnv: "PRIVATE_KEY_PASSWORD",
+		}})
+	}
+	for _, w := range currentConfig().Wallets {
+		checks = append(checks, check{w.Name, w.keySource()})
+	}
+	if len(checks) == 0 {
+		fmt.Println("未配置任何私钥")
+		return
+	}
+	failed := false
+	for _, c := range checks {
+		secret, err := signingKey(c.name, c.src, keyAddressFor(c.name))
+		if err != nil {
+			failed = true
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
+		fmt.Printf("✅ %-12s %-32s %s\n", walletLabel(c.name), c.src.describe(), encodeBase58(secret[32:]))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOpenKeyFile(t *testing.T) {
+	secret := []byte(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, 32)))
+	sealed, err := sealKeyFile(secret, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := func(fn func(f *keyFile)) []byte {
+		var f keyFile
+		if err := json.Unmarshal(sealed, &f); err != nil {
+			t.Fatal(err)
+		}
+		fn(&f)
+		b, _ := json.Marshal(f)
+		return b
+	}
+	other := encodeBase58(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, 32))[32:])
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		wantErr    string
+	}{
+		{"口令正确", sealed, "correct horse", ""},
+		{"口令错误", sealed, "wrong", "口令错误"},
+		{"地址被改动", edit(func(f *keyFile) { f.Address = other }), "correct horse", "口令错误"},
+		{"版本不支持", edit(func(f *keyFile) { f.Version = 2 }), "correct horse", "不支持"},
+		{"派生算法不支持", edit(func(f *keyFile) { f.KDF = "scrypt" }), "correct horse", "不支持"},
+		{"迭代次数无效", edit(func(f *keyFile) { f.Iterations = 0 }), "correct horse", "不支持"},
+		{"nonce 长度错误", edit(func(f *keyFile) { f.Nonce = "AAAA" }), "correct horse", "格式错误"},
+		{"密文不是 base64", edit(func(f *keyFile) { f.Ciphertext = "!!" }), "correct horse", "格式错误"},
+		{"不是 JSON", []byte("PRIVATE_KEY=abc"), "correct horse", "格式错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openKeyFile(tt.data, tt.passphrase)
+			if tt.wantErr == "" {
+				if err != nil || !bytes.Equal(got, secret) {
+					t.Errorf("解密结果不一致: err=%v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("应返回包含 %q 的错误，实际 %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCryptoJSDecrypt(t *testing.T) {
+	// 与 CryptoJS.AES.encrypt(text, "hunter2") 相同格式的密文（openssl enc -aes-256-cbc -md md5 -salt 生成）
+	const sealed = "U2FsdGVkX19KlOaqskT73gkH6/QH5nIPKFdfoVzc2uyyPTswUn7bL4svfha7zVqEM8UwTu+idDUkxyr8I/nfle0n78fDY2QDr+Fg57sZaR8="
+	const plain = "5Kd3NBUAdUnhyzenEwVLy9pBKxSwXvE9FMPyR4UKZvpe6E3AgLr"
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     string
+		wantErr  bool
+	}{
+		{"密码正确", sealed, "hunter2", plain, false},
+		{"前后空白", "  " + sealed + "\n", "hunter2", plain, false},
+		{"密码错误", sealed, "hunter3", "", true},
+		{"不是 base64", "not base64!", "hunter2", "", true},
+		{"缺少 Salted__ 头", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", "hunter2", "", true},
+		{"明文私钥", plain, "hunter2", "", true},
+		{"长度不是块大小的整数倍", sealed[:len(sealed)-8], "hunter2", "", true},
+	}
+	for _, tt := range tests {
+		got, err := cryptoJSDecrypt(tt.encoded, tt.password)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: got=%q err=%v", tt.name, got, err)
+		}
+	}
+}
+
+func TestAttachSigningKeyPipe(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, 64)
+	cmd := exec.Command("sh", "-c", `cat <&"$PRIVATE_KEY_FD"; echo; env`)
+	cmd.Env = []string{"PATH=/usr/bin:/bin", "PRIVATE_KEY=inherited", "PRIVATE_KEY_PASSWORD=inherited"}
+	closeKey, err := attachSigningKey(cmd, ScriptSpec{}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	closeKey()
+	if err != nil {
+		t.Fatalf("子进程失败: %v", err)
+	}
+	key, env, _ := strings.Cut(string(out), "\n")
+	if key != encodeBase58(secret) {
+		t.Errorf("管道中的私钥 %q，应为 %q", key, encodeBase58(secret))
+	}
+	if !strings.Contains(env, "PRIVATE_KEY_FD=3") {
+		t.Errorf("缺少 PRIVATE_KEY_FD=3: %s", env)
+	}
+	if strings.Contains(env, encodeBase58(secret)) || strings.Contains(env, "inherited") {
+		t.Errorf("私钥或继承的 PRIVATE_KEY* 出现在子进程环境中: %s", env)
+	}
+}
+
+func TestAttachSigningKeyEnv(t *testing.T) {
+	secret := bytes.Repeat([]byte{9}, 64)
+	cmd := exec.Command("true")
+	cmd.Env = []string{"PRIVATE_KEY_ENCRYPTED=true"}
+	closeKey, err := attachSigningKey(cmd, ScriptSpec{KeyEnv: "PRIVATE_KEY"}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeKey()
+	if len(cmd.ExtraFiles) != 0 || len(cmd.Env) != 1 || cmd.Env[0] != "PRIVATE_KEY="+encodeBase58(secret) {
+		t.Errorf("keyEnv 应只经该变量传入: env=%v extra=%d", cmd.Env, len(cmd.ExtraFiles))
+	}
+
+	// 未经 keys 读取私钥时不改动环境
+	plain := exec.Command("true")
+	plain.Env = []string{"PRIVATE_KEY=from-dotenv"}
+	if _, err := attachSigningKey(plain, ScriptSpec{}, nil); err != nil || len(plain.Env) != 1 || plain.ExtraFiles != nil {
+		t.Errorf("secret 为 nil 时不应改动: env=%v err=%v", plain.Env, err)
+	}
+}
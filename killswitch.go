@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// KillSwitchConfig 全局停止开关：开启后不再新增流动性（开仓、复投、再平衡）与兑换，领取、平仓、价格获取与监控照常运行。
+// 可由文件、HTTP 接口（POST /kill-switch）或信号开启；与交易时段一起在任务队列调度时统一检查
+type KillSwitchConfig struct {
+	File   string `json:"file"`   // 该文件存在时开启，删除后关闭；为空表示不检查
+	Signal string `json:"signal"` // 收到该信号时切换开关（SIGUSR1 / SIGUSR2）；为空表示不监听
+}
+
+// KillSwitchState 手动开关状态（data/state/kill_switch.json），重启后保持
+type KillSwitchState struct {
+	Active    bool   `json:"active"`
+	Reason    string `json:"reason,omitempty"`
+	Source    string `json:"source,omitempty"` // api / signal
+	ChangedAt string `json:"changedAt,omitempty"`
+}
+
+// KillSwitchStatus 停止开关与交易时段的当前状态（GET /kill-switch）
+type KillSwitchStatus struct {
+	Halted          bool            `json:"halted"` // 开仓与兑换是否停止
+	Manual          KillSwitchState `json:"manual"`
+	File            string          `json:"file,omitempty"`
+	FileActive      bool            `json:"fileActive"`
+	InTradingWindow bool            `json:"inTradingWindow"` // 当前是否允许开仓（tradingWindows / blackoutWindows）
+}
+
+// 开关来源
+const (
+	killSwitchSourceAPI    = "api"
+	killSwitchSourceSignal = "signal"
+	killSwitchSourceFile   = "file"
+)
+
+// 文件开关的检查间隔
+const killSwitchPollInterval = 5 * time.Second
+
+var killSwitchSignals = map[string]syscall.Signal{
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+var (
+	killSwitchMutex  sync.Mutex
+	killSwitchManual atomic.Bool
+	killSwitchFileOn atomic.Bool // 上次检查时文件是否存在
+)
+
+func (c KillSwitchConfig) validate() error {
+	if c.Signal != "" && killSwitchSignals[c.Signal] == 0 {
+		return fmt.Errorf("killSwitch.signal 仅支持 SIGUSR1、SIGUSR2: %s", c.Signal)
+	}
+	return nil
+}
+
+// 启动时恢复手动开关状态
+func loadKillSwitchState() {
+	var st KillSwitchState
+	if err := loadStateFile("kill_switch", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	killSwitchManual.Store(st.Active)
+	killSwitchFileOn.Store(killSwitchFilePresent())
+	if st.Active || killSwitchFileOn.Load() {
+		logWarn("🛑 停止开关已开启，不开仓、不兑换", "reason", st.Reason, "file", killSwitchFileOn.Load())
+	}
+}
+
+func killSwitchFilePresent() bool {
+	if currentConfig().KillSwitch.File == "" {
+		return false
+	}
+	_, err := os.Stat(currentConfig().KillSwitch.File)
+	return err == nil
+}
+
+// killSwitchActive 停止开关是否开启（手动或文件）
+func killSwitchActive() bool {
+	return killSwitchManual.Load() || killSwitchFileOn.Load()
+}
+
+// tradingHalt 该类型的任务当前是否停止执行，返回跳过原因与说明（允许时为空）：
+// 停止开关开启时开仓、兑换、复投均停止；交易时段之外只停止开仓（研究模式不开仓，不检查）
+func tradingHalt(jobType string) (string, string) {
+	if jobType != jobAddLiquidity && jobType != jobSwap && jobType != jobCompound {
+		return "", ""
+	}
+	if killSwitchActive() {
+		return skipKillSwitch, "停止开关已开启"
+	}
+	if jobType == jobAddLiquidity && !isPriceOnly() && !inTradingWindow() {
+		return skipOutsideWindow, "不在交易时段: " + appNow().Format("2006-01-02 15:04 MST")
+	}
+	return "", ""
+}
+
+// setKillSwitch 手动开启 / 关闭停止开关
+func setKillSwitch(active bool, reason, source string) KillSwitchStatus {
+	killSwitchMutex.Lock()
+	st := KillSwitchState{Active: active, Reason: reason, Source: source, ChangedAt: time.Now().Format(time.RFC3339)}
+	changed := killSwitchManual.Swap(active) != active
+	if err := saveStateFile("kill_switch", st); err != nil {
+		logOutput("❌ 保存停止开关状态失败: %v\n", err)
+	}
+	killSwitchMutex.Unlock()
+	if changed {
+		noteKillSwitch(active, reason, source)
+	}
+	return currentKillSwitch()
+}
+
+// noteKillSwitch 开关变化时记录并告警
+func noteKillSwitch(active bool, reason, source string) {
+	fields := map[string]string{"source": source, "reason": reason}
+	if active {
+		logWarn("🛑 停止开关已开启，停止开仓与兑换（领取与监控照常）", "source", source, "reason", reason)
+		notify(eventKillSwitch, levelCritical, "停止开关已开启", "停止开仓与兑换，领取、平仓与监控照常运行："+reason, fields)
+		return
+	}
+	if killSwitchActive() {
+		logWarn("⚠️ 停止开关的一个来源已关闭，另一来源仍开启", "source", source)
+		return
+	}
+	logInfo("▶️ 停止开关已关闭，恢复开仓与兑换", "source", source)
+	notify(eventKillSwitch, levelInfo, "停止开关已关闭", "恢复开仓与兑换", fields)
+}
+
+// currentKillSwitch 当前状态
+func currentKillSwitch() KillSwitchStatus {
+	killSwitchMutex.Lock()
+	var st KillSwitchState
+	if err := loadStateFile("kill_switch", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	killSwitchMutex.Unlock()
+	return KillSwitchStatus{
+		Halted:          killSwitchActive(),
+		Manual:          st,
+		File:            currentConfig().KillSwitch.File,
+		FileActive:      killSwitchFileOn.Load(),
+		InTradingWindow: inTradingWindow(),
+	}
+}
+
+// startKillSwitchWatcher 定期检查开关文件，并按配置监听切换信号
+func startKillSwitchWatcher() {
+	sigChan := make(chan os.Signal, 1)
+	if sig := killSwitchSignals[currentConfig().KillSwitch.Signal]; sig != 0 {
+		signal.Notify(sigChan, sig)
+		defer signal.Stop(sigChan)
+		logOutput("🛑 停止开关：收到 %s 时切换\n", currentConfig().KillSwitch.Signal)
+	}
+	ticker := time.NewTicker(killSwitchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case sig := <-sigChan:
+			active := !killSwitchManual.Load()
+			setKillSwitch(active, "收到信号 "+sig.String(), killSwitchSourceSignal)
+		case <-ticker.C:
+			present := killSwitchFilePresent()
+			if killSwitchFileOn.Swap(present) != present {
+				noteKillSwitch(present, "开关文件 "+currentConfig().KillSwitch.File, killSwitchSourceFile)
+			}
+		}
+	}
+}
+
+// haltOutcome 跳过原因对应的池文件处理结果
+func haltOutcome(reason string) string {
+	if reason == skipKillSwitch {
+		return outcomeKillSwitch
+	}
+	return outcomeOutsideWindow
+}
+
+// skipPoolFile 调度时不允许开仓的池文件：只记录信号，标记处理结果
+func skipPoolFile(path, reason, detail string) {
+	var pool, ca string
+	if content, err := os.ReadFile(path); err == nil {
+		var data ProfitData
+		if json.Unmarshal(content, &data) == nil {
+			pool = data.PoolAddress
+			ca, _ = data.Data["ca"].(string)
+		}
+	}
+	outcome := haltOutcome(reason)
+	logOutput("🌙 %s，跳过开仓: %s\n", detail, path)
+	recordSkip(subsystemEntry, reason, pool, ca, detail)
+	if pool != "" {
+		notePoolOutcome(pool, outcome)
+	}
+	markProcessed(path, outcome)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LadderConfig 阶梯仓位：同一信号在同一池按不同 bin 宽度开多个仓位，作为仓位组统一核算与平仓
+type LadderConfig struct {
+	Enabled         bool        `json:"enabled"`
+	Legs            []LadderLeg `json:"legs"`            // 第一个档位为主仓位（顶层 positionAddress），其余为附加档位
+	TakeProfitRatio float64     `json:"takeProfitRatio"` // 组价值 ≥ 投入 SOL × 该倍数时整组平仓（0 表示不按组止盈）
+}
+
+// LadderLeg 单个档位
+type LadderLeg struct {
+	Name       string  `json:"name"`
+	RangeScale float64 `json:"rangeScale"` // 相对默认 bin 宽度的倍数，如 0.5 为窄档、2 为宽档
+	SolAmount  float64 `json:"solAmount"`  // 该档位投入的 SOL
+}
+
+// PositionLeg 仓位组中的一个仓位
+type PositionLeg struct {
+	Name       string  `json:"name"`
+	Position   string  `json:"position"`
+	RangeScale float64 `json:"rangeScale"`
+	SolAmount  float64 `json:"solAmount"`
+	OpenedAt   string  `json:"openedAt"`
+	ClosedAt   string  `json:"closedAt,omitempty"`
+	ValueUSD   float64 `json:"valueUSD"` // 最近一次领取时的 累计已领取 + 当前仓位 + 未领取费用（USD）
+	UpdatedAt  string  `json:"updatedAt,omitempty"`
+}
+
+// PositionGroup 仓位组（data/state/position_groups.json: pool -> 组）
+type PositionGroup struct {
+	PoolAddress  string         `json:"poolAddress"`
+	TokenAddress string         `json:"ca,omitempty"`
+	OpenedAt     string         `json:"openedAt"`
+	ClosedAt     string         `json:"closedAt,omitempty"`
+	SolUSD       float64        `json:"solUSD"` // 最近一次领取时 1 SOL 的 USD 价格
+	Legs         []*PositionLeg `json:"legs"`
+}
+
+// GroupSummary 对外输出的仓位组与组级盈亏
+type GroupSummary struct {
+	*PositionGroup
+	CostSOL    float64 `json:"costSOL"`
+	ValueSOL   float64 `json:"valueSOL"`
+	PnLSOL     float64 `json:"pnlSOL"`
+	PnLPercent float64 `json:"pnlPercent"`
+}
+
+var positionGroupMutex sync.Mutex
+
+// 主仓位档位名（未配置档位名时使用）
+const primaryLegName = "main"
+
+func ladderEnabled() bool {
+	ladder := currentConfig().Ladder
+	return ladder.Enabled && len(ladder.Legs) > 0
+}
+
+func (l LadderLeg) name(i int) string {
+	if l.Name != "" {
+		return l.Name
+	}
+	if i == 0 {
+		return primaryLegName
+	}
+	return fmt.Sprintf("leg%d", i)
+}
+
+// 档位对应的 addLiquidity.ts 参数（主仓位不传 --leg）
+func (l LadderLeg) args(i int) []string {
+	args := []string{fmt.Sprintf("--sol-amount=%s", strconv.FormatFloat(l.SolAmount, 'f', -1, 64))}
+	if l.RangeScale > 0 && l.RangeScale != 1 {
+		args = append(args, fmt.Sprintf("--range-scale=%s", strconv.FormatFloat(l.RangeScale, 'f', -1, 64)))
+	}
+	if i > 0 {
+		args = append(args, fmt.Sprintf("--leg=%s", l.name(i)))
+	}
+	return args
+}
+
+func (c LadderConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Legs) == 0 {
+		return fmt.Errorf("ladder.legs 不能为空")
+	}
+	seen := map[string]bool{}
+	for i, leg := range c.Legs {
+		name := leg.name(i)
+		if seen[name] {
+			return fmt.Errorf("ladder.legs 档位名重复: %s", name)
+		}
+		seen[name] = true
+		if strings.ContainsAny(name, " /\\.=") {
+			return fmt.Errorf("ladder.legs 档位名包含非法字符: %s", name)
+		}
+		if leg.SolAmount <= 0 {
+			return fmt.Errorf("ladder.legs.%s 的 solAmount 必须大于0", name)
+		}
+		if leg.RangeScale < 0 {
+			return fmt.Errorf("ladder.legs.%s 的 rangeScale 不能为负数", name)
+		}
+	}
+	if c.TakeProfitRatio < 0 {
+		return fmt.Errorf("ladder.takeProfitRatio 不能为负数")
+	}
+	return nil
+}
+
+func loadPositionGroups() map[string]*PositionGroup {
+	groups := map[string]*PositionGroup{}
+	if err := loadStateFile("position_groups", &groups); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return groups
+}
+
+// 修改仓位组状态（读-改-写）
+func updatePositionGroups(fn func(groups map[string]*PositionGroup)) {
+	positionGroupMutex.Lock()
+	defer positionGroupMutex.Unlock()
+	groups := loadPositionGroups()
+	fn(groups)
+	if err := saveStateFile("position_groups", groups); err != nil {
+		logOutput("❌ 保存仓位组状态失败: %v\n", err)
+	}
+}
+
+// 池当前未平仓的仓位组
+func openPositionGroup(poolAddress string) *PositionGroup {
+	positionGroupMutex.Lock()
+	defer positionGroupMutex.Unlock()
+	if g, ok := loadPositionGroups()[poolAddress]; ok && g.ClosedAt == "" {
+		return g
+	}
+	return nil
+}
+
+// 组级盈亏（SOL 计价，使用最近一次领取时的 SOL 价格）
+func (g *PositionGroup) summary() GroupSummary {
+	s := GroupSummary{PositionGroup: g}
+	var valueUSD float64
+	for _, leg := range g.Legs {
+		s.CostSOL += leg.SolAmount
+		valueUSD += leg.ValueUSD
+	}
+	if g.SolUSD > 0 {
+		s.ValueSOL = valueUSD / g.SolUSD
+		s.PnLSOL = s.ValueSOL - s.CostSOL
+		if s.CostSOL > 0 {
+			s.PnLPercent = s.PnLSOL / s.CostSOL * 100
+		}
+	}
+	return s
+}
+
+// 所有仓位组（按开仓时间倒序）
+func listPositionGroups() []GroupSummary {
+	positionGroupMutex.Lock()
+	groups := loadPositionGroups()
+	positionGroupMutex.Unlock()
+	result := make([]GroupSummary, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g.summary())
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].OpenedAt > result[b].OpenedAt })
+	return result
+}
+
+// 从 data/<pool>.json 读取附加档位的仓位地址（legs.<name>）
+func readLegPositionFromPoolJSON(poolAddress, leg string) string {
+	bytes, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json"))
+	if err != nil {
+		return ""
+	}
+	var obj struct {
+		Legs map[string]string `json:"legs"`
+	}
+	if err := json.Unmarshal(bytes, &obj); err != nil {
+		return ""
+	}
+	return obj.Legs[leg]
+}
+
+// 主仓位开仓成功后依次开附加档位，并记录仓位组
+func openLadderLegs(poolAddress, ca string, baseArgs []string) {
+	legs := currentConfig().Ladder.Legs
+	dex := poolDex(poolAddress)
+	if isPaperPool(poolAddress) {
+		for i := 1; i < len(legs); i++ {
+			simulatePoolAction(poolAddress, "addLiquidity", dex.CommandLine(dexOpAddLiquidity, append(append([]string{}, baseArgs...), legs[i].args(i)...)...))
+			recordPnLDeposit(poolAddress, ca, "", legs[i].SolAmount)
+		}
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	group := &PositionGroup{PoolAddress: poolAddress, TokenAddress: ca, OpenedAt: now}
+	group.Legs = append(group.Legs, &PositionLeg{
+		Name: legs[0].name(0), Position: readPositionFromPoolJSON(poolAddress),
+		RangeScale: legs[0].RangeScale, SolAmount: legs[0].SolAmount, OpenedAt: now,
+	})
+
+	for i := 1; i < len(legs); i++ {
+		name := legs[i].name(i)
+		if !rateGuardAllow(rateOpen) {
+			logOutput("🛑 超出速率上限，停止开阶梯档位: pool=%s leg=%s\n", poolAddress, name)
+			recordSkip(subsystemEntry, skipQuota, poolAddress, ca, "速率保护，阶梯档位 "+name)
+			break
+		}
+		args := append(append([]string{}, baseArgs...), legs[i].args(i)...)
+		logOutput("🪜 开阶梯档位 %s: %s\n", name, strings.Join(dex.CommandLine(dexOpAddLiquidity, args...), " "))
+		ctx, cancel := context.WithTimeout(globalCtx, 5*time.Minute)
+		output, err := dex.AddLiquidity(withPoolWallet(ctx, poolAddress), args...)
+		cancel()
+		metricAddLiquidity.Inc(resultLabel(err))
+		logCommandOutput(output)
+
+		position := readLegPositionFromPoolJSON(poolAddress, name)
+		if err != nil || position == "" {
+			logError("❌ 阶梯档位开仓失败", "pool", poolAddress, "leg", name, "error", err)
+			msg := "未写入档位仓位地址"
+			if err != nil {
+				msg = err.Error()
+			}
+			notifyKeyed(eventAddLiquidityFailure, levelWarning, poolAddress+"|"+name, "阶梯档位开仓失败", msg, map[string]string{"pool": poolAddress, "leg": name})
+			publishError(subsystemEntry, poolAddress, ca, fmt.Errorf("%s", msg), map[string]string{"target": "addLiquidity", "leg": name})
+			continue
+		}
+		group.Legs = append(group.Legs, &PositionLeg{
+			Name: name, Position: position, RangeScale: legs[i].RangeScale,
+			SolAmount: legs[i].SolAmount, OpenedAt: time.Now().Format(time.RFC3339),
+		})
+		recordPnLDeposit(poolAddress, ca, position, legs[i].SolAmount)
+		recordRateEvent(rateOpen, legs[i].SolAmount)
+		logInfo("✅ 阶梯档位开仓成功", "pool", poolAddress, "leg", name, "position", position)
+		publishEvent(BusEvent{Type: busLiquidityAdded, Stage: subsystemEntry, Pool: poolAddress, Token: ca, Detail: "阶梯档位 " + name,
+			Fields: map[string]string{"solAmount": formatFloat(legs[i].SolAmount), "position": position, "leg": name}})
+	}
+
+	updatePositionGroups(func(groups map[string]*PositionGroup) { groups[poolAddress] = group })
+	logOutput("🪜 仓位组已建立: pool=%s 档位数=%d\n", poolAddress, len(group.Legs))
+}
+
+// 从 claimAllRewards.ts 输出解析仓位价值与 SOL 价格
+func parseClaimValues(output string) (valueUSD, solUSD float64, ok bool) {
+	o := decodeScriptOutput([]byte(output))
+	valueUSD, gotValue := o.Value("positionValueUSD", "累计已领取USD + 当前positionUSD + 未领取费用USD:")
+	solUSD, gotSol := o.Value("solUSD", "1 SOL 的USD价格:")
+	return valueUSD, solUSD, gotValue && gotSol
+}
+
+// 记录档位最新价值
+func recordLegValue(poolAddress, position, output string) {
+	valueUSD, solUSD, ok := parseClaimValues(output)
+	if !ok {
+		return
+	}
+	updatePositionGroups(func(groups map[string]*PositionGroup) {
+		g, exists := groups[poolAddress]
+		if !exists || g.ClosedAt != "" {
+			return
+		}
+		for _, leg := range g.Legs {
+			if leg.Position == position {
+				leg.ValueUSD = valueUSD
+				leg.UpdatedAt = time.Now().Format(time.RFC3339)
+				g.SolUSD = solUSD
+			}
+		}
+	})
+}
+
+// 领取附加档位奖励，并按组级价值判断是否整组平仓
+func claimLadderLegs(poolAddress string) {
+	group := openPositionGroup(poolAddress)
+	if group == nil {
+		return
+	}
+	dex := poolDex(poolAddress)
+	for _, leg := range group.Legs[1:] {
+		if leg.ClosedAt != "" || leg.Position == "" {
+			continue
+		}
+		args := []string{
+			fmt.Sprintf("--pool=%s", poolAddress),
+			fmt.Sprintf("--position=%s", leg.Position),
+			"--no-auto-exit",
+		}
+		args = append(args, claimPolicyArgs(poolAddress, leg.Position)...)
+		args = append(args, priorityFeeArgs(feeOpClaim)...)
+		logOutput("▶️  领取阶梯档位奖励 %s: %s\n", leg.Name, strings.Join(dex.CommandLine(dexOpClaim, args...), " "))
+		out, err := dex.Claim(withPoolWallet(context.Background(), poolAddress), args...)
+		metricClaims.Inc(resultLabel(err))
+		noteClaimOutput(poolAddress, out, err)
+		noteClaimCheck(poolAddress, leg.Position, out, err)
+		recordClaimResult(poolAddress, leg.Position, out, err)
+		noteClaimActivity(poolAddress)
+		logCommandOutput(out)
+		if err != nil {
+			logError("❌ 阶梯档位领取奖励失败", "pool", poolAddress, "leg", leg.Name, "error", err)
+			continue
+		}
+		recordLegValue(poolAddress, leg.Position, string(out))
+		recordPnLClaim(poolAddress, leg.Position, string(out))
+	}
+
+	ratio := currentConfig().Ladder.TakeProfitRatio
+	if ratio <= 0 {
+		return
+	}
+	if group = openPositionGroup(poolAddress); group == nil {
+		return
+	}
+	s := group.summary()
+	if s.ValueSOL <= 0 || s.ValueSOL < s.CostSOL*ratio {
+		return
+	}
+	logOutput("✅ 仓位组价值 %.4f SOL ≥ 投入 %.4f SOL × %g，整组平仓: pool=%s\n", s.ValueSOL, s.CostSOL, ratio, poolAddress)
+	claimAndClosePosition(poolAddress, exitReasonGroupTarget)
+}
+
+// 平掉附加档位（保留池 JSON、不 swap，由主仓位或调用方负责收尾），全部成功返回 true
+func closeLadderLegs(poolAddress string) bool {
+	group := openPositionGroup(poolAddress)
+	if group == nil {
+		return true
+	}
+	allClosed := true
+	for _, leg := range group.Legs[1:] {
+		if leg.ClosedAt != "" || leg.Position == "" {
+			continue
+		}
+		logOutput("🚪 平仓阶梯档位 %s: pool=%s position=%s\n", leg.Name, poolAddress, leg.Position)
+		if !runRemoveLiquidity(poolAddress, leg.Position, "--skipSwap", "--keep-json") {
+			allClosed = false
+			continue
+		}
+		markLegClosed(poolAddress, leg.Position)
+	}
+	return allClosed
+}
+
+func markLegClosed(poolAddress, position string) {
+	updatePositionGroups(func(groups map[string]*PositionGroup) {
+		if g, ok := groups[poolAddress]; ok {
+			for _, leg := range g.Legs {
+				if leg.Position == position && leg.ClosedAt == "" {
+					leg.ClosedAt = time.Now().Format(time.RFC3339)
+				}
+			}
+		}
+	})
+}
+
+// 主仓位平仓后关闭仓位组
+func markGroupClosed(poolAddress string) {
+	updatePositionGroups(func(groups map[string]*PositionGroup) {
+		g, ok := groups[poolAddress]
+		if !ok || g.ClosedAt != "" {
+			return
+		}
+		now := time.Now().Format(time.RFC3339)
+		for _, leg := range g.Legs {
+			if leg.ClosedAt == "" {
+				leg.ClosedAt = now
+			}
+		}
+		g.ClosedAt = now
+		s := g.summary()
+		logOutput("🪜 仓位组已平仓: pool=%s 投入 %.4f SOL, 组价值 %.4f SOL, 盈亏 %.4f SOL (%.2f%%)\n",
+			poolAddress, s.CostSOL, s.ValueSOL, s.PnLSOL, s.PnLPercent)
+	})
+}
+
+// 主仓位被脚本自行平仓（池 JSON 已归档）后，清理残留的附加档位
+func sweepOrphanLadderLegs() {
+	positionGroupMutex.Lock()
+	groups := loadPositionGroups()
+	positionGroupMutex.Unlock()
+	for poolAddress, g := range groups {
+		if g.ClosedAt != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(poolDataDir(), poolAddress+".json")); !os.IsNotExist(err) {
+			continue
+		}
+		logOutput("🧹 主仓位已平仓，清理残留阶梯档位: pool=%s\n", poolAddress)
+		if !closeLadderLegs(poolAddress) {
+			continue
+		}
+		if g.TokenAddress != "" {
+			executeJupSwapForToken(poolWallet(poolAddress), g.TokenAddress)
+		}
+		markGroupClosed(poolAddress)
+	}
+}
+
+// 仓位组中未平仓的档位数
+func openLegCount(poolAddress string) int {
+	group := openPositionGroup(poolAddress)
+	if group == nil {
+		return 0
+	}
+	n := 0
+	for _, leg := range group.Legs {
+		if leg.ClosedAt == "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,857 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 主备部署：多台服务器运行同一配置，通过租约选出唯一的主实例执行交易；备用实例不启动任何子系统，
+// 定期从主实例拉取状态快照（状态目录、黑名单与池文件），租约过期后接管。
+// 租约后端：file（共享目录中的租约文件）、redis（SET NX PX）、etcd（v3 JSON 网关的租约与事务）
+
+const (
+	leaderBackendFile  = "file"
+	leaderBackendRedis = "redis"
+	leaderBackendEtcd  = "etcd"
+)
+
+// LeaderConfig 主备选举（不支持热更新）
+type LeaderConfig struct {
+	Enabled      bool              `json:"enabled"`
+	Backend      string            `json:"backend"`      // file / redis / etcd
+	Key          string            `json:"key"`          // 租约键（redis / etcd），同一组实例必须一致
+	InstanceID   string            `json:"instanceId"`   // 为空时使用 <instance>-<PID>
+	LeaseSeconds int               `json:"leaseSeconds"` // 租约时长：主实例停止续约后，备用实例最多等待这么久接管
+	RenewSeconds int               `json:"renewSeconds"` // 续约（主实例）与竞选（备用实例）的间隔
+	AdvertiseURL string            `json:"advertiseUrl"` // 本实例管理接口对其他实例可达的地址（如 http://10.0.0.1:8080），备用实例据此拉取状态；为空时不同步（共享存储时）
+	SyncSeconds  int               `json:"syncSeconds"`  // 备用实例拉取状态快照的间隔
+	File         string            `json:"file"`         // file 后端的租约文件，须位于各实例共享的目录（如 NFS）
+	Redis        LeaderRedisConfig `json:"redis"`
+	Etcd         LeaderEtcdConfig  `json:"etcd"`
+}
+
+type LeaderRedisConfig struct {
+	Addr     string `json:"addr"` // host:port
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+type LeaderEtcdConfig struct {
+	Endpoints []string `json:"endpoints"` // 如 http://10.0.0.5:2379，依次尝试
+}
+
+func (c LeaderConfig) validate(api APIConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Backend {
+	case leaderBackendFile:
+		if c.File == "" {
+			return fmt.Errorf("leader.file 不能为空")
+		}
+	case leaderBackendRedis:
+		if c.Redis.Addr == "" {
+			return fmt.Errorf("leader.redis.addr 不能为空")
+		}
+	case leaderBackendEtcd:
+		if len(c.Etcd.Endpoints) == 0 {
+			return fmt.Errorf("leader.etcd.endpoints 不能为空")
+		}
+		for _, ep := range c.Etcd.Endpoints {
+			if u, err := url.Parse(ep); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("leader.etcd.endpoints 不是有效的 http(s) 地址: %s", ep)
+			}
+		}
+	default:
+		return fmt.Errorf("leader.backend 仅支持 %s、%s 或 %s", leaderBackendFile, leaderBackendRedis, leaderBackendEtcd)
+	}
+	if c.Key == "" {
+		return fmt.Errorf("leader.key 不能为空")
+	}
+	if c.RenewSeconds <= 0 || c.LeaseSeconds < 2*c.RenewSeconds {
+		return fmt.Errorf("leader.renewSeconds 必须大于0，且 leader.leaseSeconds 至少为其两倍")
+	}
+	if c.SyncSeconds <= 0 {
+		return fmt.Errorf("leader.syncSeconds 必须大于0")
+	}
+	if c.AdvertiseURL != "" {
+		if u, err := url.Parse(c.AdvertiseURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("leader.advertiseUrl 不是有效的 http(s) 地址")
+		}
+		if !api.Enabled {
+			return fmt.Errorf("leader.advertiseUrl 需要启用 api（备用实例通过管理接口拉取状态）")
+		}
+	}
+	return nil
+}
+
+// leaderRecord 租约中记录的持有者
+type leaderRecord struct {
+	ID    string `json:"id"`
+	API   string `json:"api,omitempty"`
+	Since string `json:"since"`
+}
+
+// leaderBackend 租约后端
+type leaderBackend interface {
+	// campaign 租约空闲或已过期时取得、已由 self 持有时续约，返回当前持有者及是否为 self
+	campaign(ctx context.Context, self leaderRecord, ttl time.Duration) (leaderRecord, bool, error)
+	// resign 释放 self 持有的租约（已被他人持有时不做任何事）
+	resign(ctx context.Context, self leaderRecord) error
+}
+
+// LeaderStatus 主备状态（GET /leader）
+type LeaderStatus struct {
+	Enabled        bool          `json:"enabled"`
+	Backend        string        `json:"backend,omitempty"`
+	InstanceID     string        `json:"instanceId,omitempty"`
+	Role           string        `json:"role"` // leader / standby
+	Leader         *leaderRecord `json:"leader,omitempty"`
+	LeaseExpiresAt string        `json:"leaseExpiresAt,omitempty"` // 本实例为主时，按本地时钟的租约到期时间
+	LastSyncAt     string        `json:"lastSyncAt,omitempty"`
+	LastSyncFiles  int           `json:"lastSyncFiles,omitempty"` // 上次同步写入或删除的文件数
+	LastError      string        `json:"lastError,omitempty"`
+}
+
+const (
+	leaderRoleLeader  = "leader"
+	leaderRoleStandby = "standby"
+)
+
+var errNotLeader = errors.New("本实例不是主实例，拒绝执行交易")
+
+var (
+	leaderElecting atomic.Bool  // runDaemon 启用了主备选举
+	leaderHeld     atomic.Bool  // 本实例持有租约
+	leaderDeadline atomic.Int64 // 租约按本地时钟的到期时间（UnixNano），续约成功时刷新
+	leaderStop     = make(chan struct{})
+
+	leaderMutex  sync.Mutex
+	leaderState  = LeaderStatus{Role: leaderRoleLeader}
+	leaderSelf   leaderRecord
+	leaderClient leaderBackend
+)
+
+var leaderHTTP = &http.Client{Timeout: 30 * time.Second}
+
+// isLeader 是否允许执行交易：未启用选举（含一次性子命令）时总是允许，否则须持有未过期的租约
+func isLeader() bool {
+	if !leaderElecting.Load() {
+		return true
+	}
+	return leaderHeld.Load() && time.Now().UnixNano() < leaderDeadline.Load()
+}
+
+func currentLeaderStatus() LeaderStatus {
+	leaderMutex.Lock()
+	defer leaderMutex.Unlock()
+	st := leaderState
+	st.Enabled = leaderElecting.Load()
+	if st.Leader != nil {
+		holder := *st.Leader
+		st.Leader = &holder
+	}
+	return st
+}
+
+func updateLeaderStatus(fn func(*LeaderStatus)) {
+	leaderMutex.Lock()
+	defer leaderMutex.Unlock()
+	fn(&leaderState)
+}
+
+func newLeaderBackend(cfg LeaderConfig) leaderBackend {
+	switch cfg.Backend {
+	case leaderBackendRedis:
+		return &redisLeaderBackend{cfg: cfg.Redis, key: cfg.Key}
+	case leaderBackendEtcd:
+		return &etcdLeaderBackend{cfg: cfg.Etcd, key: cfg.Key}
+	}
+	return &fileLeaderBackend{path: resolvePath(cfg.File)}
+}
+
+func leaderInstanceID(cfg LeaderConfig) string {
+	if cfg.InstanceID != "" {
+		return cfg.InstanceID
+	}
+	name := deployInstance
+	if name == "" {
+		name = "meteora"
+	}
+	return name + "-" + strconv.Itoa(os.Getpid())
+}
+
+// campaignLeader 竞选或续约一次，成功时刷新租约到期时间（从发起请求时算起）
+func campaignLeader(ttl time.Duration) (leaderRecord, bool, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), ttl/2)
+	defer cancel()
+	holder, held, err := leaderClient.campaign(ctx, leaderSelf, ttl)
+	if err != nil {
+		updateLeaderStatus(func(s *LeaderStatus) { s.LastError = err.Error() })
+		return holder, false, err
+	}
+	if held {
+		leaderDeadline.Store(start.Add(ttl).UnixNano())
+	}
+	updateLeaderStatus(func(s *LeaderStatus) {
+		s.LastError = ""
+		s.Leader = &holder
+		if held {
+			s.LeaseExpiresAt = start.Add(ttl).Format(time.RFC3339)
+		}
+	})
+	return holder, held, nil
+}
+
+// waitForLeadership 在 runDaemon 启动各子系统之前调用：取得租约前作为备用实例等待（只提供 /leader、指标与健康检查，按间隔同步主实例的状态），
+// 取得租约后重新加载同步来的状态并在后台续约，返回 true；等待期间收到关闭信号时返回 false
+func waitForLeadership() bool {
+	cfg := currentConfig().Leader
+	leaderClient = newLeaderBackend(cfg)
+	leaderSelf = leaderRecord{ID: leaderInstanceID(cfg), API: strings.TrimRight(cfg.AdvertiseURL, "/"), Since: time.Now().Format(time.RFC3339)}
+	leaderElecting.Store(true)
+	updateLeaderStatus(func(s *LeaderStatus) {
+		s.Backend, s.InstanceID, s.Role = cfg.Backend, leaderSelf.ID, leaderRoleStandby
+	})
+	ttl := time.Duration(cfg.LeaseSeconds) * time.Second
+	renew := time.Duration(cfg.RenewSeconds) * time.Second
+	syncEvery := time.Duration(cfg.SyncSeconds) * time.Second
+
+	stopStandbyAPI := startStandbyAPI()
+	defer stopStandbyAPI()
+
+	var lastSync time.Time
+	synced, announced, lastErr := false, "", ""
+	for {
+		holder, held, err := campaignLeader(ttl)
+		switch {
+		case err != nil:
+			if err.Error() != lastErr {
+				logWarn("⚠️ 主备租约竞选失败", "backend", cfg.Backend, "error", err)
+			}
+			lastErr = err.Error()
+		case held:
+			leaderHeld.Store(true)
+			updateLeaderStatus(func(s *LeaderStatus) { s.Role = leaderRoleLeader })
+			if synced {
+				reloadSyncedState()
+			}
+			logInfo("👑 已成为主实例", "instance", leaderSelf.ID, "backend", cfg.Backend)
+			notifyKeyed(eventLeaderChanged, levelWarning, "leader", "主实例切换："+leaderSelf.ID, "本实例取得租约，开始执行交易",
+				map[string]string{"instance": leaderSelf.ID, "backend": cfg.Backend})
+			go supervise("leaderLease", keepLeadership)
+			return true
+		default:
+			lastErr = ""
+			if holder.ID != announced {
+				logInfo("🕒 备用实例等待接管", "instance", leaderSelf.ID, "leader", holder.ID, "leaderApi", holder.API)
+				announced = holder.ID
+			}
+			if holder.API != "" && time.Since(lastSync) >= syncEvery {
+				lastSync = time.Now()
+				n, err := syncFromLeader(holder)
+				if err != nil {
+					logWarn("⚠️ 从主实例同步状态失败", "leader", holder.ID, "error", err)
+					updateLeaderStatus(func(s *LeaderStatus) { s.LastError = "同步失败: " + err.Error() })
+				} else {
+					synced = true
+					updateLeaderStatus(func(s *LeaderStatus) { s.LastSyncAt, s.LastSyncFiles = lastSync.Format(time.RFC3339), n })
+					if n > 0 {
+						logDebug("🔁 已从主实例同步状态", "leader", holder.ID, "files", n)
+					}
+				}
+			}
+		}
+		if !sleepCtx(globalCtx, renew) {
+			return false
+		}
+	}
+}
+
+// keepLeadership 主实例按间隔续约，直到 resignLeadership（优雅关闭完成后）；租约被他人取得或超过到期时间仍未续约成功时立即停止交易并重启进程
+func keepLeadership() error {
+	cfg := currentConfig().Leader
+	ttl := time.Duration(cfg.LeaseSeconds) * time.Second
+	ticker := time.NewTicker(time.Duration(cfg.RenewSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-leaderStop:
+			return nil
+		case <-ticker.C:
+		}
+		holder, held, err := campaignLeader(ttl)
+		if held {
+			continue
+		}
+		if err != nil && time.Now().UnixNano() < leaderDeadline.Load() {
+			logWarn("⚠️ 主实例续约失败，租约到期前继续重试", "error", err, "expiresAt", time.Unix(0, leaderDeadline.Load()).Format(time.RFC3339))
+			continue
+		}
+		leaderHeld.Store(false)
+		reason := "租约已过期且续约失败"
+		if err == nil {
+			reason = "租约已被 " + holder.ID + " 取得"
+		}
+		logError("❌ 失去主实例租约，停止交易", "instance", leaderSelf.ID, "reason", reason)
+		notifyKeyed(eventLeaderChanged, levelCritical, "leader", "失去主实例租约："+leaderSelf.ID, reason,
+			map[string]string{"instance": leaderSelf.ID, "backend": cfg.Backend})
+		requestRestart("失去主实例租约")
+		return nil
+	}
+}
+
+// resignLeadership 优雅关闭完成后停止续约并释放租约，备用实例无需等待租约过期即可接管
+func resignLeadership() {
+	if !leaderElecting.Load() || !leaderHeld.Swap(false) {
+		return
+	}
+	close(leaderStop)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := leaderClient.resign(ctx, leaderSelf); err != nil {
+		logWarn("⚠️ 释放主实例租约失败，备用实例将在租约过期后接管", "error", err)
+		return
+	}
+	logInfo("👋 已释放主实例租约", "instance", leaderSelf.ID)
+}
+
+// reloadSyncedState 接管前重新加载启动时读取的状态（备用期间已被同步覆盖）
+func reloadSyncedState() {
+	loadFreezeState()
+	loadKillSwitchState()
+	loadProfileState()
+	loadProcessedMarkers()
+	loadJobJournal()
+}
+
+// startStandbyAPI 备用期间在管理接口地址上只提供 /leader、/metrics、/healthz 与 /readyz（未就绪），返回的函数关闭它（接管后由完整的管理接口使用该地址）
+func startStandbyAPI() func() {
+	if !currentConfig().API.Enabled {
+		return func() {}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/leader", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentLeaderStatus())
+	}))
+	mux.HandleFunc("/leader/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusConflict, "本实例不是主实例")
+	})
+	mux.HandleFunc("/healthz", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "role": leaderRoleStandby})
+	}))
+	mux.HandleFunc("/readyz", methodOnly(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"ready": false, "reason": "备用实例"})
+	}))
+	mux.HandleFunc("/metrics", methodOnly(http.MethodGet, metricsHandler))
+	server := &http.Server{Addr: currentConfig().API.Listen, Handler: recoverHandler("standbyApi", requireAPIToken(mux)), ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logWarn("⚠️ 备用实例管理接口异常退出", "listen", currentConfig().API.Listen, "error", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		<-done
+	}
+}
+
+// ===== 状态快照 =====
+
+// leaderSnapshotDirs 快照包含的目录（相对数据目录）；另含数据目录下的池文件
+var leaderSnapshotDirs = []string{"state", "ban"}
+
+// leaderSnapshotFiles 列出快照中的文件：相对数据目录的路径 → 绝对路径（跳过锁文件与原子写入的临时文件）
+func leaderSnapshotFiles() (map[string]string, error) {
+	files := map[string]string{}
+	entries, err := os.ReadDir(poolDataDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		path := filepath.Join(poolDataDir(), e.Name())
+		if !e.IsDir() && isPoolFile(poolDataDir(), path) {
+			files[e.Name()] = path
+		}
+	}
+	for _, dir := range leaderSnapshotDirs {
+		root := dataPath(dir)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			name := d.Name()
+			if d.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".lock") {
+				return nil
+			}
+			rel, err := filepath.Rel(poolDataDir(), path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = path
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// writeLeaderSnapshot 以 tar.gz 写出状态快照（GET /leader/snapshot）
+func writeLeaderSnapshot(w io.Writer) error {
+	files, err := leaderSnapshotFiles()
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for name, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // 列出后被删除或归档
+			}
+			return err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: fi.ModTime()}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// syncFromLeader 拉取主实例的状态快照并应用，返回写入或删除的文件数
+func syncFromLeader(holder leaderRecord) (int, error) {
+	ctx, cancel := context.WithTimeout(globalCtx, leaderHTTP.Timeout)
+	defer cancel()
+	req, err := newAPIRequest(ctx, http.MethodGet, holder.API+"/leader/snapshot")
+	if err != nil {
+		return 0, err
+	}
+	resp, err := leaderHTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return applyLeaderSnapshot(resp.Body)
+}
+
+type snapshotFile struct {
+	content []byte
+	modTime time.Time
+}
+
+// applyLeaderSnapshot 完整读取快照后再应用：内容有变化的文件原子写入（保留修改时间），本地多出的文件删除
+func applyLeaderSnapshot(r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	tr := tar.NewReader(gz)
+	snapshot := map[string]snapshotFile{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("快照不完整: %v", err)
+		}
+		name := filepath.FromSlash(h.Name)
+		if !leaderSnapshotPathAllowed(name) {
+			return 0, fmt.Errorf("快照包含不允许的路径: %s", h.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return 0, fmt.Errorf("快照不完整: %v", err)
+		}
+		snapshot[name] = snapshotFile{content: content, modTime: h.ModTime}
+	}
+
+	local, err := leaderSnapshotFiles()
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for name, f := range snapshot {
+		path := filepath.Join(poolDataDir(), name)
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, f.content) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return changed, err
+		}
+		if err := writeFileAtomic(path, f.content, 0644); err != nil {
+			return changed, err
+		}
+		os.Chtimes(path, f.modTime, f.modTime)
+		changed++
+	}
+	for name, path := range local {
+		if _, ok := snapshot[filepath.FromSlash(name)]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// leaderSnapshotPathAllowed 快照中的路径只能是数据目录下的池文件或快照目录中的文件
+func leaderSnapshotPathAllowed(name string) bool {
+	if name == "" || filepath.IsAbs(name) || name != filepath.Clean(name) || strings.HasPrefix(name, "..") {
+		return false
+	}
+	dir, _, found := strings.Cut(filepath.ToSlash(name), "/")
+	if !found {
+		return isPoolFile(poolDataDir(), filepath.Join(poolDataDir(), name))
+	}
+	for _, d := range leaderSnapshotDirs {
+		if dir == d {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== file 后端 =====
+
+// fileLeaderBackend 租约文件（JSON），读改写由 lockDataFile 互斥；各实例须共享该目录且时钟同步
+type fileLeaderBackend struct {
+	path string
+}
+
+type fileLease struct {
+	leaderRecord
+	ExpiresAt string `json:"expiresAt"`
+}
+
+func (b *fileLeaderBackend) read() (fileLease, error) {
+	var lease fileLease
+	content, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lease, nil
+		}
+		return lease, err
+	}
+	if err := json.Unmarshal(content, &lease); err != nil {
+		return lease, fmt.Errorf("解析租约文件失败: %v", err)
+	}
+	return lease, nil
+}
+
+func (b *fileLeaderBackend) campaign(ctx context.Context, self leaderRecord, ttl time.Duration) (leaderRecord, bool, error) {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return leaderRecord{}, false, err
+	}
+	unlock, err := lockDataFile(b.path)
+	if err != nil {
+		return leaderRecord{}, false, err
+	}
+	defer unlock()
+	lease, err := b.read()
+	if err != nil {
+		return leaderRecord{}, false, err
+	}
+	expires, _ := time.Parse(time.RFC3339Nano, lease.ExpiresAt)
+	if lease.ID != "" && lease.ID != self.ID && time.Now().Before(expires) {
+		return lease.leaderRecord, false, nil
+	}
+	content, err := json.MarshalIndent(fileLease{leaderRecord: self, ExpiresAt: time.Now().Add(ttl).Format(time.RFC3339Nano)}, "", "  ")
+	if err != nil {
+		return leaderRecord{}, false, err
+	}
+	if err := writeFileAtomic(b.path, content, 0644); err != nil {
+		return leaderRecord{}, false, err
+	}
+	return self, true, nil
+}
+
+func (b *fileLeaderBackend) resign(ctx context.Context, self leaderRecord) error {
+	unlock, err := lockDataFile(b.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lease, err := b.read()
+	if err != nil || lease.ID != self.ID {
+		return err
+	}
+	return os.Remove(b.path)
+}
+
+// ===== redis 后端 =====
+
+// redisLeaderBackend 值为持有者记录的 JSON：SET NX PX 取得，Lua 脚本比较值后续约或删除（多个 Redis 实例需自行保证高可用，如 Sentinel 后的地址）
+type redisLeaderBackend struct {
+	cfg LeaderRedisConfig
+	key string
+}
+
+const (
+	redisRenewScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	redisResignScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+// do 建立连接（认证、选库）后依次执行命令，返回各命令的回复
+func (b *redisLeaderBackend) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", b.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	n := len(cmds)
+	if b.cfg.DB != 0 {
+		cmds = append([][]string{{"SELECT", strconv.Itoa(b.cfg.DB)}}, cmds...)
+	}
+	if b.cfg.Password != "" {
+		cmds = append([][]string{{"AUTH", b.cfg.Password}}, cmds...)
+	}
+	rd := bufio.NewReader(conn)
+	replies := make([]interface{}, 0, len(cmds))
+	for _, cmd := range cmds {
+		if _, err := conn.Write(encodeRESP(cmd)); err != nil {
+			return nil, err
+		}
+		reply, err := readRESP(rd)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies[len(replies)-n:], nil
+}
+
+func (b *redisLeaderBackend) campaign(ctx context.Context, self leaderRecord, ttl time.Duration) (leaderRecord, bool, error) {
+	value, _ := json.Marshal(self)
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	replies, err := b.do(ctx,
+		[]string{"EVAL", redisRenewScript, "1", b.key, string(value), ms},
+		[]string{"SET", b.key, string(value), "NX", "PX", ms},
+		[]string{"GET", b.key})
+	if err != nil {
+		return leaderRecord{}, false, err
+	}
+	if renewed, _ := replies[0].(int64); renewed == 1 {
+		return self, true, nil
+	}
+	if ok, _ := replies[1].(string); ok == "OK" {
+		return self, true, nil
+	}
+	current, _ := replies[2].(string)
+	var holder leaderRecord
+	if err := json.Unmarshal([]byte(current), &holder); err != nil {
+		return leaderRecord{}, false, fmt.Errorf("无法解析租约值 %q: %v", current, err)
+	}
+	return holder, holder.ID == self.ID, nil
+}
+
+func (b *redisLeaderBackend) resign(ctx context.Context, self leaderRecord) error {
+	value, _ := json.Marshal(self)
+	_, err := b.do(ctx, []string{"EVAL", redisResignScript, "1", b.key, string(value)})
+	return err
+}
+
+// ===== etcd 后端 =====
+
+// etcdLeaderBackend 通过 etcd v3 的 JSON 网关（/v3/...）：授予租约后以事务在键不存在时写入，之后对租约续期
+type etcdLeaderBackend struct {
+	cfg   LeaderEtcdConfig
+	key   string
+	lease string // 本实例持有的租约 ID
+}
+
+// etcdInt64 网关把 int64 编码为字符串
+type etcdInt64 string
+
+func (v *etcdInt64) UnmarshalJSON(b []byte) error {
+	*v = etcdInt64(strings.Trim(string(b), `"`))
+	return nil
+}
+
+type etcdKV struct {
+	Value string `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []etcdKV `json:"kvs"`
+}
+
+func (b *etcdLeaderBackend) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, ep := range b.cfg.Endpoints {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(ep, "/")+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := leaderHTTP.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("%s: HTTP %d: %s", ep, resp.StatusCode, strings.TrimSpace(string(content)))
+			continue
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(content, out)
+	}
+	return lastErr
+}
+
+func etcdKey(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+// current 读取当前持有者（键不存在时返回空记录）
+func (b *etcdLeaderBackend) current(ctx context.Context) (leaderRecord, error) {
+	var resp etcdRangeResponse
+	if err := b.post(ctx, "/v3/kv/range", map[string]string{"key": etcdKey(b.key)}, &resp); err != nil {
+		return leaderRecord{}, err
+	}
+	return decodeEtcdHolder(resp.Kvs)
+}
+
+func decodeEtcdHolder(kvs []etcdKV) (leaderRecord, error) {
+	var holder leaderRecord
+	if len(kvs) == 0 {
+		return holder, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(kvs[0].Value)
+	if err != nil {
+		return holder, err
+	}
+	if err := json.Unmarshal(raw, &holder); err != nil {
+		return holder, fmt.Errorf("无法解析租约值 %q: %v", raw, err)
+	}
+	return holder, nil
+}
+
+func (b *etcdLeaderBackend) campaign(ctx context.Context, self leaderRecord, ttl time.Duration) (leaderRecord, bool, error) {
+	// 已持有：续期租约并确认键仍是本实例
+	if b.lease != "" {
+		var ka struct {
+			Result struct {
+				TTL etcdInt64 `json:"TTL"`
+			} `json:"result"`
+		}
+		if err := b.post(ctx, "/v3/lease/keepalive", map[string]string{"ID": b.lease}, &ka); err != nil {
+			return leaderRecord{}, false, err
+		}
+		if n, _ := strconv.Atoi(string(ka.Result.TTL)); n > 0 {
+			holder, err := b.current(ctx)
+			if err != nil {
+				return leaderRecord{}, false, err
+			}
+			if holder.ID == self.ID {
+				return self, true, nil
+			}
+		}
+		b.lease = ""
+	}
+
+	var grant struct {
+		ID etcdInt64 `json:"ID"`
+	}
+	if err := b.post(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": int(ttl.Seconds())}, &grant); err != nil {
+		return leaderRecord{}, false, err
+	}
+	value, _ := json.Marshal(self)
+	key := etcdKey(b.key)
+	txn := map[string]interface{}{
+		"compare": []map[string]interface{}{{"key": key, "target": "CREATE", "result": "EQUAL", "create_revision": "0"}},
+		"success": []map[string]interface{}{{"request_put": map[string]string{"key": key, "value": base64.StdEncoding.EncodeToString(value), "lease": string(grant.ID)}}},
+		"failure": []map[string]interface{}{{"request_range": map[string]string{"key": key}}},
+	}
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			ResponseRange etcdRangeResponse `json:"response_range"`
+		} `json:"responses"`
+	}
+	if err := b.post(ctx, "/v3/kv/txn", txn, &resp); err != nil {
+		b.post(ctx, "/v3/lease/revoke", map[string]string{"ID": string(grant.ID)}, nil)
+		return leaderRecord{}, false, err
+	}
+	if resp.Succeeded {
+		b.lease = string(grant.ID)
+		return self, true, nil
+	}
+	b.post(ctx, "/v3/lease/revoke", map[string]string{"ID": string(grant.ID)}, nil)
+	if len(resp.Responses) == 0 {
+		return leaderRecord{}, false, fmt.Errorf("etcd 事务没有返回当前持有者")
+	}
+	holder, err := decodeEtcdHolder(resp.Responses[0].ResponseRange.Kvs)
+	return holder, false, err
+}
+
+func (b *etcdLeaderBackend) resign(ctx context.Context, self leaderRecord) error {
+	if b.lease == "" {
+		return nil
+	}
+	err := b.post(ctx, "/v3/lease/revoke", map[string]string{"ID": b.lease}, nil)
+	if err == nil {
+		b.lease = ""
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 升级前已存在的池文件：只标记为已处理，不再补处理开仓
+const outcomeLegacy = "legacy"
+
+// 日志中开仓成功的消息（人类可读与 JSON 格式的日志都包含）
+const legacyOpenMessage = "addLiquidity.ts执行成功"
+
+// LegacyImportConfig 首次以状态目录运行时，把旧版本留下的池文件、名单文件与日志导入新的状态结构（只执行一次）
+type LegacyImportConfig struct {
+	Enabled  bool `json:"enabled"`
+	ScanLogs bool `json:"scanLogs"` // 从 logging.dir 下的 app_*.log 恢复各池的开仓时间（缺失时取池文件修改时间）
+}
+
+// LegacyImportResult 导入结果（data/state/legacy_import.json，存在即不再导入）
+type LegacyImportResult struct {
+	ImportedAt string            `json:"importedAt"`
+	Skipped    string            `json:"skipped,omitempty"` // 未导入的原因（状态目录已在使用）
+	PoolFiles  int               `json:"poolFiles"`         // 标记为已处理的池文件
+	Positions  []string          `json:"positions"`         // 建立生命周期与盈亏记录的池
+	Bans       int               `json:"bans"`              // 从 ban.csv、pools.csv 导入的黑名单条目
+	LogFiles   int               `json:"logFiles"`
+	OpenTimes  int               `json:"openTimes"`        // 从日志恢复开仓时间的池
+	Failed     map[string]string `json:"failed,omitempty"` // 池 -> 原因
+}
+
+// legacyImportPending 是否需要导入：启用、未导入过，且状态目录中还没有已处理标记（旧版本不写状态目录）。
+// 须在其他启动步骤写入状态之前判断；状态目录已在使用时记录为跳过，之后不再判断
+func legacyImportPending() bool {
+	if !currentConfig().LegacyImport.Enabled || isDemo() {
+		return false
+	}
+	var last LegacyImportResult
+	if err := loadStateFile("legacy_import", &last); err != nil {
+		logOutput("⚠️ %v\n", err)
+		return false
+	}
+	if last.ImportedAt != "" {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(currentStateDir(), "processed_files.json")); err == nil {
+		result := LegacyImportResult{ImportedAt: time.Now().Format(time.RFC3339), Skipped: "状态目录已在使用", Positions: []string{}}
+		if err := saveStateFile("legacy_import", result); err != nil {
+			logOutput("❌ 保存旧数据导入记录失败: %v\n", err)
+		}
+		return false
+	}
+	return true
+}
+
+// startupLegacyImport 导入旧版本的数据目录并记录结果（在仓位快照导入之后、补处理之前执行）
+func startupLegacyImport() {
+	logOutput("📦 首次运行：导入旧版本的数据目录...\n")
+	result := runLegacyImport()
+	if err := saveStateFile("legacy_import", result); err != nil {
+		logOutput("❌ 保存旧数据导入记录失败: %v\n", err)
+	}
+	appendAudit(AuditEntry{Kind: auditKindImport, Reason: "legacy",
+		Detail: fmt.Sprintf("池文件 %d 个，仓位 %d 个，黑名单 %d 条", result.PoolFiles, len(result.Positions), result.Bans)})
+	logInfo("📦 旧数据导入完成", "poolFiles", result.PoolFiles, "positions", len(result.Positions), "bans", result.Bans,
+		"logFiles", result.LogFiles, "openTimes", result.OpenTimes, "failed", len(result.Failed))
+	for pool, reason := range result.Failed {
+		logWarn("⚠️ 旧数据导入跳过仓位", "pool", pool, "reason", reason)
+	}
+}
+
+func runLegacyImport() LegacyImportResult {
+	result := LegacyImportResult{ImportedAt: time.Now().Format(time.RFC3339), Positions: []string{}, Failed: map[string]string{}}
+	var openTimes map[string]time.Time
+	if currentConfig().LegacyImport.ScanLogs {
+		openTimes, result.LogFiles = legacyOpenTimes(currentConfig().Logging.Dir)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(poolDataDir(), "*.json"))
+	sort.Strings(paths)
+	records := loadPositionRecords()
+	ledger := loadPnLLedger()
+	for _, path := range paths {
+		pool := strings.TrimSuffix(filepath.Base(path), ".json")
+		if position := readPositionFromPoolJSON(pool); position != "" && records[pool] == nil && ledger[pool] == nil {
+			row, reason := legacyPosition(pool, position, path, openTimes)
+			if reason == "" {
+				if err := importPool(pool, []importedPosition{row}); err != nil {
+					reason = err.Error()
+				}
+			}
+			if reason != "" {
+				result.Failed[pool] = reason
+			} else {
+				result.Positions = append(result.Positions, pool)
+				if _, ok := openTimes[pool]; ok {
+					result.OpenTimes++
+				}
+			}
+		}
+		// 已有仓位的池由导入标记为 imported，其余池文件（失败、无效或未开仓的旧信号）不再补处理
+		if claimProcessed(path) {
+			markProcessed(path, outcomeLegacy)
+			result.PoolFiles++
+		}
+	}
+	result.Bans = importLegacyBans()
+	if len(result.Failed) == 0 {
+		result.Failed = nil
+	}
+	return result
+}
+
+// legacyPosition 由池文件构造一行仓位快照：成本取 range.solAmount（阶梯时为首档金额），入场价取开仓价或池文件价格
+func legacyPosition(pool, position, path string, openTimes map[string]time.Time) (importedPosition, string) {
+	row := importedPosition{Pool: pool, Position: position, Token: readTokenContractAddressFromPoolJSON(pool), SolAmount: mainDepositSOL(pool)}
+	if row.SolAmount <= 0 {
+		return row, "池文件缺少 range.solAmount，请用 positionImport 快照补充成本"
+	}
+	if rng := readOpenRangeFromPoolJSON(pool); rng != nil {
+		row.EntryPrice, _ = strconv.ParseFloat(rng.OpenPrice, 64)
+	}
+	row.OpenedAt = openTimes[pool]
+	if row.OpenedAt.IsZero() {
+		if fi, err := os.Stat(path); err == nil {
+			row.OpenedAt = fi.ModTime()
+		}
+	}
+	return row, ""
+}
+
+// legacyOpenTimes 从旧日志中的开仓成功记录恢复各池最近一次开仓时间，返回结果与读取的日志文件数
+func legacyOpenTimes(dir string) (map[string]time.Time, int) {
+	times := map[string]time.Time{}
+	matches, err := filepath.Glob(filepath.Join(dir, "app_*.log"))
+	if err != nil {
+		return times, 0
+	}
+	sort.Strings(matches)
+	for _, path := range matches {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			if pool, at, ok := parseLegacyOpenLine(sc.Text()); ok && at.After(times[pool]) {
+				times[pool] = at
+			}
+		}
+		f.Close()
+	}
+	return times, len(matches)
+}
+
+// parseLegacyOpenLine 解析开仓成功的日志行：
+// 人类可读格式 "[2006-01-02 15:04:05] INFO  ✅ addLiquidity.ts执行成功 pool=... token=..."，或 JSON 格式的 time、pool 字段
+func parseLegacyOpenLine(line string) (string, time.Time, bool) {
+	if !strings.Contains(line, legacyOpenMessage) {
+		return "", time.Time{}, false
+	}
+	if strings.HasPrefix(line, "{") {
+		var rec struct {
+			Time time.Time `json:"time"`
+			Pool string    `json:"pool"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Pool == "" {
+			return "", time.Time{}, false
+		}
+		return rec.Pool, rec.Time, true
+	}
+	if len(line) < 21 || line[0] != '[' {
+		return "", time.Time{}, false
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04:05", line[1:20], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	for _, field := range strings.Fields(line) {
+		if pool, ok := strings.CutPrefix(field, "pool="); ok && pool != "" {
+			return pool, at, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// importLegacyBans 把 ban.csv、pools.csv 的地址加入管理的黑名单（来源 legacy），已有的条目不覆盖；名单文件本身照常生效
+func importLegacyBans() int {
+	banStoreMutex.Lock()
+	defer banStoreMutex.Unlock()
+	bans := map[string]BanEntry{}
+	for k, v := range currentBansLocked() {
+		bans[k] = v
+	}
+	now := time.Now().Format(time.RFC3339)
+	added := 0
+	for _, list := range []struct {
+		file *banFile
+		kind string
+	}{{tokenBanList, banKindToken}, {poolBanList, banKindPool}} {
+		addrs := list.file.load()
+		for addr := range addrs {
+			if _, ok := bans[addr]; ok {
+				continue
+			}
+			bans[addr] = BanEntry{Address: addr, Kind: list.kind, Reason: filepath.Base(list.file.path()), Source: banSourceLegacy, AddedAt: now}
+			added++
+		}
+	}
+	if added == 0 {
+		return 0
+	}
+	if err := saveBansLocked(bans); err != nil {
+		logOutput("❌ 保存黑名单失败: %v\n", err)
+		return 0
+	}
+	return added
+}
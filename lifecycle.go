@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 仓位生命周期状态
+const (
+	positionStateOpened     = "opened"       // 已开仓，尚未观察到价格
+	positionStateActive     = "active"       // 价格位于 bin 范围内
+	positionStateOutOfRange = "out_of_range" // 价格超出 bin 范围
+	positionStateClosed     = "closed"
+)
+
+// 平仓原因（生命周期管理器触发）
+const (
+	exitReasonPnLTarget  = "pnl_target"
+	exitReasonOutOfRange = "out_of_range"
+	exitReasonExternal   = "external" // 脚本自行平仓（池 JSON 已归档）
+)
+
+// LifecycleConfig 仓位生命周期与自动平仓条件
+type LifecycleConfig struct {
+	Enabled           bool    `json:"enabled"`
+	MaxAgeMinutes     int     `json:"maxAgeMinutes"`     // 开仓超过该时长后平仓（0 表示不限制，与 signalTimeoutMinutes 相互独立）
+	PnLTargetPercent  float64 `json:"pnlTargetPercent"`  // 仓位价值相对投入 SOL 的收益率达到该值时平仓（0 表示不启用）
+	OutOfRangeMinutes int     `json:"outOfRangeMinutes"` // 价格持续超出 bin 范围的时长（0 表示不按范围平仓）
+	OutOfRangeSide    string  `json:"outOfRangeSide"`    // above / below / both
+
+	// 信号时间（last_updated_first）起强制平仓的时长，默认 300（原 5 小时规则）；0 表示关闭。不受 enabled 影响
+	SignalTimeoutMinutes int `json:"signalTimeoutMinutes"`
+}
+
+// signalTimeout 信号起强制平仓的时长，0 表示关闭
+func signalTimeout() time.Duration {
+	return time.Duration(currentConfig().Lifecycle.SignalTimeoutMinutes) * time.Minute
+}
+
+// OpenRange addLiquidity.ts 写入池 JSON 的开仓范围
+type OpenRange struct {
+	MinBinID  int     `json:"minBinId"`
+	MaxBinID  int     `json:"maxBinId"`
+	ActiveID  int     `json:"activeId"`
+	BinStep   int     `json:"binStep"`
+	SolAmount float64 `json:"solAmount"`
+	OpenPrice string  `json:"openPrice,omitempty"`
+}
+
+// PositionTransition 状态变更记录
+type PositionTransition struct {
+	State string `json:"state"`
+	At    string `json:"at"`
+	Note  string `json:"note,omitempty"`
+}
+
+// PositionRecord 单个池的仓位生命周期（data/state/positions.json: pool -> 记录）
+type PositionRecord struct {
+	PoolAddress  string               `json:"poolAddress"`
+	Position     string               `json:"position,omitempty"`
+	TokenAddress string               `json:"ca,omitempty"`
+	Mode         string               `json:"mode"`
+	Profile      string               `json:"profile,omitempty"` // 开仓时生效的参数档位
+	State        string               `json:"state"`
+	OpenedAt     string               `json:"openedAt"`
+	ClosedAt     string               `json:"closedAt,omitempty"`
+	CloseReason  string               `json:"closeReason,omitempty"`
+	SolAmount    float64              `json:"solAmount,omitempty"`
+	EntryPrice   float64              `json:"entryPrice,omitempty"` // 开仓时代币价格（脚本未取到时为首次采样价格）
+	LowerPrice   float64              `json:"lowerPrice,omitempty"` // bin 范围对应的价格下界
+	UpperPrice   float64              `json:"upperPrice,omitempty"` // bin 范围对应的价格上界
+	LastPrice    float64              `json:"lastPrice,omitempty"`
+	OutOfRangeAt string               `json:"outOfRangeAt,omitempty"` // 本次超出范围的开始时间
+	ValueSOL     float64              `json:"valueSOL,omitempty"`     // 最近一次领取时的仓位价值（含已领取与未领取费用）
+	PnLPercent   float64              `json:"pnlPercent,omitempty"`
+	Withdrawals  []PositionWithdrawal `json:"withdrawals,omitempty"` // 部分移除（分档止盈）记录
+	UpdatedAt    string               `json:"updatedAt"`
+	Transitions  []PositionTransition `json:"transitions"`
+}
+
+var (
+	lifecycleMutex sync.Mutex
+	closingPools   sync.Map // 正在平仓的池，避免重复触发
+)
+
+func (c LifecycleConfig) validate() error {
+	if c.MaxAgeMinutes < 0 || c.OutOfRangeMinutes < 0 || c.PnLTargetPercent < 0 || c.SignalTimeoutMinutes < 0 {
+		return fmt.Errorf("lifecycle 的取值不能为负数")
+	}
+	switch c.OutOfRangeSide {
+	case "above", "below", "both":
+	default:
+		return fmt.Errorf("lifecycle.outOfRangeSide 仅支持 above、below 或 both")
+	}
+	return nil
+}
+
+func (r *PositionRecord) transition(state, note string) {
+	if r.State == state {
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+	r.State = state
+	r.Transitions = append(r.Transitions, PositionTransition{State: state, At: now, Note: note})
+	logInfo("🔁 仓位状态变更", "pool", r.PoolAddress, "state", state, "note", note)
+}
+
+func loadPositionRecords() map[string]*PositionRecord {
+	records := map[string]*PositionRecord{}
+	if err := loadStateFile("positions", &records); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return records
+}
+
+// 修改单个池的生命周期记录（读-改-写），记录不存在时 fn 收到 nil
+func updatePositionRecord(poolAddress string, fn func(r *PositionRecord) *PositionRecord) {
+	lifecycleMutex.Lock()
+	defer lifecycleMutex.Unlock()
+	records := loadPositionRecords()
+	r := fn(records[poolAddress])
+	if r == nil {
+		return
+	}
+	r.UpdatedAt = time.Now().Format(time.RFC3339)
+	records[poolAddress] = r
+	if err := saveStateFile("positions", records); err != nil {
+		logOutput("❌ 保存仓位生命周期失败: %v\n", err)
+	}
+}
+
+// 所有生命周期记录（按开仓时间倒序）
+func listPositionRecords() []*PositionRecord {
+	lifecycleMutex.Lock()
+	records := loadPositionRecords()
+	lifecycleMutex.Unlock()
+	result := make([]*PositionRecord, 0, len(records))
+	for _, r := range records {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].OpenedAt > result[b].OpenedAt })
+	return result
+}
+
+// 从 data/<pool>.json 读取开仓范围
+func readOpenRangeFromPoolJSON(poolAddress string) *OpenRange {
+	bytes, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json"))
+	if err != nil {
+		return nil
+	}
+	var obj struct {
+		Range *OpenRange `json:"range"`
+	}
+	if err := json.Unmarshal(bytes, &obj); err != nil {
+		return nil
+	}
+	return obj.Range
+}
+
+// bin 对应价格相对开仓 activeId 的倍数
+func binPriceRatio(binStep, binID, activeID int) float64 {
+	return math.Pow(1+float64(binStep)/10000, float64(binID-activeID))
+}
+
+// 按入场价计算 bin 范围的价格上下界（以代币 USD 价格近似，忽略开仓期间 SOL 的波动）
+func (r *PositionRecord) applyRange(rng *OpenRange) {
+	if rng == nil || r.EntryPrice <= 0 || rng.BinStep <= 0 {
+		return
+	}
+	r.LowerPrice = r.EntryPrice * binPriceRatio(rng.BinStep, rng.MinBinID, rng.ActiveID)
+	r.UpperPrice = r.EntryPrice * binPriceRatio(rng.BinStep, rng.MaxBinID+1, rng.ActiveID)
+}
+
+// 开仓成功后登记
+func positionOpened(poolAddress, tokenAddress string) {
+	rng := readOpenRangeFromPoolJSON(poolAddress)
+	updatePositionRecord(poolAddress, func(_ *PositionRecord) *PositionRecord {
+		r := &PositionRecord{
+			PoolAddress:  poolAddress,
+			Position:     readPositionFromPoolJSON(poolAddress),
+			TokenAddress: tokenAddress,
+			Mode:         getPoolMode(poolAddress),
+			Profile:      poolProfileName(poolAddress),
+			OpenedAt:     time.Now().Format(time.RFC3339),
+		}
+		if rng != nil {
+			r.SolAmount = rng.SolAmount
+			r.EntryPrice, _ = strconv.ParseFloat(rng.OpenPrice, 64)
+			r.applyRange(rng)
+		}
+		r.transition(positionStateOpened, "")
+		return r
+	})
+}
+
+// 平仓后登记（同时结转盈亏台账）
+func markPositionClosed(poolAddress, reason string) {
+	recordPnLClose(poolAddress, reason)
+	transitionPool(poolAddress, poolClosed, reason)
+	updatePositionRecord(poolAddress, func(r *PositionRecord) *PositionRecord {
+		if r == nil || r.State == positionStateClosed {
+			return nil
+		}
+		r.ClosedAt = time.Now().Format(time.RFC3339)
+		r.CloseReason = reason
+		r.transition(positionStateClosed, reason)
+		return r
+	})
+}
+
+// 价格更新：刷新状态并检查平仓条件
+func updatePositionPrice(poolAddress, priceStr string) {
+	price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	var snapshot PositionRecord
+	updatePositionRecord(poolAddress, func(r *PositionRecord) *PositionRecord {
+		if r == nil || r.State == positionStateClosed {
+			return nil
+		}
+		r.LastPrice = price
+		if r.EntryPrice <= 0 {
+			r.EntryPrice = price
+			r.applyRange(readOpenRangeFromPoolJSON(poolAddress))
+		}
+		if r.LowerPrice > 0 && r.UpperPrice > 0 {
+			switch {
+			case price > r.UpperPrice:
+				if r.State != positionStateOutOfRange {
+					r.OutOfRangeAt = time.Now().Format(time.RFC3339)
+				}
+				r.transition(positionStateOutOfRange, "above")
+			case price < r.LowerPrice:
+				if r.State != positionStateOutOfRange {
+					r.OutOfRangeAt = time.Now().Format(time.RFC3339)
+				}
+				r.transition(positionStateOutOfRange, "below")
+			default:
+				r.OutOfRangeAt = ""
+				r.transition(positionStateActive, "")
+			}
+		} else {
+			r.transition(positionStateActive, "")
+		}
+		snapshot = *r
+		return r
+	})
+	if snapshot.PoolAddress != "" {
+		evaluateExitConditions(&snapshot)
+	}
+}
+
+// 领取后记录仓位价值与收益率
+func updatePositionValue(poolAddress, output string) {
+	valueUSD, solUSD, ok := parseClaimValues(output)
+	if !ok || solUSD <= 0 {
+		return
+	}
+	var snapshot PositionRecord
+	updatePositionRecord(poolAddress, func(r *PositionRecord) *PositionRecord {
+		if r == nil || r.State == positionStateClosed {
+			return nil
+		}
+		r.ValueSOL = valueUSD / solUSD
+		if r.SolAmount > 0 {
+			r.PnLPercent = (r.ValueSOL - r.SolAmount) / r.SolAmount * 100
+		}
+		snapshot = *r
+		return r
+	})
+	if snapshot.PoolAddress != "" {
+		evaluateExitConditions(&snapshot)
+	}
+}
+
+// 检查年龄、收益、范围条件，满足任一则领取并平仓
+func evaluateExitConditions(r *PositionRecord) {
+	cfg := currentConfig().Lifecycle
+	if !cfg.Enabled || isPriceOnly() {
+		return
+	}
+	reason := ""
+	if opened, err := time.Parse(time.RFC3339, r.OpenedAt); err == nil && cfg.MaxAgeMinutes > 0 &&
+		time.Since(opened) >= time.Duration(cfg.MaxAgeMinutes)*time.Minute {
+		reason = exitReasonMaxAge
+	}
+	if reason == "" && cfg.PnLTargetPercent > 0 && r.SolAmount > 0 && r.ValueSOL > 0 && r.PnLPercent >= cfg.PnLTargetPercent {
+		reason = exitReasonPnLTarget
+	}
+	if reason == "" && cfg.OutOfRangeMinutes > 0 && r.State == positionStateOutOfRange {
+		side := "above"
+		if r.LastPrice < r.LowerPrice {
+			side = "below"
+		}
+		since, err := time.Parse(time.RFC3339, r.OutOfRangeAt)
+		if err == nil && (cfg.OutOfRangeSide == "both" || cfg.OutOfRangeSide == side) &&
+			time.Since(since) >= time.Duration(cfg.OutOfRangeMinutes)*time.Minute {
+			reason = exitReasonOutOfRange
+		}
+	}
+	if reason == "" {
+		return
+	}
+	if _, busy := closingPools.LoadOrStore(r.PoolAddress, true); busy {
+		return
+	}
+	defer closingPools.Delete(r.PoolAddress)
+	logOutput("🚪 满足平仓条件 (%s)，自动领取并平仓: pool=%s\n", reason, r.PoolAddress)
+	claimAndClosePosition(r.PoolAddress, reason)
+}
+
+// 池 JSON 已被脚本归档（如 fetchPrice.ts 的价格监控移除）时将记录标记为已平仓
+func sweepClosedPositions() {
+	for _, r := range listPositionRecords() {
+		if r.State == positionStateClosed {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(poolDataDir(), r.PoolAddress+".json")); os.IsNotExist(err) {
+			markPositionClosed(r.PoolAddress, exitReasonExternal)
+		}
+	}
+}
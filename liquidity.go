@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 流动性分布策略
+const (
+	liquiditySpot     = "spot"     // 范围内均匀分布
+	liquidityCurve    = "curve"    // 集中在靠近当前价的 bin
+	liquidityBidAsk   = "bidAsk"   // 集中在范围两端（addLiquidity.ts 原有方式）
+	liquidityOneSided = "oneSided" // 均匀分布且整个范围位于 active bin 下方（只投入 SOL）
+)
+
+// LiquidityParams 策略参数（0 值表示沿用脚本计算的范围）
+type LiquidityParams struct {
+	Strategy string  `json:"strategy"`
+	Bins     int     `json:"bins"` // bin 数量，覆盖按 range-pct / last_updated_first 计算的宽度
+	Skew     float64 `json:"skew"` // 范围中位于 active bin 上方的比例：0 全部在下方（默认），0.5 以 active bin 为中心
+}
+
+// LiquidityConfig 开仓的流动性分布：默认策略、按池覆盖与按信号字段选择
+type LiquidityConfig struct {
+	LiquidityParams
+	Pools       map[string]LiquidityParams `json:"pools"`       // 按池覆盖（整体替换默认参数）
+	SignalField string                     `json:"signalField"` // 信号中指定策略名的字段（如 strategy），为空时不读取
+}
+
+// LiquidityPlan 解析后的分布方式，转换为 addLiquidity.ts 参数
+type LiquidityPlan struct {
+	Shape string  `json:"shape"` // addLiquidity.ts 的 StrategyType：spot / curve / bidask
+	Bins  int     `json:"bins,omitempty"`
+	Skew  float64 `json:"skew,omitempty"`
+}
+
+// LiquidityStrategy 流动性分布策略接口：校验参数并给出 addLiquidity.ts 使用的分布方式
+type LiquidityStrategy interface {
+	Name() string
+	Validate(p LiquidityParams) error
+	Plan(p LiquidityParams) LiquidityPlan
+}
+
+// shapeStrategy 对应 SDK StrategyType 的策略，范围位置由 skew 决定
+type shapeStrategy struct {
+	name  string
+	shape string
+}
+
+func (s shapeStrategy) Name() string { return s.name }
+
+func (s shapeStrategy) Validate(p LiquidityParams) error {
+	if p.Bins < 0 {
+		return fmt.Errorf("bins 不能为负数")
+	}
+	if p.Skew < 0 || p.Skew > 0.5 {
+		return fmt.Errorf("skew 取值范围为 0 到 0.5（只投入 SOL，active bin 上方的 bin 没有流动性）")
+	}
+	return nil
+}
+
+func (s shapeStrategy) Plan(p LiquidityParams) LiquidityPlan {
+	return LiquidityPlan{Shape: s.shape, Bins: p.Bins, Skew: p.Skew}
+}
+
+// oneSidedStrategy 均匀分布，范围固定在 active bin 下方（忽略 skew）
+type oneSidedStrategy struct{}
+
+func (oneSidedStrategy) Name() string { return liquidityOneSided }
+
+func (oneSidedStrategy) Validate(p LiquidityParams) error {
+	if p.Bins < 0 {
+		return fmt.Errorf("bins 不能为负数")
+	}
+	return nil
+}
+
+func (oneSidedStrategy) Plan(p LiquidityParams) LiquidityPlan {
+	return LiquidityPlan{Shape: "spot", Bins: p.Bins}
+}
+
+// 按名称查找策略（不区分大小写，bid-ask / bid_ask 等写法视为 bidAsk）
+func liquidityStrategy(name string) (LiquidityStrategy, error) {
+	key := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(strings.TrimSpace(name)))
+	switch key {
+	case "", "bidask":
+		return shapeStrategy{name: liquidityBidAsk, shape: "bidask"}, nil
+	case "spot":
+		return shapeStrategy{name: liquiditySpot, shape: "spot"}, nil
+	case "curve":
+		return shapeStrategy{name: liquidityCurve, shape: "curve"}, nil
+	case "onesided":
+		return oneSidedStrategy{}, nil
+	}
+	return nil, fmt.Errorf("不支持的流动性策略 %q（可选 %s、%s、%s、%s）", name, liquiditySpot, liquidityCurve, liquidityBidAsk, liquidityOneSided)
+}
+
+func (p LiquidityParams) validate() error {
+	s, err := liquidityStrategy(p.Strategy)
+	if err != nil {
+		return err
+	}
+	return s.Validate(p)
+}
+
+func (c LiquidityConfig) validate() error {
+	if err := c.LiquidityParams.validate(); err != nil {
+		return fmt.Errorf("liquidity: %v", err)
+	}
+	pools := make([]string, 0, len(c.Pools))
+	for pool := range c.Pools {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	for _, pool := range pools {
+		if err := c.Pools[pool].validate(); err != nil {
+			return fmt.Errorf("liquidity.pools[%s]: %v", pool, err)
+		}
+	}
+	return nil
+}
+
+// resolveLiquidity 池开仓使用的策略：池配置覆盖文件 > 按池配置 > 信号字段指定的策略名（沿用默认 bins / skew）> 默认策略。
+// 信号中的策略名无效时记录警告并使用默认策略
+func resolveLiquidity(poolAddress string, data map[string]interface{}) (LiquidityParams, string) {
+	cfg := currentConfig().Liquidity
+	if o := poolOverride(poolAddress); o != nil && o.Liquidity != nil {
+		return *o.Liquidity, "override"
+	}
+	if p, ok := cfg.Pools[poolAddress]; ok {
+		return p, "pool"
+	}
+	if cfg.SignalField != "" {
+		if name, ok := data[cfg.SignalField].(string); ok && strings.TrimSpace(name) != "" {
+			p := cfg.LiquidityParams
+			p.Strategy = name
+			if err := p.validate(); err != nil {
+				logWarn("⚠️ 信号指定的流动性策略无效，使用默认策略", "pool", poolAddress, "field", cfg.SignalField, "error", err)
+			} else {
+				return p, "signal"
+			}
+		}
+	}
+	return cfg.LiquidityParams, "default"
+}
+
+// liquidityArgs 池开仓的 addLiquidity.ts 策略参数（--strategy、--bins、--skew）
+func liquidityArgs(poolAddress string, data map[string]interface{}) []string {
+	params, source := resolveLiquidity(poolAddress, data)
+	s, err := liquidityStrategy(params.Strategy)
+	if err != nil {
+		logWarn("⚠️ 流动性策略无效，沿用脚本默认", "pool", poolAddress, "error", err)
+		return nil
+	}
+	plan := s.Plan(params)
+	logDebug("🧮 流动性策略", "pool", poolAddress, "strategy", s.Name(), "source", source, "bins", plan.Bins, "skew", plan.Skew)
+	return plan.args()
+}
+
+func (p LiquidityPlan) args() []string {
+	args := []string{"--strategy=" + p.Shape}
+	if p.Bins > 0 {
+		args = append(args, fmt.Sprintf("--bins=%d", p.Bins))
+	}
+	if p.Skew > 0 {
+		args = append(args, "--skew="+strconv.FormatFloat(p.Skew, 'f', -1, 64))
+	}
+	return args
+}
+
+// 从 data/<pool>.json 读取信号字段（data 对象），用于再平衡等不经过信号的开仓
+func readSignalDataFromPoolJSON(poolAddress string) map[string]interface{} {
+	bytes, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json"))
+	if err != nil {
+		return nil
+	}
+	var obj struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(bytes, &obj); err != nil {
+		return nil
+	}
+	return obj.Data
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoggingConfig 日志配置
+type LoggingConfig struct {
+	Level      string `json:"level"`      // debug / info / warn / error
+	Format     string `json:"format"`     // 日志文件格式: human 或 json（终端始终为 human）
+	Dir        string `json:"dir"`        // 日志目录
+	MaxSizeMB  int    `json:"maxSizeMB"`  // 单个文件超过该大小时轮转（0 表示不按大小轮转）
+	Daily      bool   `json:"daily"`      // 跨天时轮转
+	MaxFiles   int    `json:"maxFiles"`   // 最多保留的日志文件数（0 表示不限制）
+	MaxAgeDays int    `json:"maxAgeDays"` // 日志保留天数（0 表示不限制）
+
+	Redact LogRedactConfig `json:"redact"` // 日志脱敏
+}
+
+// 日志系统
+var (
+	logWriter  *rotatingWriter
+	fileLogger *slog.Logger
+	logLevel   = new(slog.LevelVar)
+	logMutex   sync.Mutex
+)
+
+// rotatingWriter 按大小/日期轮转的日志文件，并按数量与天数清理旧文件
+type rotatingWriter struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	daily   bool
+	maxKeep int
+	maxAge  time.Duration
+	file    *os.File
+	size    int64
+	day     string
+}
+
+func newRotatingWriter(cfg LoggingConfig) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		dir:     cfg.Dir,
+		maxSize: int64(cfg.MaxSizeMB) * 1024 * 1024,
+		daily:   cfg.Daily,
+		maxKeep: cfg.MaxFiles,
+		maxAge:  time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+	}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建data目录失败: %v", err)
+	}
+	if err := w.openNew(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// 创建带时间戳的日志文件
+func (w *rotatingWriter) openNew() error {
+	now := appNow()
+	logPath := filepath.Join(w.dir, fmt.Sprintf("app_%s.log", now.Format("2006-01-02_15-04-05")))
+	// 同一秒内多次轮转时追加序号，避免覆盖
+	for i := 1; ; i++ {
+		if _, err := os.Stat(logPath); os.IsNotExist(err) {
+			break
+		}
+		logPath = filepath.Join(w.dir, fmt.Sprintf("app_%s_%d.log", now.Format("2006-01-02_15-04-05"), i))
+	}
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("创建日志文件失败: %v", err)
+	}
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = f
+	w.size = 0
+	w.day = now.Format("2006-01-02")
+	fmt.Print(consoleLine(slog.LevelInfo, fmt.Sprintf("📝 日志文件已创建: %s\n", logPath)))
+	w.prune()
+	return nil
+}
+
+// 清理超出保留数量或天数的旧日志
+func (w *rotatingWriter) prune() {
+	if w.maxKeep <= 0 && w.maxAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(w.dir, "app_*.log"))
+	if err != nil {
+		return
+	}
+	sort.Strings(matches) // 文件名含时间戳，字典序即时间序
+	current := w.file.Name()
+	for i, path := range matches {
+		if path == current {
+			continue
+		}
+		expired := false
+		if w.maxKeep > 0 && len(matches)-i > w.maxKeep {
+			expired = true
+		}
+		if w.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > w.maxAge {
+				expired = true
+			}
+		}
+		if expired {
+			os.Remove(path)
+		}
+	}
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if (w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize && w.size > 0) ||
+		(w.daily && appNow().Format("2006-01-02") != w.day) {
+		if err := w.openNew(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// humanHandler 人类可读格式: [时间] LEVEL 消息 key=value
+type humanHandler struct {
+	out   io.Writer
+	attrs []slog.Attr
+}
+
+func (h *humanHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *humanHandler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %-5s %s", r.Time.Format("2006-01-02 15:04:05"), r.Level.String(), r.Message)
+	writeAttr := func(a slog.Attr) bool {
+		fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value.Any())
+		return true
+	}
+	for _, a := range h.attrs {
+		writeAttr(a)
+	}
+	r.Attrs(writeAttr)
+	sb.WriteString("\n")
+	_, err := io.WriteString(h.out, sb.String())
+	return err
+}
+
+func (h *humanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &humanHandler{out: h.out, attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...)}
+}
+
+func (h *humanHandler) WithGroup(string) slog.Handler { return h }
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("未知的日志级别: %s", s)
+	}
+	return level, nil
+}
+
+// 初始化日志系统
+func initLogging(cfg LoggingConfig) error {
+	level, err := parseLogLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	initLogRedaction(cfg.Redact)
+
+	w, err := newRotatingWriter(cfg)
+	if err != nil {
+		return err
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logWriter = w
+	if cfg.Format == "json" {
+		fileLogger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: logLevel}))
+	} else {
+		fileLogger = slog.New(&humanHandler{out: w})
+	}
+	// 文件日志每条都带部署标签
+	deploy := deploymentLabels()
+	attrs := make([]interface{}, len(deploy))
+	for i, v := range deploy {
+		attrs[i] = v
+	}
+	fileLogger = fileLogger.With(attrs...)
+	return nil
+}
+
+// 根据消息前缀推断级别（兼容历史的 emoji 日志）
+func inferLevel(message string) slog.Level {
+	trimmed := strings.TrimSpace(message)
+	switch {
+	case strings.HasPrefix(trimmed, "❌"), strings.HasPrefix(trimmed, "💀"):
+		return slog.LevelError
+	case strings.HasPrefix(trimmed, "⚠️"), strings.HasPrefix(trimmed, "🚨"), strings.HasPrefix(trimmed, "⏰"):
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// 结构化日志（同时输出到终端和文件），kv 为成对的字段，如 "pool", addr
+func logAt(level slog.Level, msg string, kv ...interface{}) {
+	if level < logLevel.Level() {
+		return
+	}
+
+	// 输出到终端（面板日志始终不脱敏，仅本机可见）
+	line := formatLogLine(msg, kv)
+	logRing.add(strings.TrimRight(line, "\n"))
+	redactedMsg, redactedKV := redactLog(msg, kv)
+	if redactConsole() {
+		line = formatLogLine(redactedMsg, redactedKV)
+	}
+	fmt.Print(consoleLine(level, line))
+
+	// 写入日志文件
+	logMutex.Lock()
+	logger := fileLogger
+	logMutex.Unlock()
+	if daemonMode {
+		redactedMsg = plainText(redactedMsg)
+	}
+	if logger != nil {
+		logger.Log(context.Background(), level, strings.TrimRight(redactedMsg, "\n"), redactedKV...)
+	}
+}
+
+// 终端格式: 消息 key=value
+func formatLogLine(msg string, kv []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", kv[i], kv[i+1])
+	}
+	line := sb.String()
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	return line
+}
+
+func logDebug(msg string, kv ...interface{}) { logAt(slog.LevelDebug, msg, kv...) }
+func logInfo(msg string, kv ...interface{})  { logAt(slog.LevelInfo, msg, kv...) }
+func logWarn(msg string, kv ...interface{})  { logAt(slog.LevelWarn, msg, kv...) }
+func logError(msg string, kv ...interface{}) { logAt(slog.LevelError, msg, kv...) }
+
+// 写入日志（同时输出到终端和文件），级别按消息前缀推断
+func logOutput(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if strings.TrimSpace(message) == "" {
+		return
+	}
+	logAt(inferLevel(message), message)
+}
+
+// 关闭日志系统
+func closeLogging() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logWriter != nil {
+		logWriter.Close()
+		logWriter = nil
+	}
+	fileLogger = nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LogRedactConfig 日志脱敏：写入日志文件（以及可选的终端输出）时遮蔽钱包地址、余额与盈亏，便于将日志发送到共享系统。
+// 本机面板（GET /logs）的日志不受影响
+type LogRedactConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Console   bool     `json:"console"`   // 终端输出同样脱敏（标准输出被采集时开启）
+	Addresses bool     `json:"addresses"` // 钱包地址（wallets、walletWatch.address 与环境变量 USER_WALLET_ADDRESS）保留首尾 4 位
+	Amounts   bool     `json:"amounts"`   // 余额与盈亏：fields 中的字段值，以及文本中 keywords 之后到分隔符为止的数字
+	Fields    []string `json:"fields"`    // 结构化日志中需要遮蔽的字段名
+	Keywords  []string `json:"keywords"`  // 文本日志中其后的数字需要遮蔽的关键词
+}
+
+// 遮蔽后的值
+const redactedValue = "***"
+
+// 文本中的一个词（以空白、等号、冒号、括号、斜杠与逗号分隔）
+var redactTokenPattern = regexp.MustCompile(`[^\s=:：(（)）/，,；;]+`)
+
+var (
+	redactMutex    sync.Mutex
+	redactCfg      LogRedactConfig
+	redactWallets  []string        // 需要遮蔽的钱包地址
+	redactFields   map[string]bool // 小写字段名
+	redactKeywords *regexp.Regexp  // 关键词到分隔符为止的片段
+)
+
+func (c LogRedactConfig) validate() error {
+	if c.Enabled && !c.Addresses && !c.Amounts {
+		return fmt.Errorf("logging.redact 已启用，但 addresses 与 amounts 均未开启")
+	}
+	return nil
+}
+
+// initLogRedaction 按配置准备脱敏规则（钱包地址取自当前配置与环境变量）
+func initLogRedaction(cfg LogRedactConfig) {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+	redactCfg = cfg
+	redactWallets, redactFields, redactKeywords = nil, map[string]bool{}, nil
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Addresses {
+		seen := map[string]bool{}
+		add := func(addr string) {
+			if len(addr) >= 32 && !seen[addr] {
+				seen[addr] = true
+				redactWallets = append(redactWallets, addr)
+			}
+		}
+		for _, w := range currentConfig().Wallets {
+			add(w.Address)
+		}
+		add(walletAddress())
+		add(lookupEnv("USER_WALLET_ADDRESS"))
+	}
+	if cfg.Amounts {
+		for _, f := range cfg.Fields {
+			redactFields[strings.ToLower(f)] = true
+		}
+		quoted := make([]string, 0, len(cfg.Keywords))
+		for _, k := range cfg.Keywords {
+			if k != "" {
+				quoted = append(quoted, regexp.QuoteMeta(k))
+			}
+		}
+		if len(quoted) > 0 {
+			redactKeywords = regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)[^，,；;\n]*`)
+		}
+	}
+}
+
+func maskAddress(addr string) string {
+	return addr[:4] + "…" + addr[len(addr)-4:]
+}
+
+// redactText 遮蔽文本中的钱包地址与关键词之后的数字
+func redactText(s string) string {
+	redactMutex.Lock()
+	wallets, keywords := redactWallets, redactKeywords
+	redactMutex.Unlock()
+	for _, addr := range wallets {
+		s = strings.ReplaceAll(s, addr, maskAddress(addr))
+	}
+	if keywords != nil {
+		s = keywords.ReplaceAllStringFunc(s, func(segment string) string {
+			return redactTokenPattern.ReplaceAllStringFunc(segment, func(tok string) string {
+				if _, err := strconv.ParseFloat(strings.TrimLeft(tok, "$¥€+"), 64); err == nil {
+					return redactedValue
+				}
+				return tok
+			})
+		})
+	}
+	return s
+}
+
+// redactLog 返回脱敏后的消息与字段（未启用时原样返回）
+func redactLog(msg string, kv []interface{}) (string, []interface{}) {
+	redactMutex.Lock()
+	enabled, fields := redactCfg.Enabled, redactFields
+	redactMutex.Unlock()
+	if !enabled {
+		return msg, kv
+	}
+	out := make([]interface{}, len(kv))
+	copy(out, kv)
+	for i := 0; i+1 < len(out); i += 2 {
+		if key, ok := out[i].(string); ok && fields[strings.ToLower(key)] {
+			out[i+1] = redactedValue
+			continue
+		}
+		switch v := out[i+1].(type) {
+		case string:
+			out[i+1] = redactText(v)
+		case error:
+			out[i+1] = redactText(v.Error())
+		}
+	}
+	return redactText(msg), out
+}
+
+// 终端输出是否脱敏
+func redactConsole() bool {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+	return redactCfg.Enabled && redactCfg.Console
+}
@@ -1,17 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -25,80 +23,133 @@ type ProfitData struct {
 	Data        map[string]interface{} `json:"data"`
 }
 
-var csvHeaders []string
-var processedFiles sync.Map
-
-// 日志系统
-var logFile *os.File
-var logMutex sync.Mutex
+// 全局上下文和取消函数，用于优雅关闭
+var (
+	globalCtx    context.Context
+	globalCancel context.CancelFunc
+	shutdownWg   sync.WaitGroup
+)
 
-// 初始化日志系统
-func initLogging() error {
-	dataDir := "/Users/yqw/meteora_dlmm/data/log"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("创建data目录失败: %v", err)
+// runDaemon 子命令 run：监听信号与数据目录并执行定时任务。旧的一次性参数（-backtest、-promote、-ban 等）保留兼容
+func runDaemon(args []string) {
+	fs, common := newCommandFlags("run")
+	promotePool := fs.String("promote", "", "将指定池切换为实盘（live）后退出")
+	demotePool := fs.String("demote", "", "将指定池切换为模拟（paper）后退出")
+	withdrawPool := fs.String("withdraw", "", "对指定池部分移除流动性后退出（配合 -percent）")
+	withdrawPercent := fs.Float64("percent", 50, "部分移除比例（百分比）")
+	banAddress := fs.String("ban", "", "将代币或池地址加入黑名单后退出（配合 -ban-kind、-ban-reason、-ban-ttl）")
+	banKind := fs.String("ban-kind", banKindToken, "黑名单类型: token 或 pool")
+	banReason := fs.String("ban-reason", "", "拉黑原因")
+	banTTL := fs.Duration("ban-ttl", 0, "黑名单有效期（如 24h），0 表示永久")
+	unbanAddress := fs.String("unban", "", "从黑名单移除地址后退出")
+	demoFlag := fs.Bool("demo", false, "演示/压测模式：生成合成信号，外部脚本使用模拟输出（参数见配置 demo）")
+	backtestFlag := fs.Bool("backtest", false, "回测后退出（同子命令 backtest）")
+	backtestData := fs.String("backtest-data", "", "回测的价格数据：目录或 .jsonl / .csv 文件（默认价格历史目录）")
+	backtestDays := fs.Int("backtest-days", 0, "只回放最近 N 天的价格，0 表示全部")
+	backtestReport := fs.String("backtest-report", "", "回测报告 JSON 的写入路径（为空时只输出汇总）")
+	importPositions := fs.String("import-positions", "", "从仓位快照 CSV 导入已有仓位后退出（同子命令 state migrate）")
+	daemonFlag := fs.Bool("daemon", false, "在 systemd 等进程管理器下运行：终端输出不含 emoji 与颜色，并带 journald 级别前缀")
+	recordFixtures := fs.String("record-fixtures", "", "把外部命令的真实输出追加到该目录（<target>.json），供集成测试 harness --fixtures 回放")
+	benchFlag := fs.String("bench", "", "容量压测：按逗号分隔的池数逐级爬坡（如 100,500,1000），输出各阶段报告后退出（隐含 -demo）")
+	fs.Parse(args)
+	var benchStages []int
+	if *benchFlag != "" {
+		stages, err := parseBenchStages(*benchFlag)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		benchStages, *demoFlag = stages, true
 	}
+	demoMode = *demoFlag
+	daemonMode = *daemonFlag
+	fixtureRecordDir = *recordFixtures
+	cleanup := initApp(common)
+	defer cleanup()
 
-	// 创建带时间戳的日志文件
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	logPath := filepath.Join(dataDir, fmt.Sprintf("app_%s.log", timestamp))
-
-	var err error
-	logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("创建日志文件失败: %v", err)
+	// CLI：回测后直接退出
+	if *backtestFlag {
+		runBacktestCommand(*backtestData, *backtestDays, *backtestReport)
+		return
 	}
 
-	fmt.Printf("📝 日志文件已创建: %s\n", logPath)
-	return nil
-}
-
-// 写入日志（同时输出到终端和文件）
-func logOutput(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logMessage := fmt.Sprintf("[%s] %s", timestamp, message)
-
-	// 输出到终端
-	fmt.Print(message)
-
-	// 写入日志文件
-	logMutex.Lock()
-	if logFile != nil {
-		logFile.WriteString(logMessage)
-		logFile.Sync()
+	// CLI：导入仓位快照后直接退出
+	if *importPositions != "" {
+		runStateMigrate(*importPositions, currentConfig().PositionImport.Overwrite)
+		return
 	}
-	logMutex.Unlock()
-}
 
-// 关闭日志系统
-func closeLogging() {
-	logMutex.Lock()
-	if logFile != nil {
-		logFile.Close()
-		logFile = nil
+	// CLI：切换池模式后直接退出
+	if *promotePool != "" || *demotePool != "" {
+		pool, mode := *promotePool, poolModeLive
+		if *demotePool != "" {
+			pool, mode = *demotePool, poolModePaper
+		}
+		if err := setPoolMode(pool, mode); err != nil {
+			log.Fatalf("切换池模式失败: %v", err)
+		}
+		return
 	}
-	logMutex.Unlock()
-}
 
-// 全局上下文和取消函数，用于优雅关闭
-var (
-	globalCtx    context.Context
-	globalCancel context.CancelFunc
-	shutdownWg   sync.WaitGroup
-)
-
-func main() {
-	// 初始化日志系统
-	if err := initLogging(); err != nil {
-		log.Fatalf("初始化日志系统失败: %v", err)
+	// CLI：管理黑名单后直接退出（运行中的进程按状态文件修改时间自动重新加载）
+	if *banAddress != "" {
+		if _, err := addBan(*banKind, *banAddress, *banReason, banSourceCLI, *banTTL); err != nil {
+			log.Fatalf("加入黑名单失败: %v", err)
+		}
+		return
+	}
+	if *unbanAddress != "" {
+		ok, err := removeBan(*unbanAddress)
+		if err != nil {
+			log.Fatalf("移除黑名单失败: %v", err)
+		}
+		if !ok {
+			log.Fatalf("黑名单中没有该地址: %s", *unbanAddress)
+		}
+		return
 	}
-	defer closeLogging()
 
 	// 创建可取消的上下文
 	globalCtx, globalCancel = context.WithCancel(context.Background())
 	defer globalCancel()
 
+	// CLI：部分移除流动性后直接退出
+	if *withdrawPool != "" {
+		if !runPartialWithdraw(*withdrawPool, *withdrawPercent, exitReasonManual) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// PID 文件（演示与 dry-run 不写，可与实盘进程同时运行）
+	pidFile := ""
+	if !isDemo() && !isDryRun() {
+		pidFile = currentConfig().Supervisor.PIDFile
+		if err := writePIDFile(pidFile); err != nil {
+			log.Fatalf("写入 PID 文件失败: %v", err)
+		}
+		defer removePIDFile(pidFile)
+	}
+
+	if isPriceOnly() {
+		logOutput("🔬 研究模式（price-only）：仅接收信号与记录价格，不执行任何交易\n")
+	}
+	if isDryRun() {
+		logOutput("🧪 dry-run 模式：外部命令只记录到 %s，状态写入 %s\n", dryRunActionsPath(), dryRunStateDir())
+	}
+	if isDemo() {
+		if err := prepareDemo(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		logOutput("🧪 演示模式：信号来自 %s，外部脚本使用模拟输出，状态写入 %s\n", demoCSVPath(), demoStateDir())
+	}
+	// 启动时读取并核对本进程管理的签名私钥，来源不可用时直接退出
+	if !isDemo() && !isDryRun() && !isPriceOnly() {
+		if err := preloadSigningKeys(); err != nil {
+			log.Fatalf("加载签名私钥失败: %v", err)
+		}
+	}
+	logConfigSummary()
+
 	// 设置信号处理
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -106,7 +157,7 @@ func main() {
 	// 启动信号处理goroutine
 	go func() {
 		sig := <-sigChan
-		logOutput("\n🛑 收到信号 %v，强制终止程序...\n", sig)
+		logOutput("\n🛑 收到信号 %v，停止接收新任务，等待进行中的任务完成（最长 %ds，再次发送信号立即退出）...\n", sig, currentConfig().Shutdown.GracePeriodSeconds)
 		globalCancel()
 
 		// 如果收到第二个信号，立即退出
@@ -115,253 +166,476 @@ func main() {
 		os.Exit(1)
 	}()
 
-	csvPath := "/Users/yqw/dlmm_8_27/data/auto_profit.csv"
-	dataDir := "/Users/yqw/meteora_dlmm/data"
+	// 主备部署：取得主实例租约前作为备用实例等待（同步主实例的状态，不启动任何子系统）
+	if currentConfig().Leader.Enabled && !isDemo() && !isDryRun() {
+		if !waitForLeadership() {
+			logOutput("✅ 备用实例已停止\n")
+			return
+		}
+	}
+
+	dataDir := poolDataDir()
 
 	// 确保data目录存在
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		log.Fatalf("创建data目录失败: %v", err)
 	}
 
-	// 读取CSV头部
-	if err := readCSVHeaders(csvPath); err != nil {
-		log.Fatalf("读取CSV头部失败: %v", err)
+	// 首次运行时导入旧版本的数据目录：先判断（仓位快照导入会写入状态目录），快照中的池优先
+	legacy := legacyImportPending()
+
+	// 按配置导入仓位快照（先于补处理，导入的池不会被当作新池开仓）
+	startupPositionImport()
+	if legacy {
+		startupLegacyImport()
 	}
 
-	// 获取当前文件行数
-	currentLineCount, err := getLineCount(csvPath)
+	// 创建信号输入：各CSV源读取头部与读取进度（按字节偏移追踪，重启后从上次位置继续），以及配置的其他输入
+	ingestors, err := buildIngestors()
 	if err != nil {
-		log.Fatalf("获取文件行数失败: %v", err)
+		log.Fatalf("%v", err)
 	}
-
-	logOutput("开始监听文件: %s\n", csvPath)
 	logOutput("开始监听目录: %s\n", dataDir)
-	logOutput("CSV字段数: %d\n", len(csvHeaders))
-	logOutput("当前行数: %d\n", currentLineCount)
 
-	// 启动价格获取定时任务
-	shutdownWg.Add(1)
-	go func() {
-		defer shutdownWg.Done()
-		startPriceFetcherTicker()
-	}()
+	// 并发控制：最多同时处理 maxConcurrentTasks 个 JSON 任务（可热更新）
+	setTaskCapacity(currentConfig().MaxConcurrent)
 
-	// 启动全局领取奖励定时任务
-	shutdownWg.Add(1)
-	go func() {
-		defer shutdownWg.Done()
-		startGlobalClaimRewardsTicker()
-	}()
+	// 启动事件总线（订阅者：日志、告警、审计日志）
+	startEventBus()
 
-	// 启动jupSwap定时任务
-	shutdownWg.Add(1)
-	go func() {
-		defer shutdownWg.Done()
-		startJupSwapTicker()
-	}()
+	// 启动告警发送协程
+	superviseGo("notifier", startNotifier)
+
+	// 启动背压状态上报（可选）
+	superviseGo("backpressureReporter", startBackpressureReporter)
+
+	// 启动钱包交易监控（可选）
+	superviseGo("walletWatcher", startWalletWatcher)
+
+	// 启动黑名单文件监听
+	superviseGo("banListWatcher", startBanListWatcher)
+
+	// 启动池配置覆盖目录监听
+	superviseGo("poolOverrideWatcher", startPoolOverrideWatcher)
+
+	// 启动停止开关的文件与信号监听
+	superviseGo("killSwitchWatcher", startKillSwitchWatcher)
+
+	// 启动时钟偏差检查
+	superviseGo("clockCheck", startClockCheck)
+
+	// 启动钱包余额监控（可选）
+	superviseGo("balanceMonitor", startBalanceMonitor)
+
+	// 启动配置文件监听（热更新）
+	superviseGo("configWatcher", startConfigWatcher)
+
+	// 启动数据目录检查
+	superviseGo("dataVolumeCheck", startDataVolumeCheck)
+
+	// 启动集群健康检查（可选）
+	superviseGo("clusterHealthCheck", startClusterHealthCheck)
+
+	// 启动 RPC 节点探测（配置了多个节点时）
+	superviseGo("rpcProbe", startRPCProbe)
+
+	// 启动区块哈希预取（可选）
+	superviseGo("blockhashCache", startBlockhashCache)
+
+	// 启动优先费采样（可选）
+	superviseGo("priorityFeeSampler", startPriorityFeeSampler)
+
+	// 启动交易确认跟踪
+	superviseGo("txTracker", startTxTracker)
+
+	// 启动交易成本记录（可选）
+	superviseGo("txCostCollector", startTxCostCollector)
+	superviseGo("alertRules", startAlertRules)
+
+	// 启动 RPC 限流降级监控
+	superviseGo("rpcDegradeMonitor", startRPCDegradeMonitor)
+
+	// 启动汇率记录（报表按事件发生时的汇率折算）
+	superviseGo("fxRateSampler", startFXRateSampler)
+
+	// 启动仓位再平衡检查
+	superviseGo("rebalancer", startRebalancer)
+
+	// 启动仓位老化检查
+	superviseGo("positionAging", startPositionAging)
+
+	// 启动活跃池加领
+	superviseGo("adaptiveClaims", startAdaptiveClaims)
+
+	// 启动池归档与数据目录清理
+	superviseGo("poolArchiver", startPoolArchiver)
+
+	// 启动池状态停留检查
+	superviseGo("poolStateMonitor", startPoolStateMonitor)
+
+	// 启动敞口额度等待检查
+	superviseGo("portfolioRetry", startPortfolioRetry)
+
+	// 启动入场条件过期检查
+	superviseGo("entryTriggerMonitor", startEntryTriggerMonitor)
+
+	superviseGo("priceStoreCompaction", startPriceStoreCompaction)
+
+	// 启动账户订阅（事件驱动的领取、价格获取与再平衡）
+	superviseGo("accountSubscriptions", startAccountSubscriptions)
+
+	// 演示模式：合成信号与负载报告（集成测试自行写入信号）
+	if isDemo() && !isHarness() {
+		superviseGo("demoProducer", func() {
+			if len(benchStages) > 0 {
+				startBench(benchStages)
+			} else {
+				startDemoProducer()
+			}
+		})
+		superviseGo("demoReporter", startDemoReporter)
+	}
+
+	// 启动 HTTP 管理接口（可选）
+	superviseGo("apiServer", startAPIServer)
+
+	superviseGo("grpcServer", startGRPCServer)
+
+	// 注册定时任务：价格获取、全局领取奖励、jupSwap（研究模式下不启动领取与兑换任务）
+	if err := registerJob("price", currentConfig().Schedules.Price, executePriceFetch); err != nil {
+		log.Fatalf("注册价格获取定时任务失败: %v", err)
+	}
+	if !isPriceOnly() {
+		if err := registerJob("claim", currentConfig().Schedules.Claim, executeGlobalClaimRewards); err != nil {
+			log.Fatalf("注册全局领取奖励定时任务失败: %v", err)
+		}
+		if err := registerJob("swap", currentConfig().Schedules.Swap, executeJupSwap); err != nil {
+			log.Fatalf("注册jupSwap定时任务失败: %v", err)
+		}
+		if err := registerJob("pnlReport", currentConfig().Schedules.PnLReport, writePnLReport); err != nil {
+			log.Fatalf("注册盈亏日报定时任务失败: %v", err)
+		}
+	}
+	if currentConfig().DailySummary.Enabled {
+		if err := registerJob("dailySummary", currentConfig().Schedules.DailySummary, writeDailySummary); err != nil {
+			log.Fatalf("注册每日汇总定时任务失败: %v", err)
+		}
+	}
+	if currentConfig().Export.Enabled {
+		if err := registerJob("export", currentConfig().Schedules.Export, executeExport); err != nil {
+			log.Fatalf("注册数据导出定时任务失败: %v", err)
+		}
+	}
+	superviseGo("scheduler", startScheduler)
+	superviseGo("jobWatchdog", startJobWatchdog)
 
 	// 创建文件监听器
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := newFileWatcher("data")
 	if err != nil {
 		log.Fatalf("创建文件监听器失败: %v", err)
 	}
 	defer watcher.Close()
 
-	// 监听CSV文件
-	err = watcher.Add(csvPath)
-	if err != nil {
-		log.Fatalf("添加CSV文件监听失败: %v", err)
-	}
-
-	// 监听data目录
+	// 监听data目录（先于信号输入，补处理积压行写出的池文件也能收到事件）；已有的池文件先于监听记录，之后重命名覆盖它们不算新池
+	poolFiles := newPoolFileTracker(dataDir)
 	err = watcher.Add(dataDir)
 	if err != nil {
 		log.Fatalf("添加data目录监听失败: %v", err)
 	}
 
-	// 并发控制：最多同时处理 N 个 JSON 任务
-	const maxConcurrent = 20
-	sem := make(chan struct{}, maxConcurrent)
+	// 处理池文件：同一文件只处理一次（标记持久化，重启后不会重复入场），加入任务队列异步执行
+	dispatchPoolFile := func(path string) {
+		if !claimProcessed(path) {
+			return
+		}
+		enqueuePoolFile(path)
+	}
+
+	// 核对上次运行中断的命令（预写日志中没有结束记录的 intent）：重新执行领取 / 兑换，按链上状态确认开仓与平仓
+	reconcileWAL()
+
+	// 启动补处理：停机期间写入但未处理的池文件
+	if !isPaused() {
+		for _, path := range missedPoolFiles(dataDir) {
+			logOutput("🧭 补处理池文件: %s\n", path)
+			dispatchPoolFile(path)
+		}
+	}
+
+	// 启动信号输入，信号经 channel 交给主循环串行处理
+	signals := make(chan Signal, 256)
+	for _, ing := range ingestors {
+		shutdownWg.Add(1)
+		go func(ing SignalIngestor) {
+			defer shutdownWg.Done()
+			supervise("ingest:"+ing.Name(), func() error { return runIngestor(ing, signals) })
+		}(ing)
+	}
 
-	// 监听事件
+	// 监听事件（定期心跳供存活检查判断主循环没有卡住）
+	watcherRunning.Store(true)
+	noteWatcherHeartbeat()
+	heartbeat := time.NewTicker(watcherHeartbeatInterval)
+	defer heartbeat.Stop()
 	for {
+		noteWatcherHeartbeat()
 		select {
 		case <-globalCtx.Done():
 			logOutput("🛑 收到关闭信号，停止文件监听...\n")
+			watcherRunning.Store(false)
 			watcher.Close()
+			stopJobQueue()
+			drainInFlight()
 			logOutput("⏳ 等待所有goroutine完成...\n")
 			shutdownWg.Wait()
+			resignLeadership()
+			stopEventBus()
+			if isDemo() {
+				finishDemo()
+			}
+			notifySync(eventShutdown, levelWarning, "机器人已停止", "收到关闭信号，程序已优雅关闭")
 			logOutput("✅ 程序已优雅关闭\n")
+			if restartRequested.Load() {
+				removePIDFile(pidFile)
+				cleanup()
+				os.Exit(exitCodeRestart)
+			}
 			return
+		case <-heartbeat.C:
+
+		case <-dataVolumeRecovered:
+			// 卷卸载后目录监听已失效：重新监听并补处理不可用期间写入的池文件
+			watcher.Remove(dataDir)
+			poolFiles = newPoolFileTracker(dataDir)
+			if err := watcher.Add(dataDir); err != nil {
+				logError("❌ 重新监听data目录失败", "error", err)
+			}
+			if !isPaused() {
+				for _, path := range missedPoolFiles(dataDir) {
+					logOutput("🧭 补处理池文件: %s\n", path)
+					dispatchPoolFile(path)
+				}
+			}
+
 		case event, ok := <-watcher.Events:
 			if !ok {
+				watcherRunning.Store(false)
 				return
 			}
 
-			// 处理CSV文件写入事件
-			if event.Name == csvPath && event.Op&fsnotify.Write == fsnotify.Write {
-				// 文件被写入，检查是否有新行
-				time.Sleep(200 * time.Millisecond) // 等待写入完成
-				newLineCount, err := getLineCount(csvPath)
-				if err != nil {
-					continue
-				}
-
-				if newLineCount > currentLineCount {
-					logOutput("🔄 检测到 %d 行新增，开始处理...\n", newLineCount-currentLineCount)
-					processNewLines(csvPath, dataDir, currentLineCount)
-					currentLineCount = newLineCount
-					logOutput("📊 当前总行数: %d\n", currentLineCount)
+			// 处理data目录中的新JSON文件（仅响应Create事件，带并发上限与去重；原子写入覆盖已有池文件不算新池）
+			if isPoolFile(dataDir, event.Name) {
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					poolFiles.remove(event.Name)
 				}
-			}
-
-			// 处理data目录中的新JSON文件（仅响应Create事件，带并发上限与去重）
-			if strings.HasPrefix(event.Name, dataDir) && strings.HasSuffix(event.Name, ".json") {
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					// 去重：只处理一次
-					if _, loaded := processedFiles.LoadOrStore(event.Name, true); !loaded {
+				if event.Op&fsnotify.Create == fsnotify.Create && poolFiles.create(event.Name) {
+					if isPaused() {
+						logOutput("⏸️ 已暂停，忽略JSON文件事件: %s\n", event.Name)
+						recordSkip(subsystemEntry, skipPaused, "", "", event.Name)
+						continue
+					}
+					time.Sleep(100 * time.Millisecond) // 等待文件写入完成
+					if !isProcessed(event.Name) {
 						logOutput("🆕 检测到JSON文件事件: %s, 操作: %v\n", event.Name, event.Op)
-						time.Sleep(100 * time.Millisecond) // 等待文件写入完成
-						// 占用并发令牌
-						sem <- struct{}{}
-						go func(path string) {
-							defer func() { <-sem }()
-							processNewJSONFile(path)
-						}(event.Name)
+						callRecovered("dispatchPoolFile", "", func() { dispatchPoolFile(event.Name) })
 					}
 				}
 			}
 
+		case sig := <-signals:
+			callRecovered("handleSignal:"+sig.Source, "", func() { handleSignal(sig) })
+
 		case err, ok := <-watcher.Errors:
 			if !ok {
+				watcherRunning.Store(false)
 				return
 			}
-			log.Printf("监听错误: %v", err)
+			logError("❌ 监听错误", "error", err)
 		}
 	}
 }
 
-func readCSVHeaders(csvPath string) error {
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// handleSignal 处理一条新池信号（各信号输入经主循环串行调用），池记录写入信号的输出目录
+func handleSignal(sig Signal) {
+	metricCSVRows.Inc("received")
 
-	reader := csv.NewReader(file)
-	headers, err := reader.Read()
-	if err != nil {
-		return err
+	// 解析数据（保持原始字符串、不做清洗）
+	profitData := parseSignalData(sig.Data)
+	if profitData == nil {
+		metricCSVRows.Inc("invalid")
+		recordSkip(subsystemEntry, skipInvalid, "", "", "信号缺少 poolAddress（来源 "+sig.Source+"）")
+		return
 	}
+	// 标记信号来源，便于下游按源筛选
+	profitData.Data["source"] = sig.Source
 
-	csvHeaders = headers
-	return nil
-}
+	// 地址字段无效（如截断、混入空格或非 base58 字符）的信号直接拒绝
+	if field, value, reason := validateSignalFields(profitData.Data); field != "" && !isDemo() {
+		ca, _ := profitData.Data["ca"].(string)
+		recordCSVRejection(sig, profitData.PoolAddress, ca, field, value, reason)
+		return
+	}
+	signalCA, _ := profitData.Data["ca"].(string)
+	publishEvent(BusEvent{Type: busSignalReceived, Stage: subsystemEntry, Pool: profitData.PoolAddress, Token: signalCA,
+		Fields: map[string]string{"source": sig.Source, "line": strconv.Itoa(sig.Line)}})
 
-func getLineCount(filePath string) (int, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return 0, err
+	// 数据目录不可用时无法写入池文件
+	if dataVolumeUnavailable() {
+		metricCSVRows.Inc("unavailable")
+		logOutput("💾 数据目录不可用，跳过信号: %s\n", profitData.PoolAddress)
+		ca, _ := profitData.Data["ca"].(string)
+		recordSkip(subsystemEntry, skipUnhealthy, profitData.PoolAddress, ca, "数据目录不可用")
+		return
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	count := 0
-	for scanner.Scan() {
-		count++
+	// 过期信号不再入场
+	if detail, ok := checkSignalFreshness(profitData.Data); !ok {
+		metricCSVRows.Inc("stale")
+		logOutput("⌛ 信号已过期，跳过: %s（%s）\n", profitData.PoolAddress, detail)
+		ca, _ := profitData.Data["ca"].(string)
+		recordSkip(subsystemEntry, skipStale, profitData.PoolAddress, ca, detail)
+		return
 	}
 
-	return count, scanner.Err()
-}
+	// 按当前参数档位的字段下限过滤信号
+	if field := profileFilterSignal(profitData.Data); field != "" {
+		metricCSVRows.Inc("filtered")
+		logOutput("🚫 信号未达到档位 %s 的 %s 下限，跳过: %s\n", activeProfileName(), field, profitData.PoolAddress)
+		ca, _ := profitData.Data["ca"].(string)
+		recordSkip(subsystemEntry, skipBelowThreshold, profitData.PoolAddress, ca, fmt.Sprintf("档位 %s 的 %s 下限", activeProfileName(), field))
+		return
+	}
 
-func processNewLines(csvPath, dataDir string, lastLineCount int) {
-	file, err := os.Open(csvPath)
+	// 按协议选择执行层后端并记录到池文件，不支持的协议不入场
+	dex, err := signalDex(profitData.PoolAddress, profitData.Data)
 	if err != nil {
+		metricCSVRows.Inc("invalid")
+		logOutput("🚫 %v，跳过: %s\n", err, profitData.PoolAddress)
+		ca, _ := profitData.Data["ca"].(string)
+		recordSkip(subsystemEntry, skipInvalid, profitData.PoolAddress, ca, err.Error())
 		return
 	}
-	defer file.Close()
+	profitData.Data[poolProtocolField] = dex.Name()
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // 允许字段数量不一致
-
-	// 跳过已处理的行
-	for i := 0; i < lastLineCount; i++ {
-		_, err := reader.Read()
-		if err != nil {
-			if err == io.EOF {
-				return
+	// 同一代币存在多个池时择优（记录 CSV 原始池地址）：候选只来自 DLMM API，其他协议的信号沿用原池；
+	// 候选池在 dex.pools 中指定了其他协议时同样沿用原池
+	if ca, ok := profitData.Data["ca"].(string); ok && ca != "" && dex.Name() == protocolMeteoraDLMM {
+		if best := selectBestPool(ca, profitData.PoolAddress); best != profitData.PoolAddress {
+			if d, err := signalDex(best, profitData.Data); err == nil && d.Name() == protocolMeteoraDLMM {
+				profitData.Data["csvPoolAddress"] = profitData.PoolAddress
+				profitData.Data["poolAddress"] = best
+				profitData.PoolAddress = best
 			}
-			continue
 		}
 	}
 
-	// 处理新行
-	lineNum := lastLineCount + 1
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			lineNum++
-			continue
-		}
-
-		if len(record) < 1 {
-			lineNum++
-			continue
-		}
-
-		// 解析数据（保持原始字符串、不做清洗）
-		profitData := parseCSVRecord(record)
+	// 名单策略：入场
+	ca, _ := profitData.Data["ca"].(string)
+	if !enforceListPolicy(subsystemEntry, profitData.PoolAddress, ca) {
+		metricCSVRows.Inc("banned")
+		logOutput("🚫 名单策略不允许入场，跳过信号: %s\n", profitData.PoolAddress)
+		return
+	}
 
-		// 保存为JSON文件（poolAddress 缺失则用时间戳+行号命名）
-		jsonFileName := fmt.Sprintf("%s.json", profitData.PoolAddress)
-		if profitData.PoolAddress == "" {
-			jsonFileName = fmt.Sprintf("row_%d_%d.json", time.Now().Unix(), lineNum)
-		}
-		jsonFilePath := filepath.Join(dataDir, jsonFileName)
+	// 同一代币刚在任一池入场：冷却期内忽略（信号可带 overrideField 跳过）
+	if detail, ok := checkTokenCooldown(profitData); !ok {
+		metricCSVRows.Inc("cooldown")
+		logOutput("🧊 代币入场冷却中，跳过信号: %s（%s）\n", profitData.PoolAddress, detail)
+		recordSkip(subsystemEntry, skipCooldown, profitData.PoolAddress, ca, detail)
+		return
+	}
 
-		// 输出内容：原样 headers、原样 record、以及按表头映射的 data
-		out := map[string]interface{}{
-			"poolAddress": profitData.PoolAddress,
-			"headers":     csvHeaders,
-			"record":      record,
-			"data":        profitData.Data,
-		}
+	// 准入规则：流动性、bin step、代币年龄、创建者、持仓数与单代币敞口
+	if rule, detail := admissionCheck(profitData); rule != "" {
+		metricCSVRows.Inc("rejected")
+		recordAdmissionRejection(sig, profitData, rule, detail)
+		return
+	}
 
-		jsonData, err := json.MarshalIndent(out, "", "  ")
-		if err != nil {
-			lineNum++
-			continue
-		}
+	// 代币安全检查：风险评分接口与链上铸币 / 冻结权限、持有者集中度
+	if !tokenSafetyCheck(profitData) {
+		metricCSVRows.Inc("unsafe")
+		return
+	}
 
-		err = os.WriteFile(jsonFilePath, jsonData, 0644)
-		if err != nil {
-			lineNum++
-			continue
-		}
+	// 同一代币已在其他池持仓时按 duplicateToken 策略处理
+	switch checkDuplicateToken(profitData) {
+	case duplicateSkip:
+		metricCSVRows.Inc("duplicate")
+		recordSkip(subsystemEntry, skipDuplicate, profitData.PoolAddress, ca, "同一代币已在其他池入场")
+		return
+	case duplicateReplace:
+		pendingPoolRows.Add(1)
+		safeGo("duplicateReplace", profitData.PoolAddress, func() {
+			defer pendingPoolRows.Done()
+			if closeDuplicatePools(profitData) {
+				savePoolRow(sig, profitData)
+			}
+		})
+		return
+	}
 
-		logOutput("✅ 新增行已保存: %s -> %s\n", profitData.PoolAddress, jsonFilePath)
-		lineNum++
+	// 同一池已有未平仓仓位：不覆盖池文件（其中的仓位地址供领取与平仓使用）
+	if _, ok := checkAddGuard(profitData.PoolAddress, ca, profitData.Data); !ok {
+		metricCSVRows.Inc("duplicate")
+		return
 	}
+
+	savePoolRow(sig, profitData)
 }
 
-func parseCSVRecord(record []string) *ProfitData {
-	if len(record) < 1 {
-		return nil
+// 保存为JSON文件（poolAddress 缺失则用时间戳+行号命名）
+func savePoolRow(sig Signal, profitData *ProfitData) {
+	dataDir := outputDirOrData(sig.OutputDir)
+	jsonFileName := fmt.Sprintf("%s.json", profitData.PoolAddress)
+	if profitData.PoolAddress == "" {
+		jsonFileName = fmt.Sprintf("row_%d_%d.json", time.Now().Unix(), sig.Line)
+	}
+	jsonFilePath := filepath.Join(dataDir, jsonFileName)
+
+	// 输出内容：原样 headers、原样 record、以及按表头映射的 data
+	out := map[string]interface{}{
+		"poolAddress": profitData.PoolAddress,
+		"source":      sig.Source,
+		"headers":     sig.Headers,
+		"record":      sig.Record,
+		"data":        profitData.Data,
+	}
+	// 链上信息（代币、精度、符号、bin step、费率），供日志与下游按符号显示
+	if meta := enrichPoolMetadata(profitData.PoolAddress); meta != nil {
+		out["metadata"] = meta
+	}
+	// 加锁后读取与写入，addLiquidity.ts 同时写回仓位地址时不会互相覆盖
+	unlock, err := lockDataFile(jsonFilePath)
+	if err != nil {
+		logError("❌ 写入池文件失败", "file", jsonFilePath, "error", err)
+		return
+	}
+	// 追加流动性的信号保留池文件中已有的仓位地址与开仓范围
+	_, open := poolOpenPosition(profitData.PoolAddress)
+	if open && topUpRequested(profitData.Data) {
+		keepOpenPositionFields(jsonFilePath, out)
+	}
+	err = writeJSONFileAtomic(jsonFilePath, out)
+	unlock()
+	if err != nil {
+		logError("❌ 写入池文件失败", "file", jsonFilePath, "error", err)
+		return
 	}
 
-	// 创建数据映射
-	data := make(map[string]interface{})
+	logOutput("✅ 新增行已保存: [%s] %s -> %s\n", sig.Source, poolLabel(profitData.PoolAddress), jsonFilePath)
+	metricCSVRows.Inc("saved")
+	noteSavedPoolRow(jsonFilePath)
+	// 写入监听目录的新池进入 SIGNALED（已有仓位的池由开仓流程处理）
+	if !open && dataDir == poolDataDir() {
+		transitionPool(profitData.PoolAddress, poolSignaled, sig.Source)
+	}
+}
 
-	// 将每个字段与对应的头部名称配对，保持原始字符串格式
-	for i, value := range record {
-		if i < len(csvHeaders) {
-			header := csvHeaders[i]
-			// 直接保存为字符串，不进行任何解析
-			data[header] = value
-		}
+// 从信号字段中取出 poolAddress（缺失时视为无效信号）
+func parseSignalData(data map[string]interface{}) *ProfitData {
+	if len(data) == 0 {
+		return nil
 	}
 
 	// 获取poolAddress
@@ -382,27 +656,30 @@ func parseCSVRecord(record []string) *ProfitData {
 	}
 }
 
-// processNewJSONFile 处理新创建的JSON文件，执行addLiquidity.ts命令
-func processNewJSONFile(jsonFilePath string) {
+// processNewJSONFile 处理新创建的JSON文件，执行addLiquidity.ts命令，返回处理结果（记录到已处理标记）
+func processNewJSONFile(jsonFilePath string) (outcome string) {
 	// 读取JSON文件（单次读取）
 	jsonData, err := os.ReadFile(jsonFilePath)
 	if err != nil {
-		log.Printf("读取JSON文件失败: %s, 错误: %v", jsonFilePath, err)
-		return
+		logError("❌ 读取JSON文件失败", "file", jsonFilePath, "error", err)
+		recordSkip(subsystemEntry, skipInvalid, "", "", "读取池文件失败: "+jsonFilePath)
+		return outcomeInvalid
 	}
 
 	// 解析JSON数据
 	var profitData ProfitData
 	if err := json.Unmarshal(jsonData, &profitData); err != nil {
-		log.Printf("解析JSON文件失败: %s, 错误: %v", jsonFilePath, err)
-		return
+		logError("❌ 解析JSON文件失败", "file", jsonFilePath, "error", err)
+		recordSkip(subsystemEntry, skipInvalid, "", "", "解析池文件失败: "+jsonFilePath)
+		return outcomeInvalid
 	}
 
 	// 提取所需参数
 	poolAddress := profitData.PoolAddress
 	if poolAddress == "" {
-		log.Printf("JSON文件中缺少poolAddress: %s", jsonFilePath)
-		return
+		logWarn("⚠️ JSON文件中缺少poolAddress", "file", jsonFilePath)
+		recordSkip(subsystemEntry, skipInvalid, "", "", "池文件缺少 poolAddress: "+jsonFilePath)
+		return outcomeInvalid
 	}
 
 	// 从Data中提取ca和last_updated_first
@@ -418,126 +695,243 @@ func processNewJSONFile(jsonFilePath string) {
 		}
 	}
 
+	if !enforceListPolicy(subsystemEntry, poolAddress, ca) {
+		logOutput("🚫 名单策略不允许入场，跳过开仓: %s\n", poolAddress)
+		return outcomeBanned
+	}
+	// 演示模式遗留的合成池不进入实盘
+	if !isDemo() && isDemoPool(poolAddress, profitData.Data) {
+		logWarn("⚠️ 跳过演示模式生成的池文件", "file", jsonFilePath)
+		recordSkip(subsystemEntry, skipFiltered, poolAddress, ca, "演示模式生成的池")
+		return outcomeInvalid
+	}
+	// 同一池已有未平仓仓位（CSV 重复行、池文件被重新生成）：拒绝再次开仓，信号明确要求时追加到已有仓位
+	topUp, ok := checkAddGuard(poolAddress, ca, profitData.Data)
+	if !ok {
+		return outcomeDuplicate
+	}
+	defer func() { notePoolOutcome(poolAddress, outcome) }()
+	topUpOpen := topUp != "" || (isPaperPool(poolAddress) && paperHasOpenPosition(poolAddress))
+	if topUpOpen {
+		logOutput("➕ 信号要求对已有仓位追加流动性: %s（仓位 %s）\n", poolLabel(poolAddress), topUp)
+	}
+
 	// 不对 ca/last_updated_first 做强制校验：缺失则跳过对应参数
 
+	// A/B 分配（未启用时为空）
+	variant := assignVariant(poolAddress, profitData.Data)
+
+	newPoolFields := map[string]string{"pool": poolAddress, "ca": ca}
+	if meta := poolMetadata(poolAddress); meta != nil {
+		newPoolFields["pair"] = meta.pairName()
+		newPoolFields["binStep"] = strconv.Itoa(meta.BinStep)
+		newPoolFields["baseFee"] = strconv.FormatFloat(meta.BaseFeePercent, 'f', -1, 64) + "%"
+	}
+	notifyKeyed(eventNewPool, levelInfo, poolAddress, "发现新池", "", newPoolFields)
+
+	// 研究模式：信号已落盘供价格任务采集，不添加流动性
+	if isPriceOnly() {
+		logOutput("🔬 研究模式，仅记录信号不添加流动性: %s\n", poolAddress)
+		recordSkip(subsystemEntry, skipMode, poolAddress, ca, "研究模式")
+		return outcomePriceOnly
+	}
+
+	// 交易时段之外或停止开关开启时只记录信号，不开仓（经任务队列处理时已在调度时检查，这里覆盖 signal --open 等直接处理）
+	if reason, detail := tradingHalt(jobAddLiquidity); reason != "" {
+		logOutput("🌙 %s，跳过开仓: %s\n", detail, poolAddress)
+		recordSkip(subsystemEntry, reason, poolAddress, ca, detail)
+		return haltOutcome(reason)
+	}
+
+	// 入场条件已满足的池文件：等待期间由 TTL 控制，不再按信号新鲜度拒绝
+	triggered := entryTriggered(jsonFilePath)
+
+	// 排队等待期间信号可能已过期，开仓前再检查一次
+	if age, err := signalAge(profitData.Data); err == nil {
+		metricSignalAge.Observe(age.Seconds())
+	}
+	if detail, ok := checkSignalFreshness(profitData.Data); !ok && !triggered {
+		logOutput("⌛ 信号已过期，跳过开仓: %s（%s）\n", poolAddress, detail)
+		recordSkip(subsystemEntry, skipStale, poolAddress, ca, detail)
+		return outcomeStale
+	}
+
+	// 节点落后或集群拥堵时发送的交易大多失败，暂不开仓
+	if clusterEntriesPaused() {
+		logOutput("🩺 RPC 节点或集群不健康，跳过开仓: %s\n", poolAddress)
+		recordSkip(subsystemEntry, skipUnhealthy, poolAddress, ca, "RPC 节点或集群不健康")
+		return outcomeClusterUnhealthy
+	}
+
+	// 入场条件（价格接近信号价格或回撤）：挂起池文件，由价格获取触发开仓
+	if !triggered && !topUpOpen && parkForEntry(jsonFilePath, poolAddress, ca, profitData.Data) {
+		return outcomeEntryWaiting
+	}
+
+	// 全局敞口上限：计算开仓金额并预留额度，超出时等待或放弃
+	allocSOL, limitOutcome, ok := reservePortfolio(jsonFilePath, poolAddress, ca)
+	if !ok {
+		return limitOutcome
+	}
+	defer releasePortfolio(poolAddress)
+
 	// 构建命令（按存在的字段拼接参数）
-	args := []string{"ts-node", "addLiquidity.ts", fmt.Sprintf("--pool=%s", poolAddress)}
+	args := []string{fmt.Sprintf("--pool=%s", poolAddress)}
 	if ca != "" {
 		args = append(args, fmt.Sprintf("--token=%s", ca))
 	}
 	if lastUpdatedFirst != "" {
 		args = append(args, fmt.Sprintf("--last_updated_first=%s", lastUpdatedFirst))
 	}
+	// 按近期波动率决定 bin 范围（样本不足时沿用脚本默认宽度）
+	if pct := volatilityRangePct(ca); pct > 0 {
+		args = append(args, fmt.Sprintf("--range-pct=%s", strconv.FormatFloat(pct, 'f', 2, 64)))
+	}
+	// 流动性分布策略（按池配置或信号字段选择）
+	args = append(args, liquidityArgs(poolAddress, profitData.Data)...)
+	// 参数档位的开仓金额与滑点（参与 A/B 的池使用所属变体的档位）
+	args = append(args, profileAddLiquidityArgs(poolAddress)...)
+	if allocSOL > 0 && !ladderEnabled() {
+		args = withSolAmount(args, allocSOL)
+	}
+	// 优先费（按近期区块采样动态设置）
+	args = append(args, priorityFeeArgs(feeOpAddLiquidity)...)
+	if topUp != "" {
+		args = append(args, "--top-up="+topUp)
+	}
+	// 阶梯仓位：主仓位使用第一个档位的宽度与金额（追加时不再开其他档位）
+	baseArgs := args
+	if ladderEnabled() && !topUpOpen {
+		args = append(append([]string{}, baseArgs...), currentConfig().Ladder.Legs[0].args(0)...)
+	}
 	// 创建带超时的上下文（5分钟超时）
 	ctx, cancel := context.WithTimeout(globalCtx, 5*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "npx", args...)
+	// 模拟池：只记录将执行的命令
+	if isPaperPool(poolAddress) {
+		if !beginAdding(poolAddress, ca) {
+			return outcomeDuplicate
+		}
+		simulatePoolAction(poolAddress, "addLiquidity", poolDex(poolAddress).CommandLine(dexOpAddLiquidity, args...))
+		if topUpOpen {
+			notePositionTopUp(poolAddress, mainDepositSOL(poolAddress))
+			recordPnLDeposit(poolAddress, ca, "", mainDepositSOL(poolAddress))
+			logOutput("✅ [paper] 已模拟追加流动性: %s\n", poolAddress)
+			return outcomePaper
+		}
+		if ladderEnabled() {
+			openLadderLegs(poolAddress, ca, baseArgs)
+		}
+		positionOpened(poolAddress, ca)
+		noteTokenEntry(ca, poolAddress)
+		recordPnLDeposit(poolAddress, ca, "", mainDepositSOL(poolAddress))
+		logOutput("✅ [paper] 新增池已模拟开仓: %s\n", poolAddress)
+		return outcomePaper
+	}
 
-	// 设置工作目录为当前目录
-	cmd.Dir = "/Users/yqw/meteora_dlmm"
+	// 速率保护：超出每小时开仓/投入上限时暂停自动化
+	if !rateGuardAllow(rateOpen) {
+		logOutput("🛑 超出速率上限，跳过开仓: %s\n", poolAddress)
+		recordSkip(subsystemEntry, skipQuota, poolAddress, ca, "速率保护")
+		return outcomeRateLimited
+	}
+	if !beginAdding(poolAddress, ca) {
+		return outcomeDuplicate
+	}
 
 	// 执行命令
-	logOutput("🚀 执行命令: %s\n", strings.Join(cmd.Args, " "))
+	profileName, _ := poolProfile(poolAddress)
+	dex := poolDex(poolAddress)
+	logOutput("🚀 执行命令: %s（池: %s，参数档位: %s，变体: %s）\n", strings.Join(dex.CommandLine(dexOpAddLiquidity, args...), " "), poolLabel(poolAddress), profileName, variant)
 
-	// 执行命令并捕获输出（单次执行）
-	output, err := cmd.CombinedOutput()
+	// 执行命令并捕获输出（按 exec 策略重试）；开仓前为池分配钱包，后续领取/移除沿用
+	wallet := assignPoolWallet(poolAddress)
+	// 预创建交易对代币的关联代币账户，之后的领取、兑换不再各自创建
+	ensureTokenAccounts(withWallet(ctx, wallet), poolAddress, ca)
+	output, err := dex.AddLiquidity(withWallet(ctx, wallet), args...)
+	metricAddLiquidity.Inc(resultLabel(err))
 
-	// 实时显示输出
-	logOutput("%s", string(output))
+	// 输出到终端和日志文件（逐行输出时已在执行中写入）
+	logCommandOutput(output)
 
 	// 检查是否有错误
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("⏰ 执行addLiquidity.ts超时（5分钟）: %v", err)
+			logError("⏰ 执行addLiquidity.ts超时（5分钟）", "pool", poolAddress, "token", ca, "error", err)
 		} else {
-			log.Printf("❌ 执行addLiquidity.ts失败: %v", err)
+			logError("❌ 执行addLiquidity.ts失败", "pool", poolAddress, "token", ca, "error", err)
 		}
-		return
+		notifyKeyed(eventAddLiquidityFailure, levelCritical, poolAddress, "添加流动性失败", err.Error(), map[string]string{"pool": poolAddress, "ca": ca})
+		publishError(subsystemEntry, poolAddress, ca, err, map[string]string{"target": "addLiquidity"})
+		// 追加失败时原仓位仍在
+		if topUpOpen {
+			transitionPoolFrom(poolAddress, poolAdding, poolActive, "追加流动性失败")
+		}
+		return outcomeFailed
 	}
 
-	logOutput("✅ addLiquidity.ts执行成功\n")
-
-	// 不再为单个池启动定时任务，改为全局定时任务处理所有池
-	// 这里只记录日志，实际领取由全局定时任务处理
-	logOutput("✅ 新增池已处理: %s，将由全局定时任务处理领取奖励\n", poolAddress)
-}
-
-// startGlobalClaimRewardsTicker 全局领取奖励定时任务，扫描data目录下所有JSON文件
-func startGlobalClaimRewardsTicker() {
-	logOutput("🕐 启动全局领取奖励定时任务（每分钟10秒和40秒）\n")
-
-	// 计算到下一个10秒和40秒的时间
-	now := time.Now()
-	nextMinute := now.Truncate(time.Minute).Add(time.Minute)
-	nextTarget10 := nextMinute.Add(10 * time.Second) // 10秒
-	nextTarget40 := nextMinute.Add(40 * time.Second) // 40秒
-
-	// 如果当前时间已经过了这分钟的10秒，则等到下一分钟的10秒
-	if now.After(nextTarget10) {
-		nextTarget10 = nextTarget10.Add(time.Minute)
-	}
-	// 如果当前时间已经过了这分钟的40秒，则等到下一分钟的40秒
-	if now.After(nextTarget40) {
-		nextTarget40 = nextTarget40.Add(time.Minute)
+	logInfo("✅ addLiquidity.ts执行成功", "pool", poolAddress, "token", ca)
+	notifyKeyed(eventAddLiquiditySuccess, levelInfo, poolAddress, "添加流动性成功", "", map[string]string{"pool": poolAddress, "ca": ca})
+	added := "开仓"
+	if topUpOpen {
+		added = "追加流动性"
 	}
-
-	// 选择最近的时间点
-	var nextTarget time.Time
-	if nextTarget10.Before(nextTarget40) {
-		nextTarget = nextTarget10
-	} else {
-		nextTarget = nextTarget40
+	publishEvent(BusEvent{Type: busLiquidityAdded, Stage: subsystemEntry, Pool: poolAddress, Token: ca, Detail: added,
+		Fields: map[string]string{"solAmount": formatFloat(mainDepositSOL(poolAddress)), "position": readPositionFromPoolJSON(poolAddress)}})
+	if topUpOpen {
+		notePositionTopUp(poolAddress, mainDepositSOL(poolAddress))
+		recordPnLDeposit(poolAddress, ca, topUp, mainDepositSOL(poolAddress))
+		recordRateEvent(rateOpen, mainDepositSOL(poolAddress))
+		logOutput("✅ 已追加流动性: %s\n", poolAddress)
+		return outcomeSuccess
 	}
+	positionOpened(poolAddress, ca)
+	noteTokenEntry(ca, poolAddress)
+	recordPnLDeposit(poolAddress, ca, readPositionFromPoolJSON(poolAddress), mainDepositSOL(poolAddress))
+	recordRateEvent(rateOpen, mainDepositSOL(poolAddress))
 
-	initialDelay := nextTarget.Sub(now)
-	logOutput("⏰ 距离下次领取奖励还有: %v\n", initialDelay.Round(time.Second))
-
-	// 等待到下一个时间点，但可以被取消
-	select {
-	case <-globalCtx.Done():
-		logOutput("🛑 收到关闭信号，停止全局领取奖励定时任务\n")
-		return
-	case <-time.After(initialDelay):
-		// 继续执行
+	if ladderEnabled() {
+		openLadderLegs(poolAddress, ca, baseArgs)
 	}
 
-	// 立即执行一次
-	executeGlobalClaimRewards()
-
-	// 然后每分钟的10秒和40秒执行
-	ticker := time.NewTicker(1 * time.Second) // 每秒检查一次
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-globalCtx.Done():
-			logOutput("🛑 收到关闭信号，停止全局领取奖励定时任务\n")
-			return
-		case <-ticker.C:
-			now := time.Now()
-			second := now.Second()
-			// 在10秒和40秒时执行
-			if second == 10 || second == 40 {
-				executeGlobalClaimRewards()
-			}
-		}
-	}
+	// 不再为单个池启动定时任务，改为全局定时任务处理所有池
+	// 这里只记录日志，实际领取由全局定时任务处理
+	logOutput("✅ 新增池已处理: %s，将由全局定时任务处理领取奖励\n", poolAddress)
+	return outcomeSuccess
 }
 
 // executeGlobalClaimRewards 执行全局领取奖励
 func executeGlobalClaimRewards() {
+	if isPaused() {
+		logOutput("⏸️ 已暂停，跳过本轮全局领取奖励\n")
+		recordSkip(subsystemClaim, skipPaused, "", "", "本轮全局领取")
+		return
+	}
+	if !profileClaimDue() {
+		logOutput("⏭️ 未到参数档位 %s 的领取间隔，跳过本轮全局领取奖励\n", activeProfileName())
+		recordSkip(subsystemClaim, skipCooldown, "", "", "参数档位 "+activeProfileName()+" 的领取间隔")
+		return
+	}
 	logOutput("🔄 开始全局领取奖励 - %s\n", time.Now().Format("15:04:05"))
+	metricTickerRuns.Inc("claim")
+	sweepOrphanLadderLegs()
+	sweepClosedPositions()
 
 	// 获取data目录下所有JSON文件
-	dataDir := "/Users/yqw/meteora_dlmm/data"
+	dataDir := poolDataDir()
 	files, err := os.ReadDir(dataDir)
 	if err != nil {
-		log.Printf("读取data目录失败: %v", err)
+		logError("❌ 读取data目录失败", "error", err)
 		return
 	}
 
-	poolCount := 0
+	beginClaimRound()
+	defer finishClaimRound()
+
+	// 收集有仓位的池（模拟池检查模拟仓位）
+	positions := map[string]string{}
+	var pools, positionList []string
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
 			continue
@@ -546,33 +940,93 @@ func executeGlobalClaimRewards() {
 		// 提取poolAddress（去掉.json后缀）
 		poolAddress := strings.TrimSuffix(file.Name(), ".json")
 
-		// 检查是否有positionAddress
+		// 已平仓或正在平仓的池不再领取
+		if state, ok := poolStateOf(poolAddress); ok && (state == poolClosed || state == poolExiting) {
+			continue
+		}
+		// 检查是否有positionAddress（模拟池检查模拟仓位）
 		positionAddress := readPositionFromPoolJSON(poolAddress)
-		if positionAddress == "" {
+		if positionAddress == "" && !(isPaperPool(poolAddress) && paperHasOpenPosition(poolAddress)) {
+			continue
+		}
+		pools = append(pools, poolAddress)
+		positions[poolAddress] = positionAddress
+		if positionAddress != "" && !isPaperPool(poolAddress) {
+			positionList = append(positionList, positionAddress)
+		}
+	}
+
+	// 批量读取仓位账户，没有未领取手续费与奖励的仓位不发送领取交易
+	var pendingClaims map[string]PendingClaim
+	if currentConfig().ClaimPolicy.SkipEmpty && !isDemo() {
+		pendingClaims = prefetchPendingClaims(positionList)
+	}
+
+	poolCount, subscribed, inFlight := 0, 0, 0
+	round := accountSubPollRound("claim")
+	var pending []<-chan struct{}
+	for _, poolAddress := range pools {
+		positionAddress := positions[poolAddress]
+		// 账户订阅正常的池由事件触发领取，定时领取每 pollEvery 轮兜底一次
+		if accountSubSkipPoll(round, poolAddress) {
+			subscribed++
+			continue
+		}
+		if !enforceListPolicy(subsystemClaim, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress)) {
+			noteClaimSkipped(poolAddress)
+			continue
+		}
+		// 上一次领取（上一轮、事件或 API 触发）仍未结束：本轮不再等待该池
+		if claimInFlight(poolAddress) {
+			logOutput("⏳ 上一次领取仍在执行，本轮跳过: %s\n", poolLabel(poolAddress))
+			inFlight++
+			noteClaimSkipped(poolAddress)
+			recordSkip(subsystemClaim, skipInProgress, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), "上一次领取仍在执行")
+			continue
+		}
+		// 手续费累积慢的池按 adaptive.idleIntervalMinutes 退避
+		if next, deferred := adaptiveClaimDeferred(poolAddress); deferred {
+			logOutput("💤 池不活跃，%s 前不再领取: %s\n", next, poolLabel(poolAddress))
+			noteClaimSkipped(poolAddress)
+			recordSkip(subsystemClaim, skipCooldown, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), "不活跃池的领取退避至 "+next)
+			continue
+		}
+		// 阶梯仓位组的其他档位不在批量读取范围内，照常执行
+		if p, ok := pendingClaims[positionAddress]; ok && p.empty() && openPositionGroup(poolAddress) == nil {
+			logOutput("⏭️ 仓位没有未领取的手续费与奖励，跳过领取: %s\n", poolLabel(poolAddress))
+			noteClaimEmpty(poolAddress, positionAddress)
+			noteClaimActivity(poolAddress)
+			noteClaimSkipped(poolAddress)
+			recordSkip(subsystemClaim, skipBelowThreshold, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), "没有未领取的手续费与奖励")
 			continue
 		}
 
 		poolCount++
-		logOutput("🔄 正在领取奖励: %s\n", poolAddress)
-		runClaimRewards(poolAddress)
+		logOutput("🔄 加入领取队列: %s\n", poolAddress)
+		pending = append(pending, enqueueClaim(poolAddress))
 	}
+	waitJobs(pending)
 
+	if subscribed > 0 {
+		logOutput("📡 %d 个池由账户订阅触发领取，本轮跳过\n", subscribed)
+	}
+	if inFlight > 0 {
+		logOutput("⏳ %d 个池的上一次领取仍在执行，本轮跳过\n", inFlight)
+	}
 	logOutput("✅ 本轮全局领取奖励完成，处理了 %d 个池 - %s\n", poolCount, time.Now().Format("15:04:05"))
 }
 
-// runClaimRewards 执行领取奖励脚本
-
 // 从 data/<pool>.json 读取 positionAddress（优先顶层，其次 data.positionAddress）
 func readPositionFromPoolJSON(poolAddress string) string {
-	dataPath := "/Users/yqw/meteora_dlmm/data/" + poolAddress + ".json"
+	dataPath := filepath.Join(poolDataDir(), poolAddress+".json")
 	bytes, err := os.ReadFile(dataPath)
 	if err != nil {
-		log.Printf("读取池JSON失败: %s, 错误: %v", dataPath, err)
+		logError("❌ 读取池JSON失败", "file", dataPath, "error", err)
 		return ""
 	}
 	var obj map[string]interface{}
 	if err := json.Unmarshal(bytes, &obj); err != nil {
-		log.Printf("解析池JSON失败: %s, 错误: %v", dataPath, err)
+		logError("❌ 解析池JSON失败", "file", dataPath, "error", err)
 		return ""
 	}
 	if v, ok := obj["positionAddress"].(string); ok && v != "" {
@@ -586,38 +1040,83 @@ func readPositionFromPoolJSON(poolAddress string) string {
 	return ""
 }
 
-func runClaimRewards(poolAddress string) bool {
+// runClaimRewards 执行领取奖励脚本，返回执行错误（没有仓位时为 nil）
+func runClaimRewards(poolAddress string) error {
+	// 同一池的领取串行执行（队列之外还有 API 与命令行入口）
+	lock := poolClaimLock(poolAddress)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if isPaperPool(poolAddress) {
+		if !paperHasOpenPosition(poolAddress) {
+			return nil
+		}
+		simulatePoolAction(poolAddress, "claim", poolDex(poolAddress).CommandLine(dexOpClaim, fmt.Sprintf("--pool=%s", poolAddress)))
+		return nil
+	}
+
 	// 仅从 JSON 读取 positionAddress
 	positionAddress := readPositionFromPoolJSON(poolAddress)
 	if positionAddress == "" {
-		// 返回 false 以通知上层停止定时任务
-		return false
+		return nil
 	}
-	cmd := exec.Command("npx", "ts-node", "claimAllRewards.ts",
-		fmt.Sprintf("--pool=%s", poolAddress),
-	)
-	cmd.Dir = "/Users/yqw/meteora_dlmm"
-	logOutput("▶️  执行领取奖励: %s (position 来自 JSON)\n", strings.Join(cmd.Args, " "))
-	// 执行命令（单次执行）
-	out, err := cmd.CombinedOutput()
-	logOutput("%s", string(out))
+	// 平仓或开仓进行中的池不领取
+	if err := transitionPool(poolAddress, poolClaiming, ""); err != nil {
+		recordSkip(subsystemClaim, skipInProgress, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), err.Error())
+		return nil
+	}
+	defer transitionPoolFrom(poolAddress, poolClaiming, poolActive, "")
+	// 阶梯仓位组由 main.go 按组级价值统一平仓，主仓位不再单独自动移除
+	grouped := openPositionGroup(poolAddress) != nil
+	claimArgs := []string{fmt.Sprintf("--pool=%s", poolAddress)}
+	if grouped {
+		claimArgs = append(claimArgs, "--no-auto-exit")
+	}
+	claimArgs = append(claimArgs, claimPolicyArgs(poolAddress, positionAddress)...)
+	claimArgs = append(claimArgs, priorityFeeArgs(feeOpClaim)...)
+	claimArgs = append(claimArgs, swapMaxFeeArgs()...)
+	claimArgs = append(claimArgs, claimCompoundArgs(poolAddress)...)
+	dex := poolDex(poolAddress)
+	logOutput("▶️  执行领取奖励: %s (position 来自 JSON)\n", strings.Join(dex.CommandLine(dexOpClaim, claimArgs...), " "))
+	// 执行命令（按 exec 策略重试）
+	out, err := dex.Claim(withPoolWallet(context.Background(), poolAddress), claimArgs...)
+	metricClaims.Inc(resultLabel(err))
+	noteClaimOutput(poolAddress, out, err)
+	noteClaimCheck(poolAddress, positionAddress, out, err)
+	recordClaimResult(poolAddress, positionAddress, out, err)
+	noteClaimActivity(poolAddress)
+	logCommandOutput(out)
 	if err != nil {
-		log.Printf("领取奖励执行失败: %v", err)
+		logError("❌ 领取奖励执行失败", "pool", poolAddress, "error", err)
+		notifyKeyed(eventClaimFailure, levelWarning, poolAddress, "领取奖励失败", err.Error(), map[string]string{"pool": poolAddress})
+	} else {
+		if !claimOutputClaimed(decodeScriptOutput(out)) {
+			recordSkip(subsystemClaim, skipBelowThreshold, poolAddress, readTokenContractAddressFromPoolJSON(poolAddress), "未达领取门槛")
+		}
+		updatePositionValue(poolAddress, string(out))
+		maybeCompound(poolAddress, positionAddress, out)
+		recordPnLClaim(poolAddress, positionAddress, string(out))
+		if grouped {
+			recordLegValue(poolAddress, positionAddress, string(out))
+		}
 	}
-	return true
+	if grouped {
+		claimLadderLegs(poolAddress)
+	}
+	return err
 }
 
 // 从 data/<pool>.json 读取 tokenContractAddress（ca字段）
 func readTokenContractAddressFromPoolJSON(poolAddress string) string {
-	dataPath := "/Users/yqw/meteora_dlmm/data/" + poolAddress + ".json"
+	dataPath := filepath.Join(poolDataDir(), poolAddress+".json")
 	bytes, err := os.ReadFile(dataPath)
 	if err != nil {
-		log.Printf("读取池JSON失败: %s, 错误: %v", dataPath, err)
+		logError("❌ 读取池JSON失败", "file", dataPath, "error", err)
 		return ""
 	}
 	var obj map[string]interface{}
 	if err := json.Unmarshal(bytes, &obj); err != nil {
-		log.Printf("解析池JSON失败: %s, 错误: %v", dataPath, err)
+		logError("❌ 解析池JSON失败", "file", dataPath, "error", err)
 		return ""
 	}
 
@@ -637,7 +1136,7 @@ func readTokenContractAddressFromPoolJSON(poolAddress string) string {
 
 // 从 data/<pool>.json 读取 poolName
 func readPoolNameFromPoolJSON(poolAddress string) string {
-	dataPath := "/Users/yqw/meteora_dlmm/data/" + poolAddress + ".json"
+	dataPath := filepath.Join(poolDataDir(), poolAddress+".json")
 	bytes, err := os.ReadFile(dataPath)
 	if err != nil {
 		return ""
@@ -658,17 +1157,22 @@ func readPoolNameFromPoolJSON(poolAddress string) string {
 			return v
 		}
 	}
+
+	// 最后使用链上信息的交易对名称
+	if meta := poolMetadata(poolAddress); meta != nil {
+		return meta.pairName()
+	}
 	return ""
 }
 
 // 获取所有池的tokenContractAddress
 func getAllTokenContractAddresses() map[string]string {
 	tokenAddresses := make(map[string]string)
-	dataDir := "/Users/yqw/meteora_dlmm/data"
+	dataDir := poolDataDir()
 
 	files, err := os.ReadDir(dataDir)
 	if err != nil {
-		log.Printf("读取data目录失败: %v", err)
+		logError("❌ 读取data目录失败", "error", err)
 		return tokenAddresses
 	}
 
@@ -690,10 +1194,35 @@ func getAllTokenContractAddresses() map[string]string {
 }
 
 // 通过 ca 反查 poolAddress（遍历 data 目录中每个池的 JSON，匹配顶层 ca 或 data.ca）
+func findPoolsByToken(tokenAddress string) []string {
+	var pools []string
+	for pool, ca := range getAllTokenContractAddresses() {
+		if ca == tokenAddress {
+			pools = append(pools, pool)
+		}
+	}
+	sort.Strings(pools)
+	return pools
+}
+
+// 从 data/<pool>.json 读取信号来源（CSV 源名称，旧记录为空）
+func readSourceFromPoolJSON(poolAddress string) string {
+	bytes, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json"))
+	if err != nil {
+		return ""
+	}
+	var obj struct {
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(bytes, &obj); err != nil {
+		return ""
+	}
+	return obj.Source
+}
 
 // 从 data/<pool>.json 读取 last_updated_first（优先顶层，其次 data.last_updated_first）
 func readLastUpdatedFirstFromPoolJSON(poolAddress string) string {
-	dataPath := "/Users/yqw/meteora_dlmm/data/" + poolAddress + ".json"
+	dataPath := filepath.Join(poolDataDir(), poolAddress+".json")
 	bytes, err := os.ReadFile(dataPath)
 	if err != nil {
 		return ""
@@ -713,23 +1242,55 @@ func readLastUpdatedFirstFromPoolJSON(poolAddress string) string {
 	return ""
 }
 
-// 解析 last_updated_first（格式: 2006-01-02 15:04:05，按东八区解析）
+// 从 data/<pool>.json 读取入场参考价 c（addLiquidity.ts 写入的K线收盘价，优先顶层，其次 data.c）
+func readEntryPriceFromPoolJSON(poolAddress string) float64 {
+	dataPath := filepath.Join(poolDataDir(), poolAddress+".json")
+	bytes, err := os.ReadFile(dataPath)
+	if err != nil {
+		return 0
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(bytes, &obj); err != nil {
+		return 0
+	}
+	parse := func(v interface{}) float64 {
+		switch x := v.(type) {
+		case float64:
+			return x
+		case string:
+			f, _ := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			return f
+		}
+		return 0
+	}
+	if v := parse(obj["c"]); v > 0 {
+		return v
+	}
+	if m, ok := obj["data"].(map[string]interface{}); ok {
+		return parse(m["c"])
+	}
+	return 0
+}
+
+// 解析 last_updated_first（格式: 2006-01-02 15:04:05，按 signalFreshness.timezone 解析，默认东八区；也支持 RFC3339 与 Unix 时间戳）
 func parseLastUpdatedFirstToTime(lastUpdatedFirst string) (time.Time, error) {
 	lastUpdatedFirst = strings.TrimSpace(lastUpdatedFirst)
 	if lastUpdatedFirst == "" {
 		return time.Time{}, fmt.Errorf("empty last_updated_first")
 	}
+	if t, err := time.Parse(time.RFC3339, lastUpdatedFirst); err == nil {
+		return t, nil
+	}
+	if t, ok := parseEpochString(lastUpdatedFirst); ok {
+		return t, nil
+	}
 	// 支持包含 T 或 %20 的情况，替换为空格
 	lastUpdatedFirst = strings.ReplaceAll(lastUpdatedFirst, "T", " ")
 	lastUpdatedFirst = strings.ReplaceAll(lastUpdatedFirst, "%20", " ")
 	// 去除可能包裹的引号
 	lastUpdatedFirst = strings.Trim(lastUpdatedFirst, "\"'")
 
-	loc, _ := time.LoadLocation("Asia/Shanghai")
-	if loc == nil {
-		loc = time.FixedZone("CST8", 8*60*60)
-	}
-	t, err := time.ParseInLocation("2006-01-02 15:04:05", lastUpdatedFirst, loc)
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", lastUpdatedFirst, signalLocation())
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -739,30 +1300,22 @@ func parseLastUpdatedFirstToTime(lastUpdatedFirst string) (time.Time, error) {
 // 执行价格获取命令（仅获取价格，不执行交易）
 func fetchPriceForToken(poolAddress, tokenContractAddress string) {
 	// 使用专门的价格获取脚本
-	cmd := exec.Command("npx", "ts-node", "fetchPrice.ts",
+	args := []string{
 		fmt.Sprintf("--pool=%s", poolAddress),
-		fmt.Sprintf("--token=%s", tokenContractAddress))
-	cmd.Dir = "/Users/yqw/meteora_dlmm"
-
+		fmt.Sprintf("--token=%s", tokenContractAddress)}
+	// 研究模式与模拟池只取价格，避免 fetchPrice.ts 触发真实移除
+	if isPriceOnly() || isPaperPool(poolAddress) || isDryRun() {
+		args = append(args, "--price-only")
+	}
 	// 执行命令并捕获输出
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	start := time.Now()
+	output, err := poolDex(poolAddress).Quote(context.Background(), args...)
 
-	// 实时显示所有输出到终端和日志文件
-	logOutput("%s", outputStr)
-
-	// 解析输出，提取价格信息
-	var finalPrice string
-	lines := strings.Split(outputStr, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "price:") {
-			// 提取价格值
-			parts := strings.Split(line, "price:")
-			if len(parts) > 1 {
-				finalPrice = strings.TrimSpace(parts[1])
-			}
-		}
-	}
+	// 输出到终端和日志文件（逐行输出时已在执行中写入）
+	logCommandOutput(output)
+
+	// 解析输出，提取价格信息（结构化事件优先，旧脚本回退为 "price:" 行）
+	finalPrice := decodeScriptOutput(output).Price()
 
 	// 获取poolName
 	poolName := readPoolNameFromPoolJSON(poolAddress)
@@ -770,59 +1323,35 @@ func fetchPriceForToken(poolAddress, tokenContractAddress string) {
 		poolName = "未知池"
 	}
 
+	// 多源价格：脚本输出的 OKX 价格与其他价格源按策略回退或聚合
+	finalPrice, source, quotes := resolvePrice(poolAddress, tokenContractAddress, finalPrice)
+
 	// 输出价格信息
+	metricPriceFetchLatency.Observe(time.Since(start).Seconds())
 	if finalPrice != "" {
-		logOutput("💰 最终价格: %s\n", finalPrice)
-		logOutput("✅ 价格获取成功 [ca: %s, poolName: %s]\n", tokenContractAddress, poolName)
+		metricPriceFetches.Inc("success")
+		logOutput("💰 最终价格: %s（来源: %s）\n", finalPrice, source)
+		recordPriceSample(poolAddress, tokenContractAddress, finalPrice, source, quotes)
+		checkPriceThresholds(poolAddress, tokenContractAddress, finalPrice)
+		updatePositionPrice(poolAddress, finalPrice)
+		evaluateEntryTrigger(poolAddress, finalPrice)
+		if !isPriceOnly() {
+			evaluateRisk(poolAddress, tokenContractAddress, finalPrice)
+			evaluatePartialWithdrawRules(poolAddress, finalPrice)
+		}
+		logInfo("✅ 价格获取成功", "pool", poolAddress, "token", tokenContractAddress, "poolName", poolName, "price", finalPrice, "source", source)
+		publishEvent(BusEvent{Type: busPriceFetched, Stage: subsystemPrice, Pool: poolAddress, Token: tokenContractAddress,
+			Fields: map[string]string{"price": finalPrice, "source": source}})
 	} else {
-		logOutput("❌ 价格获取失败 [ca: %s, poolName: %s]\n", tokenContractAddress, poolName)
+		metricPriceFetches.Inc("failure")
+		logError("❌ 价格获取失败", "pool", poolAddress, "token", tokenContractAddress, "poolName", poolName)
 		if err != nil {
-			log.Printf("错误详情: %v", err)
+			logError("❌ 价格获取错误详情", "pool", poolAddress, "token", tokenContractAddress, "error", err)
 		}
-	}
-}
-
-// 启动价格获取定时任务
-func startPriceFetcherTicker() {
-	logOutput("🕐 启动价格获取定时任务（每分钟01秒）\n")
-
-	// 计算到下一个01秒的时间
-	now := time.Now()
-	nextMinute := now.Truncate(time.Minute).Add(time.Minute)
-	nextTarget := nextMinute.Add(time.Second) // 01秒
-
-	// 如果当前时间已经过了这分钟的01秒，则等到下一分钟的01秒
-	if now.After(nextTarget) {
-		nextTarget = nextTarget.Add(time.Minute)
-	}
-
-	initialDelay := nextTarget.Sub(now)
-	logOutput("⏰ 距离下次价格获取还有: %v\n", initialDelay.Round(time.Second))
-
-	// 等待到下一个01秒，但可以被取消
-	select {
-	case <-globalCtx.Done():
-		logOutput("🛑 收到关闭信号，停止价格获取定时任务\n")
-		return
-	case <-time.After(initialDelay):
-		// 继续执行
-	}
-
-	// 立即执行一次
-	executePriceFetch()
-
-	// 然后每分钟的01秒执行
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-globalCtx.Done():
-			logOutput("🛑 收到关闭信号，停止价格获取定时任务\n")
-			return
-		case <-ticker.C:
-			executePriceFetch()
+		if err == nil {
+			err = fmt.Errorf("未取得价格")
 		}
+		publishError(subsystemPrice, poolAddress, tokenContractAddress, err, map[string]string{"target": "fetchPrice"})
 	}
 }
 
@@ -854,12 +1383,12 @@ func displayPositionExistenceTime(poolAddress string) {
 		timeStr = fmt.Sprintf("%.0f分钟", existenceMinutes)
 	}
 
-	// 检查是否超过5小时
-	var status string
-	if existenceDuration >= 5*time.Hour {
+	// 检查是否超过信号起强制平仓时长（lifecycle.signalTimeoutMinutes，关闭时不显示剩余时间）
+	status := "未设置强制平仓"
+	if timeout := signalTimeout(); timeout > 0 && existenceDuration >= timeout {
 		status = "🚨 已超时！需要立即移除"
-	} else {
-		remaining := 5*time.Hour - existenceDuration
+	} else if timeout > 0 {
+		remaining := timeout - existenceDuration
 		remainingMinutes := remaining.Minutes()
 		status = fmt.Sprintf("⏳ 剩余%.0f分钟", remainingMinutes)
 	}
@@ -867,8 +1396,12 @@ func displayPositionExistenceTime(poolAddress string) {
 	logOutput("📅 Position存在时间: %s (%s) - %s\n", timeStr, status, poolAddress)
 }
 
-// 检查并执行5小时超时移除流动性
-func checkAndExecute5HourTimeout(poolAddress string) {
+// 检查并执行信号起超时移除流动性（lifecycle.signalTimeoutMinutes，默认 5 小时，0 表示关闭）
+func checkAndExecuteSignalTimeout(poolAddress string) {
+	timeout := signalTimeout()
+	if timeout <= 0 {
+		return
+	}
 	// 读取 last_updated_first
 	lastStr := readLastUpdatedFirstFromPoolJSON(poolAddress)
 	if lastStr == "" {
@@ -882,125 +1415,93 @@ func checkAndExecute5HourTimeout(poolAddress string) {
 		return
 	}
 
-	// 检查是否超过5小时
-	if time.Since(lastTime) >= 5*time.Hour {
-		// 读取 positionAddress 并立即执行移除流动性
-		positionAddress := readPositionFromPoolJSON(poolAddress)
-		if positionAddress != "" {
-			existenceDuration := time.Since(lastTime)
-			existenceHours := existenceDuration.Hours()
-			logOutput("🚨 检测到超时！Position已存在%.1f小时，立即执行移除流动性: pool=%s position=%s\n",
-				existenceHours, poolAddress, positionAddress)
-
-			// 立即执行移除流动性（同步执行，确保立即处理）
-			rmCtx, rmCancel := context.WithTimeout(globalCtx, 2*time.Minute)
-			defer rmCancel()
-
-			rmCmd := exec.CommandContext(rmCtx, "npx", "ts-node", "removeLiquidity.ts",
-				fmt.Sprintf("--pool=%s", poolAddress),
-				fmt.Sprintf("--position=%s", positionAddress),
-			)
-			rmCmd.Dir = "/Users/yqw/meteora_dlmm"
-
-			logOutput("🔄 正在执行移除流动性命令...\n")
-			out, err := rmCmd.CombinedOutput()
-			logOutput("%s", string(out))
-
-			if err != nil {
-				if rmCtx.Err() == context.DeadlineExceeded {
-					logOutput("❌ 移除流动性超时（2分钟）[pool: %s]\n", poolAddress)
-				} else if rmCtx.Err() == context.Canceled {
-					logOutput("❌ 移除流动性被取消 [pool: %s]\n", poolAddress)
-				} else {
-					logOutput("❌ 移除流动性失败 [pool: %s]: %v\n", poolAddress, err)
-				}
-			} else {
-				logOutput("✅ 移除流动性执行完成 [pool: %s]\n", poolAddress)
-			}
+	// 检查是否超时
+	if time.Since(lastTime) >= timeout {
+		logOutput("🚨 检测到超时！Position已存在%.1f小时，立即领取并平仓: pool=%s\n",
+			time.Since(lastTime).Hours(), poolAddress)
+
+		// 立即执行（同步执行，确保立即处理）
+		claimAndClosePosition(poolAddress, exitReasonMaxAge)
+	}
+}
+
+// runRemoveLiquidity 执行移除流动性脚本（2分钟超时），成功返回 true；extraArgs 追加到脚本参数
+func runRemoveLiquidity(poolAddress, positionAddress string, extraArgs ...string) bool {
+	rmCtx, rmCancel := context.WithTimeout(globalCtx, 2*time.Minute)
+	defer rmCancel()
+
+	args := append([]string{
+		fmt.Sprintf("--pool=%s", poolAddress),
+		fmt.Sprintf("--position=%s", positionAddress),
+	}, extraArgs...)
+	args = append(args, swapMaxFeeArgs()...)
+
+	logOutput("🔄 正在执行移除流动性命令...\n")
+	out, err := poolDex(poolAddress).RemoveLiquidity(withPoolWallet(rmCtx, poolAddress), false, args...)
+	metricRemoveLiquidity.Inc(resultLabel(err))
+	logCommandOutput(out)
+
+	if err != nil {
+		if rmCtx.Err() == context.DeadlineExceeded {
+			logOutput("❌ 移除流动性超时（2分钟）[pool: %s]\n", poolAddress)
+		} else if rmCtx.Err() == context.Canceled {
+			logOutput("❌ 移除流动性被取消 [pool: %s]\n", poolAddress)
 		} else {
-			logOutput("⚠️ 找不到 positionAddress，无法移除流动性: pool=%s\n", poolAddress)
+			logError("❌ 移除流动性失败", "pool", poolAddress, "position", positionAddress, "error", err)
 		}
+		return false
 	}
+	logInfo("✅ 移除流动性执行完成", "pool", poolAddress, "position", positionAddress)
+	return true
 }
 
 // 执行价格获取
 func executePriceFetch() {
-	logOutput("🔄 开始价格获取 - %s\n", time.Now().Format("15:04:05"))
-
-	tokenAddresses := getAllTokenContractAddresses()
-	if len(tokenAddresses) == 0 {
-		logOutput("⚠️ 未找到任何tokenContractAddress，跳过价格获取\n")
+	if isPaused() {
+		logOutput("⏸️ 已暂停，跳过本轮价格获取\n")
+		recordSkip(subsystemPrice, skipPaused, "", "", "本轮价格获取")
 		return
 	}
+	logOutput("🔄 开始价格获取 - %s\n", time.Now().Format("15:04:05"))
+	metricTickerRuns.Inc("price")
 
-	logOutput("📊 找到 %d 个token需要获取价格\n", len(tokenAddresses))
-
-	// 顺序获取所有token的价格（避免OKX API限制）
+	tokenAddresses := getAllTokenContractAddresses()
+	// 名单策略：价格获取
 	for poolAddress, tokenAddress := range tokenAddresses {
-		logOutput("🔄 正在获取价格: %s -> %s\n", poolAddress, tokenAddress)
-
-		// 显示position存在时间
-		displayPositionExistenceTime(poolAddress)
-
-		// 检查5小时限制（在价格获取前检查）
-		checkAndExecute5HourTimeout(poolAddress)
-
-		fetchPriceForToken(poolAddress, tokenAddress)
-
-		// 添加延迟避免API限制
-		time.Sleep(1100 * time.Millisecond)
+		if !enforceListPolicy(subsystemPrice, poolAddress, tokenAddress) {
+			delete(tokenAddresses, poolAddress)
+		}
 	}
-
-	logOutput("✅ 本轮价格获取完成 - %s\n", time.Now().Format("15:04:05"))
-}
-
-// 启动jupSwap定时任务
-func startJupSwapTicker() {
-	logOutput("🕐 启动jupSwap定时任务（每分钟06秒）\n")
-
-	// 计算到下一个06秒的时间
-	now := time.Now()
-	nextMinute := now.Truncate(time.Minute).Add(time.Minute)
-	nextTarget := nextMinute.Add(6 * time.Second) // 06秒
-
-	// 如果当前时间已经过了这分钟的06秒，则等到下一分钟的06秒
-	if now.After(nextTarget) {
-		nextTarget = nextTarget.Add(time.Minute)
+	// 启用归档时，已平仓、等待归档的池不再获取价格
+	if currentConfig().Archive.Enabled {
+		for poolAddress := range tokenAddresses {
+			if state, ok := poolStateOf(poolAddress); ok && state == poolClosed {
+				delete(tokenAddresses, poolAddress)
+			}
+		}
 	}
-
-	initialDelay := nextTarget.Sub(now)
-	logOutput("⏰ 距离下次jupSwap还有: %v\n", initialDelay.Round(time.Second))
-
-	// 等待到下一个06秒，但可以被取消
-	select {
-	case <-globalCtx.Done():
-		logOutput("🛑 收到关闭信号，停止jupSwap定时任务\n")
+	// 账户订阅正常的池按 active bin 变化获取价格，定时任务每 pollEvery 轮兜底一次
+	round, subscribed := accountSubPollRound("price"), 0
+	for poolAddress := range tokenAddresses {
+		if accountSubSkipPoll(round, poolAddress) {
+			delete(tokenAddresses, poolAddress)
+			subscribed++
+		}
+	}
+	if subscribed > 0 {
+		logOutput("📡 %d 个池由账户订阅触发价格获取，本轮跳过\n", subscribed)
+	}
+	if len(tokenAddresses) == 0 {
+		logOutput("⚠️ 未找到任何tokenContractAddress，跳过价格获取\n")
 		return
-	case <-time.After(initialDelay):
-		// 继续执行
 	}
 
-	// 立即执行一次
-	executeJupSwap()
+	logOutput("📊 找到 %d 个token需要获取价格（并发 %d）\n", len(tokenAddresses), currentConfig().PriceFetch.Workers)
 
-	// 然后每分钟的06秒执行
-	ticker := time.NewTicker(1 * time.Second) // 每秒检查一次
-	defer ticker.Stop()
+	// 并发获取所有token的价格（按上游令牌桶限速，避免OKX API限制）
+	fetchPricesConcurrently(tokenAddresses)
 
-	for {
-		select {
-		case <-globalCtx.Done():
-			logOutput("🛑 收到关闭信号，停止jupSwap定时任务\n")
-			return
-		case <-ticker.C:
-			now := time.Now()
-			second := now.Second()
-			// 在06秒时执行
-			if second == 6 {
-				executeJupSwap()
-			}
-		}
-	}
+	logOutput("✅ 本轮价格获取完成 - %s\n", time.Now().Format("15:04:05"))
 }
 
 // 执行jupSwap
@@ -1013,59 +1514,82 @@ func executeJupSwap() {
 	default:
 	}
 
-	logOutput("🔄 开始jupSwap - %s\n", time.Now().Format("15:04:05"))
-
-	// 先获取持仓信息，解析出所有代币地址
-	tokenAddresses := getTokenBalancesFromJupSwap()
-	if len(tokenAddresses) == 0 {
-		logOutput("⚠️ 未找到任何代币持仓，跳过jupSwap\n")
+	if isPaused() {
+		logOutput("⏸️ 已暂停，跳过本轮jupSwap\n")
+		recordSkip(subsystemSweep, skipPaused, "", "", "本轮jupSwap")
 		return
 	}
 
-	logOutput("📊 找到 %d 个代币需要执行swap\n", len(tokenAddresses))
+	logOutput("🔄 开始jupSwap - %s\n", time.Now().Format("15:04:05"))
+	if s := consolidationSummary(); s != "" {
+		logOutput("🎯 归集策略: %s\n", s)
+	}
+	metricTickerRuns.Inc("swap")
 
-	// 顺序执行所有代币的jupSwap（避免并发冲突）
-	for i, tokenAddress := range tokenAddresses {
-		// 检查全局上下文是否已取消
-		select {
-		case <-globalCtx.Done():
+	beginSwapRound()
+	defer finishSwapRound()
+
+	// 多钱包时逐个钱包兑换
+	for _, wallet := range sweepWallets() {
+		if globalCtx.Err() != nil {
 			logOutput("⏹️ 程序已取消，停止执行jupSwap\n")
 			return
-		default:
 		}
-
-		logOutput("🔄 正在执行jupSwap (%d/%d): %s\n", i+1, len(tokenAddresses), tokenAddress)
-		executeJupSwapForToken(tokenAddress)
-
-		// 添加延迟避免系统负载过高，但检查取消状态
-		select {
-		case <-globalCtx.Done():
-			logOutput("⏹️ 程序已取消，停止执行jupSwap\n")
+		if wallet != "" {
+			logOutput("👛 钱包 %s\n", wallet)
+		}
+		if swapBalancesUnchanged(wallet) {
+			logOutput("⏭️ 钱包代币余额自上次兑换后没有变化，跳过jupSwap\n")
+			recordSkip(subsystemSweep, skipUnchanged, "", "", "钱包 "+wallet+" 的代币余额没有变化")
+			continue
+		}
+		if !sweepWallet(wallet) {
 			return
-		case <-time.After(2 * time.Second):
-			// 继续下一个代币
 		}
+		noteSwapBalances(wallet)
 	}
 
 	logOutput("✅ 本轮jupSwap完成 - %s\n", time.Now().Format("15:04:05"))
 }
 
-// 从jupSwap获取代币持仓信息
-func getTokenBalancesFromJupSwap() []string {
+// 兑换单个钱包中的代币，程序取消时返回 false
+func sweepWallet(wallet string) bool {
+	// 先获取持仓信息，解析出所有代币地址
+	tokenAddresses := getTokenBalancesFromJupSwap(wallet)
+	if len(tokenAddresses) == 0 {
+		logOutput("⚠️ 未找到任何代币持仓，跳过jupSwap\n")
+		return true
+	}
+
+	logOutput("📊 找到 %d 个代币需要执行swap\n", len(tokenAddresses))
+
+	// 加入任务队列（兑换默认顺序执行，避免并发冲突）并等待本钱包的兑换完成；输出资产由归集策略决定
+	outputMint := consolidationOutputMint()
+	var pending []<-chan struct{}
+	for i, tokenAddress := range tokenAddresses {
+		logOutput("🔄 加入jupSwap队列 (%d/%d): %s\n", i+1, len(tokenAddresses), tokenAddress)
+		pending = append(pending, enqueueSwap(wallet, tokenAddress, outputMint))
+	}
+	waitJobs(pending)
+	if globalCtx.Err() != nil {
+		logOutput("⏹️ 程序已取消，停止执行jupSwap\n")
+		return false
+	}
+	return true
+}
+
+// 从jupSwap获取代币持仓信息（wallet 为空时使用默认钱包）
+func getTokenBalancesFromJupSwap(wallet string) []string {
 	// 创建带超时的上下文
 	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
 	defer cancel()
 
 	// 执行jupSwap命令获取持仓信息（不指定input参数）
-	cmd := exec.CommandContext(ctx, "./jupSwap")
-	cmd.Dir = "/Users/yqw/meteora_dlmm"
-
-	// 执行命令并捕获输出
-	output, err := cmd.CombinedOutput()
+	output, err := runExternal(withWallet(ctx, wallet), scriptJupSwapBalances)
 	outputStr := string(output)
 
-	// 实时显示所有输出到终端和日志文件
-	logOutput("%s", outputStr)
+	// 输出到终端和日志文件（逐行输出时已在执行中写入）
+	logCommandOutput(output)
 
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
@@ -1078,116 +1602,139 @@ func getTokenBalancesFromJupSwap() []string {
 		return []string{}
 	}
 
-	// 读取黑名单（每次执行时重新读取，支持动态更新）
-	banList := readBanList()
-
-	// 解析输出，提取代币地址
-	tokenAddresses := parseTokenAddressesFromOutput(outputStr, banList)
-	logOutput("📊 从持仓信息中解析出 %d 个代币地址（已过滤黑名单）\n", len(tokenAddresses))
-
-	return tokenAddresses
-}
-
-// 读取黑名单ca地址
-func readBanList() map[string]bool {
-	banList := make(map[string]bool)
-	banFilePath := "/Users/yqw/meteora_dlmm/data/ban/ban.csv"
-
-	// 检查文件是否存在
-	if _, err := os.Stat(banFilePath); os.IsNotExist(err) {
-		logOutput("⚠️ 黑名单文件不存在: %s\n", banFilePath)
-		return banList
-	}
-
-	// 读取文件内容
-	content, err := os.ReadFile(banFilePath)
-	if err != nil {
-		logOutput("❌ 读取黑名单文件失败: %v\n", err)
-		return banList
-	}
-
-	// 解析逗号分隔的ca地址（支持英文逗号和中文逗号）
-	line := strings.TrimSpace(string(content))
-	if line == "" {
-		logOutput("📝 黑名单文件为空\n")
-		return banList
-	}
-
-	// 先替换中文逗号为英文逗号，然后分割
-	line = strings.ReplaceAll(line, "，", ",")
-	addresses := strings.Split(line, ",")
-	for _, addr := range addresses {
-		addr = strings.TrimSpace(addr)
-		if addr != "" {
-			banList[addr] = true
-			logOutput("🚫 黑名单ca: %s\n", addr)
+	// 解析输出，提取代币地址（按名单策略、持仓保护与归集策略过滤，名单缓存在文件变化时重新加载）
+	guard := swapGuardFilter(wallet)
+	consolidate := consolidationFilter(wallet, decodeScriptOutput(output).TokenBalances())
+	tokenAddresses := parseTokenAddressesFromOutput(outputStr, func(tokenAddress string) string {
+		// 风控平仓兑换为 USDC 时不再把 USDC 换回 SOL
+		if riskKeepsUSDC() && tokenAddress == usdcMint {
+			recordSkip(subsystemSweep, skipFiltered, "", tokenAddress, "风控平仓保留 USDC")
+			return swapSkipKeepUSDC
 		}
-	}
+		if reason := enforceListPolicyReason(subsystemSweep, "", tokenAddress); reason != "" {
+			return reason
+		}
+		if reason := guard(tokenAddress); reason != "" {
+			return reason
+		}
+		if reason := poolOverrideSwapFilter(tokenAddress); reason != "" {
+			return reason
+		}
+		if reason := compoundSwapFilter(wallet, tokenAddress); reason != "" {
+			return reason
+		}
+		return consolidate(tokenAddress)
+	}, wallet)
+	logOutput("📊 从持仓信息中解析出 %d 个代币地址（已按名单策略、持仓保护与归集策略过滤）\n", len(tokenAddresses))
 
-	logOutput("📊 加载了 %d 个黑名单ca\n", len(banList))
-	return banList
+	return tokenAddresses
 }
 
-// 从jupSwap输出中解析代币地址
-func parseTokenAddressesFromOutput(output string, banList map[string]bool) []string {
+// 从jupSwap输出中解析代币地址；skip 返回非空原因时跳过（记入本轮兑换汇总）
+func parseTokenAddressesFromOutput(output string, skip func(tokenAddress string) string, wallet string) []string {
 	var tokenAddresses []string
-	lines := strings.Split(output, "\n")
-
-	for _, line := range lines {
-		// 查找包含"代币:"的行
-		if strings.Contains(line, "代币:") {
-			// 解析格式: "代币: AJ5WbjdWivswCGvyfMgbTjfSegCLHXJtXBTgjRhtsE1k, 余额: 183149994540 (183149.994540)"
-			parts := strings.Split(line, "代币:")
-			if len(parts) >= 2 {
-				// 提取代币地址（去掉逗号前的部分）
-				tokenPart := strings.TrimSpace(parts[1])
-				commaIndex := strings.Index(tokenPart, ",")
-				if commaIndex > 0 {
-					tokenAddress := strings.TrimSpace(tokenPart[:commaIndex])
-					// 验证地址格式（Solana地址通常是44个字符）
-					if len(tokenAddress) >= 32 && len(tokenAddress) <= 44 {
-						// 检查是否在黑名单中
-						if banList[tokenAddress] {
-							logOutput("🚫 跳过黑名单代币: %s\n", tokenAddress)
-						} else {
-							tokenAddresses = append(tokenAddresses, tokenAddress)
-							logOutput("🔍 发现代币: %s\n", tokenAddress)
-						}
-					}
-				}
-			}
+	// token 事件（jupSwap 的文本输出已由 output.patterns 转换为事件）
+	for _, tokenAddress := range decodeScriptOutput([]byte(output)).Tokens() {
+		// 验证地址格式（Solana地址通常是44个字符）
+		if len(tokenAddress) < 32 || len(tokenAddress) > 44 {
+			continue
+		}
+		if reason := skip(tokenAddress); reason != "" {
+			logOutput("🚫 跳过代币 (%s): %s\n", reason, tokenAddress)
+			noteSwapSkip(SwapSkip{Token: tokenAddress, Wallet: wallet, Reason: reason})
+		} else {
+			tokenAddresses = append(tokenAddresses, tokenAddress)
+			logOutput("🔍 发现代币: %s (%s)\n", tokenAddress, tokenLabel(tokenAddress))
 		}
 	}
-
 	return tokenAddresses
 }
 
-// 执行单个token的jupSwap
-func executeJupSwapForToken(ca string) {
+// 执行单个token的jupSwap（兑换为SOL）
+func executeJupSwapForToken(wallet, ca string) error {
+	return executeJupSwapToMint(wallet, ca, "")
+}
+
+// 执行单个token的jupSwap，outputMint 为空时使用 jupSwap 默认输出（SOL）；返回执行错误（跳过时为 nil）
+func executeJupSwapToMint(wallet, ca, outputMint string) error {
 	// 检查全局上下文是否已取消
 	select {
 	case <-globalCtx.Done():
 		logOutput("⏹️ 程序已取消，跳过代币: %s\n", ca)
-		return
+		return nil
 	default:
 	}
 
-	// 注意：5小时超时检查已移至价格获取定时任务中，避免重复检查
+	// 注意：信号起强制平仓检查已移至价格获取定时任务中，避免重复检查
+
+	// 与同一代币的复投互斥执行；排队期间加入复投的代币不再兑换
+	lock := tokenOpLock(wallet, ca)
+	lock.Lock()
+	defer lock.Unlock()
+	if reason := compoundSwapFilter(wallet, ca); reason != "" {
+		logOutput("🔁 代币等待复投，跳过jupSwap: %s\n", ca)
+		noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: reason})
+		return nil
+	}
+
+	// 停止开关：经任务队列的兑换已在调度时跳过，这里覆盖风控、阶梯清理与命令行的直接兑换
+	if killSwitchActive() {
+		logOutput("🛑 停止开关已开启，跳过jupSwap: %s\n", ca)
+		noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: skipKillSwitch})
+		recordSkip(subsystemSweep, skipKillSwitch, "", ca, "停止开关已开启")
+		return nil
+	}
+
+	if !rateGuardAllow(rateSwap) {
+		logOutput("🛑 超出速率上限，跳过jupSwap: %s\n", ca)
+		noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: swapSkipRateLimited})
+		recordSkip(subsystemSweep, skipQuota, "", ca, "速率保护")
+		return nil
+	}
+
+	// 报价检查：价格影响过大或输出明显低于已存价格时不卖出
+	reason, detail, quote := checkSwapQuote(wallet, ca, outputMint)
+	if reason != "" {
+		logWarn("🛑 兑换报价不可接受，跳过jupSwap", "token", ca, "wallet", wallet, "reason", reason, "detail", detail)
+		metricSwapQuoteRejects.Inc(reason)
+		noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: reason, Detail: detail})
+		recordSkip(subsystemSweep, skipSlippage, "", ca, detail)
+		return nil
+	}
+
+	// jupSwap 未输出成交数量时按 SOL 余额变化估算收入
+	before := swapBalanceBefore(wallet, outputMint)
+	// 兑换后按余额变化核对实际成交
+	verify := beginSwapVerify(wallet, ca, outputMint, quote)
 
 	// 创建带超时的上下文（每个代币最多30秒）
 	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
 	defer cancel()
 
 	// 执行jupSwap命令
-	cmd := exec.CommandContext(ctx, "./jupSwap", "-input", ca, "-maxfee", "500000")
-	cmd.Dir = "/Users/yqw/meteora_dlmm"
-
-	// 执行命令并捕获输出
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	// 执行命令并捕获输出（按 exec 策略重试）
+	swapArgs := []string{"-input", ca, "-maxfee", swapMaxFee()}
+	if outputMint != "" {
+		swapArgs = append(swapArgs, "-output", outputMint)
+	}
+	output, err := runExternal(withWallet(ctx, wallet), scriptJupSwap, swapArgs...)
+	metricSwaps.Inc(resultLabel(err))
+	var proceeds, feeSOL float64
+	var proceedsSource string
+	var verification *SwapVerification
+	if err == nil {
+		proceeds, feeSOL, proceedsSource = swapProceeds(wallet, output, before)
+		// 兑换为其他代币且 jupSwap 未输出成交数量时，以核对得到的到账数量为收入
+		if verification = verify.finish(feeSOL); verification != nil && proceedsSource == "" && verification.Received > 0 {
+			proceeds, proceedsSource = verification.Received, "balance"
+		}
+	}
+	pools := recordPnLSwap(ca, outputMint, proceeds, err)
+	noteSwapResult(ca, err)
+	recordRateEvent(rateSwap, 0)
 
-	// 实时显示所有输出到终端和日志文件
-	logOutput("%s", outputStr)
+	// 输出到终端和日志文件（逐行输出时已在执行中写入）
+	logCommandOutput(output)
 
 	// 检查执行结果
 	if err != nil {
@@ -1196,11 +1743,30 @@ func executeJupSwapForToken(ca string) {
 		} else if ctx.Err() == context.Canceled {
 			logOutput("❌ jupSwap执行被取消 [ca: %s]\n", ca)
 		} else {
-			logOutput("❌ jupSwap执行失败 [ca: %s]: %v\n", ca, err)
+			logError("❌ jupSwap执行失败", "token", ca, "error", err)
 		}
+		notifyKeyed(eventSwapFailure, levelWarning, ca, "jupSwap执行失败", err.Error(), map[string]string{"ca": ca})
+		noteSwapSkip(SwapSkip{Token: ca, Wallet: wallet, Reason: swapSkipFailed, Detail: err.Error()})
+		tallySwap(nil)
+		publishError(subsystemSweep, "", ca, err, map[string]string{"target": "jupSwap", "wallet": wallet})
 	} else {
-		logOutput("✅ jupSwap执行成功 [ca: %s]\n", ca)
+		logInfo("✅ jupSwap执行成功", "token", ca, "proceeds", proceeds, "pools", len(pools))
+		if outputMint == "" {
+			outputMint = swapToSOL
+		}
+		sale := SwapSale{Token: ca, Symbol: resolveTokenSymbol(ca), Wallet: wallet, OutputMint: outputMint, Proceeds: proceeds, FeeSOL: feeSOL, Source: proceedsSource, Pools: pools,
+			ValueUSD: usdValue(outputMint, proceeds), Verify: verification}
+		noteSwapSale(sale)
+		recordSwapHistory(sale)
+		tallySwap(&sale)
+		fields := map[string]string{"outputMint": outputMint, "proceeds": formatFloat(proceeds), "valueUSD": formatFloat(sale.ValueUSD), "pools": strings.Join(pools, ",")}
+		if verification != nil {
+			fields["verify"] = verification.Result
+			fields["execPrice"] = formatFloat(verification.ExecPrice)
+		}
+		publishEvent(BusEvent{Type: busSwapExecuted, Stage: subsystemSweep, Token: ca, Wallet: wallet, Detail: proceedsSource, Fields: fields})
 	}
+	return err
 }
 
-// 删除重试逻辑：不再保留 readFileWithRetry 和 runCmdWithRetry
+// 外部命令的重试与熔断统一由 executor.go 的 runExternal 处理
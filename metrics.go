@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 轻量 Prometheus 指标实现（文本暴露格式），避免引入额外依赖
+
+type metricCollector interface {
+	write(sb *strings.Builder)
+}
+
+var (
+	metricsRegistry []metricCollector
+	registryMutex   sync.Mutex
+)
+
+func registerMetric(m metricCollector) {
+	registryMutex.Lock()
+	metricsRegistry = append(metricsRegistry, m)
+	registryMutex.Unlock()
+}
+
+// 标签值转义
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+// 每个样本都带上部署标签（instance / environment）
+func formatLabels(names, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(names)+3)
+	deploy := deploymentLabels()
+	for i := 0; i+1 < len(deploy); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, deploy[i], escapeLabel(deploy[i+1])))
+	}
+	for i, name := range names {
+		if i < len(values) {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+		}
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[0], escapeLabel(extra[1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// counterVec 带标签计数器
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+	labelSets  map[string][]string
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}, labelSets: map[string][]string{}}
+	registerMetric(c)
+	return c
+}
+
+func (c *counterVec) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+func (c *counterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += v
+	c.labelSets[key] = labelValues
+	c.mu.Unlock()
+}
+
+// Value 当前计数（压测报告读取）
+func (c *counterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *counterVec) write(sb *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(sb, "%s%s %s\n", c.name, formatLabels(c.labels, c.labelSets[k]), formatFloat(c.values[k]))
+	}
+}
+
+// histogramVec 带标签直方图
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	registerMetric(h)
+	return h
+}
+
+func (h *histogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) write(sb *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labelValues, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(sb, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+// gaugeFunc 采集时实时取值的仪表
+type gaugeFunc struct {
+	name, help string
+	fn         func() float64
+}
+
+func newGaugeFunc(name, help string, fn func() float64) *gaugeFunc {
+	g := &gaugeFunc{name: name, help: help, fn: fn}
+	registerMetric(g)
+	return g
+}
+
+func (g *gaugeFunc) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s gauge\n%s%s %s\n", g.name, g.help, g.name, g.name, formatLabels(nil, nil), formatFloat(g.fn()))
+}
+
+// gaugeVecFunc 采集时实时取值的带标签仪表
+type gaugeVecFunc struct {
+	name, help string
+	labels     []string
+	fn         func() []gaugeSample
+}
+
+type gaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+func newGaugeVecFunc(name, help string, fn func() []gaugeSample, labels ...string) *gaugeVecFunc {
+	g := &gaugeVecFunc{name: name, help: help, labels: labels, fn: fn}
+	registerMetric(g)
+	return g
+}
+
+func (g *gaugeVecFunc) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, s := range g.fn() {
+		fmt.Fprintf(sb, "%s%s %s\n", g.name, formatLabels(g.labels, s.LabelValues), formatFloat(s.Value))
+	}
+}
+
+// 脚本耗时分桶（秒）
+var scriptDurationBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	metricCSVRows             = newCounterVec("meteora_csv_rows_processed_total", "CSV rows processed", "result")
+	metricSignals             = newCounterVec("meteora_signals_received_total", "New pool signals received per ingestor", "source")
+	metricAddLiquidity        = newCounterVec("meteora_add_liquidity_total", "addLiquidity executions", "result")
+	metricClaims              = newCounterVec("meteora_claims_total", "Claim executions", "result")
+	metricSwaps               = newCounterVec("meteora_swaps_total", "jupSwap executions", "result")
+	metricRemoveLiquidity     = newCounterVec("meteora_remove_liquidity_total", "removeLiquidity executions", "result")
+	metricPriceFetches        = newCounterVec("meteora_price_fetches_total", "Price fetches", "result")
+	metricTickerRuns          = newCounterVec("meteora_ticker_runs_total", "Scheduled job rounds", "job")
+	metricExecRetries         = newCounterVec("meteora_exec_retries_total", "External command retries", "target")
+	metricBreakerTrips        = newCounterVec("meteora_circuit_breaker_trips_total", "Circuit breaker trips", "target")
+	metricABAssignments       = newCounterVec("meteora_ab_assignments_total", "Pools assigned to A/B strategy variants", "variant")
+	metricPriceSources        = newCounterVec("meteora_price_source_requests_total", "Price provider lookups", "source", "result")
+	metricListPolicy          = newCounterVec("meteora_list_policy_actions_total", "Ban/allow list policy actions taken", "list", "subsystem", "action")
+	metricConfigReloads       = newCounterVec("meteora_config_reloads_total", "Config hot reload attempts", "result")
+	metricAlertsDropped       = newCounterVec("meteora_alerts_dropped_total", "Alerts dropped because the alert queue was full")
+	metricAdmissionRejections = newCounterVec("meteora_admission_rejections_total", "New pool signals rejected by admission rules", "rule")
+	metricRPCRequests         = newCounterVec("meteora_rpc_requests_total", "Native RPC requests per endpoint", "endpoint", "result")
+	metricTokenAccounts       = newCounterVec("meteora_token_accounts_total", "Associated token accounts pre-created (or found) before opening positions", "result")
+	metricBlockhash           = newCounterVec("meteora_blockhash_cache_total", "Blockhash cache lookups and refreshes", "result")
+	metricRPCRateLimited      = newCounterVec("meteora_rpc_rate_limited_total", "RPC rate limit / node lag signals seen in script output and RPC errors", "source")
+	metricTxFinal             = newCounterVec("meteora_transactions_final_total", "Tracked transactions by final status", "target", "status")
+	metricSkips               = newCounterVec("meteora_skips_total", "Decisions not to act, by stage and typed skip reason", "stage", "reason")
+	metricQueueJobs           = newCounterVec("meteora_queue_jobs_total", "Job queue outcomes per job type", "type", "result")
+	metricRebalances          = newCounterVec("meteora_rebalances_total", "Out-of-range position rebalances", "result")
+	metricWalletTx            = newCounterVec("meteora_wallet_transactions_total", "Wallet transactions seen by the watcher", "origin")
+	metricCompounds           = newCounterVec("meteora_compounds_total", "Claimed fees re-deposited into the same position", "result")
+	metricWebhookRejected     = newCounterVec("meteora_signal_webhook_rejected_total", "Pushed signal requests rejected by authentication or parsing", "reason")
+	metricGRPCRequests        = newCounterVec("meteora_grpc_requests_total", "gRPC control-plane calls by method and status code", "method", "code")
+	metricGRPCEventsDropped   = newCounterVec("meteora_grpc_events_dropped_total", "Bus events dropped because a gRPC event stream was full")
+	metricEntryTriggers       = newCounterVec("meteora_entry_triggers_total", "Pool files parked for, triggered by or expired waiting on price entry conditions", "result")
+	metricPortfolioLimited    = newCounterVec("meteora_portfolio_limited_total", "Pool openings queued or rejected by portfolio exposure limits", "limit")
+	metricSubsystemRestarts   = newCounterVec("meteora_subsystem_restarts_total", "Supervised subsystems restarted after a panic or error, and scheduled job runs abandoned as wedged", "subsystem", "reason")
+	metricSwapQuoteRejects    = newCounterVec("meteora_swap_quote_rejects_total", "Swaps skipped by swapQuoteGuard by reason (price_impact, low_output, quote_error)", "reason")
+	metricSwapVerifications   = newCounterVec("meteora_swap_verifications_total", "Post-swap balance checks by result (ok, deviation, partial, no_fill)", "result")
+	metricAlertRules          = newCounterVec("meteora_alert_rules_fired_total", "Alert rule notifications by rule name", "rule")
+	metricTxCost              = newCounterVec("meteora_tx_cost_sol_total", "On-chain transaction costs in SOL by target and kind (fee, priority, rent_paid, rent_refunded)", "target", "kind")
+	metricScriptSchema        = newCounterVec("meteora_script_output_mismatch_total", "Successful external command runs whose output lacked the structured events declared in scripts.registry", "script")
+	metricAPIThrottled        = newCounterVec("meteora_api_throttled_total", "HTTP 429 responses from upstream price / quote APIs (including those reported by scripts)", "api")
+	metricPanicCloses         = newCounterVec("meteora_panic_closes_total", "Manual panic closes by result (success, failure)", "result")
+	metricWALReconciled       = newCounterVec("meteora_wal_reconciled_total", "Commands interrupted in a previous run, by how startup reconciliation resolved them (rerun, confirmed, failed, manual)", "resolution")
+	metricInjectedFaults      = newCounterVec("meteora_injected_faults_total", "Failures and delays injected into external commands and RPC requests by -inject-failures / -inject-latency", "kind")
+	metricGoroutinePanics     = newCounterVec("meteora_goroutine_panics_total", "Panics recovered in background goroutines, workers and request handlers", "goroutine")
+	metricPriceFetchLatency   = newHistogramVec("meteora_price_fetch_duration_seconds", "Price fetch latency", scriptDurationBuckets)
+	metricSignalAge           = newHistogramVec("meteora_signal_age_seconds", "Signal age (since last_updated_first) when a pool file is picked up for opening", signalAgeBuckets)
+	metricScriptDuration      = newHistogramVec("meteora_script_duration_seconds", "External script run durations", scriptDurationBuckets, "script", "result")
+
+	_ = newGaugeFunc("meteora_inflight_tasks", "JSON tasks currently being processed", func() float64 { return float64(inFlightTasks.Load()) })
+	_ = newGaugeFunc("meteora_leader", "Whether this instance may execute transactions (1) or is a standby (0)", func() float64 {
+		if isLeader() {
+			return 1
+		}
+		return 0
+	})
+	_ = newGaugeFunc("meteora_paused", "Whether automation is paused (1) or running (0)", func() float64 {
+		if isPaused() {
+			return 1
+		}
+		return 0
+	})
+	_ = newGaugeFunc("meteora_clock_drift_seconds", "Local clock minus NTP time at the last check", func() float64 { return currentClockStatus().DriftMs / 1000 })
+	_ = newGaugeFunc("meteora_wallet_sol_balance", "Wallet SOL balance at the last check", func() float64 { return currentWalletBalance().SOL })
+	_ = newGaugeVecFunc("meteora_wallet_token_balance", "Wallet SPL token balances (UI amount) at the last check", walletTokenSamples, "mint")
+	_ = newGaugeVecFunc("meteora_priority_fee_micro_lamports", "Compute unit price currently set per operation", priorityFeeSamples, "operation")
+	_ = newGaugeFunc("meteora_portfolio_deployed_sol", "SOL deployed in open pools (PnL ledger)", func() float64 {
+		total, _, _ := portfolioExposure()
+		return total
+	})
+	_ = newGaugeVecFunc("meteora_pool_states", "Pools per state machine state", poolStateSamples, "state")
+	_ = newGaugeFunc("meteora_rpc_slot_lag", "Slots the RPC node is behind the reference endpoint at the last check", func() float64 { return float64(currentClusterHealth().SlotLag) })
+	_ = newGaugeFunc("meteora_data_volume_available", "Whether the data, state and log directories are available (1) or not (0)", func() float64 {
+		if dataVolumeUnavailable() {
+			return 0
+		}
+		return 1
+	})
+	_ = newGaugeFunc("meteora_cluster_tps", "Cluster transactions per second from recent performance samples", func() float64 { return currentClusterHealth().TPS })
+	_ = newGaugeVecFunc("meteora_job_queue_depth", "Jobs queued, waiting to retry or running per job type", jobQueueSamples, "type", "state")
+	_ = newGaugeVecFunc("meteora_rpc_endpoint_up", "Whether the RPC endpoint is currently in rotation", rpcEndpointSamples, "endpoint")
+	_ = newGaugeVecFunc("meteora_rpc_endpoint_latency_seconds", "Moving average RPC endpoint latency", rpcLatencySamples, "endpoint")
+	_ = newGaugeVecFunc("meteora_api_budget_waiting", "Requests queued for an upstream API's shared rate-limit budget", apiBudgetSamples, "api")
+	_ = newGaugeFunc("meteora_rpc_degrade_level", "Current RPC rate limit degradation level (0 = normal)", func() float64 { return float64(currentRPCDegradeLevel()) })
+	_ = newGaugeFunc("meteora_uptime_seconds", "Process uptime in seconds", func() float64 { return time.Since(startedAt).Seconds() })
+)
+
+// 结果标签
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// 记录外部脚本耗时
+func observeScript(script string, start time.Time, err error) {
+	metricScriptDuration.Observe(time.Since(start).Seconds(), script, resultLabel(err))
+}
+
+// /metrics 处理函数
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var sb strings.Builder
+	registryMutex.Lock()
+	collectors := append([]metricCollector(nil), metricsRegistry...)
+	registryMutex.Unlock()
+	for _, m := range collectors {
+		m.write(&sb)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(sb.String()))
+}
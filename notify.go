@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警事件类型
+const (
+	eventNewPool             = "new_pool"
+	eventAddLiquiditySuccess = "add_liquidity_success"
+	eventAddLiquidityFailure = "add_liquidity_failure"
+	eventClaimFailure        = "claim_failure"
+	eventSwapFailure         = "swap_failure"
+	eventSwapDeviation       = "swap_deviation"
+	eventPriceThreshold      = "price_threshold"
+	eventStopLoss            = "stop_loss"
+	eventTakeProfit          = "take_profit"
+	eventShutdown            = "shutdown"
+	eventCircuitOpen         = "circuit_open"
+	eventWalletActivity      = "wallet_activity"
+	eventTripwire            = "tripwire"
+	eventRateGuard           = "rate_guard"
+	eventClockDrift          = "clock_drift"
+	eventListPolicy          = "list_policy"
+	eventLowBalance          = "low_balance"
+	eventConfigReload        = "config_reload"
+	eventAutoBan             = "auto_ban"
+	eventRPCDegrade          = "rpc_degraded"
+	eventTxFailed            = "tx_failed"
+	eventClusterHealth       = "cluster_unhealthy"
+	eventJobInterrupted      = "job_interrupted"
+	eventRebalance           = "rebalance"
+	eventDataVolume          = "data_volume"
+	eventDailySummary        = "daily_summary"
+	eventTokenUnsafe         = "token_unsafe"
+	eventBus                 = "bus_event"
+	eventPoolStuck           = "pool_stuck"
+	eventSubsystemRestart    = "subsystem_restart"
+	eventGoroutinePanic      = "goroutine_panic"
+	eventAlertRule           = "alert_rule"
+	eventExportFailed        = "export_failed"
+	eventLeaderChanged       = "leader_changed"
+	eventPanicClose          = "panic_close"
+	eventKillSwitch          = "kill_switch"
+)
+
+// 告警级别
+const (
+	levelInfo     = "info"
+	levelWarning  = "warning"
+	levelCritical = "critical"
+)
+
+// Alert 一条告警消息
+type Alert struct {
+	Event  string            `json:"event"`
+	Level  string            `json:"level"`
+	Title  string            `json:"title"`
+	Text   string            `json:"text,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Key    string            `json:"-"` // 限流去重键（为空时按事件类型限流）
+	Time   time.Time         `json:"time"`
+
+	Instance    string `json:"instance,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// Notifier 告警后端接口
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// NotifyConfig 告警配置
+type NotifyConfig struct {
+	Enabled         bool                          `json:"enabled"`
+	Backends        []NotifyBackendConfig         `json:"backends"`
+	Routes          map[string]NotifyRouteConfig  `json:"routes"` // 事件类型 -> 路由，"*" 为默认路由
+	PriceThresholds map[string]PriceThresholdRule `json:"priceThresholds"`
+	Language        string                        `json:"language"`    // 告警文本语言（zh、en 或自定义模板的语言），后端可单独覆盖
+	TemplateDir     string                        `json:"templateDir"` // 模板目录 <dir>/<语言>/<事件>.tmpl
+	Templates       map[string]map[string]string  `json:"templates"`   // 语言 -> 事件（"*" 为默认）-> Go 模板，优先于模板目录
+}
+
+// NotifyBackendConfig 单个告警后端
+type NotifyBackendConfig struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // telegram / discord / webhook
+	BotToken   string `json:"botToken,omitempty"`
+	ChatID     string `json:"chatId,omitempty"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	Language   string `json:"language,omitempty"` // 覆盖 notify.language
+}
+
+// NotifyRouteConfig 事件路由与限流
+type NotifyRouteConfig struct {
+	Backends           []string `json:"backends"`           // 为空表示发送到全部后端
+	MinIntervalSeconds int      `json:"minIntervalSeconds"` // 同一事件（同一去重键）两次告警的最小间隔
+	Disabled           bool     `json:"disabled"`
+}
+
+// PriceThresholdRule 价格阈值告警（按 ca 配置）
+type PriceThresholdRule struct {
+	Above float64 `json:"above,omitempty"`
+	Below float64 `json:"below,omitempty"`
+}
+
+var (
+	notifiers     = map[string]Notifier{}
+	notifierMutex sync.RWMutex // 保护 notifiers（配置热更新时整体替换）
+	alertQueue    = make(chan Alert, 256)
+	lastAlertAt   = map[string]time.Time{}
+	alertMutex    sync.Mutex
+	priceSide     = map[string]string{} // ca -> above/below/inside，用于检测穿越
+	priceSideLock sync.Mutex
+	notifyHTTP    = &http.Client{Timeout: 10 * time.Second}
+)
+
+// alertText 按后端语言渲染的告警文本
+type alertText struct {
+	templates alertTemplates
+	lang      string
+}
+
+func (t alertText) format(alert Alert) string {
+	return t.templates.render(alert, t.lang)
+}
+
+// telegramNotifier Telegram Bot 后端
+type telegramNotifier struct {
+	name, token, chatID string
+	text                alertText
+}
+
+func (n *telegramNotifier) Name() string { return n.name }
+func (n *telegramNotifier) Send(ctx context.Context, alert Alert) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", n.token)
+	return postJSON(ctx, url, map[string]string{"chat_id": n.chatID, "text": n.text.format(alert)})
+}
+
+// discordNotifier Discord Webhook 后端
+type discordNotifier struct {
+	name, url string
+	text      alertText
+}
+
+func (n *discordNotifier) Name() string { return n.name }
+func (n *discordNotifier) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.url, map[string]string{"content": n.text.format(alert)})
+}
+
+// webhookNotifier 通用 Webhook 后端（原样推送 Alert JSON）
+type webhookNotifier struct{ name, url string }
+
+func (n *webhookNotifier) Name() string { return n.name }
+func (n *webhookNotifier) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.url, alert)
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 内置告警文本（模板渲染失败时使用）
+func formatAlertText(alert Alert) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %s", strings.ToUpper(alert.Level), alert.Title)
+	if tag := deploymentTag(alert.Environment, alert.Instance); tag != "" {
+		sb.WriteString(" " + tag)
+	}
+	if alert.Text != "" {
+		sb.WriteString("\n" + alert.Text)
+	}
+	keys := make([]string, 0, len(alert.Fields))
+	for k := range alert.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "\n%s: %s", k, alert.Fields[k])
+	}
+	return sb.String()
+}
+
+// 根据配置创建告警后端
+func buildNotifiers(cfg NotifyConfig) (map[string]Notifier, error) {
+	templates, err := buildAlertTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]Notifier{}
+	for _, b := range cfg.Backends {
+		if b.Name == "" {
+			return nil, fmt.Errorf("notify.backends 存在未命名后端")
+		}
+		text := alertText{templates: templates, lang: b.Language}
+		if text.lang == "" {
+			text.lang = cfg.Language
+		}
+		if text.lang == "" {
+			text.lang = defaultAlertLanguage
+		}
+		switch b.Type {
+		case "telegram":
+			if b.BotToken == "" || b.ChatID == "" {
+				return nil, fmt.Errorf("telegram 后端 %s 缺少 botToken/chatId", b.Name)
+			}
+			result[b.Name] = &telegramNotifier{name: b.Name, token: b.BotToken, chatID: b.ChatID, text: text}
+		case "discord":
+			if b.WebhookURL == "" {
+				return nil, fmt.Errorf("discord 后端 %s 缺少 webhookUrl", b.Name)
+			}
+			result[b.Name] = &discordNotifier{name: b.Name, url: b.WebhookURL, text: text}
+		case "webhook":
+			if b.WebhookURL == "" {
+				return nil, fmt.Errorf("webhook 后端 %s 缺少 webhookUrl", b.Name)
+			}
+			result[b.Name] = &webhookNotifier{name: b.Name, url: b.WebhookURL}
+		default:
+			return nil, fmt.Errorf("未知的告警后端类型: %s", b.Type)
+		}
+	}
+	return result, nil
+}
+
+// 查找事件路由
+func routeFor(event string) NotifyRouteConfig {
+	if r, ok := currentConfig().Notify.Routes[event]; ok {
+		return r
+	}
+	return currentConfig().Notify.Routes["*"]
+}
+
+// 判断是否被限流（同时记录本次发送时间）
+func alertRateLimited(alert Alert, route NotifyRouteConfig) bool {
+	if route.MinIntervalSeconds <= 0 {
+		return false
+	}
+	key := alert.Event + "|" + alert.Key
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+	if last, ok := lastAlertAt[key]; ok && time.Since(last) < time.Duration(route.MinIntervalSeconds)*time.Second {
+		return true
+	}
+	lastAlertAt[key] = time.Now()
+	return false
+}
+
+// 发送到路由中的所有后端
+func dispatchAlert(ctx context.Context, alert Alert) {
+	route := routeFor(alert.Event)
+	if route.Disabled || alertRateLimited(alert, route) {
+		return
+	}
+	backends := currentNotifiers()
+	targets := route.Backends
+	if pool := poolNotifyBackends(alert.Fields["pool"]); len(pool) > 0 {
+		targets = pool
+	}
+	if len(targets) == 0 {
+		for name := range backends {
+			targets = append(targets, name)
+		}
+	}
+	for _, name := range targets {
+		n, ok := backends[name]
+		if !ok {
+			continue
+		}
+		if err := n.Send(ctx, alert); err != nil {
+			logOutput("❌ 告警发送失败 [%s/%s]: %v\n", name, alert.Event, err)
+		}
+	}
+}
+
+// 异步发送告警（队列满时丢弃，不阻塞业务流程）
+func notify(event, level, title, text string, fields map[string]string) {
+	notifyKeyed(event, level, "", title, text, fields)
+}
+
+// 带限流去重键的异步告警
+func notifyKeyed(event, level, key, title, text string, fields map[string]string) {
+	if !currentConfig().Notify.Enabled || len(currentNotifiers()) == 0 {
+		return
+	}
+	if isDryRun() {
+		title = "[dry-run] " + title
+	} else if isDemo() {
+		title = "[demo] " + title
+	}
+	alert := Alert{Event: event, Level: level, Title: title, Text: text, Fields: fields, Key: key, Time: time.Now(),
+		Instance: deployInstance, Environment: deployEnvironment}
+	select {
+	case alertQueue <- alert:
+	default:
+		metricAlertsDropped.Inc()
+		logOutput("⚠️ 告警队列已满，丢弃告警: %s\n", title)
+	}
+}
+
+// 同步发送告警（用于进程退出等必须送达的场景）
+func notifySync(event, level, title, text string) {
+	if !currentConfig().Notify.Enabled || len(currentNotifiers()) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dispatchAlert(ctx, Alert{Event: event, Level: level, Title: title, Text: text, Time: time.Now(),
+		Instance: deployInstance, Environment: deployEnvironment})
+}
+
+// 初始化告警后端
+func initNotifier() error {
+	if !currentConfig().Notify.Enabled {
+		return nil
+	}
+	built, err := buildNotifiers(currentConfig().Notify)
+	if err != nil {
+		return err
+	}
+	setNotifiers(built)
+	logOutput("🔔 告警系统已启用，后端数: %d\n", len(built))
+	return nil
+}
+
+func currentNotifiers() map[string]Notifier {
+	notifierMutex.RLock()
+	defer notifierMutex.RUnlock()
+	return notifiers
+}
+
+func setNotifiers(built map[string]Notifier) {
+	notifierMutex.Lock()
+	defer notifierMutex.Unlock()
+	notifiers = built
+}
+
+// 告警发送协程（告警未启用时队列为空，配置热更新启用告警后无需重启）
+func startNotifier() {
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case alert := <-alertQueue:
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			dispatchAlert(ctx, alert)
+			cancel()
+		}
+	}
+}
+
+// 检查价格阈值穿越（仅在跨越阈值时告警一次）
+func checkPriceThresholds(poolAddress, tokenAddress, priceStr string) {
+	rule, ok := currentConfig().Notify.PriceThresholds[tokenAddress]
+	if !ok {
+		return
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if err != nil {
+		return
+	}
+	side := "inside"
+	if rule.Above > 0 && price >= rule.Above {
+		side = "above"
+	} else if rule.Below > 0 && price <= rule.Below {
+		side = "below"
+	}
+
+	priceSideLock.Lock()
+	prev, seen := priceSide[tokenAddress]
+	priceSide[tokenAddress] = side
+	priceSideLock.Unlock()
+
+	if side == "inside" || (seen && prev == side) {
+		return
+	}
+	threshold := rule.Above
+	if side == "below" {
+		threshold = rule.Below
+	}
+	notifyKeyed(eventPriceThreshold, levelWarning, tokenAddress,
+		fmt.Sprintf("价格%s阈值", map[string]string{"above": "突破上", "below": "跌破下"}[side]),
+		"", map[string]string{"pool": poolAddress, "ca": tokenAddress, "price": priceStr, "threshold": strconv.FormatFloat(threshold, 'g', -1, 64)})
+}
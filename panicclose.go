@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 紧急平仓（panic close --pool / POST /pools/{addr}/panic-close）：发现正在进行的 rug 时手动处理，不等规则触发：
+// 拉黑代币，取消该池（及其代币兑换）排队中的任务，再以最高优先级执行领取、移除全部流动性、兑换并关闭仓位
+const (
+	jobPanicClose    = "panicClose"
+	exitReasonPanic  = "panic"
+	panicCloseReason = "手动紧急平仓"
+)
+
+// PanicCloseResult 紧急平仓的受理结果
+type PanicCloseResult struct {
+	Pool      string           `json:"pool"`
+	Token     string           `json:"token,omitempty"`
+	Ban       *BanEntry        `json:"ban,omitempty"`
+	Cancelled []QueueJobStatus `json:"cancelled"` // 取消的排队任务
+	Status    string           `json:"status"`    // accepted（已加入队列）/ merged（已在队列中）
+}
+
+// panicClosePool 拉黑代币、取消排队中的任务并把平仓加入队列最前；返回的 channel 在平仓结束时关闭
+func panicClosePool(poolAddress, reason, source string) (PanicCloseResult, <-chan struct{}, error) {
+	if !poolExists(poolAddress) {
+		return PanicCloseResult{}, nil, fmt.Errorf("池不存在: %s", poolAddress)
+	}
+	if reason == "" {
+		reason = panicCloseReason
+	}
+	token := readTokenContractAddressFromPoolJSON(poolAddress)
+	result := PanicCloseResult{Pool: poolAddress, Token: token}
+	logWarn("🆘 紧急平仓", "pool", poolAddress, "token", token, "reason", reason, "source", source)
+
+	// 先拉黑，避免取消任务后又有新的开仓、兑换进入队列
+	kind, address := banKindToken, token
+	if token == "" {
+		kind, address = banKindPool, poolAddress
+	}
+	if e, err := addBan(kind, address, reason, source, 0); err != nil {
+		logError("❌ 紧急平仓拉黑失败", "pool", poolAddress, "token", token, "error", err)
+	} else {
+		result.Ban = &e
+	}
+
+	poolFile := poolAddress + ".json"
+	result.Cancelled = cancelQueuedJobs(func(j *QueuedJob) bool {
+		switch j.Type {
+		case jobAddLiquidity:
+			return filepath.Base(j.Key) == poolFile
+		case jobSwap:
+			return token != "" && strings.HasSuffix(j.Key, "/"+token)
+		case jobPanicClose:
+			return false
+		}
+		return j.Key == poolAddress
+	})
+	if len(result.Cancelled) > 0 {
+		logOutput("🗑️ 紧急平仓取消了 %d 个排队中的任务: %s\n", len(result.Cancelled), poolAddress)
+	}
+
+	done, queued := enqueueJob(&QueuedJob{
+		Type:   jobPanicClose,
+		Key:    poolAddress,
+		Urgent: true,
+		Run:    func() error { runPanicClose(poolAddress, reason); return nil },
+	})
+	result.Status = "accepted"
+	if !queued {
+		result.Status = "merged"
+	}
+	return result, done, nil
+}
+
+// runPanicClose 领取、移除全部流动性并兑换（removeLiquidity.ts 负责 swap），结果以 panic_close 告警
+func runPanicClose(poolAddress, reason string) bool {
+	label := poolLabel(poolAddress)
+	fields := map[string]string{"pool": poolAddress, "ca": readTokenContractAddressFromPoolJSON(poolAddress), "reason": reason}
+	if _, busy := closingPools.LoadOrStore(poolAddress, true); busy {
+		logWarn("⚠️ 池正在平仓，紧急平仓不再重复执行", "pool", poolAddress)
+		notifyKeyed(eventPanicClose, levelWarning, poolAddress, "紧急平仓: "+label, "池正在由其他规则平仓，未重复执行", fields)
+		return false
+	}
+	defer closingPools.Delete(poolAddress)
+
+	ok := claimAndClosePosition(poolAddress, exitReasonPanic)
+	if ok {
+		metricPanicCloses.Inc("success")
+		notifyKeyed(eventPanicClose, levelCritical, poolAddress, "紧急平仓完成: "+label, reason, fields)
+	} else {
+		metricPanicCloses.Inc("failure")
+		notifyKeyed(eventPanicClose, levelCritical, poolAddress, "紧急平仓失败: "+label, "领取并平仓未完成，请人工处理", fields)
+	}
+	return ok
+}
+
+// cmdPanic panic close --pool <addr>：默认交给运行中的进程执行（POST /pools/{addr}/panic-close），
+// --local 在本进程内执行（守护进程未运行时使用）
+func cmdPanic(args []string) {
+	if len(args) == 0 || args[0] != "close" {
+		fmt.Fprintf(os.Stderr, "用法: %s panic close --pool <addr> [--url <api>] [--local]\n", os.Args[0])
+		os.Exit(2)
+	}
+	fs, common := newCommandFlags("panic close")
+	pool := fs.String("pool", "", "池地址")
+	apiURL := fs.String("url", "", "运行中进程的管理接口地址（默认按配置 api.listen）")
+	local := fs.Bool("local", false, "在本进程内执行（守护进程未运行时使用）")
+	fs.Parse(args[1:])
+	if *pool == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if !*local {
+		loadAppConfig(common)
+		base := apiBaseURL(*apiURL)
+		if base == "" {
+			log.Fatalf("未启用 HTTP 管理接口（api.enabled），请通过 --url 指定运行中进程的接口地址，或用 --local 在本进程内执行")
+		}
+		req, err := newAPIRequest(context.Background(), http.MethodPost, base+"/pools/"+*pool+"/panic-close")
+		if err != nil {
+			log.Fatal(err)
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Fatalf("连接运行中的进程失败: %v（守护进程未运行时使用 --local）", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode >= 300 {
+			log.Fatalf("紧急平仓失败: HTTP %d: %v", resp.StatusCode, body["error"])
+		}
+		cancelled, _ := body["cancelled"].([]interface{})
+		fmt.Printf("🆘 紧急平仓已加入队列最前（%v），取消了 %d 个排队中的任务，进度见日志与 GET /queue\n", body["status"], len(cancelled))
+		return
+	}
+
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+	result, done, err := panicClosePool(*pool, "", banSourceCLI)
+	if err != nil {
+		log.Fatalf("紧急平仓失败: %v", err)
+	}
+	<-done
+	if state, ok := poolStateOf(*pool); !ok || state != poolClosed {
+		log.Fatalf("紧急平仓未完成，请人工处理: %s", *pool)
+	}
+	fmt.Printf("✅ 紧急平仓完成: %s\n", *pool)
+	if result.Ban != nil {
+		fmt.Printf("🚫 已拉黑%s: %s\n", result.Ban.Kind, result.Ban.Address)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// error 事件中 panic 的阶段名
+const stagePanic = "panic"
+
+// PanicRecord 按 goroutine 名称汇总的 panic（GET /panics）
+type PanicRecord struct {
+	Goroutine string `json:"goroutine"`
+	Count     int64  `json:"count"`
+	FirstAt   string `json:"firstAt"`
+	LastAt    string `json:"lastAt"`
+	LastPool  string `json:"lastPool,omitempty"`
+	LastValue string `json:"lastValue"`
+	LastStack string `json:"lastStack"`
+}
+
+var (
+	panicMutex   sync.Mutex
+	panicRecords = map[string]*PanicRecord{}
+)
+
+// recoverPanic 在 goroutine 或单次处理的开头 defer 调用：panic 只结束当前这次处理，记录堆栈、发布 error 事件并告警，其余部分继续运行
+func recoverPanic(goroutine, pool string) {
+	if r := recover(); r != nil {
+		notePanic(goroutine, pool, &panicError{value: r, stack: debug.Stack()})
+	}
+}
+
+// safeGo 后台运行 fn，panic 时按 recoverPanic 处理
+func safeGo(goroutine, pool string, fn func()) {
+	go func() {
+		defer recoverPanic(goroutine, pool)
+		fn()
+	}()
+}
+
+// callRecovered 执行一次处理（主循环中的单条信号、单个文件等），panic 时按 recoverPanic 处理后返回
+func callRecovered(goroutine, pool string, fn func()) {
+	defer recoverPanic(goroutine, pool)
+	fn()
+}
+
+// notePanic 记录 panic 并发布 error 事件、按 goroutine 去重告警
+func notePanic(goroutine, pool string, pe *panicError) {
+	n := recordPanic(goroutine, pool, pe)
+	publishPanic(goroutine, pool, pe)
+	notifyKeyed(eventGoroutinePanic, levelCritical, goroutine, "后台处理异常",
+		fmt.Sprintf("%s: %v（累计 %d 次），已恢复，其余部分继续运行", goroutine, pe.value, n), map[string]string{"goroutine": goroutine, "pool": pool})
+}
+
+// publishPanic 发布 stage 为 panic 的 error 事件
+func publishPanic(goroutine, pool string, pe *panicError) {
+	publishError(stagePanic, pool, "", pe, map[string]string{"goroutine": goroutine})
+}
+
+// recordPanic 记录堆栈日志、计数并汇总到 goroutine 名下，返回该 goroutine 的累计次数（不发布事件，供事件分发自身使用）
+func recordPanic(goroutine, pool string, pe *panicError) int64 {
+	metricGoroutinePanics.Inc(goroutine)
+	logError("❌ goroutine panic，已恢复", "goroutine", goroutine, "pool", pool, "panic", pe.value, "stack", string(pe.stack))
+
+	now := time.Now().Format(time.RFC3339)
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+	rec, ok := panicRecords[goroutine]
+	if !ok {
+		rec = &PanicRecord{Goroutine: goroutine, FirstAt: now}
+		panicRecords[goroutine] = rec
+	}
+	rec.Count++
+	rec.LastAt, rec.LastPool = now, pool
+	rec.LastValue, rec.LastStack = fmt.Sprint(pe.value), string(pe.stack)
+	return rec.Count
+}
+
+// listPanics 各 goroutine 的 panic 汇总（按最近一次时间倒序）
+func listPanics() []PanicRecord {
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+	result := make([]PanicRecord, 0, len(panicRecords))
+	for _, rec := range panicRecords {
+		result = append(result, *rec)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].LastAt != result[b].LastAt {
+			return result[a].LastAt > result[b].LastAt
+		}
+		return result[a].Goroutine < result[b].Goroutine
+	})
+	return result
+}
+
+// recoverHandler HTTP / gRPC 请求处理 panic 时记录并返回 500（net/http 默认只打印到标准错误）；
+// http.ErrAbortHandler 是主动中断请求，原样抛出
+func recoverHandler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logError("❌ 请求处理 panic", "server", name, "method", r.Method, "path", r.URL.Path)
+				notePanic(name, "", &panicError{value: v, stack: debug.Stack()})
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
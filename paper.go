@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 池运行模式：paper 走模拟流程，live 走真实执行
+const (
+	poolModeLive  = "live"
+	poolModePaper = "paper"
+)
+
+// 模拟执行记录文件
+func paperActionsPath() string { return dataPath("paper", "actions.jsonl") }
+
+// PaperPosition 模拟仓位
+type PaperPosition struct {
+	OpenedAt   string `json:"openedAt"`
+	ClaimCount int    `json:"claimCount"`
+	ClosedAt   string `json:"closedAt,omitempty"`
+}
+
+// poolModeState 持久化的池模式与模拟仓位（data/state/pool_modes.json）
+type poolModeState struct {
+	Modes     map[string]string         `json:"modes"`
+	Positions map[string]*PaperPosition `json:"positions"`
+}
+
+var poolModeMutex sync.Mutex
+
+// 每次从文件读取，便于 CLI 修改后运行中的进程立即生效
+func loadPoolModeState() *poolModeState {
+	st := &poolModeState{Modes: map[string]string{}, Positions: map[string]*PaperPosition{}}
+	if err := loadStateFile("pool_modes", st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if st.Modes == nil {
+		st.Modes = map[string]string{}
+	}
+	if st.Positions == nil {
+		st.Positions = map[string]*PaperPosition{}
+	}
+	return st
+}
+
+// 修改池模式状态（读-改-写）
+func updatePoolModeState(fn func(st *poolModeState)) error {
+	poolModeMutex.Lock()
+	defer poolModeMutex.Unlock()
+	st := loadPoolModeState()
+	fn(st)
+	return saveStateFile("pool_modes", st)
+}
+
+func getPoolMode(poolAddress string) string {
+	st := loadPoolModeState()
+	if mode, ok := st.Modes[poolAddress]; ok {
+		return mode
+	}
+	return currentConfig().DefaultPoolMode
+}
+
+func isPaperPool(poolAddress string) bool { return getPoolMode(poolAddress) == poolModePaper }
+
+// 设置池模式
+func setPoolMode(poolAddress, mode string) error {
+	if mode != poolModeLive && mode != poolModePaper {
+		return fmt.Errorf("未知的池模式: %s", mode)
+	}
+	if mode == poolModePaper && readPositionFromPoolJSON(poolAddress) != "" {
+		return fmt.Errorf("池已有真实仓位，不能降级为 paper: %s", poolAddress)
+	}
+	err := updatePoolModeState(func(st *poolModeState) {
+		st.Modes[poolAddress] = mode
+		// 升级为实盘时结束模拟仓位
+		if p, ok := st.Positions[poolAddress]; ok && mode == poolModeLive && p.ClosedAt == "" {
+			p.ClosedAt = time.Now().Format(time.RFC3339)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	logOutput("🔀 池模式已切换: %s -> %s\n", poolAddress, mode)
+	return nil
+}
+
+// 模拟仓位是否处于打开状态
+func paperHasOpenPosition(poolAddress string) bool {
+	p, ok := loadPoolModeState().Positions[poolAddress]
+	return ok && p.ClosedAt == ""
+}
+
+// 记录一次模拟执行（日志 + data/paper/actions.jsonl）
+func simulatePoolAction(poolAddress, action string, args []string) {
+	logOutput("📝 [paper] 模拟执行 %s: %s\n", action, strings.Join(args, " "))
+
+	record, _ := json.Marshal(map[string]interface{}{
+		"time":        time.Now().Format(time.RFC3339),
+		"pool":        poolAddress,
+		"action":      action,
+		"args":        args,
+		"profile":     poolProfileName(poolAddress),
+		"instance":    deployInstance,
+		"environment": deployEnvironment,
+	})
+	if err := os.MkdirAll(filepath.Dir(paperActionsPath()), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(paperActionsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(record, '\n'))
+
+	now := time.Now().Format(time.RFC3339)
+	updatePoolModeState(func(st *poolModeState) {
+		switch action {
+		case "addLiquidity":
+			st.Positions[poolAddress] = &PaperPosition{OpenedAt: now}
+		case "claim":
+			if p, ok := st.Positions[poolAddress]; ok {
+				p.ClaimCount++
+			}
+		case "removeLiquidity":
+			if p, ok := st.Positions[poolAddress]; ok && p.ClosedAt == "" {
+				p.ClosedAt = now
+			}
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// 最小的 Parquet 写入：单个行组、每列一个 PLAIN 编码的数据页（v1）、不压缩，所有列为 REQUIRED。
+// 只支持导出用到的三种类型（UTF8 字符串、DOUBLE、INT64），元数据按 Thrift compact 协议编码
+
+// Parquet 物理类型与枚举值（parquet.thrift）
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired     = 0
+	parquetConvertedUTF = 0
+	parquetEncPlain     = 0
+	parquetRLE          = 3
+	parquetDataPage     = 0
+	parquetCodecNone    = 0
+)
+
+// Thrift compact 协议的字段类型
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter Thrift compact 协议编码（只实现元数据用到的类型）
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // 各层结构体上一个字段的 id（字段头按差值编码）
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := w.last[len(w.last)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	w.last[len(w.last)-1] = id
+}
+
+func (w *thriftWriter) beginStruct() { w.last = append(w.last, 0) }
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) str(id int16, v string) {
+	w.fieldHeader(id, thriftBinary)
+	w.binary(v)
+}
+
+// list 写入列表字段头（之后由调用方写入 n 个元素）
+func (w *thriftWriter) list(id int16, elemType byte, n int) {
+	w.fieldHeader(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.varint(uint64(n))
+	}
+}
+
+// field 开始一个结构体字段（之后 beginStruct ... endStruct）
+func (w *thriftWriter) field(id int16) { w.fieldHeader(id, thriftStruct) }
+
+// writeParquet 将表写为 Parquet 文件
+func writeParquet(t *exportTable) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(t.Columns))
+	if len(t.Rows) > 0 {
+		for i, col := range t.Columns {
+			var page bytes.Buffer
+			for _, row := range t.Rows {
+				if err := parquetPlainValue(&page, col.Type, row[i]); err != nil {
+					return nil, fmt.Errorf("列 %s: %v", col.Name, err)
+				}
+			}
+			var h thriftWriter
+			h.beginStruct()
+			h.i32(1, parquetDataPage)
+			h.i32(2, int32(page.Len()))
+			h.i32(3, int32(page.Len()))
+			h.field(5)
+			h.beginStruct()
+			h.i32(1, int32(len(t.Rows)))
+			h.i32(2, parquetEncPlain)
+			h.i32(3, parquetRLE)
+			h.i32(4, parquetRLE)
+			h.endStruct()
+			h.endStruct()
+			chunks[i] = chunk{offset: int64(out.Len()), size: int64(h.buf.Len() + page.Len())}
+			out.Write(h.buf.Bytes())
+			out.Write(page.Bytes())
+		}
+	}
+
+	var m thriftWriter
+	m.beginStruct()
+	m.i32(1, 1)
+	m.list(2, thriftStruct, len(t.Columns)+1)
+	m.beginStruct()
+	m.str(4, "schema")
+	m.i32(5, int32(len(t.Columns)))
+	m.endStruct()
+	for _, col := range t.Columns {
+		m.beginStruct()
+		m.i32(1, parquetPhysicalType(col.Type))
+		m.i32(3, parquetRequired)
+		m.str(4, col.Name)
+		if col.Type == exportString {
+			m.i32(6, parquetConvertedUTF)
+		}
+		m.endStruct()
+	}
+	m.i64(3, int64(len(t.Rows)))
+	if len(t.Rows) == 0 {
+		m.list(4, thriftStruct, 0)
+	} else {
+		var total int64
+		for _, c := range chunks {
+			total += c.size
+		}
+		m.list(4, thriftStruct, 1)
+		m.beginStruct()
+		m.list(1, thriftStruct, len(t.Columns))
+		for i, col := range t.Columns {
+			m.beginStruct()
+			m.i64(2, chunks[i].offset)
+			m.field(3)
+			m.beginStruct()
+			m.i32(1, parquetPhysicalType(col.Type))
+			m.list(2, thriftI32, 2)
+			m.zigzag(parquetEncPlain)
+			m.zigzag(parquetRLE)
+			m.list(3, thriftBinary, 1)
+			m.binary(col.Name)
+			m.i32(4, parquetCodecNone)
+			m.i64(5, int64(len(t.Rows)))
+			m.i64(6, chunks[i].size)
+			m.i64(7, chunks[i].size)
+			m.i64(9, chunks[i].offset)
+			m.endStruct()
+			m.endStruct()
+		}
+		m.i64(2, total)
+		m.i64(3, int64(len(t.Rows)))
+		m.endStruct()
+	}
+	m.str(6, "meteora_dlmm export")
+	m.endStruct()
+
+	out.Write(m.buf.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(m.buf.Len()))
+	out.WriteString("PAR1")
+	return out.Bytes(), nil
+}
+
+func parquetPhysicalType(typ string) int32 {
+	switch typ {
+	case exportFloat:
+		return parquetDouble
+	case exportInt:
+		return parquetInt64
+	}
+	return parquetByteArray
+}
+
+// parquetPlainValue 按 PLAIN 编码写入一个值
+func parquetPlainValue(buf *bytes.Buffer, typ string, v interface{}) error {
+	switch typ {
+	case exportFloat:
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("期望 float64，实际为 %T", v)
+		}
+		return binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+	case exportInt:
+		n, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("期望 int64，实际为 %T", v)
+		}
+		return binary.Write(buf, binary.LittleEndian, n)
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("期望 string，实际为 %T", v)
+		}
+		binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+		buf.WriteString(s)
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriteParquet(t *testing.T) {
+	f64 := func(v float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)) }
+	i64 := func(v int64) []byte { return binary.LittleEndian.AppendUint64(nil, uint64(v)) }
+	str := func(s string) []byte { return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...) }
+	cols := []exportColumn{{"pool", exportString}, {"value", exportFloat}, {"count", exportInt}}
+
+	tests := []struct {
+		name    string
+		rows    [][]interface{}
+		want    [][]byte // 文件中应出现的 PLAIN 编码值（按列连续写入）
+		wantErr string
+	}{
+		{"多行", [][]interface{}{{"P1", 1.5, int64(3)}, {"池二", -0.25, int64(-7)}},
+			[][]byte{append(str("P1"), str("池二")...), append(f64(1.5), f64(-0.25)...), append(i64(3), i64(-7)...)}, ""},
+		{"空表", nil, nil, ""},
+		{"类型不符", [][]interface{}{{"P1", 1, int64(3)}}, nil, "列 value"},
+		{"字符串列不是字符串", [][]interface{}{{nil, 1.0, int64(3)}}, nil, "列 pool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := writeParquet(&exportTable{Name: "positions", Columns: cols, Rows: tt.rows})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("应返回包含 %q 的错误，实际 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) < 12 || string(out[:4]) != "PAR1" || string(out[len(out)-4:]) != "PAR1" {
+				t.Fatalf("文件首尾缺少 PAR1: % x", out)
+			}
+			footerLen := int(binary.LittleEndian.Uint32(out[len(out)-8:]))
+			if footerLen <= 0 || 4+footerLen > len(out)-8 {
+				t.Fatalf("元数据长度 %d 超出文件（%d 字节）", footerLen, len(out))
+			}
+			footer := out[len(out)-8-footerLen : len(out)-8]
+			for _, c := range cols {
+				if !bytes.Contains(footer, []byte(c.Name)) {
+					t.Errorf("元数据中没有列 %s", c.Name)
+				}
+			}
+			data := out[4 : len(out)-8-footerLen]
+			for i, w := range tt.want {
+				if !bytes.Contains(data, w) {
+					t.Errorf("数据页中没有列 %s 的值 % x", cols[i].Name, w)
+				}
+			}
+			if tt.rows == nil && len(data) != 0 {
+				t.Errorf("空表不应写出数据页，实际 %d 字节", len(data))
+			}
+		})
+	}
+}
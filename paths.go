@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// 旧版本固定的安装目录，目录存在且没有其他线索时仍作为程序目录
+const legacyBaseDir = "/Users/yqw/meteora_dlmm"
+
+// 传给子进程（TS 脚本）的目录环境变量，也可在启动前设置
+const (
+	envBaseDir = "METEORA_BASE_DIR"
+	envDataDir = "METEORA_DATA_DIR"
+)
+
+// 程序目录（TS 脚本、jupSwap、.env、config.json 所在目录）与数据目录（池文件、状态、日志、价格等）
+var (
+	appBaseDir = detectBaseDir()
+	appDataDir = detectDataDir(appBaseDir)
+)
+
+// detectBaseDir 程序目录：METEORA_BASE_DIR，其次为包含 addLiquidity.ts 的当前目录或可执行文件所在目录，再次为旧的安装目录，最后为当前目录
+func detectBaseDir() string {
+	if dir := os.Getenv(envBaseDir); dir != "" {
+		return absPath(dir)
+	}
+	cwd, _ := os.Getwd()
+	candidates := []string{cwd}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Dir(exe))
+	}
+	for _, dir := range candidates {
+		if dir != "" && fileExists(filepath.Join(dir, "addLiquidity.ts")) {
+			return dir
+		}
+	}
+	if fileExists(legacyBaseDir) {
+		return legacyBaseDir
+	}
+	return cwd
+}
+
+// detectDataDir 数据目录：METEORA_DATA_DIR，其次为已存在的 <程序目录>/data；都没有时 Linux 等系统使用 XDG 数据目录，macOS 使用 <程序目录>/data
+func detectDataDir(base string) string {
+	if dir := os.Getenv(envDataDir); dir != "" {
+		return absPath(dir)
+	}
+	local := filepath.Join(base, "data")
+	if fileExists(local) || runtime.GOOS == "darwin" {
+		return local
+	}
+	return filepath.Join(xdgDataHome(), "meteora_dlmm")
+}
+
+// xdgDataHome $XDG_DATA_HOME，未设置（或不是绝对路径）时为 ~/.local/share
+func xdgDataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "meteora_dlmm")
+	}
+	return filepath.Join(home, ".local", "share")
+}
+
+// setPaths 按命令行参数 -base-dir / -data-dir 覆盖目录（为空时保持自动判断），并通过环境变量传给子进程
+func setPaths(base, data string) {
+	if base != "" {
+		appBaseDir = absPath(base)
+		if os.Getenv(envDataDir) == "" {
+			appDataDir = detectDataDir(appBaseDir)
+		}
+	}
+	if data != "" {
+		appDataDir = absPath(data)
+	}
+	os.Setenv(envBaseDir, appBaseDir)
+	os.Setenv(envDataDir, appDataDir)
+}
+
+// basePath 程序目录下的路径
+func basePath(elem ...string) string {
+	return filepath.Join(append([]string{appBaseDir}, elem...)...)
+}
+
+// dataPath 数据目录下的路径
+func dataPath(elem ...string) string {
+	return filepath.Join(append([]string{appDataDir}, elem...)...)
+}
+
+// resolvePath 配置中的相对路径按程序目录解析；空字符串保持为空
+func resolvePath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return basePath(p)
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// resolvePaths 配置文件中的相对路径统一按程序目录解析，之后各处只处理绝对路径
+func (c *Config) resolvePaths() {
+	for i := range c.CSVSources {
+		c.CSVSources[i].Path = resolvePath(c.CSVSources[i].Path)
+		c.CSVSources[i].OutputDir = resolvePath(c.CSVSources[i].OutputDir)
+	}
+	for i := range c.Ingest.JSONDirs {
+		c.Ingest.JSONDirs[i].Dir = resolvePath(c.Ingest.JSONDirs[i].Dir)
+		c.Ingest.JSONDirs[i].OutputDir = resolvePath(c.Ingest.JSONDirs[i].OutputDir)
+	}
+	for i := range c.Ingest.Redis {
+		c.Ingest.Redis[i].OutputDir = resolvePath(c.Ingest.Redis[i].OutputDir)
+	}
+	for i := range c.Ingest.NATS {
+		c.Ingest.NATS[i].OutputDir = resolvePath(c.Ingest.NATS[i].OutputDir)
+	}
+	c.Ingest.HTTP.OutputDir = resolvePath(c.Ingest.HTTP.OutputDir)
+	c.Backpressure.StatusFile = resolvePath(c.Backpressure.StatusFile)
+	c.Logging.Dir = resolvePath(c.Logging.Dir)
+	c.Audit.Dir = resolvePath(c.Audit.Dir)
+	c.Notify.TemplateDir = resolvePath(c.Notify.TemplateDir)
+	c.PositionImport.File = resolvePath(c.PositionImport.File)
+	c.PoolOverrides.Dir = resolvePath(c.PoolOverrides.Dir)
+	c.KillSwitch.File = resolvePath(c.KillSwitch.File)
+	for i := range c.DataVolume.Paths {
+		c.DataVolume.Paths[i] = resolvePath(c.DataVolume.Paths[i])
+	}
+	c.DataVolume.MarkerFile = resolvePath(c.DataVolume.MarkerFile)
+	c.GRPC.CertFile = resolvePath(c.GRPC.CertFile)
+	c.GRPC.KeyFile = resolvePath(c.GRPC.KeyFile)
+	for target, s := range c.Scripts.Registry {
+		s.Dir = resolvePath(s.Dir)
+		c.Scripts.Registry[target] = s
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PnL 台账事件类型
+const (
+	pnlDeposit = "deposit"
+	pnlClaim   = "claim"
+	pnlSwap    = "swap"
+	pnlClose   = "close"
+	pnlFee     = "fee"  // 交易费（含优先费）
+	pnlRent    = "rent" // 新建账户押金（负数为关闭账户退回）
+)
+
+// 每个池保留的台账事件数
+const maxPnLEntries = 200
+
+// 日报目录（不放在 data 根目录，避免被当作新池）
+func pnlReportDir() string { return dataPath("reports") }
+
+// PnLEntry 台账事件
+type PnLEntry struct {
+	At       string  `json:"at"`
+	Kind     string  `json:"kind"`
+	Position string  `json:"position,omitempty"`
+	SOL      float64 `json:"sol,omitempty"`
+	USD      float64 `json:"usd,omitempty"`
+	Note     string  `json:"note,omitempty"`
+	Profile  string  `json:"profile,omitempty"` // 记录时生效的参数档位
+}
+
+// PositionValue 单个仓位（主仓位或阶梯档位）最近一次领取时的估值
+type PositionValue struct {
+	ClaimedUSD float64 `json:"claimedUSD"` // 累计已领取
+	CurrentUSD float64 `json:"currentUSD"` // 当前仓位 + 未领取费用
+}
+
+// PoolPnL 单个池的成本与估值（data/state/pnl.json: pool -> 台账）
+type PoolPnL struct {
+	PoolAddress  string                    `json:"poolAddress"`
+	TokenAddress string                    `json:"ca,omitempty"`
+	Mode         string                    `json:"mode"`
+	Variant      string                    `json:"variant,omitempty"` // A/B 变体
+	OpenedAt     string                    `json:"openedAt"`
+	ClosedAt     string                    `json:"closedAt,omitempty"`
+	CostSOL      float64                   `json:"costSOL"`
+	CostUSD      float64                   `json:"costUSD"` // 按开仓时的 SOL 价格折算（未知时取首次领取的价格）
+	SolUSD       float64                   `json:"solUSD"`  // 最近一次领取时的 SOL 价格
+	Positions    map[string]*PositionValue `json:"positions"`
+	Swaps        int                       `json:"swaps"`
+	SwapProceeds map[string]float64        `json:"swapProceeds,omitempty"` // 程序兑换归属到该池的收入（输出代币 -> 数量）
+	FeesSOL      float64                   `json:"feesSOL,omitempty"`      // 链上交易费（含优先费，见 txCost）
+	FeesUSD      float64                   `json:"feesUSD,omitempty"`
+	RentSOL      float64                   `json:"rentSOL,omitempty"` // 净押金（新建账户押金减关闭账户退回）
+	RentUSD      float64                   `json:"rentUSD,omitempty"`
+	// 领取脚本实际到账的手续费（见 claims.go）：次数、各代币数量与领取前估算的价值
+	Claims         int                `json:"claims,omitempty"`
+	ClaimedTokens  map[string]float64 `json:"claimedTokens,omitempty"`
+	ClaimedFeesUSD float64            `json:"claimedFeesUSD,omitempty"`
+	ClaimedFeesSOL float64            `json:"claimedFeesSOL,omitempty"`
+	Entries        []PnLEntry         `json:"entries"`
+}
+
+// PnLSummary 盈亏汇总：已实现 = 已领取的费用与奖励 - 交易费（平仓后为全部价值减成本、交易费与净押金），未实现 = 当前仓位 + 未领取费用 - 成本 - 净押金
+type PnLSummary struct {
+	PoolAddress   string  `json:"poolAddress,omitempty"`
+	TokenAddress  string  `json:"ca,omitempty"`
+	Mode          string  `json:"mode,omitempty"`
+	Variant       string  `json:"variant,omitempty"`
+	State         string  `json:"state,omitempty"` // open / closed
+	OpenedAt      string  `json:"openedAt,omitempty"`
+	ClosedAt      string  `json:"closedAt,omitempty"`
+	CostSOL       float64 `json:"costSOL"`
+	CostUSD       float64 `json:"costUSD"`
+	ValueSOL      float64 `json:"valueSOL"`
+	ValueUSD      float64 `json:"valueUSD"`
+	RealizedSOL   float64 `json:"realizedSOL"`
+	RealizedUSD   float64 `json:"realizedUSD"`
+	UnrealizedSOL float64 `json:"unrealizedSOL"`
+	UnrealizedUSD float64 `json:"unrealizedUSD"`
+	Swaps         int     `json:"swaps"`
+	FeesSOL       float64 `json:"feesSOL"`
+	FeesUSD       float64 `json:"feesUSD"`
+	RentSOL       float64 `json:"rentSOL"`
+	RentUSD       float64 `json:"rentUSD"`
+	// 领取记录中累计到账的手续费（次数与领取时的估值）
+	Claims         int                `json:"claims"`
+	ClaimedFeesUSD float64            `json:"claimedFeesUSD"`
+	ClaimedFeesSOL float64            `json:"claimedFeesSOL"`
+	ClaimedTokens  map[string]float64 `json:"claimedTokens,omitempty"`
+	// 按计价货币折算（reporting.currency 或 ?currency=）：成本与已领取按事件发生时的汇率，当前价值按最近一次估值时的汇率
+	Currency   string  `json:"currency,omitempty"`
+	Cost       float64 `json:"cost"`
+	Value      float64 `json:"value"`
+	Realized   float64 `json:"realized"`
+	Unrealized float64 `json:"unrealized"`
+	Fees       float64 `json:"fees"`
+	Rent       float64 `json:"rent"`
+}
+
+// PnLReport 各池与总体盈亏
+type PnLReport struct {
+	Pools []PnLSummary `json:"pools"`
+	Total PnLSummary   `json:"total"`
+}
+
+var (
+	pnlMutex   sync.Mutex
+	lastSolUSD float64 // 最近一次领取输出中的 SOL 价格，用于开仓成本折算
+)
+
+func loadPnLLedger() map[string]*PoolPnL {
+	ledger := map[string]*PoolPnL{}
+	if err := loadStateFile("pnl", &ledger); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return ledger
+}
+
+// 修改单个池的台账（读-改-写），台账不存在时 fn 收到 nil
+func updatePoolPnL(poolAddress string, fn func(p *PoolPnL) *PoolPnL) {
+	pnlMutex.Lock()
+	defer pnlMutex.Unlock()
+	ledger := loadPnLLedger()
+	old := ledger[poolAddress]
+	p := fn(old)
+	if p == nil {
+		return
+	}
+	// 同一个池再次开仓时保留上一轮已平仓的台账
+	if old != nil && old != p && old.ClosedAt != "" {
+		ledger[poolAddress+"@"+old.ClosedAt] = old
+	}
+	if len(p.Entries) > maxPnLEntries {
+		p.Entries = p.Entries[len(p.Entries)-maxPnLEntries:]
+	}
+	ledger[poolAddress] = p
+	if err := saveStateFile("pnl", ledger); err != nil {
+		logOutput("❌ 保存盈亏台账失败: %v\n", err)
+	}
+}
+
+func (p *PoolPnL) add(kind, position string, sol, usd float64, note string) {
+	p.Entries = append(p.Entries, PnLEntry{At: time.Now().Format(time.RFC3339), Kind: kind, Position: position, SOL: sol, USD: usd, Note: note, Profile: poolProfileName(p.PoolAddress)})
+}
+
+// 开仓（含阶梯档位）后记录成本
+func recordPnLDeposit(poolAddress, tokenAddress, position string, solAmount float64) {
+	tallyDeposit(solAmount)
+	pnlMutex.Lock()
+	solUSD := lastSolUSD
+	pnlMutex.Unlock()
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil || p.ClosedAt != "" {
+			p = &PoolPnL{
+				PoolAddress: poolAddress, TokenAddress: tokenAddress, Mode: getPoolMode(poolAddress), Variant: poolVariant(poolAddress),
+				OpenedAt: time.Now().Format(time.RFC3339), Positions: map[string]*PositionValue{},
+			}
+		}
+		p.CostSOL += solAmount
+		p.CostUSD += solAmount * solUSD
+		if position != "" && p.Positions[position] == nil {
+			p.Positions[position] = &PositionValue{}
+		}
+		p.add(pnlDeposit, position, solAmount, solAmount*solUSD, "")
+		return p
+	})
+}
+
+// 主仓位投入的 SOL：优先取 addLiquidity.ts 写入的 range.solAmount，其次为阶梯首档金额，最后为敞口管理分配的金额
+func mainDepositSOL(poolAddress string) float64 {
+	if rng := readOpenRangeFromPoolJSON(poolAddress); rng != nil && rng.SolAmount > 0 {
+		return rng.SolAmount
+	}
+	if ladderEnabled() {
+		return currentConfig().Ladder.Legs[0].SolAmount
+	}
+	return portfolioAllocation(poolAddress)
+}
+
+// 从 claimAllRewards.ts 输出解析 "sum=" 字段
+func parseClaimSum(output, marker string) (float64, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, marker) {
+			continue
+		}
+		if idx := strings.LastIndex(line, "sum="); idx >= 0 {
+			v, err := strconv.ParseFloat(strings.TrimSpace(line[idx+len("sum="):]), 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// 领取后记录仓位估值：累计已领取计为已实现，当前仓位 + 未领取费用计为未实现
+func recordPnLClaim(poolAddress, position, output string) {
+	valueUSD, solUSD, ok := parseClaimValues(output)
+	if !ok || solUSD <= 0 {
+		return
+	}
+	claimedUSD, ok := decodeScriptOutput([]byte(output)).Value("claimedUSD", "")
+	if !ok {
+		claimedUSD, _ = parseClaimSum(output, "累计已领取(USD)")
+	}
+	pnlMutex.Lock()
+	lastSolUSD = solUSD
+	pnlMutex.Unlock()
+	recordFXRate(solMint, solUSD, "claim")
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil || p.ClosedAt != "" {
+			return nil
+		}
+		if p.CostUSD == 0 {
+			p.CostUSD = p.CostSOL * solUSD
+		}
+		p.SolUSD = solUSD
+		p.Positions[position] = &PositionValue{ClaimedUSD: claimedUSD, CurrentUSD: valueUSD - claimedUSD}
+		p.add(pnlClaim, position, 0, claimedUSD, fmt.Sprintf("value=%.6f", valueUSD))
+		return p
+	})
+}
+
+// 平仓后兑换仍可归属到该池的时间窗口
+const swapAttributionWindow = 24 * time.Hour
+
+// 兑换后记录到持有该代币的未平仓池；没有未平仓池时归属到最近平仓的池（平仓后的兑换）。
+// 收入按池数均分，返回归属的池
+func recordPnLSwap(tokenAddress, outputMint string, proceeds float64, err error) []string {
+	if outputMint == "" {
+		outputMint = swapToSOL
+	}
+	note := "ok"
+	if err != nil {
+		note = err.Error()
+	}
+	pools := swapAttributionPools(tokenAddress)
+	share := 0.0
+	if len(pools) > 0 && err == nil {
+		share = proceeds / float64(len(pools))
+	}
+	for _, pool := range pools {
+		updatePoolPnL(pool, func(p *PoolPnL) *PoolPnL {
+			if p == nil {
+				return nil
+			}
+			if err == nil {
+				p.Swaps++
+			}
+			var sol float64
+			if share > 0 {
+				if p.SwapProceeds == nil {
+					p.SwapProceeds = map[string]float64{}
+				}
+				p.SwapProceeds[outputMint] += share
+				note = fmt.Sprintf("ok, proceeds=%g %s", share, outputMint)
+				if outputMint == swapToSOL {
+					sol = share
+				}
+			}
+			p.add(pnlSwap, "", sol, 0, fmt.Sprintf("%s -> %s: %s", tokenAddress, outputMint, note))
+			return p
+		})
+	}
+	return pools
+}
+
+// swapAttributionPools 兑换（及兑换交易的成本）归属的池：持有该代币的未平仓池；没有时为 swapAttributionWindow 内最近平仓的池
+func swapAttributionPools(tokenAddress string) []string {
+	pnlMutex.Lock()
+	var pools []string
+	var lastClosed string
+	var lastClosedAt time.Time
+	for key, p := range loadPnLLedger() {
+		if p.TokenAddress != tokenAddress {
+			continue
+		}
+		if p.ClosedAt == "" {
+			pools = append(pools, key)
+			continue
+		}
+		if at, perr := time.Parse(time.RFC3339, p.ClosedAt); perr == nil && time.Since(at) < swapAttributionWindow && at.After(lastClosedAt) {
+			lastClosed, lastClosedAt = key, at
+		}
+	}
+	pnlMutex.Unlock()
+	if len(pools) == 0 && lastClosed != "" {
+		pools = []string{lastClosed}
+	}
+	sort.Strings(pools)
+	return pools
+}
+
+// recordPnLTxCost 交易成本计入池的台账（已平仓的台账同样计入，如平仓交易本身的费用与退回的押金）
+func recordPnLTxCost(poolAddress string, c TxCost, at string) {
+	pnlMutex.Lock()
+	solUSD := lastSolUSD
+	pnlMutex.Unlock()
+	fee, rent := lamportsToSOL(c.FeeLamports), lamportsToSOL(c.RentLamports)
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil {
+			return nil
+		}
+		p.FeesSOL += fee
+		p.FeesUSD += fee * solUSD
+		p.RentSOL += rent
+		p.RentUSD += rent * solUSD
+		p.Entries = append(p.Entries, PnLEntry{At: at, Kind: pnlFee, SOL: fee, USD: fee * solUSD, Note: c.Target + " " + c.Signature, Profile: poolProfileName(poolAddress)})
+		if rent != 0 {
+			p.Entries = append(p.Entries, PnLEntry{At: at, Kind: pnlRent, SOL: rent, USD: rent * solUSD, Note: c.Target + " " + c.Signature, Profile: poolProfileName(poolAddress)})
+		}
+		return p
+	})
+}
+
+// 平仓后结转：以最近一次领取时的估值作为已实现价值
+func recordPnLClose(poolAddress, reason string) {
+	updatePoolPnL(poolAddress, func(p *PoolPnL) *PoolPnL {
+		if p == nil || p.ClosedAt != "" {
+			return nil
+		}
+		p.ClosedAt = time.Now().Format(time.RFC3339)
+		s := p.summaryIn(reportCurrency(), loadFXRates())
+		p.add(pnlClose, "", s.ValueSOL, s.ValueUSD, reason)
+		logOutput("📒 盈亏结转: pool=%s 成本 %s, 价值 %s, 已实现 %s\n",
+			poolAddress, formatMoney(s.Cost, s.Currency), formatMoney(s.Value, s.Currency), formatMoney(s.Realized, s.Currency))
+		return p
+	})
+}
+
+func (p *PoolPnL) summary() PnLSummary {
+	s := PnLSummary{
+		PoolAddress: p.PoolAddress, TokenAddress: p.TokenAddress, Mode: p.Mode, Variant: p.Variant, State: "open",
+		OpenedAt: p.OpenedAt, ClosedAt: p.ClosedAt, CostSOL: p.CostSOL, CostUSD: p.CostUSD, Swaps: p.Swaps,
+		FeesSOL: p.FeesSOL, FeesUSD: p.FeesUSD, RentSOL: p.RentSOL, RentUSD: p.RentUSD,
+		Claims: p.Claims, ClaimedFeesUSD: p.ClaimedFeesUSD, ClaimedFeesSOL: p.ClaimedFeesSOL, ClaimedTokens: p.ClaimedTokens,
+	}
+	// 记录成本时还没有 SOL 价格的，按最近一次领取时的价格折算
+	if s.FeesUSD == 0 && s.RentUSD == 0 {
+		s.FeesUSD, s.RentUSD = p.FeesSOL*p.SolUSD, p.RentSOL*p.SolUSD
+	}
+	var claimedUSD, currentUSD float64
+	for _, v := range p.Positions {
+		claimedUSD += v.ClaimedUSD
+		currentUSD += v.CurrentUSD
+	}
+	s.ValueUSD = claimedUSD + currentUSD
+	if p.ClosedAt != "" {
+		s.State = "closed"
+		s.RealizedUSD = s.ValueUSD - p.CostUSD - s.FeesUSD - s.RentUSD
+	} else {
+		s.RealizedUSD = claimedUSD - s.FeesUSD
+		s.UnrealizedUSD = currentUSD - p.CostUSD - s.RentUSD
+	}
+	if p.SolUSD > 0 {
+		s.ValueSOL = s.ValueUSD / p.SolUSD
+		if p.ClosedAt != "" {
+			s.RealizedSOL = s.ValueSOL - p.CostSOL - p.FeesSOL - p.RentSOL
+		} else {
+			s.RealizedSOL = claimedUSD/p.SolUSD - p.FeesSOL
+			s.UnrealizedSOL = currentUSD/p.SolUSD - p.CostSOL - p.RentSOL
+		}
+	}
+	return s
+}
+
+// summaryIn 在 summary 的基础上按计价货币折算：开仓成本与每次新增的领取按当时的汇率，
+// 当前仓位价值按最近一次领取估值（已平仓为平仓）时的汇率
+func (p *PoolPnL) summaryIn(currency string, rates fxRates) PnLSummary {
+	s := p.summary()
+	s.Currency = currency
+	var depositSOL, claimed, feesSOL, rentSOL float64
+	claimedUSD := map[string]float64{} // 仓位 -> 上一次领取时的累计已领取
+	valuedAt := time.Time{}
+	for _, e := range p.Entries {
+		at, err := time.Parse(time.RFC3339, e.At)
+		if err != nil {
+			continue
+		}
+		switch e.Kind {
+		case pnlDeposit:
+			v, ok := rates.convert(currency, e.SOL, 0, at)
+			if !ok {
+				v, _ = rates.convert(currency, 0, e.USD, at)
+			}
+			s.Cost += v
+			depositSOL += e.SOL
+		case pnlClaim:
+			if inc := e.USD - claimedUSD[e.Position]; inc > 0 {
+				v, _ := rates.convert(currency, 0, inc, at)
+				claimed += v
+			}
+			claimedUSD[e.Position] = e.USD
+			valuedAt = at
+		case pnlFee, pnlRent:
+			v, ok := rates.convert(currency, e.SOL, 0, at)
+			if !ok {
+				v, _ = rates.convert(currency, 0, e.USD, at)
+			}
+			if e.Kind == pnlFee {
+				s.Fees += v
+				feesSOL += e.SOL
+			} else {
+				s.Rent += v
+				rentSOL += e.SOL
+			}
+		}
+	}
+	// 台账事件超出保留条数时，较早的开仓按开仓时间折算
+	if rest := p.CostSOL - depositSOL; rest > 1e-9 {
+		if at, err := time.Parse(time.RFC3339, p.OpenedAt); err == nil {
+			v, _ := rates.convert(currency, rest, 0, at)
+			s.Cost += v
+		}
+	}
+	if at, err := time.Parse(time.RFC3339, p.OpenedAt); err == nil {
+		if rest := p.FeesSOL - feesSOL; rest > 1e-9 {
+			v, _ := rates.convert(currency, rest, 0, at)
+			s.Fees += v
+		}
+		if rest := p.RentSOL - rentSOL; math.Abs(rest) > 1e-9 {
+			v, _ := rates.convert(currency, rest, 0, at)
+			s.Rent += v
+		}
+	}
+	var currentUSD float64
+	for _, v := range p.Positions {
+		currentUSD += v.CurrentUSD
+	}
+	if at, err := time.Parse(time.RFC3339, p.ClosedAt); err == nil {
+		valuedAt = at
+	}
+	current, _ := rates.convert(currency, 0, currentUSD, valuedAt)
+	s.Value = claimed + current
+	if p.ClosedAt != "" {
+		s.Realized = s.Value - s.Cost - s.Fees - s.Rent
+	} else {
+		s.Realized = claimed - s.Fees
+		s.Unrealized = current - s.Cost - s.Rent
+	}
+	return s
+}
+
+func (s *PnLSummary) accumulate(o PnLSummary) {
+	s.CostSOL += o.CostSOL
+	s.CostUSD += o.CostUSD
+	s.ValueSOL += o.ValueSOL
+	s.ValueUSD += o.ValueUSD
+	s.RealizedSOL += o.RealizedSOL
+	s.RealizedUSD += o.RealizedUSD
+	s.UnrealizedSOL += o.UnrealizedSOL
+	s.UnrealizedUSD += o.UnrealizedUSD
+	s.Swaps += o.Swaps
+	s.FeesSOL += o.FeesSOL
+	s.FeesUSD += o.FeesUSD
+	s.RentSOL += o.RentSOL
+	s.RentUSD += o.RentUSD
+	s.Claims += o.Claims
+	s.ClaimedFeesUSD += o.ClaimedFeesUSD
+	s.ClaimedFeesSOL += o.ClaimedFeesSOL
+	s.Cost += o.Cost
+	s.Value += o.Value
+	s.Realized += o.Realized
+	s.Unrealized += o.Unrealized
+	s.Fees += o.Fees
+	s.Rent += o.Rent
+}
+
+// 单个池当前台账的盈亏（按 reporting.currency 折算）
+func poolPnL(poolAddress string) (PnLSummary, bool) {
+	pnlMutex.Lock()
+	p := loadPnLLedger()[poolAddress]
+	pnlMutex.Unlock()
+	if p == nil {
+		return PnLSummary{}, false
+	}
+	return p.summaryIn(reportCurrency(), loadFXRates()), true
+}
+
+// 各池与总体盈亏（按开仓时间倒序），currency 为折算的计价货币
+func buildPnLReport(currency string) PnLReport {
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	rates := loadFXRates()
+	report := PnLReport{Pools: make([]PnLSummary, 0, len(ledger)), Total: PnLSummary{Currency: currency}}
+	for _, p := range ledger {
+		s := p.summaryIn(currency, rates)
+		report.Pools = append(report.Pools, s)
+		report.Total.accumulate(s)
+	}
+	sort.Slice(report.Pools, func(a, b int) bool { return report.Pools[a].OpenedAt > report.Pools[b].OpenedAt })
+	return report
+}
+
+// writePnLReport 写出当日盈亏 CSV（未平仓的池与当日平仓的池 + 合计行），用于与钱包对账
+func writePnLReport() {
+	day := appNow().Format("2006-01-02")
+	currency := reportCurrency()
+	report := buildPnLReport(currency)
+
+	if err := os.MkdirAll(pnlReportDir(), 0755); err != nil {
+		logError("❌ 创建日报目录失败", "dir", pnlReportDir(), "error", err)
+		return
+	}
+	path := filepath.Join(pnlReportDir(), "pnl_"+day+".csv")
+	file, err := os.Create(path)
+	if err != nil {
+		logError("❌ 写入盈亏日报失败", "file", path, "error", err)
+		return
+	}
+	defer file.Close()
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	w := csv.NewWriter(file)
+	w.Write([]string{"date", "pool", "ca", "mode", "state", "openedAt", "closedAt", "costSOL", "costUSD", "valueSOL", "valueUSD",
+		"realizedSOL", "realizedUSD", "unrealizedSOL", "unrealizedUSD", "swaps", "currency", "cost", "value", "realized", "unrealized",
+		"instance", "environment", "feesSOL", "rentSOL", "fees", "rent", "claims", "claimedFeesUSD", "claimedFeesSOL"})
+	total := PnLSummary{Currency: currency}
+	for _, s := range report.Pools {
+		if s.State == "closed" && localDay(s.ClosedAt) != day {
+			continue
+		}
+		total.accumulate(s)
+		w.Write([]string{day, s.PoolAddress, s.TokenAddress, s.Mode, s.State, s.OpenedAt, s.ClosedAt, f(s.CostSOL), f(s.CostUSD),
+			f(s.ValueSOL), f(s.ValueUSD), f(s.RealizedSOL), f(s.RealizedUSD), f(s.UnrealizedSOL), f(s.UnrealizedUSD), strconv.Itoa(s.Swaps),
+			currency, f(s.Cost), f(s.Value), f(s.Realized), f(s.Unrealized), deployInstance, deployEnvironment,
+			f(s.FeesSOL), f(s.RentSOL), f(s.Fees), f(s.Rent), strconv.Itoa(s.Claims), f(s.ClaimedFeesUSD), f(s.ClaimedFeesSOL)})
+	}
+	w.Write([]string{day, "TOTAL", "", "", "", "", "", f(total.CostSOL), f(total.CostUSD), f(total.ValueSOL), f(total.ValueUSD),
+		f(total.RealizedSOL), f(total.RealizedUSD), f(total.UnrealizedSOL), f(total.UnrealizedUSD), strconv.Itoa(total.Swaps),
+		currency, f(total.Cost), f(total.Value), f(total.Realized), f(total.Unrealized), deployInstance, deployEnvironment,
+		f(total.FeesSOL), f(total.RentSOL), f(total.Fees), f(total.Rent), strconv.Itoa(total.Claims), f(total.ClaimedFeesUSD), f(total.ClaimedFeesSOL)})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logError("❌ 写入盈亏日报失败", "file", path, "error", err)
+		return
+	}
+	logInfo("📒 盈亏日报已生成", "file", path, "realized", formatMoney(total.Realized, currency), "unrealized", formatMoney(total.Unrealized, currency))
+}
@@ -0,0 +1,156 @@
+package main
+
+import "fmt"
+
+// 名单策略动作（按严重程度递增）
+const (
+	policyNone  = "none"  // 不处理
+	policyAlert = "alert" // 继续执行，发送告警
+	policySkip  = "skip"  // 跳过
+	policyClose = "close" // 跳过并领取平仓（仅 claim / price，这两个子系统针对已有仓位）
+)
+
+var policySeverity = map[string]int{"": 0, policyNone: 0, policyAlert: 1, policySkip: 2, policyClose: 3}
+
+// 受名单策略约束的子系统
+const (
+	subsystemEntry = "entry" // 信号入场与开仓（含候选池择优）
+	subsystemClaim = "claim" // 全局领取奖励
+	subsystemPrice = "price" // 价格获取
+	subsystemSweep = "sweep" // jupSwap 代币兑换回 SOL
+)
+
+// 名单
+const (
+	listTokenBan = "tokenBan"
+	listPoolBan  = "poolBan"
+	listAllow    = "allow"
+)
+
+// 平仓原因：名单策略
+const exitReasonListPolicy = "list_policy"
+
+// ListPolicyConfig 名单 × 子系统 -> 动作
+type ListPolicyConfig struct {
+	TokenBan ListActions `json:"tokenBan"` // 代币在 ban.csv 或管理的黑名单中
+	PoolBan  ListActions `json:"poolBan"`  // 池在 pools.csv 或管理的黑名单中
+	Allow    ListActions `json:"allow"`    // allow.csv 非空且代币与池都不在其中
+}
+
+// ListActions 各子系统的动作
+type ListActions struct {
+	Entry string `json:"entry"`
+	Claim string `json:"claim"`
+	Price string `json:"price"`
+	Sweep string `json:"sweep"`
+}
+
+func (a ListActions) action(subsystem string) string {
+	switch subsystem {
+	case subsystemEntry:
+		return a.Entry
+	case subsystemClaim:
+		return a.Claim
+	case subsystemPrice:
+		return a.Price
+	case subsystemSweep:
+		return a.Sweep
+	}
+	return policyNone
+}
+
+func (a ListActions) validate(list string) error {
+	for _, sub := range []string{subsystemEntry, subsystemClaim, subsystemPrice, subsystemSweep} {
+		action := a.action(sub)
+		if _, ok := policySeverity[action]; !ok {
+			return fmt.Errorf("listPolicy.%s.%s 只支持 none、alert、skip、close: %q", list, sub, action)
+		}
+		if action == policyClose && sub != subsystemClaim && sub != subsystemPrice {
+			return fmt.Errorf("listPolicy.%s.%s 不支持 close（只有 claim 与 price 针对已有仓位）", list, sub)
+		}
+	}
+	return nil
+}
+
+func (c ListPolicyConfig) validate() error {
+	for list, a := range map[string]ListActions{listTokenBan: c.TokenBan, listPoolBan: c.PoolBan, listAllow: c.Allow} {
+		if err := a.validate(list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 默认策略与原有行为一致：代币黑名单只跳过兑换，池黑名单不再入场、领取与获取价格
+func defaultListPolicy() ListPolicyConfig {
+	return ListPolicyConfig{
+		TokenBan: ListActions{Entry: policyNone, Claim: policyNone, Price: policyNone, Sweep: policySkip},
+		PoolBan:  ListActions{Entry: policySkip, Claim: policySkip, Price: policySkip, Sweep: policyNone},
+		Allow:    ListActions{Entry: policyNone, Claim: policyNone, Price: policyNone, Sweep: policyNone},
+	}
+}
+
+// policyDecision 策略引擎的判定结果
+type policyDecision struct {
+	Action string
+	List   string // 命中的名单（动作为 none 时为空）
+}
+
+// evaluateListPolicy 按所有命中的名单取最严重的动作（无副作用）
+func evaluateListPolicy(subsystem, poolAddress, tokenAddress string) policyDecision {
+	cfg := currentConfig().ListPolicy
+	d := policyDecision{Action: policyNone}
+	consider := func(list string, a ListActions, hit func() bool) {
+		action := a.action(subsystem)
+		if policySeverity[action] <= policySeverity[d.Action] || !hit() {
+			return
+		}
+		d = policyDecision{Action: action, List: list}
+	}
+	consider(listPoolBan, cfg.PoolBan, func() bool { return poolAddress != "" && isPoolBanned(poolAddress) })
+	consider(listTokenBan, cfg.TokenBan, func() bool { return tokenAddress != "" && isTokenBanned(tokenAddress) })
+	consider(listAllow, cfg.Allow, func() bool { return !isAllowed(poolAddress, tokenAddress) })
+	return d
+}
+
+// policyBlocks 策略是否阻止该子系统处理（用于只需过滤、不做告警或平仓的场景）
+func policyBlocks(subsystem, poolAddress, tokenAddress string) bool {
+	return policySeverity[evaluateListPolicy(subsystem, poolAddress, tokenAddress).Action] >= policySeverity[policySkip]
+}
+
+// enforceListPolicy 判定并执行动作，返回是否继续处理
+func enforceListPolicy(subsystem, poolAddress, tokenAddress string) bool {
+	return enforceListPolicyReason(subsystem, poolAddress, tokenAddress) == ""
+}
+
+// enforceListPolicyReason 同 enforceListPolicy，不继续处理时返回命中的名单（继续处理时为空）
+func enforceListPolicyReason(subsystem, poolAddress, tokenAddress string) string {
+	d := evaluateListPolicy(subsystem, poolAddress, tokenAddress)
+	if d.Action == policyNone || d.Action == "" {
+		return ""
+	}
+	metricListPolicy.Inc(d.List, subsystem, d.Action)
+	target := poolAddress
+	if target == "" {
+		target = tokenAddress
+	}
+	switch d.Action {
+	case policyAlert:
+		logWarn("⚠️ 名单策略告警", "list", d.List, "subsystem", subsystem, "pool", poolAddress, "token", tokenAddress)
+		notifyKeyed(eventListPolicy, levelWarning, d.List+":"+subsystem+":"+target,
+			"名单策略命中", fmt.Sprintf("%s 命中名单 %s（%s）", target, d.List, subsystem),
+			map[string]string{"pool": poolAddress, "token": tokenAddress, "list": d.List, "subsystem": subsystem})
+		return ""
+	case policyClose:
+		logOutput("🚫 名单策略平仓 (%s/%s): %s\n", d.List, subsystem, poolAddress)
+		if claimAndClosePosition(poolAddress, exitReasonListPolicy) {
+			notifyKeyed(eventListPolicy, levelWarning, d.List+":close:"+poolAddress,
+				"名单策略平仓", fmt.Sprintf("%s 命中名单 %s，已领取并平仓", poolAddress, d.List),
+				map[string]string{"pool": poolAddress, "token": tokenAddress, "list": d.List, "subsystem": subsystem})
+		}
+		return d.List
+	}
+	logDebug("🚫 名单策略跳过", "list", d.List, "subsystem", subsystem, "pool", poolAddress, "token", tokenAddress)
+	recordSkip(subsystem, skipBanned, poolAddress, tokenAddress, "名单 "+d.List)
+	return d.List
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SOL mint（单边添加 SOL，只考虑与 SOL 配对的池）
+const solMint = "So11111111111111111111111111111111111111112"
+
+// PoolSelectionConfig 同一代币存在多个 DLMM 池（不同 bin step/费率）时按热度与费率择优
+type PoolSelectionConfig struct {
+	Enabled         bool    `json:"enabled"`
+	APIURL          string  `json:"apiUrl"`          // Meteora DLMM 池查询接口
+	TimeoutSeconds  int     `json:"timeoutSeconds"`  // 查询超时，失败时沿用 CSV 中的池
+	MinTVL          float64 `json:"minTvl"`          // 流动性（USD）低于该值的池不参与选择
+	AllowedBinSteps []int   `json:"allowedBinSteps"` // 允许的 bin step（为空表示不限制）
+	VolumeWeight    float64 `json:"volumeWeight"`    // log10(1+24h成交量) 的权重
+	TVLWeight       float64 `json:"tvlWeight"`       // log10(1+TVL) 的权重
+	FeeWeight       float64 `json:"feeWeight"`       // 24h 手续费/TVL（%）的权重
+}
+
+// PoolCandidate 候选池
+type PoolCandidate struct {
+	Address   string  `json:"address"`
+	Name      string  `json:"name"`
+	BinStep   int     `json:"binStep"`
+	BaseFee   float64 `json:"baseFeePercentage"`
+	TVL       float64 `json:"tvl"`
+	Volume24h float64 `json:"volume24h"`
+	Fees24h   float64 `json:"fees24h"`
+	Score     float64 `json:"score"`
+}
+
+// flexFloat 兼容接口中数字与字符串两种写法
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = flexFloat(v)
+	return nil
+}
+
+// Meteora 池列表响应（仅取用到的字段）
+type meteoraPair struct {
+	Address       string    `json:"address"`
+	Name          string    `json:"name"`
+	MintX         string    `json:"mint_x"`
+	MintY         string    `json:"mint_y"`
+	BinStep       int       `json:"bin_step"`
+	BaseFee       flexFloat `json:"base_fee_percentage"`
+	Liquidity     flexFloat `json:"liquidity"`
+	Volume24h     flexFloat `json:"trade_volume_24h"`
+	Fees24h       flexFloat `json:"fees_24h"`
+	Hide          bool      `json:"hide"`
+	IsBlacklisted bool      `json:"is_blacklisted"`
+}
+
+var poolSelectHTTP = &http.Client{}
+
+func (c PoolSelectionConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.APIURL == "" {
+		return fmt.Errorf("poolSelection.apiUrl 不能为空")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("poolSelection.timeoutSeconds 必须大于0")
+	}
+	if c.MinTVL < 0 || c.VolumeWeight < 0 || c.TVLWeight < 0 || c.FeeWeight < 0 {
+		return fmt.Errorf("poolSelection 的阈值与权重不能为负数")
+	}
+	return nil
+}
+
+func (c PoolSelectionConfig) binStepAllowed(binStep int) bool {
+	if len(c.AllowedBinSteps) == 0 {
+		return true
+	}
+	for _, s := range c.AllowedBinSteps {
+		if s == binStep {
+			return true
+		}
+	}
+	return false
+}
+
+func (c PoolSelectionConfig) score(p *PoolCandidate) float64 {
+	score := c.VolumeWeight*math.Log10(1+p.Volume24h) + c.TVLWeight*math.Log10(1+p.TVL)
+	if p.TVL > 0 {
+		score += c.FeeWeight * p.Fees24h / p.TVL * 100
+	}
+	return score
+}
+
+// 查询代币与 SOL 配对的全部 DLMM 池
+func fetchPoolCandidates(tokenAddress string) ([]*PoolCandidate, error) {
+	cfg := currentConfig().PoolSelection
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("search_term", tokenAddress)
+	query.Set("limit", "50")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.APIURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := poolSelectHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var body struct {
+		Pairs []meteoraPair `json:"pairs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("解析池列表失败: %v", err)
+	}
+
+	var candidates []*PoolCandidate
+	for _, p := range body.Pairs {
+		pairedWithSOL := (p.MintX == tokenAddress && p.MintY == solMint) || (p.MintY == tokenAddress && p.MintX == solMint)
+		if !pairedWithSOL || p.Hide || p.IsBlacklisted {
+			continue
+		}
+		c := &PoolCandidate{
+			Address: p.Address, Name: p.Name, BinStep: p.BinStep, BaseFee: float64(p.BaseFee),
+			TVL: float64(p.Liquidity), Volume24h: float64(p.Volume24h), Fees24h: float64(p.Fees24h),
+		}
+		c.Score = cfg.score(c)
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// selectBestPool 返回代币评分最高的池；未启用、查询失败或没有合格候选时返回 CSV 中的池
+func selectBestPool(tokenAddress, csvPool string) string {
+	cfg := currentConfig().PoolSelection
+	if !cfg.Enabled || tokenAddress == "" {
+		return csvPool
+	}
+	candidates, err := fetchPoolCandidates(tokenAddress)
+	if err != nil {
+		logWarn("⚠️ 查询候选池失败，沿用CSV中的池", "pool", csvPool, "token", tokenAddress, "error", err)
+		return csvPool
+	}
+
+	var best *PoolCandidate
+	for _, c := range candidates {
+		if c.TVL < cfg.MinTVL || !cfg.binStepAllowed(c.BinStep) || policyBlocks(subsystemEntry, c.Address, tokenAddress) {
+			continue
+		}
+		if best == nil || c.Score > best.Score {
+			best = c
+		}
+	}
+	if best == nil {
+		logOutput("⚠️ 没有满足条件的候选池（共 %d 个），沿用CSV中的池: %s\n", len(candidates), csvPool)
+		return csvPool
+	}
+	if best.Address != csvPool {
+		logInfo("🔀 选择更优的池", "token", tokenAddress, "csvPool", csvPool, "pool", best.Address,
+			"binStep", best.BinStep, "tvl", fmt.Sprintf("%.0f", best.TVL), "volume24h", fmt.Sprintf("%.0f", best.Volume24h),
+			"score", fmt.Sprintf("%.3f", best.Score), "candidates", len(candidates))
+	}
+	return best.Address
+}
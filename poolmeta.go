@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolMetadataConfig 新池到达时从链上读取池与代币信息，写入池文件的 metadata 字段
+type PoolMetadataConfig struct {
+	Enabled        bool `json:"enabled"`
+	TimeoutSeconds int  `json:"timeoutSeconds"` // 单个池的读取超时（LbPair、两个 mint 与代币元数据账户），超时后不带 metadata 保存
+}
+
+// PoolMetadata 池的链上信息（LbPair 账户与两个代币的 mint、Metaplex 元数据）
+type PoolMetadata struct {
+	MintX          string  `json:"mintX"`
+	MintY          string  `json:"mintY"`
+	DecimalsX      int     `json:"decimalsX"`
+	DecimalsY      int     `json:"decimalsY"`
+	SymbolX        string  `json:"symbolX,omitempty"` // 没有元数据账户的代币为空
+	SymbolY        string  `json:"symbolY,omitempty"`
+	NameX          string  `json:"nameX,omitempty"`
+	NameY          string  `json:"nameY,omitempty"`
+	BinStep        int     `json:"binStep"`
+	BaseFeePercent float64 `json:"baseFeePercent"`
+	ActiveBinID    int32   `json:"activeBinId"`
+	ActivePrice    float64 `json:"activePrice"` // 活跃 bin 价格：1 个 X 值多少 Y
+	FetchedAt      string  `json:"fetchedAt"`
+}
+
+// Metaplex Token Metadata 程序与元数据账户布局
+const (
+	tokenMetadataProgram    = "metaqbxxUerdq28cDB1GB1Y2VtkyjNc7K7oA2A8zoEBR"
+	metadataNameOffset      = 65 // key(1) + updateAuthority(32) + mint(32)
+	lbPairBaseFactorOffset  = 8  // StaticParameters.baseFactor
+	lbPairBaseFeePowOffset  = 34 // StaticParameters.baseFeePowerFactor
+	maxMetadataStringLength = 200
+)
+
+// 常见代币的符号（不查询元数据账户）
+var knownMintSymbols = map[string]string{
+	solMint:  "SOL",
+	usdcMint: "USDC",
+	usdtMint: "USDT",
+}
+
+var (
+	poolMetadataMutex sync.Mutex
+	poolMetadataCache = map[string]*PoolMetadata{} // 池地址 -> 链上信息（进程内缓存，来自池文件或链上读取）
+)
+
+func (c PoolMetadataConfig) validate(watch WalletWatchConfig, pool RPCPoolConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" && len(pool.Endpoints) == 0 {
+		return fmt.Errorf("poolMetadata 需要 walletWatch.rpcUrl 或 rpcPool.endpoints")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("poolMetadata.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+// enrichPoolMetadata 新池保存前读取链上信息；未启用、演示模式或读取失败时返回 nil（池文件照常保存）
+func enrichPoolMetadata(poolAddress string) *PoolMetadata {
+	cfg := currentConfig().PoolMetadata
+	if !cfg.Enabled || isDemo() || poolAddress == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	meta, err := fetchPoolMetadata(ctx, poolAddress)
+	if err != nil {
+		logWarn("⚠️ 读取池链上信息失败", "pool", poolAddress, "error", err)
+		return nil
+	}
+	poolMetadataMutex.Lock()
+	poolMetadataCache[poolAddress] = meta
+	poolMetadataMutex.Unlock()
+	logInfo("🏷️ 池链上信息", "pool", poolAddress, "pair", meta.pairName(), "binStep", meta.BinStep, "baseFee", meta.BaseFeePercent, "activeBin", meta.ActiveBinID)
+	return meta
+}
+
+// fetchPoolMetadata 读取 LbPair 账户、两个 mint 的精度与 Metaplex 元数据
+func fetchPoolMetadata(ctx context.Context, poolAddress string) (*PoolMetadata, error) {
+	data, err := accountData(ctx, poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < lbPairMinLength {
+		return nil, fmt.Errorf("LbPair 账户数据长度不足: %d", len(data))
+	}
+	meta := &PoolMetadata{
+		MintX:       encodeBase58(data[lbPairMintXOffset:lbPairMintYOffset]),
+		MintY:       encodeBase58(data[lbPairMintYOffset:lbPairMinLength]),
+		BinStep:     int(binary.LittleEndian.Uint16(data[lbPairBinStepOffset:])),
+		ActiveBinID: int32(binary.LittleEndian.Uint32(data[lbPairActiveIDOffset:])),
+		FetchedAt:   appNow().Format(time.RFC3339),
+	}
+	// 基础费率 = baseFactor × binStep × 10^baseFeePowerFactor / 1e6（%）
+	baseFactor := binary.LittleEndian.Uint16(data[lbPairBaseFactorOffset:])
+	meta.BaseFeePercent = float64(baseFactor) * float64(meta.BinStep) * math.Pow10(int(data[lbPairBaseFeePowOffset])) / 1e6
+
+	// 两个代币的精度与符号（代币信息缓存，同一代币只读取一次链上）
+	x, err := tokenMetadata(ctx, meta.MintX)
+	if err != nil {
+		return nil, err
+	}
+	y, err := tokenMetadata(ctx, meta.MintY)
+	if err != nil {
+		return nil, err
+	}
+	meta.DecimalsX, meta.SymbolX, meta.NameX = x.Decimals, x.Symbol, x.Name
+	meta.DecimalsY, meta.SymbolY, meta.NameY = y.Decimals, y.Symbol, y.Name
+	meta.ActivePrice = math.Pow(1+float64(meta.BinStep)/10000, float64(meta.ActiveBinID)) * math.Pow10(meta.DecimalsX-meta.DecimalsY)
+	return meta, nil
+}
+
+// borshString 读取 u32 长度前缀的字符串（元数据中的字符串以 \0 补齐到固定长度）
+func borshString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if n > maxMetadataStringLength || len(b) < 4+n {
+		return "", nil, false
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b[4:4+n]), "\x00")), b[4+n:], true
+}
+
+// pairName 交易对名称，如 BONK-SOL；缺少符号的一侧用地址缩写
+func (m *PoolMetadata) pairName() string {
+	x, y := m.SymbolX, m.SymbolY
+	if x == "" {
+		x = shortAddress(m.MintX)
+	}
+	if y == "" {
+		y = shortAddress(m.MintY)
+	}
+	return x + "-" + y
+}
+
+// shortAddress 地址缩写（前 4 位…后 4 位）
+func shortAddress(addr string) string {
+	if len(addr) <= 10 {
+		return addr
+	}
+	return addr[:4] + "…" + addr[len(addr)-4:]
+}
+
+// poolMetadata 池的链上信息：优先进程内缓存，其次池文件的 metadata 字段
+func poolMetadata(poolAddress string) *PoolMetadata {
+	poolMetadataMutex.Lock()
+	meta, ok := poolMetadataCache[poolAddress]
+	poolMetadataMutex.Unlock()
+	if ok {
+		return meta
+	}
+	var obj struct {
+		Metadata *PoolMetadata `json:"metadata"`
+	}
+	if b, err := os.ReadFile(filepath.Join(poolDataDir(), poolAddress+".json")); err == nil {
+		json.Unmarshal(b, &obj)
+	}
+	if obj.Metadata != nil {
+		poolMetadataMutex.Lock()
+		poolMetadataCache[poolAddress] = obj.Metadata
+		poolMetadataMutex.Unlock()
+	}
+	return obj.Metadata
+}
+
+// poolLabel 日志与告警中的池名称：交易对名称（地址缩写），没有链上信息时为池地址
+func poolLabel(poolAddress string) string {
+	if meta := poolMetadata(poolAddress); meta != nil {
+		return fmt.Sprintf("%s (%s)", meta.pairName(), shortAddress(poolAddress))
+	}
+	return poolAddress
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("无效的 base58 字符: %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	out := n.Bytes()
+	for _, c := range s {
+		if c != '1' {
+			break
+		}
+		out = append([]byte{0}, out...)
+	}
+	return out, nil
+}
+
+// findProgramAddress 计算 PDA：从 bump 255 开始，取第一个不在 ed25519 曲线上的哈希
+func findProgramAddress(seeds [][]byte, program []byte) ([]byte, error) {
+	for bump := 255; bump >= 0; bump-- {
+		h := sha256.New()
+		for _, s := range seeds {
+			h.Write(s)
+		}
+		h.Write([]byte{byte(bump)})
+		h.Write(program)
+		h.Write([]byte("ProgramDerivedAddress"))
+		sum := h.Sum(nil)
+		if !onEd25519Curve(sum) {
+			return sum, nil
+		}
+	}
+	return nil, fmt.Errorf("找不到有效的 PDA")
+}
+
+var (
+	ed25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	ed25519D = func() *big.Int {
+		// d = -121665 / 121666 mod p
+		d := new(big.Int).ModInverse(big.NewInt(121666), ed25519P)
+		d.Mul(d, big.NewInt(-121665))
+		return d.Mod(d, ed25519P)
+	}()
+)
+
+// onEd25519Curve 判断 32 字节压缩点能否解压：x² = (y² - 1) / (d·y² + 1) 在模 p 下有平方根
+func onEd25519Curve(b []byte) bool {
+	le := make([]byte, 32)
+	for i := 0; i < 32; i++ {
+		le[31-i] = b[i]
+	}
+	le[0] &= 0x7f
+	p := ed25519P
+	y := new(big.Int).SetBytes(le)
+	y.Mod(y, p)
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	u := new(big.Int).Sub(y2, big.NewInt(1))
+	u.Mod(u, p)
+	v := new(big.Int).Mul(ed25519D, y2)
+	v.Add(v, big.NewInt(1))
+	v.Mod(v, p)
+	if v.Sign() == 0 {
+		return u.Sign() == 0
+	}
+	if u.Sign() == 0 {
+		return true
+	}
+	x2 := new(big.Int).ModInverse(v, p)
+	x2.Mul(x2, u)
+	x2.Mod(x2, p)
+	// 欧拉判别：x2^((p-1)/2) == 1 时为二次剩余
+	e := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+	return new(big.Int).Exp(x2, e, p).Cmp(big.NewInt(1)) == 0
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PoolOverridesConfig 按池覆盖配置：<dir>/<pool>.json 中设置的字段覆盖全局配置，未设置的沿用全局值；目录中的文件变化时实时生效
+type PoolOverridesConfig struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir"` // 覆盖文件目录（相对路径按程序目录解析）
+}
+
+// PoolOverride 单个池的覆盖（字段缺省表示沿用全局配置）
+type PoolOverride struct {
+	SolAmount       *float64         `json:"solAmount,omitempty"`       // 开仓金额（SOL），替代参数档位与 portfolio.baseSOL；阶梯仓位不受影响
+	Liquidity       *LiquidityParams `json:"liquidity,omitempty"`       // 流动性分布策略，优先于 liquidity.pools 与信号字段
+	MinPendingUSD   *float64         `json:"minPendingUSD,omitempty"`   // 领取门槛，替代 claimPolicy.minPendingUSD
+	StopLossPercent *float64         `json:"stopLossPercent,omitempty"` // 止损回撤（%），<=0 表示该池不止损；需启用 risk.stopLoss
+	SwapExclude     bool             `json:"swapExclude,omitempty"`     // 定时兑换时保留该池的代币
+	NotifyBackends  []string         `json:"notifyBackends,omitempty"`  // 带该池地址的告警只发送到这些后端（路由的 disabled 与限流仍然生效）
+}
+
+// 兑换跳过原因：池配置覆盖要求保留该池的代币
+const swapSkipPoolOverride = "pool_override"
+
+var (
+	poolOverridesMutex   sync.Mutex
+	poolOverridesCache   map[string]*PoolOverride
+	poolOverrideErrors   map[string]string // 文件名 -> 无法解析或无效的原因
+	poolOverridesStale   atomic.Bool
+	poolOverridesWatched atomic.Bool // 监听未启动时每次读取都重新加载
+)
+
+func (c PoolOverridesConfig) validate() error {
+	if c.Enabled && c.Dir == "" {
+		return fmt.Errorf("poolOverrides.dir 不能为空")
+	}
+	return nil
+}
+
+func (o *PoolOverride) validate() error {
+	if o.SolAmount != nil && *o.SolAmount <= 0 {
+		return fmt.Errorf("solAmount 必须大于0")
+	}
+	if o.Liquidity != nil {
+		if err := o.Liquidity.validate(); err != nil {
+			return fmt.Errorf("liquidity: %v", err)
+		}
+	}
+	if o.MinPendingUSD != nil && *o.MinPendingUSD < 0 {
+		return fmt.Errorf("minPendingUSD 不能为负数")
+	}
+	if o.StopLossPercent != nil && *o.StopLossPercent >= 100 {
+		return fmt.Errorf("stopLossPercent 必须小于 100")
+	}
+	for _, name := range o.NotifyBackends {
+		if !notifyBackendConfigured(name) {
+			return fmt.Errorf("notifyBackends 中的后端未配置: %s", name)
+		}
+	}
+	return nil
+}
+
+func notifyBackendConfigured(name string) bool {
+	for _, b := range currentConfig().Notify.Backends {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPoolOverrideFiles 读取目录中的全部覆盖文件；无效的文件记录原因后忽略
+func loadPoolOverrideFiles() (map[string]*PoolOverride, map[string]string) {
+	overrides := map[string]*PoolOverride{}
+	errs := map[string]string{}
+	entries, err := os.ReadDir(currentConfig().PoolOverrides.Dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("⚠️ 读取池配置覆盖目录失败", "dir", currentConfig().PoolOverrides.Dir, "error", err)
+		}
+		return overrides, errs
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		pool := strings.TrimSuffix(name, ".json")
+		content, err := os.ReadFile(filepath.Join(currentConfig().PoolOverrides.Dir, name))
+		if err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		var o PoolOverride
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			errs[name] = "解析失败: " + err.Error()
+			continue
+		}
+		if err := validateAddress(pool); err != nil {
+			errs[name] = "文件名不是池地址: " + err.Error()
+			continue
+		}
+		if err := o.validate(); err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		overrides[pool] = &o
+	}
+	return overrides, errs
+}
+
+// 返回最新的覆盖（调用方需持有 poolOverridesMutex）
+func currentPoolOverridesLocked() map[string]*PoolOverride {
+	if poolOverridesCache == nil || poolOverridesStale.Swap(false) || !poolOverridesWatched.Load() {
+		cur, errs := loadPoolOverrideFiles()
+		for name, reason := range errs {
+			if poolOverrideErrors[name] != reason {
+				logWarn("⚠️ 池配置覆盖文件无效，已忽略", "file", name, "error", reason)
+			}
+		}
+		if poolOverridesCache != nil && poolOverridesChanged(poolOverridesCache, cur) {
+			logOutput("🎛️ 池配置覆盖已更新: 共 %d 个池\n", len(cur))
+		}
+		poolOverridesCache, poolOverrideErrors = cur, errs
+	}
+	return poolOverridesCache
+}
+
+func poolOverridesChanged(old, cur map[string]*PoolOverride) bool {
+	a, _ := json.Marshal(old)
+	b, _ := json.Marshal(cur)
+	return string(a) != string(b)
+}
+
+// poolOverride 池的覆盖，没有时为 nil
+func poolOverride(poolAddress string) *PoolOverride {
+	if !currentConfig().PoolOverrides.Enabled || poolAddress == "" {
+		return nil
+	}
+	poolOverridesMutex.Lock()
+	defer poolOverridesMutex.Unlock()
+	return currentPoolOverridesLocked()[poolAddress]
+}
+
+// poolOverrideSolAmount 池覆盖的开仓金额
+func poolOverrideSolAmount(poolAddress string) (float64, bool) {
+	if o := poolOverride(poolAddress); o != nil && o.SolAmount != nil {
+		return *o.SolAmount, true
+	}
+	return 0, false
+}
+
+// poolMinPendingUSD 池的领取门槛（覆盖或 claimPolicy.minPendingUSD）
+func poolMinPendingUSD(poolAddress string) float64 {
+	if o := poolOverride(poolAddress); o != nil && o.MinPendingUSD != nil {
+		return *o.MinPendingUSD
+	}
+	return currentConfig().ClaimPolicy.MinPendingUSD
+}
+
+// poolNotifyBackends 带池地址的告警覆盖的后端，没有覆盖时为 nil
+func poolNotifyBackends(poolAddress string) []string {
+	if o := poolOverride(poolAddress); o != nil {
+		return o.NotifyBackends
+	}
+	return nil
+}
+
+// poolOverrideSwapFilter 代币属于 swapExclude 的池时返回跳过原因
+func poolOverrideSwapFilter(tokenAddress string) string {
+	if !currentConfig().PoolOverrides.Enabled {
+		return ""
+	}
+	poolOverridesMutex.Lock()
+	var excluded []string
+	for pool, o := range currentPoolOverridesLocked() {
+		if o.SwapExclude {
+			excluded = append(excluded, pool)
+		}
+	}
+	poolOverridesMutex.Unlock()
+	for _, pool := range excluded {
+		if readTokenContractAddressFromPoolJSON(pool) == tokenAddress {
+			recordSkip(subsystemSweep, skipFiltered, pool, tokenAddress, "池配置覆盖要求保留代币")
+			return swapSkipPoolOverride
+		}
+	}
+	return ""
+}
+
+// PoolOverrideStatus 池配置覆盖的当前状态（GET /overrides）
+type PoolOverrideStatus struct {
+	Enabled   bool                     `json:"enabled"`
+	Dir       string                   `json:"dir"`
+	Overrides map[string]*PoolOverride `json:"overrides"`
+	Invalid   map[string]string        `json:"invalid,omitempty"` // 文件名 -> 原因
+}
+
+func poolOverrideStatus() PoolOverrideStatus {
+	s := PoolOverrideStatus{Enabled: currentConfig().PoolOverrides.Enabled, Dir: currentConfig().PoolOverrides.Dir, Overrides: map[string]*PoolOverride{}}
+	if !s.Enabled {
+		return s
+	}
+	poolOverridesMutex.Lock()
+	defer poolOverridesMutex.Unlock()
+	s.Overrides = currentPoolOverridesLocked()
+	s.Invalid = poolOverrideErrors
+	return s
+}
+
+// startPoolOverrideWatcher 监听覆盖目录，文件写入、重建或删除时重新加载
+func startPoolOverrideWatcher() {
+	cfg := currentConfig().PoolOverrides
+	if !cfg.Enabled {
+		return
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		logWarn("⚠️ 创建池配置覆盖目录失败，覆盖将每次重新读取", "dir", cfg.Dir, "error", err)
+		return
+	}
+	watcher, err := newFileWatcher("poolOverrides")
+	if err != nil {
+		logWarn("⚠️ 创建池配置覆盖监听失败，覆盖将每次重新读取", "error", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(cfg.Dir); err != nil {
+		logWarn("⚠️ 添加池配置覆盖监听失败，覆盖将每次重新读取", "dir", cfg.Dir, "error", err)
+		return
+	}
+	poolOverridesStale.Store(true)
+	poolOverridesWatched.Store(true)
+	defer poolOverridesWatched.Store(false)
+	logOutput("🎛️ 池配置覆盖: %s（%d 个池）\n", cfg.Dir, len(poolOverrideStatus().Overrides))
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			poolOverridesStale.Store(true)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			poolOverridesStale.Store(true)
+			logError("❌ 池配置覆盖监听错误", "error", err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 池的处理状态（与按价格划分的仓位生命周期状态 positionState* 相互独立）
+const (
+	poolSignaled = "SIGNALED" // 信号已写出池文件，等待开仓
+	poolAdding   = "ADDING"   // 正在执行 addLiquidity
+	poolActive   = "ACTIVE"   // 持有仓位
+	poolClaiming = "CLAIMING" // 正在领取
+	poolExiting  = "EXITING"  // 正在平仓
+	poolClosed   = "CLOSED"   // 已平仓或未开仓即结束
+	poolFailed   = "FAILED"   // 开仓或平仓失败，等待人工处理或下一次信号
+)
+
+var poolStates = []string{poolSignaled, poolAdding, poolActive, poolClaiming, poolExiting, poolClosed, poolFailed}
+
+// 允许的状态转换；没有记录的池（升级前已存在）可以进入任意状态
+var poolTransitions = map[string][]string{
+	poolSignaled: {poolAdding, poolActive, poolClosed, poolFailed},
+	poolAdding:   {poolActive, poolFailed},
+	poolActive:   {poolAdding, poolClaiming, poolExiting, poolClosed},
+	poolClaiming: {poolActive, poolExiting, poolClosed, poolFailed},
+	poolExiting:  {poolActive, poolClosed, poolFailed},
+	poolClosed:   {poolSignaled, poolAdding, poolActive},
+	poolFailed:   {poolSignaled, poolAdding, poolActive, poolClaiming, poolExiting, poolClosed},
+}
+
+// 每个池保留的状态变更记录数
+const maxPoolStateHistory = 20
+
+// PoolStateConfig 池状态机的停留时限：超过时限的池告警一次（状态本身不变）
+type PoolStateConfig struct {
+	StuckMinutes         map[string]int `json:"stuckMinutes"`         // 状态 -> 停留上限（分钟），未列出或为 0 的状态不检查
+	CheckIntervalSeconds int            `json:"checkIntervalSeconds"` // 检查间隔
+}
+
+// PoolStateRecord 单个池的当前状态与最近的变更（data/state/pool_states.json: pool -> 记录）
+type PoolStateRecord struct {
+	Pool    string               `json:"poolAddress"`
+	State   string               `json:"state"`
+	Since   string               `json:"since"`
+	Note    string               `json:"note,omitempty"`
+	Stuck   bool                 `json:"stuck,omitempty"` // 已超过 stuckMinutes 并告警
+	History []PositionTransition `json:"history"`
+}
+
+var poolStateMutex sync.Mutex
+
+func (c PoolStateConfig) validate() error {
+	for state, minutes := range c.StuckMinutes {
+		if _, ok := poolTransitions[state]; !ok {
+			return fmt.Errorf("poolState.stuckMinutes 不支持的状态: %s（可选 %s）", state, strings.Join(poolStates, "、"))
+		}
+		if minutes < 0 {
+			return fmt.Errorf("poolState.stuckMinutes.%s 不能为负数", state)
+		}
+	}
+	if c.CheckIntervalSeconds <= 0 {
+		return fmt.Errorf("poolState.checkIntervalSeconds 必须大于0")
+	}
+	return nil
+}
+
+func loadPoolStates() map[string]*PoolStateRecord {
+	records := map[string]*PoolStateRecord{}
+	if err := loadStateFile("pool_states", &records); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return records
+}
+
+// poolStateOf 池的当前状态（没有记录时返回 false）
+func poolStateOf(poolAddress string) (string, bool) {
+	poolStateMutex.Lock()
+	defer poolStateMutex.Unlock()
+	r := loadPoolStates()[poolAddress]
+	if r == nil {
+		return "", false
+	}
+	return r.State, true
+}
+
+// transitionPool 把池转换到 to；当前状态不允许转换到 to 时不修改并返回错误
+func transitionPool(poolAddress, to, note string) error {
+	return transitionPoolFrom(poolAddress, "", to, note)
+}
+
+// transitionPoolFrom 仅当池处于 from 时转换（from 为空表示不限），用于结束 CLAIMING / EXITING 等临时状态
+func transitionPoolFrom(poolAddress, from, to, note string) error {
+	if poolAddress == "" {
+		return nil
+	}
+	poolStateMutex.Lock()
+	records := loadPoolStates()
+	r := records[poolAddress]
+	cur := ""
+	if r != nil {
+		cur = r.State
+	}
+	if from != "" && cur != from {
+		poolStateMutex.Unlock()
+		return nil
+	}
+	if cur == to {
+		poolStateMutex.Unlock()
+		return nil
+	}
+	if cur != "" && !slices.Contains(poolTransitions[cur], to) {
+		poolStateMutex.Unlock()
+		logWarn("⚠️ 池状态转换不允许", "pool", poolAddress, "from", cur, "to", to, "note", note)
+		return fmt.Errorf("池状态 %s 不能转换为 %s", cur, to)
+	}
+	now := appNow().Format(time.RFC3339)
+	if r == nil {
+		r = &PoolStateRecord{Pool: poolAddress}
+		records[poolAddress] = r
+	}
+	r.State, r.Since, r.Note, r.Stuck = to, now, note, false
+	r.History = append(r.History, PositionTransition{State: to, At: now, Note: note})
+	if len(r.History) > maxPoolStateHistory {
+		r.History = r.History[len(r.History)-maxPoolStateHistory:]
+	}
+	err := saveStateFile("pool_states", records)
+	poolStateMutex.Unlock()
+	if err != nil {
+		logOutput("❌ 保存池状态失败: %v\n", err)
+	}
+	logDebug("🔀 池状态", "pool", poolAddress, "from", cur, "to", to, "note", note)
+	return nil
+}
+
+// poolStateOpen 池是否持有或正在建立仓位；没有记录或处于 FAILED（链上结果不确定）时返回 known=false，由调用方回退到池文件判断
+func poolStateOpen(poolAddress string) (open, known bool) {
+	state, ok := poolStateOf(poolAddress)
+	if !ok || state == poolFailed {
+		return false, false
+	}
+	switch state {
+	case poolAdding, poolActive, poolClaiming, poolExiting:
+		return true, true
+	}
+	return false, true
+}
+
+// beginAdding 开仓或追加前进入 ADDING；同一池的开仓、领取或平仓正在进行时拒绝
+func beginAdding(poolAddress, ca string) bool {
+	if err := transitionPool(poolAddress, poolAdding, ""); err != nil {
+		recordSkip(subsystemEntry, skipInProgress, poolAddress, ca, err.Error())
+		return false
+	}
+	return true
+}
+
+// notePoolOutcome 池文件处理结束：开仓成功进入 ACTIVE，失败进入 FAILED，未开仓即结束的信号进入 CLOSED
+func notePoolOutcome(poolAddress, outcome string) {
+	switch outcome {
+	case outcomeSuccess, outcomePaper:
+		transitionPoolFrom(poolAddress, poolAdding, poolActive, outcome)
+	case outcomeFailed:
+		transitionPoolFrom(poolAddress, poolAdding, poolFailed, "addLiquidity 失败")
+	case outcomeDuplicate, outcomeExposureQueued, outcomeEntryWaiting:
+	default:
+		transitionPoolFrom(poolAddress, poolSignaled, poolClosed, "未开仓: "+outcome)
+	}
+}
+
+// listPoolStates 全部池的状态（按进入当前状态的时间倒序），state 非空时只返回该状态
+func listPoolStates(state string) []*PoolStateRecord {
+	poolStateMutex.Lock()
+	records := loadPoolStates()
+	poolStateMutex.Unlock()
+	result := []*PoolStateRecord{}
+	for _, r := range records {
+		if state == "" || r.State == state {
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Since > result[b].Since })
+	return result
+}
+
+// 各状态的池数（meteora_pool_states）
+func poolStateSamples() []gaugeSample {
+	counts := map[string]int{}
+	for _, r := range listPoolStates("") {
+		counts[r.State]++
+	}
+	samples := make([]gaugeSample, 0, len(poolStates))
+	for _, s := range poolStates {
+		samples = append(samples, gaugeSample{LabelValues: []string{s}, Value: float64(counts[s])})
+	}
+	return samples
+}
+
+// startPoolStateMonitor 定期检查停留超过时限的池
+func startPoolStateMonitor() {
+	if isDemo() {
+		return
+	}
+	interval := time.Duration(currentConfig().PoolState.CheckIntervalSeconds) * time.Second
+	logOutput("🔀 启动池状态停留检查（每%v）\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			checkStuckPools()
+		}
+	}
+}
+
+// checkStuckPools 停留超过 stuckMinutes 的池标记 stuck 并告警（每次进入该状态只告警一次）
+func checkStuckPools() {
+	limits := currentConfig().PoolState.StuckMinutes
+	now := appNow()
+	var stuck []*PoolStateRecord
+	poolStateMutex.Lock()
+	records := loadPoolStates()
+	for _, r := range records {
+		limit := limits[r.State]
+		if limit <= 0 || r.Stuck {
+			continue
+		}
+		since, err := time.Parse(time.RFC3339, r.Since)
+		if err != nil || now.Sub(since) < time.Duration(limit)*time.Minute {
+			continue
+		}
+		r.Stuck = true
+		stuck = append(stuck, r)
+	}
+	if len(stuck) > 0 {
+		if err := saveStateFile("pool_states", records); err != nil {
+			logOutput("❌ 保存池状态失败: %v\n", err)
+		}
+	}
+	poolStateMutex.Unlock()
+
+	for _, r := range stuck {
+		since, _ := time.Parse(time.RFC3339, r.Since)
+		age := now.Sub(since).Round(time.Minute)
+		logWarn("⏳ 池停留在同一状态过久", "pool", r.Pool, "state", r.State, "since", r.Since, "age", age)
+		notifyKeyed(eventPoolStuck, levelWarning, r.Pool+"|"+r.State, "池状态停留过久",
+			fmt.Sprintf("%s 处于 %s 已 %v", poolLabel(r.Pool), r.State, age),
+			map[string]string{"pool": r.Pool, "state": r.State, "since": r.Since, "note": r.Note})
+	}
+}
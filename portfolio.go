@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 敞口上限名（记录在跳过说明与 meteora_portfolio_limited_total 的 limit 标签中）
+const (
+	limitTotalSOL  = "total_sol"
+	limitPoolSOL   = "pool_sol"
+	limitPositions = "positions"
+	limitTokenSOL  = "token_sol"
+)
+
+// 开仓金额的分配方式
+const (
+	allocationFixed = "fixed" // 档位（或 baseSOL）的固定金额，按剩余额度截断
+	allocationEqual = "equal" // 剩余总额度平均分给剩余的持仓名额，不超过固定金额
+)
+
+// 超出上限时的处理
+const (
+	onLimitQueue  = "queue"  // 池文件等待额度释放后重新处理
+	onLimitReject = "reject" // 直接放弃
+)
+
+// 池文件因额度不足等待重新处理的结果；等待超时记为 exposure_limit
+const (
+	outcomeExposureQueued = "exposure_queued"
+	outcomeExposureLimit  = "exposure_limit"
+)
+
+// PortfolioConfig 全局敞口上限与开仓金额分配：开仓前按未平仓池的投入（盈亏台账）与处理中的开仓计算剩余额度。
+// 持仓池数与单代币投入的上限使用 admission.maxOpenPositions / maxTokenExposureSOL，不单独配置
+type PortfolioConfig struct {
+	Enabled      bool    `json:"enabled"`
+	MaxTotalSOL  float64 `json:"maxTotalSOL"`  // 所有未平仓池投入的 SOL 上限，0 表示不限制
+	MaxPoolSOL   float64 `json:"maxPoolSOL"`   // 单个池投入的 SOL 上限（含追加流动性），0 表示不限制
+	Allocation   string  `json:"allocation"`   // fixed | equal
+	BaseSOL      float64 `json:"baseSOL"`      // 固定金额，0 表示使用参数档位的 solAmount
+	MinSOL       float64 `json:"minSOL"`       // 按剩余额度截断后低于该金额时不开仓
+	OnLimit      string  `json:"onLimit"`      // queue | reject
+	QueueMinutes int     `json:"queueMinutes"` // 等待额度的最长时间，超过后放弃
+	RetrySeconds int     `json:"retrySeconds"` // 检查等待中的池文件的间隔
+
+	// 已移除的旧配置项：设置时校验报错，避免上限被静默忽略
+	RemovedMaxPositions int     `json:"maxPositions,omitempty"`
+	RemovedMaxTokenSOL  float64 `json:"maxTokenSOL,omitempty"`
+}
+
+// PortfolioWaiting 等待额度的池文件（data/state/portfolio_waiting.json: 路径 -> 记录）
+type PortfolioWaiting struct {
+	Path   string `json:"path"`
+	Pool   string `json:"poolAddress"`
+	Token  string `json:"ca,omitempty"`
+	Since  string `json:"since"`
+	Limit  string `json:"limit"`
+	Detail string `json:"detail"`
+}
+
+// PortfolioStatus 当前敞口与剩余额度（GET /portfolio）
+type PortfolioStatus struct {
+	Enabled      bool               `json:"enabled"`
+	DeployedSOL  float64            `json:"deployedSOL"` // 未平仓池的投入
+	ReservedSOL  float64            `json:"reservedSOL"` // 处理中的开仓预留
+	Positions    int                `json:"positions"`
+	MaxTotalSOL  float64            `json:"maxTotalSOL"`
+	MaxPositions int                `json:"maxPositions"`
+	NextSOL      float64            `json:"nextSOL"` // 按当前额度下一个新池的开仓金额（0 表示不能开仓）
+	Tokens       map[string]float64 `json:"tokens"`  // 代币 -> 未平仓投入
+	Waiting      []PortfolioWaiting `json:"waiting"`
+}
+
+// 处理中的开仓预留（池 -> SOL），开仓结束后释放，此时投入已记入盈亏台账
+type portfolioReservation struct {
+	token  string
+	amount float64
+	newPos bool
+}
+
+var (
+	portfolioMutex       sync.Mutex
+	portfolioReserved    = map[string]portfolioReservation{}
+	portfolioAllocations = map[string]float64{} // 最近一次为池分配的金额（模拟池与脚本未写回金额时作为投入记账）
+)
+
+// 持仓池数与单代币上限取自准入规则的同名配置
+func (c PortfolioConfig) validate(admission AdmissionConfig) error {
+	if c.RemovedMaxPositions != 0 {
+		return fmt.Errorf("portfolio.maxPositions 已移除，请改用 admission.maxOpenPositions")
+	}
+	if c.RemovedMaxTokenSOL != 0 {
+		return fmt.Errorf("portfolio.maxTokenSOL 已移除，请改用 admission.maxTokenExposureSOL")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxTotalSOL < 0 || c.MaxPoolSOL < 0 || c.BaseSOL < 0 || c.MinSOL < 0 {
+		return fmt.Errorf("portfolio 的上限与金额不能为负数")
+	}
+	if admission.MaxOpenPositions < 0 || admission.MaxTokenExposureSOL < 0 {
+		return fmt.Errorf("admission.maxOpenPositions 与 admission.maxTokenExposureSOL 不能为负数")
+	}
+	switch c.Allocation {
+	case allocationFixed:
+	case allocationEqual:
+		if c.MaxTotalSOL <= 0 || admission.MaxOpenPositions <= 0 {
+			return fmt.Errorf("portfolio.allocation 为 equal 时需要设置 portfolio.maxTotalSOL 与 admission.maxOpenPositions")
+		}
+	default:
+		return fmt.Errorf("portfolio.allocation 只能是 fixed 或 equal")
+	}
+	switch c.OnLimit {
+	case onLimitReject:
+	case onLimitQueue:
+		if c.QueueMinutes <= 0 || c.RetrySeconds <= 0 {
+			return fmt.Errorf("portfolio.onLimit 为 queue 时 queueMinutes 与 retrySeconds 必须大于0")
+		}
+	default:
+		return fmt.Errorf("portfolio.onLimit 只能是 queue 或 reject")
+	}
+	return nil
+}
+
+// portfolioExposure 未平仓池的投入：合计、按池与按代币（盈亏台账，含模拟池）
+func portfolioExposure() (total float64, pools map[string]float64, tokens map[string]float64) {
+	pnlMutex.Lock()
+	ledger := loadPnLLedger()
+	pnlMutex.Unlock()
+	pools, tokens = map[string]float64{}, map[string]float64{}
+	for pool, p := range ledger {
+		if p.ClosedAt != "" {
+			continue
+		}
+		total += p.CostSOL
+		pools[pool] = p.CostSOL
+		if p.TokenAddress != "" {
+			tokens[p.TokenAddress] += p.CostSOL
+		}
+	}
+	return total, pools, tokens
+}
+
+// portfolioBaseSOL 不受额度限制时的开仓金额：阶梯仓位为各档位之和，否则为 baseSOL 或池参数档位的 solAmount
+func portfolioBaseSOL(poolAddress string) float64 {
+	if ladderEnabled() {
+		return plannedDepositSOL()
+	}
+	if amount, ok := poolOverrideSolAmount(poolAddress); ok {
+		return amount
+	}
+	if currentConfig().Portfolio.BaseSOL > 0 {
+		return currentConfig().Portfolio.BaseSOL
+	}
+	_, p := poolProfile(poolAddress)
+	return p.SolAmount
+}
+
+// 调用方需持有 portfolioMutex；返回开仓金额与是否新增持仓，额度不足时返回触发的上限与说明
+func portfolioAllocateLocked(poolAddress, token string) (amount float64, newPos bool, limit, detail string) {
+	conf := currentConfig()
+	cfg := conf.Portfolio
+	maxPositions, maxTokenSOL := conf.Admission.MaxOpenPositions, conf.Admission.MaxTokenExposureSOL
+	deployed, pools, tokens := portfolioExposure()
+	_, open := pools[poolAddress]
+	newPos = !open
+	positions := len(pools)
+	for pool, r := range portfolioReserved {
+		deployed += r.amount
+		if r.token != "" {
+			tokens[r.token] += r.amount
+		}
+		if r.newPos {
+			positions++
+		}
+		pools[pool] += r.amount
+	}
+
+	if newPos && maxPositions > 0 && positions >= maxPositions {
+		return 0, newPos, limitPositions, fmt.Sprintf("持仓池数 %d 已达上限 %d", positions, maxPositions)
+	}
+
+	base := portfolioBaseSOL(poolAddress)
+	amount = base
+	if cfg.Allocation == allocationEqual && newPos {
+		share := (cfg.MaxTotalSOL - deployed) / float64(maxPositions-positions)
+		if base <= 0 || share < base {
+			amount = share
+		}
+	}
+
+	// 按各上限的剩余额度截断，记录最紧的一项
+	clip := func(max, used float64, name, label string) {
+		if max <= 0 {
+			return
+		}
+		if room := max - used; room < amount {
+			amount = room
+			limit, detail = name, fmt.Sprintf("%s已投入 %.4f SOL，上限 %.4f SOL", label, used, max)
+		}
+	}
+	clip(cfg.MaxTotalSOL, deployed, limitTotalSOL, "全部池")
+	clip(cfg.MaxPoolSOL, pools[poolAddress], limitPoolSOL, "该池")
+	if token != "" {
+		clip(maxTokenSOL, tokens[token], limitTokenSOL, "该代币")
+	}
+
+	// 阶梯仓位的档位金额不能缩小，额度不足即不开仓
+	if amount <= 0 || amount < cfg.MinSOL || (ladderEnabled() && amount < base) {
+		if limit == "" {
+			limit, detail = limitTotalSOL, fmt.Sprintf("可分配金额 %.4f SOL 低于下限 %.4f SOL", amount, cfg.MinSOL)
+		}
+		return 0, newPos, limit, detail
+	}
+	return amount, newPos, "", ""
+}
+
+// reservePortfolio 开仓前计算金额并预留额度；未启用时返回 (0, true)。额度不足时记录跳过并按 onLimit 返回结果
+func reservePortfolio(path, poolAddress, token string) (amount float64, outcome string, ok bool) {
+	cfg := currentConfig().Portfolio
+	if !cfg.Enabled {
+		return 0, "", true
+	}
+	portfolioMutex.Lock()
+	amount, newPos, limit, detail := portfolioAllocateLocked(poolAddress, token)
+	if limit == "" {
+		portfolioReserved[poolAddress] = portfolioReservation{token: token, amount: amount, newPos: newPos}
+		portfolioAllocations[poolAddress] = amount
+	}
+	portfolioMutex.Unlock()
+
+	if limit == "" {
+		logOutput("💼 开仓金额 %s SOL: %s\n", formatFloat(amount), poolLabel(poolAddress))
+		return amount, "", true
+	}
+	metricPortfolioLimited.Inc(limit)
+	if cfg.OnLimit == onLimitQueue && path != "" {
+		if queuePortfolioWaiting(path, poolAddress, token, limit, detail) {
+			logOutput("💼 超出敞口上限 %s，池文件等待额度: %s（%s）\n", limit, poolLabel(poolAddress), detail)
+			return 0, outcomeExposureQueued, false
+		}
+		detail += "，等待超过 " + strconv.Itoa(cfg.QueueMinutes) + " 分钟"
+	}
+	logOutput("💼 超出敞口上限 %s，跳过开仓: %s（%s）\n", limit, poolLabel(poolAddress), detail)
+	recordSkip(subsystemEntry, skipQuota, poolAddress, token, limit+": "+detail)
+	return 0, outcomeExposureLimit, false
+}
+
+// releasePortfolio 开仓结束后释放预留（成功时投入已记入盈亏台账）
+func releasePortfolio(poolAddress string) {
+	portfolioMutex.Lock()
+	delete(portfolioReserved, poolAddress)
+	portfolioMutex.Unlock()
+}
+
+// portfolioAllocation 最近一次为池分配的开仓金额（未启用或未分配时为 0）
+func portfolioAllocation(poolAddress string) float64 {
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+	return portfolioAllocations[poolAddress]
+}
+
+// withSolAmount 把 addLiquidity.ts 参数中的 --sol-amount 替换为分配的金额
+func withSolAmount(args []string, amount float64) []string {
+	out := make([]string, 0, len(args)+1)
+	for _, a := range args {
+		if !strings.HasPrefix(a, "--sol-amount=") {
+			out = append(out, a)
+		}
+	}
+	return append(out, fmt.Sprintf("--sol-amount=%s", strconv.FormatFloat(amount, 'f', -1, 64)))
+}
+
+func loadPortfolioWaiting() map[string]*PortfolioWaiting {
+	waiting := map[string]*PortfolioWaiting{}
+	if err := loadStateFile("portfolio_waiting", &waiting); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return waiting
+}
+
+// queuePortfolioWaiting 记录等待额度的池文件（保留首次等待的时间），等待超过 queueMinutes 时返回 false 并移除
+func queuePortfolioWaiting(path, poolAddress, token, limit, detail string) bool {
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+	waiting := loadPortfolioWaiting()
+	w := waiting[path]
+	if w == nil {
+		w = &PortfolioWaiting{Path: path, Pool: poolAddress, Token: token, Since: appNow().Format(time.RFC3339)}
+		waiting[path] = w
+	}
+	w.Limit, w.Detail = limit, detail
+	queued := true
+	if since, err := time.Parse(time.RFC3339, w.Since); err == nil && appNow().Sub(since) > time.Duration(currentConfig().Portfolio.QueueMinutes)*time.Minute {
+		delete(waiting, path)
+		queued = false
+	}
+	if err := saveStateFile("portfolio_waiting", waiting); err != nil {
+		logOutput("❌ 保存等待额度的池文件失败: %v\n", err)
+	}
+	return queued
+}
+
+func listPortfolioWaiting() []PortfolioWaiting {
+	portfolioMutex.Lock()
+	waiting := loadPortfolioWaiting()
+	portfolioMutex.Unlock()
+	result := []PortfolioWaiting{}
+	for _, w := range waiting {
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Since < result[b].Since })
+	return result
+}
+
+// startPortfolioRetry 定期把等待额度的池文件重新加入开仓队列
+func startPortfolioRetry() {
+	cfg := currentConfig().Portfolio
+	if !cfg.Enabled || cfg.OnLimit != onLimitQueue {
+		return
+	}
+	interval := time.Duration(cfg.RetrySeconds) * time.Second
+	logOutput("💼 启动敞口额度等待检查（每%v）\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			retryPortfolioWaiting()
+		}
+	}
+}
+
+// retryPortfolioWaiting 已不在等待的记录移除；有额度时把最早等待的池文件重新加入队列（每次一个，结果由开仓流程重新判断）
+func retryPortfolioWaiting() {
+	if isPaused() {
+		return
+	}
+	for _, w := range listPortfolioWaiting() {
+		if processedOutcome(w.Path) != outcomeExposureQueued {
+			removePortfolioWaiting(w.Path)
+			continue
+		}
+		portfolioMutex.Lock()
+		_, _, limit, _ := portfolioAllocateLocked(w.Pool, w.Token)
+		portfolioMutex.Unlock()
+		if limit != "" {
+			continue
+		}
+		if requeueProcessed(w.Path, outcomeExposureQueued) {
+			logOutput("💼 敞口额度已释放，重新处理池文件: %s\n", poolLabel(w.Pool))
+			enqueuePoolFile(w.Path)
+		}
+		return
+	}
+}
+
+func removePortfolioWaiting(path string) {
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+	waiting := loadPortfolioWaiting()
+	if _, ok := waiting[path]; !ok {
+		return
+	}
+	delete(waiting, path)
+	if err := saveStateFile("portfolio_waiting", waiting); err != nil {
+		logOutput("❌ 保存等待额度的池文件失败: %v\n", err)
+	}
+}
+
+func currentPortfolioStatus() PortfolioStatus {
+	cfg := currentConfig().Portfolio
+	deployed, pools, tokens := portfolioExposure()
+	s := PortfolioStatus{
+		Enabled: cfg.Enabled, DeployedSOL: deployed, Positions: len(pools),
+		MaxTotalSOL: cfg.MaxTotalSOL, MaxPositions: currentConfig().Admission.MaxOpenPositions, Tokens: tokens,
+	}
+	portfolioMutex.Lock()
+	for pool, r := range portfolioReserved {
+		s.ReservedSOL += r.amount
+		if _, ok := pools[pool]; !ok {
+			s.Positions++
+		}
+	}
+	if cfg.Enabled {
+		s.NextSOL, _, _, _ = portfolioAllocateLocked("", "")
+	}
+	portfolioMutex.Unlock()
+	s.Waiting = listPortfolioWaiting()
+	return s
+}
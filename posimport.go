@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auditKindImport = "import"
+	outcomeImported = "imported" // 由仓位快照导入，不再作为新池入场
+)
+
+// PositionImportConfig 启动时从仓位快照 CSV 导入已有仓位（迁移部署时保留历史仓位与盈亏基准）
+type PositionImportConfig struct {
+	Enabled   bool   `json:"enabled"`
+	File      string `json:"file"`      // 快照 CSV：pool、position、ca、entryPrice、solAmount 等列
+	Overwrite bool   `json:"overwrite"` // 覆盖已有生命周期与盈亏记录的池（默认跳过）
+}
+
+// PositionImportResult 一次导入的结果（data/state/position_import.json 记录最近一次）
+type PositionImportResult struct {
+	File       string            `json:"file"`
+	Digest     string            `json:"digest"` // 文件内容 sha256，同一快照只在启动时导入一次
+	ImportedAt string            `json:"importedAt"`
+	Imported   []string          `json:"imported"`
+	Skipped    map[string]string `json:"skipped,omitempty"` // 池或行号 -> 原因
+}
+
+// 快照中的一行（同一池可有多行，如阶梯档位）
+type importedPosition struct {
+	Pool        string
+	Position    string
+	Token       string
+	EntryPrice  float64
+	SolAmount   float64
+	DepositUSD  float64
+	ClaimedUSD  float64
+	OpenedAt    time.Time
+	ClosedAt    time.Time
+	CloseReason string
+	Wallet      string
+}
+
+func (c PositionImportConfig) validate() error {
+	if c.Enabled && strings.TrimSpace(c.File) == "" {
+		return fmt.Errorf("positionImport.enabled 为 true 时必须设置 file")
+	}
+	return nil
+}
+
+// 列名不区分大小写，忽略 _、- 与空格
+func importColumnKey(h string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.TrimSpace(h)))
+}
+
+// readPositionSnapshot 解析快照 CSV，返回有效行与无效行（行号 -> 原因）
+func readPositionSnapshot(path string) ([]importedPosition, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取表头失败: %v", err)
+	}
+	col := func(names ...string) int {
+		for i, h := range header {
+			for _, n := range names {
+				if importColumnKey(h) == n {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	cols := map[string]int{
+		"pool":        col("pool", "pooladdress"),
+		"position":    col("position", "positionaddress"),
+		"ca":          col("ca", "token", "tokenaddress"),
+		"entryPrice":  col("entryprice", "openprice"),
+		"solAmount":   col("solamount", "depositsol", "deposits", "deposit"),
+		"depositUSD":  col("depositusd", "costusd"),
+		"claimedUSD":  col("claimedusd"),
+		"openedAt":    col("openedat"),
+		"closedAt":    col("closedat"),
+		"closeReason": col("closereason"),
+		"wallet":      col("wallet"),
+	}
+	if cols["pool"] < 0 || cols["solAmount"] < 0 {
+		return nil, nil, fmt.Errorf("缺少 pool 或 solAmount 列")
+	}
+
+	var rows []importedPosition
+	invalid := map[string]string{}
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("第 %d 行: %v", line, err)
+		}
+		field := func(name string) string {
+			i := cols[name]
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		number := func(name string) (float64, error) {
+			s := field(name)
+			if s == "" {
+				return 0, nil
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("%s 不是有效的非负数: %q", name, s)
+			}
+			return v, nil
+		}
+		p := importedPosition{
+			Pool:        field("pool"),
+			Position:    field("position"),
+			Token:       field("ca"),
+			CloseReason: field("closeReason"),
+			Wallet:      field("wallet"),
+		}
+		key := fmt.Sprintf("line %d", line)
+		if p.Pool == "" {
+			invalid[key] = "pool 为空"
+			continue
+		}
+		var errs []string
+		for _, n := range []struct {
+			name string
+			dst  *float64
+		}{{"entryPrice", &p.EntryPrice}, {"solAmount", &p.SolAmount}, {"depositUSD", &p.DepositUSD}, {"claimedUSD", &p.ClaimedUSD}} {
+			v, err := number(n.name)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			*n.dst = v
+		}
+		if p.SolAmount <= 0 && len(errs) == 0 {
+			errs = append(errs, "solAmount 必须大于0")
+		}
+		for _, n := range []struct {
+			name string
+			dst  *time.Time
+		}{{"openedAt", &p.OpenedAt}, {"closedAt", &p.ClosedAt}} {
+			if s := field(n.name); s != "" {
+				t, err := parseLastUpdatedFirstToTime(s)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s 无法解析: %q", n.name, s))
+				}
+				*n.dst = t
+			}
+		}
+		if p.Wallet != "" && len(currentConfig().Wallets) > 0 && findWallet(p.Wallet) == nil {
+			errs = append(errs, "未配置的钱包 "+p.Wallet)
+		}
+		if len(errs) > 0 {
+			invalid[key] = strings.Join(errs, "；")
+			continue
+		}
+		rows = append(rows, p)
+	}
+	return rows, invalid, nil
+}
+
+// importPositionSnapshot 按池导入快照：池 JSON（缺失时创建并标记为已处理）、生命周期记录、盈亏台账的成本基准与钱包分配
+func importPositionSnapshot(path string, overwrite bool) (*PositionImportResult, error) {
+	rows, invalid, err := readPositionSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &PositionImportResult{
+		File:       path,
+		Digest:     fileDigest(path),
+		ImportedAt: time.Now().Format(time.RFC3339),
+		Imported:   []string{},
+		Skipped:    invalid,
+	}
+
+	var pools []string
+	byPool := map[string][]importedPosition{}
+	for _, p := range rows {
+		if byPool[p.Pool] == nil {
+			pools = append(pools, p.Pool)
+		}
+		byPool[p.Pool] = append(byPool[p.Pool], p)
+	}
+	existing := loadPositionRecords()
+	ledger := loadPnLLedger()
+	for _, pool := range pools {
+		if !overwrite && (existing[pool] != nil || ledger[pool] != nil) {
+			result.Skipped[pool] = "已有仓位记录"
+			continue
+		}
+		if err := importPool(pool, byPool[pool]); err != nil {
+			result.Skipped[pool] = err.Error()
+			logError("❌ 导入仓位失败", "pool", pool, "error", err)
+			continue
+		}
+		result.Imported = append(result.Imported, pool)
+	}
+	if len(result.Skipped) == 0 {
+		result.Skipped = nil
+	}
+	return result, nil
+}
+
+func importPool(pool string, rows []importedPosition) error {
+	first := rows[0]
+	token, position := first.Token, first.Position
+	if err := ensureImportedPoolJSON(pool, position, token); err != nil {
+		return err
+	}
+	if token == "" {
+		token = readTokenContractAddressFromPoolJSON(pool)
+	}
+
+	// 开仓时间取各行最早值，缺失时为导入时间；成本按行累加
+	openedAt := time.Now()
+	var closedAt time.Time
+	var solAmount float64
+	for _, p := range rows {
+		if !p.OpenedAt.IsZero() && p.OpenedAt.Before(openedAt) {
+			openedAt = p.OpenedAt
+		}
+		if p.ClosedAt.After(closedAt) {
+			closedAt = p.ClosedAt
+		}
+		solAmount += p.SolAmount
+	}
+	// 任一行未平仓即视为池仍持有仓位
+	for _, p := range rows {
+		if p.ClosedAt.IsZero() {
+			closedAt = time.Time{}
+			break
+		}
+	}
+	opened := openedAt.Format(time.RFC3339)
+
+	if first.Wallet != "" {
+		setPoolWallet(pool, first.Wallet)
+	}
+
+	updatePositionRecord(pool, func(_ *PositionRecord) *PositionRecord {
+		r := &PositionRecord{
+			PoolAddress:  pool,
+			Position:     readPositionFromPoolJSON(pool),
+			TokenAddress: token,
+			Mode:         getPoolMode(pool),
+			OpenedAt:     opened,
+			SolAmount:    solAmount,
+			EntryPrice:   first.EntryPrice,
+		}
+		if r.EntryPrice <= 0 {
+			r.EntryPrice = readEntryPriceFromPoolJSON(pool)
+		}
+		r.applyRange(readOpenRangeFromPoolJSON(pool))
+		r.transition(positionStateOpened, "imported")
+		if !closedAt.IsZero() {
+			r.ClosedAt = closedAt.Format(time.RFC3339)
+			r.CloseReason = first.CloseReason
+			if r.CloseReason == "" {
+				r.CloseReason = "imported"
+			}
+			r.transition(positionStateClosed, r.CloseReason)
+		}
+		return r
+	})
+	if closedAt.IsZero() {
+		transitionPool(pool, poolActive, "imported")
+	} else {
+		transitionPool(pool, poolClosed, "imported")
+	}
+
+	pnlMutex.Lock()
+	solUSD := lastSolUSD
+	pnlMutex.Unlock()
+	updatePoolPnL(pool, func(_ *PoolPnL) *PoolPnL {
+		p := &PoolPnL{
+			PoolAddress: pool, TokenAddress: token, Mode: getPoolMode(pool), Variant: poolVariant(pool),
+			OpenedAt: opened, Positions: map[string]*PositionValue{},
+		}
+		for _, row := range rows {
+			usd := row.DepositUSD
+			if usd <= 0 {
+				usd = row.SolAmount * solUSD
+			}
+			p.CostSOL += row.SolAmount
+			p.CostUSD += usd
+			if p.SolUSD <= 0 && row.DepositUSD > 0 {
+				p.SolUSD = row.DepositUSD / row.SolAmount
+			}
+			// 台账事件时间取开仓时间，按计价货币折算时使用当时的汇率
+			at := opened
+			if !row.OpenedAt.IsZero() {
+				at = row.OpenedAt.Format(time.RFC3339)
+			}
+			p.add(pnlDeposit, row.Position, row.SolAmount, usd, "imported")
+			p.Entries[len(p.Entries)-1].At = at
+			if row.Position != "" || row.ClaimedUSD > 0 {
+				v := p.Positions[row.Position]
+				if v == nil {
+					v = &PositionValue{}
+					p.Positions[row.Position] = v
+				}
+				v.ClaimedUSD += row.ClaimedUSD
+			}
+			if row.ClaimedUSD > 0 {
+				p.add(pnlClaim, row.Position, 0, p.Positions[row.Position].ClaimedUSD, "imported")
+			}
+		}
+		if p.SolUSD <= 0 {
+			p.SolUSD = solUSD
+		}
+		if !closedAt.IsZero() {
+			p.ClosedAt = closedAt.Format(time.RFC3339)
+		}
+		return p
+	})
+
+	appendAudit(AuditEntry{
+		Kind: auditKindImport, Reason: "position", Pool: pool, Token: token, Wallet: first.Wallet,
+		Detail: fmt.Sprintf("仓位 %d 个，成本 %.6f SOL，开仓于 %s", len(rows), solAmount, opened),
+	})
+	logInfo("📥 已导入仓位", "pool", pool, "ca", token, "positions", len(rows), "solAmount", solAmount, "openedAt", opened, "closed", !closedAt.IsZero())
+	return nil
+}
+
+// 池 JSON 不存在时按快照创建；已存在但没有仓位地址时补写。随后标记为已处理，补处理不会把导入的池当作新池开仓
+func ensureImportedPoolJSON(pool, position, token string) error {
+	path := filepath.Join(poolDataDir(), pool+".json")
+	if err := os.MkdirAll(poolDataDir(), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockDataFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	obj := map[string]interface{}{}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, &obj); err != nil {
+			return fmt.Errorf("解析池JSON失败: %v", err)
+		}
+	case os.IsNotExist(err):
+		obj["poolAddress"] = pool
+		obj["source"] = "import"
+		obj["data"] = map[string]interface{}{"ca": token}
+	default:
+		return err
+	}
+
+	changed := os.IsNotExist(err)
+	if position != "" {
+		if current := readPositionFromPoolJSONObject(obj); current == "" {
+			obj["positionAddress"] = position
+			changed = true
+		} else if current != position {
+			logWarn("⚠️ 池JSON中的仓位地址与快照不同，保留池JSON中的地址", "pool", pool, "json", current, "snapshot", position)
+		}
+	}
+	if changed {
+		if err := writeJSONFileAtomic(path, obj); err != nil {
+			return fmt.Errorf("写入池JSON失败: %v", err)
+		}
+	}
+	if claimProcessed(path) {
+		markProcessed(path, outcomeImported)
+	}
+	return nil
+}
+
+// 池 JSON 对象中的仓位地址（顶层优先，其次 data.positionAddress）
+func readPositionFromPoolJSONObject(obj map[string]interface{}) string {
+	if v, ok := obj["positionAddress"].(string); ok && v != "" {
+		return v
+	}
+	if m, ok := obj["data"].(map[string]interface{}); ok {
+		if v, ok := m["positionAddress"].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// runPositionImport 导入快照并记录结果；onlyOnce 时同一内容的快照已导入过则跳过
+func runPositionImport(path string, overwrite, onlyOnce bool) (*PositionImportResult, error) {
+	var last PositionImportResult
+	if err := loadStateFile("position_import", &last); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if onlyOnce && last.Digest != "" && last.Digest == fileDigest(path) {
+		logDebug("📥 仓位快照已导入过，跳过", "file", path, "importedAt", last.ImportedAt)
+		return nil, nil
+	}
+	result, err := importPositionSnapshot(path, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveStateFile("position_import", result); err != nil {
+		logOutput("❌ 保存仓位导入记录失败: %v\n", err)
+	}
+	logInfo("📥 仓位快照导入完成", "file", path, "imported", len(result.Imported), "skipped", len(result.Skipped))
+	for key, reason := range result.Skipped {
+		logWarn("⚠️ 仓位快照跳过", "entry", key, "reason", reason)
+	}
+	return result, nil
+}
+
+// startupPositionImport 启动时按配置导入仓位快照（在补处理之前执行）
+func startupPositionImport() {
+	cfg := currentConfig().PositionImport
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	if _, err := runPositionImport(cfg.File, cfg.Overwrite, true); err != nil {
+		logError("❌ 导入仓位快照失败", "file", cfg.File, "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 价格历史目录（与 fetchPrice.ts 的价格缓存共用 data/prices）
+func priceHistoryDir() string { return dataPath("prices", "history") }
+
+var priceHistoryMutex sync.Mutex
+
+// PriceSample 单次价格采样记录
+type PriceSample struct {
+	Time        string       `json:"time"`
+	PoolAddress string       `json:"poolAddress"`
+	Token       string       `json:"ca"`
+	Price       string       `json:"price"`
+	Source      string       `json:"source,omitempty"` // 采用的价格源（median 表示多源中位数）
+	Quotes      []PriceQuote `json:"quotes,omitempty"` // 各价格源的报价
+	Mode        string       `json:"mode"`
+}
+
+// 追加价格采样到 data/prices/history/<ca>.jsonl（研究模式与实盘共用同一份数据）
+func recordPriceSample(poolAddress, tokenAddress, price, source string, quotes []PriceQuote) {
+	now := time.Now()
+	sample := PriceSample{
+		Time:        now.Format(time.RFC3339),
+		PoolAddress: poolAddress,
+		Token:       tokenAddress,
+		Price:       price,
+		Source:      source,
+		Quotes:      quotes,
+		Mode:        currentConfig().Mode,
+	}
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return
+	}
+	if appendPriceSample(tokenAddress, line) {
+		updateOHLC(poolAddress, tokenAddress, price, now)
+	}
+}
+
+func appendPriceSample(tokenAddress string, line []byte) bool {
+	priceHistoryMutex.Lock()
+	defer priceHistoryMutex.Unlock()
+
+	if err := os.MkdirAll(priceHistoryDir(), 0755); err != nil {
+		logOutput("❌ 创建价格历史目录失败: %v\n", err)
+		return false
+	}
+	path := filepath.Join(priceHistoryDir(), tokenAddress+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logOutput("❌ 写入价格历史失败: %v\n", err)
+		return false
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err == nil
+}
+
+// 读取 since 之后的价格采样（按时间顺序，跳过无法解析的行）
+func loadPriceHistory(tokenAddress string, since time.Time) []PriceSample {
+	priceHistoryMutex.Lock()
+	defer priceHistoryMutex.Unlock()
+
+	content, err := os.ReadFile(filepath.Join(priceHistoryDir(), tokenAddress+".jsonl"))
+	if err != nil {
+		return nil
+	}
+	var samples []PriceSample
+	for _, line := range strings.Split(string(content), "\n") {
+		var s PriceSample
+		if line == "" || json.Unmarshal([]byte(line), &s) != nil {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s.Time); err != nil || t.Before(since) {
+			continue
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
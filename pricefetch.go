@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PriceFetchConfig 价格获取并发与上游限速
+type PriceFetchConfig struct {
+	Workers        int                        `json:"workers"`        // 并发执行 fetchPrice.ts 的数量
+	Limiters       map[string]RateLimitConfig `json:"limiters"`       // 上游 API 名 -> 令牌桶（okx、jupiter、birdeye），上游没有返回限速头时按此限速
+	MaxWaitSeconds int                        `json:"maxWaitSeconds"` // 单次请求排队等待预算的上限，超过时放弃本次请求；0 表示一直等待
+}
+
+// RateLimitConfig 令牌桶：每秒补充 ratePerSecond 个令牌，最多积攒 burst 个
+type RateLimitConfig struct {
+	RatePerSecond float64 `json:"ratePerSecond"`
+	Burst         int     `json:"burst"`
+}
+
+func (c PriceFetchConfig) validate() error {
+	if c.Workers <= 0 {
+		return fmt.Errorf("priceFetch.workers 必须大于0")
+	}
+	if c.MaxWaitSeconds < 0 {
+		return fmt.Errorf("priceFetch.maxWaitSeconds 不能为负数")
+	}
+	for name, l := range c.Limiters {
+		if l.RatePerSecond <= 0 || l.Burst <= 0 {
+			return fmt.Errorf("priceFetch.limiters.%s 的 ratePerSecond 与 burst 必须大于0", name)
+		}
+	}
+	return nil
+}
+
+// tokenBucket 令牌桶限速器
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(cfg RateLimitConfig) *tokenBucket {
+	return &tokenBucket{rate: cfg.RatePerSecond, burst: float64(cfg.Burst), tokens: float64(cfg.Burst), last: time.Now()}
+}
+
+// reserve 取一个令牌，返回需要等待的时长（令牌不足时预支，等待后即可使用）
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// fetchPricesConcurrently 把所有池的价格获取加入任务队列（并发取 priceFetch.workers）并等待完成
+func fetchPricesConcurrently(tokenAddresses map[string]string) {
+	var pending []<-chan struct{}
+	for poolAddress, tokenAddress := range tokenAddresses {
+		if globalCtx.Err() != nil {
+			break
+		}
+		pending = append(pending, enqueuePriceFetch(poolAddress, tokenAddress))
+	}
+	waitJobs(pending)
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// K 线目录：data/prices/ohlc/<周期>/<ca>.jsonl，每行一根已收盘的 K 线
+func ohlcDir() string { return dataPath("prices", "ohlc") }
+
+// 支持的 K 线周期（由细到粗）
+var ohlcIntervalNames = []string{"1m", "5m", "1h"}
+
+var ohlcIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+}
+
+// PriceStoreConfig 价格历史的保留策略：原始采样按小时保留，降采样后的 K 线按周期分别保留
+type PriceStoreConfig struct {
+	RawRetentionHours      int            `json:"rawRetentionHours"`      // 原始采样保留时长，0 表示不清理
+	RetentionDays          map[string]int `json:"retentionDays"`          // 周期 -> K 线保留天数，未列出或为 0 表示不清理
+	CompactIntervalMinutes int            `json:"compactIntervalMinutes"` // 清理间隔
+}
+
+// OHLCBar 一根 K 线（time 为开盘时间）
+type OHLCBar struct {
+	Time    string  `json:"time"`
+	Pool    string  `json:"poolAddress,omitempty"` // 最后一次采样的池
+	Open    float64 `json:"open"`
+	High    float64 `json:"high"`
+	Low     float64 `json:"low"`
+	Close   float64 `json:"close"`
+	Samples int     `json:"samples"`
+
+	start time.Time
+}
+
+// PricePoint 统一的历史价格点：保留期内为原始采样，更早的部分由 K 线收盘价补齐
+type PricePoint struct {
+	At    time.Time
+	Price float64
+	Pool  string
+}
+
+var (
+	ohlcMutex sync.Mutex
+	// 周期/代币 -> 未收盘的 K 线；键存在但值为 nil 表示已从原始采样恢复且没有未收盘的 K 线
+	ohlcOpen = map[string]*OHLCBar{}
+)
+
+func (c PriceStoreConfig) validate() error {
+	if c.RawRetentionHours < 0 {
+		return fmt.Errorf("priceStore.rawRetentionHours 不能为负数")
+	}
+	for name, days := range c.RetentionDays {
+		if _, ok := ohlcIntervals[name]; !ok {
+			return fmt.Errorf("priceStore.retentionDays 不支持的周期: %s（可选 %s）", name, strings.Join(ohlcIntervalNames, "、"))
+		}
+		if days < 0 {
+			return fmt.Errorf("priceStore.retentionDays.%s 不能为负数", name)
+		}
+	}
+	if c.CompactIntervalMinutes <= 0 {
+		return fmt.Errorf("priceStore.compactIntervalMinutes 必须大于0")
+	}
+	return nil
+}
+
+func ohlcPath(name, tokenAddress string) string {
+	return filepath.Join(ohlcDir(), name, tokenAddress+".jsonl")
+}
+
+// updateOHLC 把一次价格采样计入各周期的 K 线；需在原始采样写入之后调用（首次遇到的代币从原始采样恢复未收盘的 K 线）
+func updateOHLC(poolAddress, tokenAddress, price string, at time.Time) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+	if err != nil || v <= 0 {
+		return
+	}
+	ohlcMutex.Lock()
+	defer ohlcMutex.Unlock()
+	for _, name := range ohlcIntervalNames {
+		if _, ok := ohlcOpen[name+"/"+tokenAddress]; !ok {
+			// 恢复时已包含本次采样
+			recoverOHLCLocked(name, tokenAddress)
+			continue
+		}
+		applyOHLCLocked(name, tokenAddress, poolAddress, at, v)
+	}
+}
+
+// 调用方需持有 ohlcMutex
+func applyOHLCLocked(name, tokenAddress, poolAddress string, at time.Time, price float64) {
+	key := name + "/" + tokenAddress
+	start := at.Truncate(ohlcIntervals[name])
+	bar := ohlcOpen[key]
+	if bar != nil {
+		switch {
+		case bar.start.Equal(start):
+			bar.High = max(bar.High, price)
+			bar.Low = min(bar.Low, price)
+			bar.Close = price
+			bar.Samples++
+			if poolAddress != "" {
+				bar.Pool = poolAddress
+			}
+			return
+		case start.Before(bar.start):
+			// 时钟回拨等导致的乱序采样，不计入
+			return
+		}
+		flushOHLCLocked(name, tokenAddress, bar)
+	}
+	ohlcOpen[key] = &OHLCBar{Time: start.Format(time.RFC3339), Pool: poolAddress, Open: price, High: price, Low: price, Close: price, Samples: 1, start: start}
+}
+
+// 调用方需持有 ohlcMutex
+func flushOHLCLocked(name, tokenAddress string, bar *OHLCBar) {
+	line, err := json.Marshal(bar)
+	if err != nil {
+		return
+	}
+	path := ohlcPath(name, tokenAddress)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logOutput("❌ 创建K线目录失败: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logOutput("❌ 写入K线失败: %v\n", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// recoverOHLCLocked 从最后一根已收盘 K 线之后的原始采样重建（启动后首次遇到该代币，或历史数据首次降采样）；调用方需持有 ohlcMutex
+func recoverOHLCLocked(name, tokenAddress string) {
+	ohlcOpen[name+"/"+tokenAddress] = nil
+	since := time.Time{}
+	if bars := readOHLCFile(name, tokenAddress); len(bars) > 0 {
+		since = bars[len(bars)-1].start.Add(ohlcIntervals[name])
+	}
+	for _, s := range loadPriceHistory(tokenAddress, since) {
+		at, err := time.Parse(time.RFC3339, s.Time)
+		v, perr := strconv.ParseFloat(strings.TrimSpace(s.Price), 64)
+		if err != nil || perr != nil || v <= 0 {
+			continue
+		}
+		applyOHLCLocked(name, tokenAddress, s.PoolAddress, at, v)
+	}
+}
+
+func readOHLCFile(name, tokenAddress string) []OHLCBar {
+	content, err := os.ReadFile(ohlcPath(name, tokenAddress))
+	if err != nil {
+		return nil
+	}
+	var bars []OHLCBar
+	for _, line := range strings.Split(string(content), "\n") {
+		var b OHLCBar
+		if line == "" || json.Unmarshal([]byte(line), &b) != nil {
+			continue
+		}
+		if b.start, err = time.Parse(time.RFC3339, b.Time); err != nil {
+			continue
+		}
+		bars = append(bars, b)
+	}
+	return bars
+}
+
+// loadOHLC 读取 since 之后（含跨越 since 的一根）的 K 线，末尾包含未收盘的 K 线
+func loadOHLC(tokenAddress, name string, since time.Time) ([]OHLCBar, error) {
+	d, ok := ohlcIntervals[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s（可选 %s）", name, strings.Join(ohlcIntervalNames, "、"))
+	}
+	ohlcMutex.Lock()
+	defer ohlcMutex.Unlock()
+	key := name + "/" + tokenAddress
+	if _, ok := ohlcOpen[key]; !ok {
+		recoverOHLCLocked(name, tokenAddress)
+	}
+	bars := []OHLCBar{}
+	for _, b := range readOHLCFile(name, tokenAddress) {
+		if b.start.Add(d).After(since) {
+			bars = append(bars, b)
+		}
+	}
+	if open := ohlcOpen[key]; open != nil && open.start.Add(d).After(since) {
+		bars = append(bars, *open)
+	}
+	return bars, nil
+}
+
+// storedPrices since 之后的价格序列：原始采样优先，原始采样已清理的更早时段依次用 1m、5m、1h K 线的收盘价补齐
+func storedPrices(tokenAddress string, since time.Time) []PricePoint {
+	var points []PricePoint
+	for _, s := range loadPriceHistory(tokenAddress, since) {
+		at, err := time.Parse(time.RFC3339, s.Time)
+		v, perr := strconv.ParseFloat(strings.TrimSpace(s.Price), 64)
+		if err != nil || perr != nil || v <= 0 {
+			continue
+		}
+		points = append(points, PricePoint{At: at, Price: v, Pool: s.PoolAddress})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+	earliest := time.Now()
+	if len(points) > 0 {
+		earliest = points[0].At
+	}
+	for _, name := range ohlcIntervalNames {
+		bars, _ := loadOHLC(tokenAddress, name, since)
+		var older []PricePoint
+		for _, b := range bars {
+			end := b.start.Add(ohlcIntervals[name])
+			if end.After(earliest) {
+				break
+			}
+			older = append(older, PricePoint{At: end, Price: b.Close, Pool: b.Pool})
+		}
+		if len(older) > 0 {
+			points = append(older, points...)
+			earliest = bars[0].start
+		}
+	}
+	return points
+}
+
+// storedTokens 有原始采样或 K 线的代币
+func storedTokens() []string {
+	seen := map[string]bool{}
+	dirs := []string{priceHistoryDir()}
+	for _, name := range ohlcIntervalNames {
+		dirs = append(dirs, filepath.Join(ohlcDir(), name))
+	}
+	for _, dir := range dirs {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) == ".jsonl" {
+				seen[strings.TrimSuffix(e.Name(), ".jsonl")] = true
+			}
+		}
+	}
+	tokens := make([]string, 0, len(seen))
+	for token := range seen {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// removeStoredPrices 删除代币的原始采样与 K 线（演示数据清理）
+func removeStoredPrices(tokenAddress string) {
+	priceHistoryMutex.Lock()
+	os.Remove(filepath.Join(priceHistoryDir(), tokenAddress+".jsonl"))
+	priceHistoryMutex.Unlock()
+	ohlcMutex.Lock()
+	defer ohlcMutex.Unlock()
+	for _, name := range ohlcIntervalNames {
+		os.Remove(ohlcPath(name, tokenAddress))
+		delete(ohlcOpen, name+"/"+tokenAddress)
+	}
+}
+
+// startPriceStoreCompaction 启动时补齐历史采样的 K 线，之后定期收盘过期 K 线并按保留策略清理
+func startPriceStoreCompaction() {
+	interval := time.Duration(currentConfig().PriceStore.CompactIntervalMinutes) * time.Minute
+	logOutput("🗜️ 启动价格历史降采样与清理（每%v）\n", interval)
+	compactPriceStore()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			compactPriceStore()
+		}
+	}
+}
+
+func compactPriceStore() {
+	cfg := currentConfig().PriceStore
+	now := time.Now()
+	tokens := storedTokens()
+
+	// 原始采样清理前确保已降采样：未恢复的代币先重建，已过收盘时间的 K 线写入文件
+	ohlcMutex.Lock()
+	for _, token := range tokens {
+		for _, name := range ohlcIntervalNames {
+			if _, ok := ohlcOpen[name+"/"+token]; !ok {
+				recoverOHLCLocked(name, token)
+			}
+		}
+	}
+	for key, bar := range ohlcOpen {
+		name, token, _ := strings.Cut(key, "/")
+		if bar != nil && !bar.start.Add(ohlcIntervals[name]).After(now) {
+			flushOHLCLocked(name, token, bar)
+			ohlcOpen[key] = nil
+		}
+	}
+	trimmedBars := 0
+	for _, name := range ohlcIntervalNames {
+		days := cfg.RetentionDays[name]
+		if days <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -days)
+		for _, token := range tokens {
+			trimmedBars += trimJSONL(ohlcPath(name, token), cutoff)
+		}
+	}
+	ohlcMutex.Unlock()
+
+	trimmedRaw := 0
+	if cfg.RawRetentionHours > 0 {
+		cutoff := now.Add(-time.Duration(cfg.RawRetentionHours) * time.Hour)
+		priceHistoryMutex.Lock()
+		for _, token := range tokens {
+			trimmedRaw += trimJSONL(filepath.Join(priceHistoryDir(), token+".jsonl"), cutoff)
+		}
+		priceHistoryMutex.Unlock()
+	}
+	if trimmedRaw > 0 || trimmedBars > 0 {
+		logInfo("🗜️ 已清理过期价格历史", "rawSamples", trimmedRaw, "bars", trimmedBars)
+	}
+}
+
+// trimJSONL 删除 time 字段早于 cutoff 的行（以及无法解析的行），返回删除的行数；调用方需持有对应文件的锁
+func trimJSONL(path string, cutoff time.Time) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var kept bytes.Buffer
+	removed := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var row struct {
+			Time string `json:"time"`
+		}
+		if json.Unmarshal(line, &row) == nil {
+			if t, err := time.Parse(time.RFC3339, row.Time); err == nil && !t.Before(cutoff) {
+				kept.Write(line)
+				kept.WriteByte('\n')
+				continue
+			}
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0
+	}
+	if kept.Len() == 0 {
+		os.Remove(path)
+		return removed
+	}
+	if err := writeFileAtomic(path, kept.Bytes(), 0644); err != nil {
+		logOutput("❌ 清理价格历史失败: %v\n", err)
+		return 0
+	}
+	return removed
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 价格源
+const (
+	priceSourceOKX     = "okx"     // fetchPrice.ts 输出的 OKX DEX 价格
+	priceSourceJupiter = "jupiter" // Jupiter Price API
+	priceSourceBirdeye = "birdeye" // Birdeye 公共 API
+	priceSourceDLMM    = "dlmm"    // 链上 DLMM 活跃 bin 价格（按报价代币的美元价折算）
+)
+
+// 多源聚合方式
+const (
+	pricingFallback = "fallback" // 按优先级取第一个成功的价格源
+	pricingMedian   = "median"   // 取所有成功价格源的中位数
+)
+
+// PricingConfig 多源价格：按优先级回退或取中位数，结果记录实际采用的价格源
+type PricingConfig struct {
+	Providers      []string `json:"providers"`      // 优先级顺序（okx、jupiter、birdeye、dlmm）
+	Strategy       string   `json:"strategy"`       // fallback（默认）或 median
+	MinSources     int      `json:"minSources"`     // median 时至少需要的成功价格源数量
+	BirdeyeAPIKey  string   `json:"birdeyeApiKey"`  // 为空时读取环境变量 BIRDEYE_API_KEY
+	TimeoutSeconds int      `json:"timeoutSeconds"` // 单个 HTTP 价格源超时
+}
+
+// PriceQuote 单个价格源的报价
+type PriceQuote struct {
+	Source string  `json:"source"`
+	Price  float64 `json:"price"`
+}
+
+// PriceProvider 价格源接口，返回代币的美元价格
+type PriceProvider interface {
+	Name() string
+	Price(ctx context.Context, poolAddress, tokenAddress string) (float64, error)
+}
+
+// USDT mint（与 USDC 一样按 1 美元折算）
+const usdtMint = "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB"
+
+var pricingHTTP = &http.Client{}
+
+func (c PricingConfig) validate() error {
+	if len(c.Providers) == 0 {
+		return fmt.Errorf("pricing.providers 不能为空")
+	}
+	seen := map[string]bool{}
+	for _, p := range c.Providers {
+		switch p {
+		case priceSourceOKX, priceSourceJupiter, priceSourceBirdeye, priceSourceDLMM:
+		default:
+			return fmt.Errorf("pricing.providers 不支持的价格源: %s", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("pricing.providers 重复: %s", p)
+		}
+		seen[p] = true
+	}
+	if c.Strategy != pricingFallback && c.Strategy != pricingMedian {
+		return fmt.Errorf("pricing.strategy 仅支持 %s 或 %s", pricingFallback, pricingMedian)
+	}
+	if c.MinSources < 0 || c.MinSources > len(c.Providers) {
+		return fmt.Errorf("pricing.minSources 需在 0 到价格源数量之间")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("pricing.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+// scriptProvider fetchPrice.ts 已输出的价格（脚本照常执行，此处只负责解析）
+type scriptProvider struct{ price string }
+
+func (p scriptProvider) Name() string { return priceSourceOKX }
+func (p scriptProvider) Price(context.Context, string, string) (float64, error) {
+	if p.price == "" {
+		return 0, fmt.Errorf("fetchPrice.ts 未输出价格")
+	}
+	return parsePositivePrice(p.price)
+}
+
+// jupiterProvider Jupiter Price API v3
+type jupiterProvider struct{}
+
+func (jupiterProvider) Name() string { return priceSourceJupiter }
+func (jupiterProvider) Price(ctx context.Context, _, tokenAddress string) (float64, error) {
+	return jupiterUSDPrice(ctx, tokenAddress)
+}
+
+func jupiterUSDPrice(ctx context.Context, mint string) (float64, error) {
+	var resp map[string]struct {
+		USDPrice float64 `json:"usdPrice"`
+	}
+	if err := apiGetJSON(ctx, priceSourceJupiter, "https://lite-api.jup.ag/price/v3?ids="+url.QueryEscape(mint), nil, &resp); err != nil {
+		return 0, err
+	}
+	item, ok := resp[mint]
+	if !ok || item.USDPrice <= 0 {
+		return 0, fmt.Errorf("jupiter 未返回价格: %s", mint)
+	}
+	return item.USDPrice, nil
+}
+
+// birdeyeProvider Birdeye 公共 API（需要 API Key）
+type birdeyeProvider struct{ apiKey string }
+
+func (birdeyeProvider) Name() string { return priceSourceBirdeye }
+func (p birdeyeProvider) Price(ctx context.Context, _, tokenAddress string) (float64, error) {
+	if p.apiKey == "" {
+		return 0, fmt.Errorf("未配置 Birdeye API Key（pricing.birdeyeApiKey 或 BIRDEYE_API_KEY）")
+	}
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Value float64 `json:"value"`
+		} `json:"data"`
+	}
+	headers := map[string]string{"X-API-KEY": p.apiKey, "x-chain": "solana"}
+	if err := apiGetJSON(ctx, priceSourceBirdeye, "https://public-api.birdeye.so/defi/price?address="+url.QueryEscape(tokenAddress), headers, &resp); err != nil {
+		return 0, err
+	}
+	if !resp.Success || resp.Data.Value <= 0 {
+		return 0, fmt.Errorf("birdeye 未返回价格: %s", tokenAddress)
+	}
+	return resp.Data.Value, nil
+}
+
+// dlmmProvider 读取 LbPair 账户的活跃 bin 计算价格：(1 + binStep/10000)^activeId × 10^(decX-decY)
+type dlmmProvider struct{}
+
+// LbPair 账户布局中的偏移（8 字节 discriminator + 参数区 64 字节之后）
+const (
+	lbPairActiveIDOffset = 76
+	lbPairBinStepOffset  = 80
+	lbPairMintXOffset    = 88
+	lbPairMintYOffset    = 120
+	lbPairMinLength      = 152
+	mintDecimalsOffset   = 44
+)
+
+func (dlmmProvider) Name() string { return priceSourceDLMM }
+func (dlmmProvider) Price(ctx context.Context, poolAddress, tokenAddress string) (float64, error) {
+	data, err := accountData(ctx, poolAddress)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < lbPairMinLength {
+		return 0, fmt.Errorf("LbPair 账户数据长度不足: %d", len(data))
+	}
+	activeID := int32(binary.LittleEndian.Uint32(data[lbPairActiveIDOffset:]))
+	binStep := binary.LittleEndian.Uint16(data[lbPairBinStepOffset:])
+	mintX := encodeBase58(data[lbPairMintXOffset:lbPairMintYOffset])
+	mintY := encodeBase58(data[lbPairMintYOffset:lbPairMinLength])
+	decX, err := mintDecimals(ctx, mintX)
+	if err != nil {
+		return 0, err
+	}
+	decY, err := mintDecimals(ctx, mintY)
+	if err != nil {
+		return 0, err
+	}
+	// X 以 Y 计价的价格
+	price := math.Pow(1+float64(binStep)/10000, float64(activeID)) * math.Pow10(decX-decY)
+	quote := mintY
+	switch tokenAddress {
+	case mintX:
+	case mintY:
+		price, quote = 1/price, mintX
+	default:
+		return 0, fmt.Errorf("代币 %s 不属于池 %s", tokenAddress, poolAddress)
+	}
+	quoteUSD := 1.0
+	if quote != usdcMint && quote != usdtMint {
+		if quoteUSD, err = jupiterUSDPrice(ctx, quote); err != nil {
+			return 0, fmt.Errorf("获取报价代币美元价格失败: %v", err)
+		}
+	}
+	return price * quoteUSD, nil
+}
+
+// 读取账户数据（base64）
+func accountData(ctx context.Context, address string) ([]byte, error) {
+	data, _, err := accountInfo(ctx, address)
+	if err == nil && data == nil {
+		err = fmt.Errorf("账户不存在: %s", address)
+	}
+	return data, err
+}
+
+// accountInfo 账户数据与所属程序；账户不存在时返回 nil 数据、无错误
+func accountInfo(ctx context.Context, address string) ([]byte, string, error) {
+	var result struct {
+		Value *struct {
+			Data  []string `json:"data"`
+			Owner string   `json:"owner"`
+		} `json:"value"`
+	}
+	if err := solanaRPC(ctx, "getAccountInfo", []interface{}{address, map[string]string{"encoding": "base64"}}, &result); err != nil {
+		return nil, "", err
+	}
+	if result.Value == nil || len(result.Value.Data) == 0 {
+		return nil, "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Value.Data[0])
+	return data, result.Value.Owner, err
+}
+
+// mintDecimals 代币精度（来自代币信息缓存）
+func mintDecimals(ctx context.Context, mint string) (int, error) {
+	meta, err := tokenMetadata(ctx, mint)
+	if err != nil {
+		return 0, err
+	}
+	return meta.Decimals, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func encodeBase58(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func parsePositivePrice(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("无效的价格: %q", s)
+	}
+	return v, nil
+}
+
+func formatPrice(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// 按配置顺序构建价格源
+func pricingProviders(scriptPrice string) []PriceProvider {
+	cfg := currentConfig().Pricing
+	providers := make([]PriceProvider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		switch name {
+		case priceSourceOKX:
+			providers = append(providers, scriptProvider{price: scriptPrice})
+		case priceSourceJupiter:
+			providers = append(providers, jupiterProvider{})
+		case priceSourceBirdeye:
+			key := cfg.BirdeyeAPIKey
+			if key == "" {
+				key = os.Getenv("BIRDEYE_API_KEY")
+			}
+			providers = append(providers, birdeyeProvider{apiKey: key})
+		case priceSourceDLMM:
+			providers = append(providers, dlmmProvider{})
+		}
+	}
+	return providers
+}
+
+// resolvePrice 综合各价格源得到最终价格：fallback 按优先级取第一个成功的，median 取所有成功报价的中位数。
+// 返回价格字符串、采用的价格源（median 时为 "median"）与各源报价；全部失败时价格为空
+func resolvePrice(poolAddress, tokenAddress, scriptPrice string) (string, string, []PriceQuote) {
+	cfg := currentConfig().Pricing
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	var quotes []PriceQuote
+	for _, p := range pricingProviders(scriptPrice) {
+		ctx, cancel := context.WithTimeout(globalCtx, timeout)
+		v, err := p.Price(ctx, poolAddress, tokenAddress)
+		cancel()
+		if err != nil {
+			metricPriceSources.Inc(p.Name(), "failure")
+			if p.Name() != priceSourceOKX {
+				logWarn("⚠️ 价格源获取失败", "source", p.Name(), "pool", poolAddress, "token", tokenAddress, "error", err)
+			}
+			continue
+		}
+		metricPriceSources.Inc(p.Name(), "success")
+		quotes = append(quotes, PriceQuote{Source: p.Name(), Price: v})
+		if cfg.Strategy == pricingFallback {
+			return formatPrice(v), p.Name(), quotes
+		}
+	}
+	if len(quotes) == 0 {
+		return "", "", nil
+	}
+	if cfg.Strategy == pricingFallback {
+		return "", "", quotes
+	}
+	if len(quotes) < cfg.MinSources {
+		logWarn("⚠️ 成功的价格源数量不足，放弃本次价格", "pool", poolAddress, "sources", len(quotes), "minSources", cfg.MinSources)
+		return "", "", quotes
+	}
+	values := make([]float64, len(quotes))
+	for i, q := range quotes {
+		values[i] = q.Price
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	median := values[mid]
+	if len(values)%2 == 0 {
+		median = (values[mid-1] + values[mid]) / 2
+	}
+	return formatPrice(median), pricingMedian, quotes
+}
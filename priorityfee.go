@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 设置优先费的操作
+const (
+	feeOpAddLiquidity = "addLiquidity"
+	feeOpClaim        = "claim"
+	feeOpSwap         = "swap"
+)
+
+// PriorityFeeConfig 按近期区块的优先费动态设置计算单元价格（getRecentPrioritizationFees，RPC 同 walletWatch.rpcUrl）
+type PriorityFeeConfig struct {
+	Enabled          bool                           `json:"enabled"`
+	IntervalSeconds  int                            `json:"intervalSeconds"`  // 采样间隔
+	Percentile       float64                        `json:"percentile"`       // 取最近区块优先费的分位数（0~100）
+	MinMicroLamports int64                          `json:"minMicroLamports"` // 计算单元价格下限（尚未采样成功时使用）
+	Operations       map[string]PriorityFeeOpConfig `json:"operations"`       // addLiquidity / claim / swap
+}
+
+// PriorityFeeOpConfig 单个操作的优先费
+type PriorityFeeOpConfig struct {
+	Multiplier       float64 `json:"multiplier"`       // 采样值的倍数
+	MaxMicroLamports int64   `json:"maxMicroLamports"` // 计算单元价格上限
+	ComputeUnits     int64   `json:"computeUnits"`     // 估算的计算单元数，用于换算 jupSwap -maxfee（lamports）
+}
+
+// PriorityFeeStatus 最近一次采样与各操作当前生效的价格
+type PriorityFeeStatus struct {
+	SampledAt     string           `json:"sampledAt,omitempty"`
+	Slots         int              `json:"slots"`
+	MicroLamports int64            `json:"microLamports"` // 采样分位数
+	Operations    map[string]int64 `json:"operations"`    // 操作 -> 计算单元价格（microLamports）
+	SwapMaxFee    string           `json:"swapMaxFee"`    // jupSwap -maxfee（lamports）
+	Error         string           `json:"error,omitempty"`
+}
+
+type prioritizationFee struct {
+	Slot              uint64 `json:"slot"`
+	PrioritizationFee int64  `json:"prioritizationFee"`
+}
+
+var (
+	priorityFeeMutex  sync.Mutex
+	priorityFeeSample PriorityFeeStatus
+)
+
+func (c PriorityFeeConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("priorityFee 需要 walletWatch.rpcUrl")
+	}
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("priorityFee.intervalSeconds 必须大于0")
+	}
+	if c.Percentile < 0 || c.Percentile > 100 {
+		return fmt.Errorf("priorityFee.percentile 取值范围为 0~100")
+	}
+	if c.MinMicroLamports < 0 {
+		return fmt.Errorf("priorityFee.minMicroLamports 不能为负数")
+	}
+	for _, op := range []string{feeOpAddLiquidity, feeOpClaim, feeOpSwap} {
+		o, ok := c.Operations[op]
+		if !ok {
+			return fmt.Errorf("priorityFee.operations 缺少 %s", op)
+		}
+		if o.Multiplier <= 0 || o.MaxMicroLamports <= 0 {
+			return fmt.Errorf("priorityFee.operations.%s 的 multiplier、maxMicroLamports 必须大于0", op)
+		}
+		if o.MaxMicroLamports < c.MinMicroLamports {
+			return fmt.Errorf("priorityFee.operations.%s.maxMicroLamports 不能小于 minMicroLamports", op)
+		}
+	}
+	if c.Operations[feeOpSwap].ComputeUnits <= 0 {
+		return fmt.Errorf("priorityFee.operations.swap.computeUnits 必须大于0")
+	}
+	return nil
+}
+
+// 采样一次最近区块的优先费
+func samplePriorityFees() {
+	cfg := currentConfig().PriorityFee
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	var fees []prioritizationFee
+	err := solanaRPC(ctx, "getRecentPrioritizationFees", []interface{}{}, &fees)
+
+	priorityFeeMutex.Lock()
+	defer priorityFeeMutex.Unlock()
+	if err != nil {
+		priorityFeeSample.Error = err.Error()
+		logWarn("⚠️ 获取近期优先费失败，沿用上次采样", "error", err)
+		return
+	}
+	if len(fees) == 0 {
+		return
+	}
+	values := make([]int64, 0, len(fees))
+	for _, f := range fees {
+		values = append(values, f.PrioritizationFee)
+	}
+	sort.Slice(values, func(a, b int) bool { return values[a] < values[b] })
+	idx := int(math.Ceil(cfg.Percentile/100*float64(len(values)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	priorityFeeSample = PriorityFeeStatus{
+		SampledAt:     time.Now().Format(time.RFC3339),
+		Slots:         len(values),
+		MicroLamports: values[idx],
+	}
+	logDebug("💸 近期优先费采样", "slots", len(values), "percentile", cfg.Percentile, "microLamports", values[idx])
+}
+
+// computeUnitPrice 操作当前的计算单元价格（microLamports）：采样值 × multiplier，限制在 [minMicroLamports, maxMicroLamports]
+func computeUnitPrice(op string) int64 {
+	cfg := currentConfig().PriorityFee
+	o := cfg.Operations[op]
+	priorityFeeMutex.Lock()
+	sampled := priorityFeeSample.MicroLamports
+	priorityFeeMutex.Unlock()
+	price := int64(float64(sampled) * o.Multiplier)
+	if price < cfg.MinMicroLamports {
+		price = cfg.MinMicroLamports
+	}
+	if o.MaxMicroLamports > 0 && price > o.MaxMicroLamports {
+		price = o.MaxMicroLamports
+	}
+	return price
+}
+
+// priorityFeeArgs 脚本的 --cu-price 参数（未启用时不传，脚本沿用 SDK 默认）
+func priorityFeeArgs(op string) []string {
+	if !currentConfig().PriorityFee.Enabled {
+		return nil
+	}
+	return []string{"--cu-price=" + strconv.FormatInt(computeUnitPrice(op), 10)}
+}
+
+// swapMaxFee jupSwap 的 -maxfee（lamports）：启用时为 swap 的计算单元价格 × computeUnits，否则为参数档位的取值
+func swapMaxFee() string {
+	if !currentConfig().PriorityFee.Enabled {
+		return profileSwapMaxFee()
+	}
+	lamports := computeUnitPrice(feeOpSwap) * currentConfig().PriorityFee.Operations[feeOpSwap].ComputeUnits / 1_000_000
+	if lamports < 1 {
+		lamports = 1
+	}
+	return strconv.FormatInt(lamports, 10)
+}
+
+// 脚本内部执行 jupSwap 时的 --swap-maxfee 参数
+func swapMaxFeeArgs() []string {
+	if !currentConfig().PriorityFee.Enabled {
+		return nil
+	}
+	return []string{"--swap-maxfee=" + swapMaxFee()}
+}
+
+func currentPriorityFee() PriorityFeeStatus {
+	priorityFeeMutex.Lock()
+	s := priorityFeeSample
+	priorityFeeMutex.Unlock()
+	s.Operations = map[string]int64{}
+	if currentConfig().PriorityFee.Enabled {
+		for _, op := range []string{feeOpAddLiquidity, feeOpClaim, feeOpSwap} {
+			s.Operations[op] = computeUnitPrice(op)
+		}
+	}
+	s.SwapMaxFee = swapMaxFee()
+	return s
+}
+
+// 各操作当前计算单元价格（/metrics）
+func priorityFeeSamples() []gaugeSample {
+	if !currentConfig().PriorityFee.Enabled {
+		return nil
+	}
+	var samples []gaugeSample
+	for _, op := range []string{feeOpAddLiquidity, feeOpClaim, feeOpSwap} {
+		samples = append(samples, gaugeSample{LabelValues: []string{op}, Value: float64(computeUnitPrice(op))})
+	}
+	return samples
+}
+
+// startPriorityFeeSampler 定期采样近期优先费（演示模式不访问 RPC，按下限设置）
+func startPriorityFeeSampler() {
+	cfg := currentConfig().PriorityFee
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🕐 启动优先费采样（每%v，取 p%g）\n", interval, cfg.Percentile)
+	samplePriorityFees()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止优先费采样\n")
+			return
+		case <-ticker.C:
+			samplePriorityFees()
+		}
+	}
+}
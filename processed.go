@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+)
+
+// 新池 JSON 的处理结果
+const (
+	outcomeProcessing       = "processing" // 处理中（重启后仍为此状态说明处理被中断）
+	outcomeSuccess          = "success"
+	outcomeFailed           = "failed"
+	outcomeInvalid          = "invalid"
+	outcomePaper            = "paper"
+	outcomePriceOnly        = "price_only"
+	outcomeRateLimited      = "rate_limited"
+	outcomeOutsideWindow    = "outside_window"
+	outcomeKillSwitch       = "kill_switch"
+	outcomeBanned           = "banned"
+	outcomeClusterUnhealthy = "cluster_unhealthy"
+	outcomeStale            = "stale"
+	outcomeDuplicate        = "duplicate" // 池已有未平仓仓位，拒绝重复开仓
+)
+
+// 已处理标记保留时长，过期后清理
+const processedRetention = 30 * 24 * time.Hour
+
+// ProcessedMarker 已处理的池 JSON 文件（data/state/processed_files.json: 路径 -> 标记）
+type ProcessedMarker struct {
+	Digest      string `json:"digest"` // 文件内容 sha256，同路径内容变化视为新信号
+	ProcessedAt string `json:"processedAt"`
+	Outcome     string `json:"outcome"`
+}
+
+var (
+	processedMutex sync.Mutex
+	processedMarks = map[string]ProcessedMarker{}
+)
+
+// loadProcessedMarkers 启动时加载已处理标记，清理过期记录
+func loadProcessedMarkers() {
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	marks := map[string]ProcessedMarker{}
+	if err := loadStateFile("processed_files", &marks); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	cutoff := time.Now().Add(-processedRetention)
+	interrupted := 0
+	for path, m := range marks {
+		if t, err := time.Parse(time.RFC3339, m.ProcessedAt); err == nil && t.Before(cutoff) {
+			delete(marks, path)
+			continue
+		}
+		// 上次运行中断时可能已提交开仓交易，为避免重复加仓不再自动重试
+		if m.Outcome == outcomeProcessing {
+			interrupted++
+			logWarn("⚠️ 上次运行处理中断的池文件，不再自动重试，请人工核对", "file", path, "since", m.ProcessedAt)
+		}
+	}
+	processedMarks = marks
+	logOutput("📋 已加载 %d 条已处理文件标记（中断 %d 条）\n", len(marks), interrupted)
+}
+
+func fileDigest(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// 调用方需持有 processedMutex
+func saveProcessedMarkersLocked() {
+	if err := saveStateFile("processed_files", processedMarks); err != nil {
+		logOutput("❌ 保存已处理文件标记失败: %v\n", err)
+	}
+}
+
+// claimProcessed 文件未处理过（或内容已变化）时记为处理中并返回 true；已处理过返回 false
+func claimProcessed(path string) bool {
+	digest := fileDigest(path)
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	if processedLocked(path, digest) {
+		return false
+	}
+	processedMarks[path] = ProcessedMarker{Digest: digest, ProcessedAt: time.Now().Format(time.RFC3339), Outcome: outcomeProcessing}
+	saveProcessedMarkersLocked()
+	return true
+}
+
+// isProcessed 文件是否已有处理标记（内容未变化）
+func isProcessed(path string) bool {
+	digest := fileDigest(path)
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	return processedLocked(path, digest)
+}
+
+// 调用方需持有 processedMutex
+func processedLocked(path, digest string) bool {
+	m, ok := processedMarks[path]
+	return ok && (digest == "" || m.Digest == digest)
+}
+
+// markProcessed 记录处理结果
+func markProcessed(path, outcome string) {
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	m := processedMarks[path]
+	m.ProcessedAt = time.Now().Format(time.RFC3339)
+	m.Outcome = outcome
+	processedMarks[path] = m
+	saveProcessedMarkersLocked()
+}
+
+// processedOutcome 文件最近一次的处理结果（没有标记时为空）
+func processedOutcome(path string) string {
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	return processedMarks[path].Outcome
+}
+
+// requeueProcessed 处理结果为 outcome（等待额度、等待入场条件）的文件重新记为处理中，返回 true 表示调用方应重新加入队列
+func requeueProcessed(path, outcome string) bool {
+	digest := fileDigest(path)
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	m, ok := processedMarks[path]
+	if !ok || m.Outcome != outcome || digest == "" {
+		return false
+	}
+	processedMarks[path] = ProcessedMarker{Digest: digest, ProcessedAt: time.Now().Format(time.RFC3339), Outcome: outcomeProcessing}
+	saveProcessedMarkersLocked()
+	return true
+}
+
+// releaseProcessed 撤销处理中标记（任务未开始执行即被丢弃），下次启动时补处理
+func releaseProcessed(path string) {
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	if m, ok := processedMarks[path]; ok && m.Outcome == outcomeProcessing {
+		delete(processedMarks, path)
+		saveProcessedMarkersLocked()
+	}
+}
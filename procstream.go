@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ExecOutputConfig 外部命令输出的处理：逐行写入日志、空闲超时与保留上限
+type ExecOutputConfig struct {
+	Stream             bool `json:"stream"`             // 逐行写入日志（带目标与任务编号）；关闭时命令结束后一次性输出
+	LineTimeoutSeconds int  `json:"lineTimeoutSeconds"` // 超过该时长没有新的输出行即终止命令，0 表示不限制（整体超时仍由调用方控制）
+	MaxBytes           int  `json:"maxBytes"`           // 每次执行保留的输出上限，超出时保留开头、结尾与全部结构化事件行
+}
+
+// 单行上限：超过时按已读内容切分为一行
+const maxOutputLineBytes = 64 * 1024
+
+// errOutputIdle 超过 lineTimeoutSeconds 没有输出
+var errOutputIdle = errors.New("输出空闲超时")
+
+// 日志中区分并发命令的编号
+var outputSeq atomic.Int64
+
+func (c ExecOutputConfig) validate() error {
+	if c.LineTimeoutSeconds < 0 {
+		return fmt.Errorf("exec.output.lineTimeoutSeconds 不能为负数")
+	}
+	if c.MaxBytes < 4096 {
+		return fmt.Errorf("exec.output.maxBytes 不能小于 4096")
+	}
+	return nil
+}
+
+// outputStreamed 外部命令的输出是否已逐行写入日志（调用方不再整体输出；演示模式的模拟输出不经过子进程）
+func outputStreamed() bool {
+	return currentConfig().Exec.Output.Stream && !isDemo()
+}
+
+// logCommandOutput 命令结束后输出全部内容（已逐行输出时跳过）
+func logCommandOutput(out []byte) {
+	if outputStreamed() {
+		return
+	}
+	logOutput("%s", string(out))
+}
+
+// outputCollector 按行接收子进程的 stdout/stderr：逐行写日志、记录最近一行的时间，并按上限保留内容
+type outputCollector struct {
+	tag    string
+	stream bool
+	limit  int
+
+	mu       sync.Mutex
+	partial  []byte
+	head     bytes.Buffer // 开头（上限的一半）
+	events   bytes.Buffer // 从中间丢弃的部分里保留下来的事件行
+	tail     [][]byte     // 结尾的行（合计不超过上限的一半）
+	tailSize int
+	dropped  int
+	lastLine atomic.Int64 // 最近一次收到输出的时间（UnixNano）
+}
+
+func newOutputCollector(target string) *outputCollector {
+	cfg := currentConfig().Exec.Output
+	c := &outputCollector{tag: fmt.Sprintf("%s#%d", target, outputSeq.Add(1)), stream: cfg.Stream, limit: cfg.MaxBytes}
+	c.lastLine.Store(time.Now().UnixNano())
+	return c
+}
+
+func (c *outputCollector) Write(p []byte) (int, error) {
+	c.lastLine.Store(time.Now().UnixNano())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partial = append(c.partial, p...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			if len(c.partial) >= maxOutputLineBytes {
+				c.addLine(c.partial)
+				c.partial = nil
+			}
+			return len(p), nil
+		}
+		c.addLine(c.partial[:i+1])
+		c.partial = c.partial[i+1:]
+	}
+}
+
+// addLine 写日志并保留一行（含换行符）
+func (c *outputCollector) addLine(line []byte) {
+	if c.stream {
+		if text := string(bytes.TrimRight(line, "\r\n")); text != "" {
+			logOutput("[%s] %s\n", c.tag, text)
+		}
+	}
+	if c.head.Len()+len(line) <= c.limit/2 && len(c.tail) == 0 {
+		c.head.Write(line)
+		return
+	}
+	kept := append([]byte(nil), line...)
+	c.tail = append(c.tail, kept)
+	c.tailSize += len(kept)
+	for c.tailSize > c.limit/2 && len(c.tail) > 1 {
+		old := c.tail[0]
+		c.tail = c.tail[1:]
+		c.tailSize -= len(old)
+		if bytes.HasPrefix(bytes.TrimSpace(old), []byte(scriptEventPrefix)) {
+			c.events.Write(old)
+		} else {
+			c.dropped += len(old)
+		}
+	}
+}
+
+// output 保留的输出：开头、省略说明、中间的事件行与结尾
+func (c *outputCollector) output() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.partial) > 0 {
+		c.addLine(c.partial)
+		c.partial = nil
+	}
+	var out bytes.Buffer
+	out.Write(c.head.Bytes())
+	if c.dropped > 0 {
+		out.WriteString("... [输出过长，省略 " + strconv.Itoa(c.dropped) + " 字节] ...\n")
+	}
+	out.Write(c.events.Bytes())
+	for _, line := range c.tail {
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+func (c *outputCollector) idle() time.Duration {
+	return time.Since(time.Unix(0, c.lastLine.Load()))
+}
+
+// runCommand 执行子进程：stdout 与 stderr 合并后逐行处理（同 CombinedOutput 的交错顺序），
+// 超过 lineTimeoutSeconds 没有输出时终止整个进程组
+func runCommand(cmd *exec.Cmd, target string) ([]byte, error) {
+	c := newOutputCollector(target)
+	cmd.Stdout = c
+	cmd.Stderr = c
+	// 孙进程继承输出管道且不退出时，不无限等待管道关闭
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(currentConfig().Exec.Output.LineTimeoutSeconds) * time.Second
+	var idleKilled atomic.Bool
+	stop := make(chan struct{})
+	if timeout > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if c.idle() > timeout {
+						idleKilled.Store(true)
+						logWarn("⏰ 外部命令长时间没有输出，终止", "target", target, "tag", c.tag, "idle", timeout)
+						syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+						return
+					}
+				}
+			}
+		}()
+	}
+	err := cmd.Wait()
+	close(stop)
+	if idleKilled.Load() {
+		err = fmt.Errorf("%w: %d 秒没有新的输出 (%v)", errOutputIdle, currentConfig().Exec.Output.LineTimeoutSeconds, err)
+	}
+	return c.output(), err
+}
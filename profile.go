@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProfileConfig 命名参数档位：打包开仓金额、滑点、领取频率与信号过滤阈值，可通过 API 运行时切换
+type ProfileConfig struct {
+	SolAmount            float64            `json:"solAmount"`            // 单次开仓投入 SOL（0 表示使用 SOL_AMOUNT；启用阶梯仓位时以档位金额为准）
+	SlippagePct          float64            `json:"slippagePct"`          // addLiquidity.ts 滑点百分比（0 表示脚本默认 0.1）
+	SwapMaxFee           int64              `json:"swapMaxFee"`           // jupSwap -maxfee（lamports，0 表示默认 500000）
+	ClaimIntervalSeconds int                `json:"claimIntervalSeconds"` // 两轮全局领取的最小间隔（0 表示按 schedules.claim 每次都领取）
+	MinFields            map[string]float64 `json:"minFields"`            // CSV 字段下限，低于该值或无法解析的信号不入场
+}
+
+// ProfileState 当前生效的档位（data/state/profile.json，重启后保持）
+type ProfileState struct {
+	Name      string `json:"name"`
+	ChangedAt string `json:"changedAt,omitempty"`
+}
+
+// ProfileStatus 对外输出的档位信息
+type ProfileStatus struct {
+	Active    string                   `json:"active"`
+	ChangedAt string                   `json:"changedAt,omitempty"`
+	Profiles  map[string]ProfileConfig `json:"profiles"`
+}
+
+var (
+	profileMutex  sync.Mutex
+	profileState  ProfileState
+	lastClaimTime time.Time // 最近一轮全局领取的开始时间（按档位间隔节流）
+)
+
+// 内置档位（金额均为 0，沿用 SOL_AMOUNT；可在配置文件中按名覆盖或新增）
+func defaultProfiles() map[string]ProfileConfig {
+	return map[string]ProfileConfig{
+		"conservative": {SlippagePct: 0.05, ClaimIntervalSeconds: 120},
+		"normal":       {},
+		"aggressive":   {SlippagePct: 0.5, SwapMaxFee: 1000000},
+	}
+}
+
+func (p ProfileConfig) validate(name string) error {
+	if p.SolAmount < 0 || p.SlippagePct < 0 || p.SwapMaxFee < 0 || p.ClaimIntervalSeconds < 0 {
+		return fmt.Errorf("profiles.%s 的取值不能为负数", name)
+	}
+	if p.SlippagePct >= 100 {
+		return fmt.Errorf("profiles.%s.slippagePct 必须小于100", name)
+	}
+	return nil
+}
+
+func validateProfiles(c *Config) error {
+	for name, p := range c.Profiles {
+		if name == "" || strings.ContainsAny(name, " /\\.") {
+			return fmt.Errorf("profiles 档位名非法: %q", name)
+		}
+		if err := p.validate(name); err != nil {
+			return err
+		}
+	}
+	if c.Profile != "" {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			return fmt.Errorf("profile 引用了不存在的档位: %s", c.Profile)
+		}
+	}
+	return nil
+}
+
+// 启动时恢复上次通过 API 切换的档位（档位已从配置中删除时使用配置的 profile）
+func loadProfileState() {
+	var st ProfileState
+	if err := loadStateFile("profile", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	if _, ok := currentConfig().Profiles[st.Name]; ok && st.Name != "" {
+		profileState = st
+	} else {
+		profileState = ProfileState{Name: currentConfig().Profile}
+	}
+	if profileState.Name != "" {
+		logOutput("🎚️ 当前参数档位: %s\n", profileState.Name)
+	}
+}
+
+// 当前档位名与参数（未启用档位时返回空名与零值）
+func activeProfile() (string, ProfileConfig) {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	return profileState.Name, currentConfig().Profiles[profileState.Name]
+}
+
+func activeProfileName() string {
+	name, _ := activeProfile()
+	return name
+}
+
+func poolProfileName(poolAddress string) string {
+	name, _ := poolProfile(poolAddress)
+	return name
+}
+
+// 运行时切换档位并持久化
+func setActiveProfile(name string) error {
+	if _, ok := currentConfig().Profiles[name]; !ok {
+		return fmt.Errorf("档位不存在: %s", name)
+	}
+	profileMutex.Lock()
+	old := profileState.Name
+	profileState = ProfileState{Name: name, ChangedAt: time.Now().Format(time.RFC3339)}
+	st := profileState
+	profileMutex.Unlock()
+	if err := saveStateFile("profile", st); err != nil {
+		logOutput("❌ 保存参数档位失败: %v\n", err)
+	}
+	logInfo("🎚️ 参数档位已切换", "from", old, "to", name)
+	return nil
+}
+
+func currentProfileStatus() ProfileStatus {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	return ProfileStatus{Active: profileState.Name, ChangedAt: profileState.ChangedAt, Profiles: currentConfig().Profiles}
+}
+
+// 池开仓档位对应的 addLiquidity.ts 参数（阶梯仓位的金额由档位配置决定，不追加 --sol-amount）
+func profileAddLiquidityArgs(poolAddress string) []string {
+	_, p := poolProfile(poolAddress)
+	if amount, ok := poolOverrideSolAmount(poolAddress); ok {
+		p.SolAmount = amount
+	}
+	var args []string
+	if p.SolAmount > 0 && !ladderEnabled() {
+		args = append(args, fmt.Sprintf("--sol-amount=%s", strconv.FormatFloat(p.SolAmount, 'f', -1, 64)))
+	}
+	if p.SlippagePct > 0 {
+		args = append(args, fmt.Sprintf("--slippage=%s", strconv.FormatFloat(p.SlippagePct, 'f', -1, 64)))
+	}
+	return args
+}
+
+// jupSwap 的 -maxfee 取值
+func profileSwapMaxFee() string {
+	if _, p := activeProfile(); p.SwapMaxFee > 0 {
+		return strconv.FormatInt(p.SwapMaxFee, 10)
+	}
+	return "500000"
+}
+
+// 按档位的领取间隔节流全局领取，返回 false 表示本轮跳过
+func profileClaimDue() bool {
+	_, p := activeProfile()
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	if p.ClaimIntervalSeconds > 0 && time.Since(lastClaimTime) < time.Duration(p.ClaimIntervalSeconds)*time.Second {
+		return false
+	}
+	lastClaimTime = time.Now()
+	return true
+}
+
+// profileClaimWindow 档位的领取间隔与最近一轮全局领取的开始时间，用于预测后续轮次
+func profileClaimWindow() (time.Duration, time.Time) {
+	_, p := activeProfile()
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	return time.Duration(p.ClaimIntervalSeconds) * time.Second, lastClaimTime
+}
+
+// 按档位的字段下限过滤信号，返回未通过的字段（全部通过时为空）
+func profileFilterSignal(data map[string]interface{}) string {
+	_, p := activeProfile()
+	fields := make([]string, 0, len(p.MinFields))
+	for field := range p.MinFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		s, _ := data[field].(string)
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || v < p.MinFields[field] {
+			return field
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 速率保护统计的动作
+const (
+	rateOpen = "open" // 开仓（含阶梯档位）
+	rateSwap = "swap"
+)
+
+// RateGuardConfig 每小时自动化动作上限，超出即暂停自动化并告警（防止上游 CSV 失控或逻辑错误）
+type RateGuardConfig struct {
+	Enabled               bool    `json:"enabled"`
+	MaxPositionsPerHour   int     `json:"maxPositionsPerHour"`   // 0 表示不限制
+	MaxSwapsPerHour       int     `json:"maxSwapsPerHour"`       // 0 表示不限制
+	MaxSOLDeployedPerHour float64 `json:"maxSolDeployedPerHour"` // 0 表示不限制
+}
+
+// RateEvent 一次计入速率保护的动作（data/state/rate_guard.json）
+type RateEvent struct {
+	Kind string  `json:"kind"`
+	At   string  `json:"at"`
+	SOL  float64 `json:"sol,omitempty"`
+}
+
+// RateGuardStatus 最近一小时的统计
+type RateGuardStatus struct {
+	Enabled     bool            `json:"enabled"`
+	Positions   int             `json:"positions"`
+	Swaps       int             `json:"swaps"`
+	SOLDeployed float64         `json:"solDeployed"`
+	Limits      RateGuardConfig `json:"limits"`
+}
+
+var rateGuardMutex sync.Mutex
+
+func (c RateGuardConfig) validate() error {
+	if c.MaxPositionsPerHour < 0 || c.MaxSwapsPerHour < 0 || c.MaxSOLDeployedPerHour < 0 {
+		return fmt.Errorf("rateGuard 的上限不能为负数")
+	}
+	return nil
+}
+
+// 读取最近一小时的动作（调用方持有 rateGuardMutex）
+func loadRateEvents() []RateEvent {
+	var events []RateEvent
+	if err := loadStateFile("rate_guard", &events); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	cutoff := time.Now().Add(-time.Hour)
+	kept := events[:0]
+	for _, e := range events {
+		if at, err := time.Parse(time.RFC3339, e.At); err == nil && at.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func summarizeRateEvents(events []RateEvent) RateGuardStatus {
+	s := RateGuardStatus{Enabled: currentConfig().RateGuard.Enabled, Limits: currentConfig().RateGuard}
+	for _, e := range events {
+		switch e.Kind {
+		case rateOpen:
+			s.Positions++
+			s.SOLDeployed += e.SOL
+		case rateSwap:
+			s.Swaps++
+		}
+	}
+	return s
+}
+
+// 超出上限：暂停自动化并告警
+func tripRateGuard(kind, detail string) {
+	first := !isPaused()
+	setPaused(true)
+	if !first {
+		return
+	}
+	logError("🛑 超出自动化速率上限，已暂停自动化", "kind", kind, "detail", detail)
+	notifyKeyed(eventRateGuard, levelCritical, kind, "超出自动化速率上限，已暂停",
+		detail+"。确认无误后通过 POST /resume 恢复", map[string]string{"kind": kind})
+}
+
+// rateGuardAllow 执行动作前检查最近一小时的次数与投入 SOL，超出时暂停自动化并返回 false
+func rateGuardAllow(kind string) bool {
+	cfg := currentConfig().RateGuard
+	if !cfg.Enabled {
+		return true
+	}
+	rateGuardMutex.Lock()
+	s := summarizeRateEvents(loadRateEvents())
+	rateGuardMutex.Unlock()
+
+	detail := ""
+	switch kind {
+	case rateOpen:
+		if cfg.MaxPositionsPerHour > 0 && s.Positions >= cfg.MaxPositionsPerHour {
+			detail = fmt.Sprintf("最近一小时已开仓 %d 次（上限 %d）", s.Positions, cfg.MaxPositionsPerHour)
+		} else if cfg.MaxSOLDeployedPerHour > 0 && s.SOLDeployed >= cfg.MaxSOLDeployedPerHour {
+			detail = fmt.Sprintf("最近一小时已投入 %.4f SOL（上限 %g）", s.SOLDeployed, cfg.MaxSOLDeployedPerHour)
+		}
+	case rateSwap:
+		if cfg.MaxSwapsPerHour > 0 && s.Swaps >= cfg.MaxSwapsPerHour {
+			detail = fmt.Sprintf("最近一小时已兑换 %d 次（上限 %d）", s.Swaps, cfg.MaxSwapsPerHour)
+		}
+	}
+	if detail == "" {
+		return true
+	}
+	tripRateGuard(kind, detail)
+	return false
+}
+
+// 记录一次动作；投入 SOL 累计超出上限时立即暂停，阻止后续开仓
+func recordRateEvent(kind string, sol float64) {
+	cfg := currentConfig().RateGuard
+	if !cfg.Enabled {
+		return
+	}
+	rateGuardMutex.Lock()
+	events := append(loadRateEvents(), RateEvent{Kind: kind, At: time.Now().Format(time.RFC3339), SOL: sol})
+	if err := saveStateFile("rate_guard", events); err != nil {
+		logOutput("❌ 保存速率保护状态失败: %v\n", err)
+	}
+	s := summarizeRateEvents(events)
+	rateGuardMutex.Unlock()
+
+	if kind == rateOpen && cfg.MaxSOLDeployedPerHour > 0 && s.SOLDeployed > cfg.MaxSOLDeployedPerHour {
+		tripRateGuard(kind, fmt.Sprintf("最近一小时已投入 %.4f SOL（上限 %g）", s.SOLDeployed, cfg.MaxSOLDeployedPerHour))
+	}
+}
+
+// 最近一小时的统计
+func currentRateGuardStatus() RateGuardStatus {
+	rateGuardMutex.Lock()
+	defer rateGuardMutex.Unlock()
+	return summarizeRateEvents(loadRateEvents())
+}
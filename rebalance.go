@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RebalanceConfig 价格离开仓位 bin 范围时自动再平衡：移除流动性、可选兑换，再围绕池当前 active bin 重新开仓
+type RebalanceConfig struct {
+	Enabled              bool    `json:"enabled"`
+	IntervalSeconds      int     `json:"intervalSeconds"`      // 检查间隔（读取池的链上 active bin）
+	Side                 string  `json:"side"`                 // 触发方向：above / below / both
+	MinOutOfRangeMinutes float64 `json:"minOutOfRangeMinutes"` // 持续超出范围该时长后才再平衡，避免来回穿越
+	CooldownMinutes      float64 `json:"cooldownMinutes"`      // 同一池两次再平衡的最小间隔
+	Swap                 bool    `json:"swap"`                 // 移除后兑换代币为 SOL（removeLiquidity.ts 内的 jupSwap），恢复开仓所需的 SOL 比例
+	RangePct             float64 `json:"rangePct"`             // 重新开仓的范围宽度（同 --range-pct），0 表示按波动率或脚本默认
+}
+
+// 平仓原因：再平衡
+const exitReasonRebalance = "rebalance"
+
+// RebalanceRecord 池的再平衡记录（data/state/rebalances.json: pool -> 记录）
+type RebalanceRecord struct {
+	PoolAddress string `json:"poolAddress"`
+	Count       int    `json:"count"`
+	LastAt      string `json:"lastAt"`
+	LastSide    string `json:"lastSide"`
+	LastResult  string `json:"lastResult"` // success / remove_failed / reopen_failed
+	OldPosition string `json:"oldPosition,omitempty"`
+	NewPosition string `json:"newPosition,omitempty"`
+	ActiveID    int    `json:"activeId"` // 触发时池的 active bin
+	Detail      string `json:"detail,omitempty"`
+}
+
+var (
+	rebalanceMutex    sync.Mutex
+	rebalanceOutSince = map[string]time.Time{} // 池首次观察到超出范围的时间
+)
+
+func (c RebalanceConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("rebalance.intervalSeconds 必须大于0")
+	}
+	if c.Side != "above" && c.Side != "below" && c.Side != "both" {
+		return fmt.Errorf("rebalance.side 仅支持 above、below 或 both")
+	}
+	if c.MinOutOfRangeMinutes < 0 || c.CooldownMinutes < 0 || c.RangePct < 0 {
+		return fmt.Errorf("rebalance.minOutOfRangeMinutes、cooldownMinutes、rangePct 不能为负数")
+	}
+	return nil
+}
+
+// 池当前的 active bin（读取 LbPair 账户）
+func poolActiveBin(ctx context.Context, poolAddress string) (int, error) {
+	data, err := accountData(ctx, poolAddress)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < lbPairMinLength {
+		return 0, fmt.Errorf("LbPair 账户数据长度不足: %d", len(data))
+	}
+	return int(int32(binary.LittleEndian.Uint32(data[lbPairActiveIDOffset:]))), nil
+}
+
+func loadRebalanceRecords() map[string]*RebalanceRecord {
+	records := map[string]*RebalanceRecord{}
+	if err := loadStateFile("rebalances", &records); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return records
+}
+
+func updateRebalanceRecord(poolAddress string, fn func(r *RebalanceRecord)) {
+	rebalanceMutex.Lock()
+	defer rebalanceMutex.Unlock()
+	records := loadRebalanceRecords()
+	r := records[poolAddress]
+	if r == nil {
+		r = &RebalanceRecord{PoolAddress: poolAddress}
+		records[poolAddress] = r
+	}
+	fn(r)
+	if err := saveStateFile("rebalances", records); err != nil {
+		logOutput("⚠️ 保存再平衡记录失败: %v\n", err)
+	}
+}
+
+// listRebalanceRecords 各池的再平衡记录（GET /rebalances）
+func listRebalanceRecords() []*RebalanceRecord {
+	rebalanceMutex.Lock()
+	records := loadRebalanceRecords()
+	rebalanceMutex.Unlock()
+	result := make([]*RebalanceRecord, 0, len(records))
+	for _, r := range records {
+		result = append(result, r)
+	}
+	return result
+}
+
+// 距上次再平衡是否已过冷却时间
+func rebalanceCooledDown(poolAddress string) bool {
+	rebalanceMutex.Lock()
+	r := loadRebalanceRecords()[poolAddress]
+	rebalanceMutex.Unlock()
+	if r == nil {
+		return true
+	}
+	last, err := time.Parse(time.RFC3339, r.LastAt)
+	return err != nil || time.Since(last) >= time.Duration(currentConfig().Rebalance.CooldownMinutes*float64(time.Minute))
+}
+
+// startRebalancer 定期对比各实盘仓位的 bin 范围与池当前 active bin（演示模式没有链上仓位，不启动）
+func startRebalancer() {
+	cfg := currentConfig().Rebalance
+	if !cfg.Enabled || isDemo() {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("⚖️ 启动仓位再平衡检查（每%v，方向 %s）\n", interval, cfg.Side)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止仓位再平衡检查\n")
+			return
+		case <-ticker.C:
+			checkRebalances()
+		}
+	}
+}
+
+// 检查所有实盘未平仓的仓位（模拟池与阶梯仓位组不参与）
+func checkRebalances() {
+	// 停止开关开启时不再平衡（平仓后需要重新开仓）
+	if isPaused() || isPriceOnly() || shuttingDown() || dataVolumeUnavailable() || killSwitchActive() {
+		return
+	}
+	for _, r := range listPositionRecords() {
+		if globalCtx.Err() != nil {
+			return
+		}
+		if r.State == positionStateClosed || r.Mode == poolModePaper || openPositionGroup(r.PoolAddress) != nil {
+			continue
+		}
+		rng := readOpenRangeFromPoolJSON(r.PoolAddress)
+		if rng == nil || readPositionFromPoolJSON(r.PoolAddress) == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(globalCtx, 10*time.Second)
+		activeID, err := poolActiveBin(ctx, r.PoolAddress)
+		cancel()
+		if err != nil {
+			logDebug("读取池 active bin 失败", "pool", r.PoolAddress, "error", err)
+			continue
+		}
+		checkRebalanceAt(r.PoolAddress, r.TokenAddress, rng, activeID)
+	}
+}
+
+// checkRebalanceAt 按池当前 active bin 判断仓位是否超出范围，持续超出且已过冷却时加入再平衡
+// （定期检查与账户订阅的 LbPair 变化共用）
+func checkRebalanceAt(poolAddress, ca string, rng *OpenRange, activeID int) {
+	cfg := currentConfig().Rebalance
+	side := ""
+	switch {
+	case activeID > rng.MaxBinID:
+		side = "above"
+	case activeID < rng.MinBinID:
+		side = "below"
+	}
+
+	rebalanceMutex.Lock()
+	since, seen := rebalanceOutSince[poolAddress]
+	if side == "" || (cfg.Side != "both" && cfg.Side != side) {
+		delete(rebalanceOutSince, poolAddress)
+		rebalanceMutex.Unlock()
+		return
+	}
+	if !seen {
+		since = time.Now()
+		rebalanceOutSince[poolAddress] = since
+	}
+	rebalanceMutex.Unlock()
+
+	if time.Since(since) < time.Duration(cfg.MinOutOfRangeMinutes*float64(time.Minute)) || !rebalanceCooledDown(poolAddress) {
+		return
+	}
+	logOutput("⚖️ 价格超出仓位范围 (%s，active bin %d，范围 %d~%d)，加入再平衡: pool=%s\n", side, activeID, rng.MinBinID, rng.MaxBinID, poolAddress)
+	enqueueRebalance(poolAddress, ca, side, activeID)
+}
+
+// enqueueRebalance 再平衡占用开仓并发（与同一池的池文件任务去重）
+func enqueueRebalance(poolAddress, ca, side string, activeID int) {
+	enqueueJob(&QueuedJob{
+		Type: jobAddLiquidity,
+		Key:  filepath.Join(poolDataDir(), poolAddress+".json"),
+		Run: func() error {
+			rebalancePosition(poolAddress, ca, side, activeID)
+			return nil
+		},
+	})
+}
+
+// rebalancePosition 移除流动性（保留池 JSON，按配置兑换），清除旧仓位后围绕当前 active bin 重新开仓
+func rebalancePosition(poolAddress, ca, side string, activeID int) {
+	if _, busy := closingPools.LoadOrStore(poolAddress, true); busy {
+		return
+	}
+	defer closingPools.Delete(poolAddress)
+	rebalanceMutex.Lock()
+	delete(rebalanceOutSince, poolAddress)
+	rebalanceMutex.Unlock()
+
+	oldPosition := readPositionFromPoolJSON(poolAddress)
+	if oldPosition == "" {
+		return
+	}
+	record := func(result, newPosition, detail string) {
+		updateRebalanceRecord(poolAddress, func(r *RebalanceRecord) {
+			r.Count++
+			r.LastAt = time.Now().Format(time.RFC3339)
+			r.LastSide, r.LastResult, r.ActiveID = side, result, activeID
+			r.OldPosition, r.NewPosition, r.Detail = oldPosition, newPosition, detail
+		})
+		metricRebalances.Inc(result)
+	}
+	fields := map[string]string{"pool": poolAddress, "ca": ca, "side": side, "activeId": strconv.Itoa(activeID)}
+
+	args := []string{"--keep-json"}
+	if !currentConfig().Rebalance.Swap {
+		args = append(args, "--skipSwap")
+	}
+	logOutput("⚖️ 再平衡：移除流动性 pool=%s position=%s\n", poolAddress, oldPosition)
+	if !runRemoveLiquidity(poolAddress, oldPosition, args...) {
+		record("remove_failed", "", "移除流动性失败")
+		notifyKeyed(eventRebalance, levelWarning, poolAddress, "再平衡移除流动性失败", "", fields)
+		return
+	}
+	markPositionClosed(poolAddress, exitReasonRebalance)
+	if err := clearPositionFromPoolJSON(poolAddress); err != nil {
+		record("reopen_failed", "", err.Error())
+		notifyKeyed(eventRebalance, levelCritical, poolAddress, "再平衡重新开仓失败", err.Error(), fields)
+		return
+	}
+
+	newPosition, err := reopenPosition(poolAddress, ca)
+	if err != nil {
+		record("reopen_failed", "", err.Error())
+		logError("❌ 再平衡重新开仓失败", "pool", poolAddress, "error", err)
+		notifyKeyed(eventRebalance, levelCritical, poolAddress, "再平衡重新开仓失败", err.Error(), fields)
+		return
+	}
+	record("success", newPosition, "")
+	logInfo("✅ 再平衡完成", "pool", poolAddress, "oldPosition", oldPosition, "newPosition", newPosition)
+	fields["position"] = newPosition
+	notifyKeyed(eventRebalance, levelInfo, poolAddress, "仓位已再平衡", "", fields)
+}
+
+// 清除池 JSON 中的旧仓位地址与开仓范围（addLiquidity.ts 会复用已有的 positionAddress）
+func clearPositionFromPoolJSON(poolAddress string) error {
+	path := filepath.Join(poolDataDir(), poolAddress+".json")
+	err := updateJSONFile(path, func(obj map[string]interface{}) (bool, error) {
+		delete(obj, "positionAddress")
+		delete(obj, "range")
+		if data, ok := obj["data"].(map[string]interface{}); ok {
+			delete(data, "positionAddress")
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("更新池文件失败: %v", err)
+	}
+	return nil
+}
+
+// reopenPosition 以当前参数档位围绕池当前 active bin 重新开仓（沿用池已分配的钱包），返回新仓位地址
+func reopenPosition(poolAddress, ca string) (string, error) {
+	if !rateGuardAllow(rateOpen) {
+		return "", fmt.Errorf("超出速率上限")
+	}
+	args := []string{fmt.Sprintf("--pool=%s", poolAddress)}
+	if ca != "" {
+		args = append(args, fmt.Sprintf("--token=%s", ca))
+	}
+	pct := currentConfig().Rebalance.RangePct
+	if pct <= 0 {
+		pct = volatilityRangePct(ca)
+	}
+	if pct > 0 {
+		args = append(args, fmt.Sprintf("--range-pct=%s", strconv.FormatFloat(pct, 'f', 2, 64)))
+	}
+	args = append(args, liquidityArgs(poolAddress, readSignalDataFromPoolJSON(poolAddress))...)
+	args = append(args, profileAddLiquidityArgs(poolAddress)...)
+	args = append(args, priorityFeeArgs(feeOpAddLiquidity)...)
+
+	ctx, cancel := context.WithTimeout(globalCtx, 5*time.Minute)
+	defer cancel()
+	dex := poolDex(poolAddress)
+	logOutput("🚀 再平衡重新开仓: %s\n", strings.Join(dex.CommandLine(dexOpAddLiquidity, args...), " "))
+	output, err := dex.AddLiquidity(withPoolWallet(ctx, poolAddress), args...)
+	metricAddLiquidity.Inc(resultLabel(err))
+	logCommandOutput(output)
+	if err != nil {
+		return "", err
+	}
+	position := readPositionFromPoolJSON(poolAddress)
+	if position == "" {
+		return "", fmt.Errorf("addLiquidity.ts 未写入新的仓位地址")
+	}
+	positionOpened(poolAddress, ca)
+	recordPnLDeposit(poolAddress, ca, position, mainDepositSOL(poolAddress))
+	recordRateEvent(rateOpen, mainDepositSOL(poolAddress))
+	return position, nil
+}
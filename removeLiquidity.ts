@@ -12,10 +12,16 @@ import bs58 from 'bs58';
 import CryptoJS from 'crypto-js';
 import fs from 'fs';
 import path from 'path';
-import { exec } from 'child_process';
-import { promisify } from 'util';
+import { DATA_DIR, poolFilePath, withFileLock } from './dataFile';
+import { execWithKey, pipedPrivateKey } from './signingKey';
 
-const execAsync = promisify(exec);
+// 程序目录（由 Go 调度程序通过环境变量传入；单独运行时为脚本所在目录）
+const BASE_DIR = process.env.METEORA_BASE_DIR || __dirname;
+
+// ===== 结构化输出（Go 端按 "@@event <json>" 行解析，见 scriptproto.go）=====
+function emitEvent(type: string, fields: Record<string, unknown> = {}): void {
+  console.log(`@@event ${JSON.stringify({ type, ...fields })}`);
+}
 
 // 加载环境变量
 dotenv.config();
@@ -136,12 +142,10 @@ async function executeJupSwap(ca: string): Promise<boolean> {
   try {
     console.log(`🔄 开始执行 jupSwap: ${ca}`);
     
-    const command = `./jupSwap -input ${ca} -maxfee 500000`;
+    const command = `./jupSwap -input ${ca} -maxfee ${getSwapMaxFeeFromArgs()}`;
     console.log(`执行命令: ${command}`);
     
-    const { stdout, stderr } = await execAsync(command, {
-      cwd: '/Users/yqw/meteora_dlmm'
-    });
+    const { stdout, stderr } = await execWithKey(command, BASE_DIR, true);
     
     if (stdout) {
       console.log('jupSwap 输出:', stdout);
@@ -172,7 +176,7 @@ async function executeJupSwap(ca: string): Promise<boolean> {
  */
 function readTokenContractAddressFromPoolJson(poolAddress: string): string | undefined {
   try {
-    const file = path.resolve(__dirname, 'data', `${poolAddress}.json`);
+    const file = poolFilePath(poolAddress);
     const raw = fs.readFileSync(file, 'utf8');
     const json = JSON.parse(raw);
     
@@ -199,14 +203,14 @@ function readTokenContractAddressFromPoolJson(poolAddress: string): string | und
 async function moveJsonToHistory(poolAddress: string): Promise<void> {
   try {
     // 确保history目录存在
-    const historyDir = path.resolve(__dirname, 'data', 'history');
+    const historyDir = path.join(DATA_DIR, 'history');
     if (!fs.existsSync(historyDir)) {
       fs.mkdirSync(historyDir, { recursive: true });
       console.log('📁 创建history目录:', historyDir);
     }
     
     // 源文件路径
-    const sourceFile = path.resolve(__dirname, 'data', `${poolAddress}.json`);
+    const sourceFile = poolFilePath(poolAddress);
     // 目标文件路径（添加时间戳避免重名）
     const timestamp = new Date().toISOString().replace(/[:.]/g, '-');
     const targetFile = path.resolve(historyDir, `${poolAddress}_${timestamp}.json`);
@@ -217,8 +221,8 @@ async function moveJsonToHistory(poolAddress: string): Promise<void> {
       return;
     }
     
-    // 移动文件
-    fs.renameSync(sourceFile, targetFile);
+    // 移动文件（持有池文件锁：正在读改写池文件的一方写完后再归档，其写回不会丢失）
+    withFileLock(sourceFile, () => fs.renameSync(sourceFile, targetFile));
     console.log('📦 JSON文件已移动到history目录:');
     console.log(`   源文件: ${sourceFile}`);
     console.log(`   目标文件: ${targetFile}`);
@@ -228,8 +232,23 @@ async function moveJsonToHistory(poolAddress: string): Promise<void> {
   }
 }
 
-// 连接配置
-const connection = new Connection(clusterApiUrl('mainnet-beta'), 'confirmed');
+// 连接配置（RPC_URL 由 main.go 按 RPC 节点池的当前最优节点传入，未设置时使用公共节点）
+const connection = new Connection(process.env.RPC_URL || clusterApiUrl('mainnet-beta'), 'confirmed');
+useCachedBlockhash(connection);
+
+// --blockhash=<hash>:<lastValidBlockHeight>：main.go 预取的区块哈希，传入时 SDK 构建交易不再请求 getLatestBlockhash
+function useCachedBlockhash(conn: Connection): void {
+  for (const arg of process.argv.slice(2)) {
+    if (!arg.startsWith('--blockhash=')) continue;
+    const [blockhash, height] = arg.slice('--blockhash='.length).replace(/['"]/g, '').trim().split(':');
+    const lastValidBlockHeight = Number(height);
+    if (!blockhash || !Number.isInteger(lastValidBlockHeight) || lastValidBlockHeight <= 0) {
+      throw new Error(`--blockhash 取值无效: ${arg}`);
+    }
+    conn.getLatestBlockhash = async () => ({ blockhash, lastValidBlockHeight });
+    return;
+  }
+}
 
 // 命令行参数解析
 const argv = process.argv.slice(2);
@@ -264,6 +283,42 @@ function getSkipSwapFromArgs(): boolean {
   return false;
 }
 
+// 从命令行参数中获取是否保留池配置JSON（阶梯档位逐个平仓时使用，由最后一个仓位负责归档）
+function getKeepJsonFromArgs(): boolean {
+  for (const arg of argv) {
+    if (arg === '--keep-json' || arg === '--keep-json=true') return true;
+  }
+  return false;
+}
+
+// --swap-maxfee=<lamports>：移除后 jupSwap 的 -maxfee，由 main.go 按优先费传入；默认 500000
+function getSwapMaxFeeFromArgs(): string {
+  for (const arg of argv) {
+    if (arg.startsWith('--swap-maxfee=')) {
+      const v = sanitizeString(arg.split('=')[1]);
+      if (/^\d+$/.test(v)) return v;
+    }
+  }
+  return '500000';
+}
+
+// 从命令行参数中获取移除比例（--percent=50 或 --bps=5000），默认 100%
+function getBpsFromArgs(): number {
+  for (const arg of argv) {
+    if (arg.startsWith('--bps=')) {
+      const bps = parseInt(sanitizeString(arg.split('=')[1]), 10);
+      if (Number.isFinite(bps) && bps > 0 && bps <= 10000) return bps;
+      throw new Error(`--bps 取值无效: ${arg}`);
+    }
+    if (arg.startsWith('--percent=')) {
+      const percent = parseFloat(sanitizeString(arg.split('=')[1]));
+      if (Number.isFinite(percent) && percent > 0 && percent <= 100) return Math.round(percent * 100);
+      throw new Error(`--percent 取值无效: ${arg}`);
+    }
+  }
+  return 10000;
+}
+
 /**
  * 解密私钥
  * @param encryptedPrivateKey 加密的私钥
@@ -339,8 +394,12 @@ async function removeLiquidity() {
     // 3. 创建用户密钥对
     let userKeypair: Keypair;
     
-    // 检查是否使用加密私钥
-    if (process.env.PRIVATE_KEY_ENCRYPTED === 'true') {
+    // 主程序经管道传入的私钥优先，否则检查是否使用加密私钥
+    const pipedKey = pipedPrivateKey();
+    if (pipedKey !== undefined) {
+      userKeypair = Keypair.fromSecretKey(bs58.decode(pipedKey));
+      console.log('✅ 已读取主程序传入的私钥');
+    } else if (process.env.PRIVATE_KEY_ENCRYPTED === 'true') {
       if (!process.env.PRIVATE_KEY_PASSWORD) {
         throw new Error('使用加密私钥时，必须设置PRIVATE_KEY_PASSWORD环境变量');
       }
@@ -358,6 +417,10 @@ async function removeLiquidity() {
       console.log('✅ 从环境变量加载钱包 (明文私钥)');
     }
     
+    // 部分移除时保留仓位，不领取关闭、不兑换、不归档
+    const bps = getBpsFromArgs();
+    const isPartial = bps < 10000;
+
     // 4. Bin范围设置
     const lowerBinId = -443636;  // 负无穷大 (Meteora DLMM 最小bin ID)
     const upperBinId = 443636;   // 正无穷大 (Meteora DLMM 最大bin ID)
@@ -367,6 +430,7 @@ async function removeLiquidity() {
     console.log('仓位地址:', positionPubKey.toString());
     console.log('池地址:', poolPubKey.toString());
     console.log('Bin范围:', `${lowerBinId} - ${upperBinId}`);
+    console.log('移除比例:', `${bps / 100}%`);
     
     // 5. 调用removeLiquidity方法 - 默认移除所有流动性
     let transactions;
@@ -376,8 +440,8 @@ async function removeLiquidity() {
         position: positionPubKey,              // 仓位公钥
         fromBinId: lowerBinId,                 // 下限bin ID
         toBinId: upperBinId,                   // 上限bin ID
-        bps: new BN(10000),                    // 移除100%流动性 (10000 BPS = 100%) - 默认移除所有流动性
-        shouldClaimAndClose: true,             // 领取奖励并关闭仓位 - 默认true
+        bps: new BN(bps),                      // 移除比例 (10000 BPS = 100%) - 默认移除所有流动性
+        shouldClaimAndClose: !isPartial,       // 全部移除时领取奖励并关闭仓位；部分移除保留仓位
         skipUnwrapSOL: false                   // 不解包SOL - 默认false
       }), 'dlmmPool.removeLiquidity');
     } catch (error) {
@@ -424,6 +488,7 @@ async function removeLiquidity() {
       
       const txHash = await withRetry(() => connection.sendTransaction(versionedTransaction), 'connection.sendTransaction');
       console.log(`交易 ${i + 1} 哈希:`, txHash);
+      emitEvent('signature', { signature: txHash, action: 'removeLiquidity' });
       
       // 等待确认
       await withRetry(() => connection.getSignatureStatus(txHash, { searchTransactionHistory: true }), 'connection.getSignatureStatus');
@@ -431,6 +496,12 @@ async function removeLiquidity() {
     }
     
     console.log('✅ 移除流动性完成');
+    emitEvent('status', { status: 'ok' });
+
+    if (isPartial) {
+      console.log(`✅ 部分移除完成（${bps / 100}%），仓位保持打开`);
+      return;
+    }
     
     // 可通过 --skipSwap 控制是否在移除后立即执行 jupSwap（默认执行）
     const skipSwap = getSkipSwapFromArgs();
@@ -456,10 +527,16 @@ async function removeLiquidity() {
       }
     }
 
+    if (getKeepJsonFromArgs()) {
+      console.log('⏭️ 检测到 --keep-json，保留池配置JSON文件');
+      return;
+    }
+
     await moveJsonToHistory(finalPoolAddress);
     
   } catch (error) {
     console.error('错误:', error);
+    emitEvent('error', { code: 'REMOVE_LIQUIDITY_FAILED', message: error instanceof Error ? error.message : String(error) });
   }
 }
 
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 风控平仓后兑换目标
+const (
+	swapToSOL  = "SOL"
+	swapToUSDC = "USDC"
+)
+
+// USDC mint
+const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+
+// 平仓原因：止损 / 止盈
+const (
+	exitReasonStopLoss   = "stop_loss"
+	exitReasonTakeProfit = "take_profit"
+)
+
+// RiskConfig 风控配置（每次价格更新时统一评估）
+type RiskConfig struct {
+	StopLoss   StopLossConfig   `json:"stopLoss"`
+	TakeProfit TakeProfitConfig `json:"takeProfit"`
+}
+
+// StopLossConfig 价格相对入场价回撤达到阈值时移除流动性并兑换
+type StopLossConfig struct {
+	Enabled        bool               `json:"enabled"`
+	DefaultPercent float64            `json:"defaultPercent"` // 默认止损回撤（%）
+	Pools          map[string]float64 `json:"pools"`          // 按池覆盖，<=0 表示该池不止损
+	SwapTo         string             `json:"swapTo"`         // SOL（默认，由 removeLiquidity.ts 内部兑换）或 USDC
+}
+
+// TakeProfitConfig 收益达到规则阈值时领取、移除流动性并兑换
+type TakeProfitConfig struct {
+	Enabled bool                        `json:"enabled"`
+	Rules   []TakeProfitRule            `json:"rules"`  // 全局规则
+	Pools   map[string][]TakeProfitRule `json:"pools"`  // 按池整体覆盖全局规则，空列表表示该池不止盈
+	SwapTo  string                      `json:"swapTo"` // SOL（默认）或 USDC
+}
+
+// TakeProfitRule 规则中非 0 的条件需同时满足
+type TakeProfitRule struct {
+	Name             string  `json:"name"`
+	PnLPercent       float64 `json:"pnlPercent"`       // 仓位价值（含已领取与未领取费用）相对投入 SOL 的收益率（%），取最近一次领取时的估值
+	PriceGainPercent float64 `json:"priceGainPercent"` // 代币价格相对入场价的涨幅（%）
+}
+
+func validateSwapTo(name, swapTo string) error {
+	if swapTo != swapToSOL && swapTo != swapToUSDC {
+		return fmt.Errorf("%s 仅支持 %s 或 %s", name, swapToSOL, swapToUSDC)
+	}
+	return nil
+}
+
+func (c StopLossConfig) validate() error {
+	if err := validateSwapTo("risk.stopLoss.swapTo", c.SwapTo); err != nil {
+		return err
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if c.DefaultPercent < 0 || c.DefaultPercent >= 100 {
+		return fmt.Errorf("risk.stopLoss.defaultPercent 必须在 [0, 100) 之间")
+	}
+	for pool, pct := range c.Pools {
+		if pct >= 100 {
+			return fmt.Errorf("risk.stopLoss.pools.%s 必须小于100", pool)
+		}
+	}
+	return nil
+}
+
+func (r TakeProfitRule) validate(name string) error {
+	if r.PnLPercent < 0 || r.PriceGainPercent < 0 {
+		return fmt.Errorf("%s 的阈值不能为负数", name)
+	}
+	if r.PnLPercent == 0 && r.PriceGainPercent == 0 {
+		return fmt.Errorf("%s 至少需要设置 pnlPercent 或 priceGainPercent", name)
+	}
+	return nil
+}
+
+func (r TakeProfitRule) key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("pnl%g_price%g", r.PnLPercent, r.PriceGainPercent)
+}
+
+func (c TakeProfitConfig) validate() error {
+	if err := validateSwapTo("risk.takeProfit.swapTo", c.SwapTo); err != nil {
+		return err
+	}
+	if !c.Enabled {
+		return nil
+	}
+	for i, r := range c.Rules {
+		if err := r.validate(fmt.Sprintf("risk.takeProfit.rules[%d]", i)); err != nil {
+			return err
+		}
+	}
+	for pool, rules := range c.Pools {
+		for i, r := range rules {
+			if err := r.validate(fmt.Sprintf("risk.takeProfit.pools.%s[%d]", pool, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c RiskConfig) validate() error {
+	if err := c.StopLoss.validate(); err != nil {
+		return err
+	}
+	return c.TakeProfit.validate()
+}
+
+// 风控平仓后是否兑换为 USDC（全局兑换时需保留 USDC 余额）
+func riskKeepsUSDC() bool {
+	return currentConfig().Risk.StopLoss.SwapTo == swapToUSDC || currentConfig().Risk.TakeProfit.SwapTo == swapToUSDC
+}
+
+// 池的止损阈值（%），0 表示不止损；池配置覆盖优先于 pools
+func (c StopLossConfig) percentFor(poolAddress string) float64 {
+	pct, ok := c.Pools[poolAddress]
+	if o := poolOverride(poolAddress); o != nil && o.StopLossPercent != nil {
+		pct, ok = *o.StopLossPercent, true
+	}
+	if ok {
+		if pct <= 0 {
+			return 0
+		}
+		return pct
+	}
+	return c.DefaultPercent
+}
+
+// 池适用的止盈规则
+func (c TakeProfitConfig) rulesFor(poolAddress string) []TakeProfitRule {
+	if rules, ok := c.Pools[poolAddress]; ok {
+		return rules
+	}
+	return c.Rules
+}
+
+// 入场价：优先使用生命周期记录的开仓价，其次为信号收盘价 c
+func riskEntryPrice(r *PositionRecord, poolAddress string) float64 {
+	if r != nil && r.EntryPrice > 0 {
+		return r.EntryPrice
+	}
+	return readEntryPriceFromPoolJSON(poolAddress)
+}
+
+// 价格回撤（%）
+func drawdownPercent(entry, price float64) float64 {
+	if entry <= 0 {
+		return 0
+	}
+	return (entry - price) / entry * 100
+}
+
+// 止损检查：返回告警正文，空字符串表示未触发
+func checkStopLoss(poolAddress string, entry, price float64) string {
+	cfg := currentConfig().Risk.StopLoss
+	if !cfg.Enabled {
+		return ""
+	}
+	threshold := cfg.percentFor(poolAddress)
+	drawdown := drawdownPercent(entry, price)
+	if threshold <= 0 || entry <= 0 || drawdown < threshold {
+		return ""
+	}
+	return fmt.Sprintf("回撤 %.2f%% ≥ %g%%", drawdown, threshold)
+}
+
+// 止盈检查：返回告警正文，空字符串表示未触发
+func checkTakeProfit(poolAddress string, r *PositionRecord, entry, price float64) string {
+	cfg := currentConfig().Risk.TakeProfit
+	if !cfg.Enabled {
+		return ""
+	}
+	priceGain := -drawdownPercent(entry, price)
+	for _, rule := range cfg.rulesFor(poolAddress) {
+		if rule.PriceGainPercent > 0 && (entry <= 0 || priceGain < rule.PriceGainPercent) {
+			continue
+		}
+		if rule.PnLPercent > 0 && (r == nil || r.SolAmount <= 0 || r.ValueSOL <= 0 || r.PnLPercent < rule.PnLPercent) {
+			continue
+		}
+		detail := fmt.Sprintf("规则 %s：价格涨幅 %.2f%%", rule.key(), priceGain)
+		if r != nil && r.ValueSOL > 0 {
+			detail += fmt.Sprintf("，仓位收益 %.2f%%", r.PnLPercent)
+		}
+		return detail
+	}
+	return ""
+}
+
+// evaluateRisk 在价格更新时依次检查止损与止盈，触发则领取并平仓，再按配置兑换为 SOL 或 USDC
+func evaluateRisk(poolAddress, tokenAddress, priceStr string) {
+	cfg := currentConfig().Risk
+	if !cfg.StopLoss.Enabled && !cfg.TakeProfit.Enabled {
+		return
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	if readPositionFromPoolJSON(poolAddress) == "" && !(isPaperPool(poolAddress) && paperHasOpenPosition(poolAddress)) {
+		return
+	}
+	lifecycleMutex.Lock()
+	r := loadPositionRecords()[poolAddress]
+	lifecycleMutex.Unlock()
+	if r != nil && r.State == positionStateClosed {
+		r = nil
+	}
+	entry := riskEntryPrice(r, poolAddress)
+
+	event, title, reason, swapTo := eventStopLoss, "触发止损", exitReasonStopLoss, cfg.StopLoss.SwapTo
+	detail := checkStopLoss(poolAddress, entry, price)
+	if detail == "" {
+		event, title, reason, swapTo = eventTakeProfit, "触发止盈", exitReasonTakeProfit, cfg.TakeProfit.SwapTo
+		detail = checkTakeProfit(poolAddress, r, entry, price)
+	}
+	if detail == "" {
+		return
+	}
+	if _, busy := closingPools.LoadOrStore(poolAddress, true); busy {
+		return
+	}
+	defer closingPools.Delete(poolAddress)
+
+	logWarn("🚨 "+title, "pool", poolAddress, "token", tokenAddress, "entry", entry, "price", price, "detail", detail)
+	level := levelCritical
+	if reason == exitReasonTakeProfit {
+		level = levelInfo
+	}
+	fields := map[string]string{"pool": poolAddress, "ca": tokenAddress, "price": priceStr, "entry": strconv.FormatFloat(entry, 'g', -1, 64)}
+	if s, ok := poolPnL(poolAddress); ok {
+		fields["value"] = formatMoney(s.Value, s.Currency)
+		fields["pnl"] = formatMoney(s.Realized+s.Unrealized, s.Currency)
+	}
+	notifyKeyed(event, level, poolAddress, title, detail, fields)
+
+	if swapTo == swapToUSDC {
+		if claimAndClosePosition(poolAddress, reason, "--skipSwap") && !isPaperPool(poolAddress) {
+			executeJupSwapToMint(poolWallet(poolAddress), tokenAddress, usdcMint)
+		}
+		return
+	}
+	claimAndClosePosition(poolAddress, reason)
+}
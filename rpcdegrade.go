@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RPCDegradeConfig RPC 限流 / 节点落后时自动降级：拉长领取、价格等定时任务的间隔并降低并发，恢复后逐级还原
+type RPCDegradeConfig struct {
+	Enabled         bool     `json:"enabled"`
+	WindowSeconds   int      `json:"windowSeconds"`   // 统计限流信号的窗口
+	Threshold       int      `json:"threshold"`       // 窗口内的限流信号达到该次数时升一级
+	MaxLevel        int      `json:"maxLevel"`        // 最高级别：第 n 级时任务每 2^n 轮执行一次，并发为 1/2^n
+	RecoverySeconds int      `json:"recoverySeconds"` // 连续该时间没有限流信号时降一级
+	Jobs            []string `json:"jobs"`            // 降级时拉长间隔的定时任务
+	Patterns        []string `json:"patterns"`        // 限流 / 节点落后的关键词（匹配脚本输出与 RPC 错误，不区分大小写）
+}
+
+// RPCDegradeStatus 当前降级状态
+type RPCDegradeStatus struct {
+	Level        int    `json:"level"`
+	Signals      int    `json:"signals"` // 窗口内的限流信号数
+	LastSignal   string `json:"lastSignal,omitempty"`
+	LastSource   string `json:"lastSource,omitempty"`
+	ChangedAt    string `json:"changedAt,omitempty"`
+	Concurrency  int    `json:"concurrency"`  // 当前生效的新池任务并发
+	PriceWorkers int    `json:"priceWorkers"` // 当前生效的价格获取 worker 数
+}
+
+// 降级级别检查间隔
+const rpcDegradeCheckInterval = 5 * time.Second
+
+var (
+	rpcDegradeMutex   sync.Mutex
+	rpcDegradeLevel   int
+	rpcDegradeChanged time.Time
+	rpcSignals        []time.Time
+	rpcLastSignal     time.Time
+	rpcLastSource     string
+	rpcJobTicks       = map[string]int{} // 降级期间各任务的触发计数
+)
+
+func (c RPCDegradeConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WindowSeconds <= 0 || c.Threshold <= 0 || c.MaxLevel <= 0 || c.RecoverySeconds <= 0 {
+		return fmt.Errorf("rpcDegrade.windowSeconds、threshold、maxLevel、recoverySeconds 必须大于0")
+	}
+	if c.MaxLevel > 6 {
+		return fmt.Errorf("rpcDegrade.maxLevel 不能大于6")
+	}
+	if len(c.Patterns) == 0 {
+		return fmt.Errorf("rpcDegrade.patterns 不能为空")
+	}
+	return nil
+}
+
+// noteRPCOutput 检查脚本输出或 RPC 错误中的限流 / 节点落后信号（一次输出最多计一次）
+func noteRPCOutput(source, text string) {
+	cfg := currentConfig().RPCDegrade
+	if !cfg.Enabled || text == "" {
+		return
+	}
+	lower := strings.ToLower(text)
+	for _, p := range cfg.Patterns {
+		if p != "" && strings.Contains(lower, strings.ToLower(p)) {
+			now := time.Now()
+			rpcDegradeMutex.Lock()
+			rpcSignals = append(rpcSignals, now)
+			rpcLastSignal, rpcLastSource = now, source
+			rpcDegradeMutex.Unlock()
+			metricRPCRateLimited.Inc(source)
+			return
+		}
+	}
+}
+
+// 当前降级级别（未启用时为 0）
+func currentRPCDegradeLevel() int {
+	if !currentConfig().RPCDegrade.Enabled {
+		return 0
+	}
+	rpcDegradeMutex.Lock()
+	defer rpcDegradeMutex.Unlock()
+	return rpcDegradeLevel
+}
+
+// rpcDegradeState 任务的降级状态：当前级别与触发计数（任务不受降级影响时 level 为 0），用于预测后续轮次
+func rpcDegradeState(job string) (level, tick int) {
+	level = currentRPCDegradeLevel()
+	if level == 0 || !slices.Contains(currentConfig().RPCDegrade.Jobs, job) {
+		return 0, 0
+	}
+	rpcDegradeMutex.Lock()
+	defer rpcDegradeMutex.Unlock()
+	return level, rpcJobTicks[job]
+}
+
+// 按降级级别缩减后的并发（至少 1）
+func degradedConcurrency(n int) int {
+	n >>= currentRPCDegradeLevel()
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// rpcDegradeSkip 降级期间配置的任务每 2^level 轮只执行一轮，返回 true 表示本轮跳过
+func rpcDegradeSkip(job string) bool {
+	level := currentRPCDegradeLevel()
+	if level == 0 {
+		return false
+	}
+	found := false
+	for _, name := range currentConfig().RPCDegrade.Jobs {
+		found = found || name == job
+	}
+	if !found {
+		return false
+	}
+	rpcDegradeMutex.Lock()
+	defer rpcDegradeMutex.Unlock()
+	tick := rpcJobTicks[job]
+	rpcJobTicks[job]++
+	return tick%(1<<level) != 0
+}
+
+// 按窗口内的信号数升级，或在恢复期内没有信号时降级
+func evaluateRPCDegrade() {
+	cfg := currentConfig().RPCDegrade
+	now := time.Now()
+	window := time.Duration(cfg.WindowSeconds) * time.Second
+	recovery := time.Duration(cfg.RecoverySeconds) * time.Second
+
+	rpcDegradeMutex.Lock()
+	kept := rpcSignals[:0]
+	for _, t := range rpcSignals {
+		if now.Sub(t) <= window {
+			kept = append(kept, t)
+		}
+	}
+	rpcSignals = kept
+	prev := rpcDegradeLevel
+	switch {
+	case len(rpcSignals) >= cfg.Threshold && rpcDegradeLevel < cfg.MaxLevel && now.Sub(rpcDegradeChanged) >= window:
+		rpcDegradeLevel++
+		// 下一级需要新窗口内再次达到阈值
+		rpcSignals = nil
+	case rpcDegradeLevel > cfg.MaxLevel:
+		rpcDegradeLevel = cfg.MaxLevel
+	case rpcDegradeLevel > 0 && now.Sub(rpcLastSignal) >= recovery && now.Sub(rpcDegradeChanged) >= recovery:
+		rpcDegradeLevel--
+	}
+	level := rpcDegradeLevel
+	if level != prev {
+		rpcDegradeChanged = now
+		rpcJobTicks = map[string]int{}
+	}
+	source := rpcLastSource
+	rpcDegradeMutex.Unlock()
+
+	if level == prev {
+		return
+	}
+	applyRPCDegrade()
+	fields := map[string]string{
+		"level":        fmt.Sprint(level),
+		"source":       source,
+		"concurrency":  fmt.Sprint(degradedConcurrency(currentConfig().MaxConcurrent)),
+		"priceWorkers": fmt.Sprint(degradedConcurrency(currentConfig().PriceFetch.Workers)),
+		"jobs":         strings.Join(cfg.Jobs, ","),
+	}
+	if level > prev {
+		logWarn("🐢 RPC 限流，降级运行", "level", level, "source", source, "stretch", fmt.Sprintf("%dx", 1<<level),
+			"concurrency", fields["concurrency"], "priceWorkers", fields["priceWorkers"])
+		notifyKeyed(eventRPCDegrade, levelWarning, "rpc", "RPC 限流，已降级运行",
+			fmt.Sprintf("%s 的间隔拉长为 %d 倍，并发降为 %s", fields["jobs"], 1<<level, fields["concurrency"]), fields)
+		return
+	}
+	logInfo("✅ RPC 限流缓解，降级级别下调", "level", level, "concurrency", fields["concurrency"], "priceWorkers", fields["priceWorkers"])
+	if level == 0 {
+		notifyKeyed(eventRPCDegrade, levelInfo, "rpc", "RPC 已恢复，退出降级", "", fields)
+	}
+}
+
+// 按当前级别设置新池任务并发（价格 worker 数在每轮开始时读取）
+func applyRPCDegrade() {
+	setTaskCapacity(degradedConcurrency(currentConfig().MaxConcurrent))
+}
+
+func currentRPCDegrade() RPCDegradeStatus {
+	s := RPCDegradeStatus{
+		Level:        currentRPCDegradeLevel(),
+		Concurrency:  degradedConcurrency(currentConfig().MaxConcurrent),
+		PriceWorkers: degradedConcurrency(currentConfig().PriceFetch.Workers),
+	}
+	rpcDegradeMutex.Lock()
+	defer rpcDegradeMutex.Unlock()
+	s.Signals = len(rpcSignals)
+	s.LastSource = rpcLastSource
+	if !rpcLastSignal.IsZero() {
+		s.LastSignal = rpcLastSignal.Format(time.RFC3339)
+	}
+	if !rpcDegradeChanged.IsZero() {
+		s.ChangedAt = rpcDegradeChanged.Format(time.RFC3339)
+	}
+	return s
+}
+
+// startRPCDegradeMonitor 定期评估降级级别
+func startRPCDegradeMonitor() {
+	if !currentConfig().RPCDegrade.Enabled {
+		return
+	}
+	logOutput("🐢 启动 RPC 限流降级监控（窗口 %ds 内 %d 次限流升级，%ds 无限流降级）\n",
+		currentConfig().RPCDegrade.WindowSeconds, currentConfig().RPCDegrade.Threshold, currentConfig().RPCDegrade.RecoverySeconds)
+	ticker := time.NewTicker(rpcDegradeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止 RPC 限流降级监控\n")
+			return
+		case <-ticker.C:
+			evaluateRPCDegrade()
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RPCPoolConfig 多个 Solana RPC 节点：定期探测延迟与健康，按健康与延迟择优，失败时自动切换，每个节点独立限速。
+// walletWatch.rpcUrl 不在 endpoints 中时作为第一个节点；所有原生 RPC 调用与脚本的 RPC_URL 都经由节点池
+type RPCPoolConfig struct {
+	Endpoints            []RPCEndpointConfig `json:"endpoints"`
+	ProbeIntervalSeconds int                 `json:"probeIntervalSeconds"` // 健康探测间隔（getHealth）
+	ProbeTimeoutSeconds  int                 `json:"probeTimeoutSeconds"`
+	FailureThreshold     int                 `json:"failureThreshold"` // 连续失败该次数后下线
+	CooldownSeconds      int                 `json:"cooldownSeconds"`  // 下线时长，期满或探测成功后恢复
+}
+
+// RPCEndpointConfig 单个 RPC 节点
+type RPCEndpointConfig struct {
+	Name   string  `json:"name"` // 日志、指标与接口中的名称，为空时取 URL 的主机名（不输出带密钥的完整 URL）
+	URL    string  `json:"url"`
+	MaxRPS float64 `json:"maxRps"` // 每秒请求上限，0 表示不限速
+}
+
+// RPCEndpointStatus 节点状态（GET /rpc/endpoints）
+type RPCEndpointStatus struct {
+	Name      string  `json:"name"`
+	Up        bool    `json:"up"`
+	LatencyMs float64 `json:"latencyMs"` // 请求与探测延迟的滑动平均
+	Failures  int     `json:"failures"`  // 连续失败次数
+	DownUntil string  `json:"downUntil,omitempty"`
+	LastError string  `json:"lastError,omitempty"`
+	Requests  int64   `json:"requests"`
+	MaxRPS    float64 `json:"maxRps,omitempty"`
+}
+
+// errRPCUnavailable 节点不可用（网络错误、HTTP 429/5xx、节点落后），可切换到其他节点重试
+var errRPCUnavailable = errors.New("RPC 节点不可用")
+
+// 延迟滑动平均的权重
+const rpcLatencyAlpha = 0.3
+
+type rpcEndpointState struct {
+	failures   int
+	downUntil  time.Time
+	latencyMs  float64
+	lastError  string
+	requests   int64
+	tokens     float64
+	lastRefill time.Time
+}
+
+var (
+	rpcPoolMutex sync.Mutex
+	rpcStates    = map[string]*rpcEndpointState{} // URL -> 状态
+)
+
+func (c RPCPoolConfig) validate() error {
+	seen := map[string]bool{}
+	for i, e := range c.Endpoints {
+		if e.URL == "" {
+			return fmt.Errorf("rpcPool.endpoints[%d].url 不能为空", i)
+		}
+		if _, err := url.Parse(e.URL); err != nil {
+			return fmt.Errorf("rpcPool.endpoints[%d].url 无效: %v", i, err)
+		}
+		if seen[e.URL] {
+			return fmt.Errorf("rpcPool.endpoints 中的 URL 重复: %s", rpcEndpointLabel(e))
+		}
+		seen[e.URL] = true
+		if e.MaxRPS < 0 {
+			return fmt.Errorf("rpcPool.endpoints[%d].maxRps 不能为负数", i)
+		}
+	}
+	if c.ProbeIntervalSeconds <= 0 || c.ProbeTimeoutSeconds <= 0 || c.FailureThreshold <= 0 || c.CooldownSeconds <= 0 {
+		return fmt.Errorf("rpcPool.probeIntervalSeconds、probeTimeoutSeconds、failureThreshold、cooldownSeconds 必须大于0")
+	}
+	return nil
+}
+
+func rpcEndpointLabel(e RPCEndpointConfig) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	if u, err := url.Parse(e.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "rpc"
+}
+
+// 节点列表：walletWatch.rpcUrl（未在 endpoints 中时）+ endpoints
+func rpcEndpoints() []RPCEndpointConfig {
+	endpoints := currentConfig().RPCPool.Endpoints
+	primary := currentConfig().WalletWatch.RPCURL
+	if primary == "" {
+		return endpoints
+	}
+	for _, e := range endpoints {
+		if e.URL == primary {
+			return endpoints
+		}
+	}
+	return append([]RPCEndpointConfig{{Name: "primary", URL: primary}}, endpoints...)
+}
+
+// 调用方需持有 rpcPoolMutex
+func rpcStateLocked(e RPCEndpointConfig) *rpcEndpointState {
+	s := rpcStates[e.URL]
+	if s == nil {
+		s = &rpcEndpointState{tokens: math.Max(e.MaxRPS, 1), lastRefill: time.Now()}
+		rpcStates[e.URL] = s
+	}
+	return s
+}
+
+// 令牌桶：有令牌时取走一个返回 0，否则返回需要等待的时间
+func (s *rpcEndpointState) take(maxRPS float64, now time.Time) time.Duration {
+	if maxRPS <= 0 {
+		return 0
+	}
+	s.tokens = math.Min(math.Max(maxRPS, 1), s.tokens+now.Sub(s.lastRefill).Seconds()*maxRPS)
+	s.lastRefill = now
+	if s.tokens >= 1 {
+		s.tokens--
+		return 0
+	}
+	return time.Duration((1 - s.tokens) / maxRPS * float64(time.Second))
+}
+
+// acquireRPCEndpoint 选择未尝试过的节点：在线的按延迟优先，全部下线时仍按延迟尝试；所选节点超出限速时等待令牌
+func acquireRPCEndpoint(ctx context.Context, tried map[string]bool) (RPCEndpointConfig, error) {
+	for {
+		now := time.Now()
+		rpcPoolMutex.Lock()
+		var candidates []RPCEndpointConfig
+		for _, e := range rpcEndpoints() {
+			if !tried[e.URL] {
+				candidates = append(candidates, e)
+			}
+		}
+		if len(candidates) == 0 {
+			rpcPoolMutex.Unlock()
+			return RPCEndpointConfig{}, fmt.Errorf("%w: 没有可用的 RPC 节点", errRPCUnavailable)
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			sa, sb := rpcStateLocked(candidates[a]), rpcStateLocked(candidates[b])
+			upA, upB := !now.Before(sa.downUntil), !now.Before(sb.downUntil)
+			if upA != upB {
+				return upA
+			}
+			return sa.latencyMs < sb.latencyMs
+		})
+		wait := time.Duration(math.MaxInt64)
+		for _, e := range candidates {
+			s := rpcStateLocked(e)
+			d := s.take(e.MaxRPS, now)
+			if d == 0 {
+				s.requests++
+				rpcPoolMutex.Unlock()
+				return e, nil
+			}
+			if d < wait {
+				wait = d
+			}
+		}
+		rpcPoolMutex.Unlock()
+		metricRPCRequests.Inc(rpcEndpointLabel(candidates[0]), "throttled")
+		select {
+		case <-ctx.Done():
+			return RPCEndpointConfig{}, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// recordRPCResult 记录一次请求或探测的结果：不可用时累计失败，达到阈值下线；成功时清零并更新延迟
+func recordRPCResult(e RPCEndpointConfig, err error, latency time.Duration, probe bool) {
+	cfg := currentConfig().RPCPool
+	label := rpcEndpointLabel(e)
+	rpcPoolMutex.Lock()
+	s := rpcStateLocked(e)
+	wasDown := time.Now().Before(s.downUntil)
+	if err != nil && errors.Is(err, errRPCUnavailable) {
+		s.failures++
+		s.lastError = err.Error()
+		failures := s.failures
+		down := failures >= cfg.FailureThreshold || probe
+		if down {
+			s.downUntil = time.Now().Add(time.Duration(cfg.CooldownSeconds) * time.Second)
+		}
+		rpcPoolMutex.Unlock()
+		metricRPCRequests.Inc(label, "unavailable")
+		if down && !wasDown {
+			logWarn("⚠️ RPC 节点下线，切换到其他节点", "endpoint", label, "failures", failures, "cooldown", fmt.Sprintf("%ds", cfg.CooldownSeconds), "error", err)
+		}
+		return
+	}
+	s.failures = 0
+	s.downUntil = time.Time{}
+	ms := float64(latency) / float64(time.Millisecond)
+	if s.latencyMs == 0 {
+		s.latencyMs = ms
+	} else {
+		s.latencyMs = rpcLatencyAlpha*ms + (1-rpcLatencyAlpha)*s.latencyMs
+	}
+	rpcPoolMutex.Unlock()
+	// JSON-RPC 业务错误说明节点可用，不影响健康状态
+	metricRPCRequests.Inc(label, resultLabel(err))
+	if wasDown {
+		logInfo("✅ RPC 节点恢复", "endpoint", label, "latencyMs", fmt.Sprintf("%.0f", ms))
+	}
+}
+
+// rpcScriptURL 传给脚本的 RPC_URL：当前在线且延迟最低的节点（未配置时为空，脚本使用默认节点）
+func rpcScriptURL() string {
+	now := time.Now()
+	rpcPoolMutex.Lock()
+	defer rpcPoolMutex.Unlock()
+	best, bestLatency := "", math.MaxFloat64
+	for _, e := range rpcEndpoints() {
+		s := rpcStateLocked(e)
+		if now.Before(s.downUntil) {
+			continue
+		}
+		if best == "" || s.latencyMs < bestLatency {
+			best, bestLatency = e.URL, s.latencyMs
+		}
+	}
+	if best == "" {
+		// 全部下线时仍返回第一个节点，避免脚本回退到公共节点
+		if endpoints := rpcEndpoints(); len(endpoints) > 0 {
+			return endpoints[0].URL
+		}
+	}
+	return best
+}
+
+// 探测所有节点（getHealth）
+func probeRPCEndpoints() {
+	cfg := currentConfig().RPCPool
+	for _, e := range rpcEndpoints() {
+		ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.ProbeTimeoutSeconds)*time.Second)
+		start := time.Now()
+		var health string
+		err := solanaRPCAt(ctx, e.URL, "", "getHealth", []interface{}{}, &health)
+		cancel()
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("%w: 探测超时", errRPCUnavailable)
+		}
+		recordRPCResult(e, err, time.Since(start), true)
+	}
+}
+
+func listRPCEndpoints() []RPCEndpointStatus {
+	now := time.Now()
+	rpcPoolMutex.Lock()
+	defer rpcPoolMutex.Unlock()
+	result := []RPCEndpointStatus{}
+	for _, e := range rpcEndpoints() {
+		s := rpcStateLocked(e)
+		st := RPCEndpointStatus{
+			Name: rpcEndpointLabel(e), Up: !now.Before(s.downUntil), LatencyMs: math.Round(s.latencyMs*10) / 10,
+			Failures: s.failures, LastError: s.lastError, Requests: s.requests, MaxRPS: e.MaxRPS,
+		}
+		if !st.Up {
+			st.DownUntil = s.downUntil.Format(time.RFC3339)
+		}
+		result = append(result, st)
+	}
+	return result
+}
+
+// 各节点在线状态与延迟（/metrics）
+func rpcEndpointSamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, s := range listRPCEndpoints() {
+		up := 0.0
+		if s.Up {
+			up = 1
+		}
+		samples = append(samples, gaugeSample{LabelValues: []string{s.Name}, Value: up})
+	}
+	return samples
+}
+
+func rpcLatencySamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, s := range listRPCEndpoints() {
+		samples = append(samples, gaugeSample{LabelValues: []string{s.Name}, Value: s.LatencyMs / 1000})
+	}
+	return samples
+}
+
+// startRPCProbe 定期探测节点健康（单节点且未配置 endpoints 时不探测；演示模式不访问 RPC）
+func startRPCProbe() {
+	cfg := currentConfig().RPCPool
+	if len(cfg.Endpoints) == 0 || isDemo() {
+		return
+	}
+	interval := time.Duration(cfg.ProbeIntervalSeconds) * time.Second
+	logOutput("📡 启动 RPC 节点探测（%d 个节点，每%v）\n", len(rpcEndpoints()), interval)
+	probeRPCEndpoints()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止 RPC 节点探测\n")
+			return
+		case <-ticker.C:
+			probeRPCEndpoints()
+		}
+	}
+}
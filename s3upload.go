@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 兼容对象存储的上传（AWS Signature V4，单次 PUT），凭据取自环境变量或 .env：
+// AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY，可选 AWS_SESSION_TOKEN
+
+// S3Config 对象存储目标
+type S3Config struct {
+	Bucket    string `json:"bucket"`    // 为空表示不上传
+	Region    string `json:"region"`    // 如 us-east-1
+	Endpoint  string `json:"endpoint"`  // S3 兼容服务的地址（MinIO、R2 等），为空时使用 AWS
+	Prefix    string `json:"prefix"`    // 对象键前缀，如 meteora/exports/
+	PathStyle bool   `json:"pathStyle"` // 使用 <endpoint>/<bucket>/<key> 形式（自定义 endpoint 时通常需要）
+}
+
+var s3HTTP = &http.Client{Timeout: 2 * time.Minute}
+
+func (c S3Config) validate(prefix string) error {
+	if c.Bucket == "" {
+		return nil
+	}
+	if c.Region == "" {
+		return fmt.Errorf("%s.s3.region 不能为空", prefix)
+	}
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("%s.s3.endpoint 不是有效的 http(s) 地址", prefix)
+		}
+	}
+	return nil
+}
+
+// objectURL 对象的地址（虚拟主机形式 <bucket>.<host>/<key>，pathStyle 时为 <host>/<bucket>/<key>）
+func (c S3Config) objectURL(key string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: "s3." + c.Region + ".amazonaws.com"}
+	if c.Endpoint != "" {
+		u, _ = url.Parse(strings.TrimRight(c.Endpoint, "/"))
+	}
+	base := u.Path
+	path, rawPath := "/"+key, "/"+s3EscapePath(key)
+	if c.PathStyle {
+		path, rawPath = "/"+c.Bucket+path, "/"+c.Bucket+rawPath
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+	}
+	u.Path, u.RawPath = base+path, s3EscapePath(base)+rawPath
+	return u
+}
+
+// putS3Object 上传一个对象，返回 s3://bucket/key
+func putS3Object(ctx context.Context, c S3Config, key string, body []byte, contentType string) (string, error) {
+	accessKey, secretKey := lookupEnv("AWS_ACCESS_KEY_ID"), lookupEnv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("缺少 AWS_ACCESS_KEY_ID 或 AWS_SECRET_ACCESS_KEY")
+	}
+	key = strings.TrimLeft(c.Prefix+key, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token := lookupEnv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, body, accessKey, secretKey, c.Region, "s3", time.Now())
+
+	resp, err := s3HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return "s3://" + c.Bucket + "/" + key, nil
+}
+
+// signAWSRequest 按 AWS Signature V4 签名（签名 Host、Content-Type 与全部 x-amz-* 请求头），service 如 s3、secretsmanager
+func signAWSRequest(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EscapePath 按 SigV4 的规则编码对象键（保留 /，其余非保留字符按 %XX 编码）
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 预览窗口默认与最大时长（分钟），单个任务最多列出的轮次
+const (
+	schedulePreviewMinutes    = 60
+	schedulePreviewMaxMinutes = 24 * 60
+	schedulePreviewMaxRuns    = 500
+)
+
+// ScheduledRun 预计的一次任务触发
+type ScheduledRun struct {
+	Job  string `json:"job"`
+	At   string `json:"at"`
+	Skip string `json:"skip,omitempty"` // 按当前状态预计跳过的原因：paused / degraded / data_unavailable / profile_interval
+}
+
+// JobPreview 单个任务在窗口内的触发计划
+type JobPreview struct {
+	Name      string `json:"name"`
+	Cron      string `json:"cron"`
+	Jitter    string `json:"jitter"` // 实际执行时间最多晚于计划时间的随机延迟
+	Runs      int    `json:"runs"`
+	Skipped   int    `json:"skipped"`
+	Truncated bool   `json:"truncated,omitempty"` // 轮次超过上限，只列出前面的部分
+}
+
+// PoolClaimPreview 单个仓位的领取预计：领取门槛与最长间隔（claimPolicy）按仓位自适应，全局领取轮次中只有满足条件时才实际领取
+type PoolClaimPreview struct {
+	PoolAddress string  `json:"poolAddress"`
+	Position    string  `json:"position"`
+	PendingUSD  float64 `json:"pendingUSD"` // 最近一次检查时的未领取手续费
+	CheckedAt   string  `json:"checkedAt,omitempty"`
+	LastClaimAt string  `json:"lastClaimAt,omitempty"`
+	ForceAt     string  `json:"forceAt,omitempty"`   // 达到 maxIntervalMinutes 的时间，之后的轮次强制领取
+	NextCheck   string  `json:"nextCheck,omitempty"` // 窗口内下一次领取检查
+	NextClaim   string  `json:"nextClaim,omitempty"` // 窗口内预计实际领取的轮次
+	Reason      string  `json:"reason"`              // threshold / forced / below_threshold / first_check / no_run
+}
+
+// SchedulePreview 未来一段时间的任务触发计划（GET /schedule/upcoming）
+type SchedulePreview struct {
+	From    string             `json:"from"`
+	Until   string             `json:"until"`
+	Minutes int                `json:"minutes"`
+	Jobs    []JobPreview       `json:"jobs"`
+	Runs    []ScheduledRun     `json:"runs"`  // 所有任务按时间排序
+	Pools   []PoolClaimPreview `json:"pools"` // 持有仓位的池在窗口内的领取预计
+}
+
+// 暂停时整轮跳过的任务（价格任务不受暂停影响）
+var pauseSkippedJobs = map[string]bool{"claim": true, "swap": true}
+
+// schedulePreview 按当前的 cron、暂停状态、RPC 降级计数与档位领取间隔推算未来 minutes 分钟的触发计划。
+// 降级级别、暂停与数据目录状态按当前值推算，窗口内发生变化时以实际为准
+func schedulePreview(minutes int) SchedulePreview {
+	if minutes <= 0 {
+		minutes = schedulePreviewMinutes
+	}
+	if minutes > schedulePreviewMaxMinutes {
+		minutes = schedulePreviewMaxMinutes
+	}
+	now := appNow()
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	preview := SchedulePreview{
+		From: now.Format(time.RFC3339), Until: until.Format(time.RFC3339), Minutes: minutes,
+		Jobs: []JobPreview{}, Runs: []ScheduledRun{}, Pools: []PoolClaimPreview{},
+	}
+
+	schedulerMutex.Lock()
+	jobs := make([]*scheduledJob, 0, len(schedulerJobs))
+	for _, j := range schedulerJobs {
+		jobs = append(jobs, j)
+	}
+	schedulerMutex.Unlock()
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].name < jobs[b].name })
+
+	var claimRuns []time.Time // 实际会执行的领取轮次
+	hasClaim := false
+	for _, j := range jobs {
+		hasClaim = hasClaim || j.name == "claim"
+		j.mu.Lock()
+		schedule, jitter := j.schedule, j.jitter
+		j.mu.Unlock()
+		jp := JobPreview{Name: j.name, Cron: schedule.expr, Jitter: jitter.String()}
+		level, tick := rpcDegradeState(j.name)
+		claimInterval, lastClaim := profileClaimWindow()
+		for t := schedule.Next(now); !t.IsZero() && !t.After(until); t = schedule.Next(t) {
+			if jp.Runs >= schedulePreviewMaxRuns {
+				jp.Truncated = true
+				break
+			}
+			run := ScheduledRun{Job: j.name, At: t.Format(time.RFC3339)}
+			switch {
+			case j.paused.Load():
+				run.Skip = "paused"
+			case level > 0 && tick%(1<<level) != 0:
+				run.Skip = "degraded"
+			case dataVolumeUnavailable():
+				run.Skip = "data_unavailable"
+			case pauseSkippedJobs[j.name] && isPaused():
+				run.Skip = "paused"
+			case j.name == "claim" && claimInterval > 0 && t.Sub(lastClaim) < claimInterval:
+				run.Skip = "profile_interval"
+			}
+			if level > 0 && !j.paused.Load() {
+				tick++
+			}
+			if run.Skip == "" && j.name == "claim" {
+				lastClaim = t
+				claimRuns = append(claimRuns, t)
+			}
+			jp.Runs++
+			if run.Skip != "" {
+				jp.Skipped++
+			}
+			preview.Runs = append(preview.Runs, run)
+		}
+		preview.Jobs = append(preview.Jobs, jp)
+	}
+	sort.SliceStable(preview.Runs, func(a, b int) bool { return preview.Runs[a].At < preview.Runs[b].At })
+	if hasClaim {
+		preview.Pools = poolClaimPreviews(claimRuns)
+	}
+	return preview
+}
+
+// 各持仓池在领取轮次中预计的实际领取时间：最近一次检查的未领取手续费已达门槛，或已超过最长间隔
+func poolClaimPreviews(runs []time.Time) []PoolClaimPreview {
+	cfg := currentConfig().ClaimPolicy
+	checks := listClaimChecks()
+	files, err := os.ReadDir(poolDataDir())
+	if err != nil {
+		return []PoolClaimPreview{}
+	}
+	result := []PoolClaimPreview{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		pool := strings.TrimSuffix(file.Name(), ".json")
+		position := readPositionFromPoolJSON(pool)
+		if position == "" {
+			continue
+		}
+		p := PoolClaimPreview{PoolAddress: pool, Position: position}
+		c, seen := checks[position]
+		var forceAt time.Time
+		if seen {
+			p.PendingUSD, p.CheckedAt, p.LastClaimAt = c.PendingUSD, c.CheckedAt, c.LastClaimAt
+			since := c.LastClaimAt
+			if since == "" {
+				since = c.FirstSeenAt
+			}
+			if t, err := time.Parse(time.RFC3339, since); err == nil && cfg.MaxIntervalMinutes > 0 {
+				forceAt = t.Add(time.Duration(cfg.MaxIntervalMinutes * float64(time.Minute)))
+				p.ForceAt = forceAt.Format(time.RFC3339)
+			}
+		}
+		switch {
+		case len(runs) == 0:
+			p.Reason = "no_run"
+		case !seen:
+			p.Reason = "first_check"
+		case p.PendingUSD > 0 && p.PendingUSD >= cfg.MinPendingUSD:
+			p.Reason = "threshold"
+			p.NextClaim = runs[0].Format(time.RFC3339)
+		default:
+			p.Reason = "below_threshold"
+			for _, t := range runs {
+				if !forceAt.IsZero() && !t.Before(forceAt) {
+					p.Reason = "forced"
+					p.NextClaim = t.Format(time.RFC3339)
+					break
+				}
+			}
+		}
+		if len(runs) > 0 {
+			p.NextCheck = runs[0].Format(time.RFC3339)
+		}
+		result = append(result, p)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].PoolAddress < result[b].PoolAddress })
+	return result
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cronSchedule 带秒字段的 cron 表达式: 秒 分 时 日 月 周
+type cronSchedule struct {
+	expr                                  string
+	second, minute, hour, dom, month, dow uint64 // 位图
+	domStar, dowStar                      bool
+}
+
+// 字段取值范围（周：0 与 7 都表示周日）
+var cronFieldRanges = [6][2]int{{0, 59}, {0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// 解析单个字段: *、*/n、a、a-b、a-b/n、逗号组合
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长: %s", part)
+			}
+			step = n
+			part = part[:idx]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, err1 := strconv.Atoi(bounds[0])
+			b, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("无效的范围: %s", part)
+			}
+			lo, hi = a, b
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("无效的取值: %s", part)
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("取值超出范围 [%d-%d]: %s", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// 解析 cron 表达式（6 个字段，含秒）
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("cron 表达式需要 6 个字段（秒 分 时 日 月 周）: %q", expr)
+	}
+	var parsed [6]uint64
+	for i, f := range fields {
+		bits, err := parseCronField(f, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 第%d个字段: %v", expr, i+1, err)
+		}
+		parsed[i] = bits
+	}
+	// 周字段的 7 归并为 0（time.Weekday 中周日为 0）
+	if parsed[5]&(1<<7) != 0 {
+		parsed[5] = parsed[5]&^(1<<7) | 1
+	}
+	return &cronSchedule{
+		expr:    expr,
+		second:  parsed[0],
+		minute:  parsed[1],
+		hour:    parsed[2],
+		dom:     parsed[3],
+		month:   parsed[4],
+		dow:     parsed[5],
+		domStar: fields[3] == "*",
+		dowStar: fields[5] == "*",
+	}, nil
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	// 与标准 cron 一致：日与周都被限定时，满足其一即可
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next 返回严格晚于 t 的下一次触发时间
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if c.second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// JobRun 一次执行记录
+type JobRun struct {
+	ScheduledAt string `json:"scheduledAt"`
+	StartedAt   string `json:"startedAt,omitempty"`
+	Duration    string `json:"duration,omitempty"`
+	Skipped     string `json:"skipped,omitempty"` // paused / overlap / degraded / backoff
+	Failed      string `json:"failed,omitempty"`  // panic，或卡住后被放弃（wedged）
+}
+
+// maxJobHistory 每个任务保留的执行记录数
+const maxJobHistory = 50
+
+// scheduledJob 定时任务
+type scheduledJob struct {
+	name     string
+	schedule *cronSchedule
+	jitter   time.Duration
+	reload   chan struct{} // 配置热更新后唤醒主循环，按新的 cron 重新计算下次时间
+	fn       func()
+	paused   atomic.Bool
+	running  atomic.Bool
+	mu       sync.Mutex
+	next     time.Time
+	history  []JobRun
+	runStart time.Time // 当前一轮的开始时间（执行中时有效）
+	lastDone time.Time // 最近一轮执行完成的时间
+
+	gen          uint64    // 当前一轮的编号；放弃卡住的一轮后递增，旧的一轮结束时不再更新状态
+	release      func()    // 当前一轮占用的 shutdownWg（放弃时提前释放，关闭时不等待卡住的一轮）
+	failures     int       // 连续 panic 的轮数
+	backoffUntil time.Time // panic 后在此之前跳过执行
+}
+
+// JobStatus 对外输出的任务状态
+type JobStatus struct {
+	Name    string   `json:"name"`
+	Cron    string   `json:"cron"`
+	Jitter  string   `json:"jitter"`
+	Paused  bool     `json:"paused"`
+	Running bool     `json:"running"`
+	NextRun string   `json:"nextRun"`
+	LastRun string   `json:"lastRun,omitempty"` // 最近一轮执行完成的时间
+	History []JobRun `json:"history"`
+}
+
+var (
+	schedulerJobs  = map[string]*scheduledJob{}
+	schedulerMutex sync.Mutex
+)
+
+// ScheduleConfig 单个任务的调度配置
+type ScheduleConfig struct {
+	Cron     string `json:"cron"`
+	JitterMs int    `json:"jitterMs"` // 每次触发额外随机延迟的上限
+}
+
+// 注册定时任务
+func registerJob(name string, cfg ScheduleConfig, fn func()) error {
+	schedule, err := parseCron(cfg.Cron)
+	if err != nil {
+		return err
+	}
+	job := &scheduledJob{name: name, schedule: schedule, jitter: time.Duration(cfg.JitterMs) * time.Millisecond, reload: make(chan struct{}, 1), fn: fn}
+	schedulerMutex.Lock()
+	schedulerJobs[name] = job
+	schedulerMutex.Unlock()
+	return nil
+}
+
+func (j *scheduledJob) record(run JobRun) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.history = append(j.history, run)
+	if len(j.history) > maxJobHistory {
+		j.history = j.history[len(j.history)-maxJobHistory:]
+	}
+}
+
+// abandonIfWedged 本轮执行超过 limit 时放弃：释放防重叠标记与 shutdownWg，返回已执行的时长
+func (j *scheduledJob) abandonIfWedged(limit time.Duration) (time.Duration, bool) {
+	j.mu.Lock()
+	if !j.running.Load() || j.runStart.IsZero() || time.Since(j.runStart) <= limit {
+		j.mu.Unlock()
+		return 0, false
+	}
+	started := j.runStart
+	d := time.Since(started)
+	release := j.release
+	j.gen++
+	j.runStart, j.release = time.Time{}, nil
+	j.running.Store(false)
+	j.mu.Unlock()
+	if release != nil {
+		release()
+	}
+	j.record(JobRun{ScheduledAt: started.Format(time.RFC3339), StartedAt: started.Format(time.RFC3339), Duration: d.Round(time.Millisecond).String(), Failed: "wedged"})
+	return d, true
+}
+
+// 定时任务对应的环节（记入跳过原因统计；盈亏日报等不涉及动作的任务不记录）
+var jobSkipStages = map[string]string{"price": subsystemPrice, "claim": subsystemClaim, "swap": subsystemSweep}
+
+func (j *scheduledJob) recordSkip(reason string) {
+	if stage, ok := jobSkipStages[j.name]; ok {
+		recordSkip(stage, reason, "", "", "定时任务 "+j.name+" 本轮跳过")
+	}
+}
+
+// 任务主循环：按 cron 计算下次时间，到点后检查暂停与重叠再执行
+func (j *scheduledJob) loop() {
+	for {
+		j.mu.Lock()
+		schedule, jitter := j.schedule, j.jitter
+		j.mu.Unlock()
+		next := schedule.Next(appNow())
+		if next.IsZero() {
+			logOutput("⚠️ 定时任务 %s 没有可执行的时间点，已停止\n", j.name)
+			return
+		}
+		j.mu.Lock()
+		j.next = next
+		j.mu.Unlock()
+
+		delay := time.Until(next)
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止定时任务 %s\n", j.name)
+			return
+		case <-j.reload:
+			continue
+		case <-time.After(delay):
+		}
+
+		run := JobRun{ScheduledAt: next.Format(time.RFC3339)}
+		if j.paused.Load() {
+			run.Skipped = "paused"
+			j.record(run)
+			j.recordSkip(skipPaused)
+			continue
+		}
+		// RPC 限流降级：按级别拉长间隔
+		if rpcDegradeSkip(j.name) {
+			run.Skipped = "degraded"
+			j.record(run)
+			j.recordSkip(skipUnhealthy)
+			continue
+		}
+		// 数据目录不可用：读写池文件与状态会失败，恢复前跳过
+		if dataVolumeUnavailable() {
+			run.Skipped = "data_unavailable"
+			j.record(run)
+			j.recordSkip(skipUnhealthy)
+			continue
+		}
+		// 上一轮 panic 后按 supervisor 的指数退避暂停
+		j.mu.Lock()
+		backoffUntil := j.backoffUntil
+		j.mu.Unlock()
+		if time.Now().Before(backoffUntil) {
+			run.Skipped = "backoff"
+			j.record(run)
+			j.recordSkip(skipUnhealthy)
+			continue
+		}
+		// 防重叠：上一轮仍在执行则跳过本次
+		if !j.running.CompareAndSwap(false, true) {
+			run.Skipped = "overlap"
+			j.record(run)
+			logOutput("⏭️ 定时任务 %s 上一轮仍在执行，跳过本次\n", j.name)
+			continue
+		}
+
+		shutdownWg.Add(1)
+		var once sync.Once
+		release := func() { once.Do(shutdownWg.Done) }
+		start := time.Now()
+		run.StartedAt = start.Format(time.RFC3339)
+		j.mu.Lock()
+		j.gen++
+		gen := j.gen
+		j.runStart, j.release = start, release
+		j.mu.Unlock()
+		go func(run JobRun) {
+			defer release()
+			err := runRecovered(func() error {
+				j.fn()
+				return nil
+			})
+			run.Duration = time.Since(start).Round(time.Millisecond).String()
+			j.mu.Lock()
+			if j.gen != gen {
+				j.mu.Unlock()
+				logWarn("⚠️ 已放弃的定时任务轮次结束", "job", j.name, "duration", run.Duration, "error", err)
+				return
+			}
+			j.lastDone, j.release = time.Now(), nil
+			var delay time.Duration
+			if err != nil {
+				j.failures++
+				delay = supervisorBackoff(currentConfig().Supervisor, j.failures)
+				j.backoffUntil = time.Now().Add(delay)
+				run.Failed = err.Error()
+			} else {
+				j.failures = 0
+			}
+			j.running.Store(false)
+			j.mu.Unlock()
+			j.record(run)
+			if pe, ok := err.(*panicError); ok {
+				metricSubsystemRestarts.Inc("job:"+j.name, "panic")
+				recordPanic("job:"+j.name, "", pe)
+				publishPanic("job:"+j.name, "", pe)
+				logError("❌ 定时任务 panic，退避后继续执行", "job", j.name, "backoff", delay)
+				notifyKeyed(eventSubsystemRestart, levelCritical, "job:"+j.name, "定时任务异常",
+					fmt.Sprintf("%s: %v，%v 内跳过执行", j.name, err, delay), map[string]string{"subsystem": "job:" + j.name, "reason": "panic"})
+			}
+		}(run)
+	}
+}
+
+// 启动所有已注册任务
+func startScheduler() {
+	schedulerMutex.Lock()
+	jobs := make([]*scheduledJob, 0, len(schedulerJobs))
+	for _, j := range schedulerJobs {
+		jobs = append(jobs, j)
+	}
+	schedulerMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		first := j.schedule.Next(appNow())
+		logOutput("🕐 启动定时任务 %s（cron: %s），距离下次执行还有: %v\n", j.name, j.schedule.expr, time.Until(first).Round(time.Second))
+		wg.Add(1)
+		go func(j *scheduledJob) {
+			defer wg.Done()
+			supervise("scheduler:"+j.name, func() error {
+				j.loop()
+				return nil
+			})
+		}(j)
+	}
+	wg.Wait()
+}
+
+// 更新任务的 cron 与随机延迟（配置热更新），等待中的任务立即按新的时间表重新计算
+func rescheduleJob(name string, cfg ScheduleConfig) error {
+	schedulerMutex.Lock()
+	j, ok := schedulerJobs[name]
+	schedulerMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	schedule, err := parseCron(cfg.Cron)
+	if err != nil {
+		return err
+	}
+	jitter := time.Duration(cfg.JitterMs) * time.Millisecond
+	j.mu.Lock()
+	changed := j.schedule.expr != schedule.expr || j.jitter != jitter
+	j.schedule, j.jitter = schedule, jitter
+	j.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	select {
+	case j.reload <- struct{}{}:
+	default:
+	}
+	logOutput("🕐 定时任务 %s 已更新（cron: %s），下次执行: %s\n", name, schedule.expr, schedule.Next(appNow()).Format(time.RFC3339))
+	return nil
+}
+
+// 暂停/恢复单个任务
+func setJobPaused(name string, paused bool) bool {
+	schedulerMutex.Lock()
+	j, ok := schedulerJobs[name]
+	schedulerMutex.Unlock()
+	if !ok {
+		return false
+	}
+	j.paused.Store(paused)
+	if paused {
+		logOutput("⏸️ 定时任务 %s 已暂停\n", name)
+	} else {
+		logOutput("▶️ 定时任务 %s 已恢复\n", name)
+	}
+	return true
+}
+
+// 任务是否被人工暂停（未注册的任务视为未暂停）
+func jobPaused(name string) bool {
+	schedulerMutex.Lock()
+	j, ok := schedulerJobs[name]
+	schedulerMutex.Unlock()
+	return ok && j.paused.Load()
+}
+
+// 所有任务状态（按名称排序）
+func listJobStatus() []JobStatus {
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+	result := make([]JobStatus, 0, len(schedulerJobs))
+	for _, j := range schedulerJobs {
+		j.mu.Lock()
+		status := JobStatus{
+			Name:    j.name,
+			Cron:    j.schedule.expr,
+			Jitter:  j.jitter.String(),
+			Paused:  j.paused.Load(),
+			Running: j.running.Load(),
+			History: append([]JobRun{}, j.history...),
+		}
+		if !j.next.IsZero() {
+			status.NextRun = j.next.Format(time.RFC3339)
+		}
+		if !j.lastDone.IsZero() {
+			status.LastRun = j.lastDone.Format(time.RFC3339)
+		}
+		j.mu.Unlock()
+		result = append(result, status)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+	return result
+}
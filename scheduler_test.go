@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"1 * * * * *", true},
+		{"10,40 * * * * *", true},
+		{"*/15 0-30/5 9-17 1,15 1-12 1-5", true},
+		{"0 0 0 * * 7", true},
+		{"0 0 0 * * 5-7", true},
+		{"* * * * *", false},       // 只有 5 个字段
+		{"60 * * * * *", false},    // 秒超出范围
+		{"* * 24 * * *", false},    // 时超出范围
+		{"* * * 0 * *", false},     // 日从 1 开始
+		{"* * * * 13 *", false},    // 月超出范围
+		{"* * * * * 8", false},     // 周超出范围
+		{"*/0 * * * * *", false},   // 步长为 0
+		{"5-3 * * * * *", false},   // 范围倒置
+		{"a * * * * *", false},     // 非数字
+		{"1-a * * * * *", false},   // 范围非数字
+		{"1,,2 * * * * *", false},  // 空的列表项
+		{"* * * * * 1-8/2", false}, // 带步长的范围超出
+		{"0 0 0 * * */-1", false},  // 负步长
+		{"0 0 0 * * 7-1", false},   // 周范围倒置
+		{"0 0 0 * * 0-7", true},    // 0 与 7 同时出现
+		{"  0  0  0  *  *  *  ", true},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCron(%q): err=%v，应 ok=%v", tt.expr, err, tt.ok)
+		}
+	}
+}
+
+func TestParseCronSundaySeven(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"0 0 0 * * 7", "0 0 0 * * 0"},
+		{"0 0 0 * * 5-7", "0 0 0 * * 0,5,6"},
+		{"0 0 0 * * 1-7", "0 0 0 * * *"},
+		{"0 0 0 * * 0,7", "0 0 0 * * 0"},
+	}
+	for _, tt := range tests {
+		a, errA := parseCron(tt.a)
+		b, errB := parseCron(tt.b)
+		if errA != nil || errB != nil {
+			t.Fatalf("%q / %q: %v %v", tt.a, tt.b, errA, errB)
+		}
+		if a.dow != b.dow {
+			t.Errorf("%q 的周位图 %b，应与 %q 的 %b 相同", tt.a, a.dow, tt.b, b.dow)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr, from, want string
+	}{
+		{"0 0 12 * * *", "2026-10-15 11:59:59", "2026-10-15 12:00:00"},
+		{"0 0 12 * * *", "2026-10-15 12:00:00", "2026-10-16 12:00:00"}, // 严格晚于 t
+		{"*/15 * * * * *", "2026-10-15 10:00:07", "2026-10-15 10:00:15"},
+		{"10,40 * * * * *", "2026-10-15 10:00:40", "2026-10-15 10:01:10"},
+		{"50 59 23 * * *", "2026-12-31 23:59:50", "2027-01-01 23:59:50"}, // 跨年
+		{"0 30 9 * * 1-5", "2026-10-16 10:00:00", "2026-10-19 09:30:00"}, // 周五之后是周一
+		{"0 0 0 * * 7", "2026-10-15 08:00:00", "2026-10-18 00:00:00"},    // 7 为周日
+		{"0 0 0 * * 0", "2026-10-15 08:00:00", "2026-10-18 00:00:00"},
+		{"0 0 0 31 * *", "2026-10-31 00:00:00", "2026-12-31 00:00:00"}, // 跳过没有 31 日的月份
+		{"0 0 0 1 * 1", "2026-10-15 00:00:00", "2026-10-19 00:00:00"},  // 日与周都限定时满足其一
+		{"0 0 0 13 * 5", "2026-11-01 00:00:00", "2026-11-06 00:00:00"},
+		{"0 0 0 29 2 *", "2026-03-01 00:00:00", "2028-02-29 00:00:00"}, // 闰日
+		{"0 0 0 30 2 *", "2026-03-01 00:00:00", "0001-01-01 00:00:00"}, // 永不触发
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		got := c.Next(at(tt.from))
+		if want := at(tt.want); !got.Equal(want) && !(want.IsZero() && got.IsZero()) {
+			t.Errorf("%q 从 %s: Next=%s，应为 %s", tt.expr, tt.from, got.Format(time.DateTime), tt.want)
+		}
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	c, err := parseCron("50 59 23 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2026, 10, 15, 12, 0, 0, 0, loc))
+	if want := time.Date(2026, 10, 15, 23, 59, 50, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next=%s，应为 %s（按 t 的时区计算）", got, want)
+	}
+}
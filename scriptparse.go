@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 输出解析层：外部命令的输出在 runExternal 中统一转换为 @@event 事件，之后的解析（持仓代币、余额、价格、签名等）只读取事件，
+// 不依赖子进程输出文本的语言：
+//   - output.format 为 json：子进程每行输出一个 JSON 事件（字段同 @@event，不带前缀），output.jsonFlag 为让子进程切换到 JSON 输出的参数
+//   - output.patterns：输出中没有结构化事件时按正则逐行匹配，命名分组对应事件字段，生成的事件行追加在输出末尾（不支持 JSON 输出的旧版本）
+
+// OutputPattern 按正则生成事件的规则
+type OutputPattern struct {
+	Event string `json:"event"`         // 生成的事件类型：token、price、signature、value、claimed、account、status
+	Regex string `json:"regex"`         // 逐行匹配，命名分组：token、balance、price、source、signature、action、amount、account、status、code、value
+	Key   string `json:"key,omitempty"` // value 事件的 key（如 proceeds、feeSOL）
+}
+
+// 可由正则生成的事件类型
+var patternEvents = map[string]bool{
+	scriptEventToken: true, scriptEventPrice: true, scriptEventSignature: true, scriptEventValue: true,
+	scriptEventClaimed: true, scriptEventAccount: true, scriptEventStatus: true,
+}
+
+// defaultOutputPatterns 内置的回退规则（注册项未设置 patterns 时使用，设置为 [] 表示不使用）：
+// jupSwap 二进制的持仓行 "代币: <ca>, 余额: <原始值> (<数量>)"，英文输出为 "Token: <ca>, Balance: ..."
+var defaultOutputPatterns = map[string][]OutputPattern{
+	scriptJupSwapBalances: {{
+		Event: scriptEventToken,
+		Regex: `(?:代币|Token):\s*(?P<token>[1-9A-HJ-NP-Za-km-z]{32,44})(?:,\s*(?:余额|Balance):\s*\d+\s*\((?P<balance>[0-9.]+)\))?`,
+	}},
+}
+
+var outputPatternCache sync.Map // 正则 -> *regexp.Regexp
+
+func (p OutputPattern) compile() (*regexp.Regexp, error) {
+	if re, ok := outputPatternCache.Load(p.Regex); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(p.Regex)
+	if err != nil {
+		return nil, err
+	}
+	outputPatternCache.Store(p.Regex, re)
+	return re, nil
+}
+
+func (p OutputPattern) validate() error {
+	if !patternEvents[p.Event] {
+		return fmt.Errorf("不支持的事件类型 %q", p.Event)
+	}
+	re, err := p.compile()
+	if err != nil {
+		return fmt.Errorf("正则无效: %v", err)
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if _, ok := patternEventSetters[name]; !ok {
+			return fmt.Errorf("不支持的命名分组 %q", name)
+		}
+		named = true
+	}
+	if !named {
+		return fmt.Errorf("正则至少需要一个命名分组")
+	}
+	if p.Event == scriptEventValue && p.Key == "" {
+		return fmt.Errorf("value 事件需要 key")
+	}
+	return nil
+}
+
+// 命名分组 -> 事件字段
+var patternEventSetters = map[string]func(ev *ScriptEvent, v string){
+	"token":     func(ev *ScriptEvent, v string) { ev.Token = v },
+	"balance":   func(ev *ScriptEvent, v string) { ev.Balance = v },
+	"price":     func(ev *ScriptEvent, v string) { ev.Price = v },
+	"source":    func(ev *ScriptEvent, v string) { ev.Source = v },
+	"signature": func(ev *ScriptEvent, v string) { ev.Signature = v },
+	"action":    func(ev *ScriptEvent, v string) { ev.Action = v },
+	"amount":    func(ev *ScriptEvent, v string) { ev.Amount = v },
+	"account":   func(ev *ScriptEvent, v string) { ev.Account = v },
+	"status":    func(ev *ScriptEvent, v string) { ev.Status = v },
+	"code":      func(ev *ScriptEvent, v string) { ev.Code = v },
+	"value":     func(ev *ScriptEvent, v string) { ev.Value, _ = strconv.ParseFloat(v, 64) },
+}
+
+// outputPatterns 注册项的回退规则（未设置时为内置规则）
+func outputPatterns(target string, s ScriptSpec) []OutputPattern {
+	if s.Output.Patterns != nil {
+		return s.Output.Patterns
+	}
+	return defaultOutputPatterns[target]
+}
+
+// normalizeScriptOutput 把 JSON 事件行加上 @@event 前缀；仍没有结构化事件时按回退规则生成事件行追加在末尾
+func normalizeScriptOutput(target string, s ScriptSpec, out []byte) []byte {
+	if len(out) == 0 {
+		return out
+	}
+	if s.Output.Format == scriptOutputJSON {
+		out = prefixJSONEvents(out)
+	}
+	patterns := outputPatterns(target, s)
+	if len(patterns) == 0 || decodeScriptOutput(out).Structured() {
+		return out
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(string(out), "\n") {
+		for _, p := range patterns {
+			re, err := p.compile()
+			if err != nil {
+				continue
+			}
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			ev := ScriptEvent{Type: p.Event, Key: p.Key}
+			for i, name := range re.SubexpNames() {
+				if set := patternEventSetters[name]; set != nil && m[i] != "" {
+					set(&ev, strings.TrimSpace(m[i]))
+				}
+			}
+			line, _ := json.Marshal(ev)
+			b.WriteString(scriptEventPrefix + string(line) + "\n")
+		}
+	}
+	if b.Len() == 0 {
+		return out
+	}
+	if out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return append(out, b.String()...)
+}
+
+// prefixJSONEvents 带 type 字段的 JSON 对象行改写为 @@event 行，其余行保持不变
+func prefixJSONEvents(out []byte) []byte {
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "{") {
+			continue
+		}
+		var ev ScriptEvent
+		if err := json.Unmarshal([]byte(trimmed), &ev); err == nil && ev.Type != "" {
+			lines[i] = scriptEventPrefix + trimmed
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
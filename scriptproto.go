@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// 子进程结构化输出协议：脚本在普通日志之外，每个机器可读事件单独输出一行
+//
+//	@@event {"type":"price","price":"0.0123","source":"okx"}
+//
+// 事件类型：
+//   - status    {"status":"ok|error","code":"..."}        脚本最终状态
+//   - price     {"price":"...","source":"..."}           价格（字符串，保持原始精度）
+//   - signature {"signature":"...","action":"..."}       已发送的交易签名
+//   - error     {"code":"...","message":"...","retryable":true}
+//   - token     {"token":"...","balance":"..."}          持仓代币（balance 为代币数量）
+//   - value     {"key":"...","value":1.23}               数值指标（如 positionValueUSD、solUSD、claimedUSD、feeSOL）
+//   - claimed   {"token":"...","amount":"..."}           本次领取到账的代币数量
+//   - account   {"token":"...","account":"..."}          已存在或已创建的关联代币账户
+//   - ratelimit {"api":"okx","httpStatus":200,"remaining":9,"limit":10,"resetSeconds":1,"retryAfter":0}
+//     上游 API 响应的限速信息（计入共享请求预算）
+//
+// 不支持事件行的命令（jupSwap 二进制）由 runExternal 按注册项的 output.patterns 生成事件（见 scriptparse.go）；
+// 旧脚本没有事件行时，价格与领取估值回退到原有的文本解析。
+const scriptEventPrefix = "@@event "
+
+// 事件类型
+const (
+	scriptEventStatus    = "status"
+	scriptEventPrice     = "price"
+	scriptEventSignature = "signature"
+	scriptEventError     = "error"
+	scriptEventToken     = "token"
+	scriptEventValue     = "value"
+	scriptEventClaimed   = "claimed"
+	scriptEventAccount   = "account"
+	scriptEventRateLimit = "ratelimit"
+)
+
+// ScriptEvent 子进程输出的一个结构化事件
+type ScriptEvent struct {
+	Type      string  `json:"type"`
+	Status    string  `json:"status,omitempty"`
+	Code      string  `json:"code,omitempty"`
+	Message   string  `json:"message,omitempty"`
+	Retryable bool    `json:"retryable,omitempty"`
+	Price     string  `json:"price,omitempty"`
+	Source    string  `json:"source,omitempty"`
+	Signature string  `json:"signature,omitempty"`
+	Action    string  `json:"action,omitempty"`
+	Token     string  `json:"token,omitempty"`
+	Balance   string  `json:"balance,omitempty"`
+	Amount    string  `json:"amount,omitempty"`
+	Key       string  `json:"key,omitempty"`
+	Value     float64 `json:"value,omitempty"`
+	Account   string  `json:"account,omitempty"`
+	// ratelimit 事件
+	API          string   `json:"api,omitempty"`
+	HTTPStatus   int      `json:"httpStatus,omitempty"`
+	Remaining    *float64 `json:"remaining,omitempty"`
+	Limit        float64  `json:"limit,omitempty"`
+	ResetSeconds float64  `json:"resetSeconds,omitempty"`
+	RetryAfter   float64  `json:"retryAfter,omitempty"`
+}
+
+// ScriptOutput 解码后的子进程输出
+type ScriptOutput struct {
+	Raw    string
+	Events []ScriptEvent
+}
+
+// decodeScriptOutput 提取输出中的事件行（无法解析的事件行忽略）
+func decodeScriptOutput(out []byte) ScriptOutput {
+	o := ScriptOutput{Raw: string(out)}
+	for _, line := range strings.Split(o.Raw, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, scriptEventPrefix) {
+			continue
+		}
+		var ev ScriptEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, scriptEventPrefix)), &ev); err != nil || ev.Type == "" {
+			continue
+		}
+		o.Events = append(o.Events, ev)
+	}
+	return o
+}
+
+// Structured 脚本是否输出了结构化事件
+func (o ScriptOutput) Structured() bool { return len(o.Events) > 0 }
+
+func (o ScriptOutput) eventsOf(typ string) []ScriptEvent {
+	var evs []ScriptEvent
+	for _, ev := range o.Events {
+		if ev.Type == typ {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// Price 最后一个价格事件；旧脚本回退为包含 "price:" 的最后一行
+func (o ScriptOutput) Price() string {
+	if evs := o.eventsOf(scriptEventPrice); len(evs) > 0 {
+		return evs[len(evs)-1].Price
+	}
+	var price string
+	for _, line := range strings.Split(o.Raw, "\n") {
+		if parts := strings.Split(line, "price:"); len(parts) > 1 {
+			price = strings.TrimSpace(parts[1])
+		}
+	}
+	return price
+}
+
+// Signatures 交易签名；没有结构化事件时按签名格式匹配整段输出
+func (o ScriptOutput) Signatures() []string {
+	if !o.Structured() {
+		return signaturePattern.FindAllString(o.Raw, -1)
+	}
+	var sigs []string
+	for _, ev := range o.eventsOf(scriptEventSignature) {
+		sigs = append(sigs, ev.Signature)
+	}
+	return sigs
+}
+
+// Tokens 持仓代币（token 事件，jupSwap 的文本输出由 output.patterns 生成）
+func (o ScriptOutput) Tokens() []string {
+	var tokens []string
+	for _, ev := range o.eventsOf(scriptEventToken) {
+		tokens = append(tokens, ev.Token)
+	}
+	return tokens
+}
+
+// TokenBalances 持仓代币的余额（代币数量）
+func (o ScriptOutput) TokenBalances() map[string]float64 {
+	balances := map[string]float64{}
+	for _, ev := range o.eventsOf(scriptEventToken) {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(ev.Balance), 64); err == nil {
+			balances[ev.Token] = v
+		}
+	}
+	return balances
+}
+
+// Value 数值事件；没有对应事件时在旧输出中查找 legacyMarker 之后的数字
+func (o ScriptOutput) Value(key, legacyMarker string) (float64, bool) {
+	for _, ev := range o.eventsOf(scriptEventValue) {
+		if ev.Key == key {
+			return ev.Value, true
+		}
+	}
+	if legacyMarker == "" {
+		return 0, false
+	}
+	for _, line := range strings.Split(o.Raw, "\n") {
+		if idx := strings.Index(line, legacyMarker); idx >= 0 {
+			v, err := strconv.ParseFloat(strings.TrimSpace(line[idx+len(legacyMarker):]), 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Claimed 本次领取的代币数量（按代币累加）；旧输出在 "✅ 领取完成" 时取 "X代币地址:" / "Y代币地址:" 与
+// 依次出现的两行 "可领取 ... 费用 (实际): <数量>"
+func (o ScriptOutput) Claimed() map[string]float64 {
+	claimed := map[string]float64{}
+	for _, ev := range o.eventsOf(scriptEventClaimed) {
+		if v, err := strconv.ParseFloat(ev.Amount, 64); err == nil && ev.Token != "" {
+			claimed[ev.Token] += v
+		}
+	}
+	if o.Structured() || !strings.Contains(o.Raw, "✅ 领取完成") {
+		return claimed
+	}
+	var mints []string
+	var amounts []float64
+	for _, line := range strings.Split(o.Raw, "\n") {
+		for _, marker := range []string{"X代币地址:", "Y代币地址:"} {
+			if idx := strings.Index(line, marker); idx >= 0 {
+				mints = append(mints, strings.TrimSpace(line[idx+len(marker):]))
+			}
+		}
+		if idx := strings.Index(line, "费用 (实际):"); idx >= 0 && strings.Contains(line, "可领取") {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(line[idx+len("费用 (实际):"):]), 64); err == nil {
+				amounts = append(amounts, v)
+			}
+		}
+	}
+	for i := 0; i < len(mints) && i < len(amounts); i++ {
+		if mints[i] != "" && amounts[i] > 0 {
+			claimed[mints[i]] += amounts[i]
+		}
+	}
+	return claimed
+}
+
+// Accounts 已就绪的关联代币账户：代币 -> 账户地址
+func (o ScriptOutput) Accounts() map[string]string {
+	accounts := map[string]string{}
+	for _, ev := range o.eventsOf(scriptEventAccount) {
+		if ev.Token != "" {
+			accounts[ev.Token] = ev.Account
+		}
+	}
+	return accounts
+}
+
+// Error 第一个错误事件
+func (o ScriptOutput) Error() *ScriptEvent {
+	if evs := o.eventsOf(scriptEventError); len(evs) > 0 {
+		return &evs[0]
+	}
+	return nil
+}
+
+// Status 最后一个状态事件（未输出时为空）
+func (o ScriptOutput) Status() string {
+	if evs := o.eventsOf(scriptEventStatus); len(evs) > 0 {
+		return evs[len(evs)-1].Status
+	}
+	return ""
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 外部命令目标（同 /metrics 的 script 标签与 exec.targets 的键）
+const (
+	scriptAddLiquidity           = "addLiquidity"
+	scriptClaimAllRewards        = "claimAllRewards"
+	scriptFetchPrice             = "fetchPrice"
+	scriptRemoveLiquidity        = "removeLiquidity"
+	scriptRemoveLiquidityPartial = "removeLiquidityPartial"
+	scriptCreateTokenAccounts    = "createTokenAccounts"
+	scriptJupSwap                = "jupSwap"
+	scriptJupSwapBalances        = "jupSwapBalances"
+)
+
+// 代码中调用的全部目标，注册表必须包含
+var scriptTargets = []string{
+	scriptAddLiquidity, scriptClaimAllRewards, scriptFetchPrice, scriptRemoveLiquidity,
+	scriptRemoveLiquidityPartial, scriptCreateTokenAccounts, scriptJupSwap, scriptJupSwapBalances,
+}
+
+// 脚本输出格式
+const (
+	scriptOutputEvents = "events" // 按 @@event 协议输出结构化事件（见 scriptproto.go）
+	scriptOutputJSON   = "json"   // 每行一个 JSON 事件（不带 @@event 前缀，见 scriptparse.go）
+	scriptOutputText   = "text"   // 只有文本输出（jupSwap 二进制），不检查，按 patterns 生成事件
+)
+
+// 执行超时与输出不符合约定（strict）时返回的错误
+var (
+	errScriptTimeout = errors.New("script timeout")
+	errOutputSchema  = errors.New("script output schema mismatch")
+)
+
+// ScriptsConfig 外部命令注册表：每个目标的命令、超时、工作目录、额外环境变量与期望的输出格式，所有外部命令经 runExternal 按此执行
+type ScriptsConfig struct {
+	EnvPassthrough []string              `json:"envPassthrough"` // 从本进程继承的环境变量（NAME_* 按前缀匹配，"*" 表示全部继承）；其余变量不传给子进程
+	Registry       map[string]ScriptSpec `json:"registry"`       // 按目标整体覆盖
+}
+
+// ScriptSpec 一个外部命令
+type ScriptSpec struct {
+	Command        string            `json:"command"`        // 可执行文件（npx、./jupSwap 等）
+	Args           []string          `json:"args"`           // 固定参数（如 ts-node addLiquidity.ts），调用方的参数追加在后
+	Dir            string            `json:"dir"`            // 工作目录，相对路径按程序目录解析，为空时为程序目录
+	TimeoutSeconds int               `json:"timeoutSeconds"` // 单次执行超时（每次重试单独计时），超时终止整个进程组；0 表示不限制
+	Env            map[string]string `json:"env"`            // 额外环境变量（如 RPC_URL、KEYPAIR_PATH），值中的 ${NAME} 取自进程环境或 .env；优先于钱包与 RPC 节点池注入的值
+	KeyEnv         string            `json:"keyEnv"`         // 私钥来自 keys 时经该环境变量传入明文（只能从环境变量读取私钥的可执行文件）；为空时经继承的管道传入（PRIVATE_KEY_FD）
+	Output         ScriptOutputSpec  `json:"output"`
+}
+
+// ScriptOutputSpec 期望的输出格式
+type ScriptOutputSpec struct {
+	Format   string          `json:"format"`             // events | json | text
+	Require  []string        `json:"require"`            // 成功退出时必须出现的事件类型
+	Strict   bool            `json:"strict"`             // 不符合时视为执行失败（不重试）；默认只告警并计数
+	JSONFlag string          `json:"jsonFlag,omitempty"` // 追加在固定参数之后、让子进程输出 JSON 事件的参数（如 -json）
+	Patterns []OutputPattern `json:"patterns,omitempty"` // 没有结构化事件时按正则生成事件；未设置时使用内置规则，[] 表示不使用
+}
+
+func (c ScriptsConfig) validate() error {
+	for _, target := range scriptTargets {
+		if _, ok := c.Registry[target]; !ok {
+			return fmt.Errorf("scripts.registry 缺少 %s", target)
+		}
+	}
+	for target, s := range c.Registry {
+		if s.Command == "" {
+			return fmt.Errorf("scripts.registry.%s.command 不能为空", target)
+		}
+		if s.TimeoutSeconds < 0 {
+			return fmt.Errorf("scripts.registry.%s.timeoutSeconds 不能为负数", target)
+		}
+		switch s.Output.Format {
+		case scriptOutputEvents, scriptOutputJSON:
+		case scriptOutputText:
+			if len(s.Output.Require) > 0 {
+				return fmt.Errorf("scripts.registry.%s.output.require 仅用于 %s 或 %s 格式", target, scriptOutputEvents, scriptOutputJSON)
+			}
+		default:
+			return fmt.Errorf("scripts.registry.%s.output.format 仅支持 %s、%s 或 %s", target, scriptOutputEvents, scriptOutputJSON, scriptOutputText)
+		}
+		for i, p := range s.Output.Patterns {
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("scripts.registry.%s.output.patterns[%d]: %v", target, i, err)
+			}
+		}
+		for k := range s.Env {
+			if k == "" || strings.ContainsAny(k, "= ") {
+				return fmt.Errorf("scripts.registry.%s.env 的变量名无效: %q", target, k)
+			}
+		}
+		if strings.ContainsAny(s.KeyEnv, "= ") {
+			return fmt.Errorf("scripts.registry.%s.keyEnv 的变量名无效: %q", target, s.KeyEnv)
+		}
+	}
+	return nil
+}
+
+// 默认继承的环境变量：系统与 Node 运行所需、代理与证书，以及脚本读取的变量（私钥也可只放在 .env 中）
+var defaultEnvPassthrough = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_*", "TZ", "TMPDIR", "TERM",
+	"NODE_*", "npm_config_*", "NPM_CONFIG_*",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "SSL_CERT_FILE", "SSL_CERT_DIR",
+	"METEORA_*", "PRIVATE_KEY*", "USER_WALLET_ADDRESS", "RPC_URL", "OKX_*", "ENABLE_OKX", "BIN_RANGE_MODE",
+}
+
+// defaultScriptRegistry 内置的外部命令：超时取各调用处原有的整体超时量级；领取可能在脚本内触发移除流动性，留得更长
+func defaultScriptRegistry() map[string]ScriptSpec {
+	tsNode := func(file string, timeout int, require ...string) ScriptSpec {
+		return ScriptSpec{
+			Command:        "npx",
+			Args:           []string{"ts-node", file},
+			TimeoutSeconds: timeout,
+			Output:         ScriptOutputSpec{Format: scriptOutputEvents, Require: require},
+		}
+	}
+	jupSwap := ScriptSpec{Command: "./jupSwap", TimeoutSeconds: 30, KeyEnv: "PRIVATE_KEY", Output: ScriptOutputSpec{Format: scriptOutputText}}
+	return map[string]ScriptSpec{
+		scriptAddLiquidity:           tsNode("addLiquidity.ts", 300, scriptEventSignature),
+		scriptClaimAllRewards:        tsNode("claimAllRewards.ts", 300, scriptEventStatus),
+		scriptFetchPrice:             tsNode("fetchPrice.ts", 60, scriptEventPrice),
+		scriptRemoveLiquidity:        tsNode("removeLiquidity.ts", 120, scriptEventStatus),
+		scriptRemoveLiquidityPartial: tsNode("removeLiquidity.ts", 120, scriptEventStatus),
+		scriptCreateTokenAccounts:    tsNode("createTokenAccounts.ts", 120, scriptEventStatus),
+		scriptJupSwap:                jupSwap,
+		scriptJupSwapBalances:        jupSwap,
+	}
+}
+
+// scriptSpec 目标的注册项（配置校验保证存在）
+func scriptSpec(target string) ScriptSpec {
+	if s, ok := currentConfig().Scripts.Registry[target]; ok {
+		return s
+	}
+	return defaultScriptRegistry()[target]
+}
+
+// scriptCommandLine 目标的完整命令行（命令、固定参数与调用方参数），用于 paper 模式记录与日志
+func scriptCommandLine(target string, args ...string) []string {
+	s := scriptSpec(target)
+	return append(append([]string{s.Command}, s.fixedArgs()...), args...)
+}
+
+// fixedArgs 固定参数（含切换到 JSON 输出的参数），调用方的参数追加在后
+func (s ScriptSpec) fixedArgs() []string {
+	if s.Output.JSONFlag == "" {
+		return s.Args
+	}
+	return append(s.Args[:len(s.Args):len(s.Args)], s.Output.JSONFlag)
+}
+
+// dir 工作目录
+func (s ScriptSpec) dir() string {
+	if s.Dir == "" {
+		return appBaseDir
+	}
+	return s.Dir
+}
+
+// scriptEnv 子进程环境：按 envPassthrough 过滤的本进程环境、目录变量、钱包变量、RPC 节点池的当前最优节点，最后是注册项的 env
+func scriptEnv(s ScriptSpec, wallet []string, rpcURL string) []string {
+	patterns := currentConfig().Scripts.EnvPassthrough
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if envAllowed(patterns, name) {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, envBaseDir+"="+appBaseDir, envDataDir+"="+appDataDir)
+	env = append(env, wallet...)
+	if _, ok := s.Env["RPC_URL"]; !ok && rpcURL != "" {
+		env = append(env, "RPC_URL="+rpcURL)
+	}
+	names := make([]string, 0, len(s.Env))
+	for name := range s.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+os.Expand(s.Env[name], lookupEnv))
+	}
+	return env
+}
+
+// envAllowed 变量名是否匹配 envPassthrough（精确匹配，或以 * 结尾时按前缀匹配）
+func envAllowed(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == name || (strings.HasSuffix(p, "*") && strings.HasPrefix(name, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScriptOutput 成功退出时检查输出是否符合注册项的格式：缺少结构化事件或必需的事件类型时告警并计数，strict 时返回错误
+func checkScriptOutput(target string, s ScriptSpec, out []byte) error {
+	if s.Output.Format == scriptOutputText {
+		return nil
+	}
+	decoded := decodeScriptOutput(out)
+	var missing []string
+	if !decoded.Structured() {
+		missing = append(missing, "@@event")
+	} else {
+		for _, typ := range s.Output.Require {
+			if len(decoded.eventsOf(typ)) == 0 {
+				missing = append(missing, typ)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	metricScriptSchema.Inc(target)
+	logWarn("⚠️ 外部命令输出不符合约定", "target", target, "missing", strings.Join(missing, ","), "strict", s.Output.Strict)
+	if s.Output.Strict {
+		return fmt.Errorf("%w: %s 缺少 %s", errOutputSchema, target, strings.Join(missing, "、"))
+	}
+	return nil
+}
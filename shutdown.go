@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownConfig 收到关闭信号后的排空策略
+type ShutdownConfig struct {
+	GracePeriodSeconds int      `json:"gracePeriodSeconds"` // 等待进行中的外部命令与新池任务完成的最长时间，超时后终止
+	ResumeTargets      []string `json:"resumeTargets"`      // 被中断后下次启动时重新执行的目标（需可重复执行）
+}
+
+// PendingJob 一个正在执行的外部命令（预写日志 data/state/wal.jsonl 的 intent，见 wal.go）
+type PendingJob struct {
+	ID         string   `json:"id"`
+	Target     string   `json:"target"`
+	Args       []string `json:"args"`
+	Pool       string   `json:"poolAddress,omitempty"`
+	Token      string   `json:"ca,omitempty"`
+	OutputMint string   `json:"outputMint,omitempty"`
+	Wallet     string   `json:"wallet,omitempty"`
+	Position   string   `json:"positionAddress,omitempty"` // 平仓时的仓位地址（核对链上是否已关闭）
+	StartedAt  string   `json:"startedAt"`
+}
+
+// errShuttingDown 正在关闭，不再启动新的外部命令
+var errShuttingDown = errors.New("正在关闭，不再执行新的命令")
+
+// 排空期间的进度日志间隔
+const drainLogInterval = 10 * time.Second
+
+var (
+	jobMutex        sync.Mutex
+	activeJobs      = map[string]PendingJob{}
+	interruptedJobs = map[string]PendingJob{} // 上次运行中断（预写日志中没有结束记录）、或本次关闭期间失败 / 被终止的命令
+	jobSeq          atomic.Int64
+
+	// 宽限期结束时取消，终止仍在执行的外部命令
+	hardStopCtx, hardStop = context.WithCancel(context.Background())
+)
+
+func (c ShutdownConfig) validate() error {
+	if c.GracePeriodSeconds < 0 {
+		return fmt.Errorf("shutdown.gracePeriodSeconds 不能为负数")
+	}
+	for _, t := range c.ResumeTargets {
+		if t != "claimAllRewards" && t != "jupSwap" {
+			return fmt.Errorf("shutdown.resumeTargets 仅支持 claimAllRewards、jupSwap: %s", t)
+		}
+	}
+	return nil
+}
+
+// shuttingDown 已收到关闭信号
+func shuttingDown() bool { return globalCtx != nil && globalCtx.Err() != nil }
+
+// commandContext 外部命令使用的上下文：不随关闭信号取消（只保留调用方的超时与其他取消原因），宽限期结束时才终止
+func commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	cmdCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		cmdCtx, cancelDeadline = context.WithDeadline(cmdCtx, deadline)
+		prev := cancel
+		cancel = func() { cancelDeadline(); prev() }
+	}
+	stopCaller := context.AfterFunc(ctx, func() {
+		// 调用方因关闭信号取消时继续执行，其他原因照常取消
+		if !shuttingDown() {
+			cancel()
+		}
+	})
+	stopHard := context.AfterFunc(hardStopCtx, cancel)
+	return cmdCtx, func() {
+		stopCaller()
+		stopHard()
+		cancel()
+	}
+}
+
+// 正在执行的外部命令（按开始时间排序）
+func listActiveJobs() []PendingJob {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	result := make([]PendingJob, 0, len(activeJobs))
+	for _, job := range activeJobs {
+		result = append(result, job)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].StartedAt < result[b].StartedAt })
+	return result
+}
+
+func activeJobCount() int {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	return len(activeJobs)
+}
+
+// drainInFlight 等待进行中的外部命令与新池任务完成，超过宽限期后终止剩余命令
+func drainInFlight() {
+	grace := time.Duration(currentConfig().Shutdown.GracePeriodSeconds) * time.Second
+	deadline := time.Now().Add(grace)
+	lastLog := time.Time{}
+	for {
+		jobs, tasks := activeJobCount(), inFlightTasks.Load()
+		if jobs == 0 && tasks == 0 {
+			logOutput("✅ 进行中的任务已全部完成\n")
+			return
+		}
+		if !time.Now().Before(deadline) {
+			logWarn("⏰ 宽限期已到，终止剩余的外部命令", "commands", jobs, "tasks", tasks, "grace", grace)
+			hardStop()
+			return
+		}
+		if time.Since(lastLog) >= drainLogInterval {
+			logOutput("⏳ 等待 %d 个外部命令、%d 个新池任务完成（剩余 %v）\n", jobs, tasks, time.Until(deadline).Round(time.Second))
+			lastLog = time.Now()
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 手动注入与回放信号：构造的信号或 CSV 中的历史行经 handleSignal 进入与实时信号相同的流程（字段校验、过滤、准入、写池文件），
+// 守护进程运行时由它监听 data 目录开仓；--open 时在本进程内立即处理写出的池文件（守护进程未运行时使用）
+
+// signal inject 默认的信号来源名
+const manualSignalSource = "manual"
+
+var (
+	savedRowsMutex sync.Mutex
+	savedRows      []string // 本进程写入的池文件（signal 子命令收集，守护进程中为 nil 不收集）
+	collectRows    bool
+
+	// 同一代币已持仓且策略为 replace 时，平掉旧池后再异步写池文件；一次性子命令退出前等待
+	pendingPoolRows sync.WaitGroup
+)
+
+// noteSavedPoolRow 记录写入的池文件（savePoolRow 调用）
+func noteSavedPoolRow(path string) {
+	savedRowsMutex.Lock()
+	defer savedRowsMutex.Unlock()
+	if collectRows {
+		savedRows = append(savedRows, path)
+	}
+}
+
+// takeSavedPoolRows 取出并清空已记录的池文件
+func takeSavedPoolRows() []string {
+	savedRowsMutex.Lock()
+	defer savedRowsMutex.Unlock()
+	rows := savedRows
+	savedRows = nil
+	return rows
+}
+
+// signalFields 可重复的 --field key=value
+type signalFields map[string]string
+
+func (f signalFields) String() string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k+"="+f[k])
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func (f signalFields) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if k = strings.TrimSpace(k); !ok || k == "" {
+		return fmt.Errorf("字段格式应为 key=value: %q", s)
+	}
+	f[k] = v
+	return nil
+}
+
+func cmdSignal(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "用法:\n  %[1]s signal inject --pool <addr> [--token <ca>] [--field key=value ...] [--source manual] [--ignore-age] [--open]\n"+
+			"  %[1]s signal replay --from-line N [--to-line M] [--source <csv源>] [--file <csv>] [--ignore-age] [--open]\n", os.Args[0])
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+	switch args[0] {
+	case "inject":
+		cmdSignalInject(args[1:])
+	case "replay":
+		cmdSignalReplay(args[1:])
+	default:
+		usage()
+	}
+}
+
+// signalRunFlags inject 与 replay 共用的参数
+type signalRunFlags struct {
+	ignoreAge *bool
+	open      *bool
+}
+
+func newSignalRunFlags(fs *flag.FlagSet) *signalRunFlags {
+	return &signalRunFlags{
+		ignoreAge: fs.Bool("ignore-age", false, "不按 signalFreshness 拒绝（信号带 "+signalIgnoreAgeField+"=true，开仓前同样不检查）"),
+		open:      fs.Bool("open", false, "在本进程内立即处理写出的池文件并开仓（守护进程未运行时使用，运行中时由它处理）"),
+	}
+}
+
+// cmdSignalInject 构造一条信号：pool 与 token 之外的字段用 --field 指定，未指定 last_updated_first 时按当前时间
+func cmdSignalInject(args []string) {
+	fs, common := newCommandFlags("signal inject")
+	pool := fs.String("pool", "", "池地址")
+	token := fs.String("token", "", "代币 ca")
+	source := fs.String("source", manualSignalSource, "信号来源名（写入池文件的 source）")
+	fields := signalFields{}
+	fs.Var(fields, "field", "其他信号字段 key=value，可重复（如 --field liquidity=50000）")
+	run := newSignalRunFlags(fs)
+	fs.Parse(args)
+	if *pool == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+
+	fields["poolAddress"] = *pool
+	if *token != "" {
+		fields["ca"] = *token
+	}
+	if _, ok := fields[signalTimeField]; !ok {
+		fields[signalTimeField] = appNow().Format(time.RFC3339)
+	}
+	if *run.ignoreAge {
+		fields[signalIgnoreAgeField] = "true"
+	}
+	payload, _ := json.Marshal(fields)
+	signals, err := parseSignalJSON(*source, "", payload)
+	if err != nil {
+		log.Fatalf("构造信号失败: %v", err)
+	}
+	if !runSignals(signals, *run.open) {
+		os.Exit(1)
+	}
+}
+
+// cmdSignalReplay 从 CSV 源的第 N 行（表头为第 1 行）起重新处理，按该源的表头映射与输出目录
+func cmdSignalReplay(args []string) {
+	fs, common := newCommandFlags("signal replay")
+	fromLine := fs.Int("from-line", 0, "起始行号（含，表头为第 1 行）")
+	toLine := fs.Int("to-line", 0, "结束行号（含），0 表示到文件末尾")
+	sourceName := fs.String("source", "", "csvSources 中的源名（只有一个源时可省略）")
+	file := fs.String("file", "", "读取的 CSV 文件（默认该源的 path，可指定轮转后的旧文件）")
+	run := newSignalRunFlags(fs)
+	fs.Parse(args)
+	if *fromLine < 2 || (*toLine > 0 && *toLine < *fromLine) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	defer initApp(common)()
+	startCommandContext()
+	defer globalCancel()
+
+	var source *CSVSourceConfig
+	for i, s := range currentConfig().CSVSources {
+		if s.Name == *sourceName || (*sourceName == "" && len(currentConfig().CSVSources) == 1) {
+			source = &currentConfig().CSVSources[i]
+			break
+		}
+	}
+	if source == nil {
+		log.Fatalf("请用 --source 指定 csvSources 中的源（当前配置 %d 个）", len(currentConfig().CSVSources))
+	}
+	path := source.Path
+	if *file != "" {
+		path = *file
+	}
+	signals, err := readCSVSignals(*source, path, *fromLine, *toLine)
+	if err != nil {
+		log.Fatalf("读取CSV失败: %v", err)
+	}
+	if len(signals) == 0 {
+		log.Fatalf("%s 中没有第 %d 行之后的记录", path, *fromLine)
+	}
+	if *run.ignoreAge {
+		for _, sig := range signals {
+			sig.Data[signalIgnoreAgeField] = "true"
+		}
+	}
+	if !runSignals(signals, *run.open) {
+		os.Exit(1)
+	}
+}
+
+// readCSVSignals 读取 CSV 中 [from, to] 行的记录（to 为 0 时到末尾，末尾的半截行忽略）
+func readCSVSignals(source CSVSourceConfig, path string, from, to int) ([]Signal, error) {
+	end, _, err := lastLineBoundary(path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// 逐行读取，不把整个文件载入内存
+	reader := csv.NewReader(bufio.NewReader(io.LimitReader(file, end)))
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %v", err)
+	}
+	fields, err := mapCSVHeaders(source, headers)
+	if err != nil {
+		return nil, err
+	}
+	var signals []Signal
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			logWarn("⚠️ 跳过无法解析的CSV行", "line", line, "error", err)
+			continue
+		}
+		if line < from {
+			continue
+		}
+		if to > 0 && line > to {
+			break
+		}
+		data := make(map[string]interface{})
+		for i, value := range record {
+			if i < len(fields) {
+				data[fields[i]] = value
+			}
+		}
+		signals = append(signals, Signal{Source: source.Name, OutputDir: source.outputDir(), Data: data, Headers: headers, Record: record, Line: line})
+	}
+	return signals, nil
+}
+
+// runSignals 逐条经 handleSignal 处理并输出结果；open 时处理写入 data 目录的池文件。有信号未写出池文件时返回 false
+func runSignals(signals []Signal, open bool) bool {
+	savedRowsMutex.Lock()
+	collectRows = true
+	savedRowsMutex.Unlock()
+
+	ok := true
+	var saved []string
+	for _, sig := range signals {
+		pool, _ := sig.Data["poolAddress"].(string)
+		callRecovered("signal:"+sig.Source, pool, func() { handleSignal(sig) })
+		rows := takeSavedPoolRows()
+		switch {
+		case len(rows) > 0:
+			fmt.Printf("✅ 第 %d 行 %s -> %s\n", sig.Line, pool, strings.Join(rows, ", "))
+		case sig.Line > 0:
+			ok = false
+			fmt.Printf("⏭️ 第 %d 行 %s 未写出池文件（原因见上方日志与 GET /skips）\n", sig.Line, pool)
+		default:
+			ok = false
+			fmt.Printf("⏭️ %s 未写出池文件（原因见上方日志与 GET /skips）\n", pool)
+		}
+		saved = append(saved, rows...)
+	}
+	// 同一代币按 replace 策略平掉旧池后写出的池文件
+	pendingPoolRows.Wait()
+	if rows := takeSavedPoolRows(); len(rows) > 0 {
+		fmt.Printf("✅ 平掉同代币的旧池后写出: %s\n", strings.Join(rows, ", "))
+		saved = append(saved, rows...)
+	}
+
+	if !open {
+		return ok
+	}
+	for _, path := range saved {
+		if filepath.Dir(path) != poolDataDir() {
+			fmt.Printf("📁 %s 不在 data 目录，只落盘不开仓\n", path)
+			continue
+		}
+		if !claimProcessed(path) {
+			fmt.Printf("⏭️ %s 已处理过\n", path)
+			continue
+		}
+		outcome := processNewJSONFile(path)
+		markProcessed(path, outcome)
+		fmt.Printf("📈 %s: %s\n", path, outcome)
+	}
+	return ok
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 信号字段 last_updated_first：信号源首次列出该池的时间，即信号产生时间。
+// 支持 "2006-01-02 15:04:05"（按 signalFreshness.timezone 解析）、RFC3339 与 Unix 秒 / 毫秒时间戳
+const signalTimeField = "last_updated_first"
+
+// 信号字段 ignoreAge：为 true 时不按新鲜度拒绝（signal inject / replay 的 --ignore-age 写入，用于补处理错过的信号）
+const signalIgnoreAgeField = "ignoreAge"
+
+// SignalFreshnessConfig 信号新鲜度：信号产生后超过 maxAgeMinutes 不再开仓（过期信号是亏损的主要来源）
+type SignalFreshnessConfig struct {
+	Enabled       bool    `json:"enabled"`
+	MaxAgeMinutes float64 `json:"maxAgeMinutes"` // 信号产生到开仓的最长时间
+	Timezone      string  `json:"timezone"`      // 不带时区的时间按该时区解析，默认 Asia/Shanghai
+	RejectMissing bool    `json:"rejectMissing"` // 缺少或无法解析 last_updated_first 时同样不开仓（默认放行）
+}
+
+// 信号时间晚于本机时间的容差（超过时记录警告，年龄按 0 计）
+const signalClockSkewTolerance = 2 * time.Minute
+
+// 信号年龄分布的桶（秒）
+var signalAgeBuckets = []float64{30, 60, 120, 300, 600, 1800, 3600, 7200, 18000}
+
+func (c SignalFreshnessConfig) validate() error {
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("signalFreshness.timezone 无效: %v", err)
+		}
+	}
+	if c.Enabled && c.MaxAgeMinutes <= 0 {
+		return fmt.Errorf("signalFreshness.maxAgeMinutes 必须大于0")
+	}
+	return nil
+}
+
+// 不带时区的信号时间所用的时区
+func signalLocation() *time.Location {
+	name := "Asia/Shanghai"
+	if currentConfig() != nil && currentConfig().SignalFreshness.Timezone != "" {
+		name = currentConfig().SignalFreshness.Timezone
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST8", 8*60*60)
+}
+
+// signalTime 信号中的产生时间（字段可为字符串或数字时间戳）
+func signalTime(data map[string]interface{}) (time.Time, error) {
+	switch v := data[signalTimeField].(type) {
+	case string:
+		return parseLastUpdatedFirstToTime(v)
+	case float64:
+		return epochToTime(v), nil
+	case nil:
+		return time.Time{}, fmt.Errorf("缺少 %s", signalTimeField)
+	default:
+		return time.Time{}, fmt.Errorf("%s 类型不支持: %T", signalTimeField, v)
+	}
+}
+
+// Unix 秒或毫秒时间戳（大于 1e12 视为毫秒）
+func epochToTime(v float64) time.Time {
+	if v > 1e12 {
+		return time.UnixMilli(int64(v))
+	}
+	return time.Unix(int64(v), 0)
+}
+
+// 字符串形式的数字时间戳
+func parseEpochString(s string) (time.Time, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 || strings.ContainsAny(s, "-:") {
+		return time.Time{}, false
+	}
+	return epochToTime(v), true
+}
+
+// signalAge 信号产生至今的时长；时间晚于本机时间时按 0 计
+func signalAge(data map[string]interface{}) (time.Duration, error) {
+	t, err := signalTime(data)
+	if err != nil {
+		return 0, err
+	}
+	age := appNow().Sub(t)
+	if age < -signalClockSkewTolerance {
+		logWarn("⚠️ 信号时间晚于本机时间", "time", t.Format(time.RFC3339), "ahead", (-age).Round(time.Second))
+	}
+	if age < 0 {
+		age = 0
+	}
+	return age, nil
+}
+
+// checkSignalFreshness 信号是否仍可开仓；不可开仓时返回原因说明
+func checkSignalFreshness(data map[string]interface{}) (string, bool) {
+	cfg := currentConfig().SignalFreshness
+	if !cfg.Enabled || signalFlag(data, signalIgnoreAgeField) {
+		return "", true
+	}
+	age, err := signalAge(data)
+	if err != nil {
+		if cfg.RejectMissing {
+			return "无法确定信号时间: " + err.Error(), false
+		}
+		return "", true
+	}
+	maxAge := time.Duration(cfg.MaxAgeMinutes * float64(time.Minute))
+	if age > maxAge {
+		return fmt.Sprintf("信号已产生 %v，超过 %v", age.Round(time.Second), maxAge), false
+	}
+	return "", true
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 签名校验失败的原因（meteora_signal_webhook_rejected_total 的 reason）
+const (
+	webhookRejectToken     = "token"
+	webhookRejectSignature = "signature"
+	webhookRejectTimestamp = "timestamp"
+	webhookRejectReplay    = "replay"
+	webhookRejectInvalid   = "invalid"
+)
+
+// errWebhookReplay 同一签名在时间窗口内重复出现
+var errWebhookReplay = errors.New("replayed request")
+
+var (
+	webhookReplayMutex sync.Mutex
+	// 时间窗口内已接受的签名 -> 接受时间
+	webhookSeenSignatures = map[string]time.Time{}
+)
+
+func (c HTTPIngestConfig) signatureHeader() string {
+	if c.SignatureHeader == "" {
+		return "X-Signature"
+	}
+	return c.SignatureHeader
+}
+
+func (c HTTPIngestConfig) timestampHeader() string {
+	if c.TimestampHeader == "" {
+		return "X-Signature-Timestamp"
+	}
+	return c.TimestampHeader
+}
+
+func (c HTTPIngestConfig) maxSkew() time.Duration {
+	if c.MaxSkewSeconds == 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(c.MaxSkewSeconds) * time.Second
+}
+
+// signWebhookPayload 签名 = hex(HMAC-SHA256(secret, "<timestamp>.<body>"))
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature 校验签名头（可带 sha256= 前缀）与时间戳头（Unix 秒），并拒绝时间窗口内重复的签名；返回失败原因
+func verifyWebhookSignature(cfg HTTPIngestConfig, r *http.Request, body []byte) (string, error) {
+	timestamp := strings.TrimSpace(r.Header.Get(cfg.timestampHeader()))
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return webhookRejectTimestamp, fmt.Errorf("missing or invalid %s", cfg.timestampHeader())
+	}
+	skew := time.Since(time.Unix(sec, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > cfg.maxSkew() {
+		return webhookRejectTimestamp, fmt.Errorf("timestamp outside the allowed window (%v)", cfg.maxSkew())
+	}
+	got := strings.TrimPrefix(strings.TrimSpace(r.Header.Get(cfg.signatureHeader())), "sha256=")
+	want := signWebhookPayload(cfg.HMACSecret, timestamp, body)
+	if !hmac.Equal([]byte(strings.ToLower(got)), []byte(want)) {
+		return webhookRejectSignature, errors.New("invalid signature")
+	}
+
+	webhookReplayMutex.Lock()
+	defer webhookReplayMutex.Unlock()
+	now := time.Now()
+	for sig, at := range webhookSeenSignatures {
+		if now.Sub(at) > 2*cfg.maxSkew() {
+			delete(webhookSeenSignatures, sig)
+		}
+	}
+	if _, seen := webhookSeenSignatures[want]; seen {
+		return webhookRejectReplay, errWebhookReplay
+	}
+	webhookSeenSignatures[want] = now
+	return "", nil
+}
+
+// authorizeSignalRequest 校验 token 与 HMAC 签名；失败时写出响应并返回 false
+func authorizeSignalRequest(w http.ResponseWriter, r *http.Request, cfg HTTPIngestConfig, body []byte) bool {
+	if cfg.Token != "" && !hmac.Equal([]byte(r.Header.Get("Authorization")), []byte("Bearer "+cfg.Token)) {
+		metricWebhookRejected.Inc(webhookRejectToken)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+	if cfg.HMACSecret == "" {
+		return true
+	}
+	reason, err := verifyWebhookSignature(cfg, r, body)
+	if err == nil {
+		return true
+	}
+	metricWebhookRejected.Inc(reason)
+	logWarn("⚠️ 拒绝签名无效的信号推送", "source", cfg.name(), "remote", r.RemoteAddr, "reason", reason, "error", err)
+	status := http.StatusUnauthorized
+	if err == errWebhookReplay {
+		status = http.StatusConflict
+	}
+	writeError(w, status, err.Error())
+	return false
+}
+
+// parsePushedSignals 按 Content-Type 解析推送的信号：text/csv 为带表头的 CSV（与 CSV 源的行相同），其他按 JSON 解析
+func parsePushedSignals(cfg HTTPIngestConfig, contentType string, body []byte) ([]Signal, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/csv" {
+		return parseSignalCSV(cfg.name(), outputDirOrData(cfg.OutputDir), body)
+	}
+	return parseSignalJSON(cfg.name(), outputDirOrData(cfg.OutputDir), body)
+}
+
+// parseSignalCSV 首行为表头，之后每行一条信号
+func parseSignalCSV(source, outputDir string, payload []byte) ([]Signal, error) {
+	r := csv.NewReader(bytes.NewReader(payload))
+	r.FieldsPerRecord = -1
+	headers, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+	for i := range headers {
+		headers[i] = strings.TrimSpace(strings.TrimPrefix(headers[i], "\ufeff"))
+	}
+	var signals []Signal
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV失败: %v", err)
+		}
+		if len(record) != len(headers) {
+			return nil, fmt.Errorf("CSV第%d行字段数 %d 与表头 %d 不一致", len(signals)+2, len(record), len(headers))
+		}
+		sig := Signal{Source: source, OutputDir: outputDir, Data: map[string]interface{}{}, Headers: headers, Record: record}
+		for i, h := range headers {
+			sig.Data[h] = record[i]
+		}
+		signals = append(signals, sig)
+	}
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("CSV中没有信号行")
+	}
+	return signals, nil
+}
+
+// serveSignalWebhook 在单独的地址上只提供 POST /signals（管理接口可继续只监听本机）
+func serveSignalWebhook(ctx context.Context, cfg HTTPIngestConfig) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signals", methodOnly(http.MethodPost, signalsHandler))
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           recoverHandler("signalWebhook", mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	logOutput("🌐 信号推送接口已启动: http://%s/signals\n", cfg.Listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// webhookInvalid 记录无法解析的推送
+func webhookInvalid(w http.ResponseWriter, err error) {
+	metricWebhookRejected.Inc(webhookRejectInvalid)
+	writeError(w, http.StatusBadRequest, err.Error())
+}
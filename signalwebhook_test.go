@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(cfg HTTPIngestConfig, body, timestamp, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/signals", strings.NewReader(body))
+	if timestamp != "" {
+		r.Header.Set(cfg.timestampHeader(), timestamp)
+	}
+	if signature != "" {
+		r.Header.Set(cfg.signatureHeader(), signature)
+	}
+	return r
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	cfg := HTTPIngestConfig{HMACSecret: "s3cret", MaxSkewSeconds: 60}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		body      string
+		timestamp string
+		signature string // 为空时按 secret 计算正确的签名
+		reason    string
+	}{
+		{"签名正确", `{"a":1}`, now, "", ""},
+		{"带 sha256= 前缀", `{"a":2}`, now, "sha256=" + signWebhookPayload("s3cret", now, []byte(`{"a":2}`)), ""},
+		{"大写十六进制", `{"a":3}`, now, strings.ToUpper(signWebhookPayload("s3cret", now, []byte(`{"a":3}`))), ""},
+		{"签名错误", `{"a":4}`, now, signWebhookPayload("other", now, []byte(`{"a":4}`)), webhookRejectSignature},
+		{"正文被改动", `{"a":5}`, now, signWebhookPayload("s3cret", now, []byte(`{"a":6}`)), webhookRejectSignature},
+		{"缺少时间戳", `{"a":7}`, "", "", webhookRejectTimestamp},
+		{"时间戳不是数字", `{"a":8}`, "yesterday", "", webhookRejectTimestamp},
+		{"时间戳过旧", `{"a":9}`, old, "", webhookRejectTimestamp},
+		{"时间戳超前", `{"a":10}`, future, "", webhookRejectTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := tt.signature
+			if sig == "" {
+				sig = signWebhookPayload(cfg.HMACSecret, tt.timestamp, []byte(tt.body))
+			}
+			reason, err := verifyWebhookSignature(cfg, signedRequest(cfg, tt.body, tt.timestamp, sig), []byte(tt.body))
+			if reason != tt.reason || (err == nil) != (tt.reason == "") {
+				t.Errorf("reason=%q err=%v，应为 %q", reason, err, tt.reason)
+			}
+		})
+	}
+
+	// 时间窗口内重复的签名视为重放
+	body := `{"replay":true}`
+	sig := signWebhookPayload(cfg.HMACSecret, now, []byte(body))
+	if _, err := verifyWebhookSignature(cfg, signedRequest(cfg, body, now, sig), []byte(body)); err != nil {
+		t.Fatalf("首次请求被拒绝: %v", err)
+	}
+	if reason, err := verifyWebhookSignature(cfg, signedRequest(cfg, body, now, sig), []byte(body)); reason != webhookRejectReplay || err != errWebhookReplay {
+		t.Errorf("重放应被拒绝，实际 reason=%q err=%v", reason, err)
+	}
+}
+
+func TestAuthorizeSignalRequest(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	tests := []struct {
+		name   string
+		cfg    HTTPIngestConfig
+		body   string
+		auth   string
+		sign   bool // 按 cfg 的 secret 签名
+		ok     bool
+		status int
+	}{
+		{"未配置鉴权", HTTPIngestConfig{}, `{"n":1}`, "", false, true, http.StatusOK},
+		{"令牌正确", HTTPIngestConfig{Token: "tok"}, `{"n":2}`, "Bearer tok", false, true, http.StatusOK},
+		{"缺少令牌", HTTPIngestConfig{Token: "tok"}, `{"n":3}`, "", false, false, http.StatusUnauthorized},
+		{"令牌错误", HTTPIngestConfig{Token: "tok"}, `{"n":4}`, "Bearer bad", false, false, http.StatusUnauthorized},
+		{"令牌与签名", HTTPIngestConfig{Token: "tok", HMACSecret: "k"}, `{"n":5}`, "Bearer tok", true, true, http.StatusOK},
+		{"令牌正确但未签名", HTTPIngestConfig{Token: "tok", HMACSecret: "k"}, `{"n":6}`, "Bearer tok", false, false, http.StatusUnauthorized},
+		{"只校验签名", HTTPIngestConfig{HMACSecret: "k"}, `{"n":7}`, "", true, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(tt.cfg, tt.body, now, "")
+			if tt.sign {
+				r.Header.Set(tt.cfg.signatureHeader(), signWebhookPayload(tt.cfg.HMACSecret, now, []byte(tt.body)))
+			}
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			ok := authorizeSignalRequest(rec, r, tt.cfg, []byte(tt.body))
+			if ok != tt.ok || rec.Code != tt.status {
+				t.Errorf("ok=%v status=%d，应为 ok=%v status=%d", ok, rec.Code, tt.ok, tt.status)
+			}
+		})
+	}
+
+	// 重放返回 409
+	cfg := HTTPIngestConfig{HMACSecret: "k"}
+	body := `{"n":"replay"}`
+	for i, want := range []int{http.StatusOK, http.StatusConflict} {
+		r := signedRequest(cfg, body, now, signWebhookPayload("k", now, []byte(body)))
+		rec := httptest.NewRecorder()
+		authorizeSignalRequest(rec, r, cfg, []byte(body))
+		if rec.Code != want {
+			t.Errorf("第 %d 次请求 status=%d，应为 %d", i+1, rec.Code, want)
+		}
+	}
+}
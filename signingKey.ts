@@ -0,0 +1,40 @@
+import fs from 'fs';
+import { exec } from 'child_process';
+import { promisify } from 'util';
+
+// 主程序（keys 配置的私钥来源）经继承的管道传入的私钥，约定与 main 程序的 keys.go 相同：
+// PRIVATE_KEY_FD 为管道在本进程中的文件描述符，内容为 Base58 secret key，读到 EOF 为止；私钥不出现在环境变量与命令行中。
+// 管道只能读取一次，读取后保存在内存中，本脚本调用的子命令经 execWithKey 再次传入
+
+const execAsync = promisify(exec);
+
+let pipedKey: string | undefined;
+
+// 管道传入的私钥；未设置 PRIVATE_KEY_FD 时返回 undefined，由调用方按 .env 中的 PRIVATE_KEY 读取
+export function pipedPrivateKey(): string | undefined {
+  if (pipedKey === undefined && process.env.PRIVATE_KEY_FD) {
+    pipedKey = fs.readFileSync(Number(process.env.PRIVATE_KEY_FD), 'utf8').trim();
+  }
+  return pipedKey;
+}
+
+// 执行子命令：私钥经标准输入传给 TS 脚本（PRIVATE_KEY_FD=0）；
+// binary 为 true 时（jupSwap 可执行文件只能从环境变量读取私钥）经 PRIVATE_KEY 传入
+export function execWithKey(command: string, cwd: string, binary = false): Promise<{ stdout: string; stderr: string }> {
+  const key = pipedPrivateKey();
+  const env: NodeJS.ProcessEnv = { ...process.env };
+  delete env.PRIVATE_KEY_FD;
+  if (key !== undefined) {
+    delete env.PRIVATE_KEY_ENCRYPTED;
+    delete env.PRIVATE_KEY_PASSWORD;
+    if (binary) {
+      env.PRIVATE_KEY = key;
+    } else {
+      delete env.PRIVATE_KEY;
+      env.PRIVATE_KEY_FD = '0';
+    }
+  }
+  const child = execAsync(command, { cwd, env });
+  child.child.stdin?.end(key !== undefined && !binary ? key : '');
+  return child;
+}
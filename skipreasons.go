@@ -0,0 +1,65 @@
+package main
+
+// 不执行动作的原因分类（meteora_skips_total 的 reason 标签与审计日志的 reason 字段）
+const (
+	skipBanned         = "banned"          // 名单策略跳过（黑名单、不在白名单）、创建者在黑名单中
+	skipBelowThreshold = "below_threshold" // 档位字段下限、准入规则的流动性 / bin step / 代币年龄、领取门槛、兑换最低余额与灰尘
+	skipFiltered       = "filtered"        // 演示池、风控保留的 USDC、未平仓池的代币等按规则过滤
+	skipCooldown       = "cooldown"        // 档位的领取间隔、不活跃池的领取退避、持仓代币的兑换间隔、代币入场冷却未到
+	skipQuota          = "quota"           // 速率保护、持仓数与单代币敞口上限
+	skipDuplicate      = "duplicate"       // 同一代币已在其他池入场
+	skipPaused         = "paused"          // 人工暂停（全局或单个定时任务）
+	skipOutsideWindow  = "outside_window"  // 不在交易时段（tradingWindows / blackoutWindows）
+	skipKillSwitch     = "kill_switch"     // 停止开关已开启
+	skipUnhealthy      = "unhealthy"       // 集群不健康、RPC 限流降级、数据目录不可用
+	skipMode           = "mode"            // 研究模式不开仓
+	skipInvalid        = "invalid"         // 信号或池文件无效、信号指定的协议不支持
+	skipStale          = "stale"           // 信号产生后超过新鲜度时限
+	skipInProgress     = "in_progress"     // 同一池的上一次领取仍在排队或执行中
+	skipUnsafe         = "unsafe"          // 代币安全检查未通过（风险评分、铸币 / 冻结权限、持有者集中度）
+	skipSlippage       = "slippage"        // 兑换报价的价格影响过大、输出低于按已存价格估算的下限或报价失败
+	skipUnchanged      = "unchanged"       // 钱包代币余额自上次兑换后没有变化
+)
+
+// 审计日志中跳过记录的类型
+const auditKindSkip = "skip"
+
+// SkipSummary 最近的跳过统计（GET /skips）
+type SkipSummary struct {
+	Days   int                       `json:"days"`
+	Total  int                       `json:"total"`
+	Counts map[string]map[string]int `json:"counts"` // 环节 -> 原因 -> 次数
+	Recent []AuditEntry              `json:"recent"`
+}
+
+// 准入规则对应的跳过原因
+var admissionSkipReasons = map[string]string{
+	ruleMinLiquidity:  skipBelowThreshold,
+	ruleBinStep:       skipBelowThreshold,
+	ruleTokenAge:      skipBelowThreshold,
+	ruleCreator:       skipBanned,
+	ruleMaxPositions:  skipQuota,
+	ruleTokenExposure: skipQuota,
+}
+
+// recordSkip 记录一次不执行动作的决定：stage 为 entry / claim / price / sweep，reason 为上面的分类
+func recordSkip(stage, reason, pool, token, detail string) {
+	metricSkips.Inc(stage, reason)
+	appendAudit(AuditEntry{Kind: auditKindSkip, Stage: stage, Reason: reason, Pool: pool, Token: token, Detail: detail})
+}
+
+// 最近 days 天的跳过统计，recent 为最近 limit 条记录（新的在前）
+func skipSummary(days, limit int) SkipSummary {
+	entries := readAudit(auditKindSkip, days)
+	s := SkipSummary{Days: days, Total: len(entries), Counts: map[string]map[string]int{}, Recent: []AuditEntry{}}
+	for _, e := range entries {
+		if s.Counts[e.Stage] == nil {
+			s.Counts[e.Stage] = map[string]int{}
+		}
+		s.Counts[e.Stage][e.Reason]++
+	}
+	for i := len(entries) - 1; i >= 0 && len(s.Recent) < limit; i-- {
+		s.Recent = append(s.Recent, entries[i])
+	}
+	return s
+}
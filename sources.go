@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 池记录与自动入场所在的 data 目录
+func poolDataDir() string { return appDataDir }
+
+// CSVSourceConfig 一个上游信号 CSV：各自的表头映射与输出目录，生成的池记录带 source 标记
+type CSVSourceConfig struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	HeaderMap map[string]string `json:"headerMap"` // 原始表头 -> 统一字段名（如 "pool": "poolAddress"、"mint": "ca"）
+	OutputDir string            `json:"outputDir"` // 池记录输出目录（默认 data 目录；其他目录只落盘、不自动入场）
+}
+
+func (s CSVSourceConfig) outputDir() string {
+	return outputDirOrData(s.OutputDir)
+}
+
+func validateCSVSources(sources []CSVSourceConfig) error {
+	names := map[string]bool{}
+	paths := map[string]bool{}
+	for _, s := range sources {
+		if s.Name == "" || strings.ContainsAny(s.Name, " /\\.") {
+			return fmt.Errorf("csvSources 名称非法: %q", s.Name)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("csvSources 名称重复: %s", s.Name)
+		}
+		names[s.Name] = true
+		if s.Path == "" || !filepath.IsAbs(s.Path) {
+			return fmt.Errorf("csvSources.%s.path 必须为绝对路径", s.Name)
+		}
+		if paths[s.Path] {
+			return fmt.Errorf("csvSources.%s.path 与其他源重复: %s", s.Name, s.Path)
+		}
+		paths[s.Path] = true
+		if s.OutputDir != "" && !filepath.IsAbs(s.OutputDir) {
+			return fmt.Errorf("csvSources.%s.outputDir 必须为绝对路径", s.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 持久化状态目录（位于 data 子目录，不会触发 data/*.json 的新池监听）
+func stateDir() string { return dataPath("state") }
+
+var stateMutex sync.Mutex
+
+// dry-run 与演示模式下使用独立的状态目录
+func currentStateDir() string {
+	if isDryRun() {
+		return dryRunStateDir()
+	}
+	if isDemo() {
+		return demoStateDir()
+	}
+	return stateDir()
+}
+
+// 读取状态文件 data/state/<name>.json；文件不存在时保持 v 不变
+func loadStateFile(name string, v interface{}) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	content, err := os.ReadFile(filepath.Join(currentStateDir(), name+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取状态文件失败: %v", err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析状态文件失败: %s, %v", name, err)
+	}
+	return nil
+}
+
+// 写入状态文件（原子写入）
+func saveStateFile(name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	dir := currentStateDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建状态目录失败: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, name+".json"), content, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SupervisorConfig 进程内守护：后台子系统 panic 或异常退出时按指数退避重启，定时任务单轮卡住时放弃该轮
+type SupervisorConfig struct {
+	PIDFile               string `json:"pidFile"`               // PID 文件（为空时不写）；文件中的进程仍在运行时拒绝启动
+	InitialBackoffSeconds int    `json:"initialBackoffSeconds"` // 第一次重启前的等待
+	MaxBackoffSeconds     int    `json:"maxBackoffSeconds"`     // 每次重启等待翻倍，不超过该值
+	StableSeconds         int    `json:"stableSeconds"`         // 连续运行超过该时长后退避从头计算
+	AbandonWedgedJobs     bool   `json:"abandonWedgedJobs"`     // 定时任务单轮超过 health.maxJobRunSeconds 时放弃该轮，下次按时重新执行
+	MaxWedgedRuns         int    `json:"maxWedgedRuns"`         // 累计放弃的轮次达到该数时优雅退出并返回非零退出码，由 systemd 等重启整个进程（0 表示不退出）
+}
+
+func (c SupervisorConfig) validate() error {
+	if c.InitialBackoffSeconds <= 0 || c.MaxBackoffSeconds < c.InitialBackoffSeconds {
+		return fmt.Errorf("supervisor.initialBackoffSeconds 必须大于0且不大于 maxBackoffSeconds")
+	}
+	if c.StableSeconds < 0 || c.MaxWedgedRuns < 0 {
+		return fmt.Errorf("supervisor.stableSeconds、maxWedgedRuns 不能为负数")
+	}
+	return nil
+}
+
+// 受守护子系统的状态
+const (
+	supervisedRunning = "running"
+	supervisedBackoff = "backoff" // 异常退出后等待重启
+	supervisedStopped = "stopped" // 正常返回（未启用）或已关闭
+)
+
+// 进程管理器据此重启进程的退出码（EX_TEMPFAIL）
+const exitCodeRestart = 75
+
+// SupervisedStatus 一个受守护的子系统（GET /supervisor）
+type SupervisedStatus struct {
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	Restarts    int    `json:"restarts"`
+	StartedAt   string `json:"startedAt,omitempty"` // 当前这次运行的开始时间
+	LastError   string `json:"lastError,omitempty"`
+	LastErrorAt string `json:"lastErrorAt,omitempty"`
+	NextRestart string `json:"nextRestart,omitempty"`
+}
+
+// panicError 子系统或定时任务 panic 的值与堆栈
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *panicError) Error() string { return fmt.Sprintf("panic: %v", e.value) }
+
+var (
+	supervisorMutex  sync.Mutex
+	supervisedStates = map[string]*SupervisedStatus{}
+
+	wedgedRuns       atomic.Int64 // 累计放弃的卡住轮次
+	restartRequested atomic.Bool
+)
+
+// superviseGo 在 shutdownWg 中后台运行子系统（取代 go startX()），panic 后自动重启
+func superviseGo(name string, fn func()) {
+	shutdownWg.Add(1)
+	go func() {
+		defer shutdownWg.Done()
+		supervise(name, func() error {
+			fn()
+			return nil
+		})
+	}()
+}
+
+// supervise 运行子系统直到正常返回或收到关闭信号：panic 或返回错误时告警并按指数退避重启
+func supervise(name string, run func() error) {
+	updateSupervised(name, func(s *SupervisedStatus) {})
+	failures := 0
+	for {
+		start := time.Now()
+		updateSupervised(name, func(s *SupervisedStatus) {
+			s.State, s.StartedAt, s.NextRestart = supervisedRunning, start.Format(time.RFC3339), ""
+		})
+		err := runRecovered(run)
+		if err == nil || globalCtx.Err() != nil {
+			updateSupervised(name, func(s *SupervisedStatus) { s.State = supervisedStopped })
+			return
+		}
+
+		cfg := currentConfig().Supervisor
+		if time.Since(start) >= time.Duration(cfg.StableSeconds)*time.Second {
+			failures = 0
+		}
+		failures++
+		delay := supervisorBackoff(cfg, failures)
+		reason := "error"
+		var pe *panicError
+		if errors.As(err, &pe) {
+			reason = "panic"
+			recordPanic(name, "", pe)
+			publishPanic(name, "", pe)
+		}
+		metricSubsystemRestarts.Inc(name, reason)
+		logError("❌ 子系统异常退出，稍后重启", "subsystem", name, "error", err, "attempt", failures, "backoff", delay)
+		notifyKeyed(eventSubsystemRestart, levelCritical, name, "子系统异常退出",
+			fmt.Sprintf("%s: %v，%v 后重启（第 %d 次）", name, err, delay, failures), map[string]string{"subsystem": name, "reason": reason})
+		now := time.Now()
+		updateSupervised(name, func(s *SupervisedStatus) {
+			s.State = supervisedBackoff
+			s.Restarts++
+			s.LastError, s.LastErrorAt = err.Error(), now.Format(time.RFC3339)
+			s.NextRestart = now.Add(delay).Format(time.RFC3339)
+		})
+		if !sleepCtx(globalCtx, delay) {
+			updateSupervised(name, func(s *SupervisedStatus) { s.State = supervisedStopped })
+			return
+		}
+		logOutput("🔁 重启子系统: %s\n", name)
+	}
+}
+
+// runRecovered 执行 run，panic 转为 *panicError
+func runRecovered(run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return run()
+}
+
+// supervisorBackoff 第 n 次重启前的等待：initial * 2^(n-1)，不超过 max
+func supervisorBackoff(cfg SupervisorConfig, n int) time.Duration {
+	delay := time.Duration(cfg.InitialBackoffSeconds) * time.Second
+	max := time.Duration(cfg.MaxBackoffSeconds) * time.Second
+	for i := 1; i < n && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func updateSupervised(name string, fn func(s *SupervisedStatus)) {
+	supervisorMutex.Lock()
+	defer supervisorMutex.Unlock()
+	s, ok := supervisedStates[name]
+	if !ok {
+		s = &SupervisedStatus{Name: name, State: supervisedRunning}
+		supervisedStates[name] = s
+	}
+	fn(s)
+}
+
+// listSupervised 所有受守护子系统的状态（按名称排序）
+func listSupervised() []SupervisedStatus {
+	supervisorMutex.Lock()
+	defer supervisorMutex.Unlock()
+	result := make([]SupervisedStatus, 0, len(supervisedStates))
+	for _, s := range supervisedStates {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+	return result
+}
+
+// checkSupervised 等待重启的子系统视为不健康（存活检查）
+func checkSupervised() map[string]HealthCheck {
+	checks := map[string]HealthCheck{}
+	for _, s := range listSupervised() {
+		if s.State == supervisedBackoff {
+			checks["subsystem:"+s.Name] = HealthCheck{Detail: fmt.Sprintf("异常退出（%s），%s 重启", s.LastError, s.NextRestart)}
+		}
+	}
+	return checks
+}
+
+// startJobWatchdog 定时任务单轮超过 health.maxJobRunSeconds 时放弃该轮：不再占用防重叠标记，下次按时重新执行。
+// 卡住的 goroutine 无法强制结束，放弃后其结果被忽略；累计放弃次数达到 maxWedgedRuns 时请求重启进程
+func startJobWatchdog() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			return
+		case <-ticker.C:
+			if !currentConfig().Supervisor.AbandonWedgedJobs {
+				continue
+			}
+			limit := time.Duration(currentConfig().Health.MaxJobRunSeconds) * time.Second
+			schedulerMutex.Lock()
+			jobs := make([]*scheduledJob, 0, len(schedulerJobs))
+			for _, j := range schedulerJobs {
+				jobs = append(jobs, j)
+			}
+			schedulerMutex.Unlock()
+			for _, j := range jobs {
+				if d, ok := j.abandonIfWedged(limit); ok {
+					n := wedgedRuns.Add(1)
+					metricSubsystemRestarts.Inc("job:"+j.name, "wedged")
+					logError("❌ 定时任务本轮卡住，已放弃，下次按时重新执行", "job", j.name, "running", d.Round(time.Second), "abandoned", n)
+					notifyKeyed(eventSubsystemRestart, levelCritical, "job:"+j.name, "定时任务卡住",
+						fmt.Sprintf("%s 本轮已执行 %v，已放弃（累计 %d 次）", j.name, d.Round(time.Second), n), map[string]string{"subsystem": "job:" + j.name, "reason": "wedged"})
+					if max := currentConfig().Supervisor.MaxWedgedRuns; max > 0 && n >= int64(max) {
+						requestRestart(fmt.Sprintf("累计 %d 轮定时任务卡住", n))
+					}
+				}
+			}
+		}
+	}
+}
+
+// requestRestart 优雅关闭后以 exitCodeRestart 退出，由 systemd（Restart=on-failure）等进程管理器重新拉起
+func requestRestart(reason string) {
+	if !restartRequested.CompareAndSwap(false, true) {
+		return
+	}
+	logError("❌ 请求重启进程", "reason", reason, "exitCode", exitCodeRestart)
+	globalCancel()
+}
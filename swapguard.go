@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SwapGuardConfig 持仓保护：定时兑换不卖出实盘未平仓池的代币（仓位刚领取的收益与再平衡所需的代币）
+type SwapGuardConfig struct {
+	Enabled            bool     `json:"enabled"`
+	Mode               string   `json:"mode"`               // skip：跳过；cap：每个代币每 capIntervalMinutes 最多兑换一次
+	CapIntervalMinutes int      `json:"capIntervalMinutes"` // cap 模式的兑换间隔
+	States             []string `json:"states"`             // 需要保护的仓位状态，默认 opened / active / out_of_range
+}
+
+// 持仓保护方式
+const (
+	swapGuardSkip = "skip"
+	swapGuardCap  = "cap"
+)
+
+// 持仓保护的兑换跳过原因（记入本轮兑换汇总）
+const (
+	swapSkipPositionGuard = "position_guard"
+	swapSkipPositionCap   = "position_cap"
+)
+
+var swapGuardMutex sync.Mutex
+
+func (c SwapGuardConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Mode != swapGuardSkip && c.Mode != swapGuardCap {
+		return fmt.Errorf("swapGuard.mode 仅支持 %s 或 %s", swapGuardSkip, swapGuardCap)
+	}
+	if c.Mode == swapGuardCap && c.CapIntervalMinutes <= 0 {
+		return fmt.Errorf("swapGuard.capIntervalMinutes 必须大于0")
+	}
+	for _, s := range c.States {
+		switch s {
+		case positionStateOpened, positionStateActive, positionStateOutOfRange:
+		default:
+			return fmt.Errorf("swapGuard.states 不支持 %q", s)
+		}
+	}
+	return nil
+}
+
+// positionTokens 钱包中未平仓的实盘池：代币 -> 池（states 为空时包含所有未平仓状态，模拟池不占用钱包余额，不计入）
+func positionTokens(wallet string, states []string) map[string]string {
+	tokens := map[string]string{}
+	for _, r := range listPositionRecords() {
+		if r.State == positionStateClosed || r.Mode == poolModePaper || r.TokenAddress == "" || poolWallet(r.PoolAddress) != wallet {
+			continue
+		}
+		if len(states) > 0 && !slices.Contains(states, r.State) {
+			continue
+		}
+		tokens[r.TokenAddress] = r.PoolAddress
+	}
+	return tokens
+}
+
+// swapGuardFilter 返回本轮兑换的持仓保护过滤函数：返回非空原因时该代币留在钱包中
+func swapGuardFilter(wallet string) func(tokenAddress string) string {
+	cfg := currentConfig().SwapGuard
+	if !cfg.Enabled {
+		return func(string) string { return "" }
+	}
+	guarded := positionTokens(wallet, cfg.States)
+	return func(tokenAddress string) string {
+		pool, ok := guarded[tokenAddress]
+		if !ok {
+			return ""
+		}
+		if cfg.Mode == swapGuardSkip {
+			recordSkip(subsystemSweep, skipFiltered, pool, tokenAddress, "代币属于实盘未平仓的池")
+			return swapSkipPositionGuard
+		}
+		if last, ok := allowGuardedSwap(tokenAddress, time.Duration(cfg.CapIntervalMinutes)*time.Minute); !ok {
+			recordSkip(subsystemSweep, skipCooldown, pool, tokenAddress, "持仓代币上次兑换于 "+last.Format(time.RFC3339))
+			return swapSkipPositionCap
+		}
+		return ""
+	}
+}
+
+// 持仓代币是否已到兑换间隔；允许时记录本次兑换时间（data/state/swap_guard.json），否则返回上次兑换时间
+func allowGuardedSwap(tokenAddress string, interval time.Duration) (time.Time, bool) {
+	swapGuardMutex.Lock()
+	defer swapGuardMutex.Unlock()
+	lastSwaps := map[string]string{}
+	if err := loadStateFile("swap_guard", &lastSwaps); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	now := appNow()
+	if last, err := time.Parse(time.RFC3339, lastSwaps[tokenAddress]); err == nil && now.Sub(last) < interval {
+		return last, false
+	}
+	lastSwaps[tokenAddress] = now.Format(time.RFC3339)
+	if err := saveStateFile("swap_guard", lastSwaps); err != nil {
+		logOutput("⚠️ 保存持仓保护记录失败: %v\n", err)
+	}
+	return time.Time{}, true
+}
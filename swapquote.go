@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SwapQuoteGuardConfig 兑换前报价检查：Jupiter 报价的价格影响过大，或报价输出低于按已存价格估算的价值时跳过兑换，避免把流动性差的代币以极差的价格卖出
+type SwapQuoteGuardConfig struct {
+	Enabled            bool    `json:"enabled"`
+	QuoteURL           string  `json:"quoteUrl"`           // Jupiter 报价接口
+	SlippageBps        int     `json:"slippageBps"`        // 报价使用的滑点（基点）
+	MaxPriceImpactPct  float64 `json:"maxPriceImpactPct"`  // 价格影响上限（%），0 表示不检查
+	MinOutputPct       float64 `json:"minOutputPct"`       // 报价输出价值不得低于 余额 × 已存价格 的百分比，0 表示不检查
+	PriceMaxAgeMinutes int     `json:"priceMaxAgeMinutes"` // 已存价格（价格历史）的有效期，没有更新的价格时不检查最低输出
+	OnQuoteError       string  `json:"onQuoteError"`       // 查询余额或报价失败时：skip 跳过本次兑换，swap 照常兑换
+}
+
+// 报价失败时的处理
+const (
+	quoteErrorSkip = "skip"
+	quoteErrorSwap = "swap"
+)
+
+// 报价检查的兑换跳过原因（记入本轮兑换汇总）
+const (
+	swapSkipPriceImpact = "price_impact"
+	swapSkipLowOutput   = "low_output"
+	swapSkipQuoteError  = "quote_error"
+)
+
+// swapQuote Jupiter 报价中用到的字段
+type swapQuote struct {
+	InAmount       string `json:"inAmount"`
+	OutAmount      string `json:"outAmount"`
+	PriceImpactPct string `json:"priceImpactPct"` // 比例（0.05 表示 5%）
+}
+
+func (c SwapQuoteGuardConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("swapQuoteGuard 需要 walletWatch.rpcUrl（查询兑换数量）")
+	}
+	if c.QuoteURL == "" {
+		return fmt.Errorf("swapQuoteGuard.quoteUrl 不能为空")
+	}
+	if c.SlippageBps <= 0 || c.SlippageBps > 10000 {
+		return fmt.Errorf("swapQuoteGuard.slippageBps 必须在 1~10000 之间")
+	}
+	if c.MaxPriceImpactPct < 0 || c.MaxPriceImpactPct > 100 || c.MinOutputPct < 0 || c.MinOutputPct > 100 {
+		return fmt.Errorf("swapQuoteGuard.maxPriceImpactPct、minOutputPct 必须在 0~100 之间")
+	}
+	if c.PriceMaxAgeMinutes <= 0 {
+		return fmt.Errorf("swapQuoteGuard.priceMaxAgeMinutes 必须大于0")
+	}
+	if c.OnQuoteError != quoteErrorSkip && c.OnQuoteError != quoteErrorSwap {
+		return fmt.Errorf("swapQuoteGuard.onQuoteError 仅支持 %s 或 %s", quoteErrorSkip, quoteErrorSwap)
+	}
+	return nil
+}
+
+// checkSwapQuote 兑换前按钱包中的全部余额查询报价，返回非空原因时跳过本次兑换（outputMint 为空表示 SOL）；
+// 通过检查时同时返回报价，供兑换后核对到账（swapVerify）使用
+func checkSwapQuote(wallet, ca, outputMint string) (reason, detail string, quote *swapQuote) {
+	cfg := currentConfig().SwapQuoteGuard
+	if !cfg.Enabled || isDemo() {
+		return "", "", nil
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	reason, detail, quote, err := evaluateSwapQuote(ctx, cfg, wallet, ca, outputMint)
+	if err != nil {
+		logWarn("⚠️ 兑换报价失败", "token", ca, "wallet", wallet, "onQuoteError", cfg.OnQuoteError, "error", err)
+		if cfg.OnQuoteError == quoteErrorSwap {
+			return "", "", nil
+		}
+		return swapSkipQuoteError, err.Error(), nil
+	}
+	return reason, detail, quote
+}
+
+// fetchSwapQuote 查询 Jupiter 报价（amount 为输入代币的最小单位数量）
+func fetchSwapQuote(ctx context.Context, inputMint, outputMint, amount string) (*swapQuote, error) {
+	cfg := currentConfig().SwapQuoteGuard
+	q := url.Values{}
+	q.Set("inputMint", inputMint)
+	q.Set("outputMint", outputMint)
+	q.Set("amount", amount)
+	q.Set("slippageBps", strconv.Itoa(cfg.SlippageBps))
+	var quote swapQuote
+	if err := apiGetJSON(ctx, priceSourceJupiter, cfg.QuoteURL+"?"+q.Encode(), nil, &quote); err != nil {
+		return nil, fmt.Errorf("查询报价失败: %v", err)
+	}
+	if _, err := strconv.ParseFloat(quote.OutAmount, 64); err != nil {
+		return nil, fmt.Errorf("报价缺少 outAmount: %q", quote.OutAmount)
+	}
+	return &quote, nil
+}
+
+func evaluateSwapQuote(ctx context.Context, cfg SwapQuoteGuardConfig, wallet, ca, outputMint string) (string, string, *swapQuote, error) {
+	outMint, outLabel := outputMint, outputMint
+	if outMint == "" {
+		outMint, outLabel = solMint, swapToSOL
+	}
+	address := walletAddressFor(wallet)
+	if address == "" {
+		return "", "", nil, fmt.Errorf("未知的钱包地址")
+	}
+	balances, err := fetchTokenBalances(ctx, address)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("查询余额失败: %v", err)
+	}
+	var balance *TokenBalance
+	for i := range balances {
+		if balances[i].Mint == ca {
+			balance = &balances[i]
+		}
+	}
+	// 余额为 0 时交给 jupSwap 处理
+	if balance == nil {
+		return "", "", nil, nil
+	}
+
+	quote, err := fetchSwapQuote(ctx, ca, outMint, balance.Amount)
+	if err != nil {
+		return "", "", nil, err
+	}
+	impact, _ := strconv.ParseFloat(quote.PriceImpactPct, 64)
+	impactPct := math.Abs(impact) * 100
+	if cfg.MaxPriceImpactPct > 0 && impactPct > cfg.MaxPriceImpactPct {
+		return swapSkipPriceImpact, fmt.Sprintf("价格影响 %.2f%% 超过 %.2f%%（数量 %g）", impactPct, cfg.MaxPriceImpactPct, balance.UIAmount), nil, nil
+	}
+	if cfg.MinOutputPct <= 0 {
+		return "", "", quote, nil
+	}
+
+	// 按已存价格估算的价值（价格过期或输出资产没有美元汇率时不检查）
+	points := storedPrices(ca, time.Now().Add(-time.Duration(cfg.PriceMaxAgeMinutes)*time.Minute))
+	if len(points) == 0 {
+		return "", "", quote, nil
+	}
+	expectedUSD := balance.UIAmount * points[len(points)-1].Price
+	outAmount, err := tokenUIAmount(ctx, outMint, quote.OutAmount)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("查询输出代币精度失败: %v", err)
+	}
+	outUSD := usdValue(outLabel, outAmount)
+	if expectedUSD <= 0 || outUSD <= 0 {
+		return "", "", quote, nil
+	}
+	if ratio := outUSD / expectedUSD * 100; ratio < cfg.MinOutputPct {
+		return swapSkipLowOutput, fmt.Sprintf("报价输出 $%.4f 仅为按已存价格估算的 $%.4f 的 %.1f%%（下限 %.0f%%）", outUSD, expectedUSD, ratio, cfg.MinOutputPct), nil, nil
+	}
+	return "", "", quote, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SwapSale 一次成功的兑换
+type SwapSale struct {
+	Token      string            `json:"token"`
+	Symbol     string            `json:"symbol,omitempty"` // 代币符号（代币信息缓存，没有元数据时为空）
+	Wallet     string            `json:"wallet,omitempty"`
+	OutputMint string            `json:"outputMint"`       // SOL 或输出代币 mint
+	Proceeds   float64           `json:"proceeds"`         // 得到的输出代币数量（未知时为 0）
+	FeeSOL     float64           `json:"feeSOL"`           // 交易手续费（jupSwap 未输出时为 0，已计入按余额变化得到的 proceeds）
+	Source     string            `json:"source,omitempty"` // proceeds 来源：event（jupSwap 输出）或 balance（SOL 余额变化，或 swapVerify 核对的到账数量）
+	Pools      []string          `json:"pools,omitempty"`  // 归属的池
+	ValueUSD   float64           `json:"valueUSD"`         // 兑换时按当时汇率计算的收入美元价值（汇率未知时为 0）
+	Verify     *SwapVerification `json:"verify,omitempty"` // 兑换后按余额变化的核对结果（swapVerify）
+}
+
+// SwapSkip 未兑换的代币及原因
+type SwapSkip struct {
+	Token  string `json:"token"`
+	Wallet string `json:"wallet,omitempty"`
+	Reason string `json:"reason"` // tokenBan / poolBan / allow（名单策略）、keep_usdc、position_guard / position_cap（持仓保护）、target / below_min / dust / open_position（归集策略）、price_impact / low_output / quote_error（报价检查）、pool_override（池配置覆盖）、kill_switch（停止开关）、rate_limited、failed
+	Detail string `json:"detail,omitempty"`
+}
+
+// 跳过原因
+const (
+	swapSkipKeepUSDC    = "keep_usdc"
+	swapSkipRateLimited = "rate_limited"
+	swapSkipFailed      = "failed"
+)
+
+// SwapRoundSummary 一轮定时兑换的汇总（data/state/swap_round.json 保存最近一轮）
+type SwapRoundSummary struct {
+	StartedAt       string             `json:"startedAt"`
+	FinishedAt      string             `json:"finishedAt,omitempty"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Sold            []SwapSale         `json:"sold"`
+	Skipped         []SwapSkip         `json:"skipped"`
+	Proceeds        map[string]float64 `json:"proceeds"` // 输出代币 -> 合计数量
+	FeeSOL          float64            `json:"feeSOL"`
+	Currency        string             `json:"currency"`
+	Value           float64            `json:"value"` // 收入按兑换时的汇率折算为计价货币
+	Instance        string             `json:"instance,omitempty"`
+	Environment     string             `json:"environment,omitempty"`
+}
+
+var (
+	swapRoundMutex sync.Mutex
+	swapRound      *SwapRoundSummary // 进行中的一轮（风控、阶梯清理等轮外兑换不计入）
+	swapRoundStart time.Time
+)
+
+func beginSwapRound() {
+	swapRoundMutex.Lock()
+	defer swapRoundMutex.Unlock()
+	swapRoundStart = time.Now()
+	swapRound = &SwapRoundSummary{StartedAt: swapRoundStart.Format(time.RFC3339), Sold: []SwapSale{}, Skipped: []SwapSkip{}, Proceeds: map[string]float64{}}
+}
+
+func noteSwapSale(sale SwapSale) {
+	swapRoundMutex.Lock()
+	defer swapRoundMutex.Unlock()
+	if swapRound == nil {
+		return
+	}
+	swapRound.Sold = append(swapRound.Sold, sale)
+	swapRound.Proceeds[sale.OutputMint] += sale.Proceeds
+	swapRound.FeeSOL += sale.FeeSOL
+}
+
+func noteSwapSkip(skip SwapSkip) {
+	swapRoundMutex.Lock()
+	defer swapRoundMutex.Unlock()
+	if swapRound != nil {
+		swapRound.Skipped = append(swapRound.Skipped, skip)
+	}
+}
+
+// 结束一轮兑换：输出单行汇总并保存
+func finishSwapRound() {
+	swapRoundMutex.Lock()
+	s := swapRound
+	swapRound = nil
+	start := swapRoundStart
+	swapRoundMutex.Unlock()
+	if s == nil {
+		return
+	}
+	now := time.Now()
+	s.FinishedAt = now.Format(time.RFC3339)
+	s.DurationSeconds = now.Sub(start).Seconds()
+	s.Instance, s.Environment = deployInstance, deployEnvironment
+	s.Currency = reportCurrency()
+	rates := loadFXRates()
+	for _, sale := range s.Sold {
+		v, _ := rates.convert(s.Currency, 0, sale.ValueUSD, now)
+		s.Value += v
+	}
+	logOutput("📋 本轮兑换汇总: %s\n", s.line())
+	if err := saveStateFile("swap_round", s); err != nil {
+		logOutput("❌ 保存兑换汇总失败: %v\n", err)
+	}
+}
+
+// 单行汇总，如 "卖出 2 个代币，收入 SOL=0.84（合计 0.8400 SOL），手续费 0.00001 SOL，跳过 3 个（tokenBan×2, failed×1），耗时 8.1s"
+func (s *SwapRoundSummary) line() string {
+	mints := make([]string, 0, len(s.Proceeds))
+	for mint := range s.Proceeds {
+		mints = append(mints, mint)
+	}
+	sort.Strings(mints)
+	proceeds := make([]string, 0, len(mints))
+	for _, mint := range mints {
+		proceeds = append(proceeds, fmt.Sprintf("%s=%g", tokenLabel(mint), s.Proceeds[mint]))
+	}
+	proceedsText := "无"
+	if len(proceeds) > 0 {
+		proceedsText = strings.Join(proceeds, " / ")
+		if s.Value > 0 {
+			proceedsText += "（合计 " + formatMoney(s.Value, s.Currency) + "）"
+		}
+	}
+
+	reasons := map[string]int{}
+	for _, skip := range s.Skipped {
+		reasons[skip.Reason]++
+	}
+	keys := make([]string, 0, len(reasons))
+	for r := range reasons {
+		keys = append(keys, r)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, r := range keys {
+		parts = append(parts, fmt.Sprintf("%s×%d", r, reasons[r]))
+	}
+	skipped := fmt.Sprintf("%d 个", len(s.Skipped))
+	if len(parts) > 0 {
+		skipped += "（" + strings.Join(parts, ", ") + "）"
+	}
+	return fmt.Sprintf("卖出 %d 个代币，收入 %s，手续费 %.6f SOL，跳过 %s，耗时 %.1fs",
+		len(s.Sold), proceedsText, s.FeeSOL, skipped, s.DurationSeconds)
+}
+
+// 最近一轮兑换汇总（尚无记录时返回 nil）
+func lastSwapRound() *SwapRoundSummary {
+	var s SwapRoundSummary
+	if err := loadStateFile("swap_round", &s); err != nil {
+		logOutput("⚠️ %v\n", err)
+		return nil
+	}
+	if s.StartedAt == "" {
+		return nil
+	}
+	return &s
+}
+
+// 钱包地址（wallet 为空时为默认钱包）
+func walletAddressFor(wallet string) string {
+	if w := findWallet(wallet); w != nil {
+		return w.Address
+	}
+	if addr := walletAddress(); addr != "" {
+		return addr
+	}
+	return lookupEnv("USER_WALLET_ADDRESS")
+}
+
+// 兑换前记录 SOL 余额，用于 jupSwap 未输出成交数量时按余额变化估算收入（返回 -1 表示未知）
+func swapBalanceBefore(wallet, outputMint string) float64 {
+	if outputMint != "" || isDryRun() || isDemo() || currentConfig().WalletWatch.RPCURL == "" {
+		return -1
+	}
+	address := walletAddressFor(wallet)
+	if address == "" {
+		return -1
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 10*time.Second)
+	defer cancel()
+	sol, err := getSOLBalance(ctx, address)
+	if err != nil {
+		logDebug("查询兑换前余额失败", "wallet", wallet, "error", err)
+		return -1
+	}
+	return sol
+}
+
+// swapProceeds 解析兑换收入：优先 jupSwap 的 value 事件（proceeds、feeSOL），其次为 SOL 余额变化（已扣除手续费）
+func swapProceeds(wallet string, output []byte, before float64) (proceeds, feeSOL float64, source string) {
+	o := decodeScriptOutput(output)
+	feeSOL, _ = o.Value("feeSOL", "")
+	if v, ok := o.Value("proceeds", ""); ok {
+		return v, feeSOL, "event"
+	}
+	if before < 0 {
+		return 0, feeSOL, ""
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 10*time.Second)
+	defer cancel()
+	after, err := getSOLBalance(ctx, walletAddressFor(wallet))
+	if err != nil || after <= before {
+		return 0, feeSOL, ""
+	}
+	return after - before, feeSOL, "balance"
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// SwapVerifyConfig 兑换后核对到账：jupSwap 报告成功后按 RPC 查询的余额变化计算实际卖出与到账数量，
+// 与兑换前的报价比较并记录实际成交价；没有卖出、只卖出一部分或成交价明显差于报价时告警
+type SwapVerifyConfig struct {
+	Enabled       bool    `json:"enabled"`
+	TolerancePct  float64 `json:"tolerancePct"`  // 成交价低于报价价格超过该百分比、或剩余输入代币超过该比例时告警
+	SettleSeconds int     `json:"settleSeconds"` // 兑换后等待余额变化的最长时间（RPC 节点可能滞后）
+}
+
+// 核对结果
+const (
+	swapVerifyOK        = "ok"
+	swapVerifyDeviation = "deviation" // 成交价低于报价超过容差
+	swapVerifyPartial   = "partial"   // 只卖出了一部分
+	swapVerifyNoFill    = "no_fill"   // 报告成功但输入代币余额没有变化
+)
+
+// 兑换后查询余额的间隔
+const swapVerifyPollInterval = 2 * time.Second
+
+// SwapVerification 一次兑换的核对结果（记入兑换记录的 verify 字段）
+type SwapVerification struct {
+	Sold         float64 `json:"sold"`                   // 实际卖出的代币数量（输入代币余额变化）
+	Remaining    float64 `json:"remaining,omitempty"`    // 兑换后剩余的输入代币数量
+	Received     float64 `json:"received"`               // 实际到账的输出数量（输出余额变化，SOL 加回 jupSwap 报告的手续费）
+	Quoted       float64 `json:"quoted,omitempty"`       // 报价输出（按兑换前的全部余额，报价失败时为 0）
+	ExecPrice    float64 `json:"execPrice"`              // 实际成交价：每个代币得到的输出数量
+	QuotePrice   float64 `json:"quotePrice,omitempty"`   // 报价价格
+	DeviationPct float64 `json:"deviationPct,omitempty"` // 成交价低于报价价格的百分比（负数表示优于报价）
+	Result       string  `json:"result"`
+}
+
+// swapVerifyState 兑换前的余额与报价
+type swapVerifyState struct {
+	wallet    string
+	address   string
+	token     string
+	outMint   string
+	outLabel  string
+	inRaw     string  // 兑换前输入代币余额（最小单位）
+	inAmount  float64 // 兑换前输入代币数量
+	outBefore float64
+	quoted    float64
+}
+
+func (c SwapVerifyConfig) validate(watch WalletWatchConfig, quote SwapQuoteGuardConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("swapVerify 需要 walletWatch.rpcUrl（查询兑换前后的余额）")
+	}
+	if quote.QuoteURL == "" || quote.SlippageBps <= 0 {
+		return fmt.Errorf("swapVerify 需要 swapQuoteGuard.quoteUrl 与 slippageBps（查询报价）")
+	}
+	if c.TolerancePct <= 0 || c.TolerancePct >= 100 {
+		return fmt.Errorf("swapVerify.tolerancePct 必须在 0~100 之间")
+	}
+	if c.SettleSeconds <= 0 {
+		return fmt.Errorf("swapVerify.settleSeconds 必须大于0")
+	}
+	return nil
+}
+
+// beginSwapVerify 兑换前记录输入、输出余额与报价（quote 为报价检查已查询的报价，没有时重新查询）；未启用或查询失败时返回 nil
+func beginSwapVerify(wallet, ca, outputMint string, quote *swapQuote) *swapVerifyState {
+	if !currentConfig().SwapVerify.Enabled || isDryRun() || isDemo() {
+		return nil
+	}
+	s := &swapVerifyState{wallet: wallet, address: walletAddressFor(wallet), token: ca, outMint: outputMint, outLabel: outputMint}
+	if s.outMint == "" {
+		s.outMint, s.outLabel = solMint, swapToSOL
+	}
+	if s.address == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	in, out, err := s.balances(ctx)
+	if err != nil {
+		logWarn("⚠️ 兑换前查询余额失败，不核对到账", "token", ca, "wallet", wallet, "error", err)
+		return nil
+	}
+	// 余额为 0 时交给 jupSwap 处理
+	if in == nil {
+		return nil
+	}
+	s.inRaw, s.inAmount, s.outBefore = in.Amount, in.UIAmount, out
+	if quote == nil || quote.InAmount != s.inRaw {
+		if quote, err = fetchSwapQuote(ctx, ca, s.outMint, s.inRaw); err != nil {
+			logWarn("⚠️ 兑换前查询报价失败，只核对卖出数量", "token", ca, "error", err)
+		}
+	}
+	if quote != nil {
+		if s.quoted, err = tokenUIAmount(ctx, s.outMint, quote.OutAmount); err != nil {
+			logWarn("⚠️ 查询输出代币精度失败，只核对卖出数量", "token", ca, "error", err)
+		}
+	}
+	return s
+}
+
+// balances 输入代币余额（没有时为 nil）与输出资产数量（SOL 或输出代币）
+func (s *swapVerifyState) balances(ctx context.Context) (*TokenBalance, float64, error) {
+	tokens, err := fetchTokenBalances(ctx, s.address)
+	if err != nil {
+		return nil, 0, err
+	}
+	var in *TokenBalance
+	var out float64
+	for i := range tokens {
+		switch tokens[i].Mint {
+		case s.token:
+			in = &tokens[i]
+		case s.outMint:
+			out = tokens[i].UIAmount
+		}
+	}
+	if s.outMint == solMint {
+		if out, err = getSOLBalance(ctx, s.address); err != nil {
+			return nil, 0, err
+		}
+	}
+	return in, out, nil
+}
+
+// finish 兑换成功后等待输入代币余额变化，计算实际成交并在偏离时告警；未启用或查询失败时返回 nil
+func (s *swapVerifyState) finish(feeSOL float64) *SwapVerification {
+	if s == nil {
+		return nil
+	}
+	settle := time.Duration(currentConfig().SwapVerify.SettleSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(globalCtx, settle+15*time.Second)
+	defer cancel()
+	deadline := time.Now().Add(settle)
+	var in *TokenBalance
+	var out float64
+	var err error
+	for {
+		in, out, err = s.balances(ctx)
+		if err == nil && (in == nil || in.Amount != s.inRaw) {
+			break
+		}
+		if time.Now().After(deadline) || !sleepCtx(ctx, swapVerifyPollInterval) {
+			break
+		}
+	}
+	if err != nil {
+		logWarn("⚠️ 兑换后查询余额失败，不核对到账", "token", s.token, "wallet", s.wallet, "error", err)
+		return nil
+	}
+
+	v := &SwapVerification{Sold: s.inAmount, Received: out - s.outBefore, Quoted: s.quoted}
+	if in != nil {
+		v.Remaining = in.UIAmount
+		v.Sold = s.inAmount - in.UIAmount
+	}
+	if s.outMint == solMint {
+		v.Received += feeSOL
+	}
+	tolerance := currentConfig().SwapVerify.TolerancePct
+	switch {
+	case v.Sold <= 0:
+		v.Result = swapVerifyNoFill
+	default:
+		v.ExecPrice = v.Received / v.Sold
+		if s.quoted > 0 && s.inAmount > 0 {
+			v.QuotePrice = s.quoted / s.inAmount
+			v.DeviationPct = (1 - v.ExecPrice/v.QuotePrice) * 100
+		}
+		switch {
+		case v.Remaining/s.inAmount*100 > tolerance:
+			v.Result = swapVerifyPartial
+		case v.QuotePrice > 0 && v.DeviationPct > tolerance:
+			v.Result = swapVerifyDeviation
+		default:
+			v.Result = swapVerifyOK
+		}
+	}
+	metricSwapVerifications.Inc(v.Result)
+	s.report(v)
+	return v
+}
+
+// report 记录核对结果，异常时告警
+func (s *swapVerifyState) report(v *SwapVerification) {
+	label := tokenLabel(s.token)
+	fields := map[string]string{
+		"ca":        s.token,
+		"output":    s.outLabel,
+		"sold":      formatFloat(v.Sold),
+		"remaining": formatFloat(v.Remaining),
+		"received":  formatFloat(v.Received),
+		"execPrice": formatFloat(v.ExecPrice),
+	}
+	if v.Quoted > 0 {
+		fields["quoted"] = formatFloat(v.Quoted)
+		fields["quotePrice"] = formatFloat(v.QuotePrice)
+		fields["deviation"] = strconv.FormatFloat(v.DeviationPct, 'f', 2, 64) + "%"
+	}
+	switch v.Result {
+	case swapVerifyOK:
+		logInfo("🧾 兑换到账核对通过", "token", s.token, "sold", v.Sold, "received", v.Received, "execPrice", v.ExecPrice, "deviationPct", math.Round(v.DeviationPct*100)/100)
+		return
+	case swapVerifyNoFill:
+		logError("❌ jupSwap报告成功但代币余额没有变化", "token", s.token, "wallet", s.wallet)
+		notifyKeyed(eventSwapDeviation, levelCritical, s.token, "兑换报告成功但未成交: "+label,
+			fmt.Sprintf("%s 兑换后余额仍为 %g，未收到 %s", label, s.inAmount, s.outLabel), fields)
+	case swapVerifyPartial:
+		logWarn("⚠️ 兑换只成交了一部分", "token", s.token, "wallet", s.wallet, "sold", v.Sold, "remaining", v.Remaining)
+		notifyKeyed(eventSwapDeviation, levelWarning, s.token, "兑换部分成交: "+label,
+			fmt.Sprintf("卖出 %g，剩余 %g（兑换前 %g）", v.Sold, v.Remaining, s.inAmount), fields)
+	case swapVerifyDeviation:
+		logWarn("⚠️ 兑换成交价明显差于报价", "token", s.token, "wallet", s.wallet, "execPrice", v.ExecPrice, "quotePrice", v.QuotePrice, "deviationPct", v.DeviationPct)
+		notifyKeyed(eventSwapDeviation, levelWarning, s.token, "兑换成交价偏离报价: "+label,
+			fmt.Sprintf("到账 %g %s，报价 %g，成交价低于报价 %.2f%%", v.Received, s.outLabel, v.Quoted, v.DeviationPct), fields)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// csvRow 一条完整的 CSV 记录及其行号（表头为第 1 行）
+type csvRow struct {
+	line   int
+	record []string
+}
+
+// csvTailState 持久化的读取进度（data/state/csv_tail_<source>.json）
+type csvTailState struct {
+	Path      string `json:"path"`
+	Inode     uint64 `json:"inode"`
+	Offset    int64  `json:"offset"` // 已处理到的字节位置（总是位于行首）
+	Line      int    `json:"line"`   // 已处理到的行号
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// csvTailer 按字节偏移追踪 CSV 新增内容：识别截断与轮转（inode 变化），
+// 只消费以换行结尾的完整行（半截行留到下次），并在重启后从持久化的偏移继续
+type csvTailer struct {
+	path    string
+	source  CSVSourceConfig
+	headers []string // 原始表头
+	fields  []string // 按 headerMap 与 csvSchema.aliases 映射后的字段名
+	state   csvTailState
+	catchUp bool // 下一次读取的内容为停机期间的积压，需按补处理时限过滤
+}
+
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
+
+// 新建读取器并读取表头；有与当前文件一致的持久化进度时从该位置继续，否则从文件末尾开始
+func newCSVTailer(source CSVSourceConfig) (*csvTailer, error) {
+	path := source.Path
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &csvTailer{path: path, source: source}
+	var saved csvTailState
+	// 兼容单一 CSV 源时的进度文件 csv_tail.json（按路径校验，不会误用）
+	for _, name := range []string{t.stateName(), "csv_tail"} {
+		if err := loadStateFile(name, &saved); err != nil {
+			logOutput("⚠️ %v\n", err)
+		}
+		if saved.Path == path {
+			break
+		}
+	}
+
+	headerEnd, err := t.readHeaders()
+	if err != nil {
+		return nil, err
+	}
+	if saved.Path == path && saved.Inode == fileInode(fi) && saved.Offset >= headerEnd && saved.Offset <= fi.Size() {
+		t.state = saved
+		logOutput("📍 从上次进度继续读取CSV: offset=%d line=%d（文件大小 %d）\n", saved.Offset, saved.Line, fi.Size())
+		if saved.Offset < fi.Size() {
+			t.skipOrCatchUp()
+		}
+		return t, nil
+	}
+
+	// 首次运行或文件已更换：跳过已有内容，从最后一个完整行之后开始
+	offset, lines, err := lastLineBoundary(path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.state = csvTailState{Path: path, Inode: fileInode(fi), Offset: offset, Line: lines}
+	t.save()
+	return t, nil
+}
+
+// 停机期间有新增行：启用补处理时留给首次读取（按时限过滤），否则跳到文件末尾
+func (t *csvTailer) skipOrCatchUp() {
+	if currentConfig().CatchUp.Enabled {
+		t.catchUp = true
+		return
+	}
+	offset, lines, err := lastLineBoundary(t.path, t.state.Offset, t.state.Line)
+	if err != nil {
+		logWarn("⚠️ 跳过CSV积压失败，将补处理", "source", t.source.Name, "error", err)
+		return
+	}
+	logWarn("⏭️ 未启用补处理，丢弃停机期间的CSV新增行", "source", t.source.Name, "rows", lines-t.state.Line)
+	t.state.Offset, t.state.Line = offset, lines
+	t.save()
+}
+
+// 读取表头，返回表头结束的字节位置
+func (t *csvTailer) readHeaders() (int64, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	idx := bytes.IndexByte(content, '\n')
+	if idx < 0 {
+		return 0, fmt.Errorf("CSV表头不完整: %s", t.path)
+	}
+	headers, err := csv.NewReader(bytes.NewReader(content[:idx+1])).Read()
+	if err != nil {
+		return 0, err
+	}
+	fields, err := mapCSVHeaders(t.source, headers)
+	if err != nil {
+		return 0, err
+	}
+	t.headers = headers
+	t.fields = fields
+	return int64(idx + 1), nil
+}
+
+func (t *csvTailer) stateName() string { return "csv_tail_" + t.source.Name }
+
+// 每次读取的最大字节数：大文件分块扫描与读取，不整个载入内存
+const csvReadChunk = 4 << 20
+
+// 从 offset（位于行首，之前已有 lines 行）向后分块扫描，返回最后一个换行之后的位置与完整行数
+func lastLineBoundary(path string, offset int64, lines int) (int64, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	buf := make([]byte, 64*1024)
+	pos, end := offset, offset
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			lines += bytes.Count(chunk, []byte{'\n'})
+			if idx := bytes.LastIndexByte(chunk, '\n'); idx >= 0 {
+				end = pos + int64(idx+1)
+			}
+			pos += int64(n)
+		}
+		if err == io.EOF {
+			return end, lines, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// 从 offset 读取新增内容中的完整行（以换行结尾），最多约 csvReadChunk 字节；单行超过该长度时继续读到行尾。
+// more 表示之后还有未读取的完整内容
+func readCompleteLines(file *os.File, offset, size int64) (complete []byte, more bool, err error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	var content []byte
+	buf := make([]byte, csvReadChunk)
+	for offset+int64(len(content)) < size {
+		n, err := io.ReadFull(file, buf[:min(int64(len(buf)), size-offset-int64(len(content)))])
+		content = append(content, buf[:n]...)
+		if end := bytes.LastIndexByte(content, '\n'); end >= 0 {
+			return content[:end+1], offset+int64(end+1) < size, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break // 文件在 Stat 之后被截断，下次再读
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil // 只有半截行，等待写完
+}
+
+func (t *csvTailer) save() {
+	t.state.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := saveStateFile(t.stateName(), t.state); err != nil {
+		logOutput("❌ 保存CSV读取进度失败: %s, %v\n", t.source.Name, err)
+	}
+}
+
+// poll 读取自上次以来新增的完整记录：从已处理的偏移处定位读取，每次最多约 csvReadChunk 字节，
+// more 表示还有未读取的新增行（调用方应继续读取）
+func (t *csvTailer) poll() (rows []csvRow, more bool, err error) {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if inode := fileInode(fi); inode != t.state.Inode {
+		logWarn("🔁 CSV文件已轮转，从新文件开头读取", "source", t.source.Name, "file", t.path, "oldInode", t.state.Inode, "inode", inode)
+		t.state = csvTailState{Path: t.path, Inode: inode}
+	} else if fi.Size() < t.state.Offset {
+		logWarn("✂️ CSV文件被截断或重写，从开头重新读取", "source", t.source.Name, "file", t.path, "offset", t.state.Offset, "size", fi.Size())
+		t.state.Offset, t.state.Line = 0, 0
+	}
+	if t.state.Offset == 0 {
+		headerEnd, err := t.readHeaders()
+		if err != nil {
+			return nil, false, err // 表头尚未写完，下次再读
+		}
+		t.state.Offset, t.state.Line = headerEnd, 1
+		logOutput("📋 已重新读取CSV表头: %s，字段数: %d\n", t.source.Name, len(t.headers))
+	}
+	if fi.Size() == t.state.Offset {
+		return nil, false, nil
+	}
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	complete, more, err := readCompleteLines(file, t.state.Offset, fi.Size())
+	if err != nil || len(complete) == 0 {
+		return nil, false, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(complete))
+	reader.FieldsPerRecord = -1 // 允许字段数量不一致
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			metricCSVRows.Inc("invalid")
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow{line: t.state.Line + line, record: record})
+	}
+	t.state.Offset += int64(len(complete))
+	t.state.Line += bytes.Count(complete, []byte{'\n'})
+	t.save()
+	return rows, more, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCompleteLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		offset   int64
+		size     int64 // Stat 得到的大小；-1 表示文件实际大小
+		complete string
+		more     bool
+	}{
+		{"完整的行", "a,b\nc,d\n", 0, -1, "a,b\nc,d\n", false},
+		{"末尾半截行", "a,b\nc,", 0, -1, "a,b\n", true},
+		{"只有半截行", "a,b", 0, -1, "", false},
+		{"从偏移处读取", "head\nx\ny\n", 5, -1, "x\ny\n", false},
+		{"偏移之后没有新内容", "head\n", 5, -1, "", false},
+		{"只读到 Stat 的大小", "a\nb\nc\n", 0, 4, "a\nb\n", false},
+		{"Stat 的大小之内只有半截行", "a\nbbbb\n", 0, 4, "a\n", true},
+		{"Stat 之后文件被截断", "a\nb", 0, 100, "a\n", true}, // 下次读取时发现截断
+		{"Windows 换行", "a\r\nb\r\n", 0, -1, "a\r\nb\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "signals.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			size := tt.size
+			if size < 0 {
+				size = int64(len(tt.content))
+			}
+			complete, more, err := readCompleteLines(file, tt.offset, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(complete) != tt.complete || more != tt.more {
+				t.Errorf("complete=%q more=%v，应为 %q more=%v", complete, more, tt.complete, tt.more)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata" // 内置时区数据，精简系统（无 /usr/share/zoneinfo）也能加载 IANA 时区
+)
+
+// TradingWindow 允许（tradingWindows）或禁止（blackoutWindows）自动开仓的时间段，end 早于 start 表示跨零点
+type TradingWindow struct {
+	Days     []int  `json:"days"`               // 星期几（0=周日 … 6=周六），为空表示每天
+	Start    string `json:"start"`              // HH:MM
+	End      string `json:"end"`                // HH:MM（不含）
+	Timezone string `json:"timezone,omitempty"` // 该时段使用的 IANA 时区，为空时按配置时区
+}
+
+// 配置时区（定时任务、日报日期边界、日志按天切分与交易时段都按该时区计算）
+var appLocation = time.Local
+
+// appNow 配置时区下的当前时间
+func appNow() time.Time {
+	return time.Now().In(appLocation)
+}
+
+// localDay RFC3339 时间戳在配置时区下的日期（解析失败时取字符串前缀）
+func localDay(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		if len(ts) >= 10 {
+			return ts[:10]
+		}
+		return ts
+	}
+	return t.In(appLocation).Format("2006-01-02")
+}
+
+// 解析时区名称：空或 Local 为本机时区，其余为 IANA 名称（如 Asia/Shanghai、UTC）
+func parseTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("timezone 无效: %s, %v", name, err)
+	}
+	return loc, nil
+}
+
+// 解析 HH:MM 为当天分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w TradingWindow) validate() error {
+	for _, d := range w.Days {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("tradingWindows.days 取值范围为 0-6: %d", d)
+		}
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return fmt.Errorf("tradingWindows.start: %v", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return fmt.Errorf("tradingWindows.end: %v", err)
+	}
+	if start == end {
+		return fmt.Errorf("tradingWindows 的 start 与 end 不能相同: %s", w.Start)
+	}
+	if _, err := parseTimezone(w.Timezone); err != nil {
+		return fmt.Errorf("tradingWindows.%v", err)
+	}
+	return nil
+}
+
+var windowLocations sync.Map // 时区名 -> *time.Location
+
+// location 时段的时区（未设置时为配置时区）
+func (w TradingWindow) location() *time.Location {
+	if w.Timezone == "" {
+		return appLocation
+	}
+	if loc, ok := windowLocations.Load(w.Timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := parseTimezone(w.Timezone)
+	if err != nil {
+		return appLocation
+	}
+	windowLocations.Store(w.Timezone, loc)
+	return loc
+}
+
+func (w TradingWindow) dayMatches(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, v := range w.Days {
+		if time.Weekday(v) == d {
+			return true
+		}
+	}
+	return false
+}
+
+// 跨零点的时段，零点之后的部分按开始那天的星期判断
+func (w TradingWindow) contains(t time.Time) bool {
+	t = t.In(w.location())
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return minute >= start && minute < end && w.dayMatches(t.Weekday())
+	}
+	if minute >= start {
+		return w.dayMatches(t.Weekday())
+	}
+	return minute < end && w.dayMatches(t.AddDate(0, 0, -1).Weekday())
+}
+
+// inTradingWindow 当前是否允许开仓：处于某个 tradingWindows 时段（未配置时始终允许），且不在任何 blackoutWindows 时段内
+func inTradingWindow() bool {
+	now := appNow()
+	for _, w := range currentConfig().BlackoutWindows {
+		if w.contains(now) {
+			return false
+		}
+	}
+	if len(currentConfig().TradingWindows) == 0 {
+		return true
+	}
+	for _, w := range currentConfig().TradingWindows {
+		if w.contains(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 原生 SOL 的包装代币：脚本按需创建并在结束时关闭，不预创建
+const wrappedSOLMint = "So11111111111111111111111111111111111111112"
+
+// TokenAccountsConfig 开仓前为池的交易对代币批量预创建关联代币账户，之后的领取、兑换不必各自创建或因账户缺失失败
+type TokenAccountsConfig struct {
+	Enabled        bool     `json:"enabled"`
+	ExtraMints     []string `json:"extraMints"`     // 每个钱包额外预创建的代币（如 USDC）
+	SkipMints      []string `json:"skipMints"`      // 不预创建的代币（默认 wSOL）
+	BatchSize      int      `json:"batchSize"`      // 每笔交易最多创建的账户数
+	TimeoutSeconds int      `json:"timeoutSeconds"` // createTokenAccounts.ts 超时
+}
+
+// 已就绪的关联代币账户（data/state/token_accounts.json: 钱包 -> 代币 -> 账户地址），已记录的代币不再检查
+var tokenAccountMutex sync.Mutex
+
+func (c TokenAccountsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.BatchSize <= 0 || c.BatchSize > 10 {
+		return fmt.Errorf("tokenAccounts.batchSize 取值范围为 1~10")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("tokenAccounts.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+func loadTokenAccounts() map[string]map[string]string {
+	accounts := map[string]map[string]string{}
+	if err := loadStateFile("token_accounts", &accounts); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return accounts
+}
+
+// 池需要预创建账户的代币：代币 ca、池的两个代币（演示模式不查询）与 extraMints，去掉 skipMints
+func tokenAccountMints(poolAddress, ca string) []string {
+	cfg := currentConfig().TokenAccounts
+	candidates := []string{ca}
+	if !isDemo() && currentConfig().Admission.PairAPIURL != "" {
+		if pair, err := fetchPair(poolAddress); err != nil {
+			logWarn("⚠️ 查询池代币失败，只预创建 ca 的账户", "pool", poolAddress, "error", err)
+		} else {
+			candidates = append(candidates, pair.MintX, pair.MintY)
+		}
+	}
+	candidates = append(candidates, cfg.ExtraMints...)
+	skip := map[string]bool{}
+	for _, m := range cfg.SkipMints {
+		skip[m] = true
+	}
+	var mints []string
+	for _, m := range candidates {
+		if m != "" && !skip[m] {
+			skip[m] = true
+			mints = append(mints, m)
+		}
+	}
+	return mints
+}
+
+// ensureTokenAccounts 开仓前为池的代币预创建钱包的关联代币账户（一笔交易最多 batchSize 个）；失败只告警，不影响开仓
+func ensureTokenAccounts(ctx context.Context, poolAddress, ca string) {
+	cfg := currentConfig().TokenAccounts
+	if !cfg.Enabled {
+		return
+	}
+	wallet, _ := ctx.Value(walletCtxKey{}).(string)
+	tokenAccountMutex.Lock()
+	known := loadTokenAccounts()[wallet]
+	tokenAccountMutex.Unlock()
+	var missing []string
+	for _, m := range tokenAccountMints(poolAddress, ca) {
+		if _, ok := known[m]; !ok {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	args := []string{"--pool=" + poolAddress,
+		"--mints=" + strings.Join(missing, ","), "--batch=" + strconv.Itoa(cfg.BatchSize)}
+	args = append(args, priorityFeeArgs(feeOpClaim)...)
+	out, err := runExternal(ctx, scriptCreateTokenAccounts, args...)
+	ready := decodeScriptOutput(out).Accounts()
+	metricTokenAccounts.Add(float64(len(ready)), resultLabel(nil))
+	if err != nil {
+		metricTokenAccounts.Add(float64(len(missing)-len(ready)), resultLabel(err))
+		logCommandOutput(out)
+		logWarn("⚠️ 预创建关联代币账户失败，由开仓、领取脚本自行创建", "pool", poolAddress, "mints", strings.Join(missing, ","), "error", err)
+	} else {
+		logInfo("✅ 关联代币账户已就绪", "pool", poolAddress, "wallet", wallet, "count", len(ready))
+	}
+	if len(ready) == 0 {
+		return
+	}
+
+	tokenAccountMutex.Lock()
+	defer tokenAccountMutex.Unlock()
+	accounts := loadTokenAccounts()
+	if accounts[wallet] == nil {
+		accounts[wallet] = map[string]string{}
+	}
+	for mint, account := range ready {
+		accounts[wallet][mint] = account
+	}
+	if err := saveStateFile("token_accounts", accounts); err != nil {
+		logOutput("❌ 保存关联代币账户记录失败: %v\n", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenCooldownConfig 同一代币入场冷却：开仓成功后 minutes 分钟内忽略该代币的后续信号（同一代币常在几分钟内出现在多个池，避免开出高度相关的仓位）
+type TokenCooldownConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Minutes       int    `json:"minutes"`
+	OverrideField string `json:"overrideField"` // 信号中该字段为 true / 1 / yes 时不受冷却限制，为空表示不允许跳过
+}
+
+// TokenCooldown 冷却中的代币（GET /cooldowns）
+type TokenCooldown struct {
+	Token     string `json:"token"`
+	Pool      string `json:"pool"`
+	EnteredAt string `json:"enteredAt"`
+	Until     string `json:"until"`
+}
+
+// 最近一次入场记录（data/state/token_cooldown.json，代币 -> 记录）
+type tokenEntry struct {
+	Pool      string `json:"pool"`
+	EnteredAt string `json:"enteredAt"`
+}
+
+var tokenCooldownMutex sync.Mutex
+
+func (c TokenCooldownConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Minutes <= 0 {
+		return fmt.Errorf("tokenCooldown.minutes 必须大于0")
+	}
+	if strings.ContainsAny(c.OverrideField, " \t") {
+		return fmt.Errorf("tokenCooldown.overrideField 不能包含空白")
+	}
+	return nil
+}
+
+func loadTokenEntries() map[string]tokenEntry {
+	entries := map[string]tokenEntry{}
+	if err := loadStateFile("token_cooldown", &entries); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return entries
+}
+
+// noteTokenEntry 开仓成功后记录代币的入场时间（追加流动性不重新计时）；顺带清理已过冷却期的记录
+func noteTokenEntry(tokenAddress, poolAddress string) {
+	cfg := currentConfig().TokenCooldown
+	if !cfg.Enabled || tokenAddress == "" {
+		return
+	}
+	tokenCooldownMutex.Lock()
+	defer tokenCooldownMutex.Unlock()
+	entries := loadTokenEntries()
+	now := appNow()
+	window := time.Duration(cfg.Minutes) * time.Minute
+	for token, e := range entries {
+		if at, err := time.Parse(time.RFC3339, e.EnteredAt); err != nil || now.Sub(at) >= window {
+			delete(entries, token)
+		}
+	}
+	entries[tokenAddress] = tokenEntry{Pool: poolAddress, EnteredAt: now.Format(time.RFC3339)}
+	if err := saveStateFile("token_cooldown", entries); err != nil {
+		logOutput("⚠️ 保存代币入场记录失败: %v\n", err)
+	}
+}
+
+// checkTokenCooldown 信号的代币是否仍在入场冷却期内，返回跳过说明；信号带 overrideField 或对已有仓位追加流动性时放行
+func checkTokenCooldown(profitData *ProfitData) (string, bool) {
+	cfg := currentConfig().TokenCooldown
+	ca, _ := profitData.Data["ca"].(string)
+	if !cfg.Enabled || ca == "" {
+		return "", true
+	}
+	tokenCooldownMutex.Lock()
+	e, ok := loadTokenEntries()[ca]
+	tokenCooldownMutex.Unlock()
+	if !ok {
+		return "", true
+	}
+	at, err := time.Parse(time.RFC3339, e.EnteredAt)
+	until := at.Add(time.Duration(cfg.Minutes) * time.Minute)
+	if err != nil || !appNow().Before(until) {
+		return "", true
+	}
+	if signalFlag(profitData.Data, cfg.OverrideField) {
+		logInfo("⏩ 信号要求跳过代币入场冷却", "token", ca, "pool", profitData.PoolAddress, "lastPool", e.Pool)
+		return "", true
+	}
+	if e.Pool == profitData.PoolAddress && topUpRequested(profitData.Data) {
+		return "", true
+	}
+	return fmt.Sprintf("代币于 %s 在池 %s 入场，冷却至 %s", at.Format(time.RFC3339), e.Pool, until.Format(time.RFC3339)), false
+}
+
+// listTokenCooldowns 冷却中的代币（按结束时间排序）
+func listTokenCooldowns() []TokenCooldown {
+	cfg := currentConfig().TokenCooldown
+	result := []TokenCooldown{}
+	if !cfg.Enabled {
+		return result
+	}
+	tokenCooldownMutex.Lock()
+	entries := loadTokenEntries()
+	tokenCooldownMutex.Unlock()
+	now := appNow()
+	for token, e := range entries {
+		at, err := time.Parse(time.RFC3339, e.EnteredAt)
+		until := at.Add(time.Duration(cfg.Minutes) * time.Minute)
+		if err != nil || !now.Before(until) {
+			continue
+		}
+		result = append(result, TokenCooldown{Token: token, Pool: e.Pool, EnteredAt: e.EnteredAt, Until: until.Format(time.RFC3339)})
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Until < result[b].Until })
+	return result
+}
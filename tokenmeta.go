@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenMetadata 代币的链上信息：mint 账户的精度与所属程序，Metaplex 元数据（或 Token-2022 元数据扩展）的符号与名称。
+// 首次用到时读取链上并持久化（data/state/token_metadata.json），之后的兑换、盈亏与日志直接查表
+type TokenMetadata struct {
+	Mint      string `json:"mint"`
+	Decimals  int    `json:"decimals"`
+	Program   string `json:"program,omitempty"` // SPL Token 或 Token-2022 程序
+	Symbol    string `json:"symbol,omitempty"`  // 没有元数据时为空
+	Name      string `json:"name,omitempty"`
+	FetchedAt string `json:"fetchedAt"`
+}
+
+// Token-2022 mint 扩展：账户类型位于 165 字节处，之后为 TLV（u16 类型 + u16 长度）
+const (
+	token2022AccountTypeOffset = 165
+	token2022MetadataExtension = 19 // TokenMetadata：updateAuthority(32) + mint(32) + name + symbol + uri
+)
+
+// 常见代币的精度（不读取链上）
+var knownMintDecimals = map[string]int{
+	solMint:  9,
+	usdcMint: 6,
+	usdtMint: 6,
+}
+
+var (
+	tokenMetaMutex sync.Mutex
+	tokenMetaCache map[string]*TokenMetadata // nil 表示尚未从状态文件加载
+)
+
+// 调用方需持有 tokenMetaMutex
+func tokenMetaLocked() map[string]*TokenMetadata {
+	if tokenMetaCache == nil {
+		tokenMetaCache = map[string]*TokenMetadata{}
+		if err := loadStateFile("token_metadata", &tokenMetaCache); err != nil {
+			logOutput("⚠️ %v\n", err)
+		}
+		for mint, d := range knownMintDecimals {
+			if tokenMetaCache[mint] == nil {
+				tokenMetaCache[mint] = &TokenMetadata{Mint: mint, Decimals: d, Symbol: knownMintSymbols[mint], Name: knownMintSymbols[mint]}
+			}
+		}
+	}
+	return tokenMetaCache
+}
+
+// cachedTokenMetadata 已缓存的代币信息（不读取链上），没有时为 nil
+func cachedTokenMetadata(mint string) *TokenMetadata {
+	tokenMetaMutex.Lock()
+	defer tokenMetaMutex.Unlock()
+	return tokenMetaLocked()[mint]
+}
+
+// tokenMetadata 代币信息：优先缓存，没有时读取链上并持久化
+func tokenMetadata(ctx context.Context, mint string) (*TokenMetadata, error) {
+	if meta := cachedTokenMetadata(mint); meta != nil {
+		return meta, nil
+	}
+	meta, err := fetchTokenMetadata(ctx, mint)
+	if err != nil {
+		return nil, err
+	}
+	tokenMetaMutex.Lock()
+	cache := tokenMetaLocked()
+	cache[mint] = meta
+	if err := saveStateFile("token_metadata", cache); err != nil {
+		logOutput("❌ 保存代币信息失败: %v\n", err)
+	}
+	tokenMetaMutex.Unlock()
+	logInfo("🏷️ 代币信息", "mint", mint, "symbol", meta.Symbol, "decimals", meta.Decimals)
+	return meta, nil
+}
+
+// fetchTokenMetadata 读取 mint 账户的精度，再读取 Token-2022 元数据扩展或 Metaplex 元数据账户
+func fetchTokenMetadata(ctx context.Context, mint string) (*TokenMetadata, error) {
+	data, owner, err := accountInfo(ctx, mint)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("账户不存在: %s", mint)
+	}
+	if len(data) <= mintDecimalsOffset {
+		return nil, fmt.Errorf("mint 账户数据长度不足: %s", mint)
+	}
+	meta := &TokenMetadata{Mint: mint, Decimals: int(data[mintDecimalsOffset]), Program: owner, FetchedAt: appNow().Format(time.RFC3339)}
+	if name, symbol, ok := token2022Metadata(data); ok {
+		meta.Name, meta.Symbol = name, symbol
+		return meta, nil
+	}
+	// 元数据读取失败（RPC 出错）时不缓存，下次重新读取
+	if meta.Symbol, meta.Name, err = metaplexMetadata(ctx, mint); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// token2022Metadata 从 Token-2022 mint 账户的 TokenMetadata 扩展读取名称与符号
+func token2022Metadata(data []byte) (string, string, bool) {
+	if len(data) <= token2022AccountTypeOffset {
+		return "", "", false
+	}
+	tlv := data[token2022AccountTypeOffset+1:]
+	for len(tlv) >= 4 {
+		typ := binary.LittleEndian.Uint16(tlv)
+		n := int(binary.LittleEndian.Uint16(tlv[2:]))
+		if len(tlv) < 4+n {
+			return "", "", false
+		}
+		if typ == token2022MetadataExtension && n > 64 {
+			name, rest, ok := borshString(tlv[4+64 : 4+n])
+			if !ok {
+				return "", "", false
+			}
+			symbol, _, ok := borshString(rest)
+			return name, symbol, ok
+		}
+		tlv = tlv[4+n:]
+	}
+	return "", "", false
+}
+
+// metaplexMetadata 读取 Metaplex 元数据账户的符号与名称；没有元数据账户时返回空
+func metaplexMetadata(ctx context.Context, mint string) (string, string, error) {
+	program, err := decodeBase58(tokenMetadataProgram)
+	if err != nil {
+		return "", "", err
+	}
+	mintKey, err := decodeBase58(mint)
+	if err != nil {
+		return "", "", err
+	}
+	pda, err := findProgramAddress([][]byte{[]byte("metadata"), program, mintKey}, program)
+	if err != nil {
+		return "", "", err
+	}
+	data, _, err := accountInfo(ctx, encodeBase58(pda))
+	if err != nil || data == nil {
+		return "", "", err
+	}
+	name, rest, ok := borshString(data[min(metadataNameOffset, len(data)):])
+	if !ok {
+		return "", "", nil
+	}
+	symbol, _, _ := borshString(rest)
+	return symbol, name, nil
+}
+
+// tokenSymbol 已缓存的代币符号，未知时为空
+func tokenSymbol(mint string) string {
+	if meta := cachedTokenMetadata(mint); meta != nil {
+		return meta.Symbol
+	}
+	return ""
+}
+
+// resolveTokenSymbol 代币符号：未缓存时读取链上（最多 5 秒，失败或没有元数据时为空）
+func resolveTokenSymbol(mint string) string {
+	ctx, cancel := context.WithTimeout(globalCtx, 5*time.Second)
+	defer cancel()
+	if meta, err := tokenMetadata(ctx, mint); err == nil {
+		return meta.Symbol
+	}
+	return ""
+}
+
+// tokenLabel 日志与汇总中的代币名称：符号，未知时为地址缩写
+func tokenLabel(mint string) string {
+	if mint == swapToSOL {
+		return mint
+	}
+	if s := tokenSymbol(mint); s != "" {
+		return s
+	}
+	return shortAddress(mint)
+}
+
+// tokenUIAmount 把原始整数数量（最小单位）按代币精度换算为代币数量
+func tokenUIAmount(ctx context.Context, mint, raw string) (float64, error) {
+	meta, err := tokenMetadata(ctx, mint)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := new(big.Float).SetString(strings.TrimSpace(raw))
+	if !ok {
+		return 0, fmt.Errorf("无效的数量: %q", raw)
+	}
+	v, _ := new(big.Float).Quo(n, big.NewFloat(math.Pow10(meta.Decimals))).Float64()
+	return v, nil
+}
+
+// formatTokenAmounts 代币 -> 数量 的可读形式，如 "BONK=1250.5 / SOL=0.84"
+func formatTokenAmounts(amounts map[string]float64) string {
+	parts := make([]string, 0, len(amounts))
+	for mint, amount := range amounts {
+		parts = append(parts, fmt.Sprintf("%s=%g", tokenLabel(mint), amount))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " / ")
+}
+
+// listTokenMetadata 已缓存的代币信息（按符号排序，GET /tokens/metadata）
+func listTokenMetadata() []TokenMetadata {
+	tokenMetaMutex.Lock()
+	result := make([]TokenMetadata, 0, len(tokenMetaLocked()))
+	for _, meta := range tokenMetaLocked() {
+		result = append(result, *meta)
+	}
+	tokenMetaMutex.Unlock()
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Symbol != result[b].Symbol {
+			return result[a].Symbol < result[b].Symbol
+		}
+		return result[a].Mint < result[b].Mint
+	})
+	return result
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 代币安全检查不通过时的处理
+const (
+	safetyActionSkip = "skip" // 拒绝信号（不写出池文件）
+	safetyActionFlag = "flag" // 照常入场，池文件带 tokenSafety 字段并告警
+)
+
+// SPL Token mint 账户布局（Token-2022 的前 82 字节相同）
+const (
+	mintAuthorityOptionOffset = 0
+	mintSupplyOffset          = 36
+	mintFreezeOptionOffset    = 46
+	mintBaseLength            = 82
+	tokenAccountOwnerOffset   = 32
+	tokenAccountMinLength     = 72
+)
+
+// 保留的检查记录数
+const maxTokenSafetyRecords = 500
+
+// TokenSafetyConfig 开仓前的代币安全检查：外部风险评分接口（RugCheck 或兼容接口）与链上检查
+type TokenSafetyConfig struct {
+	Enabled bool   `json:"enabled"`
+	Action  string `json:"action"` // skip（默认）或 flag
+
+	// 风险评分接口：URL 中的 {mint} 替换为代币地址；为空时只做链上检查
+	ProviderURL     string   `json:"providerUrl"`     // 默认 RugCheck 的 /v1/tokens/{mint}/report/summary
+	MaxRiskScore    float64  `json:"maxRiskScore"`    // score_normalised 上限，0 表示不检查
+	RejectRiskLevel []string `json:"rejectRiskLevel"` // 出现这些级别的风险项即不通过，默认 danger
+
+	// 链上检查
+	RequireNoMintAuthority   bool     `json:"requireNoMintAuthority"`   // 铸币权限必须已放弃
+	RequireNoFreezeAuthority bool     `json:"requireNoFreezeAuthority"` // 冻结权限必须已放弃
+	MaxTopHolderPercent      float64  `json:"maxTopHolderPercent"`      // 单个持有者占总供应量的上限（%），0 表示不检查
+	MaxTop10Percent          float64  `json:"maxTop10Percent"`          // 前 10 持有者合计上限（%），0 表示不检查
+	ExcludeHolders           []string `json:"excludeHolders"`           // 不计入集中度的持有者（钱包地址，如销毁地址、锁仓合约）；池自身的储备账户总是排除
+
+	FailOpen       bool `json:"failOpen"`       // 接口或 RPC 出错时放行（默认视为不通过）
+	CacheMinutes   int  `json:"cacheMinutes"`   // 同一代币的检查结果缓存时间
+	TimeoutSeconds int  `json:"timeoutSeconds"` // 单次检查（接口与链上读取）的超时
+}
+
+// TokenSafetyReport 一次代币安全检查的结果（data/state/token_safety.json 与池文件的 tokenSafety 字段）
+type TokenSafetyReport struct {
+	Time            string   `json:"time"`
+	Pool            string   `json:"poolAddress,omitempty"`
+	Mint            string   `json:"mint"`
+	Passed          bool     `json:"passed"`
+	Failures        []string `json:"failures,omitempty"`
+	Action          string   `json:"action,omitempty"` // 未通过时的处理：skip / flag
+	RiskScore       *float64 `json:"riskScore,omitempty"`
+	Risks           []string `json:"risks,omitempty"`
+	MintAuthority   bool     `json:"mintAuthority"`
+	FreezeAuthority bool     `json:"freezeAuthority"`
+	TopHolderPct    float64  `json:"topHolderPercent"`
+	Top10Pct        float64  `json:"top10Percent"`
+	Error           string   `json:"error,omitempty"`
+}
+
+var (
+	tokenSafetyHTTP  = &http.Client{}
+	tokenSafetyMutex sync.Mutex
+	tokenSafetyCache = map[string]tokenSafetyCached{} // 池/代币 -> 最近一次检查结果
+)
+
+type tokenSafetyCached struct {
+	report TokenSafetyReport
+	at     time.Time
+}
+
+func (c TokenSafetyConfig) validate(watch WalletWatchConfig, pool RPCPoolConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Action != safetyActionSkip && c.Action != safetyActionFlag {
+		return fmt.Errorf("tokenSafety.action 必须是 skip 或 flag")
+	}
+	if c.MaxRiskScore < 0 || c.MaxTopHolderPercent < 0 || c.MaxTop10Percent < 0 {
+		return fmt.Errorf("tokenSafety 的阈值不能为负数")
+	}
+	if c.ProviderURL != "" && !strings.Contains(c.ProviderURL, "{mint}") {
+		return fmt.Errorf("tokenSafety.providerUrl 必须包含 {mint}")
+	}
+	onChain := c.RequireNoMintAuthority || c.RequireNoFreezeAuthority || c.MaxTopHolderPercent > 0 || c.MaxTop10Percent > 0
+	if onChain && watch.RPCURL == "" && len(pool.Endpoints) == 0 {
+		return fmt.Errorf("tokenSafety 的链上检查需要 walletWatch.rpcUrl 或 rpcPool.endpoints")
+	}
+	if c.ProviderURL == "" && !onChain {
+		return fmt.Errorf("tokenSafety 未配置任何检查（providerUrl 或链上检查）")
+	}
+	if c.CacheMinutes < 0 {
+		return fmt.Errorf("tokenSafety.cacheMinutes 不能为负数")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("tokenSafety.timeoutSeconds 必须大于0")
+	}
+	return nil
+}
+
+// tokenSafetyCheck 检查信号中的代币；返回 false 表示按 skip 处理拒绝信号。
+// flag 模式下未通过的结果写入 data["tokenSafety"]（随池文件保存）并告警
+func tokenSafetyCheck(profitData *ProfitData) bool {
+	cfg := currentConfig().TokenSafety
+	if !cfg.Enabled || isDemo() {
+		return true
+	}
+	pool := profitData.PoolAddress
+	ca, _ := profitData.Data["ca"].(string)
+	mint := tokenSafetyMint(pool, ca)
+	if mint == "" {
+		logWarn("⚠️ 代币安全检查：无法确定代币地址", "pool", pool)
+		return cfg.FailOpen
+	}
+
+	report := checkTokenSafety(pool, mint)
+	if report.Passed {
+		return true
+	}
+	report.Action = cfg.Action
+	appendHistory("token_safety", report, maxTokenSafetyRecords)
+	detail := strings.Join(report.Failures, "; ")
+	fields := map[string]string{"pool": pool, "mint": mint, "action": cfg.Action}
+	if cfg.Action == safetyActionFlag {
+		profitData.Data["tokenSafety"] = report
+		logOutput("⚠️ 代币安全检查未通过（标记后继续）: %s %s（%s）\n", poolLabel(pool), mint, detail)
+		notifyKeyed(eventTokenUnsafe, levelWarning, mint, "代币安全检查未通过（已标记）", detail, fields)
+		return true
+	}
+	recordSkip(subsystemEntry, skipUnsafe, pool, mint, detail)
+	logOutput("🚫 代币安全检查未通过，跳过信号: %s %s（%s）\n", poolLabel(pool), mint, detail)
+	notifyKeyed(eventTokenUnsafe, levelInfo, mint, "代币安全检查未通过，已跳过", detail, fields)
+	return false
+}
+
+// tokenSafetyMint 待检查的代币：信号的 ca 字段，缺失时取池中不是 SOL/USDC/USDT 的一侧
+func tokenSafetyMint(pool, ca string) string {
+	if ca != "" {
+		return ca
+	}
+	if pool == "" || len(rpcEndpoints()) == 0 {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(currentConfig().TokenSafety.TimeoutSeconds)*time.Second)
+	defer cancel()
+	data, err := accountData(ctx, pool)
+	if err != nil || len(data) < lbPairMinLength {
+		return ""
+	}
+	mintX := encodeBase58(data[lbPairMintXOffset:lbPairMintYOffset])
+	mintY := encodeBase58(data[lbPairMintYOffset:lbPairMinLength])
+	if _, known := knownMintSymbols[mintX]; known {
+		return mintY
+	}
+	return mintX
+}
+
+// checkTokenSafety 依次执行风险评分接口与链上检查（结果按池与代币缓存 cacheMinutes）
+func checkTokenSafety(pool, mint string) TokenSafetyReport {
+	cfg := currentConfig().TokenSafety
+	key := pool + "/" + mint
+	tokenSafetyMutex.Lock()
+	cached, ok := tokenSafetyCache[key]
+	tokenSafetyMutex.Unlock()
+	if ok && time.Since(cached.at) < time.Duration(cfg.CacheMinutes)*time.Minute {
+		return cached.report
+	}
+
+	ctx, cancel := context.WithTimeout(globalCtx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	report := TokenSafetyReport{Time: appNow().Format(time.RFC3339), Pool: pool, Mint: mint}
+	var errs []string
+	if cfg.ProviderURL != "" {
+		if err := checkRiskProvider(ctx, cfg, &report); err != nil {
+			errs = append(errs, "评分接口: "+err.Error())
+		}
+	}
+	if cfg.RequireNoMintAuthority || cfg.RequireNoFreezeAuthority || cfg.MaxTopHolderPercent > 0 || cfg.MaxTop10Percent > 0 {
+		if err := checkMintOnChain(ctx, cfg, &report); err != nil {
+			errs = append(errs, "链上检查: "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		report.Error = strings.Join(errs, "; ")
+		logWarn("⚠️ 代币安全检查出错", "mint", mint, "error", report.Error)
+		if !cfg.FailOpen {
+			report.Failures = append(report.Failures, "检查出错: "+report.Error)
+		}
+	}
+	report.Passed = len(report.Failures) == 0
+
+	// 出错的结果不缓存，下一个信号重新检查
+	if report.Error == "" {
+		tokenSafetyMutex.Lock()
+		tokenSafetyCache[key] = tokenSafetyCached{report: report, at: time.Now()}
+		tokenSafetyMutex.Unlock()
+	}
+	return report
+}
+
+// checkRiskProvider 查询风险评分接口：RugCheck 的 score_normalised 与 risks[].level；
+// 兼容接口也可以直接返回 {"safe": false, "reason": "..."}
+func checkRiskProvider(ctx context.Context, cfg TokenSafetyConfig, report *TokenSafetyReport) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(cfg.ProviderURL, "{mint}", report.Mint), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := tokenSafetyHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var body struct {
+		Score           *float64 `json:"score"`
+		ScoreNormalised *float64 `json:"score_normalised"`
+		Risks           []struct {
+			Name  string `json:"name"`
+			Level string `json:"level"`
+		} `json:"risks"`
+		Safe   *bool  `json:"safe"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	if body.Safe != nil && !*body.Safe {
+		reason := body.Reason
+		if reason == "" {
+			reason = "接口判定不安全"
+		}
+		report.Failures = append(report.Failures, reason)
+	}
+	report.RiskScore = body.ScoreNormalised
+	if report.RiskScore == nil {
+		report.RiskScore = body.Score
+	}
+	if cfg.MaxRiskScore > 0 && report.RiskScore != nil && *report.RiskScore > cfg.MaxRiskScore {
+		report.Failures = append(report.Failures, fmt.Sprintf("风险评分 %.0f 高于 %.0f", *report.RiskScore, cfg.MaxRiskScore))
+	}
+	for _, r := range body.Risks {
+		report.Risks = append(report.Risks, r.Level+": "+r.Name)
+		for _, level := range cfg.RejectRiskLevel {
+			if strings.EqualFold(r.Level, level) {
+				report.Failures = append(report.Failures, fmt.Sprintf("风险项 [%s] %s", r.Level, r.Name))
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// checkMintOnChain 读取 mint 账户的铸币 / 冻结权限与最大持有者（getTokenLargestAccounts，排除池储备与 excludeHolders）
+func checkMintOnChain(ctx context.Context, cfg TokenSafetyConfig, report *TokenSafetyReport) error {
+	data, err := accountData(ctx, report.Mint)
+	if err != nil {
+		return err
+	}
+	if len(data) < mintBaseLength {
+		return fmt.Errorf("mint 账户数据长度不足: %d", len(data))
+	}
+	report.MintAuthority = binary.LittleEndian.Uint32(data[mintAuthorityOptionOffset:]) == 1
+	report.FreezeAuthority = binary.LittleEndian.Uint32(data[mintFreezeOptionOffset:]) == 1
+	if cfg.RequireNoMintAuthority && report.MintAuthority {
+		report.Failures = append(report.Failures, "铸币权限未放弃")
+	}
+	if cfg.RequireNoFreezeAuthority && report.FreezeAuthority {
+		report.Failures = append(report.Failures, "冻结权限未放弃")
+	}
+	if cfg.MaxTopHolderPercent <= 0 && cfg.MaxTop10Percent <= 0 {
+		return nil
+	}
+
+	supply := new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[mintSupplyOffset:]))
+	if supply.Sign() == 0 {
+		return fmt.Errorf("总供应量为 0")
+	}
+	var largest struct {
+		Value []struct {
+			Address string `json:"address"`
+			Amount  string `json:"amount"`
+		} `json:"value"`
+	}
+	if err := solanaRPC(ctx, "getTokenLargestAccounts", []interface{}{report.Mint}, &largest); err != nil {
+		return err
+	}
+	var addresses []string
+	for _, v := range largest.Value {
+		addresses = append(addresses, v.Address)
+	}
+	accounts, err := multipleAccountData(ctx, addresses)
+	if err != nil {
+		return err
+	}
+	excluded := map[string]bool{report.Pool: report.Pool != ""}
+	for _, h := range cfg.ExcludeHolders {
+		excluded[h] = true
+	}
+
+	// 前 10 个未排除持有者的占比（%）
+	counted := 0
+	supplyF, _ := new(big.Float).SetInt(supply).Float64()
+	for _, v := range largest.Value {
+		if acc := accounts[v.Address]; len(acc) >= tokenAccountMinLength && excluded[encodeBase58(acc[tokenAccountOwnerOffset:tokenAccountOwnerOffset+32])] {
+			continue
+		}
+		amount, ok := new(big.Float).SetString(v.Amount)
+		if !ok {
+			continue
+		}
+		f, _ := amount.Float64()
+		pct := f / supplyF * 100
+		if counted == 0 {
+			report.TopHolderPct = pct
+		}
+		if counted < 10 {
+			report.Top10Pct += pct
+		}
+		counted++
+	}
+	if cfg.MaxTopHolderPercent > 0 && report.TopHolderPct > cfg.MaxTopHolderPercent {
+		report.Failures = append(report.Failures, fmt.Sprintf("最大持有者占比 %.1f%% 高于 %.1f%%", report.TopHolderPct, cfg.MaxTopHolderPercent))
+	}
+	if cfg.MaxTop10Percent > 0 && report.Top10Pct > cfg.MaxTop10Percent {
+		report.Failures = append(report.Failures, fmt.Sprintf("前 10 持有者占比 %.1f%% 高于 %.1f%%", report.Top10Pct, cfg.MaxTop10Percent))
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TripwireConfig 疑似私钥泄露时自动冻结所有交易（依赖 walletWatch）
+type TripwireConfig struct {
+	Enabled        bool    `json:"enabled"`
+	MinOutgoingSOL float64 `json:"minOutgoingSOL"` // 外部交易转出 SOL（不含手续费）超过该值即冻结；代币余额任何减少都会冻结
+	BalanceDropSOL float64 `json:"balanceDropSOL"` // 两次轮询间无本程序操作但 SOL 余额下降超过该值即冻结（0 表示不检查）
+}
+
+// FreezeState 冻结状态（data/state/freeze.json），需通过 API 手动解除
+type FreezeState struct {
+	Frozen     bool   `json:"frozen"`
+	Reason     string `json:"reason,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+	FrozenAt   string `json:"frozenAt,omitempty"`
+	UnfrozenAt string `json:"unfrozenAt,omitempty"`
+}
+
+// 冻结期间拒绝执行的错误
+var errFrozen = errors.New("transactions frozen by tripwire")
+
+var (
+	frozenFlag    atomic.Bool
+	freezeMutex   sync.Mutex
+	balanceMutex  sync.Mutex
+	lastBalance   = int64(-1) // 上次轮询的 SOL 余额（lamports），-1 表示未知
+	lastBalanceAt time.Time
+)
+
+func (c TripwireConfig) validate(walletWatch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if !walletWatch.Enabled {
+		return fmt.Errorf("tripwire 依赖 walletWatch，请同时启用")
+	}
+	if c.MinOutgoingSOL < 0 || c.BalanceDropSOL < 0 {
+		return fmt.Errorf("tripwire 的阈值不能为负数")
+	}
+	return nil
+}
+
+func isFrozen() bool { return frozenFlag.Load() }
+
+// 启动时恢复冻结状态
+func loadFreezeState() {
+	var st FreezeState
+	if err := loadStateFile("freeze", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	frozenFlag.Store(st.Frozen)
+	if st.Frozen {
+		logWarn("🧊 交易处于冻结状态，需通过 POST /unfreeze 手动解除", "reason", st.Reason, "frozenAt", st.FrozenAt)
+	}
+}
+
+func currentFreezeState() FreezeState {
+	freezeMutex.Lock()
+	defer freezeMutex.Unlock()
+	var st FreezeState
+	if err := loadStateFile("freeze", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return st
+}
+
+// freezeTransactions 冻结所有交易子系统并发送紧急告警
+func freezeTransactions(reason, signature string) {
+	freezeMutex.Lock()
+	if frozenFlag.Load() {
+		freezeMutex.Unlock()
+		return
+	}
+	st := FreezeState{Frozen: true, Reason: reason, Signature: signature, FrozenAt: time.Now().Format(time.RFC3339)}
+	frozenFlag.Store(true)
+	if err := saveStateFile("freeze", st); err != nil {
+		logOutput("❌ 保存冻结状态失败: %v\n", err)
+	}
+	freezeMutex.Unlock()
+
+	logError("🧊 触发安全冻结，已停止所有交易", "reason", reason, "signature", signature)
+	notifySync(eventTripwire, levelCritical, "🧊 安全冻结：已停止所有交易",
+		fmt.Sprintf("%s（签名: %s）。请检查钱包后通过 POST /unfreeze 手动解除", reason, signature))
+}
+
+// unfreezeTransactions 手动解除冻结
+func unfreezeTransactions() FreezeState {
+	freezeMutex.Lock()
+	defer freezeMutex.Unlock()
+	var st FreezeState
+	if err := loadStateFile("freeze", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	st.Frozen = false
+	st.UnfrozenAt = time.Now().Format(time.RFC3339)
+	frozenFlag.Store(false)
+	if err := saveStateFile("freeze", st); err != nil {
+		logOutput("❌ 保存冻结状态失败: %v\n", err)
+	}
+	balanceMutex.Lock()
+	lastBalance = -1 // 解冻后重新建立余额基线
+	balanceMutex.Unlock()
+	logOutput("🔓 已手动解除交易冻结\n")
+	return st
+}
+
+type parsedTokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UITokenAmount struct {
+		Amount string `json:"amount"`
+	} `json:"uiTokenAmount"`
+}
+
+type parsedTransaction struct {
+	Meta *struct {
+		Fee               int64                `json:"fee"`
+		PreBalances       []int64              `json:"preBalances"`
+		PostBalances      []int64              `json:"postBalances"`
+		PreTokenBalances  []parsedTokenBalance `json:"preTokenBalances"`
+		PostTokenBalances []parsedTokenBalance `json:"postTokenBalances"`
+	} `json:"meta"`
+	Transaction struct {
+		Message struct {
+			AccountKeys []struct {
+				Pubkey string `json:"pubkey"`
+			} `json:"accountKeys"`
+		} `json:"message"`
+	} `json:"transaction"`
+}
+
+// 分析外部交易是否从钱包转出资产，返回原因（空字符串表示没有转出）
+func outgoingTransferReason(ctx context.Context, address, signature string) (string, error) {
+	var tx *parsedTransaction
+	params := []interface{}{signature, map[string]interface{}{"encoding": "jsonParsed", "maxSupportedTransactionVersion": 0, "commitment": "confirmed"}}
+	if err := solanaRPC(ctx, "getTransaction", params, &tx); err != nil {
+		return "", err
+	}
+	if tx == nil || tx.Meta == nil {
+		return "", fmt.Errorf("交易不存在或缺少 meta: %s", signature)
+	}
+
+	meta := tx.Meta
+	for i, key := range tx.Transaction.Message.AccountKeys {
+		if key.Pubkey != address || i >= len(meta.PreBalances) || i >= len(meta.PostBalances) {
+			continue
+		}
+		delta := meta.PostBalances[i] - meta.PreBalances[i]
+		if i == 0 {
+			delta += meta.Fee // 钱包为手续费支付方
+		}
+		if out := float64(-delta) / 1e9; out > currentConfig().Tripwire.MinOutgoingSOL {
+			return fmt.Sprintf("外部交易转出 %.6f SOL", out), nil
+		}
+	}
+
+	pre := map[int]parsedTokenBalance{}
+	for _, b := range meta.PreTokenBalances {
+		if b.Owner == address {
+			pre[b.AccountIndex] = b
+		}
+	}
+	for idx, before := range pre {
+		after := int64(0)
+		for _, b := range meta.PostTokenBalances {
+			if b.AccountIndex == idx {
+				after, _ = strconv.ParseInt(b.UITokenAmount.Amount, 10, 64)
+			}
+		}
+		amount, _ := strconv.ParseInt(before.UITokenAmount.Amount, 10, 64)
+		if after < amount {
+			return fmt.Sprintf("外部交易转出代币 %s（%d -> %d）", before.Mint, amount, after), nil
+		}
+	}
+	return "", nil
+}
+
+// 检查外部交易，发现转出即冻结
+func checkExternalTransaction(address string, tx WalletTx) {
+	if !currentConfig().Tripwire.Enabled || tx.Failed {
+		return
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+	defer cancel()
+	reason, err := outgoingTransferReason(ctx, address, tx.Signature)
+	if err != nil {
+		logWarn("⚠️ 解析外部交易失败", "signature", tx.Signature, "error", err)
+		return
+	}
+	if reason != "" {
+		freezeTransactions(reason, tx.Signature)
+	}
+}
+
+// 检查两次轮询间的 SOL 余额变化：没有本程序操作时余额下降超过阈值即冻结
+func checkBalanceDrop(address string) {
+	cfg := currentConfig().Tripwire
+	if !cfg.Enabled || cfg.BalanceDropSOL <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 20*time.Second)
+	defer cancel()
+	var result struct {
+		Value int64 `json:"value"`
+	}
+	if err := solanaRPC(ctx, "getBalance", []interface{}{address, map[string]string{"commitment": "confirmed"}}, &result); err != nil {
+		logWarn("⚠️ 查询钱包余额失败", "address", address, "error", err)
+		return
+	}
+
+	balanceMutex.Lock()
+	prev, prevAt := lastBalance, lastBalanceAt
+	lastBalance, lastBalanceAt = result.Value, time.Now()
+	balanceMutex.Unlock()
+	if prev < 0 {
+		return
+	}
+	drop := float64(prev-result.Value) / 1e9
+	if drop > cfg.BalanceDropSOL && !botActiveSince(prevAt) {
+		freezeTransactions(fmt.Sprintf("无本程序操作期间 SOL 余额下降 %.6f", drop), "")
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// 终端监控（子命令 tui）：通过运行中进程的 HTTP 管理接口定时拉取池、仓位、任务与最近的错误，
+// 并可对选中的池手动领取、平仓或拉黑代币。只依赖 ANSI 转义序列与 stty，不引入终端 UI 库
+
+// tuiSnapshot 一次刷新取得的数据
+type tuiSnapshot struct {
+	Status    map[string]interface{}
+	Pools     []PoolRecord
+	Positions map[string]*PositionRecord // pool -> 生命周期记录
+	Jobs      []JobStatus
+	Queue     []QueueJobStatus
+	Errors    []AuditEntry
+	FetchedAt time.Time
+	Err       error
+}
+
+// tuiRow 池列表中的一行
+type tuiRow struct {
+	Pool     PoolRecord
+	Position *PositionRecord
+}
+
+// tuiConfirm 等待确认的操作
+type tuiConfirm struct {
+	prompt string
+	run    func() string
+}
+
+type tuiClient struct {
+	base string
+	http *http.Client
+}
+
+type tuiModel struct {
+	client   *tuiClient
+	snap     tuiSnapshot
+	rows     []tuiRow
+	selected int
+	message  string
+	confirm  *tuiConfirm
+}
+
+func cmdTUI(args []string) {
+	fs, common := newCommandFlags("tui")
+	apiURL := fs.String("url", "", "管理接口地址（默认按配置 api.listen，如 http://127.0.0.1:8088）")
+	interval := fs.Duration("interval", 2*time.Second, "刷新间隔")
+	fs.Parse(args)
+	loadAppConfig(common)
+
+	base := apiBaseURL(*apiURL)
+	if base == "" {
+		log.Fatalf("未启用 HTTP 管理接口（api.enabled），请通过 --url 指定运行中进程的接口地址")
+	}
+	if *interval < 500*time.Millisecond {
+		log.Fatalf("--interval 不能小于 500ms")
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		log.Fatalf("tui 需要在终端中运行")
+	}
+
+	restore, err := tuiRawMode()
+	if err != nil {
+		log.Fatalf("切换终端模式失败: %v", err)
+	}
+	// 备用屏幕、隐藏光标；退出时恢复
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+
+	m := &tuiModel{client: &tuiClient{base: base, http: &http.Client{Timeout: 5 * time.Second}}}
+	keys := make(chan string, 16)
+	go tuiReadKeys(os.Stdin, keys)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	m.refresh()
+	m.render()
+	for {
+		select {
+		case <-sigChan:
+			return
+		case <-ticker.C:
+			m.refresh()
+		case key, ok := <-keys:
+			if !ok || !m.handleKey(key) {
+				return
+			}
+		}
+		m.render()
+	}
+}
+
+// tuiRawMode 关闭行缓冲与回显（保留 Ctrl+C 信号与输出换行处理），返回恢复原设置的函数
+func tuiRawMode() (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
+
+// tuiTermSize 终端行数与列数，取不到时按 24×80
+func tuiTermSize() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err == nil {
+		if f := strings.Fields(string(out)); len(f) == 2 {
+			rows, err1 := strconv.Atoi(f[0])
+			cols, err2 := strconv.Atoi(f[1])
+			if err1 == nil && err2 == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// tuiReadKeys 逐个读取按键；方向键的转义序列转换为 up / down
+func tuiReadKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		s := string(buf[:n])
+		switch s {
+		case "\x1b[A", "\x1bOA":
+			keys <- "up"
+		case "\x1b[B", "\x1bOB":
+			keys <- "down"
+		default:
+			for _, ch := range s {
+				keys <- string(ch)
+			}
+		}
+	}
+}
+
+// apiBaseURL 运行中进程的管理接口地址：--url 优先，否则按配置 api.listen；未启用时为空
+func apiBaseURL(url string) string {
+	if base := strings.TrimRight(url, "/"); base != "" {
+		return base
+	}
+	if !currentConfig().API.Enabled || currentConfig().API.Listen == "" {
+		return ""
+	}
+	listen := currentConfig().API.Listen
+	if strings.HasPrefix(listen, ":") {
+		listen = "127.0.0.1" + listen
+	}
+	return "http://" + listen
+}
+
+func (c *tuiClient) get(path string, v interface{}) error {
+	req, err := newAPIRequest(context.Background(), http.MethodGet, c.base+path)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// post 执行操作，返回接口的状态说明
+func (c *tuiClient) post(path string) (string, error) {
+	req, err := newAPIRequest(context.Background(), http.MethodPost, c.base+path)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HTTP %d: %v", resp.StatusCode, body["error"])
+	}
+	if s, ok := body["status"].(string); ok {
+		return s, nil
+	}
+	return "ok", nil
+}
+
+// refresh 拉取全部数据；单个接口失败时保留上次的数据并显示错误
+func (m *tuiModel) refresh() {
+	c := m.client
+	snap := m.snap
+	snap.Err = nil
+	var records []*PositionRecord
+	for _, step := range []struct {
+		path string
+		v    interface{}
+	}{
+		{"/status", &snap.Status},
+		{"/pools", &snap.Pools},
+		{"/lifecycle", &records},
+		{"/jobs", &snap.Jobs},
+		{"/queue", &snap.Queue},
+		{"/events?type=" + busError + "&limit=8", &snap.Errors},
+	} {
+		if err := c.get(step.path, step.v); err != nil && snap.Err == nil {
+			snap.Err = err
+		}
+	}
+	if records != nil {
+		snap.Positions = map[string]*PositionRecord{}
+		for _, r := range records {
+			snap.Positions[r.PoolAddress] = r
+		}
+	}
+	snap.FetchedAt = time.Now()
+	m.snap = snap
+	m.buildRows()
+}
+
+// buildRows 未平仓的池在前，其余按名称排序；刷新后尽量保持选中同一个池
+func (m *tuiModel) buildRows() {
+	var current string
+	if m.selected < len(m.rows) {
+		current = m.rows[m.selected].Pool.PoolAddress
+	}
+	rows := make([]tuiRow, 0, len(m.snap.Pools))
+	for _, p := range m.snap.Pools {
+		rows = append(rows, tuiRow{Pool: p, Position: m.snap.Positions[p.PoolAddress]})
+	}
+	open := func(r tuiRow) bool { return r.Position != nil && r.Position.State != positionStateClosed }
+	sort.SliceStable(rows, func(a, b int) bool {
+		if open(rows[a]) != open(rows[b]) {
+			return open(rows[a])
+		}
+		return rows[a].name() < rows[b].name()
+	})
+	m.rows = rows
+	m.selected = 0
+	for i, r := range rows {
+		if r.Pool.PoolAddress == current {
+			m.selected = i
+		}
+	}
+}
+
+func (r tuiRow) name() string {
+	if r.Pool.PoolName != "" {
+		return r.Pool.PoolName
+	}
+	return shortAddress(r.Pool.PoolAddress)
+}
+
+// handleKey 处理按键，返回 false 表示退出
+func (m *tuiModel) handleKey(key string) bool {
+	if m.confirm != nil {
+		if key == "y" || key == "Y" {
+			m.message = m.confirm.run()
+			m.refresh()
+		} else {
+			m.message = "已取消"
+		}
+		m.confirm = nil
+		return true
+	}
+	var row *tuiRow
+	if m.selected < len(m.rows) {
+		row = &m.rows[m.selected]
+	}
+	switch key {
+	case "q", "Q":
+		return false
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(m.rows)-1 {
+			m.selected++
+		}
+	case "r":
+		m.refresh()
+		m.message = "已刷新"
+	case "c":
+		if row != nil {
+			m.message = m.poolAction(row.Pool.PoolAddress, "claim")
+		}
+	case "x":
+		if row != nil {
+			pool := row.Pool.PoolAddress
+			m.confirm = &tuiConfirm{
+				prompt: fmt.Sprintf("确认领取并平仓 %s (%s)？[y/N]", row.name(), pool),
+				run:    func() string { return m.poolAction(pool, "close") },
+			}
+		}
+	case "b":
+		if row != nil {
+			kind, address := banKindToken, row.Pool.TokenAddress
+			if address == "" {
+				kind, address = banKindPool, row.Pool.PoolAddress
+			}
+			m.confirm = &tuiConfirm{
+				prompt: fmt.Sprintf("确认将%s %s 加入黑名单？[y/N]", map[string]string{banKindToken: "代币", banKindPool: "池"}[kind], address),
+				run:    func() string { return m.ban(kind, address) },
+			}
+		}
+	}
+	return true
+}
+
+func (m *tuiModel) poolAction(pool, action string) string {
+	status, err := m.client.post("/pools/" + url.PathEscape(pool) + "/" + action)
+	if err != nil {
+		return fmt.Sprintf("❌ %s %s 失败: %v", action, shortAddress(pool), err)
+	}
+	return fmt.Sprintf("✅ %s %s: %s", action, shortAddress(pool), status)
+}
+
+func (m *tuiModel) ban(kind, address string) string {
+	q := url.Values{"kind": {kind}, "address": {address}, "reason": {"tui"}}
+	if _, err := m.client.post("/bans?" + q.Encode()); err != nil {
+		return fmt.Sprintf("❌ 拉黑 %s 失败: %v", shortAddress(address), err)
+	}
+	return fmt.Sprintf("✅ 已拉黑 %s %s", kind, shortAddress(address))
+}
+
+// render 整屏重绘：状态栏、池与仓位、任务、最近错误、按键说明
+func (m *tuiModel) render() {
+	height, width := tuiTermSize()
+	s := m.snap
+	var out []string
+	line := func(format string, args ...interface{}) {
+		out = append(out, tuiClip(fmt.Sprintf(format, args...), width))
+	}
+
+	status := fmt.Sprintf("meteora_dlmm  %s  刷新 %s", m.client.base, s.FetchedAt.Format("15:04:05"))
+	if s.Status != nil {
+		status += fmt.Sprintf("  模式 %v  运行 %v", s.Status["mode"], s.Status["uptime"])
+		if paused, _ := s.Status["paused"].(bool); paused {
+			status += "  [已暂停]"
+		}
+		if frozen, _ := s.Status["frozen"].(bool); frozen {
+			status += "  [已冻结]"
+		}
+		if halted, _ := s.Status["killSwitch"].(bool); halted {
+			status += "  [停止开关]"
+		}
+	}
+	line("\x1b[1m%s\x1b[0m", status)
+	if s.Err != nil {
+		line("\x1b[31m接口错误: %v\x1b[0m", s.Err)
+	}
+	out = append(out, "")
+
+	// 下方区域固定占用的行数（任务、错误、提示），其余留给池列表
+	jobLines := len(s.Jobs) + 2
+	errLines := len(s.Errors) + 2
+	reserved := len(out) + 1 + jobLines + errLines + 2
+	maxRows := height - reserved
+	if maxRows < 3 {
+		maxRows = 3
+	}
+
+	table := tuiTable([]string{"POOL", "MODE", "STATE", "ENTRY", "LAST", "PNL%", "WITHDRAWN%", "AGE"}, len(m.rows), maxRows, m.selected,
+		func(i int) []string {
+			r := m.rows[i]
+			cols := []string{r.name(), r.Pool.Mode, "-", "-", "-", "-", "-", "-"}
+			if p := r.Position; p != nil {
+				cols[2] = p.State
+				cols[3] = tuiNumber(p.EntryPrice)
+				cols[4] = tuiNumber(p.LastPrice)
+				cols[5] = fmt.Sprintf("%.2f", p.PnLPercent)
+				if w := p.withdrawnPct(); w > 0 {
+					cols[6] = fmt.Sprintf("%.0f", w)
+				}
+				if at, err := time.Parse(time.RFC3339, p.OpenedAt); err == nil && p.State != positionStateClosed {
+					cols[7] = time.Since(at).Round(time.Minute).String()
+				}
+			}
+			return cols
+		})
+	for _, l := range table {
+		out = append(out, tuiClip(l, width))
+	}
+	out = append(out, "")
+
+	running, pending := 0, 0
+	for _, q := range s.Queue {
+		if q.State == "running" {
+			running++
+		} else {
+			pending++
+		}
+	}
+	line("\x1b[1m定时任务\x1b[0m（执行队列：执行中 %d，排队 %d）", running, pending)
+	for _, j := range s.Jobs {
+		state := ""
+		if j.Running {
+			state = " 执行中"
+		}
+		if j.Paused {
+			state += " 已暂停"
+		}
+		line("  %-14s 下次 %s%s", j.Name, tuiClock(j.NextRun), state)
+	}
+	out = append(out, "")
+
+	line("\x1b[1m最近错误\x1b[0m")
+	if len(s.Errors) == 0 {
+		line("  无")
+	}
+	for _, e := range s.Errors {
+		target := e.Pool
+		if target == "" {
+			target = e.Token
+		}
+		line("  %s %-6s %s %s", tuiClock(e.Time), e.Stage, shortAddress(target), e.Detail)
+	}
+	out = append(out, "")
+
+	if m.confirm != nil {
+		line("\x1b[33m%s\x1b[0m", m.confirm.prompt)
+	} else {
+		line("↑/↓ 或 j/k 选择  c 领取  x 平仓  b 拉黑代币  r 刷新  q 退出   %s", m.message)
+	}
+
+	if len(out) > height {
+		out = out[:height]
+	}
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(out, "\r\n"))
+}
+
+// tuiTable 表格行（表头 + 最多 maxRows 行数据，滚动使选中行可见；选中行反色）
+func tuiTable(header []string, n, maxRows, selected int, row func(i int) []string) []string {
+	start := 0
+	if selected >= maxRows {
+		start = selected - maxRows + 1
+	}
+	end := start + maxRows
+	if end > n {
+		end = n
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for i := start; i < end; i++ {
+		fmt.Fprintln(w, strings.Join(row(i), "\t"))
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	lines[0] = "\x1b[1m" + lines[0] + "\x1b[0m"
+	for i := start; i < end; i++ {
+		if i == selected {
+			lines[i-start+1] = "\x1b[7m" + lines[i-start+1] + "\x1b[0m"
+		}
+	}
+	if n > end {
+		lines = append(lines, fmt.Sprintf("  … 另有 %d 个池", n-end))
+	}
+	if n == 0 {
+		lines = append(lines, "  （没有池）")
+	}
+	return lines
+}
+
+// tuiClip 按显示宽度截断一行（宽字符按 2 列计，跳过 ANSI 转义序列）
+func tuiClip(s string, width int) string {
+	var b strings.Builder
+	cols, escape := 0, false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			escape = true
+		case escape:
+			if r >= '@' && r <= '~' && r != '[' {
+				escape = false
+			}
+		default:
+			w := 1
+			if r >= 0x1100 {
+				w = 2
+			}
+			if cols+w > width {
+				return b.String() + "\x1b[0m"
+			}
+			cols += w
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// tuiNumber 价格的简短显示
+func tuiNumber(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// tuiClock RFC3339 时间只显示时分秒
+func tuiClock(ts string) string {
+	if at, err := time.Parse(time.RFC3339, ts); err == nil {
+		return at.Format("15:04:05")
+	}
+	return ts
+}
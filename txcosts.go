@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TxCostConfig 记录每笔链上交易的实际成本（交易费、其中的优先费、新建账户押金减关闭账户退回的租金），按池与按天汇总并计入盈亏
+type TxCostConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"intervalSeconds"` // 查询交易详情的间隔（getTransaction，RPC 同 walletWatch.rpcUrl）
+	MaxAgeMinutes   int  `json:"maxAgeMinutes"`   // 发送后超过该时间仍查不到交易（未上链或节点不保留历史）时放弃
+	BatchSize       int  `json:"batchSize"`       // 每轮最多查询的交易数
+}
+
+// 每笔交易的签名基础费（lamports / 签名）
+const lamportsPerSignature = 5000
+
+// 保留的逐笔成本记录数
+const maxRecentTxCosts = 500
+
+// PendingTxCost 待查询成本的交易
+type PendingTxCost struct {
+	Signature string `json:"signature"`
+	Target    string `json:"target"`
+	Action    string `json:"action,omitempty"`
+	Pool      string `json:"poolAddress,omitempty"`
+	Token     string `json:"ca,omitempty"`
+	Wallet    string `json:"wallet,omitempty"`
+	SentAt    string `json:"sentAt"`
+}
+
+// TxCost 一笔交易的实际成本
+type TxCost struct {
+	PendingTxCost
+	At               string   `json:"at"` // 区块时间（未知时为查询时间）
+	Slot             uint64   `json:"slot"`
+	Failed           bool     `json:"failed,omitempty"` // 上链但执行失败（仍收取交易费）
+	FeeLamports      int64    `json:"feeLamports"`      // 交易费（基础费 + 优先费）
+	PriorityLamports int64    `json:"priorityLamports"` // 其中的优先费
+	RentLamports     int64    `json:"rentLamports"`     // 新建账户的押金减关闭账户退回的余额（负数为净退回）
+	Pools            []string `json:"pools,omitempty"`  // 计入盈亏的池（兑换按持有该代币的池均分）
+}
+
+// CostTotals 成本汇总
+type CostTotals struct {
+	Txs              int   `json:"txs"`
+	FeeLamports      int64 `json:"feeLamports"`
+	PriorityLamports int64 `json:"priorityLamports"`
+	RentLamports     int64 `json:"rentLamports"`
+}
+
+// DayCosts 一天的成本（配置时区），按目标细分
+type DayCosts struct {
+	CostTotals
+	Targets map[string]*CostTotals `json:"targets"`
+}
+
+// txCostState 待查询的交易、最近的逐笔成本与汇总（data/state/tx_costs.json）
+type txCostState struct {
+	Pending []PendingTxCost        `json:"pending"`
+	Recent  []TxCost               `json:"recent"`
+	Days    map[string]*DayCosts   `json:"days"`
+	Pools   map[string]*CostTotals `json:"pools"`
+}
+
+var txCostMutex sync.Mutex
+
+func (c TxCostConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("txCost 需要 walletWatch.rpcUrl")
+	}
+	if c.IntervalSeconds <= 0 || c.MaxAgeMinutes <= 0 || c.BatchSize <= 0 {
+		return fmt.Errorf("txCost.intervalSeconds、maxAgeMinutes、batchSize 必须大于0")
+	}
+	return nil
+}
+
+func (t *CostTotals) add(c TxCost) {
+	t.Txs++
+	t.FeeLamports += c.FeeLamports
+	t.PriorityLamports += c.PriorityLamports
+	t.RentLamports += c.RentLamports
+}
+
+func loadTxCosts() txCostState {
+	var st txCostState
+	if err := loadStateFile("tx_costs", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if st.Days == nil {
+		st.Days = map[string]*DayCosts{}
+	}
+	if st.Pools == nil {
+		st.Pools = map[string]*CostTotals{}
+	}
+	return st
+}
+
+// queueTxCosts 记录外部命令输出中的交易签名，稍后查询实际成本（每次尝试都调用：失败的尝试中已上链的交易同样收费）
+func queueTxCosts(ctx context.Context, target string, args []string, output []byte) {
+	if !currentConfig().TxCost.Enabled || isDemo() {
+		return
+	}
+	o := decodeScriptOutput(output)
+	sigs := o.Signatures()
+	if len(sigs) == 0 {
+		return
+	}
+	actions := map[string]string{}
+	for _, ev := range o.eventsOf(scriptEventSignature) {
+		actions[ev.Signature] = ev.Action
+	}
+	wallet, _ := ctx.Value(walletCtxKey{}).(string)
+	now := time.Now().Format(time.RFC3339)
+
+	txCostMutex.Lock()
+	defer txCostMutex.Unlock()
+	st := loadTxCosts()
+	queued := map[string]bool{}
+	for _, p := range st.Pending {
+		queued[p.Signature] = true
+	}
+	for _, c := range st.Recent {
+		queued[c.Signature] = true
+	}
+	for _, sig := range sigs {
+		if queued[sig] {
+			continue
+		}
+		queued[sig] = true
+		st.Pending = append(st.Pending, PendingTxCost{
+			Signature: sig, Target: target, Action: actions[sig], Pool: argValue(args, "--pool"),
+			Token: flagValue(args, "-input"), Wallet: wallet, SentAt: now,
+		})
+	}
+	if err := saveStateFile("tx_costs", st); err != nil {
+		logOutput("❌ 保存交易成本记录失败: %v\n", err)
+	}
+}
+
+// getTransaction 的返回中计算成本所需的部分
+type txDetail struct {
+	Slot        uint64 `json:"slot"`
+	BlockTime   *int64 `json:"blockTime"`
+	Transaction struct {
+		Signatures []string `json:"signatures"`
+	} `json:"transaction"`
+	Meta *struct {
+		Err               json.RawMessage  `json:"err"`
+		Fee               int64            `json:"fee"`
+		PreBalances       []int64          `json:"preBalances"`
+		PostBalances      []int64          `json:"postBalances"`
+		PreTokenBalances  []txTokenBalance `json:"preTokenBalances"`
+		PostTokenBalances []txTokenBalance `json:"postTokenBalances"`
+	} `json:"meta"`
+}
+
+type txTokenBalance struct {
+	AccountIndex int    `json:"accountIndex"`
+	Mint         string `json:"mint"`
+}
+
+// fetchTxCost 查询一笔交易的成本；交易尚未查到时返回 false。
+// 租金按余额变化估算：除付费账户外，交易前余额为 0 的账户（新建的仓位、代币账户、bin array）计为押金，交易后余额为 0 的账户（关闭的仓位等）计为退回；
+// wSOL 代币账户的余额是兑换或存入的资金，不计入
+func fetchTxCost(p PendingTxCost) (TxCost, bool, error) {
+	var d *txDetail
+	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+	defer cancel()
+	err := solanaRPC(ctx, "getTransaction", []interface{}{p.Signature,
+		map[string]interface{}{"encoding": "json", "commitment": "confirmed", "maxSupportedTransactionVersion": 0}}, &d)
+	if err != nil || d == nil || d.Meta == nil {
+		return TxCost{}, false, err
+	}
+	m := d.Meta
+	c := TxCost{PendingTxCost: p, Slot: d.Slot, FeeLamports: m.Fee, At: time.Now().Format(time.RFC3339)}
+	if d.BlockTime != nil {
+		c.At = time.Unix(*d.BlockTime, 0).Format(time.RFC3339)
+	}
+	c.Failed = len(m.Err) > 0 && string(m.Err) != "null"
+	c.PriorityLamports = max(m.Fee-int64(lamportsPerSignature*len(d.Transaction.Signatures)), 0)
+	if !c.Failed {
+		wsol := map[int]bool{}
+		for _, b := range append(m.PreTokenBalances, m.PostTokenBalances...) {
+			if b.Mint == solMint {
+				wsol[b.AccountIndex] = true
+			}
+		}
+		for i := 1; i < len(m.PreBalances) && i < len(m.PostBalances); i++ {
+			pre, post := m.PreBalances[i], m.PostBalances[i]
+			switch {
+			case wsol[i]:
+			case pre == 0 && post > 0:
+				c.RentLamports += post
+			case pre > 0 && post == 0:
+				c.RentLamports -= pre
+			}
+		}
+	}
+	return c, true, nil
+}
+
+// pollTxCosts 查询一批待处理交易的成本，计入汇总与盈亏台账
+func pollTxCosts() {
+	cfg := currentConfig().TxCost
+	txCostMutex.Lock()
+	pending := append([]PendingTxCost(nil), loadTxCosts().Pending...)
+	txCostMutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	if len(pending) > cfg.BatchSize {
+		pending = pending[:cfg.BatchSize]
+	}
+
+	maxAge := time.Duration(cfg.MaxAgeMinutes) * time.Minute
+	var costs []TxCost
+	done := map[string]bool{}
+	for _, p := range pending {
+		c, found, err := fetchTxCost(p)
+		if err != nil {
+			logWarn("⚠️ 查询交易成本失败", "signature", p.Signature, "target", p.Target, "error", err)
+			break
+		}
+		if found {
+			c.Pools = txCostPools(c)
+			costs = append(costs, c)
+			done[p.Signature] = true
+			continue
+		}
+		if sent, perr := time.Parse(time.RFC3339, p.SentAt); perr == nil && time.Since(sent) > maxAge {
+			logWarn("⚠️ 交易超时未查到，放弃记录成本", "signature", p.Signature, "target", p.Target, "pool", p.Pool)
+			done[p.Signature] = true
+		}
+	}
+	if len(done) == 0 {
+		return
+	}
+
+	txCostMutex.Lock()
+	st := loadTxCosts()
+	kept := st.Pending[:0]
+	for _, p := range st.Pending {
+		if !done[p.Signature] {
+			kept = append(kept, p)
+		}
+	}
+	st.Pending = kept
+	for _, c := range costs {
+		day := localDay(c.At)
+		dc := st.Days[day]
+		if dc == nil {
+			dc = &DayCosts{Targets: map[string]*CostTotals{}}
+			st.Days[day] = dc
+		}
+		dc.add(c)
+		if dc.Targets[c.Target] == nil {
+			dc.Targets[c.Target] = &CostTotals{}
+		}
+		dc.Targets[c.Target].add(c)
+		for _, pool := range c.Pools {
+			if st.Pools[pool] == nil {
+				st.Pools[pool] = &CostTotals{}
+			}
+			st.Pools[pool].add(splitTxCost(c, len(c.Pools)))
+		}
+		st.Recent = append(st.Recent, c)
+	}
+	if len(st.Recent) > maxRecentTxCosts {
+		st.Recent = st.Recent[len(st.Recent)-maxRecentTxCosts:]
+	}
+	if err := saveStateFile("tx_costs", st); err != nil {
+		logOutput("❌ 保存交易成本记录失败: %v\n", err)
+	}
+	txCostMutex.Unlock()
+
+	for _, c := range costs {
+		observeTxCost(c)
+		share := splitTxCost(c, len(c.Pools))
+		for _, pool := range c.Pools {
+			recordPnLTxCost(pool, share, c.At)
+		}
+	}
+}
+
+// txCostPools 成本计入的池：命令参数中的池；兑换按 recordPnLSwap 的规则归属到持有该代币的池
+func txCostPools(c TxCost) []string {
+	if c.Pool != "" {
+		return []string{c.Pool}
+	}
+	if c.Token != "" {
+		return swapAttributionPools(c.Token)
+	}
+	return nil
+}
+
+// splitTxCost 成本按池数均分（计入各池的一份）
+func splitTxCost(c TxCost, n int) TxCost {
+	if n <= 1 {
+		return c
+	}
+	c.FeeLamports /= int64(n)
+	c.PriorityLamports /= int64(n)
+	c.RentLamports /= int64(n)
+	return c
+}
+
+// observeTxCost 记录日志与指标
+func observeTxCost(c TxCost) {
+	metricTxCost.Add(lamportsToSOL(c.FeeLamports-c.PriorityLamports), c.Target, "fee")
+	metricTxCost.Add(lamportsToSOL(c.PriorityLamports), c.Target, "priority")
+	if c.RentLamports > 0 {
+		metricTxCost.Add(lamportsToSOL(c.RentLamports), c.Target, "rent_paid")
+	} else if c.RentLamports < 0 {
+		metricTxCost.Add(lamportsToSOL(-c.RentLamports), c.Target, "rent_refunded")
+	}
+	logDebug("💸 交易成本", "signature", c.Signature, "target", c.Target, "pool", c.Pool, "fee", c.FeeLamports,
+		"priority", c.PriorityLamports, "rent", c.RentLamports, "failed", c.Failed)
+}
+
+func lamportsToSOL(lamports int64) float64 {
+	return float64(lamports) / 1e9
+}
+
+// dayTxCosts 一天的成本汇总（配置时区的日期）
+func dayTxCosts(day string) CostTotals {
+	txCostMutex.Lock()
+	defer txCostMutex.Unlock()
+	if dc := loadTxCosts().Days[day]; dc != nil {
+		return dc.CostTotals
+	}
+	return CostTotals{}
+}
+
+// TxCostReport GET /costs：按天与按池的汇总、最近的逐笔成本与待查询数
+type TxCostReport struct {
+	Days    map[string]*DayCosts   `json:"days"`
+	Pools   map[string]*CostTotals `json:"pools"`
+	Recent  []TxCost               `json:"recent"`
+	Pending int                    `json:"pending"`
+}
+
+// txCostReport pool 不为空时只返回该池的逐笔记录与汇总；recent 按时间倒序
+func txCostReport(pool string, limit int) TxCostReport {
+	txCostMutex.Lock()
+	st := loadTxCosts()
+	txCostMutex.Unlock()
+	report := TxCostReport{Days: st.Days, Pools: st.Pools, Recent: []TxCost{}, Pending: len(st.Pending)}
+	if pool != "" {
+		report.Days = nil
+		report.Pools = map[string]*CostTotals{}
+		if t := st.Pools[pool]; t != nil {
+			report.Pools[pool] = t
+		}
+	}
+	for i := len(st.Recent) - 1; i >= 0 && (limit <= 0 || len(report.Recent) < limit); i-- {
+		c := st.Recent[i]
+		if pool == "" || containsTarget(c.Pools, pool) {
+			report.Recent = append(report.Recent, c)
+		}
+	}
+	sort.SliceStable(report.Recent, func(a, b int) bool { return report.Recent[a].At > report.Recent[b].At })
+	return report
+}
+
+// startTxCostCollector 定期查询已发送交易的成本
+func startTxCostCollector() {
+	cfg := currentConfig().TxCost
+	if !cfg.Enabled || isDemo() || isDryRun() {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🕐 启动交易成本记录（每%v查询）\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止交易成本记录\n")
+			return
+		case <-ticker.C:
+			pollTxCosts()
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TxTrackerConfig 跟踪脚本发出的交易直到确认：区块哈希过期而丢弃的交易按目标重新执行或告警，链上失败的交易告警
+type TxTrackerConfig struct {
+	Enabled         bool     `json:"enabled"`
+	IntervalSeconds int      `json:"intervalSeconds"` // 查询确认状态的间隔（RPC 地址同 walletWatch.rpcUrl）
+	Commitment      string   `json:"commitment"`      // 视为最终成功的确认级别: confirmed 或 finalized
+	ExpireSeconds   int      `json:"expireSeconds"`   // 发送后超过该时间仍查不到，视为区块哈希过期、交易已丢弃
+	ResubmitTargets []string `json:"resubmitTargets"` // 交易丢弃后可重新执行的目标（重新执行整个操作，需可重复执行）
+	MaxResubmits    int      `json:"maxResubmits"`    // 同一池 / 代币的同一操作连续重新执行的上限，之后只告警
+}
+
+// 交易状态
+const (
+	txStatusPending     = "pending"
+	txStatusConfirmed   = "confirmed"
+	txStatusFailed      = "failed"      // 已上链但执行失败
+	txStatusExpired     = "expired"     // 区块哈希过期，交易未上链
+	txStatusResubmitted = "resubmitted" // 已过期并重新执行了对应操作
+)
+
+// TrackedTx 一笔被跟踪的交易（data/state/tx_tracker.json）
+type TrackedTx struct {
+	Signature  string `json:"signature"`
+	Target     string `json:"target"`
+	Action     string `json:"action,omitempty"` // 脚本 signature 事件中的 action
+	Pool       string `json:"poolAddress,omitempty"`
+	Token      string `json:"ca,omitempty"`
+	Wallet     string `json:"wallet,omitempty"`
+	OutputMint string `json:"outputMint,omitempty"`
+	SentAt     string `json:"sentAt"`
+	// 脚本使用缓存区块哈希时的有效高度上限，超过后判定过期（否则按 expireSeconds）
+	LastValidBlockHeight uint64 `json:"lastValidBlockHeight,omitempty"`
+	Status               string `json:"status"`
+	Slot                 uint64 `json:"slot,omitempty"`
+	Error                string `json:"error,omitempty"`
+	CheckedAt            string `json:"checkedAt,omitempty"`
+	FinalizedAt          string `json:"finalizedAt,omitempty"` // 状态确定的时间
+}
+
+// txTrackerState 交易记录与各操作的连续重新执行次数
+type txTrackerState struct {
+	Txs       map[string]*TrackedTx `json:"txs"`
+	Resubmits map[string]int        `json:"resubmits"` // 目标:池/代币 -> 连续重新执行次数，确认成功后清零
+}
+
+// 已确定状态的交易保留时间
+const txRetention = 7 * 24 * time.Hour
+
+// getSignatureStatuses 单次最多查询的签名数
+const maxSignatureStatuses = 256
+
+var txTrackerMutex sync.Mutex
+
+type signatureStatus struct {
+	Slot               uint64          `json:"slot"`
+	Err                json.RawMessage `json:"err"`
+	ConfirmationStatus string          `json:"confirmationStatus"`
+}
+
+func (c TxTrackerConfig) validate(watch WalletWatchConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if watch.RPCURL == "" {
+		return fmt.Errorf("txTracker 需要 walletWatch.rpcUrl")
+	}
+	if c.IntervalSeconds <= 0 || c.ExpireSeconds <= 0 {
+		return fmt.Errorf("txTracker.intervalSeconds、expireSeconds 必须大于0")
+	}
+	if c.Commitment != "confirmed" && c.Commitment != "finalized" {
+		return fmt.Errorf("txTracker.commitment 仅支持 confirmed 或 finalized")
+	}
+	if c.MaxResubmits < 0 {
+		return fmt.Errorf("txTracker.maxResubmits 不能为负数")
+	}
+	return nil
+}
+
+func loadTxTracker() txTrackerState {
+	var st txTrackerState
+	if err := loadStateFile("tx_tracker", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if st.Txs == nil {
+		st.Txs = map[string]*TrackedTx{}
+	}
+	if st.Resubmits == nil {
+		st.Resubmits = map[string]int{}
+	}
+	return st
+}
+
+// 操作的标识：目标 + 池（领取等）或代币（兑换）
+func (t *TrackedTx) opKey() string {
+	if t.Pool != "" {
+		return t.Target + ":" + t.Pool
+	}
+	return t.Target + ":" + t.Token
+}
+
+// 命令参数中 flag 之后的值（jupSwap 的 "-input <ca>" 形式）
+func flagValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// trackTransactions 记录外部命令输出中的交易签名（演示模式的模拟签名不跟踪）
+func trackTransactions(ctx context.Context, target string, args []string, output []byte) {
+	if !currentConfig().TxTracker.Enabled || isDemo() {
+		return
+	}
+	o := decodeScriptOutput(output)
+	actions := map[string]string{}
+	for _, ev := range o.eventsOf(scriptEventSignature) {
+		actions[ev.Signature] = ev.Action
+	}
+	sigs := o.Signatures()
+	if len(sigs) == 0 {
+		return
+	}
+	wallet, _ := ctx.Value(walletCtxKey{}).(string)
+	now := time.Now().Format(time.RFC3339)
+
+	txTrackerMutex.Lock()
+	defer txTrackerMutex.Unlock()
+	st := loadTxTracker()
+	for _, sig := range sigs {
+		if _, ok := st.Txs[sig]; ok {
+			continue
+		}
+		st.Txs[sig] = &TrackedTx{
+			Signature: sig, Target: target, Action: actions[sig], Pool: argValue(args, "--pool"),
+			Token: flagValue(args, "-input"), OutputMint: flagValue(args, "-output"), Wallet: wallet,
+			SentAt: now, Status: txStatusPending, LastValidBlockHeight: argLastValidBlockHeight(args),
+		}
+	}
+	if err := saveStateFile("tx_tracker", st); err != nil {
+		logOutput("❌ 保存交易跟踪记录失败: %v\n", err)
+	}
+}
+
+// pollTransactions 查询待确认交易的状态，处理过期与失败的交易
+func pollTransactions() {
+	cfg := currentConfig().TxTracker
+	txTrackerMutex.Lock()
+	st := loadTxTracker()
+	var pending []string
+	needHeight := false
+	for sig, t := range st.Txs {
+		if t.Status == txStatusPending {
+			pending = append(pending, sig)
+			needHeight = needHeight || t.LastValidBlockHeight > 0
+		}
+	}
+	txTrackerMutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	sort.Strings(pending)
+
+	statuses := map[string]*signatureStatus{}
+	for start := 0; start < len(pending); start += maxSignatureStatuses {
+		end := start + maxSignatureStatuses
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+		var result struct {
+			Value []*signatureStatus `json:"value"`
+		}
+		ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+		err := solanaRPC(ctx, "getSignatureStatuses", []interface{}{batch, map[string]bool{"searchTransactionHistory": true}}, &result)
+		cancel()
+		if err != nil {
+			logWarn("⚠️ 查询交易确认状态失败", "count", len(batch), "error", err)
+			return
+		}
+		for i, s := range result.Value {
+			if i < len(batch) {
+				statuses[batch[i]] = s
+			}
+		}
+	}
+
+	// 带区块哈希有效高度的交易按当前区块高度判定过期
+	var blockHeight uint64
+	if needHeight {
+		ctx, cancel := context.WithTimeout(globalCtx, 10*time.Second)
+		if err := solanaRPC(ctx, "getBlockHeight", []interface{}{map[string]string{"commitment": "confirmed"}}, &blockHeight); err != nil {
+			logWarn("⚠️ 查询区块高度失败，本轮按 expireSeconds 判定过期", "error", err)
+		}
+		cancel()
+	}
+
+	now := time.Now()
+	expireAfter := time.Duration(cfg.ExpireSeconds) * time.Second
+	var settled []TrackedTx
+	txTrackerMutex.Lock()
+	st = loadTxTracker()
+	for _, sig := range pending {
+		t := st.Txs[sig]
+		if t == nil || t.Status != txStatusPending {
+			continue
+		}
+		t.CheckedAt = now.Format(time.RFC3339)
+		s := statuses[sig]
+		switch {
+		case s != nil && len(s.Err) > 0 && string(s.Err) != "null":
+			t.Status, t.Slot, t.Error = txStatusFailed, s.Slot, string(s.Err)
+		case s != nil && (s.ConfirmationStatus == cfg.Commitment || s.ConfirmationStatus == "finalized"):
+			t.Status, t.Slot = txStatusConfirmed, s.Slot
+			delete(st.Resubmits, t.opKey())
+		case s == nil && t.LastValidBlockHeight > 0 && blockHeight > 0:
+			if blockHeight > t.LastValidBlockHeight {
+				t.Status = txStatusExpired
+			}
+		case s == nil:
+			if sent, err := time.Parse(time.RFC3339, t.SentAt); err == nil && now.Sub(sent) > expireAfter {
+				t.Status = txStatusExpired
+			}
+		}
+		if t.Status != txStatusPending {
+			t.FinalizedAt = t.CheckedAt
+			settled = append(settled, *t)
+		}
+	}
+
+	// 同一操作的多笔交易只重新执行一次
+	resubmit := map[string]TrackedTx{}
+	for i, t := range settled {
+		if t.Status != txStatusExpired || !containsTarget(cfg.ResubmitTargets, t.Target) {
+			continue
+		}
+		key := t.opKey()
+		if _, ok := resubmit[key]; !ok {
+			if st.Resubmits[key] >= cfg.MaxResubmits {
+				continue
+			}
+			resubmit[key] = t
+			st.Resubmits[key]++
+		}
+		st.Txs[t.Signature].Status = txStatusResubmitted
+		settled[i].Status = txStatusResubmitted
+	}
+
+	for sig, t := range st.Txs {
+		if t.Status == txStatusPending || t.FinalizedAt == "" {
+			continue
+		}
+		if at, err := time.Parse(time.RFC3339, t.FinalizedAt); err == nil && now.Sub(at) > txRetention {
+			delete(st.Txs, sig)
+		}
+	}
+	if err := saveStateFile("tx_tracker", st); err != nil {
+		logOutput("❌ 保存交易跟踪记录失败: %v\n", err)
+	}
+	txTrackerMutex.Unlock()
+
+	for _, t := range settled {
+		reportSettledTx(t)
+	}
+	for _, t := range resubmit {
+		resubmitOperation(t)
+	}
+}
+
+func containsTarget(targets []string, target string) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录日志并对失败 / 丢弃的交易告警
+func reportSettledTx(t TrackedTx) {
+	metricTxFinal.Inc(t.Target, t.Status)
+	fields := map[string]string{"signature": t.Signature, "target": t.Target, "status": t.Status}
+	for k, v := range map[string]string{"action": t.Action, "pool": t.Pool, "ca": t.Token, "wallet": t.Wallet} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	switch t.Status {
+	case txStatusConfirmed:
+		logDebug("✅ 交易已确认", "signature", t.Signature, "target", t.Target, "slot", t.Slot)
+	case txStatusFailed:
+		fields["error"] = t.Error
+		logError("❌ 交易上链后执行失败", "signature", t.Signature, "target", t.Target, "pool", t.Pool, "error", t.Error)
+		notifyKeyed(eventTxFailed, levelCritical, t.Signature, "交易执行失败", fmt.Sprintf("%s 的交易已上链但执行失败，请核对仓位状态", t.Target), fields)
+	case txStatusExpired:
+		logError("❌ 交易未确认且区块哈希已过期", "signature", t.Signature, "target", t.Target, "pool", t.Pool)
+		notifyKeyed(eventTxFailed, levelCritical, t.Signature, "交易已丢弃", fmt.Sprintf("%s 的交易未上链，脚本已按成功返回，请核对仓位状态", t.Target), fields)
+	case txStatusResubmitted:
+		logWarn("🔁 交易已丢弃，重新执行操作", "signature", t.Signature, "target", t.Target, "pool", t.Pool, "ca", t.Token)
+	}
+}
+
+// 重新执行交易被丢弃的操作（走原有入口，领取汇总、盈亏与兑换记录照常更新）
+func resubmitOperation(t TrackedTx) {
+	switch t.Target {
+	case "claimAllRewards":
+		if t.Pool == "" {
+			return
+		}
+		enqueueClaim(t.Pool)
+	case "jupSwap":
+		if t.Token == "" {
+			return
+		}
+		enqueueSwap(t.Wallet, t.Token, t.OutputMint)
+	default:
+		logWarn("⚠️ 目标不支持重新执行，仅告警", "target", t.Target, "signature", t.Signature)
+	}
+}
+
+// 交易记录（按发送时间倒序），status 为空时返回全部
+func listTrackedTx(status string) []TrackedTx {
+	txTrackerMutex.Lock()
+	st := loadTxTracker()
+	txTrackerMutex.Unlock()
+	result := []TrackedTx{}
+	for _, t := range st.Txs {
+		if status == "" || t.Status == status {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].SentAt > result[b].SentAt })
+	return result
+}
+
+// startTxTracker 定期查询待确认交易
+func startTxTracker() {
+	cfg := currentConfig().TxTracker
+	if !cfg.Enabled || isDemo() || isDryRun() {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🕐 启动交易确认跟踪（每%v查询，%ds 未上链视为丢弃）\n", interval, cfg.ExpireSeconds)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止交易确认跟踪\n")
+			return
+		case <-ticker.C:
+			pollTransactions()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VolatilityRangeConfig 开仓时按近期价格波动率自动计算 bin 范围的下跌幅度
+type VolatilityRangeConfig struct {
+	Enabled         bool    `json:"enabled"`
+	LookbackMinutes int     `json:"lookbackMinutes"` // 统计波动率的历史窗口
+	MinSamples      int     `json:"minSamples"`      // 样本不足时回退为默认宽度（-60%）
+	HorizonMinutes  int     `json:"horizonMinutes"`  // 折算波动率的持仓周期（默认与信号起强制平仓的 300 分钟一致）
+	Multiplier      float64 `json:"multiplier"`      // 下跌幅度 = 1 - exp(-multiplier × 周期波动率)
+	MinRangePct     float64 `json:"minRangePct"`     // 下跌幅度下限（%）
+	MaxRangePct     float64 `json:"maxRangePct"`     // 下跌幅度上限（%）
+}
+
+func (c VolatilityRangeConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.LookbackMinutes <= 0 || c.HorizonMinutes <= 0 {
+		return fmt.Errorf("volatilityRange.lookbackMinutes 与 horizonMinutes 必须大于0")
+	}
+	if c.MinSamples < 3 {
+		return fmt.Errorf("volatilityRange.minSamples 至少为3")
+	}
+	if c.Multiplier <= 0 {
+		return fmt.Errorf("volatilityRange.multiplier 必须大于0")
+	}
+	if c.MinRangePct <= 0 || c.MaxRangePct >= 100 || c.MinRangePct > c.MaxRangePct {
+		return fmt.Errorf("volatilityRange 需满足 0 < minRangePct ≤ maxRangePct < 100")
+	}
+	return nil
+}
+
+// 对数收益率的标准差（每个采样间隔）与平均采样间隔
+func logReturnVolatility(samples []PriceSample) (sigma float64, interval time.Duration, n int) {
+	var prices []float64
+	var first, last time.Time
+	for _, s := range samples {
+		p, err := strconv.ParseFloat(strings.TrimSpace(s.Price), 64)
+		t, terr := time.Parse(time.RFC3339, s.Time)
+		if err != nil || terr != nil || p <= 0 {
+			continue
+		}
+		if first.IsZero() {
+			first = t
+		}
+		last = t
+		prices = append(prices, p)
+	}
+	if len(prices) < 3 {
+		return 0, 0, len(prices)
+	}
+	returns := make([]float64, 0, len(prices)-1)
+	var mean float64
+	for i := 1; i < len(prices); i++ {
+		r := math.Log(prices[i] / prices[i-1])
+		returns = append(returns, r)
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+	return math.Sqrt(variance), last.Sub(first) / time.Duration(len(returns)), len(prices)
+}
+
+// volatilityRangePct 根据代币近期波动率计算范围下跌幅度（%）；未启用或样本不足时返回 0（使用默认宽度）
+func volatilityRangePct(tokenAddress string) float64 {
+	cfg := currentConfig().VolatilityRange
+	if !cfg.Enabled || tokenAddress == "" {
+		return 0
+	}
+	samples := loadPriceHistory(tokenAddress, time.Now().Add(-time.Duration(cfg.LookbackMinutes)*time.Minute))
+	sigma, interval, n := logReturnVolatility(samples)
+	if n < cfg.MinSamples || interval <= 0 {
+		logOutput("📐 价格样本不足（%d/%d），使用默认 bin 宽度: ca=%s\n", n, cfg.MinSamples, tokenAddress)
+		return 0
+	}
+	horizonSigma := sigma * math.Sqrt(float64(time.Duration(cfg.HorizonMinutes)*time.Minute)/float64(interval))
+	pct := (1 - math.Exp(-cfg.Multiplier*horizonSigma)) * 100
+	pct = math.Max(cfg.MinRangePct, math.Min(cfg.MaxRangePct, pct))
+	logInfo("📐 按波动率计算 bin 范围", "token", tokenAddress, "samples", n, "sigma", fmt.Sprintf("%.5f", sigma),
+		"horizonSigma", fmt.Sprintf("%.4f", horizonSigma), "rangePct", fmt.Sprintf("%.2f", pct))
+	return pct
+}
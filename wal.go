@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// 外部命令的预写日志（data/state/wal.jsonl）：每个非只读命令执行前追加一条 intent 并落盘，成功后追加 commit（含交易签名），
+// 失败后追加 fail。启动时没有 commit / fail 的 intent 即上次运行中断的命令（关闭期间被终止或进程崩溃），逐条核对后追加 reconcile：
+//   - shutdown.resumeTargets 中的目标（领取、兑换）按池 / 代币重新执行
+//   - 开仓：池文件中的仓位在链上存在时视为已完成，否则记为失败
+//   - 平仓：记录的仓位在链上已关闭时视为已完成，否则记为失败
+//   - 其余目标与无法核对时记为 manual；失败与 manual 发送 job_interrupted 告警等待人工核对
+
+// 日志记录类型
+const (
+	walIntent    = "intent"
+	walCommit    = "commit"
+	walFail      = "fail"
+	walReconcile = "reconcile"
+)
+
+// 中断命令的核对结果（reconcile 记录的 resolution）
+const (
+	walResolvedRerun     = "rerun"     // 已重新执行
+	walResolvedConfirmed = "confirmed" // 链上状态表明已完成
+	walResolvedFailed    = "failed"    // 链上状态表明未完成
+	walResolvedManual    = "manual"    // 无法自动核对，需人工处理
+)
+
+// 追加的记录数超过该值时压缩日志（只保留未完成的 intent）
+const walCompactRecords = 1000
+
+// WALRecord 预写日志中的一条记录
+type WALRecord struct {
+	ID         string      `json:"id"`
+	Op         string      `json:"op"`
+	Time       string      `json:"time"`
+	Job        *PendingJob `json:"job,omitempty"` // intent
+	Error      string      `json:"error,omitempty"`
+	Signatures []string    `json:"signatures,omitempty"` // commit：命令输出的交易签名
+	Resolution string      `json:"resolution,omitempty"` // reconcile
+	Detail     string      `json:"detail,omitempty"`     // reconcile：核对依据
+}
+
+var (
+	walAppended   int         // 上次压缩后追加的记录数
+	walReconciled []WALRecord // 本次启动核对的中断命令（GET /wal）
+)
+
+func walPath() string { return filepath.Join(currentStateDir(), "wal.jsonl") }
+
+// 预写日志无法落盘时拒绝执行非只读命令
+var errWALUnavailable = errors.New("预写日志不可用，拒绝执行")
+
+// 调用方需持有 jobMutex；追加一条记录并落盘，写入或落盘失败时返回错误
+func appendWALLocked(rec WALRecord) error {
+	rec.Time = time.Now().Format(time.RFC3339)
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(currentStateDir(), 0755); err != nil {
+		return fmt.Errorf("写入预写日志失败: %v", err)
+	}
+	f, err := os.OpenFile(walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("写入预写日志失败: %v", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("写入预写日志失败: %v", err)
+	}
+	if walAppended++; walAppended >= walCompactRecords {
+		compactWALLocked()
+	}
+	return nil
+}
+
+// 调用方需持有 jobMutex；commit / fail / reconcile 记录写入失败只记录日志（命令已执行完毕，下次启动按 intent 核对）
+func appendWALRecordLocked(rec WALRecord) {
+	if err := appendWALLocked(rec); err != nil {
+		logOutput("❌ %v\n", err)
+	}
+}
+
+// 调用方需持有 jobMutex；重写日志，只保留执行中与中断（尚未核对）的命令
+func compactWALLocked() {
+	var buf bytes.Buffer
+	for _, jobs := range []map[string]PendingJob{interruptedJobs, activeJobs} {
+		for id, job := range jobs {
+			line, _ := json.Marshal(WALRecord{ID: id, Op: walIntent, Time: job.StartedAt, Job: &job})
+			buf.Write(append(line, '\n'))
+		}
+	}
+	if err := writeFileAtomic(walPath(), buf.Bytes(), 0644); err != nil {
+		logOutput("❌ 压缩预写日志失败: %v\n", err)
+		return
+	}
+	walAppended = 0
+}
+
+// beginJob 登记一个非只读的外部命令：执行前写入 intent 并落盘；落盘失败时返回错误，调用方不得执行命令
+func beginJob(ctx context.Context, target string, args []string) (string, error) {
+	if readOnlyTargets[target] {
+		return "", nil
+	}
+	wallet, _ := ctx.Value(walletCtxKey{}).(string)
+	job := PendingJob{
+		ID:     strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(jobSeq.Add(1), 10),
+		Target: target, Args: args, Pool: argValue(args, "--pool"),
+		Token: flagValue(args, "-input"), OutputMint: flagValue(args, "-output"), Wallet: wallet,
+		StartedAt: time.Now().Format(time.RFC3339),
+	}
+	// 平仓前记录仓位地址：平仓完成后池文件可能已归档，核对时按该地址查询链上
+	if job.Pool != "" && (target == scriptRemoveLiquidity || target == scriptRemoveLiquidityPartial) {
+		job.Position = readPositionFromPoolJSON(job.Pool)
+	}
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	if err := appendWALLocked(WALRecord{ID: job.ID, Op: walIntent, Job: &job}); err != nil {
+		return "", fmt.Errorf("%w: %v", errWALUnavailable, err)
+	}
+	activeJobs[job.ID] = job
+	return job.ID, nil
+}
+
+// finishJob 命令结束：成功写入 commit，失败写入 fail；关闭期间失败或被终止的不写入，下次启动时核对
+func finishJob(id string, out []byte, err error) {
+	if id == "" {
+		return
+	}
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	job := activeJobs[id]
+	delete(activeJobs, id)
+	switch {
+	case err == nil:
+		appendWALRecordLocked(WALRecord{ID: id, Op: walCommit, Signatures: decodeScriptOutput(out).Signatures()})
+	case shuttingDown():
+		interruptedJobs[id] = job
+		logWarn("⏹️ 外部命令在关闭期间中断，下次启动时核对", "target", job.Target, "pool", job.Pool, "ca", job.Token, "error", err)
+	default:
+		appendWALRecordLocked(WALRecord{ID: id, Op: walFail, Error: err.Error()})
+	}
+}
+
+// loadJobJournal 启动时读取预写日志，没有结束记录的 intent 即上次运行中断的命令（在执行任何外部命令之前）
+func loadJobJournal() {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	interruptedJobs = map[string]PendingJob{}
+	walAppended = 0
+	if f, err := os.Open(walPath()); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var rec WALRecord
+			// 崩溃时写了一半的末行无法解析，忽略
+			if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.ID == "" {
+				continue
+			}
+			walAppended++
+			if rec.Op == walIntent && rec.Job != nil {
+				interruptedJobs[rec.ID] = *rec.Job
+			} else {
+				delete(interruptedJobs, rec.ID)
+			}
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		logOutput("⚠️ 读取预写日志失败: %v\n", err)
+	}
+	// 旧版本的待完成记录（data/state/pending_jobs.json）并入日志
+	var legacy map[string]PendingJob
+	if err := loadStateFile("pending_jobs", &legacy); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	for id, job := range legacy {
+		interruptedJobs[id] = job
+	}
+	if legacy != nil {
+		compactWALLocked()
+		os.Remove(filepath.Join(currentStateDir(), "pending_jobs.json"))
+	}
+	if len(interruptedJobs) > 0 {
+		logOutput("📋 上次运行有 %d 个命令未完成，启动后核对\n", len(interruptedJobs))
+	}
+}
+
+// reconcileWAL 核对上次运行中断的命令，结果追加到日志
+func reconcileWAL() {
+	jobMutex.Lock()
+	journal := make(map[string]PendingJob, len(interruptedJobs))
+	for id, job := range interruptedJobs {
+		journal[id] = job
+	}
+	jobMutex.Unlock()
+
+	// 同一操作只重新执行一次
+	seen := map[string]bool{}
+	var results []WALRecord
+	for id, job := range journal {
+		resolution, detail := reconcileJob(job, seen)
+		metricWALReconciled.Inc(resolution)
+		jobMutex.Lock()
+		delete(interruptedJobs, id)
+		appendWALRecordLocked(WALRecord{ID: id, Op: walReconcile, Resolution: resolution, Detail: detail})
+		jobMutex.Unlock()
+		results = append(results, WALRecord{ID: id, Op: walReconcile, Job: &job, Resolution: resolution, Detail: detail})
+		if resolution == walResolvedFailed || resolution == walResolvedManual {
+			logWarn("⚠️ 上次运行中断的命令未完成，请人工核对", "target", job.Target, "pool", job.Pool, "ca", job.Token, "since", job.StartedAt, "detail", detail)
+			notifyKeyed(eventJobInterrupted, levelWarning, id, "命令在上次运行中中断",
+				fmt.Sprintf("%s 在上次运行中未完成：%s，请核对仓位状态", job.Target, detail),
+				map[string]string{"target": job.Target, "pool": job.Pool, "ca": job.Token, "startedAt": job.StartedAt, "resolution": resolution})
+		} else {
+			logInfo("📋 已核对上次运行中断的命令", "target", job.Target, "pool", job.Pool, "ca", job.Token, "resolution", resolution, "detail", detail)
+		}
+	}
+	jobMutex.Lock()
+	walReconciled = results
+	if len(journal) > 0 {
+		compactWALLocked()
+	}
+	jobMutex.Unlock()
+}
+
+// reconcileJob 按目标核对一个中断的命令，返回核对结果与说明
+func reconcileJob(job PendingJob, seen map[string]bool) (string, string) {
+	if containsTarget(currentConfig().Shutdown.ResumeTargets, job.Target) {
+		t := TrackedTx{Target: job.Target, Pool: job.Pool, Token: job.Token, OutputMint: job.OutputMint, Wallet: job.Wallet}
+		if key := t.opKey(); !seen[key] {
+			seen[key] = true
+			logOutput("🔁 重新执行上次运行中断的命令: %s %s%s\n", job.Target, job.Pool, job.Token)
+			resubmitOperation(t)
+		}
+		return walResolvedRerun, "已重新执行"
+	}
+	if isDemo() {
+		return walResolvedManual, "演示模式不核对链上状态"
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 15*time.Second)
+	defer cancel()
+	switch job.Target {
+	case scriptAddLiquidity:
+		position := readPositionFromPoolJSON(job.Pool)
+		if position == "" {
+			return walResolvedFailed, "池文件中没有仓位地址"
+		}
+		exists, err := accountExists(ctx, position)
+		if err != nil {
+			return walResolvedManual, "查询仓位失败: " + err.Error()
+		}
+		if exists {
+			return walResolvedConfirmed, "仓位在链上存在: " + position
+		}
+		return walResolvedFailed, "仓位在链上不存在: " + position
+	case scriptRemoveLiquidity:
+		if job.Position == "" {
+			return walResolvedManual, "没有记录仓位地址"
+		}
+		exists, err := accountExists(ctx, job.Position)
+		if err != nil {
+			return walResolvedManual, "查询仓位失败: " + err.Error()
+		}
+		if !exists {
+			return walResolvedConfirmed, "仓位已在链上关闭: " + job.Position
+		}
+		return walResolvedFailed, "仓位仍在链上: " + job.Position
+	}
+	return walResolvedManual, "该目标不支持自动核对"
+}
+
+// accountExists 账户在链上是否存在
+func accountExists(ctx context.Context, address string) (bool, error) {
+	data, _, err := accountInfo(ctx, address)
+	return data != nil, err
+}
+
+// WALStatus 预写日志的当前状态（GET /wal）
+type WALStatus struct {
+	Pending    []PendingJob `json:"pending"`    // 执行中（已写 intent 未结束）
+	Reconciled []WALRecord  `json:"reconciled"` // 本次启动核对的上次中断命令
+}
+
+func walStatus() WALStatus {
+	jobs := listActiveJobs()
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	return WALStatus{Pending: jobs, Reconciled: append([]WALRecord{}, walReconciled...)}
+}
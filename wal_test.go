@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 数据目录替换为临时目录（或无法创建状态目录的普通文件），测试结束后恢复
+func withDataDir(t *testing.T, dir string) {
+	t.Helper()
+	prev := appDataDir
+	appDataDir = dir
+	t.Cleanup(func() { appDataDir = prev })
+}
+
+func TestBeginJobWritesIntent(t *testing.T) {
+	withDataDir(t, t.TempDir())
+	id, err := beginJob(context.Background(), scriptAddLiquidity, []string{"--pool=P1"})
+	if err != nil || id == "" {
+		t.Fatalf("beginJob: id=%q err=%v", id, err)
+	}
+	content, err := os.ReadFile(walPath())
+	if err != nil || !strings.Contains(string(content), `"op":"intent"`) || !strings.Contains(string(content), id) {
+		t.Fatalf("预写日志中没有 intent: %s (%v)", content, err)
+	}
+	finishJob(id, nil, nil)
+	if content, _ := os.ReadFile(walPath()); !strings.Contains(string(content), `"op":"commit"`) {
+		t.Errorf("成功后没有 commit: %s", content)
+	}
+}
+
+func TestBeginJobRefusesWithoutDurableIntent(t *testing.T) {
+	// 数据目录是普通文件：状态目录无法创建，intent 不能落盘
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	withDataDir(t, file)
+
+	id, err := beginJob(context.Background(), scriptRemoveLiquidity, []string{"--pool=P2"})
+	if !errors.Is(err, errWALUnavailable) {
+		t.Fatalf("应返回 errWALUnavailable，实际 id=%q err=%v", id, err)
+	}
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	for _, job := range activeJobs {
+		if job.Pool == "P2" {
+			t.Errorf("intent 未落盘的命令不应登记为执行中: %+v", job)
+		}
+	}
+}
+
+func TestRunExternalRefusesWithoutDurableIntent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	withDataDir(t, file)
+	// 集成测试结束时已取消全局上下文，这里恢复为未关闭状态，确保拒绝来自预写日志
+	prevCtx := globalCtx
+	globalCtx = context.Background()
+	t.Cleanup(func() { globalCtx = prevCtx })
+	// 非只读目标在执行命令前被拒绝（命令本身不存在，若被执行会返回其他错误）
+	if _, err := runExternal(context.Background(), scriptJupSwap, "-input", "T"); !errors.Is(err, errWALUnavailable) {
+		t.Fatalf("应在执行前拒绝，实际 err=%v", err)
+	}
+	// 只读目标不写预写日志，照常执行
+	if _, err := beginJob(context.Background(), scriptFetchPrice, nil); err != nil {
+		t.Errorf("只读目标不应写入预写日志: %v", err)
+	}
+}
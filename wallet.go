@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// WalletWatchConfig 监控签名钱包的交易记录，发现非本程序发起的交易时告警
+type WalletWatchConfig struct {
+	Enabled         bool     `json:"enabled"`
+	RPCURL          string   `json:"rpcUrl"`
+	Address         string   `json:"address"`         // 为空时读取环境变量 USER_WALLET_ADDRESS
+	IntervalSeconds int      `json:"intervalSeconds"` // 轮询间隔
+	GraceSeconds    int      `json:"graceSeconds"`    // 交易上链后等待多久再判定（等待脚本输出签名）
+	WindowTargets   []string `json:"windowTargets"`   // 输出中不含签名的目标，按执行时间窗口归属（默认 jupSwap）
+}
+
+// WalletTx 钱包交易记录
+type WalletTx struct {
+	Signature  string `json:"signature"`
+	Slot       uint64 `json:"slot"`
+	BlockTime  string `json:"blockTime,omitempty"`
+	Failed     bool   `json:"failed,omitempty"`
+	Memo       string `json:"memo,omitempty"`
+	DetectedAt string `json:"detectedAt"`
+}
+
+// walletWatchState 轮询游标与最近的外部交易（data/state/wallet_watch.json）
+type walletWatchState struct {
+	Cursor   string     `json:"cursor"` // 最近一条已判定的签名
+	External []WalletTx `json:"external"`
+}
+
+// 保留的外部交易条数
+const maxExternalTx = 100
+
+// 交易签名（base58，64 字节）
+var signaturePattern = regexp.MustCompile(`[1-9A-HJ-NP-Za-km-z]{86,88}`)
+
+var (
+	botActivityMutex sync.Mutex
+	botSignatures    = map[string]time.Time{} // 本程序命令输出中出现过的签名
+	botWindows       []activityWindow         // 按时间窗口归属的命令执行区间
+	activeRuns       = map[int64]time.Time{}  // 正在执行的外部命令
+	activeRunSeq     int64
+	walletWatchMutex sync.Mutex
+	walletRPCHTTP    = &http.Client{Timeout: 20 * time.Second}
+)
+
+type activityWindow struct{ start, end time.Time }
+
+func (c WalletWatchConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RPCURL == "" {
+		return fmt.Errorf("walletWatch.rpcUrl 不能为空")
+	}
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("walletWatch.intervalSeconds 必须大于0")
+	}
+	if c.GraceSeconds < 0 {
+		return fmt.Errorf("walletWatch.graceSeconds 不能为负数")
+	}
+	return nil
+}
+
+// 被监控的钱包地址
+func walletAddress() string {
+	if currentConfig().WalletWatch.Address != "" {
+		return currentConfig().WalletWatch.Address
+	}
+	if addr := os.Getenv("USER_WALLET_ADDRESS"); addr != "" {
+		return addr
+	}
+	return defaultKeyAddress()
+}
+
+// 外部命令开始执行时登记，返回的函数在结束时调用
+func beginBotActivity() func() {
+	botActivityMutex.Lock()
+	activeRunSeq++
+	id := activeRunSeq
+	activeRuns[id] = time.Now()
+	botActivityMutex.Unlock()
+	return func() {
+		botActivityMutex.Lock()
+		delete(activeRuns, id)
+		botActivityMutex.Unlock()
+	}
+}
+
+// 记录外部命令输出中的交易签名；windowTargets 中的目标同时记录执行区间
+func noteBotActivity(target string, start time.Time, output []byte) {
+	now := time.Now()
+	botActivityMutex.Lock()
+	defer botActivityMutex.Unlock()
+	for _, sig := range decodeScriptOutput(output).Signatures() {
+		botSignatures[sig] = now
+	}
+	for _, t := range currentConfig().WalletWatch.WindowTargets {
+		if t == target {
+			botWindows = append(botWindows, activityWindow{start: start, end: now})
+			break
+		}
+	}
+	// 清理一天前的记录
+	cutoff := now.Add(-24 * time.Hour)
+	for sig, at := range botSignatures {
+		if at.Before(cutoff) {
+			delete(botSignatures, sig)
+		}
+	}
+	kept := botWindows[:0]
+	for _, w := range botWindows {
+		if w.end.After(cutoff) {
+			kept = append(kept, w)
+		}
+	}
+	botWindows = kept
+}
+
+// 交易是否由本程序发起
+func isBotTransaction(sig string, blockTime time.Time) bool {
+	grace := time.Duration(currentConfig().WalletWatch.GraceSeconds) * time.Second
+	botActivityMutex.Lock()
+	defer botActivityMutex.Unlock()
+	if _, ok := botSignatures[sig]; ok {
+		return true
+	}
+	if blockTime.IsZero() {
+		return false
+	}
+	for _, w := range botWindows {
+		if !blockTime.Before(w.start.Add(-grace)) && !blockTime.After(w.end.Add(grace)) {
+			return true
+		}
+	}
+	return false
+}
+
+// since 之后本程序是否执行过（或正在执行）外部命令
+func botActiveSince(since time.Time) bool {
+	botActivityMutex.Lock()
+	defer botActivityMutex.Unlock()
+	if len(activeRuns) > 0 {
+		return true
+	}
+	for _, at := range botSignatures {
+		if at.After(since) {
+			return true
+		}
+	}
+	for _, w := range botWindows {
+		if w.end.After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// 判定截止时间：晚于该时间的交易可能仍在等待脚本输出签名，留到下一轮
+func classifyCutoff() time.Time {
+	grace := time.Duration(currentConfig().WalletWatch.GraceSeconds) * time.Second
+	cutoff := time.Now().Add(-grace)
+	botActivityMutex.Lock()
+	defer botActivityMutex.Unlock()
+	for _, start := range activeRuns {
+		if s := start.Add(-grace); s.Before(cutoff) {
+			cutoff = s
+		}
+	}
+	return cutoff
+}
+
+// 调用 Solana JSON-RPC
+// 经由 RPC 节点池发送：节点不可用时依次切换到其他节点重试
+func solanaRPC(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	tried := map[string]bool{}
+	var lastErr error
+	for {
+		e, err := acquireRPCEndpoint(ctx, tried)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+		tried[e.URL] = true
+		start := time.Now()
+		err = solanaRPCAt(ctx, e.URL, "walletRPC", method, params, out)
+		// 调用方超时或取消不计入节点健康
+		if ctx.Err() != nil {
+			return err
+		}
+		recordRPCResult(e, err, time.Since(start), false)
+		if err == nil || !errors.Is(err, errRPCUnavailable) {
+			return err
+		}
+		lastErr = err
+	}
+}
+
+// solanaRPCAt 向指定节点发送 JSON-RPC 请求；source 非空时错误计入 RPC 限流信号
+func solanaRPCAt(ctx context.Context, url, source, method string, params []interface{}, out interface{}) error {
+	if err := chaosRPC(ctx, url, method); err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := walletRPCHTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRPCUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("RPC %s HTTP %d", method, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			err = fmt.Errorf("%w: %v", errRPCUnavailable, err)
+		}
+		if source != "" {
+			noteRPCOutput(source, err.Error())
+		}
+		return err
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("解析 RPC %s 响应失败: %v", method, err)
+	}
+	if envelope.Error != nil {
+		err := fmt.Errorf("RPC %s 错误 %d: %s", method, envelope.Error.Code, envelope.Error.Message)
+		// -32005：节点落后 / 不健康
+		if envelope.Error.Code == -32005 {
+			err = fmt.Errorf("%w: %v", errRPCUnavailable, err)
+		}
+		if source != "" {
+			noteRPCOutput(source, err.Error())
+		}
+		return err
+	}
+	return json.Unmarshal(envelope.Result, out)
+}
+
+type signatureInfo struct {
+	Signature string          `json:"signature"`
+	Slot      uint64          `json:"slot"`
+	Err       json.RawMessage `json:"err"`
+	Memo      *string         `json:"memo"`
+	BlockTime *int64          `json:"blockTime"`
+}
+
+// 轮询一次钱包交易：按时间正序判定游标之后的交易
+func pollWalletActivity(address string) {
+	walletWatchMutex.Lock()
+	defer walletWatchMutex.Unlock()
+
+	var st walletWatchState
+	if err := loadStateFile("wallet_watch", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	opts := map[string]interface{}{"limit": 100, "commitment": "confirmed"}
+	if st.Cursor != "" {
+		opts["until"] = st.Cursor
+	}
+	ctx, cancel := context.WithTimeout(globalCtx, 30*time.Second)
+	defer cancel()
+	var sigs []signatureInfo
+	if err := solanaRPC(ctx, "getSignaturesForAddress", []interface{}{address, opts}, &sigs); err != nil {
+		logWarn("⚠️ 查询钱包交易失败", "address", address, "error", err)
+		return
+	}
+	if len(sigs) == 0 {
+		return
+	}
+	if len(sigs) == 100 && st.Cursor != "" {
+		logWarn("⚠️ 两次轮询之间的交易超过100条，更早的交易未判定", "address", address)
+	}
+	// 首次运行只建立基线
+	if st.Cursor == "" {
+		st.Cursor = sigs[0].Signature
+		saveStateFile("wallet_watch", st)
+		logOutput("👛 钱包交易监控已建立基线: %s\n", st.Cursor)
+		return
+	}
+
+	cutoff := classifyCutoff()
+	for i := len(sigs) - 1; i >= 0; i-- {
+		s := sigs[i]
+		var blockTime time.Time
+		if s.BlockTime != nil {
+			blockTime = time.Unix(*s.BlockTime, 0)
+		}
+		if blockTime.IsZero() || blockTime.After(cutoff) {
+			break
+		}
+		st.Cursor = s.Signature
+		if isBotTransaction(s.Signature, blockTime) {
+			metricWalletTx.Inc("bot")
+			continue
+		}
+		metricWalletTx.Inc("external")
+		tx := WalletTx{
+			Signature: s.Signature, Slot: s.Slot, BlockTime: blockTime.Format(time.RFC3339),
+			Failed: len(s.Err) > 0 && string(s.Err) != "null", DetectedAt: time.Now().Format(time.RFC3339),
+		}
+		if s.Memo != nil {
+			tx.Memo = *s.Memo
+		}
+		st.External = append(st.External, tx)
+		logWarn("🚨 检测到非本程序发起的钱包交易", "address", address, "signature", tx.Signature, "blockTime", tx.BlockTime, "failed", tx.Failed)
+		notifyKeyed(eventWalletActivity, levelCritical, tx.Signature, "检测到非本程序发起的钱包交易",
+			"可能是私钥泄露或人工操作，请核对仓位状态", map[string]string{"address": address, "signature": tx.Signature, "blockTime": tx.BlockTime})
+		checkExternalTransaction(address, tx)
+	}
+	if len(st.External) > maxExternalTx {
+		st.External = st.External[len(st.External)-maxExternalTx:]
+	}
+	if err := saveStateFile("wallet_watch", st); err != nil {
+		logOutput("❌ 保存钱包监控状态失败: %v\n", err)
+	}
+}
+
+// 最近的外部交易
+func listExternalWalletTx() []WalletTx {
+	walletWatchMutex.Lock()
+	defer walletWatchMutex.Unlock()
+	var st walletWatchState
+	if err := loadStateFile("wallet_watch", &st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if st.External == nil {
+		return []WalletTx{}
+	}
+	return st.External
+}
+
+// 启动钱包交易监控
+func startWalletWatcher() {
+	cfg := currentConfig().WalletWatch
+	if !cfg.Enabled {
+		return
+	}
+	address := walletAddress()
+	if address == "" {
+		logWarn("⚠️ 未配置钱包地址（walletWatch.address 或 USER_WALLET_ADDRESS），钱包交易监控未启动")
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	logOutput("🕐 启动钱包交易监控（每%v轮询 %s）\n", interval, address)
+
+	pollWalletActivity(address)
+	checkBalanceDrop(address)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-globalCtx.Done():
+			logOutput("🛑 收到关闭信号，停止钱包交易监控\n")
+			return
+		case <-ticker.C:
+			pollWalletActivity(address)
+			checkBalanceDrop(address)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WalletConfig 一个签名钱包。私钥不写入配置文件，只配置保存私钥的环境变量名（进程环境或 .env）
+type WalletConfig struct {
+	Name          string           `json:"name"`
+	Address       string           `json:"address"`       // 公钥，传给脚本的 USER_WALLET_ADDRESS
+	PrivateKeyEnv string           `json:"privateKeyEnv"` // 私钥所在的环境变量，传给脚本的 PRIVATE_KEY
+	Encrypted     bool             `json:"encrypted"`     // 私钥是否为 encrypt_private_key.ts 加密后的密文
+	PasswordEnv   string           `json:"passwordEnv"`   // 加密私钥的密码环境变量（默认 PRIVATE_KEY_PASSWORD）
+	Key           *KeySourceConfig `json:"key"`           // 由本进程读取私钥（加密文件、Vault、AWS 等，见 keys），配置后不使用 privateKeyEnv
+}
+
+// WalletAssignmentConfig 新池分配钱包的方式（已分配的池保持不变）
+type WalletAssignmentConfig struct {
+	Strategy string            `json:"strategy"` // explicit | roundRobin | source
+	Pools    map[string]string `json:"pools"`    // 池地址 -> 钱包名（任何策略下都优先）
+	BySource map[string]string `json:"bySource"` // 信号源 -> 钱包名（source 策略）
+	Default  string            `json:"default"`  // 未匹配时使用的钱包，为空时使用第一个钱包
+}
+
+// 钱包分配策略
+const (
+	walletAssignExplicit   = "explicit"
+	walletAssignRoundRobin = "roundRobin"
+	walletAssignSource     = "source"
+)
+
+// .env 文件（TS 脚本通过 dotenv 读取，Go 侧解析私钥环境变量时同样查找）
+func dotEnvPath() string { return basePath(".env") }
+
+// poolWalletState 池与钱包的对应关系（data/state/pool_wallets.json）
+type poolWalletState struct {
+	Pools map[string]string `json:"pools"`
+	Next  int               `json:"next"` // roundRobin 下一个钱包的序号
+}
+
+var poolWalletMutex sync.Mutex
+
+// 钱包的上下文键：runExternal 据此为子进程设置钱包环境变量
+type walletCtxKey struct{}
+
+func validateWallets(wallets []WalletConfig, a WalletAssignmentConfig) error {
+	if len(wallets) == 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for i, w := range wallets {
+		if w.Name == "" || w.Address == "" || (w.PrivateKeyEnv == "" && w.Key == nil) {
+			return fmt.Errorf("wallets[%d] 的 name、address 不能为空，且需要 privateKeyEnv 或 key", i)
+		}
+		if w.Key != nil {
+			if err := w.Key.validate(fmt.Sprintf("wallets[%d].key", i)); err != nil {
+				return err
+			}
+		}
+		if names[w.Name] {
+			return fmt.Errorf("wallets 中存在重名钱包: %s", w.Name)
+		}
+		names[w.Name] = true
+	}
+	switch a.Strategy {
+	case "", walletAssignExplicit, walletAssignRoundRobin, walletAssignSource:
+	default:
+		return fmt.Errorf("walletAssignment.strategy 只支持 explicit、roundRobin、source: %s", a.Strategy)
+	}
+	if a.Default != "" && !names[a.Default] {
+		return fmt.Errorf("walletAssignment.default 不是已配置的钱包: %s", a.Default)
+	}
+	for pool, name := range a.Pools {
+		if !names[name] {
+			return fmt.Errorf("walletAssignment.pools.%s 不是已配置的钱包: %s", pool, name)
+		}
+	}
+	for source, name := range a.BySource {
+		if !names[name] {
+			return fmt.Errorf("walletAssignment.bySource.%s 不是已配置的钱包: %s", source, name)
+		}
+	}
+	return nil
+}
+
+func findWallet(name string) *WalletConfig {
+	for i := range currentConfig().Wallets {
+		if currentConfig().Wallets[i].Name == name {
+			return &currentConfig().Wallets[i]
+		}
+	}
+	return nil
+}
+
+func loadPoolWallets() *poolWalletState {
+	st := &poolWalletState{}
+	if err := loadStateFile("pool_wallets", st); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	if st.Pools == nil {
+		st.Pools = map[string]string{}
+	}
+	return st
+}
+
+// poolWallet 池已分配的钱包名；未配置多钱包或池在多钱包之前开仓时为空（使用进程环境中的默认钱包）
+func poolWallet(poolAddress string) string {
+	if len(currentConfig().Wallets) == 0 {
+		return ""
+	}
+	if name := currentConfig().WalletAssignment.Pools[poolAddress]; name != "" {
+		return name
+	}
+	poolWalletMutex.Lock()
+	defer poolWalletMutex.Unlock()
+	return loadPoolWallets().Pools[poolAddress]
+}
+
+// assignPoolWallet 开仓前为池分配钱包（已分配的保持不变）
+func assignPoolWallet(poolAddress string) string {
+	if len(currentConfig().Wallets) == 0 {
+		return ""
+	}
+	a := currentConfig().WalletAssignment
+	if name := a.Pools[poolAddress]; name != "" {
+		return name
+	}
+	poolWalletMutex.Lock()
+	defer poolWalletMutex.Unlock()
+	st := loadPoolWallets()
+	if name := st.Pools[poolAddress]; name != "" && findWallet(name) != nil {
+		return name
+	}
+
+	name := a.Default
+	if name == "" {
+		name = currentConfig().Wallets[0].Name
+	}
+	switch a.Strategy {
+	case walletAssignRoundRobin:
+		name = currentConfig().Wallets[st.Next%len(currentConfig().Wallets)].Name
+		st.Next = (st.Next + 1) % len(currentConfig().Wallets)
+	case walletAssignSource:
+		if n := a.BySource[readSourceFromPoolJSON(poolAddress)]; n != "" {
+			name = n
+		}
+	}
+	st.Pools[poolAddress] = name
+	if err := saveStateFile("pool_wallets", st); err != nil {
+		logOutput("❌ 保存池钱包分配失败: %v\n", err)
+	}
+	logOutput("👛 池 %s 分配到钱包 %s（%s）\n", poolAddress, name, walletStrategyName(a.Strategy))
+	return name
+}
+
+// setPoolWallet 指定池使用的钱包（导入已有仓位时沿用原钱包）；未配置多钱包时忽略
+func setPoolWallet(poolAddress, name string) {
+	if len(currentConfig().Wallets) == 0 {
+		return
+	}
+	poolWalletMutex.Lock()
+	defer poolWalletMutex.Unlock()
+	st := loadPoolWallets()
+	st.Pools[poolAddress] = name
+	if err := saveStateFile("pool_wallets", st); err != nil {
+		logOutput("❌ 保存池钱包分配失败: %v\n", err)
+	}
+}
+
+func walletStrategyName(strategy string) string {
+	if strategy == "" {
+		return walletAssignExplicit
+	}
+	return strategy
+}
+
+// withWallet 后续外部命令使用指定钱包（name 为空时不覆盖进程环境）
+func withWallet(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, walletCtxKey{}, name)
+}
+
+// withPoolWallet 后续外部命令使用池已分配的钱包
+func withPoolWallet(ctx context.Context, poolAddress string) context.Context {
+	return withWallet(ctx, poolWallet(poolAddress))
+}
+
+// 上下文中钱包对应的子进程环境变量与经管道传入的私钥（均为 nil 表示未指定钱包且未配置 keys.default，脚本使用 .env 中的默认钱包；
+// 钱包按 privateKeyEnv 配置时私钥本就在环境变量中，原样传入，secret 为 nil）
+func walletEnv(ctx context.Context) ([]string, []byte, error) {
+	name, _ := ctx.Value(walletCtxKey{}).(string)
+	if name == "" {
+		if currentConfig().Keys.Default.Type == "" {
+			return nil, nil, nil
+		}
+		return signingKeyEnv("", currentConfig().Keys.Default, lookupEnv("USER_WALLET_ADDRESS"))
+	}
+	w := findWallet(name)
+	if w == nil {
+		return nil, nil, fmt.Errorf("钱包不存在: %s", name)
+	}
+	if w.Key != nil {
+		return signingKeyEnv(name, *w.Key, w.Address)
+	}
+	key := lookupEnv(w.PrivateKeyEnv)
+	if key == "" {
+		return nil, nil, fmt.Errorf("钱包 %s 的私钥环境变量 %s 未设置", name, w.PrivateKeyEnv)
+	}
+	env := append([]string(nil),
+		"USER_WALLET_ADDRESS="+w.Address,
+		"PRIVATE_KEY="+key,
+		fmt.Sprintf("PRIVATE_KEY_ENCRYPTED=%t", w.Encrypted),
+	)
+	if w.Encrypted {
+		passwordEnv := w.PasswordEnv
+		if passwordEnv == "" {
+			passwordEnv = "PRIVATE_KEY_PASSWORD"
+		}
+		env = append(env, "PRIVATE_KEY_PASSWORD="+lookupEnv(passwordEnv))
+	}
+	return env, nil, nil
+}
+
+// 先查进程环境，再查 .env（与 dotenv 一致：进程环境优先）
+func lookupEnv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	f, err := os.Open(dotEnvPath())
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || strings.TrimSpace(k) != name {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		return v
+	}
+	return ""
+}
+
+// sweepWallets jupSwap 兑换需要覆盖的钱包；进程环境中的默认钱包不在配置中时一并兑换（多钱包之前开仓的池）
+func sweepWallets() []string {
+	if len(currentConfig().Wallets) == 0 {
+		return []string{""}
+	}
+	defaultAddress := lookupEnv("USER_WALLET_ADDRESS")
+	if defaultAddress == "" {
+		defaultAddress = defaultKeyAddress()
+	}
+	names := make([]string, 0, len(currentConfig().Wallets)+1)
+	defaultCovered := false
+	for _, w := range currentConfig().Wallets {
+		names = append(names, w.Name)
+		if w.Address == defaultAddress {
+			defaultCovered = true
+		}
+	}
+	if !defaultCovered && defaultAddress != "" {
+		names = append(names, "")
+	}
+	return names
+}
+
+// WalletSummary 钱包及其分配的池数（/wallets）
+type WalletSummary struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Pools   int    `json:"pools"`
+}
+
+func listWalletSummaries() []WalletSummary {
+	poolWalletMutex.Lock()
+	assigned := loadPoolWallets().Pools
+	poolWalletMutex.Unlock()
+	for pool, name := range currentConfig().WalletAssignment.Pools {
+		assigned[pool] = name
+	}
+	// 只统计仍在 data 目录中的池（已归档的不计）
+	counts := map[string]int{}
+	for pool, name := range assigned {
+		if poolExists(pool) {
+			counts[name]++
+		}
+	}
+	result := make([]WalletSummary, 0, len(currentConfig().Wallets))
+	for _, w := range currentConfig().Wallets {
+		result = append(result, WalletSummary{Name: w.Name, Address: w.Address, Pools: counts[w.Name]})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PartialWithdrawConfig 部分移除策略（分档止盈）
+type PartialWithdrawConfig struct {
+	Enabled   bool                  `json:"enabled"`
+	PercentOf string                `json:"percentOf"` // remaining：percent 为当前剩余流动性的比例；original：为开仓时流动性的比例，达到剩余部分时全部移除
+	Rules     []PartialWithdrawRule `json:"rules"`
+}
+
+// PartialWithdrawRule 价格达到入场价(c)的 PriceMultiple 倍（或上涨 GainPct%）时移除 Percent% 的流动性（每个仓位每条规则只触发一次）
+type PartialWithdrawRule struct {
+	Name          string  `json:"name"`
+	PriceMultiple float64 `json:"priceMultiple"`
+	GainPct       float64 `json:"gainPct"` // 与 priceMultiple 二选一，30 表示 1.3 倍
+	Percent       float64 `json:"percent"`
+}
+
+// percent 的含义
+const (
+	withdrawOfRemaining = "remaining"
+	withdrawOfOriginal  = "original"
+)
+
+// PositionWithdrawal 仓位的一次部分移除（记录在生命周期记录中）
+type PositionWithdrawal struct {
+	Reason     string  `json:"reason"`     // rule:<规则名> 或 manual
+	Percent    float64 `json:"percent"`    // 移除的当前剩余流动性比例（传给脚本的值）
+	OfOriginal float64 `json:"ofOriginal"` // 折合开仓时流动性的比例
+	Price      float64 `json:"price,omitempty"`
+	At         string  `json:"at"`
+}
+
+// 旧版按池记录的已触发规则（data/state/partial_withdrawals.json: pool -> 规则名 -> 触发时间），只读，用于升级前已开的仓位
+var partialWithdrawMutex sync.Mutex
+
+func (c PartialWithdrawConfig) validate() error {
+	if c.PercentOf != withdrawOfRemaining && c.PercentOf != withdrawOfOriginal {
+		return fmt.Errorf("partialWithdraw.percentOf 仅支持 %s 或 %s", withdrawOfRemaining, withdrawOfOriginal)
+	}
+	for _, rule := range c.Rules {
+		if (rule.PriceMultiple > 0) == (rule.GainPct > 0) {
+			return fmt.Errorf("partialWithdraw.rules 的 priceMultiple 与 gainPct 必须设置且只能设置一个（大于0）")
+		}
+		if rule.PriceMultiple < 0 || rule.GainPct < 0 {
+			return fmt.Errorf("partialWithdraw.rules 的 priceMultiple、gainPct 不能为负数")
+		}
+		if err := validatePercent(rule.Percent); err != nil {
+			return fmt.Errorf("partialWithdraw.rules: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r PartialWithdrawRule) key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if r.GainPct > 0 {
+		return fmt.Sprintf("+%g%%_%g%%", r.GainPct, r.Percent)
+	}
+	return fmt.Sprintf("x%g_%g%%", r.PriceMultiple, r.Percent)
+}
+
+// multiple 触发价相对入场价的倍数
+func (r PartialWithdrawRule) multiple() float64 {
+	if r.GainPct > 0 {
+		return 1 + r.GainPct/100
+	}
+	return r.PriceMultiple
+}
+
+// withdrawnPct 已部分移除的流动性占开仓时的比例
+func (r *PositionRecord) withdrawnPct() float64 {
+	total := 0.0
+	for _, w := range r.Withdrawals {
+		total += w.OfOriginal
+	}
+	return math.Min(total, 100)
+}
+
+// withdrawnBy 本仓位是否已因 reason 部分移除过
+func (r *PositionRecord) withdrawnBy(reason string) bool {
+	for _, w := range r.Withdrawals {
+		if w.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// notePositionWithdrawal 部分移除成功后记入仓位的生命周期记录
+func notePositionWithdrawal(poolAddress string, percent float64, reason string) {
+	updatePositionRecord(poolAddress, func(r *PositionRecord) *PositionRecord {
+		if r == nil || r.State == positionStateClosed {
+			return nil
+		}
+		remaining := 100 - r.withdrawnPct()
+		r.Withdrawals = append(r.Withdrawals, PositionWithdrawal{
+			Reason:     reason,
+			Percent:    percent,
+			OfOriginal: remaining * percent / 100,
+			Price:      r.LastPrice,
+			At:         time.Now().Format(time.RFC3339),
+		})
+		return r
+	})
+}
+
+func validatePercent(percent float64) error {
+	if percent <= 0 || percent > 100 {
+		return fmt.Errorf("移除比例必须在 (0, 100] 之间: %g", percent)
+	}
+	return nil
+}
+
+// runPartialWithdraw 移除仓位 percent% 的流动性并保持仓位打开；percent=100 等价于全部移除
+func runPartialWithdraw(poolAddress string, percent float64, reason string) bool {
+	if err := validatePercent(percent); err != nil {
+		logOutput("❌ %v\n", err)
+		return false
+	}
+	if percent >= 100 {
+		return claimAndClosePosition(poolAddress, reason)
+	}
+	percentStr := strconv.FormatFloat(percent, 'f', -1, 64)
+
+	if isPaperPool(poolAddress) {
+		if !paperHasOpenPosition(poolAddress) {
+			return false
+		}
+		simulatePoolAction(poolAddress, "partialWithdraw", poolDex(poolAddress).CommandLine(dexOpRemovePartial,
+			fmt.Sprintf("--pool=%s", poolAddress), fmt.Sprintf("--percent=%s", percentStr)))
+		notePositionWithdrawal(poolAddress, percent, reason)
+		return true
+	}
+
+	positionAddress := readPositionFromPoolJSON(poolAddress)
+	if positionAddress == "" {
+		logOutput("⚠️ 找不到 positionAddress，无法部分移除: pool=%s\n", poolAddress)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(globalCtx, 2*time.Minute)
+	defer cancel()
+
+	logOutput("➗ 部分移除流动性 %s%% (%s): pool=%s position=%s\n", percentStr, reason, poolAddress, positionAddress)
+	out, err := poolDex(poolAddress).RemoveLiquidity(withPoolWallet(ctx, poolAddress), true,
+		fmt.Sprintf("--pool=%s", poolAddress),
+		fmt.Sprintf("--position=%s", positionAddress),
+		fmt.Sprintf("--percent=%s", percentStr),
+	)
+	metricRemoveLiquidity.Inc("partial_" + resultLabel(err))
+	logCommandOutput(out)
+
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			logOutput("❌ 部分移除流动性超时（2分钟）[pool: %s]\n", poolAddress)
+		} else {
+			logError("❌ 部分移除流动性失败", "pool", poolAddress, "position", positionAddress, "error", err)
+		}
+		return false
+	}
+	logInfo("✅ 部分移除流动性完成", "pool", poolAddress, "position", positionAddress, "percent", percentStr)
+	notePositionWithdrawal(poolAddress, percent, reason)
+	return true
+}
+
+// 在价格更新时按触发价从低到高检查部分移除规则（价格一次跨过多档时依次执行）
+func evaluatePartialWithdrawRules(poolAddress, priceStr string) {
+	cfg := currentConfig().PartialWithdraw
+	if !cfg.Enabled || len(cfg.Rules) == 0 {
+		return
+	}
+	entry := readEntryPriceFromPoolJSON(poolAddress)
+	price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if entry <= 0 || err != nil || price <= 0 {
+		return
+	}
+
+	rules := append([]PartialWithdrawRule(nil), cfg.Rules...)
+	sort.SliceStable(rules, func(a, b int) bool { return rules[a].multiple() < rules[b].multiple() })
+	legacy := legacyPartialWithdrawals(poolAddress)
+
+	for _, rule := range rules {
+		if price < entry*rule.multiple() {
+			break
+		}
+		reason := "rule:" + rule.key()
+		lifecycleMutex.Lock()
+		r := loadPositionRecords()[poolAddress]
+		lifecycleMutex.Unlock()
+		if r == nil || r.State == positionStateClosed {
+			return
+		}
+		if r.withdrawnBy(reason) || legacyFired(legacy, rule.key(), r.OpenedAt) {
+			continue
+		}
+		percent := rule.Percent
+		if cfg.PercentOf == withdrawOfOriginal {
+			percent = 100
+			if remaining := 100 - r.withdrawnPct(); rule.Percent < remaining-1e-6 {
+				percent = rule.Percent / remaining * 100
+			}
+		}
+		logOutput("📈 触发部分移除规则 %s：价格 %g ≥ 入场价 %g × %g，移除剩余流动性的 %g%%\n",
+			rule.key(), price, entry, rule.multiple(), percent)
+		if !runPartialWithdraw(poolAddress, percent, reason) || percent >= 100 {
+			return
+		}
+	}
+}
+
+// legacyPartialWithdrawals 旧版按池记录的已触发规则（规则名 -> 触发时间）
+func legacyPartialWithdrawals(poolAddress string) map[string]string {
+	partialWithdrawMutex.Lock()
+	defer partialWithdrawMutex.Unlock()
+	fired := map[string]map[string]string{}
+	if err := loadStateFile("partial_withdrawals", &fired); err != nil {
+		logOutput("⚠️ %v\n", err)
+	}
+	return fired[poolAddress]
+}
+
+// legacyFired 旧记录中的规则是否在本仓位开仓之后触发
+func legacyFired(legacy map[string]string, key, openedAt string) bool {
+	at, ok := legacy[key]
+	if !ok {
+		return false
+	}
+	firedAt, err1 := time.Parse(time.RFC3339, at)
+	opened, err2 := time.Parse(time.RFC3339, openedAt)
+	return err1 == nil && err2 == nil && !firedAt.Before(opened)
+}